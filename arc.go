@@ -5,17 +5,35 @@
 // structure and deduplication-enabled blob storage. The Radix tree provides
 // space-efficient key management through prefix compression, while the blob
 // storage handles values with automatic deduplication.
+//
+// Iterators, cursors, listings, and navigation methods order the records by
+// their keys in lexicographic byte order, as defined by bytes.Compare. The
+// order only depends on the keys, and is unaffected by the order in which the
+// records were inserted, or by the node splits and merges that the writes
+// cause. Options.ReverseOrder reverses it, and the CaseInsensitiveKeys and
+// LargeKeys options order the records by their case-folded and stored keys.
 package arc
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var (
 	// ErrCorrupted is returned when a database corruption is detected.
 	ErrCorrupted = errors.New("database corruption detected")
 
+	// ErrDatabaseLocked is returned when the database file is locked by another
+	// process that opened it in an incompatible mode.
+	ErrDatabaseLocked = errors.New("database file is locked")
+
 	// ErrDuplicateKey is returned when an insertion is attempted using a
 	// key that already exists in the database.
 	ErrDuplicateKey = errors.New("cannot insert duplicate key")
@@ -23,10 +41,14 @@ var (
 	// ErrInvalidChecksum is returned when the node checksum is invalid.
 	ErrInvalidChecksum = errors.New("invalid checksum detected")
 
+	// ErrInvalidOptions is returned when the given Options are out of range.
+	ErrInvalidOptions = errors.New("invalid options")
+
 	// ErrKeyNotFound is returned when the key does not exist in the index.
 	ErrKeyNotFound = errors.New("key not found")
 
-	// ErrKeyTooLarge is returned when the key size exceeds the 64KB limit.
+	// ErrKeyTooLarge is returned when the key size exceeds the configured
+	// limit, which can be at most 64KB unless LargeKeys is set.
 	ErrKeyTooLarge = errors.New("key is too large")
 
 	// ErrNilKey is returned when an insertion is attempted using a nil key.
@@ -35,10 +57,76 @@ var (
 	// ErrNodeCorrupted is returned when an index node corruption is detected.
 	ErrNodeCorrupted = errors.New("index node corruption detected")
 
-	// ErrValueTooLarge is returned when the value size exceeds the 4GB limit.
+	// ErrReadOnly is returned when a write is attempted on a database that
+	// was opened using OpenReadOnly.
+	ErrReadOnly = errors.New("database is read-only")
+
+	// ErrValueTooLarge is returned when the value size exceeds the configured
+	// limit, which can be at most 4GB.
 	ErrValueTooLarge = errors.New("value is too large")
 )
 
+// SizeError describes a key or value that exceeds the configured size limit.
+// Err is either ErrKeyTooLarge or ErrValueTooLarge, so callers can continue to
+// use errors.Is to detect the failure.
+type SizeError struct {
+	Err   error // ErrKeyTooLarge or ErrValueTooLarge.
+	Size  int   // Size of the offending key or value in bytes.
+	Limit int   // Configured size limit in bytes.
+}
+
+// Error returns the error message including the offending size.
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("%v: %d bytes exceeds the %d-byte limit", e.Err, e.Size, e.Limit)
+}
+
+// Unwrap returns the underlying sentinel error.
+func (e *SizeError) Unwrap() error {
+	return e.Err
+}
+
+// maxOpErrorKeyLen is the number of leading key bytes that an OpError holds.
+const maxOpErrorKeyLen = 64
+
+// OpError describes the failure of an operation that runs hooks, such as Get,
+// Put, and Delete, along with the key that it was given. Err is the error that
+// the operation failed with, which is usually one of the sentinel errors or a
+// SizeError, so callers can continue to use errors.Is and errors.As.
+type OpError struct {
+	Op  Op     // The operation.
+	Key []byte // Copy of the leading bytes of the key, at most 64.
+	Len int    // Length of the full key in bytes.
+	Err error  // The underlying error.
+}
+
+// Error returns the error message including the operation and the key.
+func (e *OpError) Error() string {
+	if e.Len > len(e.Key) {
+		return fmt.Sprintf("%v %q... (%d bytes): %v", e.Op, e.Key, e.Len, e.Err)
+	}
+
+	return fmt.Sprintf("%v %q: %v", e.Op, e.Key, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// wrapOpError returns err wrapped in an OpError, or nil if err is nil. Errors
+// that are already wrapped are returned as is.
+func wrapOpError(op Op, key []byte, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := err.(*OpError); ok {
+		return err
+	}
+
+	return &OpError{Op: op, Key: bytes.Clone(key[:min(len(key), maxOpErrorKeyLen)]), Len: len(key), Err: err}
+}
+
 const (
 	maxUint16     = (1 << 16) - 1 // maxUint16 is the maximum value of uint16.
 	maxUint32     = (1 << 32) - 1 // maxUint32 is the maximum value of uint32.
@@ -51,64 +139,403 @@ const (
 // Arc represents the API interface of a space-efficient key-value database that
 // combines a Radix tree for key indexing and a space-optimized blob store.
 type Arc struct {
-	root       *node        // Pointer to the root node.
-	numNodes   int          // Number of nodes in the tree.
-	numRecords int          // Number of records in the tree.
-	mu         sync.RWMutex // RWLock for concurrency management.
+	root       *node         // Pointer to the root node.
+	numNodes   int           // Number of nodes in the tree.
+	numRecords int           // Number of records in the tree.
+	mu         *sync.RWMutex // RWLock for concurrency management.
+	opts       Options       // Normalized database options.
 
 	// Stores deduplicated values that are larger than 32 bytes.
 	blobs blobStore
+
+	// Allocates the keys of the nodes that are inserted into the tree.
+	keys *keyArena
+
+	// Maps blobIDs to the keys of the records that reference them. Only
+	// used when the TrackBlobReferences option is enabled.
+	blobRefs blobRefs
+
+	// Maps record keys to their timestamps. Only used when the
+	// RecordTimestamps option is enabled.
+	timestamps map[string]*recordTimestamps
+
+	// Maps record keys to their expiration times. Only holds the records
+	// that expire.
+	expirations map[string]time.Time
+
+	// Maps record keys to their previous values, from the most recent to
+	// the oldest. Only used when the VersionsToKeep option is enabled.
+	versions map[string][]*node
+
+	// Maps the keys of the deleted records to their tombstones. Only used
+	// when the TombstoneRetention option is enabled.
+	tombstones map[string]tombstone
+
+	// User metadata set by SetMeta, which is stored in the file header.
+	meta map[string][]byte
+
+	// Compresses the blob values in the database file. Nil unless a
+	// dictionary was trained using TrainDictionary.
+	dictionary []byte
+
+	// Maps folded record keys to the spelling that they were inserted with.
+	// Only used when the CaseInsensitiveKeys option is enabled, and only
+	// holds the keys that were not spelled in their folded form.
+	originalKeys map[string][]byte
+
+	// Stops the expiration sweeper, and is closed once it has exited. Both
+	// are nil unless the ExpirationSweepInterval option is set.
+	sweepStop chan struct{}
+	sweepDone chan struct{}
+
+	// Tracks the record usage for eviction. Nil unless a capacity limit is
+	// configured.
+	usage *usageTracker
+
+	// Maps nodes to the cached hashes of their subtrees. Nil unless the
+	// TrackSubtreeHashes option is enabled, and only holds up-to-date hashes.
+	hashes map[*node]subtreeHash
+
+	// Distributions of the key and value sizes. Nil unless the
+	// TrackSizeHistograms option is set.
+	sizes *sizeHistograms
+
+	// Sampled read counts of the records. Nil unless the ReadSampleRate
+	// option is set.
+	reads *readCounts
+
+	// File that the TieringPolicy demotes the blobs to, which is shared with
+	// the clones, and the channels that stop the goroutine that demotes and
+	// promotes the blobs. Nil unless the Tiering option is set.
+	tier     *blobTier
+	tierStop chan struct{}
+	tierDone chan struct{}
+
+	// Counting Bloom filter over the record keys. Nil unless the
+	// BloomFilterBitsPerKey option is set.
+	filter *keyFilter
+
+	// Maps index names to the secondary indexes.
+	indexes map[string]*index
+
+	// Hooks registered with Use. The slice is replaced rather than modified,
+	// which allows the hooks to be loaded without holding the lock.
+	hooks atomic.Pointer[[]Hook]
+
+	// Returns the current time. Tests may override it for determinism.
+	now func() time.Time
+
+	// Path of the database file. Empty unless the database was opened using
+	// Open, in which case Save writes to it.
+	path string
+
+	// Number of write operations applied so far, and the value that it had
+	// when the database was last saved. They differ when there are unsaved
+	// writes.
+	seq      uint64
+	savedSeq uint64
+
+	// Number of changes to the persisted metadata, such as the compression
+	// dictionary, which are not replicated and therefore do not increment
+	// seq, and the value that it had when the database was last saved.
+	metaSeq      uint64
+	savedMetaSeq uint64
+
+	// Maps the keys written while optimistic transactions are active to the
+	// sequence number of their last write, which allows the transactions to
+	// detect conflicts. Nil while no optimistic transaction is active.
+	txnWrites      map[string]uint64
+	optimisticTxns int
+
+	// Maps key prefixes to the quotas set by SetQuota.
+	quotas map[string]*Quota
+
+	// Set while the writes are throttled by the BackpressurePolicy. Writes
+	// that wait for a save receive from saved, which is closed by the save.
+	throttled bool
+	saved     chan struct{}
+
+	// Write epoch of the database file, which is incremented by every save
+	// and tags the pages of the file.
+	epoch uint64
+
+	// Container that the database is a namespace of, if any. Namespaces
+	// share the lock and the blobStore of their container.
+	container *Container
+
+	// Counts the databases that share their structure with this one since
+	// Clone, including this one. Nil unless the database was cloned or is a
+	// clone, and reset once the database has its own copy of the structure.
+	shared *atomic.Int32
+
+	// Serializes Save, Compact, and Close.
+	saveMu sync.Mutex
+
+	// Manifest entry of the database file as of the last save or load. Nil
+	// unless the Manifest option is set and the file has a manifest.
+	// Guarded by saveMu.
+	manifest *manifestEntry
+
+	// Contention on mu, which ProfileSnapshot reports.
+	contention lockContention
+
+	// Rejects writes with ErrReadOnly. Set by OpenReadOnly and NewReplica.
+	readOnly bool
+
+	// Set by OpenReadOnly. Immutable databases never change, therefore their
+	// readers skip the lock acquisition.
+	immutable bool
+
+	// Lock file that coordinates the processes that open the same database
+	// file. Nil unless the database was opened from a file.
+	lockFile *os.File
+
+	// Streams of the connected replicas, guarded by replicasMu.
+	replicas   map[*replicationStream]struct{}
+	replicasMu sync.Mutex
+
+	// Connection to the primary. Nil unless the database is a replica.
+	follower *follower
+
+	// Stops the background compaction, and is closed once it has exited.
+	// Both are nil unless a CompactionPolicy is in effect.
+	compactStop chan struct{}
+	compactDone chan struct{}
+
+	// Stops the background syncer, and is closed once it has exited. Both
+	// are nil unless the SyncInterval policy is in effect.
+	syncStop chan struct{}
+	syncDone chan struct{}
+
+	// Open commitGroup of the SyncGroupCommit policy, if any, which groupMu
+	// guards.
+	groupMu sync.Mutex
+	group   *commitGroup
+
+	// Goroutine that applies the writes queued by PutAsync, if started,
+	// which asyncMu guards.
+	asyncMu sync.Mutex
+	async   *asyncWriter
 }
 
-// New returns an empty Arc database handler.
+// New returns an empty Arc database handler with the default options.
 func New() *Arc {
-	return &Arc{blobs: blobStore{}}
+	ret, _ := NewWithOptions(Options{})
+	return ret
 }
 
-// Len returns the number of records.
-func (a *Arc) Len() int {
+// NewWithOptions returns an empty Arc database handler configured with the
+// given options. It returns ErrInvalidOptions if the options are out of range.
+func NewWithOptions(opts Options) (*Arc, error) {
+	ret, err := newArc(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret.startSweeper()
+	ret.startTiering()
+
+	return ret, nil
+}
+
+// newArc is like NewWithOptions, but does not start the background work. This
+// allows the caller to finish setting up the database beforehand.
+func newArc(opts Options) (*Arc, error) {
+	opts, err := opts.normalize()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &Arc{mu: &sync.RWMutex{}, blobs: blobStore{}, keys: &keyArena{}, opts: opts, now: time.Now}
+
+	if opts.RecordTimestamps {
+		ret.timestamps = map[string]*recordTimestamps{}
+	}
+
+	if opts.CaseInsensitiveKeys {
+		ret.originalKeys = map[string][]byte{}
+	}
+
+	if ret.evictionEnabled() {
+		ret.usage = newUsageTracker()
+	}
+
+	if opts.TrackSubtreeHashes {
+		ret.hashes = map[*node]subtreeHash{}
+	}
+
+	if opts.BloomFilterBitsPerKey > 0 {
+		ret.filter = newKeyFilter(opts.BloomFilterBitsPerKey, 0)
+	}
+
+	if opts.TrackBlobReferences {
+		ret.blobRefs = blobRefs{}
+	}
+
+	if opts.TrackSizeHistograms {
+		ret.sizes = &sizeHistograms{}
+	}
+
+	if opts.ReadSampleRate > 0 {
+		ret.reads = newReadCounts()
+	}
+
+	if opts.Tiering.Path != "" {
+		ret.tier = newBlobTier(opts)
+	}
+
+	return ret, nil
+}
+
+// lock acquires the write lock, and gives the database its own copy of the
+// structure that it shares with its clones before the caller modifies it.
+func (a *Arc) lock() {
+	if !a.mu.TryLock() {
+		start := time.Now()
+		a.mu.Lock()
+		a.contention.waits.Add(1)
+		a.contention.waitNanos.Add(int64(time.Since(start)))
+	}
+
+	a.unshare()
+}
+
+// rlock acquires the read lock, unless the database is immutable.
+func (a *Arc) rlock() {
+	if a.immutable || a.mu.TryRLock() {
+		return
+	}
+
+	start := time.Now()
 	a.mu.RLock()
-	defer a.mu.RUnlock()
+	a.contention.readWaits.Add(1)
+	a.contention.readWaitNanos.Add(int64(time.Since(start)))
+}
+
+// runlock releases the read lock acquired by rlock.
+func (a *Arc) runlock() {
+	if !a.immutable {
+		a.mu.RUnlock()
+	}
+}
+
+// Len returns the number of records. Expired records are not counted, which
+// takes time proportional to the number of records that expire.
+func (a *Arc) Len() int {
+	a.rlock()
+	defer a.runlock()
 
-	return a.numRecords
+	return a.numRecords - a.countExpired(nil)
 }
 
 // Add inserts a new key-value pair in the database. It returns ErrDuplicateKey
 // if the key already exists.
 func (a *Arc) Add(key []byte, value []byte) error {
-	a.mu.Lock()
-	defer a.mu.Unlock()
+	key = a.applyKeyTransform(key)
+
+	return a.runHooks(OpInfo{Op: OpAdd, Key: key, ValueSize: len(value)}, func(*OpInfo) error {
+		if a.readOnly {
+			return ErrReadOnly
+		}
+
+		a.lock()
+		defer a.mu.Unlock()
 
-	return a.insert(key, value, false)
+		return a.putRecord(key, value, false)
+	})
 }
 
-// Put inserts or updates a key-value pair in the database.
+// Put inserts or updates a key-value pair in the database. A nil value and an
+// empty value are distinct, and Get returns the one that was put.
 func (a *Arc) Put(key []byte, value []byte) error {
-	a.mu.Lock()
-	defer a.mu.Unlock()
+	key = a.applyKeyTransform(key)
+
+	return a.runHooks(OpInfo{Op: OpPut, Key: key, ValueSize: len(value)}, func(*OpInfo) error {
+		if a.readOnly {
+			return ErrReadOnly
+		}
+
+		a.lock()
+		defer a.mu.Unlock()
+
+		return a.putRecord(key, value, true)
+	})
+}
+
+// PutReader inserts or updates a key-value pair in the database, reading the
+// value from r until EOF. Reading stops as soon as the value exceeds the size
+// limit, in which case a *SizeError wrapping ErrValueTooLarge is returned.
+// Since the stream is not drained, the reported size is a lower bound.
+func (a *Arc) PutReader(key []byte, r io.Reader) error {
+	if err := a.checkKey(key); err != nil {
+		return err
+	}
+
+	limit := a.opts.MaxValueBytes
+	value, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+
+	if err != nil {
+		return err
+	}
+
+	if len(value) > limit {
+		return &SizeError{Err: ErrValueTooLarge, Size: len(value), Limit: limit}
+	}
+
+	return a.Put(key, value)
+}
 
-	return a.insert(key, value, true)
+// checkKey returns an error if the given key is nil or exceeds the key size
+// limit. The options are immutable, therefore the lock does not need to be
+// held when calling this function.
+func (a *Arc) checkKey(key []byte) error {
+	if key == nil {
+		return ErrNilKey
+	}
+
+	if len(key) > a.opts.MaxKeyBytes {
+		return &SizeError{Err: ErrKeyTooLarge, Size: len(key), Limit: a.opts.MaxKeyBytes}
+	}
+
+	return nil
+}
+
+// checkValue returns an error if the given value exceeds the size limit.
+func (a *Arc) checkValue(value []byte) error {
+	if len(value) > a.opts.MaxValueBytes {
+		return &SizeError{Err: ErrValueTooLarge, Size: len(value), Limit: a.opts.MaxValueBytes}
+	}
+
+	return nil
 }
 
 // insert adds a key-value pair to the database. If the key already exists and
 // overwrite is true, the existing value is updated. If overwrite is false and
 // the key exists, ErrDuplicateKey is returned. It returns nil on success.
 func (a *Arc) insert(key []byte, value []byte, overwrite bool) error {
-	if key == nil {
-		return ErrNilKey
+	if err := a.checkKey(key); err != nil {
+		return err
 	}
 
-	if len(key) > maxKeyBytes {
-		return ErrKeyTooLarge
+	if err := a.checkValue(value); err != nil {
+		return err
 	}
 
-	if len(value) > maxValueBytes {
-		return ErrValueTooLarge
+	if err := a.checkHashCollision(value); err != nil {
+		return err
+	}
+
+	if err := a.checkShape(key); err != nil {
+		return err
 	}
 
+	defer a.reclaimKeys()
+
 	// Empty tree, set the new record node as the root node.
 	if a.empty() {
-		a.root = newRecordNode(a.blobs, key, value)
+		a.root = a.newRecordNode(key, value)
 		a.numNodes = 1
 		a.numRecords = 1
 
@@ -121,7 +548,7 @@ func (a *Arc) insert(key []byte, value []byte, overwrite bool) error {
 
 		a.root = &node{key: nil}
 		a.root.addChild(oldRoot)
-		a.root.addChild(newRecordNode(a.blobs, key, value))
+		a.root.addChild(a.newRecordNode(key, value))
 
 		a.numNodes += 2
 		a.numRecords++
@@ -147,7 +574,7 @@ func (a *Arc) insert(key []byte, value []byte, overwrite bool) error {
 				a.numRecords++
 			}
 
-			current.setValue(a.blobs, value)
+			current.setValue(a.blobs, a.makeBlobID, value)
 
 			return nil
 		}
@@ -160,10 +587,12 @@ func (a *Arc) insert(key []byte, value []byte, overwrite bool) error {
 		// "le", and then becomes a child of the "app" node, forming the path:
 		// ["app"(new node) -> "le"(current)].
 		if prefixLen == len(key) && prefixLen < len(current.key) {
+			a.forgetHash(current)
+
 			if current == a.root {
 				current.setKey(current.key[len(key):])
 
-				a.root = newRecordNode(a.blobs, key, value)
+				a.root = a.newRecordNode(key, value)
 				a.root.addChild(current)
 			} else {
 				if err := parent.removeChild(current); err != nil {
@@ -172,7 +601,7 @@ func (a *Arc) insert(key []byte, value []byte, overwrite bool) error {
 
 				current.setKey(current.key[len(key):])
 
-				n := newRecordNode(a.blobs, key, value)
+				n := a.newRecordNode(key, value)
 				n.addChild(current)
 
 				parent.addChild(n)
@@ -186,7 +615,7 @@ func (a *Arc) insert(key []byte, value []byte, overwrite bool) error {
 
 		// Partial match with key exhaustion: Insert via node splitting.
 		if prefixLen > 0 && prefixLen < len(current.key) {
-			a.splitNode(parent, current, newRecordNode(a.blobs, key, value), prefix)
+			a.splitNode(parent, current, a.newRecordNode(key, value), prefix)
 			return nil
 		}
 
@@ -206,10 +635,10 @@ func (a *Arc) insert(key []byte, value []byte, overwrite bool) error {
 		if nextNode == nil {
 			if current == a.root {
 				if a.root.key == nil || prefixLen == len(a.root.key) {
-					a.root.addChild(newRecordNode(a.blobs, key, value))
+					a.root.addChild(a.newRecordNode(key, value))
 				}
 			} else {
-				current.addChild(newRecordNode(a.blobs, key, value))
+				current.addChild(a.newRecordNode(key, value))
 			}
 
 			a.numNodes++
@@ -225,15 +654,45 @@ func (a *Arc) insert(key []byte, value []byte, overwrite bool) error {
 	}
 }
 
-// Get retrieves the value that matches the given key. Returns ErrKeyNotFound
-// if the key does not exist.
+// Get retrieves the value that matches the given key. The value is nil if the
+// record was put with a nil value, and an empty non-nil slice if it was put
+// with an empty value. Use Has to check for a record without reading its
+// value. Returns ErrKeyNotFound if the key does not exist.
 func (a *Arc) Get(key []byte) ([]byte, error) {
-	if key == nil {
-		return nil, ErrNilKey
+	var ret []byte
+
+	key = a.transformKey(key)
+
+	err := a.runHooks(OpInfo{Op: OpGet, Key: key}, func(info *OpInfo) error {
+		var err error
+
+		ret, err = a.get(key)
+		info.ValueSize = len(ret)
+
+		return err
+	})
+
+	return ret, err
+}
+
+// get implements Get without running the hooks.
+func (a *Arc) get(key []byte) ([]byte, error) {
+	if err := a.checkKey(key); err != nil {
+		return nil, err
 	}
 
-	a.mu.RLock()
-	defer a.mu.RUnlock()
+	a.rlock()
+	defer a.runlock()
+
+	return a.lookup(key)
+}
+
+// lookup returns the value of the live record that matches the given key.
+// The caller must hold the read lock.
+func (a *Arc) lookup(key []byte) ([]byte, error) {
+	if a.filter != nil && !a.filter.mayContain(key) {
+		return nil, ErrKeyNotFound
+	}
 
 	node, _, err := a.findNodeAndParent(key)
 
@@ -241,29 +700,215 @@ func (a *Arc) Get(key []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	if !node.isRecord {
+	if !node.isRecord || a.expired(key) {
 		return nil, ErrKeyNotFound
 	}
 
-	return node.value(a.blobs), nil
+	a.touchUsage(node)
+	a.countRead(key)
+	a.touchBlob(node)
+
+	return a.nodeValue(node)
+}
+
+// nodeValue returns a copy of the node's value. Blob values are verified
+// against their blobID, which is the hash of the content, unless the
+// SkipBlobVerification option is set. It returns ErrCorrupted if the blob is
+// missing or its content no longer matches the blobID.
+func (a *Arc) nodeValue(n *node) ([]byte, error) {
+	ret := n.value(a.blobs)
+
+	if !n.blobValue {
+		return ret, nil
+	}
+
+	// Blob values are never empty since they exceed the inline threshold.
+	if ret == nil {
+		a.log(slog.LevelWarn, "blob is missing", "blob_id", fmt.Sprintf("%x", n.data))
+		return nil, ErrCorrupted
+	}
+
+	if !a.opts.SkipBlobVerification && !bytes.Equal(n.data, a.makeBlobID(ret).Slice()) {
+		a.log(slog.LevelWarn, "blob content does not match its ID", "blob_id", fmt.Sprintf("%x", n.data))
+		return nil, ErrCorrupted
+	}
+
+	return ret, nil
 }
 
 // Delete removes a record that matches the given key.
 func (a *Arc) Delete(key []byte) error {
-	if key == nil {
-		return ErrNilKey
+	key = a.transformKey(key)
+
+	return a.runHooks(OpInfo{Op: OpDelete, Key: key}, func(*OpInfo) error {
+		if err := a.checkKey(key); err != nil {
+			return err
+		}
+
+		if a.readOnly {
+			return ErrReadOnly
+		}
+
+		a.lock()
+		defer a.mu.Unlock()
+
+		return a.deleteRecord(key)
+	})
+}
+
+// putRecord inserts the record into the tree, and then updates the record
+// metadata and the secondary indexes. The caller must hold the write lock.
+func (a *Arc) putRecord(key []byte, value []byte, overwrite bool) error {
+	// Case-insensitive keys are stored folded, and the spelling that a record
+	// was first inserted with is kept aside.
+	original := key
+
+	if err := a.checkLargeKey(original); err != nil {
+		return err
 	}
 
-	if a.empty() {
-		return ErrKeyNotFound
+	key = a.foldKey(key)
+	inserted := a.opts.CaseInsensitiveKeys && !a.isLiveRecord(key)
+
+	// Expired records are overwritten as if they had already been deleted.
+	expired := a.expired(key)
+
+	if expired {
+		overwrite = true
+	}
+
+	quotaRecords, quotaBytes := a.quotaDelta(key, len(value))
+
+	if err := a.checkQuotas(key, quotaRecords, quotaBytes); err != nil {
+		return err
+	}
+
+	updates, err := a.planIndexUpdates(key, value, false)
+
+	if err != nil {
+		return err
+	}
+
+	var blobRef blobID
+	var hadBlobRef bool
+
+	if a.blobRefs != nil {
+		blobRef, hadBlobRef = a.recordBlobID(key)
+	}
+
+	var previousSize recordSize
+	var hadPrevious bool
+
+	if a.sizes != nil {
+		previousSize, hadPrevious = a.recordSize(key)
+	}
+
+	// The value of an expired record is not kept as a previous value.
+	var previous *node
+
+	if !expired {
+		previous = a.detachValue(key)
+	}
+
+	numRecords := a.numRecords
+
+	if err := a.insert(key, value, overwrite); err != nil {
+		if previous != nil {
+			previous.deleteValue(a.blobs)
+		}
+
+		return err
+	}
+
+	if a.numRecords > numRecords {
+		a.filterAdd(key)
+		a.retainLargeKey(key, original)
+	}
+
+	a.updateBlobRef(key, blobRef, hadBlobRef)
+
+	if a.sizes != nil {
+		if hadPrevious {
+			a.sizes.remove(previousSize)
+		}
+
+		a.sizes.add(recordSize{keyLen: len(key), valueLen: len(value), blob: len(value) > inlineValueThreshold})
+	}
+
+	a.chargeQuotas(key, quotaRecords, quotaBytes)
+
+	if expired {
+		a.setUserFlags(key, 0)
+		a.forgetVersions(key)
+	}
+
+	if previous != nil {
+		a.keepVersion(key, previous)
 	}
 
-	if len(key) > maxKeyBytes {
-		return ErrKeyTooLarge
+	delete(a.expirations, string(key))
+	delete(a.tombstones, string(key))
+	a.touchRecord(key)
+
+	if inserted {
+		a.rememberOriginalKey(key, original)
 	}
 
-	a.mu.Lock()
-	defer a.mu.Unlock()
+	a.refreshSubtreeRecords(key)
+	a.refreshSubtreeHashes(key)
+	a.applyIndexUpdates(updates)
+	a.seq++
+	a.noteTxnWrite(key)
+	a.publishChange(OpPut, a.originalKey(key), value)
+	a.trackUsage(key, len(value))
+
+	return nil
+}
+
+// deleteRecord removes the record from the tree, and then discards the record
+// metadata and the secondary index entries. The caller must hold the write
+// lock.
+func (a *Arc) deleteRecord(key []byte) error {
+	updates, err := a.planIndexUpdates(key, nil, true)
+
+	if err != nil {
+		return err
+	}
+
+	quotaRecords, quotaBytes := a.quotaDelta(key, -1)
+
+	// The record is uncounted up front, since deleting the last record
+	// clears the tree along with the size histograms.
+	if a.sizes != nil {
+		if size, found := a.recordSize(key); found {
+			a.sizes.remove(size)
+		}
+	}
+
+	if err := a.delete(key); err != nil {
+		return err
+	}
+
+	a.chargeQuotas(key, quotaRecords, quotaBytes)
+
+	a.seq++
+	a.buryRecord(key)
+	a.forgetRecord(key)
+	a.refreshSubtreeRecords(key)
+	a.refreshSubtreeHashes(key)
+	a.applyIndexUpdates(updates)
+	a.noteTxnWrite(key)
+	a.publishChange(OpDelete, key, nil)
+
+	return nil
+}
+
+// delete removes the record that matches the given key from the tree, while
+// keeping the tree structure compressed. The caller must hold the write lock.
+func (a *Arc) delete(key []byte) error {
+	if a.empty() {
+		return ErrKeyNotFound
+	}
 
 	delNode, parent, err := a.findNodeAndParent(key)
 
@@ -275,6 +920,21 @@ func (a *Arc) Delete(key []byte) error {
 		return ErrKeyNotFound
 	}
 
+	defer a.reclaimKeys()
+
+	// Every deletion path discards the value, therefore release it up front
+	// to keep the blob reference counts accurate. The user flags belong to
+	// the record, and must not carry over to a record inserted later.
+	if a.blobRefs != nil && delNode.blobValue {
+		a.blobRefs.remove(blobID(delNode.data), key)
+	}
+
+	delNode.deleteValue(a.blobs)
+	delNode.userFlags = 0
+	a.forgetUsage(delNode)
+	a.filterRemove(key)
+	a.releaseLargeKey(key)
+
 	// Root node deletion is handled separately to improve code readability.
 	if delNode == a.root {
 		a.deleteRootNode()
@@ -294,9 +954,12 @@ func (a *Arc) Delete(key []byte) error {
 		}
 
 		child := delNode.firstChild
-		child.prependKey(delNode.key)
+		child.prependKey(a.keys, delNode.key)
 		parent.addChild(child)
 
+		a.forgetHash(delNode)
+		a.forgetHash(child)
+
 		a.numNodes--
 		a.numRecords--
 
@@ -311,6 +974,7 @@ func (a *Arc) Delete(key []byte) error {
 			return err
 		}
 
+		a.forgetHash(delNode)
 		a.numNodes--
 		a.numRecords--
 
@@ -319,7 +983,7 @@ func (a *Arc) Delete(key []byte) error {
 		// parent and the only-child nodes.
 		if !parent.isRecord && parent.numChildren == 1 {
 			child := parent.firstChild
-			child.prependKey(parent.key)
+			child.prependKey(a.keys, parent.key)
 
 			// Save the parent's sibling before overwriting it.
 			sibling := parent.nextSibling
@@ -330,6 +994,9 @@ func (a *Arc) Delete(key []byte) error {
 			parent.shallowCopyFrom(child)
 			parent.nextSibling = sibling
 
+			a.forgetHash(parent)
+			a.forgetHash(child)
+
 			// Decrement for removing the parent node.
 			a.numNodes--
 		}
@@ -340,7 +1007,6 @@ func (a *Arc) Delete(key []byte) error {
 	// Reaching this point means we are deleting a non-root internal node
 	// that has more than one edges. Convert the node to a non-record type.
 	delNode.isRecord = false
-	delNode.deleteValue(a.blobs)
 
 	a.numRecords--
 
@@ -358,8 +1024,10 @@ func (a *Arc) deleteRootNode() {
 	if a.root.numChildren == 1 {
 		// The root node only has one child, which will become the new root.
 		child := a.root.firstChild
-		child.prependKey(a.root.key)
+		child.prependKey(a.keys, a.root.key)
 
+		a.forgetHash(a.root)
+		a.forgetHash(child)
 		a.root = child
 
 		// Decrement for the original root node removal.
@@ -368,9 +1036,8 @@ func (a *Arc) deleteRootNode() {
 	} else {
 		// The root node has multiple children, thus it must continue to exist
 		// for the tree to sustain its structure. Convert it to a non-record
-		// node by removing its value and flagging it as a non-record node.
+		// node. The caller has already released the value.
 		a.root.isRecord = false
-		a.root.deleteValue(a.blobs)
 	}
 
 	a.numRecords--
@@ -382,7 +1049,50 @@ func (a *Arc) clear() {
 	a.root = nil
 	a.numNodes = 0
 	a.numRecords = 0
-	a.blobs = blobStore{}
+	a.expirations = nil
+	a.keys = &keyArena{}
+
+	// The blobStore of a namespace is shared with the other namespaces of
+	// its Container.
+	if a.container == nil {
+		a.blobs = blobStore{}
+	}
+
+	if a.usage != nil {
+		a.usage = newUsageTracker()
+	}
+
+	if a.hashes != nil {
+		a.hashes = map[*node]subtreeHash{}
+	}
+
+	if a.timestamps != nil {
+		a.timestamps = map[string]*recordTimestamps{}
+	}
+
+	if a.originalKeys != nil {
+		a.originalKeys = map[string][]byte{}
+	}
+
+	if a.filter != nil {
+		a.filter = newKeyFilter(a.opts.BloomFilterBitsPerKey, 0)
+	}
+
+	if a.blobRefs != nil {
+		a.blobRefs = blobRefs{}
+	}
+
+	if a.sizes != nil {
+		a.sizes = &sizeHistograms{}
+	}
+
+	if a.reads != nil {
+		a.reads = newReadCounts()
+	}
+
+	for _, idx := range a.indexes {
+		idx.tree.clear()
+	}
 }
 
 // empty returns true if the database is empty.
@@ -399,6 +1109,8 @@ func (a *Arc) empty() bool {
 func (a *Arc) splitNode(parent *node, current *node, newNode *node, commonPrefix []byte) {
 	newParent := &node{key: commonPrefix}
 
+	a.forgetHash(current)
+
 	// Splitting the root node only requires setting the new branch as root.
 	if current == a.root {
 		current.setKey(current.key[len(commonPrefix):])
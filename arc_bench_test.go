@@ -0,0 +1,265 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// benchRecords is the number of records that the read benchmarks preload.
+const benchRecords = 100_000
+
+func BenchmarkPutSequential(b *testing.B) {
+	arc := New()
+	value := []byte("value")
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		arc.Put(sequentialKey(i), value)
+	}
+}
+
+func BenchmarkPutRandom(b *testing.B) {
+	keys := randomKeys(b.N, 16)
+	arc := New()
+	value := []byte("value")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		arc.Put(keys[i], value)
+	}
+}
+
+func BenchmarkPutKeyLength(b *testing.B) {
+	for _, keyLen := range []int{8, 32, 128, 1024} {
+		b.Run(fmt.Sprintf("len=%d", keyLen), func(b *testing.B) {
+			keys := randomKeys(b.N, keyLen)
+			arc := New()
+			value := []byte("value")
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				arc.Put(keys[i], value)
+			}
+		})
+	}
+}
+
+// BenchmarkPutFanout writes keys that share a prefix and differ in a single
+// byte, which controls the number of children per node.
+func BenchmarkPutFanout(b *testing.B) {
+	for _, fanout := range []int{2, 16, 256} {
+		b.Run(fmt.Sprintf("fanout=%d", fanout), func(b *testing.B) {
+			arc := New()
+			value := []byte("value")
+
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				key := []byte("prefix/")
+
+				for n := i; ; n /= fanout {
+					key = append(key, byte(n%fanout))
+
+					if n < fanout {
+						break
+					}
+				}
+
+				arc.Put(key, value)
+			}
+		})
+	}
+}
+
+func BenchmarkPutBlob(b *testing.B) {
+	for _, size := range []int{64, 4096, 65536} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			arc := New()
+
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+
+			for i := 0; i < b.N; i++ {
+				arc.Put(sequentialKey(i), uniqueValue(i, size))
+			}
+		})
+	}
+}
+
+func BenchmarkGetHit(b *testing.B) {
+	arc, keys := benchTree(benchRecords, 16)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := arc.Get(keys[i%len(keys)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetMiss(b *testing.B) {
+	arc, _ := benchTree(benchRecords, 16)
+	misses := randomKeys(benchRecords, 17)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		arc.Get(misses[i%len(misses)])
+	}
+}
+
+// BenchmarkGetMissDeep looks up absent keys that extend existing keys, which
+// descend the tree all the way, with and without the Bloom filter.
+func BenchmarkGetMissDeep(b *testing.B) {
+	for _, bitsPerKey := range []int{0, 10} {
+		b.Run(fmt.Sprintf("bloom=%d", bitsPerKey), func(b *testing.B) {
+			arc, _ := NewWithOptions(Options{BloomFilterBitsPerKey: bitsPerKey})
+			keys := randomKeys(benchRecords, 16)
+			misses := make([][]byte, len(keys))
+
+			for i, key := range keys {
+				arc.Put(key, []byte("value"))
+				misses[i] = append(key[:len(key):len(key)], 'x')
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				arc.Get(misses[i%len(misses)])
+			}
+		})
+	}
+}
+
+func BenchmarkGetBlob(b *testing.B) {
+	arc := New()
+	size := 4096
+
+	for i := 0; i < benchRecords; i++ {
+		arc.Put(sequentialKey(i), uniqueValue(i, size))
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := arc.Get(sequentialKey(i % benchRecords)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkMixed runs concurrent workloads with the given percentage of
+// writes, which exercises the lock contention between readers and writers.
+func BenchmarkMixed(b *testing.B) {
+	for _, writePercent := range []int{0, 10, 50} {
+		b.Run(fmt.Sprintf("writes=%d%%", writePercent), func(b *testing.B) {
+			arc, keys := benchTree(benchRecords, 16)
+			value := []byte("value")
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				rng := rand.New(rand.NewSource(rand.Int63()))
+
+				for pb.Next() {
+					key := keys[rng.Intn(len(keys))]
+
+					if rng.Intn(100) < writePercent {
+						arc.Put(key, value)
+					} else {
+						arc.Get(key)
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkScan(b *testing.B) {
+	arc, _ := benchTree(benchRecords, 16)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for range arc.Scan(nil) {
+		}
+	}
+}
+
+func BenchmarkDelete(b *testing.B) {
+	keys := randomKeys(b.N, 16)
+	arc := New()
+	value := []byte("value")
+
+	for _, key := range keys {
+		arc.Put(key, value)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		arc.Delete(keys[i])
+	}
+}
+
+// benchTree returns a database that holds n records with random keys of the
+// given length, along with the keys.
+func benchTree(n int, keyLen int) (*Arc, [][]byte) {
+	arc := New()
+	keys := randomKeys(n, keyLen)
+	value := []byte("value")
+
+	for _, key := range keys {
+		arc.Put(key, value)
+	}
+
+	return arc, keys
+}
+
+// randomKeys returns n deterministic random keys of the given length.
+func randomKeys(n int, keyLen int) [][]byte {
+	rng := rand.New(rand.NewSource(int64(keyLen)))
+	ret := make([][]byte, n)
+
+	for i := range ret {
+		ret[i] = make([]byte, keyLen)
+		rng.Read(ret[i])
+	}
+
+	return ret
+}
+
+// sequentialKey returns the big-endian encoding of i, which sorts in the same
+// order as i.
+func sequentialKey(i int) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(i))
+}
+
+// uniqueValue returns a value of the given size that is unique to i, which
+// defeats the blob deduplication. A new slice is returned every time, since
+// the database retains the values that it is given.
+func uniqueValue(i int, size int) []byte {
+	ret := make([]byte, size)
+	binary.LittleEndian.PutUint64(ret, uint64(i))
+
+	return ret
+}
@@ -9,6 +9,8 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
 )
 
@@ -40,6 +42,7 @@ func TestLongestCommonPrefix(t *testing.T) {
 
 func TestSplitNode(t *testing.T) {
 	arc := &Arc{
+		mu:         &sync.RWMutex{},
 		root:       &node{key: []byte("apple")},
 		numNodes:   1,
 		numRecords: 1,
@@ -128,7 +131,7 @@ func TestAdd(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			arc := basicTestTree()
 
-			if err := arc.Add(tc.key, nil); err != tc.want {
+			if err := arc.Add(tc.key, nil); !errors.Is(err, tc.want) {
 				t.Errorf("unexpected error: got:%v, want:%v", err, tc.want)
 			}
 		})
@@ -528,12 +531,12 @@ func TestGet(t *testing.T) {
 	}
 
 	// Test a key that do not exist.
-	if _, err := arc.Get([]byte("bogus")); err != ErrKeyNotFound {
+	if _, err := arc.Get([]byte("bogus")); !errors.Is(err, ErrKeyNotFound) {
 		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
 	}
 
 	// Test nil key.
-	if _, err := arc.Get(nil); err != ErrNilKey {
+	if _, err := arc.Get(nil); !errors.Is(err, ErrNilKey) {
 		t.Errorf("unexpected error: got:%v, want:%v", err, ErrNilKey)
 	}
 }
@@ -1001,7 +1004,7 @@ func TestDelete(t *testing.T) {
 					expected = ErrKeyNotFound
 				}
 
-				if _, err := arc.Get(record.key); err != expected {
+				if _, err := arc.Get(record.key); !errors.Is(err, expected) {
 					t.Fatalf("unexpected error: got:%v, want:%v", err, expected)
 				}
 			}
@@ -1011,7 +1014,7 @@ func TestDelete(t *testing.T) {
 	t.Run("with nil key", func(t *testing.T) {
 		arc := New()
 
-		if err := arc.Delete(nil); err != ErrNilKey {
+		if err := arc.Delete(nil); !errors.Is(err, ErrNilKey) {
 			t.Fatalf("unexpected result, got:%v, want:%v", err, ErrNilKey)
 		}
 	})
@@ -1133,7 +1136,7 @@ func TestDeleteWithIPStringTree(t *testing.T) {
 					expected = ErrKeyNotFound
 				}
 
-				if _, err := arc.Get(node.key); err != expected {
+				if _, err := arc.Get(node.key); !errors.Is(err, expected) {
 					t.Fatalf("unexpected error: got:%v, want:%v", err, expected)
 				}
 			}
@@ -1141,6 +1144,28 @@ func TestDeleteWithIPStringTree(t *testing.T) {
 	}
 }
 
+func TestDeleteMergesBlobValue(t *testing.T) {
+	arc := New()
+
+	arc.Put([]byte("apple"), []byte("1"))
+	arc.Put([]byte("apricot"), blobValueX())
+
+	// Deleting "apple" merges the non-record "ap" node with "ricot".
+	if err := arc.Delete([]byte("apple")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := arc.Get([]byte("apricot"))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !bytes.Equal(got, blobValueX()) {
+		t.Errorf("unexpected value: got:%q, want:%q", got, blobValueX())
+	}
+}
+
 func collectNodesByLevel(root *node) [][]*node {
 	if root == nil {
 		return nil
@@ -1504,3 +1529,44 @@ type testNode struct {
 	isRecord    bool
 	numChildren int
 }
+
+func TestOpError(t *testing.T) {
+	arc, _ := NewWithOptions(Options{MaxKeyBytes: 100})
+
+	_, err := arc.Get([]byte("missing"))
+
+	var opErr *OpError
+
+	if !errors.As(err, &opErr) || opErr.Op != OpGet || string(opErr.Key) != "missing" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected the error to wrap %v", ErrKeyNotFound)
+	}
+
+	if want := `get "missing": key not found`; err.Error() != want {
+		t.Errorf("unexpected message: got:%q, want:%q", err.Error(), want)
+	}
+
+	// Long keys are truncated, and the size error remains accessible.
+	err = arc.Put(bytes.Repeat([]byte("k"), 200), nil)
+
+	var sizeErr *SizeError
+
+	if !errors.As(err, &opErr) || len(opErr.Key) != maxOpErrorKeyLen || opErr.Len != 200 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !errors.As(err, &sizeErr) || sizeErr.Size != 200 {
+		t.Errorf("expected the error to wrap a *SizeError: %v", err)
+	}
+
+	if want := fmt.Sprintf("put %q... (200 bytes): %v", bytes.Repeat([]byte("k"), maxOpErrorKeyLen), sizeErr); err.Error() != want {
+		t.Errorf("unexpected message: got:%q, want:%q", err.Error(), want)
+	}
+
+	if err := arc.Put([]byte("key"), nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
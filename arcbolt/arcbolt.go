@@ -0,0 +1,267 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+// Package arcbolt migrates bbolt databases to Arc. It reads the bbolt file
+// format directly, therefore it does not depend on the bbolt package, and the
+// file is never modified. The records of nested buckets are mapped to
+// path-style keys built by arckey.JoinPath, which consist of the names of the
+// enclosing buckets followed by the key. For example, the key "alice" of the
+// bucket "users" becomes "users/alice", and the buckets of a key can be
+// listed using Arc.ScanChildren.
+package arcbolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"os"
+	"slices"
+
+	"github.com/chronohq/arc"
+	"github.com/chronohq/arc/arckey"
+)
+
+// ErrInvalidFile is returned when the file is not a valid bbolt database.
+var ErrInvalidFile = errors.New("arcbolt: invalid bbolt file")
+
+const (
+	boltMagic   = 0xED0CDAED
+	boltVersion = 2
+
+	pageHeaderLen  = 16
+	metaLen        = 64
+	metaSumPos     = 56
+	branchElemLen  = 16
+	leafElemLen    = 16
+	bucketHeadLen  = 16
+	defaultPageLen = 4096
+
+	// maxDepth bounds the nesting of pages and buckets, which prevents
+	// corrupted files with cyclic page references from recursing forever.
+	maxDepth = 256
+)
+
+// Page and element flags of the bbolt file format.
+const (
+	branchPageFlag = 0x01
+	leafPageFlag   = 0x02
+	bucketLeafFlag = 0x01
+)
+
+// boltFile is a bbolt database file loaded in memory.
+type boltFile struct {
+	src     []byte
+	pageLen uint64
+	root    uint64
+}
+
+// Load puts every record of the bbolt database at the given path into db,
+// which is typically a database opened with arc.Open. Only files written on
+// little-endian machines, which covers all common platforms, are supported.
+// The records that precede an error remain in db. Returns ErrInvalidFile if
+// the file is not a valid bbolt database.
+func Load(db *arc.Arc, path string) error {
+	src, err := os.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	f, err := newBoltFile(src)
+
+	if err != nil {
+		return err
+	}
+
+	page, err := f.page(f.root)
+
+	if err != nil {
+		return err
+	}
+
+	// The values are cloned, since Put retains them, which would otherwise
+	// keep the whole file in memory.
+	return f.walk(page, nil, 0, func(key []byte, value []byte) error {
+		return db.Put(key, bytes.Clone(value))
+	})
+}
+
+// newBoltFile selects the latest valid meta page of the file, which records
+// the page size and the root bucket.
+func newBoltFile(src []byte) (*boltFile, error) {
+	pageLen := uint64(defaultPageLen)
+
+	if m, ok := readMeta(src, 0); ok {
+		pageLen = m.pageLen
+	}
+
+	var latest *meta
+
+	for _, offset := range []uint64{0, pageLen} {
+		m, ok := readMeta(src, offset)
+
+		if ok && (latest == nil || m.txid > latest.txid) {
+			latest = &m
+		}
+	}
+
+	if latest == nil {
+		return nil, ErrInvalidFile
+	}
+
+	return &boltFile{src: src, pageLen: latest.pageLen, root: latest.root}, nil
+}
+
+// meta holds the fields of a meta page that are relevant to reading the file.
+type meta struct {
+	pageLen uint64
+	root    uint64
+	txid    uint64
+}
+
+// readMeta reads the meta page at the given offset. It returns false if the
+// page is not a valid meta page.
+func readMeta(src []byte, offset uint64) (meta, bool) {
+	if offset+pageHeaderLen+metaLen > uint64(len(src)) {
+		return meta{}, false
+	}
+
+	m := src[offset+pageHeaderLen : offset+pageHeaderLen+metaLen]
+
+	h := fnv.New64a()
+	h.Write(m[:metaSumPos])
+
+	if binary.LittleEndian.Uint32(m[0:]) != boltMagic ||
+		binary.LittleEndian.Uint32(m[4:]) != boltVersion ||
+		binary.LittleEndian.Uint64(m[metaSumPos:]) != h.Sum64() {
+		return meta{}, false
+	}
+
+	ret := meta{
+		pageLen: uint64(binary.LittleEndian.Uint32(m[8:])),
+		root:    binary.LittleEndian.Uint64(m[16:]),
+		txid:    binary.LittleEndian.Uint64(m[48:]),
+	}
+
+	if ret.pageLen < pageHeaderLen+metaLen {
+		return meta{}, false
+	}
+
+	return ret, true
+}
+
+// page returns the bytes of the page with the given ID, including its
+// overflow pages.
+func (f *boltFile) page(id uint64) ([]byte, error) {
+	if id > uint64(len(f.src))/f.pageLen {
+		return nil, ErrInvalidFile
+	}
+
+	start := id * f.pageLen
+
+	if start+pageHeaderLen > uint64(len(f.src)) {
+		return nil, ErrInvalidFile
+	}
+
+	overflow := uint64(binary.LittleEndian.Uint32(f.src[start+12:]))
+	end := start + (overflow+1)*f.pageLen
+
+	if end > uint64(len(f.src)) {
+		return nil, ErrInvalidFile
+	}
+
+	return f.src[start:end], nil
+}
+
+// walk calls fn for every record below the given page, which belongs to the
+// bucket identified by path. Nested buckets are walked recursively.
+func (f *boltFile) walk(page []byte, path [][]byte, depth int, fn func(key []byte, value []byte) error) error {
+	if depth > maxDepth || len(page) < pageHeaderLen {
+		return ErrInvalidFile
+	}
+
+	flags := binary.LittleEndian.Uint16(page[8:])
+	count := int(binary.LittleEndian.Uint16(page[10:]))
+
+	switch {
+	case flags&branchPageFlag != 0:
+		for i := range count {
+			elem := pageHeaderLen + i*branchElemLen
+
+			if elem+branchElemLen > len(page) {
+				return ErrInvalidFile
+			}
+
+			child, err := f.page(binary.LittleEndian.Uint64(page[elem+8:]))
+
+			if err != nil {
+				return err
+			}
+
+			if err := f.walk(child, path, depth+1, fn); err != nil {
+				return err
+			}
+		}
+
+	case flags&leafPageFlag != 0:
+		for i := range count {
+			elem := pageHeaderLen + i*leafElemLen
+
+			if elem+leafElemLen > len(page) {
+				return ErrInvalidFile
+			}
+
+			elemFlags := binary.LittleEndian.Uint32(page[elem:])
+			start := uint64(elem) + uint64(binary.LittleEndian.Uint32(page[elem+4:]))
+			keyLen := uint64(binary.LittleEndian.Uint32(page[elem+8:]))
+			valueLen := uint64(binary.LittleEndian.Uint32(page[elem+12:]))
+
+			if start+keyLen+valueLen > uint64(len(page)) {
+				return ErrInvalidFile
+			}
+
+			key := page[start : start+keyLen]
+			value := page[start+keyLen : start+keyLen+valueLen]
+
+			if elemFlags&bucketLeafFlag != 0 {
+				if err := f.walkBucket(value, append(slices.Clip(path), key), depth+1, fn); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if err := fn(arckey.JoinPath(append(slices.Clip(path), key)...), value); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return ErrInvalidFile
+	}
+
+	return nil
+}
+
+// walkBucket walks the nested bucket whose header is given. The pages of
+// small buckets are stored inline, right after the header.
+func (f *boltFile) walkBucket(header []byte, path [][]byte, depth int, fn func(key []byte, value []byte) error) error {
+	if len(header) < bucketHeadLen {
+		return ErrInvalidFile
+	}
+
+	root := binary.LittleEndian.Uint64(header)
+
+	if root == 0 {
+		return f.walk(header[bucketHeadLen:], path, depth, fn)
+	}
+
+	page, err := f.page(root)
+
+	if err != nil {
+		return err
+	}
+
+	return f.walk(page, path, depth, fn)
+}
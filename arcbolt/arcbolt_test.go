@@ -0,0 +1,193 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arcbolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chronohq/arc"
+)
+
+const testPageLen = 4096
+
+type testElem struct {
+	key    string
+	value  []byte
+	bucket bool
+}
+
+func testPageHeader(flags uint16, count int, overflow uint32) []byte {
+	ret := make([]byte, pageHeaderLen)
+	binary.LittleEndian.PutUint16(ret[8:], flags)
+	binary.LittleEndian.PutUint16(ret[10:], uint16(count))
+	binary.LittleEndian.PutUint32(ret[12:], overflow)
+
+	return ret
+}
+
+func testLeafPage(overflow uint32, elems ...testElem) []byte {
+	ret := testPageHeader(leafPageFlag, len(elems), overflow)
+	data := pageHeaderLen + len(elems)*leafElemLen
+	var body []byte
+
+	for i, e := range elems {
+		var flags uint32
+
+		if e.bucket {
+			flags = bucketLeafFlag
+		}
+
+		pos := data + len(body) - (pageHeaderLen + i*leafElemLen)
+		ret = binary.LittleEndian.AppendUint32(ret, flags)
+		ret = binary.LittleEndian.AppendUint32(ret, uint32(pos))
+		ret = binary.LittleEndian.AppendUint32(ret, uint32(len(e.key)))
+		ret = binary.LittleEndian.AppendUint32(ret, uint32(len(e.value)))
+		body = append(append(body, e.key...), e.value...)
+	}
+
+	return append(ret, body...)
+}
+
+func testBranchPage(children ...uint64) []byte {
+	ret := testPageHeader(branchPageFlag, len(children), 0)
+
+	for i, child := range children {
+		ret = binary.LittleEndian.AppendUint32(ret, uint32(len(children)-i)*branchElemLen)
+		ret = binary.LittleEndian.AppendUint32(ret, 0)
+		ret = binary.LittleEndian.AppendUint64(ret, child)
+	}
+
+	return ret
+}
+
+func testMetaPage(root uint64, txid uint64) []byte {
+	m := make([]byte, metaLen)
+	binary.LittleEndian.PutUint32(m[0:], boltMagic)
+	binary.LittleEndian.PutUint32(m[4:], boltVersion)
+	binary.LittleEndian.PutUint32(m[8:], testPageLen)
+	binary.LittleEndian.PutUint64(m[16:], root)
+	binary.LittleEndian.PutUint64(m[48:], txid)
+
+	h := fnv.New64a()
+	h.Write(m[:metaSumPos])
+	binary.LittleEndian.PutUint64(m[metaSumPos:], h.Sum64())
+
+	return append(testPageHeader(0x04, 0, 0), m...)
+}
+
+func testBucket(root uint64, inline []byte) []byte {
+	ret := binary.LittleEndian.AppendUint64(nil, root)
+	ret = binary.LittleEndian.AppendUint64(ret, 0)
+
+	return append(ret, inline...)
+}
+
+// testFile lays out the pages in order, padding each to the page size.
+func testFile(pages ...[]byte) []byte {
+	var ret []byte
+
+	for _, page := range pages {
+		padded := make([]byte, (len(page)+testPageLen-1)/testPageLen*testPageLen)
+		copy(padded, page)
+		ret = append(ret, padded...)
+	}
+
+	return ret
+}
+
+func writeTestFile(t *testing.T, src []byte) string {
+	path := filepath.Join(t.TempDir(), "bolt.db")
+
+	if err := os.WriteFile(path, src, 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	large := bytes.Repeat([]byte("x"), 5000)
+
+	deep := testLeafPage(0, testElem{key: "k", value: []byte("v")})
+	inline := testLeafPage(0,
+		testElem{key: "a", value: []byte("1")},
+		testElem{key: "deep", value: testBucket(0, deep), bucket: true},
+	)
+
+	src := testFile(
+		// The stale meta page references the freelist as the root.
+		testMetaPage(2, 1),
+		testMetaPage(3, 2),
+		testPageHeader(0x10, 0, 0),
+		testLeafPage(0,
+			testElem{key: "inline", value: testBucket(0, inline), bucket: true},
+			testElem{key: "users", value: testBucket(4, nil), bucket: true},
+		),
+		testBranchPage(5, 6),
+		testLeafPage(0, testElem{key: "alice", value: []byte("admin")}),
+		testLeafPage(1, testElem{key: "bob", value: large}),
+	)
+
+	db := arc.New()
+
+	if err := Load(db, writeTestFile(t, src)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string][]byte{
+		"inline/a":      []byte("1"),
+		"inline/deep/k": []byte("v"),
+		"users/alice":   []byte("admin"),
+		"users/bob":     large,
+	}
+
+	if db.Len() != len(want) {
+		t.Errorf("unexpected length: got:%d, want:%d", db.Len(), len(want))
+	}
+
+	for key, value := range want {
+		got, err := db.Get([]byte(key))
+
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", key, err)
+		}
+
+		if !bytes.Equal(got, value) {
+			t.Errorf("unexpected value for %q: got:%d bytes, want:%d bytes", key, len(got), len(value))
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	valid := testFile(
+		testMetaPage(2, 1),
+		testMetaPage(2, 1),
+		testLeafPage(0, testElem{key: "b", value: testBucket(3, nil), bucket: true}),
+	)
+
+	corruptMeta := bytes.Clone(valid)
+	corruptMeta[pageHeaderLen+20] ^= 0xFF
+	corruptMeta[testPageLen+pageHeaderLen+20] ^= 0xFF
+
+	tests := []struct {
+		name string
+		src  []byte
+	}{
+		{"empty", nil},
+		{"corrupt meta", corruptMeta},
+		{"missing page", valid},
+		{"truncated", valid[:2*testPageLen+pageHeaderLen]},
+	}
+
+	for _, test := range tests {
+		if err := Load(arc.New(), writeTestFile(t, test.src)); err != ErrInvalidFile {
+			t.Errorf("%s: unexpected error: got:%v, want:%v", test.name, err, ErrInvalidFile)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+// Package arccache turns an Arc database into a read-through cache in front of
+// a slower source of truth, such as a remote service or a relational
+// database. Get serves the records that the database holds, and loads the
+// missing ones with a Loader, whose values are then cached. Concurrent misses
+// of the same key share a single load.
+//
+// Writes go to the database and, if a Writer is configured, to the source
+// according to the WritePolicy: WriteThrough writes the source before the
+// database, whereas WriteBack only writes the database, and writes the
+// source on Flush. Bounding the database with MaxRecords or MaxBytes turns it
+// into a bounded cache, whose evicted records are loaded again on the next
+// miss:
+//
+//	db, _ := arc.NewWithOptions(arc.Options{MaxRecords: 10000})
+//	cache := arccache.New(db, func(key []byte) ([]byte, error) {
+//		return loadFromPostgres(key)
+//	})
+//
+//	value, err := cache.Get([]byte("users/alice"))
+package arccache
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/chronohq/arc"
+)
+
+// Loader loads the value of the key from the source when the key is not
+// cached. It returns arc.ErrKeyNotFound if the source does not hold the key,
+// which is not cached.
+type Loader func(key []byte) ([]byte, error)
+
+// Writer persists the writes to the source.
+type Writer interface {
+	// Write stores the value of the key in the source.
+	Write(key []byte, value []byte) error
+
+	// Delete removes the key from the source.
+	Delete(key []byte) error
+}
+
+// WritePolicy selects when the writes reach the Writer.
+type WritePolicy int
+
+const (
+	// WriteThrough writes the source before the cache, therefore a write
+	// that fails leaves the cache unchanged.
+	WriteThrough WritePolicy = iota
+
+	// WriteBack only writes the cache, and keeps track of the written keys,
+	// which Flush then writes to the source. Reads of the written keys are
+	// served from the cache, or from the pending writes if the cache has
+	// evicted them. Writes that have not been flushed are lost if the
+	// process exits.
+	WriteBack
+)
+
+// Options configures a Cache.
+type Options struct {
+	// Writer persists the writes to the source. Nil only writes the cache,
+	// which suits sources that are written elsewhere and only need the
+	// cache to be invalidated.
+	Writer Writer
+
+	// Policy selects when the writes reach the Writer. The zero value is
+	// WriteThrough.
+	Policy WritePolicy
+
+	// FlushInterval is how often the WriteBack policy flushes the pending
+	// writes in the background. Zero only flushes them on Flush and Close.
+	FlushInterval time.Duration
+
+	// OnFlushError is called with the error of a background flush, whose
+	// writes are retried by the next flush. Nil ignores the errors.
+	OnFlushError func(err error)
+}
+
+// Cache is an Arc database that acts as a read-through cache.
+type Cache struct {
+	db   *arc.Arc
+	load Loader
+	opts Options
+
+	// Serialize the writes, which keeps the Writer and the cache in the
+	// same order, and the flushes, which keeps them from reordering the
+	// pending writes.
+	writeMu sync.Mutex
+	flushMu sync.Mutex
+
+	// Stop the background flush, which closes flushDone once it has exited.
+	// Both are nil unless a FlushInterval is set.
+	flushStop chan struct{}
+	flushDone chan struct{}
+
+	// Guards the fields below.
+	mu sync.Mutex
+
+	// Loads in progress by key, which concurrent misses wait for.
+	calls map[string]*call
+
+	// Writes of the WriteBack policy that have not been flushed yet, by key.
+	pending map[string]pendingWrite
+}
+
+// call is a load in progress.
+type call struct {
+	done  chan struct{} // Closed once the load has finished.
+	value []byte
+	err   error
+
+	// Set when the key is written during the load, in which case the loaded
+	// value is stale and is not cached.
+	stale bool
+}
+
+// pendingWrite is a write of the WriteBack policy that has not been flushed.
+type pendingWrite struct {
+	value   []byte
+	deleted bool
+}
+
+// New returns a Cache that loads the missing keys of db with load, and only
+// writes the cache.
+func New(db *arc.Arc, load Loader) *Cache {
+	ret, _ := NewWithOptions(db, load, Options{})
+	return ret
+}
+
+// NewWithOptions is like New, but configures the cache with the given
+// options. Returns arc.ErrInvalidOptions if the options are out of range, or
+// if the WriteBack policy has no Writer.
+func NewWithOptions(db *arc.Arc, load Loader, opts Options) (*Cache, error) {
+	if opts.Policy < WriteThrough || opts.Policy > WriteBack || opts.FlushInterval < 0 {
+		return nil, arc.ErrInvalidOptions
+	}
+
+	if opts.Policy == WriteBack && opts.Writer == nil {
+		return nil, arc.ErrInvalidOptions
+	}
+
+	ret := &Cache{db: db, load: load, opts: opts, calls: map[string]*call{}, pending: map[string]pendingWrite{}}
+
+	if opts.Policy == WriteBack && opts.FlushInterval > 0 {
+		ret.startFlusher()
+	}
+
+	return ret, nil
+}
+
+// Arc returns the database that holds the cached records.
+func (c *Cache) Arc() *arc.Arc {
+	return c.db
+}
+
+// Get returns the value of the key, which is loaded from the source and
+// cached if the key is not cached. Concurrent calls that miss the same key
+// wait for a single load. Returns arc.ErrKeyNotFound if neither the cache nor
+// the source holds the key, and the error of the Loader if the load fails.
+func (c *Cache) Get(key []byte) ([]byte, error) {
+	value, err := c.db.Get(key)
+
+	if !errors.Is(err, arc.ErrKeyNotFound) {
+		return value, err
+	}
+
+	c.mu.Lock()
+
+	if w, found := c.pending[string(key)]; found {
+		c.mu.Unlock()
+
+		if w.deleted {
+			return nil, arc.ErrKeyNotFound
+		}
+
+		return bytes.Clone(w.value), nil
+	}
+
+	if cl, found := c.calls[string(key)]; found {
+		c.mu.Unlock()
+		<-cl.done
+
+		return bytes.Clone(cl.value), cl.err
+	}
+
+	cl := &call{done: make(chan struct{})}
+	c.calls[string(key)] = cl
+	c.mu.Unlock()
+
+	cl.value, cl.err = c.load(key)
+
+	c.mu.Lock()
+	delete(c.calls, string(key))
+
+	// The lock keeps writes of the key from interleaving with the fill.
+	if cl.err == nil && !cl.stale {
+		if err := c.db.Put(key, cl.value); err != nil {
+			cl.err = err
+		}
+	}
+
+	c.mu.Unlock()
+	close(cl.done)
+
+	return bytes.Clone(cl.value), cl.err
+}
+
+// Put writes the value of the key according to the WritePolicy. Loads of the
+// key that are in progress are not cached, since they may predate the write.
+func (c *Cache) Put(key []byte, value []byte) error {
+	return c.write(key, value, false)
+}
+
+// Delete removes the key according to the WritePolicy. Deleting a key that is
+// not cached is not an error.
+func (c *Cache) Delete(key []byte) error {
+	return c.write(key, nil, true)
+}
+
+// write applies a write of the key to the Writer and the cache.
+func (c *Cache) write(key []byte, value []byte, deleted bool) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.opts.Writer != nil && c.opts.Policy == WriteThrough {
+		var err error
+
+		if deleted {
+			err = c.opts.Writer.Delete(key)
+		} else {
+			err = c.opts.Writer.Write(key, value)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cl, found := c.calls[string(key)]; found {
+		cl.stale = true
+	}
+
+	var err error
+
+	if deleted {
+		if err = c.db.Delete(key); errors.Is(err, arc.ErrKeyNotFound) {
+			err = nil
+		}
+	} else {
+		err = c.db.Put(key, value)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if c.opts.Policy == WriteBack {
+		c.pending[string(key)] = pendingWrite{value: bytes.Clone(value), deleted: deleted}
+	}
+
+	return nil
+}
+
+// Pending returns the number of writes of the WriteBack policy that have not
+// been flushed yet.
+func (c *Cache) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.pending)
+}
+
+// Flush writes the pending writes of the WriteBack policy to the Writer in
+// key order. The writes that fail remain pending, unless the key is written
+// again in the meantime, and their errors are returned. Writes are not
+// blocked by the flush. It is a no-op under the WriteThrough policy.
+func (c *Cache) Flush() error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = map[string]pendingWrite{}
+	c.mu.Unlock()
+
+	keys := make([]string, 0, len(pending))
+
+	for key := range pending {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	var errs []error
+	failed := map[string]pendingWrite{}
+
+	for _, key := range keys {
+		w := pending[key]
+
+		var err error
+
+		if w.deleted {
+			err = c.opts.Writer.Delete([]byte(key))
+		} else {
+			err = c.opts.Writer.Write([]byte(key), w.value)
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+			failed[key] = w
+		}
+	}
+
+	if len(failed) > 0 {
+		c.mu.Lock()
+
+		for key, w := range failed {
+			if _, found := c.pending[key]; !found {
+				c.pending[key] = w
+			}
+		}
+
+		c.mu.Unlock()
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close stops the background flush, and flushes the pending writes. The
+// database is left open.
+func (c *Cache) Close() error {
+	if c.flushStop != nil {
+		select {
+		case <-c.flushStop:
+		default:
+			close(c.flushStop)
+		}
+
+		<-c.flushDone
+	}
+
+	return c.Flush()
+}
+
+// startFlusher starts the goroutine that flushes the pending writes on the
+// FlushInterval.
+func (c *Cache) startFlusher() {
+	c.flushStop = make(chan struct{})
+	c.flushDone = make(chan struct{})
+
+	go func() {
+		defer close(c.flushDone)
+
+		ticker := time.NewTicker(c.opts.FlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.flushStop:
+				return
+			case <-ticker.C:
+				if err := c.Flush(); err != nil && c.opts.OnFlushError != nil {
+					c.opts.OnFlushError(err)
+				}
+			}
+		}
+	}()
+}
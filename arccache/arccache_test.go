@@ -0,0 +1,296 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arccache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chronohq/arc"
+)
+
+// testSource is a source of truth that counts the loads.
+type testSource struct {
+	mu      sync.Mutex
+	records map[string]string
+	loads   atomic.Int32
+	fail    error
+}
+
+func newTestSource(records map[string]string) *testSource {
+	return &testSource{records: records}
+}
+
+func (s *testSource) Load(key []byte) ([]byte, error) {
+	s.loads.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, found := s.records[string(key)]
+
+	if !found {
+		return nil, arc.ErrKeyNotFound
+	}
+
+	return []byte(value), nil
+}
+
+func (s *testSource) Write(key []byte, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fail != nil {
+		return s.fail
+	}
+
+	s.records[string(key)] = string(value)
+
+	return nil
+}
+
+func (s *testSource) Delete(key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fail != nil {
+		return s.fail
+	}
+
+	delete(s.records, string(key))
+
+	return nil
+}
+
+func (s *testSource) get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, found := s.records[key]
+
+	return value, found
+}
+
+func TestGet(t *testing.T) {
+	src := newTestSource(map[string]string{"apple": "red"})
+	cache := New(arc.New(), src.Load)
+
+	for range 3 {
+		if got, err := cache.Get([]byte("apple")); err != nil || string(got) != "red" {
+			t.Fatalf("unexpected value: got:%q, err:%v", got, err)
+		}
+	}
+
+	if got := src.loads.Load(); got != 1 {
+		t.Errorf("unexpected loads: got:%d, want:1", got)
+	}
+
+	// Missing keys are not cached.
+	for range 2 {
+		if _, err := cache.Get([]byte("banana")); !errors.Is(err, arc.ErrKeyNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	if got := src.loads.Load(); got != 3 {
+		t.Errorf("unexpected loads: got:%d, want:3", got)
+	}
+
+	if cache.Arc().Len() != 1 {
+		t.Errorf("unexpected cached records: %d", cache.Arc().Len())
+	}
+}
+
+func TestGetConcurrent(t *testing.T) {
+	var loads atomic.Int32
+	release := make(chan struct{})
+
+	cache := New(arc.New(), func(key []byte) ([]byte, error) {
+		loads.Add(1)
+		<-release
+		return []byte("value"), nil
+	})
+
+	var wg sync.WaitGroup
+
+	for range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if got, err := cache.Get([]byte("key")); err != nil || string(got) != "value" {
+				t.Errorf("unexpected value: got:%q, err:%v", got, err)
+			}
+		}()
+	}
+
+	// Wait for the first miss to start the load.
+	for loads.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := loads.Load(); got != 1 {
+		t.Errorf("unexpected loads: got:%d, want:1", got)
+	}
+}
+
+func TestGetStale(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	cache := New(arc.New(), func(key []byte) ([]byte, error) {
+		close(started)
+		<-release
+		return []byte("old"), nil
+	})
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		cache.Get([]byte("key"))
+	}()
+
+	// A write during the load keeps the loaded value out of the cache.
+	<-started
+	cache.Put([]byte("key"), []byte("new"))
+	close(release)
+	<-done
+
+	if got, _ := cache.Get([]byte("key")); string(got) != "new" {
+		t.Errorf("unexpected value: got:%q, want:new", got)
+	}
+}
+
+func TestWriteThrough(t *testing.T) {
+	src := newTestSource(map[string]string{"apple": "red"})
+	cache, err := NewWithOptions(arc.New(), src.Load, Options{Writer: src})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cache.Put([]byte("apple"), []byte("green"))
+
+	if value, _ := src.get("apple"); value != "green" {
+		t.Errorf("unexpected source value: %q", value)
+	}
+
+	// A failed write leaves the cache unchanged.
+	src.fail = errors.New("unavailable")
+
+	if err := cache.Put([]byte("apple"), []byte("yellow")); !errors.Is(err, src.fail) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if got, _ := cache.Get([]byte("apple")); string(got) != "green" {
+		t.Errorf("unexpected value: %q", got)
+	}
+
+	src.fail = nil
+	cache.Delete([]byte("apple"))
+
+	if _, found := src.get("apple"); found {
+		t.Errorf("expected the key to be deleted from the source")
+	}
+
+	if _, err := cache.Get([]byte("apple")); !errors.Is(err, arc.ErrKeyNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if cache.Pending() != 0 {
+		t.Errorf("unexpected pending writes: %d", cache.Pending())
+	}
+}
+
+func TestWriteBack(t *testing.T) {
+	src := newTestSource(map[string]string{"apple": "red", "banana": "yellow"})
+	db, _ := arc.NewWithOptions(arc.Options{MaxRecords: 1})
+	cache, err := NewWithOptions(db, src.Load, Options{Writer: src, Policy: WriteBack})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cache.Put([]byte("apple"), []byte("green"))
+	cache.Delete([]byte("banana"))
+
+	if value, _ := src.get("apple"); value != "red" || cache.Pending() != 2 {
+		t.Fatalf("unexpected source value before the flush: %q", value)
+	}
+
+	// Pending writes are served even if the cache has evicted them.
+	cache.Put([]byte("cherry"), []byte("red"))
+
+	if got, _ := cache.Get([]byte("apple")); string(got) != "green" {
+		t.Errorf("unexpected value: %q", got)
+	}
+
+	if _, err := cache.Get([]byte("banana")); !errors.Is(err, arc.ErrKeyNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Failed writes are retried by the next flush.
+	src.fail = errors.New("unavailable")
+
+	if err := cache.Flush(); !errors.Is(err, src.fail) || cache.Pending() != 3 {
+		t.Fatalf("unexpected flush: pending:%d, err:%v", cache.Pending(), err)
+	}
+
+	src.fail = nil
+
+	if err := cache.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if value, _ := src.get("apple"); value != "green" {
+		t.Errorf("unexpected source value: %q", value)
+	}
+
+	if _, found := src.get("banana"); found {
+		t.Errorf("expected the key to be deleted from the source")
+	}
+
+	if cache.Pending() != 0 {
+		t.Errorf("unexpected pending writes: %d", cache.Pending())
+	}
+}
+
+func TestWriteBackInterval(t *testing.T) {
+	src := newTestSource(map[string]string{})
+	cache, _ := NewWithOptions(arc.New(), src.Load, Options{Writer: src, Policy: WriteBack, FlushInterval: time.Millisecond})
+	defer cache.Close()
+
+	cache.Put([]byte("apple"), []byte("red"))
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if _, found := src.get("apple"); found {
+			return
+		}
+	}
+
+	t.Errorf("expected the write to be flushed in the background")
+}
+
+func TestOptions(t *testing.T) {
+	invalid := []Options{
+		{Policy: WriteBack},
+		{Policy: WritePolicy(-1)},
+		{Policy: WriteBack + 1},
+		{Writer: newTestSource(nil), FlushInterval: -time.Second},
+	}
+
+	for _, opts := range invalid {
+		if _, err := NewWithOptions(arc.New(), nil, opts); !errors.Is(err, arc.ErrInvalidOptions) {
+			t.Errorf("unexpected error with %+v: %v", opts, err)
+		}
+	}
+}
@@ -0,0 +1,531 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+// Package arccluster shards a keyspace across several Arc databases, such as
+// one file per disk, or databases that are too large for a single file. Keys
+// are routed to the shards by consistent hashing, therefore adding or
+// removing a shard only moves the keys of about one shard:
+//
+//	cluster, _ := arccluster.New(map[string]*arc.Arc{"a": dbA, "b": dbB})
+//	cluster.Put([]byte("users/alice"), value)
+//
+//	cluster.AddShard("c", dbC)
+//	moved, err := cluster.Rebalance()
+//
+// Between a change of the shards and the end of Rebalance, the records that
+// have yet to be moved are still found on their previous shards, therefore the
+// cluster remains usable while it is rebalanced.
+package arccluster
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"iter"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/chronohq/arc"
+)
+
+var (
+	// ErrNoShards is returned when a cluster would be left without shards.
+	ErrNoShards = errors.New("arccluster: no shards")
+
+	// ErrShardExists is returned by AddShard when the name is taken.
+	ErrShardExists = errors.New("arccluster: shard already exists")
+
+	// ErrUnknownShard is returned by RemoveShard when the name is unknown.
+	ErrUnknownShard = errors.New("arccluster: unknown shard")
+)
+
+// defaultVirtualNodes is the number of points that every shard takes on the
+// ring unless Options.VirtualNodes is set.
+const defaultVirtualNodes = 128
+
+// Options configures a Cluster.
+type Options struct {
+	// VirtualNodes is the number of points that every shard takes on the
+	// hash ring. More points spread the keys more evenly, at the cost of a
+	// larger ring. Zero selects 128.
+	VirtualNodes int
+}
+
+// Cluster routes the keys across its shards. It implements arc.Store.
+type Cluster struct {
+	vnodes int
+
+	// Guards the fields below. Rebalance holds the write lock while it
+	// moves a record, which keeps the writes from racing with the move.
+	mu sync.RWMutex
+
+	// Maps the names of the shards to their databases, including the
+	// shards that were removed but still hold records.
+	shards map[string]*arc.Arc
+
+	// Current ring, and the rings that preceded it since the cluster was
+	// last balanced, which may still own records. Empty if the cluster is
+	// balanced.
+	ring     *ring
+	previous []*ring
+
+	// Incremented by every change of the shards, which tells Rebalance
+	// whether the shards changed while it moved the records.
+	generation uint64
+}
+
+// New returns a Cluster over the given shards with the default options. The
+// names of the shards determine the placement of the keys, therefore they
+// must be the same every time the cluster is created over the same
+// databases. Returns ErrNoShards if there are no shards.
+func New(shards map[string]*arc.Arc) (*Cluster, error) {
+	return NewWithOptions(shards, Options{})
+}
+
+// NewWithOptions is like New, but configures the cluster with the given
+// options. Returns arc.ErrInvalidOptions if the options are out of range.
+func NewWithOptions(shards map[string]*arc.Arc, opts Options) (*Cluster, error) {
+	if opts.VirtualNodes < 0 {
+		return nil, arc.ErrInvalidOptions
+	}
+
+	if len(shards) == 0 {
+		return nil, ErrNoShards
+	}
+
+	if opts.VirtualNodes == 0 {
+		opts.VirtualNodes = defaultVirtualNodes
+	}
+
+	ret := &Cluster{vnodes: opts.VirtualNodes, shards: map[string]*arc.Arc{}}
+	names := make([]string, 0, len(shards))
+
+	for name, db := range shards {
+		ret.shards[name] = db
+		names = append(names, name)
+	}
+
+	ret.ring = newRing(names, ret.vnodes)
+
+	return ret, nil
+}
+
+// Shard returns the name of the shard that owns the key.
+func (c *Cluster) Shard(key []byte) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.ring.owner(key)
+}
+
+// Shards returns the names of the shards in sorted order, which excludes the
+// shards that were removed.
+func (c *Cluster) Shards() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.ring.names()
+}
+
+// DB returns the database of the named shard, or nil if the shard is unknown.
+func (c *Cluster) DB(name string) *arc.Arc {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.shards[name]
+}
+
+// Get returns the value of the key from the shard that owns it, or from a
+// previous shard if the key has yet to be moved.
+func (c *Cluster) Get(key []byte) ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var value []byte
+	var err error
+
+	for _, name := range c.owners(key) {
+		if value, err = c.shards[name].Get(key); !errors.Is(err, arc.ErrKeyNotFound) {
+			break
+		}
+	}
+
+	return value, err
+}
+
+// Put writes the key to the shard that owns it, and removes the copies that
+// previous shards may still hold.
+func (c *Cluster) Put(key []byte, value []byte) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	owner := c.ring.owner(key)
+
+	if err := c.shards[owner].Put(key, value); err != nil {
+		return err
+	}
+
+	return c.deletePrevious(key, owner)
+}
+
+// Delete removes the key from the shard that owns it, and from the previous
+// shards. Returns arc.ErrKeyNotFound if none of them holds the key.
+func (c *Cluster) Delete(key []byte) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	found := false
+
+	for _, name := range c.owners(key) {
+		err := c.shards[name].Delete(key)
+
+		if errors.Is(err, arc.ErrKeyNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		found = true
+	}
+
+	if !found {
+		return arc.ErrKeyNotFound
+	}
+
+	return nil
+}
+
+// deletePrevious removes the key from the previous shards other than the
+// given owner. The caller must hold the read lock.
+func (c *Cluster) deletePrevious(key []byte, owner string) error {
+	for _, name := range c.owners(key) {
+		if name == owner {
+			continue
+		}
+
+		if err := c.shards[name].Delete(key); err != nil && !errors.Is(err, arc.ErrKeyNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// owners returns the shard that owns the key followed by the distinct shards
+// that owned it on the previous rings, newest first. The caller must hold the
+// lock.
+func (c *Cluster) owners(key []byte) []string {
+	ret := []string{c.ring.owner(key)}
+
+	for i := len(c.previous) - 1; i >= 0; i-- {
+		if name := c.previous[i].owner(key); !slices.Contains(ret, name) {
+			ret = append(ret, name)
+		}
+	}
+
+	return ret
+}
+
+// Len returns the number of records across the shards.
+func (c *Cluster) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var ret int
+
+	for _, db := range c.shards {
+		ret += db.Len()
+	}
+
+	return ret
+}
+
+// Scan returns an iterator over the records whose keys begin with the given
+// prefix across the shards, in lexicographic order. The records of every
+// shard are captured when the iteration begins, like with arc.Arc.Scan. A key
+// that is being moved by Rebalance is yielded once.
+func (c *Cluster) Scan(prefix []byte) iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		c.mu.RLock()
+		names := slices.Sorted(func(yield func(string) bool) {
+			for name := range c.shards {
+				if !yield(name) {
+					return
+				}
+			}
+		})
+
+		var cursors []*cursor
+
+		for _, name := range names {
+			next, stop := iter.Pull2(c.shards[name].Scan(prefix))
+			defer stop()
+
+			if cur := (&cursor{next: next}); cur.advance() {
+				cursors = append(cursors, cur)
+			}
+		}
+
+		c.mu.RUnlock()
+
+		var last []byte
+
+		for len(cursors) > 0 {
+			i := 0
+
+			for j := 1; j < len(cursors); j++ {
+				if bytes.Compare(cursors[j].key, cursors[i].key) < 0 {
+					i = j
+				}
+			}
+
+			cur := cursors[i]
+
+			if last == nil || !bytes.Equal(cur.key, last) {
+				if !yield(cur.key, cur.value) {
+					return
+				}
+
+				last = cur.key
+			}
+
+			if !cur.advance() {
+				cursors = slices.Delete(cursors, i, i+1)
+			}
+		}
+	}
+}
+
+// cursor is the position of Scan within a shard.
+type cursor struct {
+	next  func() ([]byte, []byte, bool)
+	key   []byte
+	value []byte
+}
+
+// advance moves the cursor to the next record, and returns false once the
+// records are exhausted.
+func (c *cursor) advance() bool {
+	var ok bool
+	c.key, c.value, ok = c.next()
+
+	return ok
+}
+
+// AddShard adds a shard to the cluster. The keys that the shard takes over
+// from the other shards are moved by Rebalance. Returns ErrShardExists if the
+// name is taken, including by a removed shard that still holds records.
+func (c *Cluster) AddShard(name string, db *arc.Arc) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, found := c.shards[name]; found {
+		return ErrShardExists
+	}
+
+	c.shards[name] = db
+	c.reshard(append(c.ring.names(), name))
+
+	return nil
+}
+
+// RemoveShard removes a shard from the cluster. Its keys are moved to the
+// other shards by Rebalance, after which the shard is no longer used. Returns
+// ErrUnknownShard if there is no such shard, and ErrNoShards if it is the
+// last shard.
+func (c *Cluster) RemoveShard(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := c.ring.names()
+
+	if !slices.Contains(names, name) {
+		return ErrUnknownShard
+	}
+
+	if len(names) == 1 {
+		return ErrNoShards
+	}
+
+	c.reshard(slices.DeleteFunc(names, func(n string) bool { return n == name }))
+
+	return nil
+}
+
+// reshard replaces the ring with one over the given shards. The replaced ring
+// is kept until Rebalance completes, therefore the keys are found where they
+// were written. The caller must hold the write lock.
+func (c *Cluster) reshard(names []string) {
+	c.previous = append(c.previous, c.ring)
+	c.ring = newRing(names, c.vnodes)
+	c.generation++
+}
+
+// Rebalance moves every record that is not on the shard that owns it to that
+// shard, and returns the number of moved records. Records that the owner
+// already holds, because they were written since the shards changed, are not
+// overwritten. The cluster remains usable while it is rebalanced, but the
+// writes wait for the move of the current record. Once every record is in
+// place, the removed shards are no longer used. If the shards change while
+// the records are moved, Rebalance must be called again. On error, the
+// records that were moved so far remain moved, and Rebalance can be retried.
+func (c *Cluster) Rebalance() (int, error) {
+	c.mu.RLock()
+	generation := c.generation
+	shards := make(map[string]*arc.Arc, len(c.shards))
+
+	for name, db := range c.shards {
+		shards[name] = db
+	}
+
+	c.mu.RUnlock()
+
+	var moved int
+
+	for name, db := range shards {
+		for key := range db.Keys(nil) {
+			ok, err := c.move(name, key)
+
+			if err != nil {
+				return moved, err
+			}
+
+			if ok {
+				moved++
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The records written since the shards changed may be on neither the
+	// current nor the previous shards, therefore the previous rings remain.
+	if c.generation != generation {
+		return moved, nil
+	}
+
+	names := c.ring.names()
+
+	for name := range c.shards {
+		if !slices.Contains(names, name) {
+			delete(c.shards, name)
+		}
+	}
+
+	c.previous = nil
+
+	return moved, nil
+}
+
+// move moves the key from the named shard to the shard that owns it, unless
+// they are the same, and returns true if the record was moved.
+func (c *Cluster) move(from string, key []byte) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	owner := c.ring.owner(key)
+
+	if owner == from {
+		return false, nil
+	}
+
+	src := c.shards[from]
+	value, err := src.Get(key)
+
+	if errors.Is(err, arc.ErrKeyNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if err := c.shards[owner].Add(key, value); err != nil && !errors.Is(err, arc.ErrDuplicateKey) {
+		return false, err
+	}
+
+	if err := src.Delete(key); err != nil && !errors.Is(err, arc.ErrKeyNotFound) {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ring places the shards on a hash ring. Every shard takes several points,
+// and a key is owned by the shard of the first point at or after the hash of
+// the key.
+type ring struct {
+	points []uint64
+	owners []string
+}
+
+// newRing returns the ring of the given shards.
+func newRing(names []string, vnodes int) *ring {
+	type point struct {
+		hash  uint64
+		owner string
+	}
+
+	points := make([]point, 0, len(names)*vnodes)
+
+	for _, name := range names {
+		for i := range vnodes {
+			points = append(points, point{hash: hashKey([]byte(name + "#" + strconv.Itoa(i))), owner: name})
+		}
+	}
+
+	// Collisions are broken by name, which keeps the ring deterministic.
+	slices.SortFunc(points, func(a point, b point) int {
+		if a.hash != b.hash {
+			if a.hash < b.hash {
+				return -1
+			}
+
+			return 1
+		}
+
+		return bytes.Compare([]byte(a.owner), []byte(b.owner))
+	})
+
+	ret := &ring{points: make([]uint64, len(points)), owners: make([]string, len(points))}
+
+	for i, p := range points {
+		ret.points[i] = p.hash
+		ret.owners[i] = p.owner
+	}
+
+	return ret
+}
+
+// owner returns the name of the shard that owns the key.
+func (r *ring) owner(key []byte) string {
+	i, _ := slices.BinarySearch(r.points, hashKey(key))
+
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.owners[i]
+}
+
+// names returns the names of the shards on the ring in sorted order.
+func (r *ring) names() []string {
+	ret := slices.Clone(r.owners)
+	slices.Sort(ret)
+
+	return slices.Compact(ret)
+}
+
+// hashKey returns the position of the key on the ring. SHA-256 spreads the
+// similar names of the points evenly, unlike cheaper hashes such as FNV.
+func hashKey(key []byte) uint64 {
+	sum := sha256.Sum256(key)
+	return binary.LittleEndian.Uint64(sum[:8])
+}
+
+var _ arc.Store = (*Cluster)(nil)
@@ -0,0 +1,236 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arccluster
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/chronohq/arc"
+)
+
+// newTestCluster returns a cluster over new databases with the given names.
+func newTestCluster(t *testing.T, names ...string) *Cluster {
+	t.Helper()
+
+	shards := map[string]*arc.Arc{}
+
+	for _, name := range names {
+		shards[name] = arc.New()
+	}
+
+	ret, err := New(shards)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return ret
+}
+
+// testKey returns the i-th key of the tests.
+func testKey(i int) []byte {
+	return []byte(fmt.Sprintf("key/%04d", i))
+}
+
+// assertRecords fails the test unless the cluster holds exactly the first n
+// test keys, each on the shard that owns it.
+func assertRecords(t *testing.T, c *Cluster, n int) {
+	t.Helper()
+
+	if c.Len() != n {
+		t.Errorf("unexpected length: got:%d, want:%d", c.Len(), n)
+	}
+
+	for i := range n {
+		key := testKey(i)
+
+		if found, _ := c.DB(c.Shard(key)).Has(key); !found {
+			t.Fatalf("expected %q on shard %q", key, c.Shard(key))
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(nil); !errors.Is(err, ErrNoShards) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	shards := map[string]*arc.Arc{"a": arc.New()}
+
+	if _, err := NewWithOptions(shards, Options{VirtualNodes: -1}); !errors.Is(err, arc.ErrInvalidOptions) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRouting(t *testing.T) {
+	c := newTestCluster(t, "a", "b", "c")
+
+	for i := range 300 {
+		if err := c.Put(testKey(i), testKey(i)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	assertRecords(t, c, 300)
+
+	// Keys are spread across the shards.
+	for _, name := range c.Shards() {
+		if n := c.DB(name).Len(); n < 50 {
+			t.Errorf("unexpected records on shard %q: %d", name, n)
+		}
+	}
+
+	if got, err := c.Get(testKey(7)); err != nil || string(got) != string(testKey(7)) {
+		t.Errorf("unexpected value: got:%q, err:%v", got, err)
+	}
+
+	if err := c.Delete(testKey(7)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := c.Delete(testKey(7)); !errors.Is(err, arc.ErrKeyNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Placement only depends on the names of the shards.
+	other := newTestCluster(t, "c", "b", "a")
+
+	for i := range 300 {
+		if c.Shard(testKey(i)) != other.Shard(testKey(i)) {
+			t.Fatalf("unexpected placement of %q", testKey(i))
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	c := newTestCluster(t, "a", "b", "c")
+
+	for i := range 100 {
+		c.Put(testKey(i), nil)
+	}
+
+	var got []string
+
+	for key := range c.Scan([]byte("key/00")) {
+		got = append(got, string(key))
+	}
+
+	var want []string
+
+	for i := range 100 {
+		want = append(want, string(testKey(i)))
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("unexpected keys: %q", got)
+	}
+
+	// Breaking out of the loop stops the iteration.
+	for range c.Scan(nil) {
+		break
+	}
+}
+
+func TestAddShard(t *testing.T) {
+	c := newTestCluster(t, "a", "b")
+
+	for i := range 300 {
+		c.Put(testKey(i), testKey(i))
+	}
+
+	if err := c.AddShard("a", arc.New()); !errors.Is(err, ErrShardExists) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := c.AddShard("c", arc.New()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Records remain reachable before they are moved, and writes go to the
+	// new owner.
+	for i := range 300 {
+		if got, err := c.Get(testKey(i)); err != nil || string(got) != string(testKey(i)) {
+			t.Fatalf("unexpected value: got:%q, err:%v", got, err)
+		}
+	}
+
+	c.Put(testKey(0), []byte("updated"))
+
+	if c.Len() != 300 {
+		t.Errorf("unexpected length: %d", c.Len())
+	}
+
+	moved, err := c.Rebalance()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if moved == 0 || moved > 200 {
+		t.Errorf("unexpected moved records: %d", moved)
+	}
+
+	assertRecords(t, c, 300)
+
+	if got, _ := c.Get(testKey(0)); string(got) != "updated" {
+		t.Errorf("unexpected value: %q", got)
+	}
+
+	if moved, _ := c.Rebalance(); moved != 0 {
+		t.Errorf("unexpected moved records: %d", moved)
+	}
+}
+
+func TestRemoveShard(t *testing.T) {
+	c := newTestCluster(t, "a", "b", "c")
+
+	for i := range 300 {
+		c.Put(testKey(i), testKey(i))
+	}
+
+	if err := c.RemoveShard("d"); !errors.Is(err, ErrUnknownShard) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	removed := c.DB("b")
+
+	if err := c.RemoveShard("b"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Changes before the rebalance keep the records reachable.
+	if err := c.AddShard("d", arc.New()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := range 300 {
+		if _, err := c.Get(testKey(i)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if _, err := c.Rebalance(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertRecords(t, c, 300)
+
+	if removed.Len() != 0 || c.DB("b") != nil {
+		t.Errorf("expected the removed shard to be emptied and dropped")
+	}
+
+	if got := c.Shards(); !slices.Equal(got, []string{"a", "c", "d"}) {
+		t.Errorf("unexpected shards: %q", got)
+	}
+
+	c.RemoveShard("a")
+	c.RemoveShard("c")
+
+	if err := c.RemoveShard("d"); !errors.Is(err, ErrNoShards) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+// Package arcimmutable provides an immutable Arc tree with a functional API.
+// A Tree never changes once it is created. Writes are made through a Txn,
+// and Commit returns a new Tree that holds them, while the trees committed
+// before remain valid snapshots. Since nothing modifies a Tree, any number of
+// goroutines can read it without coordinating with the writers.
+//
+// The package is built on arc.Arc.Clone, which shares the nodes and the blobs
+// of the tree that a transaction starts from until the transaction first
+// writes. The transaction then copies the nodes for itself, but never the
+// blob values. Therefore transactions that only read are free, while the
+// first write of a transaction costs a copy of the tree structure.
+package arcimmutable
+
+import (
+	"iter"
+
+	"github.com/chronohq/arc"
+)
+
+// Tree is an immutable key-value tree.
+type Tree struct {
+	db *arc.Arc
+}
+
+// Txn is a transaction that derives a new Tree from an existing one. It is
+// not safe for concurrent use.
+type Txn struct {
+	db *arc.Arc
+}
+
+// New returns an empty Tree with the default options.
+func New() *Tree {
+	return &Tree{db: arc.New()}
+}
+
+// NewWithOptions returns an empty Tree configured with the given options. It
+// returns arc.ErrInvalidOptions if the options are out of range.
+func NewWithOptions(opts arc.Options) (*Tree, error) {
+	db, err := arc.NewWithOptions(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tree{db: db}, nil
+}
+
+// FromArc returns a Tree that holds the records of db as of the call. Later
+// writes to db are not reflected in the Tree, and the other way around.
+func FromArc(db *arc.Arc) *Tree {
+	return &Tree{db: db.Clone()}
+}
+
+// Len returns the number of records.
+func (t *Tree) Len() int {
+	return t.db.Len()
+}
+
+// Get retrieves the value that matches the given key. Returns
+// arc.ErrKeyNotFound if the key does not exist.
+func (t *Tree) Get(key []byte) ([]byte, error) {
+	return t.db.Get(key)
+}
+
+// Scan returns an iterator over the records whose keys begin with the given
+// prefix in ascending key order.
+func (t *Tree) Scan(prefix []byte) iter.Seq2[[]byte, []byte] {
+	return t.db.Scan(prefix)
+}
+
+// RootHash returns the SHA-256 fingerprint of the records, or nil if the Tree
+// is empty. Trees that hold the same records have the same root hash.
+func (t *Tree) RootHash() []byte {
+	return t.db.RootHash()
+}
+
+// Arc returns a mutable database that holds the records of the Tree. Writes
+// to the returned database are not reflected in the Tree.
+func (t *Tree) Arc() *arc.Arc {
+	return t.db.Clone()
+}
+
+// Txn starts a transaction based on the Tree.
+func (t *Tree) Txn() *Txn {
+	return &Txn{db: t.db.Clone()}
+}
+
+// Len returns the number of records, including the writes of the transaction.
+func (t *Txn) Len() int {
+	return t.db.Len()
+}
+
+// Get retrieves the value that matches the given key, as seen by the
+// transaction. Returns arc.ErrKeyNotFound if the key does not exist.
+func (t *Txn) Get(key []byte) ([]byte, error) {
+	return t.db.Get(key)
+}
+
+// Put inserts or updates a key-value pair.
+func (t *Txn) Put(key []byte, value []byte) error {
+	return t.db.Put(key, value)
+}
+
+// Delete removes the record that matches the given key. Returns
+// arc.ErrKeyNotFound if the key does not exist.
+func (t *Txn) Delete(key []byte) error {
+	return t.db.Delete(key)
+}
+
+// Commit returns a new Tree that holds the writes of the transaction. The
+// transaction remains usable, and its later writes are based on the returned
+// Tree, which they do not modify.
+func (t *Txn) Commit() *Tree {
+	ret := &Tree{db: t.db}
+	t.db = t.db.Clone()
+
+	return ret
+}
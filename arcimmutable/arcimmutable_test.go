@@ -0,0 +1,121 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arcimmutable
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/chronohq/arc"
+)
+
+func collectKeys(t *Tree) []string {
+	var ret []string
+
+	for key := range t.Scan(nil) {
+		ret = append(ret, string(key))
+	}
+
+	return ret
+}
+
+func TestCommit(t *testing.T) {
+	empty := New()
+	txn := empty.Txn()
+
+	txn.Put([]byte("apple"), []byte("cider"))
+	txn.Put([]byte("banana"), []byte("ripe"))
+
+	if value, _ := txn.Get([]byte("apple")); string(value) != "cider" {
+		t.Errorf("unexpected value: got:%q, want:%q", value, "cider")
+	}
+
+	if empty.Len() != 0 {
+		t.Errorf("expected the tree to be unchanged: %q", collectKeys(empty))
+	}
+
+	first := txn.Commit()
+
+	// The transaction remains usable after the commit.
+	txn.Delete([]byte("apple"))
+	txn.Put([]byte("cherry"), []byte("red"))
+
+	second := txn.Commit()
+
+	if got := collectKeys(first); len(got) != 2 || got[0] != "apple" || got[1] != "banana" {
+		t.Errorf("unexpected keys of the first tree: %q", got)
+	}
+
+	if got := collectKeys(second); len(got) != 2 || got[0] != "banana" || got[1] != "cherry" {
+		t.Errorf("unexpected keys of the second tree: %q", got)
+	}
+
+	if err := txn.Delete([]byte("apple")); !errors.Is(err, arc.ErrKeyNotFound) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, arc.ErrKeyNotFound)
+	}
+
+	// Deriving the same records again produces the same root hash.
+	txn = first.Txn()
+	txn.Delete([]byte("apple"))
+	txn.Put([]byte("cherry"), []byte("red"))
+
+	if !bytes.Equal(txn.Commit().RootHash(), second.RootHash()) {
+		t.Errorf("expected equal trees to have equal root hashes")
+	}
+}
+
+func TestFromArc(t *testing.T) {
+	db := arc.New()
+	db.Put([]byte("key"), bytes.Repeat([]byte("x"), 64))
+
+	tree := FromArc(db)
+	db.Delete([]byte("key"))
+
+	if value, err := tree.Get([]byte("key")); err != nil || len(value) != 64 {
+		t.Fatalf("expected the tree to keep the record: %v", err)
+	}
+
+	mutable := tree.Arc()
+	mutable.Put([]byte("other"), []byte("value"))
+
+	if tree.Len() != 1 || mutable.Len() != 2 {
+		t.Errorf("unexpected lengths: tree:%d, mutable:%d", tree.Len(), mutable.Len())
+	}
+}
+
+func TestConcurrentReaders(t *testing.T) {
+	txn := New().Txn()
+
+	for i := range 100 {
+		txn.Put([]byte(fmt.Sprintf("key%03d", i)), []byte("v0"))
+	}
+
+	tree := txn.Commit()
+
+	var wg sync.WaitGroup
+
+	for range 4 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range 100 {
+				if value, _ := tree.Get([]byte(fmt.Sprintf("key%03d", i))); string(value) != "v0" {
+					t.Errorf("unexpected value: got:%q, want:%q", value, "v0")
+				}
+			}
+		}()
+	}
+
+	for i := range 100 {
+		txn.Put([]byte(fmt.Sprintf("key%03d", i)), []byte("v1"))
+		txn.Commit()
+	}
+
+	wg.Wait()
+}
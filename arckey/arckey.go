@@ -0,0 +1,258 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+// Package arckey implements order-preserving encodings for building composite
+// keys. Arc orders keys by comparing their bytes, therefore multi-part keys
+// only sort and scan correctly if each part is encoded such that the byte-wise
+// order of the encoding matches the natural order of the value. For example,
+// a key composed of a tenant name and a record ID can be built as follows:
+//
+//	key := arckey.AppendString(nil, "acme")
+//	key = arckey.AppendUint64(key, 42)
+//
+// Scanning the prefix arckey.AppendString(nil, "acme") then yields the records
+// of the tenant in ascending record ID order, and never the records of other
+// tenants whose names begin with "acme". Fields can be ordered in descending
+// order with the Desc variants, and are decoded with the matching Read
+// functions in the same order as they were appended.
+package arckey
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+// ErrMalformed is returned when a key cannot be decoded.
+var ErrMalformed = errors.New("arckey: malformed key")
+
+const (
+	// escapeByte is the first byte of the two-byte sequences used by the
+	// variable-length encoding. It is the smallest byte value, therefore
+	// the terminator sorts before any content byte.
+	escapeByte = byte(0x00)
+
+	// escapedByte follows escapeByte to represent a literal 0x00 byte.
+	escapedByte = byte(0xFF)
+
+	// terminatorByte follows escapeByte to mark the end of the field.
+	terminatorByte = byte(0x01)
+
+	// timeLen is the length of an encoded time.Time value.
+	timeLen = 12
+)
+
+// AppendBytes appends the order-preserving encoding of b to dst and returns
+// the extended buffer. The encoding escapes 0x00 bytes as 0x00 0xFF and ends
+// with 0x00 0x01, so a field never matches a longer field it is a prefix of.
+func AppendBytes(dst []byte, b []byte) []byte {
+	for _, c := range b {
+		if c == escapeByte {
+			dst = append(dst, escapeByte, escapedByte)
+		} else {
+			dst = append(dst, c)
+		}
+	}
+
+	return append(dst, escapeByte, terminatorByte)
+}
+
+// AppendString appends the order-preserving encoding of s to dst.
+func AppendString(dst []byte, s string) []byte {
+	return AppendBytes(dst, []byte(s))
+}
+
+// AppendUint64 appends the order-preserving encoding of v to dst, which is
+// its 8-byte big-endian representation.
+func AppendUint64(dst []byte, v uint64) []byte {
+	return binary.BigEndian.AppendUint64(dst, v)
+}
+
+// AppendInt64 appends the order-preserving encoding of v to dst. The sign bit
+// is flipped so that negative values sort before positive values.
+func AppendInt64(dst []byte, v int64) []byte {
+	return AppendUint64(dst, uint64(v)^(1<<63))
+}
+
+// AppendTime appends the order-preserving encoding of t to dst. The encoding
+// covers the full range of time.Time with nanosecond precision, but does not
+// retain the location. Decoded values are in UTC.
+func AppendTime(dst []byte, t time.Time) []byte {
+	dst = AppendInt64(dst, t.Unix())
+	return binary.BigEndian.AppendUint32(dst, uint32(t.Nanosecond()))
+}
+
+// AppendBytesDesc is like AppendBytes, but encodes b in descending order.
+func AppendBytesDesc(dst []byte, b []byte) []byte {
+	return appendDesc(dst, func(dst []byte) []byte { return AppendBytes(dst, b) })
+}
+
+// AppendStringDesc is like AppendString, but encodes s in descending order.
+func AppendStringDesc(dst []byte, s string) []byte {
+	return AppendBytesDesc(dst, []byte(s))
+}
+
+// AppendUint64Desc is like AppendUint64, but encodes v in descending order.
+func AppendUint64Desc(dst []byte, v uint64) []byte {
+	return AppendUint64(dst, ^v)
+}
+
+// AppendInt64Desc is like AppendInt64, but encodes v in descending order.
+func AppendInt64Desc(dst []byte, v int64) []byte {
+	return appendDesc(dst, func(dst []byte) []byte { return AppendInt64(dst, v) })
+}
+
+// AppendTimeDesc is like AppendTime, but encodes t in descending order, which
+// is useful for listing the most recent entries first.
+func AppendTimeDesc(dst []byte, t time.Time) []byte {
+	return appendDesc(dst, func(dst []byte) []byte { return AppendTime(dst, t) })
+}
+
+// appendDesc appends the field produced by fn with every byte complemented.
+// Complementing reverses the byte-wise order of the field, which remains
+// correct for variable-length fields because their encoding is prefix-free.
+func appendDesc(dst []byte, fn func([]byte) []byte) []byte {
+	start := len(dst)
+	dst = fn(dst)
+
+	for i := start; i < len(dst); i++ {
+		dst[i] = ^dst[i]
+	}
+
+	return dst
+}
+
+// ReadBytes decodes a field encoded by AppendBytes from the beginning of src.
+// It returns the decoded value and the remainder of src.
+func ReadBytes(src []byte) (value []byte, rest []byte, err error) {
+	return readBytes(src, 0)
+}
+
+// ReadString decodes a field encoded by AppendString from the beginning of src.
+func ReadString(src []byte) (value string, rest []byte, err error) {
+	b, rest, err := ReadBytes(src)
+	return string(b), rest, err
+}
+
+// ReadUint64 decodes a field encoded by AppendUint64 from the beginning of src.
+func ReadUint64(src []byte) (value uint64, rest []byte, err error) {
+	if len(src) < 8 {
+		return 0, src, ErrMalformed
+	}
+
+	return binary.BigEndian.Uint64(src), src[8:], nil
+}
+
+// ReadInt64 decodes a field encoded by AppendInt64 from the beginning of src.
+func ReadInt64(src []byte) (value int64, rest []byte, err error) {
+	v, rest, err := ReadUint64(src)
+	return int64(v ^ (1 << 63)), rest, err
+}
+
+// ReadTime decodes a field encoded by AppendTime from the beginning of src.
+func ReadTime(src []byte) (value time.Time, rest []byte, err error) {
+	if len(src) < timeLen {
+		return time.Time{}, src, ErrMalformed
+	}
+
+	sec, _, _ := ReadInt64(src)
+	nsec := binary.BigEndian.Uint32(src[8:timeLen])
+
+	if nsec >= uint32(time.Second) {
+		return time.Time{}, src, ErrMalformed
+	}
+
+	return time.Unix(sec, int64(nsec)).UTC(), src[timeLen:], nil
+}
+
+// ReadBytesDesc decodes a field encoded by AppendBytesDesc.
+func ReadBytesDesc(src []byte) (value []byte, rest []byte, err error) {
+	return readBytes(src, 0xFF)
+}
+
+// ReadStringDesc decodes a field encoded by AppendStringDesc.
+func ReadStringDesc(src []byte) (value string, rest []byte, err error) {
+	b, rest, err := ReadBytesDesc(src)
+	return string(b), rest, err
+}
+
+// ReadUint64Desc decodes a field encoded by AppendUint64Desc.
+func ReadUint64Desc(src []byte) (value uint64, rest []byte, err error) {
+	v, rest, err := ReadUint64(src)
+	return ^v, rest, err
+}
+
+// ReadInt64Desc decodes a field encoded by AppendInt64Desc.
+func ReadInt64Desc(src []byte) (value int64, rest []byte, err error) {
+	v, rest, err := ReadUint64(src)
+	return int64(^v ^ (1 << 63)), rest, err
+}
+
+// ReadTimeDesc decodes a field encoded by AppendTimeDesc.
+func ReadTimeDesc(src []byte) (value time.Time, rest []byte, err error) {
+	if len(src) < timeLen {
+		return time.Time{}, src, ErrMalformed
+	}
+
+	var buf [timeLen]byte
+
+	for i := range buf {
+		buf[i] = ^src[i]
+	}
+
+	value, _, err = ReadTime(buf[:])
+
+	if err != nil {
+		return time.Time{}, src, err
+	}
+
+	return value, src[timeLen:], nil
+}
+
+// readBytes decodes a variable-length field whose bytes were XOR-ed with mask.
+func readBytes(src []byte, mask byte) ([]byte, []byte, error) {
+	ret := []byte{}
+
+	for i := 0; i < len(src); i++ {
+		c := src[i] ^ mask
+
+		if c != escapeByte {
+			ret = append(ret, c)
+			continue
+		}
+
+		if i+1 >= len(src) {
+			break
+		}
+
+		switch src[i+1] ^ mask {
+		case terminatorByte:
+			return ret, src[i+2:], nil
+		case escapedByte:
+			ret = append(ret, escapeByte)
+			i++
+		default:
+			return nil, src, ErrMalformed
+		}
+	}
+
+	return nil, src, ErrMalformed
+}
+
+// PrefixEnd returns the smallest key that is greater than every key that
+// begins with prefix, which is the exclusive upper bound of a range scan over
+// the prefix. It returns nil if no such key exists, meaning that the range is
+// unbounded.
+func PrefixEnd(prefix []byte) []byte {
+	ret := append([]byte{}, prefix...)
+
+	for i := len(ret) - 1; i >= 0; i-- {
+		if ret[i] < math.MaxUint8 {
+			ret[i]++
+			return ret[:i+1]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arckey
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBytesOrdering(t *testing.T) {
+	// Values in ascending order, including embedded 0x00 bytes and prefixes.
+	values := [][]byte{
+		{},
+		{0x00},
+		{0x00, 0x00},
+		{0x00, 0x01},
+		{0x01},
+		[]byte("a"),
+		[]byte("a\x00"),
+		[]byte("a\x00b"),
+		[]byte("ab"),
+		{0xFF},
+		{0xFF, 0xFF},
+	}
+
+	assertAscending(t, values, AppendBytes)
+	assertDescending(t, values, AppendBytesDesc)
+
+	for _, v := range values {
+		got, rest, err := ReadBytes(AppendBytes(nil, v))
+
+		if err != nil || !bytes.Equal(got, v) || len(rest) != 0 {
+			t.Errorf("unexpected decode of %q: got:%q, rest:%q, err:%v", v, got, rest, err)
+		}
+
+		got, rest, err = ReadBytesDesc(AppendBytesDesc(nil, v))
+
+		if err != nil || !bytes.Equal(got, v) || len(rest) != 0 {
+			t.Errorf("unexpected desc decode of %q: got:%q, rest:%q, err:%v", v, got, rest, err)
+		}
+	}
+}
+
+func TestIntegerOrdering(t *testing.T) {
+	unsigned := []uint64{0, 1, 255, 256, 1 << 32, math.MaxUint64}
+	signed := []int64{math.MinInt64, -256, -1, 0, 1, 256, math.MaxInt64}
+
+	var encoded [][]byte
+
+	for _, v := range unsigned {
+		encoded = append(encoded, AppendUint64(nil, v))
+
+		if got, _, _ := ReadUint64(AppendUint64(nil, v)); got != v {
+			t.Errorf("unexpected uint64: got:%d, want:%d", got, v)
+		}
+
+		if got, _, _ := ReadUint64Desc(AppendUint64Desc(nil, v)); got != v {
+			t.Errorf("unexpected desc uint64: got:%d, want:%d", got, v)
+		}
+	}
+
+	assertSorted(t, encoded, 1)
+
+	encoded = nil
+
+	for _, v := range signed {
+		encoded = append(encoded, AppendInt64Desc(nil, v))
+
+		if got, _, _ := ReadInt64(AppendInt64(nil, v)); got != v {
+			t.Errorf("unexpected int64: got:%d, want:%d", got, v)
+		}
+
+		if got, _, _ := ReadInt64Desc(AppendInt64Desc(nil, v)); got != v {
+			t.Errorf("unexpected desc int64: got:%d, want:%d", got, v)
+		}
+	}
+
+	assertSorted(t, encoded, -1)
+}
+
+func TestTimeOrdering(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	values := []time.Time{
+		time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC),
+		base.Add(-time.Nanosecond),
+		base,
+		base.Add(time.Nanosecond),
+		base.Add(time.Second),
+		time.Date(9999, 12, 31, 23, 59, 59, 999999999, time.UTC),
+	}
+
+	var asc, desc [][]byte
+
+	for _, v := range values {
+		asc = append(asc, AppendTime(nil, v))
+		desc = append(desc, AppendTimeDesc(nil, v))
+
+		if got, _, err := ReadTime(AppendTime(nil, v)); err != nil || !got.Equal(v) {
+			t.Errorf("unexpected time: got:%v, want:%v, err:%v", got, v, err)
+		}
+
+		if got, _, err := ReadTimeDesc(AppendTimeDesc(nil, v)); err != nil || !got.Equal(v) {
+			t.Errorf("unexpected desc time: got:%v, want:%v, err:%v", got, v, err)
+		}
+	}
+
+	assertSorted(t, asc, 1)
+	assertSorted(t, desc, -1)
+}
+
+func TestCompositeKey(t *testing.T) {
+	// Tenant "acme" must not share a prefix with tenant "acme2".
+	keys := [][]byte{
+		AppendUint64(AppendString(nil, "acme"), 2),
+		AppendUint64(AppendString(nil, "acme2"), 1),
+		AppendUint64(AppendString(nil, "acme"), 10),
+	}
+
+	prefix := AppendString(nil, "acme")
+
+	if bytes.HasPrefix(keys[1], prefix) {
+		t.Errorf("unexpected prefix match: %q", keys[1])
+	}
+
+	if bytes.Compare(keys[0], keys[2]) >= 0 || bytes.Compare(keys[2], keys[1]) >= 0 {
+		t.Errorf("unexpected composite key order")
+	}
+
+	name, rest, err := ReadString(keys[2])
+
+	if err != nil || name != "acme" {
+		t.Fatalf("unexpected name: got:%q, err:%v", name, err)
+	}
+
+	if id, rest, err := ReadUint64(rest); err != nil || id != 10 || len(rest) != 0 {
+		t.Errorf("unexpected id: got:%d, rest:%q, err:%v", id, rest, err)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	testCases := []struct {
+		name string
+		fn   func() error
+	}{
+		{"unterminated bytes", func() error { _, _, err := ReadBytes([]byte("abc")); return err }},
+		{"dangling escape", func() error { _, _, err := ReadBytes([]byte{'a', 0x00}); return err }},
+		{"invalid escape", func() error { _, _, err := ReadBytes([]byte{0x00, 0x02}); return err }},
+		{"short uint64", func() error { _, _, err := ReadUint64([]byte{0x01}); return err }},
+		{"short time", func() error { _, _, err := ReadTime(make([]byte, 8)); return err }},
+		{"invalid nanoseconds", func() error { _, _, err := ReadTime(bytes.Repeat([]byte{0xFF}, 12)); return err }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.fn(); err != ErrMalformed {
+				t.Errorf("unexpected error: got:%v, want:%v", err, ErrMalformed)
+			}
+		})
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	testCases := []struct {
+		prefix []byte
+		want   []byte
+	}{
+		{[]byte("abc"), []byte("abd")},
+		{[]byte{'a', 0xFF}, []byte("b")},
+		{[]byte{0xFF, 0xFF}, nil},
+		{nil, nil},
+	}
+
+	for _, tc := range testCases {
+		if got := PrefixEnd(tc.prefix); !bytes.Equal(got, tc.want) {
+			t.Errorf("unexpected prefix end: got:%q, want:%q", got, tc.want)
+		}
+	}
+}
+
+func assertAscending(t *testing.T, values [][]byte, fn func([]byte, []byte) []byte) {
+	t.Helper()
+
+	var encoded [][]byte
+
+	for _, v := range values {
+		encoded = append(encoded, fn(nil, v))
+	}
+
+	assertSorted(t, encoded, 1)
+}
+
+func assertDescending(t *testing.T, values [][]byte, fn func([]byte, []byte) []byte) {
+	t.Helper()
+
+	var encoded [][]byte
+
+	for _, v := range values {
+		encoded = append(encoded, fn(nil, v))
+	}
+
+	assertSorted(t, encoded, -1)
+}
+
+// assertSorted checks that the encoded values are strictly ordered in the
+// given direction, where 1 means ascending and -1 means descending.
+func assertSorted(t *testing.T, encoded [][]byte, direction int) {
+	t.Helper()
+
+	for i := 1; i < len(encoded); i++ {
+		if bytes.Compare(encoded[i-1], encoded[i]) != -direction {
+			t.Errorf("unexpected order at %d: %x vs %x", i, encoded[i-1], encoded[i])
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arckey
+
+const (
+	// PathSeparator separates the segments of a path-style key.
+	PathSeparator = byte('/')
+
+	// PathEscape precedes the separators and escape bytes that appear within
+	// the segments of a path-style key.
+	PathEscape = byte('\\')
+)
+
+// JoinPath builds a path-style key, such as "users/alice/settings", from the
+// given segments. Separators and escape bytes within the segments are escaped,
+// therefore segments may hold arbitrary bytes, and SplitPath always recovers
+// the original segments. It returns nil if there are no segments.
+func JoinPath(segments ...[]byte) []byte {
+	var ret []byte
+
+	for i, segment := range segments {
+		if i > 0 {
+			ret = append(ret, PathSeparator)
+		}
+
+		ret = appendPathSegment(ret, segment)
+	}
+
+	if ret == nil && len(segments) > 0 {
+		ret = []byte{}
+	}
+
+	return ret
+}
+
+// PathPrefix is like JoinPath, but appends a trailing separator. The result
+// is the prefix of the keys below the given path, which can be passed to
+// Arc.ScanChildren. It returns nil, the prefix of the root, if there are no
+// segments.
+func PathPrefix(segments ...[]byte) []byte {
+	if len(segments) == 0 {
+		return nil
+	}
+
+	return append(JoinPath(segments...), PathSeparator)
+}
+
+// SplitPath splits a path-style key built by JoinPath into its unescaped
+// segments. It returns ErrMalformed if the key holds an invalid escape
+// sequence.
+func SplitPath(key []byte) ([][]byte, error) {
+	var ret [][]byte
+
+	for {
+		i := IndexPathSeparator(key)
+
+		if i < 0 {
+			segment, err := unescapePathSegment(key)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return append(ret, segment), nil
+		}
+
+		segment, err := unescapePathSegment(key[:i])
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, segment)
+		key = key[i+1:]
+	}
+}
+
+// IndexPathSeparator returns the index of the first unescaped separator in the
+// path-style key, or -1 if there is none.
+func IndexPathSeparator(key []byte) int {
+	for i := 0; i < len(key); i++ {
+		switch key[i] {
+		case PathEscape:
+			i++
+		case PathSeparator:
+			return i
+		}
+	}
+
+	return -1
+}
+
+// appendPathSegment appends the escaped segment to dst.
+func appendPathSegment(dst []byte, segment []byte) []byte {
+	for _, c := range segment {
+		if c == PathSeparator || c == PathEscape {
+			dst = append(dst, PathEscape)
+		}
+
+		dst = append(dst, c)
+	}
+
+	return dst
+}
+
+// unescapePathSegment decodes a segment escaped by appendPathSegment, which
+// must not hold unescaped separators.
+func unescapePathSegment(src []byte) ([]byte, error) {
+	ret := make([]byte, 0, len(src))
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+
+		if c == PathEscape {
+			if i+1 >= len(src) || (src[i+1] != PathSeparator && src[i+1] != PathEscape) {
+				return nil, ErrMalformed
+			}
+
+			i++
+			c = src[i]
+		}
+
+		ret = append(ret, c)
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,78 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arckey
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestJoinPath(t *testing.T) {
+	testCases := []struct {
+		segments [][]byte
+		want     []byte
+	}{
+		{nil, nil},
+		{[][]byte{[]byte("users")}, []byte("users")},
+		{[][]byte{[]byte("users"), []byte("alice")}, []byte("users/alice")},
+		{[][]byte{[]byte("a/b"), []byte(`c\d`)}, []byte(`a\/b/c\\d`)},
+		{[][]byte{{}, {}}, []byte("/")},
+	}
+
+	for _, tc := range testCases {
+		got := JoinPath(tc.segments...)
+
+		if !bytes.Equal(got, tc.want) {
+			t.Errorf("unexpected key: got:%q, want:%q", got, tc.want)
+		}
+
+		if len(tc.segments) == 0 {
+			continue
+		}
+
+		segments, err := SplitPath(got)
+
+		if err != nil || !slices.EqualFunc(segments, tc.segments, bytes.Equal) {
+			t.Errorf("unexpected segments of %q: got:%q, err:%v", got, segments, err)
+		}
+	}
+}
+
+func TestPathPrefix(t *testing.T) {
+	if got := PathPrefix(); got != nil {
+		t.Errorf("unexpected root prefix: %q", got)
+	}
+
+	if got := PathPrefix([]byte("a/b"), []byte("c")); !bytes.Equal(got, []byte(`a\/b/c/`)) {
+		t.Errorf("unexpected prefix: %q", got)
+	}
+}
+
+func TestSplitPathMalformed(t *testing.T) {
+	for _, key := range []string{`a\`, `a\b`, `a/\x`} {
+		if _, err := SplitPath([]byte(key)); err != ErrMalformed {
+			t.Errorf("unexpected error for %q: got:%v, want:%v", key, err, ErrMalformed)
+		}
+	}
+}
+
+func TestIndexPathSeparator(t *testing.T) {
+	testCases := []struct {
+		key  string
+		want int
+	}{
+		{"", -1},
+		{"abc", -1},
+		{"a/b", 1},
+		{`a\/b/c`, 4},
+		{`a\\/b`, 3},
+	}
+
+	for _, tc := range testCases {
+		if got := IndexPathSeparator([]byte(tc.key)); got != tc.want {
+			t.Errorf("unexpected index for %q: got:%d, want:%d", tc.key, got, tc.want)
+		}
+	}
+}
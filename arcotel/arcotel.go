@@ -0,0 +1,190 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+// Package arcotel traces the operations of an Arc database, which lets
+// services see the latency of Arc inside their distributed traces. Wrap
+// returns a DB whose Get, Put, Delete, and Scan methods take a context, and
+// start a span for every call with the sizes of the keys and values, and the
+// outcome, as attributes. Keys and values are never recorded.
+//
+// The package does not depend on OpenTelemetry. Instead, spans are started by
+// a Tracer, which takes a few lines to implement on top of an OpenTelemetry
+// tracer:
+//
+//	type tracer struct{ trace.Tracer }
+//
+//	func (t tracer) Start(ctx context.Context, name string) (context.Context, arcotel.Span) {
+//		ctx, s := t.Tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//		return ctx, span{s}
+//	}
+//
+//	type span struct{ trace.Span }
+//
+//	func (s span) SetInt(key string, v int)   { s.Span.SetAttributes(attribute.Int(key, v)) }
+//	func (s span) SetBool(key string, v bool) { s.Span.SetAttributes(attribute.Bool(key, v)) }
+//	func (s span) SetString(key, v string)    { s.Span.SetAttributes(attribute.String(key, v)) }
+//	func (s span) End()                       { s.Span.End() }
+//
+//	func (s span) Fail(err error) {
+//		s.Span.RecordError(err)
+//		s.Span.SetStatus(codes.Error, err.Error())
+//	}
+//
+//	db := arcotel.Wrap(arcDB, tracer{otel.Tracer("arc")})
+//	value, err := db.Get(ctx, []byte("key"))
+package arcotel
+
+import (
+	"context"
+	"errors"
+	"iter"
+
+	"github.com/chronohq/arc"
+)
+
+// Span attribute keys. The system and operation names follow the OpenTelemetry
+// semantic conventions for database clients.
+const (
+	AttrSystem     = "db.system.name"    // Always "arc".
+	AttrOperation  = "db.operation.name" // Get, Put, Delete, or Scan.
+	AttrKeySize    = "arc.key.size"      // Size of the key in bytes.
+	AttrValueSize  = "arc.value.size"    // Size of the value in bytes.
+	AttrPrefixSize = "arc.prefix.size"   // Size of the scanned prefix in bytes.
+	AttrFound      = "arc.found"         // Whether the key existed.
+	AttrRecords    = "arc.records"       // Number of records yielded by Scan.
+)
+
+// Tracer starts the spans of the operations.
+type Tracer interface {
+	// Start starts a span with the given name as a child of the span in
+	// ctx, if any, and returns a context that holds the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	SetInt(key string, value int)
+	SetBool(key string, value bool)
+	SetString(key string, value string)
+
+	// Fail marks the span as failed with the given error.
+	Fail(err error)
+
+	// End completes the span.
+	End()
+}
+
+// DB is an Arc database whose operations are traced.
+type DB struct {
+	db     *arc.Arc
+	tracer Tracer
+}
+
+// Wrap returns a DB that traces the operations on db using the given tracer.
+func Wrap(db *arc.Arc, tracer Tracer) *DB {
+	return &DB{db: db, tracer: tracer}
+}
+
+// Arc returns the wrapped database, whose operations are not traced.
+func (d *DB) Arc() *arc.Arc {
+	return d.db
+}
+
+// Get is like arc.Arc.Get, and traces the call as the span "arc.Get". A
+// missing key is not considered a failure, but sets the found attribute to
+// false.
+func (d *DB) Get(ctx context.Context, key []byte) ([]byte, error) {
+	span := d.start(ctx, "Get")
+	defer span.End()
+
+	span.SetInt(AttrKeySize, len(key))
+
+	value, err := d.db.Get(key)
+
+	if d.finish(span, err) {
+		span.SetInt(AttrValueSize, len(value))
+	}
+
+	return value, err
+}
+
+// Put is like arc.Arc.Put, and traces the call as the span "arc.Put".
+func (d *DB) Put(ctx context.Context, key []byte, value []byte) error {
+	span := d.start(ctx, "Put")
+	defer span.End()
+
+	span.SetInt(AttrKeySize, len(key))
+	span.SetInt(AttrValueSize, len(value))
+
+	err := d.db.Put(key, value)
+
+	if err != nil {
+		span.Fail(err)
+	}
+
+	return err
+}
+
+// Delete is like arc.Arc.Delete, and traces the call as the span "arc.Delete".
+// A missing key is reported like Get does.
+func (d *DB) Delete(ctx context.Context, key []byte) error {
+	span := d.start(ctx, "Delete")
+	defer span.End()
+
+	span.SetInt(AttrKeySize, len(key))
+
+	err := d.db.Delete(key)
+	d.finish(span, err)
+
+	return err
+}
+
+// Scan is like arc.Arc.Scan, and traces the iteration as the span "arc.Scan",
+// which starts when the iteration begins and ends when the loop exits. The
+// number of records yielded is recorded as an attribute.
+func (d *DB) Scan(ctx context.Context, prefix []byte) iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		span := d.start(ctx, "Scan")
+		defer span.End()
+
+		span.SetInt(AttrPrefixSize, len(prefix))
+
+		var records int
+
+		defer func() {
+			span.SetInt(AttrRecords, records)
+		}()
+
+		for key, value := range d.db.Scan(prefix) {
+			records++
+
+			if !yield(key, value) {
+				return
+			}
+		}
+	}
+}
+
+// start starts the span of the named operation, and sets the attributes that
+// are common to every operation.
+func (d *DB) start(ctx context.Context, op string) Span {
+	_, span := d.tracer.Start(ctx, "arc."+op)
+
+	span.SetString(AttrSystem, "arc")
+	span.SetString(AttrOperation, op)
+
+	return span
+}
+
+// finish records the outcome of a lookup by key on the span, and returns true
+// if the key was found.
+func (d *DB) finish(span Span, err error) bool {
+	if err != nil && !errors.Is(err, arc.ErrKeyNotFound) {
+		span.Fail(err)
+		return false
+	}
+
+	span.SetBool(AttrFound, err == nil)
+
+	return err == nil
+}
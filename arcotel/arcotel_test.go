@@ -0,0 +1,113 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arcotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/chronohq/arc"
+)
+
+type testSpan struct {
+	name  string
+	attrs map[string]any
+	err   error
+	ended bool
+}
+
+func (s *testSpan) SetInt(key string, value int)       { s.attrs[key] = value }
+func (s *testSpan) SetBool(key string, value bool)     { s.attrs[key] = value }
+func (s *testSpan) SetString(key string, value string) { s.attrs[key] = value }
+func (s *testSpan) Fail(err error)                     { s.err = err }
+func (s *testSpan) End()                               { s.ended = true }
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &testSpan{name: name, attrs: map[string]any{}}
+	t.spans = append(t.spans, span)
+
+	return ctx, span
+}
+
+// last returns the most recently started span.
+func (t *testTracer) last() *testSpan {
+	return t.spans[len(t.spans)-1]
+}
+
+func TestWrap(t *testing.T) {
+	ctx := context.Background()
+	tracer := &testTracer{}
+	db := Wrap(arc.New(), tracer)
+
+	if err := db.Put(ctx, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	span := tracer.last()
+
+	if span.name != "arc.Put" || !span.ended || span.attrs[AttrKeySize] != 3 || span.attrs[AttrValueSize] != 5 || span.attrs[AttrOperation] != "Put" {
+		t.Errorf("unexpected span: %+v", span)
+	}
+
+	if value, err := db.Get(ctx, []byte("key")); err != nil || string(value) != "value" {
+		t.Fatalf("unexpected value: got:%q, err:%v", value, err)
+	}
+
+	if span := tracer.last(); span.name != "arc.Get" || span.attrs[AttrFound] != true || span.attrs[AttrValueSize] != 5 || span.err != nil {
+		t.Errorf("unexpected span: %+v", span)
+	}
+
+	if _, err := db.Get(ctx, []byte("missing")); !errors.Is(err, arc.ErrKeyNotFound) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if span := tracer.last(); span.attrs[AttrFound] != false || span.err != nil {
+		t.Errorf("expected a missing key not to fail the span: %+v", span)
+	}
+
+	db.Put(ctx, []byte("key2"), nil)
+
+	var records int
+
+	for range db.Scan(ctx, []byte("key")) {
+		records++
+		break
+	}
+
+	if span := tracer.last(); span.name != "arc.Scan" || !span.ended || span.attrs[AttrRecords] != records || span.attrs[AttrPrefixSize] != 3 {
+		t.Errorf("unexpected span: %+v", span)
+	}
+
+	if err := db.Delete(ctx, []byte("key")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if span := tracer.last(); span.name != "arc.Delete" || span.attrs[AttrFound] != true {
+		t.Errorf("unexpected span: %+v", span)
+	}
+
+	if db.Arc().Len() != 1 {
+		t.Errorf("unexpected length: %d", db.Arc().Len())
+	}
+}
+
+func TestWrapFail(t *testing.T) {
+	tracer := &testTracer{}
+	db := Wrap(arc.New(), tracer)
+
+	err := db.Put(context.Background(), nil, []byte("value"))
+
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	if span := tracer.last(); !errors.Is(span.err, err) || !span.ended {
+		t.Errorf("expected the span to fail: %+v", span)
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+// Package arcraft replicates an Arc database with the Raft consensus
+// algorithm, which keeps a strongly consistent copy of the database on every
+// node of a cluster. It is experimental, and its command format may change.
+//
+// An FSM is the replicated state machine of a node. Writes are encoded as a
+// Batch, whose Bytes are proposed to the Raft log, and every node applies the
+// committed batches to its database atomically and in the same order.
+// Snapshots of the state machine are backups of the database in the file
+// format, as written by arc.Arc.Backup.
+//
+// The package does not depend on a Raft implementation. Instead, the FSM takes
+// a few lines to adapt to one, such as hashicorp/raft:
+//
+//	type fsm struct{ *arcraft.FSM }
+//
+//	func (f fsm) Apply(l *raft.Log) any         { return f.FSM.Apply(l.Data) }
+//	func (f fsm) Restore(r io.ReadCloser) error { return f.FSM.Restore(r) }
+//
+//	func (f fsm) Snapshot() (raft.FSMSnapshot, error) {
+//		s, err := f.FSM.Snapshot()
+//		return snapshot{s}, err
+//	}
+//
+//	type snapshot struct{ *arcraft.Snapshot }
+//
+//	func (s snapshot) Persist(sink raft.SnapshotSink) error {
+//		if err := s.Snapshot.Persist(sink); err != nil {
+//			sink.Cancel()
+//			return err
+//		}
+//		return sink.Close()
+//	}
+//
+//	r, _ := raft.NewRaft(config, fsm{arcraft.New()}, logs, stable, snaps, transport)
+//
+//	var batch arcraft.Batch
+//	batch.Put([]byte("users/alice"), value)
+//	future := r.Apply(batch.Bytes(), time.Second)
+//
+//	if err := future.Error(); err != nil {
+//		return err // Not committed, such as on a follower.
+//	}
+//	if err, _ := future.Response().(error); err != nil {
+//		return err // Committed, but rejected by the database.
+//	}
+//
+// The database of a node must only be written through the log, since direct
+// writes are not replicated. Reads from the database of a node may lag behind
+// the leader, therefore reads that must observe every committed write go
+// through the leader after a barrier, such as raft.Raft.Barrier.
+package arcraft
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/chronohq/arc"
+)
+
+// ErrInvalidCommand is returned by Apply if the command is not a Batch.
+var ErrInvalidCommand = errors.New("arcraft: invalid command")
+
+const (
+	// commandVersion is the version of the encoding of the batches, which
+	// leads every encoded batch.
+	commandVersion = 1
+
+	sizeOfUint8  = 1
+	sizeOfUint32 = 4
+)
+
+// Batch is a sequence of writes that an FSM applies atomically. The zero value
+// is an empty batch.
+type Batch struct {
+	buf []byte
+	len int
+}
+
+// Put adds a write of the value of the key to the batch.
+func (b *Batch) Put(key []byte, value []byte) {
+	b.append(arc.OpPut, key, value)
+}
+
+// Delete adds a deletion of the key to the batch. Deleting a key that does
+// not exist is not an error.
+func (b *Batch) Delete(key []byte) {
+	b.append(arc.OpDelete, key, nil)
+}
+
+// Len returns the number of writes in the batch.
+func (b *Batch) Len() int {
+	return b.len
+}
+
+// Bytes returns the encoded batch, which is proposed to the Raft log. The
+// batch remains usable, and later writes are not reflected in the returned
+// slice.
+func (b *Batch) Bytes() []byte {
+	if len(b.buf) == 0 {
+		return []byte{commandVersion}
+	}
+
+	return b.buf[:len(b.buf):len(b.buf)]
+}
+
+// append encodes a write as [op][key length][key][value length][value].
+func (b *Batch) append(op arc.Op, key []byte, value []byte) {
+	if len(b.buf) == 0 {
+		b.buf = append(b.buf, commandVersion)
+	}
+
+	b.buf = append(b.buf, byte(op))
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(key)))
+	b.buf = append(b.buf, key...)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(value)))
+	b.buf = append(b.buf, value...)
+	b.len++
+}
+
+// write is a decoded write of a batch.
+type write struct {
+	op    arc.Op
+	key   []byte
+	value []byte
+}
+
+// decodeBatch returns the writes of the encoded batch. Returns
+// ErrInvalidCommand if the batch is malformed.
+func decodeBatch(src []byte) ([]write, error) {
+	if len(src) == 0 || src[0] != commandVersion {
+		return nil, ErrInvalidCommand
+	}
+
+	var ret []write
+
+	for pos := sizeOfUint8; pos < len(src); {
+		w := write{op: arc.Op(src[pos])}
+		pos += sizeOfUint8
+
+		if w.op != arc.OpPut && w.op != arc.OpDelete {
+			return nil, ErrInvalidCommand
+		}
+
+		for _, field := range []*[]byte{&w.key, &w.value} {
+			if len(src)-pos < sizeOfUint32 {
+				return nil, ErrInvalidCommand
+			}
+
+			n := binary.LittleEndian.Uint32(src[pos:])
+			pos += sizeOfUint32
+
+			if uint64(len(src)-pos) < uint64(n) {
+				return nil, ErrInvalidCommand
+			}
+
+			*field = src[pos : pos+int(n) : pos+int(n)]
+			pos += int(n)
+		}
+
+		ret = append(ret, w)
+	}
+
+	return ret, nil
+}
+
+// FSM is the replicated state machine of a node, which applies the committed
+// batches to an Arc database.
+type FSM struct {
+	opts arc.Options
+
+	// Guards db, which Restore replaces.
+	mu sync.RWMutex
+	db *arc.Arc
+}
+
+// New returns an FSM over an empty in-memory database with the default
+// options.
+func New() *FSM {
+	ret, _ := NewWithOptions(arc.Options{})
+	return ret
+}
+
+// NewWithOptions returns an FSM over an empty in-memory database configured
+// with the given options, which also configure the databases restored from
+// snapshots. Every node must use the same options, since options such as
+// MaxRecords and KeyTransform determine the outcome of the writes. Options
+// that depend on timing, such as MaxTxnDuration and Backpressure, make the
+// nodes diverge. Returns arc.ErrInvalidOptions if the options are out of
+// range, or if they would make every node reach a different state from the
+// same writes, which is the case of arc.EvictRandom, and of RecordTimestamps
+// since each node records the time at which it applies a write.
+func NewWithOptions(opts arc.Options) (*FSM, error) {
+	if opts.Eviction == arc.EvictRandom || opts.RecordTimestamps {
+		return nil, arc.ErrInvalidOptions
+	}
+
+	db, err := arc.NewWithOptions(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &FSM{opts: opts, db: db}, nil
+}
+
+// DB returns the database of the node. The database is replaced by Restore,
+// therefore callers must not retain it across restores.
+func (f *FSM) DB() *arc.Arc {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.db
+}
+
+// Apply applies an encoded Batch that was committed to the Raft log, and
+// returns the outcome as an error, which is nil if the batch was applied. The
+// writes of the batch are committed in a single transaction, therefore if one
+// fails, such as with arc.ErrQuotaExceeded, the writes that were applied
+// before it are undone, and the database is left as it was. Returns
+// ErrInvalidCommand if the command is malformed.
+func (f *FSM) Apply(command []byte) any {
+	writes, err := decodeBatch(command)
+
+	if err != nil {
+		return err
+	}
+
+	// Pessimistic transactions never conflict, nor are throttled, which
+	// applies the batch on every node alike.
+	txn := f.DB().BeginWithMode(arc.TxnPessimistic)
+
+	for _, w := range writes {
+		var err error
+
+		if w.op == arc.OpPut {
+			err = txn.Put(w.key, w.value)
+		} else if err = txn.Delete(w.key); errors.Is(err, arc.ErrKeyNotFound) {
+			err = nil
+		}
+
+		if err != nil {
+			txn.Rollback()
+			return err
+		}
+	}
+
+	if err := txn.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Snapshot captures the database as of the last applied batch. The capture
+// takes constant time, therefore it barely delays the next batch, and the
+// snapshot is serialized by Persist concurrently with later batches.
+func (f *FSM) Snapshot() (*Snapshot, error) {
+	return &Snapshot{db: f.DB().Clone()}, nil
+}
+
+// Restore replaces the database with the one in the snapshot written by
+// Snapshot.Persist, and closes r. The replaced database is closed. Returns
+// arc.ErrCorrupted if the snapshot is corrupted, in which case the database
+// is left unchanged.
+func (f *FSM) Restore(r io.ReadCloser) error {
+	defer r.Close()
+
+	db, err := arc.ReadBackup(r, f.opts)
+
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	prev := f.db
+	f.db = db
+	f.mu.Unlock()
+
+	return prev.Close()
+}
+
+// Close closes the database of the node.
+func (f *FSM) Close() error {
+	return f.DB().Close()
+}
+
+// Snapshot is a point-in-time copy of the database of an FSM.
+type Snapshot struct {
+	db *arc.Arc
+}
+
+// Persist writes the snapshot to w in the file format of Arc.
+func (s *Snapshot) Persist(w io.Writer) error {
+	return s.db.Backup(w)
+}
+
+// Release discards the snapshot.
+func (s *Snapshot) Release() {
+	s.db.Close()
+}
@@ -0,0 +1,181 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arcraft
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/chronohq/arc"
+)
+
+// applyBatch applies the batch to the FSM, and fails the test on error.
+func applyBatch(t *testing.T, f *FSM, b *Batch) {
+	t.Helper()
+
+	if err, _ := f.Apply(b.Bytes()).(error); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	f := New()
+	defer f.Close()
+
+	var b Batch
+	b.Put([]byte("apple"), []byte("red"))
+	b.Put([]byte("banana"), []byte("yellow"))
+	b.Delete([]byte("missing"))
+
+	if b.Len() != 3 {
+		t.Errorf("unexpected length: %d", b.Len())
+	}
+
+	applyBatch(t, f, &b)
+
+	var next Batch
+	next.Delete([]byte("apple"))
+	next.Put([]byte("banana"), []byte("green"))
+
+	applyBatch(t, f, &next)
+
+	if _, err := f.DB().Get([]byte("apple")); !errors.Is(err, arc.ErrKeyNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if got, _ := f.DB().Get([]byte("banana")); string(got) != "green" {
+		t.Errorf("unexpected value: %q", got)
+	}
+
+	// Empty batches are valid.
+	applyBatch(t, f, &Batch{})
+}
+
+func TestApplyAtomic(t *testing.T) {
+	f, err := NewWithOptions(arc.Options{MaxValueBytes: 4})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer f.Close()
+
+	var b Batch
+	b.Put([]byte("apple"), []byte("red"))
+	b.Put([]byte("banana"), []byte("yellow"))
+
+	if err, _ := f.Apply(b.Bytes()).(error); err == nil {
+		t.Errorf("expected an error")
+	}
+
+	if f.DB().Len() != 0 {
+		t.Errorf("expected none of the writes to be applied")
+	}
+
+	// The third write only exceeds the limit once the others are applied.
+	f, _ = NewWithOptions(arc.Options{MaxChildrenPerNode: 2})
+	defer f.Close()
+
+	b = Batch{}
+	b.Put([]byte("a"), nil)
+	b.Put([]byte("b"), nil)
+	b.Put([]byte("c"), nil)
+
+	if err, _ := f.Apply(b.Bytes()).(error); !errors.Is(err, arc.ErrTooManyChildren) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if f.DB().Len() != 0 {
+		t.Errorf("expected none of the writes to be applied")
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	for _, opts := range []arc.Options{{Eviction: arc.EvictRandom, MaxRecords: 10}, {RecordTimestamps: true}} {
+		if _, err := NewWithOptions(opts); !errors.Is(err, arc.ErrInvalidOptions) {
+			t.Errorf("unexpected error with %+v: %v", opts, err)
+		}
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	f := New()
+	defer f.Close()
+
+	var b Batch
+	b.Put([]byte("apple"), []byte("red"))
+	valid := b.Bytes()
+
+	invalid := [][]byte{
+		nil,
+		{commandVersion + 1},
+		{commandVersion, byte(arc.OpRename)},
+		valid[:len(valid)-1],
+	}
+
+	for _, command := range invalid {
+		if err, _ := f.Apply(command).(error); !errors.Is(err, ErrInvalidCommand) {
+			t.Errorf("unexpected error with %v: %v", command, err)
+		}
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	leader := New()
+	defer leader.Close()
+
+	var b Batch
+	b.Put([]byte("apple"), []byte("red"))
+	b.Put([]byte("banana"), bytes.Repeat([]byte("y"), 1024))
+	applyBatch(t, leader, &b)
+
+	snapshot, err := leader.Snapshot()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Batches applied after the capture are not in the snapshot.
+	var later Batch
+	later.Put([]byte("cherry"), []byte("red"))
+	applyBatch(t, leader, &later)
+
+	var buf bytes.Buffer
+
+	if err := snapshot.Persist(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	snapshot.Release()
+
+	follower := New()
+	defer follower.Close()
+
+	applyBatch(t, follower, &later)
+
+	if err := follower.Restore(io.NopCloser(&buf)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if follower.DB().Len() != 2 {
+		t.Errorf("unexpected length: %d", follower.DB().Len())
+	}
+
+	applyBatch(t, follower, &later)
+
+	if !bytes.Equal(follower.DB().RootHash(), leader.DB().RootHash()) {
+		t.Errorf("expected the follower to converge with the leader")
+	}
+
+	// A corrupted snapshot leaves the database unchanged.
+	if err := follower.Restore(io.NopCloser(bytes.NewReader([]byte("corrupt")))); err == nil {
+		t.Errorf("expected an error")
+	}
+
+	if follower.DB().Len() != 3 {
+		t.Errorf("unexpected length: %d", follower.DB().Len())
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+// Package arcs3 implements arc.ObjectStore for Amazon S3 and compatible
+// services, such as MinIO and Cloudflare R2. Requests are signed with AWS
+// Signature Version 4, and the package does not depend on the AWS SDK. For
+// example, a database can be backed up as follows:
+//
+//	store := &arcs3.Client{
+//		Endpoint:        "https://s3.us-east-1.amazonaws.com",
+//		Region:          "us-east-1",
+//		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+//		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+//	}
+//
+//	err := db.BackupToObjectStore(ctx, store, "backups", "arc/db")
+package arcs3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	serviceName      = "s3"
+	amzDateFormat    = "20060102T150405Z"
+	scopeDateFormat  = "20060102"
+
+	// maxErrorBodyLen bounds the portion of an error response that is
+	// included in the returned error.
+	maxErrorBodyLen = 1 << 10
+)
+
+// Client is an S3-compatible object store. Objects are addressed using
+// path-style URLs, such as https://endpoint/bucket/key, which are supported
+// by all S3-compatible services.
+type Client struct {
+	// Endpoint is the base URL of the service, including the scheme.
+	Endpoint string
+
+	// Region is the region of the bucket, such as "us-east-1". Services
+	// without regions typically accept "auto" or "us-east-1".
+	Region string
+
+	// AccessKeyID and SecretAccessKey are the credentials of the requests.
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// SessionToken is the token of temporary credentials, if any.
+	SessionToken string
+
+	// HTTPClient sends the requests. http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+}
+
+// PutObject uploads the body as the object with the given key.
+func (c *Client) PutObject(ctx context.Context, bucket string, key string, body []byte) error {
+	resp, err := c.do(ctx, http.MethodPut, bucket, key, body)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(http.MethodPut, bucket, key, resp)
+	}
+
+	return nil
+}
+
+// GetObject downloads the object with the given key. The caller must close
+// the returned reader.
+func (c *Client) GetObject(ctx context.Context, bucket string, key string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, bucket, key, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError(http.MethodGet, bucket, key, resp)
+	}
+
+	return resp.Body, nil
+}
+
+// do sends a signed request for the object.
+func (c *Client) do(ctx context.Context, method string, bucket string, key string, body []byte) (*http.Response, error) {
+	u, err := url.Parse(strings.TrimSuffix(c.Endpoint, "/"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Signature Version 4 requires a stricter escaping of the path than the
+	// one applied by net/url, which is used verbatim for the request.
+	u.Path += "/" + bucket + "/" + key
+	u.RawPath = escapePath(u.Path)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.ContentLength = int64(len(body))
+
+	if body == nil {
+		req.Body = http.NoBody
+	}
+
+	c.sign(req, body, time.Now().UTC())
+
+	client := c.HTTPClient
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return client.Do(req)
+}
+
+// sign adds the Signature Version 4 authorization to the request, which
+// covers the host, the payload, and every header already set on the request.
+func (c *Client) sign(req *http.Request, body []byte, t time.Time) {
+	payloadHash := sha256.Sum256(body)
+	amzDate := t.Format(amzDateFormat)
+	scope := t.Format(scopeDateFormat) + "/" + c.Region + "/" + serviceName + "/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	var canonicalHeaders strings.Builder
+
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := signingAlgorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), t.Format(scopeDateFormat))
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, serviceName)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm, c.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// escapePath escapes every byte of the path except the unreserved characters
+// of RFC 3986 and the slashes.
+func escapePath(path string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the data.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
+
+// responseError describes an unsuccessful response, including the start of
+// its body, which holds the error code of the service.
+func responseError(method string, bucket string, key string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyLen))
+
+	return fmt.Errorf("arcs3: %s %s/%s: %s: %s", method, bucket, key, resp.Status, bytes.TrimSpace(body))
+}
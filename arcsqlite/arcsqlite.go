@@ -0,0 +1,98 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+// Package arcsqlite copies records between Arc and SQLite databases, which
+// allows the data to be inspected with standard SQL tooling, and to be
+// migrated in either direction. The records are mapped to a table of two
+// columns:
+//
+//	CREATE TABLE arc (key BLOB PRIMARY KEY, value BLOB NOT NULL)
+//
+// The package works with database/sql, and does not depend on a particular
+// SQLite driver. The caller registers a driver of its choice, such as
+// modernc.org/sqlite or github.com/mattn/go-sqlite3, and opens the database:
+//
+//	conn, err := sql.Open("sqlite", "data.db")
+//	err = arcsqlite.Export(ctx, db, conn)
+package arcsqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/chronohq/arc"
+)
+
+// Table is the name of the table that holds the records.
+const Table = "arc"
+
+const (
+	createTableQuery = "CREATE TABLE IF NOT EXISTS " + Table + " (key BLOB PRIMARY KEY, value BLOB NOT NULL)"
+	insertQuery      = "INSERT OR REPLACE INTO " + Table + " (key, value) VALUES (?, ?)"
+	selectQuery      = "SELECT key, value FROM " + Table + " ORDER BY key"
+)
+
+// Export copies every record of db to the table of conn, which is created if
+// it does not exist. Records of the table with the same keys are replaced,
+// and other records are retained. The records are written in a single
+// transaction, therefore the table is left untouched if an error occurs.
+func Export(ctx context.Context, db *arc.Arc, conn *sql.DB) error {
+	tx, err := conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, createTableQuery); err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, insertQuery)
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	for key, value := range db.Scan(nil) {
+		// SQLite stores empty blobs given as nil as NULL.
+		if value == nil {
+			value = []byte{}
+		}
+
+		if _, err := stmt.ExecContext(ctx, key, value); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Import puts every record of the table of conn into db. The records that
+// precede an error remain in db.
+func Import(ctx context.Context, db *arc.Arc, conn *sql.DB) error {
+	rows, err := conn.QueryContext(ctx, selectQuery)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, value []byte
+
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+
+		if err := db.Put(key, value); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
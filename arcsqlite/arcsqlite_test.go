@@ -0,0 +1,193 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arcsqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chronohq/arc"
+)
+
+// fakeDriver is a database/sql driver that understands the queries issued by
+// this package, which allows testing without a SQLite dependency. Databases
+// are shared by name, and transactions apply their writes immediately.
+type fakeDriver struct {
+	mu  sync.Mutex
+	dbs map[string]*fakeDB
+}
+
+type fakeDB struct {
+	mu      sync.Mutex
+	table   bool
+	records map[string][]byte
+}
+
+type fakeConn struct{ db *fakeDB }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+type fakeRows struct {
+	keys []string
+	db   *fakeDB
+}
+
+var testDriver = &fakeDriver{dbs: map[string]*fakeDB{}}
+
+func init() {
+	sql.Register("arcsqlite-fake", testDriver)
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.dbs[name] == nil {
+		d.dbs[name] = &fakeDB{records: map[string][]byte{}}
+	}
+
+	return &fakeConn{db: d.dbs[name]}, nil
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *fakeConn) Commit() error             { return nil }
+func (c *fakeConn) Rollback() error           { return nil }
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return strings.Count(s.query, "?") }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	switch s.query {
+	case createTableQuery:
+		s.db.table = true
+	case insertQuery:
+		if !s.db.table {
+			return nil, errors.New("no such table")
+		}
+
+		s.db.records[string(args[0].([]byte))] = bytes.Clone(args[1].([]byte))
+	default:
+		return nil, errors.New("unexpected query")
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if s.query != selectQuery || !s.db.table {
+		return nil, errors.New("unexpected query")
+	}
+
+	var keys []string
+
+	for key := range s.db.records {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	return &fakeRows{keys: keys, db: s.db}, nil
+}
+
+func (r *fakeRows) Columns() []string { return []string{"key", "value"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.keys) == 0 {
+		return io.EOF
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	dest[0] = []byte(r.keys[0])
+	dest[1] = r.db.records[r.keys[0]]
+	r.keys = r.keys[1:]
+
+	return nil
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	conn, err := sql.Open("arcsqlite-fake", t.Name())
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer conn.Close()
+
+	src := arc.New()
+	records := map[string][]byte{
+		"apple":  []byte("red"),
+		"apply":  nil,
+		"banana": bytes.Repeat([]byte("y"), 100),
+	}
+
+	for key, value := range records {
+		src.Put([]byte(key), value)
+	}
+
+	if err := Export(ctx, src, conn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dst := arc.New()
+
+	if err := Import(ctx, dst, conn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if dst.Len() != len(records) {
+		t.Errorf("unexpected length: got:%d, want:%d", dst.Len(), len(records))
+	}
+
+	for key, want := range records {
+		got, err := dst.Get([]byte(key))
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !bytes.Equal(got, want) {
+			t.Errorf("unexpected value for %q: got:%q, want:%q", key, got, want)
+		}
+	}
+}
+
+func TestImportMissingTable(t *testing.T) {
+	conn, err := sql.Open("arcsqlite-fake", t.Name())
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer conn.Close()
+
+	if err := Import(context.Background(), arc.New(), conn); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+// Package arctest helps applications that embed Arc test their error
+// handling. NewFlaky wraps an arc.Store, such as an in-memory database, and
+// injects the errors and latency of a FaultPolicy into its calls:
+//
+//	db := arctest.NewFlaky(arc.New(), arctest.FaultPolicy{
+//		Get: arctest.Fault{Err: arc.ErrCorrupted, Nth: 3},
+//		Put: arctest.Fault{Latency: 100 * time.Millisecond},
+//	})
+//
+//	service := NewService(db) // Takes an arc.Store.
+package arctest
+
+import (
+	"iter"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/chronohq/arc"
+)
+
+// Fault is a fault that is injected into the calls of an operation. The zero
+// value injects nothing.
+type Fault struct {
+	// Err is returned by the matching calls instead of calling the
+	// wrapped Store. Nil calls the wrapped Store, which only adds latency.
+	Err error
+
+	// Nth selects the Nth call, counting from 1, as the only matching
+	// call. Zero selects every call, subject to Rate.
+	Nth int
+
+	// Rate is the probability of a call being selected, between 0 and 1.
+	// Zero selects every call. It is ignored if Nth is set.
+	Rate float64
+
+	// Latency delays the matching calls.
+	Latency time.Duration
+}
+
+// FaultPolicy configures the faults of a Flaky store by operation. Len is
+// never faulted.
+type FaultPolicy struct {
+	Get    Fault
+	Put    Fault
+	Delete Fault
+
+	// Scan faults delay the iteration. Scan cannot return an error,
+	// therefore a fault with an Err ends the iteration before the first
+	// record, as if the store was empty.
+	Scan Fault
+
+	// Seed seeds the selection of the calls by Rate, which makes the faults
+	// reproducible.
+	Seed uint64
+}
+
+// Counts holds a number of calls by operation.
+type Counts struct {
+	Get    int
+	Put    int
+	Delete int
+	Scan   int
+}
+
+// op identifies a faulted operation.
+type op int
+
+const (
+	opGet op = iota
+	opPut
+	opDelete
+	opScan
+)
+
+// add increments the count of the operation.
+func (c *Counts) add(op op) {
+	switch op {
+	case opGet:
+		c.Get++
+	case opPut:
+		c.Put++
+	case opDelete:
+		c.Delete++
+	case opScan:
+		c.Scan++
+	}
+}
+
+// get returns the count of the operation.
+func (c *Counts) get(op op) int {
+	switch op {
+	case opGet:
+		return c.Get
+	case opPut:
+		return c.Put
+	case opDelete:
+		return c.Delete
+	default:
+		return c.Scan
+	}
+}
+
+// Flaky is an arc.Store that injects faults into the calls of the Store that
+// it wraps. It is safe for concurrent use if the wrapped Store is.
+type Flaky struct {
+	store arc.Store
+
+	// Guards the fields below.
+	mu     sync.Mutex
+	policy FaultPolicy
+	rand   *rand.Rand
+	calls  Counts
+	faults Counts
+}
+
+// NewFlaky returns a Flaky store that wraps store, and injects the faults of
+// the policy.
+func NewFlaky(store arc.Store, policy FaultPolicy) *Flaky {
+	ret := &Flaky{store: store}
+	ret.SetPolicy(policy)
+
+	return ret
+}
+
+// SetPolicy replaces the fault policy, such as to let the store recover, and
+// resets the call counts.
+func (f *Flaky) SetPolicy(policy FaultPolicy) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.policy = policy
+	f.rand = rand.New(rand.NewPCG(policy.Seed, policy.Seed))
+	f.calls = Counts{}
+	f.faults = Counts{}
+}
+
+// Calls returns the number of calls by operation since the policy was set.
+func (f *Flaky) Calls() Counts {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls
+}
+
+// Faults returns the number of faulted calls by operation since the policy
+// was set.
+func (f *Flaky) Faults() Counts {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.faults
+}
+
+// Get implements arc.Store.
+func (f *Flaky) Get(key []byte) ([]byte, error) {
+	if err := f.inject(opGet); err != nil {
+		return nil, err
+	}
+
+	return f.store.Get(key)
+}
+
+// Put implements arc.Store.
+func (f *Flaky) Put(key []byte, value []byte) error {
+	if err := f.inject(opPut); err != nil {
+		return err
+	}
+
+	return f.store.Put(key, value)
+}
+
+// Delete implements arc.Store.
+func (f *Flaky) Delete(key []byte) error {
+	if err := f.inject(opDelete); err != nil {
+		return err
+	}
+
+	return f.store.Delete(key)
+}
+
+// Scan implements arc.Store. The fault is injected when the iteration begins.
+func (f *Flaky) Scan(prefix []byte) iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		if err := f.inject(opScan); err != nil {
+			return
+		}
+
+		for key, value := range f.store.Scan(prefix) {
+			if !yield(key, value) {
+				return
+			}
+		}
+	}
+}
+
+// Len implements arc.Store.
+func (f *Flaky) Len() int {
+	return f.store.Len()
+}
+
+// inject counts a call of the operation, and applies the fault if the call
+// matches. It returns the error of the fault, if any.
+func (f *Flaky) inject(op op) error {
+	f.mu.Lock()
+
+	fault := f.fault(op)
+	f.calls.add(op)
+
+	var match bool
+
+	switch {
+	case fault.Nth > 0:
+		match = f.calls.get(op) == fault.Nth
+	case fault.Rate > 0:
+		match = f.rand.Float64() < fault.Rate
+	default:
+		match = true
+	}
+
+	if match && (fault.Err != nil || fault.Latency > 0) {
+		f.faults.add(op)
+	} else {
+		match = false
+	}
+
+	f.mu.Unlock()
+
+	if !match {
+		return nil
+	}
+
+	// The latency is waited for outside the lock, which lets concurrent
+	// calls be delayed together.
+	time.Sleep(fault.Latency)
+
+	return fault.Err
+}
+
+// fault returns the fault of the operation. The caller must hold the lock.
+func (f *Flaky) fault(op op) Fault {
+	switch op {
+	case opGet:
+		return f.policy.Get
+	case opPut:
+		return f.policy.Put
+	case opDelete:
+		return f.policy.Delete
+	default:
+		return f.policy.Scan
+	}
+}
+
+var _ arc.Store = (*Flaky)(nil)
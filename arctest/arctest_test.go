@@ -0,0 +1,118 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arctest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chronohq/arc"
+)
+
+func TestFlakyNth(t *testing.T) {
+	db := arc.New()
+	db.Put([]byte("apple"), []byte("red"))
+
+	f := NewFlaky(db, FaultPolicy{Get: Fault{Err: arc.ErrCorrupted, Nth: 2}})
+
+	for i := 1; i <= 3; i++ {
+		_, err := f.Get([]byte("apple"))
+
+		if want := i == 2; errors.Is(err, arc.ErrCorrupted) != want {
+			t.Errorf("unexpected error of call %d: %v", i, err)
+		}
+	}
+
+	if calls, faults := f.Calls(), f.Faults(); calls.Get != 3 || faults.Get != 1 {
+		t.Errorf("unexpected counts: calls:%+v, faults:%+v", calls, faults)
+	}
+
+	// Faulted writes do not reach the store.
+	f.SetPolicy(FaultPolicy{Put: Fault{Err: arc.ErrReadOnly}, Delete: Fault{Err: arc.ErrReadOnly}})
+
+	if err := f.Put([]byte("banana"), nil); !errors.Is(err, arc.ErrReadOnly) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := f.Delete([]byte("apple")); !errors.Is(err, arc.ErrReadOnly) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if f.Len() != 1 || f.Calls().Get != 0 {
+		t.Errorf("unexpected state: len:%d, calls:%+v", f.Len(), f.Calls())
+	}
+}
+
+func TestFlakyRate(t *testing.T) {
+	policy := FaultPolicy{Put: Fault{Err: arc.ErrBusy, Rate: 0.5}, Seed: 42}
+
+	run := func() []bool {
+		f := NewFlaky(arc.New(), policy)
+		ret := make([]bool, 100)
+
+		for i := range ret {
+			ret[i] = f.Put([]byte("key"), nil) != nil
+		}
+
+		return ret
+	}
+
+	first := run()
+	second := run()
+	var failed int
+
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("expected the faults to be reproducible")
+		}
+
+		if first[i] {
+			failed++
+		}
+	}
+
+	if failed < 25 || failed > 75 {
+		t.Errorf("unexpected faults: %d", failed)
+	}
+}
+
+func TestFlakyLatency(t *testing.T) {
+	f := NewFlaky(arc.New(), FaultPolicy{Put: Fault{Latency: 20 * time.Millisecond}})
+	start := time.Now()
+
+	if err := f.Put([]byte("apple"), []byte("red")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("unexpected latency: %v", elapsed)
+	}
+
+	if got, err := f.Get([]byte("apple")); err != nil || string(got) != "red" {
+		t.Errorf("unexpected value: got:%q, err:%v", got, err)
+	}
+}
+
+func TestFlakyScan(t *testing.T) {
+	db := arc.New()
+	db.Put([]byte("apple"), nil)
+	db.Put([]byte("banana"), nil)
+
+	f := NewFlaky(db, FaultPolicy{Scan: Fault{Err: arc.ErrCorrupted, Nth: 1}})
+
+	for range f.Scan(nil) {
+		t.Errorf("expected the faulted scan to yield nothing")
+	}
+
+	var n int
+
+	for range f.Scan(nil) {
+		n++
+	}
+
+	if n != 2 || f.Calls().Scan != 2 || f.Faults().Scan != 1 {
+		t.Errorf("unexpected scan: records:%d, calls:%+v", n, f.Calls())
+	}
+}
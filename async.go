@@ -0,0 +1,74 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+// asyncQueueLen is the number of writes that PutAsync queues before it blocks.
+const asyncQueueLen = 1024
+
+// asyncPut is a write queued by PutAsync.
+type asyncPut struct {
+	key   []byte
+	value []byte
+	done  func(error)
+}
+
+// asyncWriter applies the writes queued by PutAsync on its own goroutine, in
+// the order that they were queued.
+type asyncWriter struct {
+	queue chan asyncPut
+	done  chan struct{} // Closed once the goroutine has exited.
+}
+
+// PutAsync queues a Put of the given key and value, and returns without
+// waiting for it. The writes are applied in the order that they were queued
+// by a single goroutine, therefore the writes to the same key take effect in
+// order. Once the write has been applied, and persisted if the SyncPolicy
+// requires it, done is called with the error that Put would have returned.
+// The done function may be nil. It runs on the goroutine that applies the
+// writes, therefore it delays the writes that follow, and must not call
+// PutAsync. The key and value must not be modified until done is called.
+// PutAsync blocks while 1024 writes are queued. Close applies the queued
+// writes before it returns.
+func (a *Arc) PutAsync(key []byte, value []byte, done func(error)) {
+	a.asyncMu.Lock()
+	defer a.asyncMu.Unlock()
+
+	if a.async == nil {
+		a.async = &asyncWriter{queue: make(chan asyncPut, asyncQueueLen), done: make(chan struct{})}
+
+		go a.async.run(a)
+	}
+
+	a.async.queue <- asyncPut{key: key, value: value, done: done}
+}
+
+// run applies the queued writes until the queue is closed.
+func (w *asyncWriter) run(a *Arc) {
+	defer close(w.done)
+
+	for p := range w.queue {
+		err := a.Put(p.key, p.value)
+
+		if p.done != nil {
+			p.done(err)
+		}
+	}
+}
+
+// stopAsync applies the writes that PutAsync has queued, and stops the
+// goroutine that applies them. A later PutAsync starts another goroutine. It
+// is safe to call more than once.
+func (a *Arc) stopAsync() {
+	a.asyncMu.Lock()
+	w := a.async
+	a.async = nil
+	a.asyncMu.Unlock()
+
+	if w == nil {
+		return
+	}
+
+	close(w.queue)
+	<-w.done
+}
@@ -0,0 +1,99 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestPutAsync(t *testing.T) {
+	arc := New()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var applied []int
+
+	// The writes to the same key take effect in order.
+	for i := range 100 {
+		wg.Add(1)
+
+		arc.PutAsync([]byte("key"), []byte(fmt.Sprint(i)), func(err error) {
+			defer wg.Done()
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			mu.Lock()
+			applied = append(applied, i)
+			mu.Unlock()
+		})
+	}
+
+	wg.Wait()
+
+	for i, n := range applied {
+		if i != n {
+			t.Fatalf("unexpected order: %v", applied)
+		}
+	}
+
+	if value, err := arc.Get([]byte("key")); err != nil || string(value) != "99" {
+		t.Errorf("unexpected value: got:%q, err:%v", value, err)
+	}
+
+	// The callback receives the error of the write.
+	errs := make(chan error, 1)
+	arc.PutAsync(nil, []byte("value"), func(err error) { errs <- err })
+
+	if err := <-errs; !errors.Is(err, ErrNilKey) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrNilKey)
+	}
+
+	// A nil callback is allowed.
+	arc.PutAsync([]byte("quiet"), []byte("value"), nil)
+	arc.Close()
+
+	if _, err := arc.Get([]byte("quiet")); err != nil {
+		t.Errorf("expected Close to apply the queued write: %v", err)
+	}
+
+	// The database remains usable after Close.
+	arc.PutAsync([]byte("again"), []byte("value"), func(err error) { errs <- err })
+
+	if err := <-errs; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	arc.Close()
+}
+
+func TestPutAsyncPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := Open(path)
+
+	for i := range 10 {
+		arc.PutAsync([]byte(fmt.Sprintf("key%d", i)), []byte("value"), nil)
+	}
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := Open(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer reopened.Close()
+
+	if reopened.Len() != 10 {
+		t.Errorf("unexpected length: got:%d, want:10", reopened.Len())
+	}
+}
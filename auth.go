@@ -0,0 +1,47 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import "errors"
+
+// ErrPermission is returned when the Authorizer denies an operation, or when
+// the primary rejects the ReplicationToken of a replica.
+var ErrPermission = errors.New("permission denied")
+
+// Authorizer decides which operations are permitted on which keys, which
+// allows permissions to be enforced centrally, such as per key prefix, rather
+// than at every call site. It is consulted by Get, Put, Add, Delete, Expire,
+// SetFlags, Stat, the navigation methods, and transactions, which fail with
+// ErrPermission when it denies the operation. Rename and MovePrefix must be
+// permitted on both the source and the destination. Iterators and cursors skip the
+// records that it denies OpGet for.
+type Authorizer interface {
+	// Authorize reports whether op is permitted on the key. It may be
+	// called while the database lock is held, therefore it must not call
+	// methods of the database. It must neither modify nor retain the key.
+	Authorize(op Op, key []byte) bool
+}
+
+// AuthorizerFunc adapts a function to the Authorizer interface.
+type AuthorizerFunc func(op Op, key []byte) bool
+
+// Authorize calls f(op, key).
+func (f AuthorizerFunc) Authorize(op Op, key []byte) bool {
+	return f(op, key)
+}
+
+// authorize returns ErrPermission if the Authorizer denies op on the key.
+func (a *Arc) authorize(op Op, key []byte) error {
+	if a.opts.Authorizer != nil && !a.opts.Authorizer.Authorize(op, key) {
+		return ErrPermission
+	}
+
+	return nil
+}
+
+// readable reports whether the Authorizer permits reading the record that is
+// stored under the given key. The caller must hold the read lock.
+func (a *Arc) readable(key []byte) bool {
+	return a.opts.Authorizer == nil || a.opts.Authorizer.Authorize(OpGet, a.originalKey(key))
+}
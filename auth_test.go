@@ -0,0 +1,83 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestAuthorizer(t *testing.T) {
+	// Everything under "public/" is readable, and only "public/shared/" is
+	// writable.
+	auth := AuthorizerFunc(func(op Op, key []byte) bool {
+		if op == OpGet {
+			return bytes.HasPrefix(key, []byte("public/"))
+		}
+
+		return bytes.HasPrefix(key, []byte("public/shared/"))
+	})
+
+	arc, _ := NewWithOptions(Options{Authorizer: auth})
+
+	// Seed the database by bypassing the authorizer.
+	arc.mu.Lock()
+	arc.putRecord([]byte("private/key"), []byte("secret"), true)
+	arc.putRecord([]byte("public/key"), []byte("value"), true)
+	arc.mu.Unlock()
+
+	if _, err := arc.Get([]byte("private/key")); !errors.Is(err, ErrPermission) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrPermission)
+	}
+
+	if _, err := arc.Stat([]byte("private/key")); err != ErrPermission {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrPermission)
+	}
+
+	if _, _, err := arc.Min(); err != ErrPermission {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrPermission)
+	}
+
+	if got, _ := arc.Get([]byte("public/key")); string(got) != "value" {
+		t.Errorf("unexpected value: got:%q, want:%q", got, "value")
+	}
+
+	if err := arc.Put([]byte("public/key"), nil); !errors.Is(err, ErrPermission) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrPermission)
+	}
+
+	if err := arc.Put([]byte("public/shared/key"), nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Iterators skip the records that cannot be read.
+	want := []string{"public/key", "public/shared/key"}
+
+	var got []string
+
+	for key := range arc.Keys(nil) {
+		got = append(got, string(key))
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("unexpected keys: got:%q, want:%q", got, want)
+	}
+
+	if c := arc.Cursor(nil); !c.Next() || string(c.Key()) != "public/key" {
+		t.Errorf("expected the cursor to skip the private record")
+	}
+
+	txn := arc.Begin()
+	defer txn.Rollback()
+
+	if _, err := txn.Get([]byte("private/key")); err != ErrPermission {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrPermission)
+	}
+
+	if err := txn.Delete([]byte("public/key")); err != ErrPermission {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrPermission)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrBusy is returned when a write is rejected because the persistence of the
+// previous writes has fallen behind. See BackpressurePolicy.
+var ErrBusy = errors.New("database is busy")
+
+// BackpressurePolicy throttles the writes to a file-backed database once too
+// many of them have not been persisted yet, which happens when the writes
+// outpace the SyncInterval policy or the background compaction. Throttled
+// writes either fail with ErrBusy or wait for the next save. The zero value
+// disables backpressure.
+type BackpressurePolicy struct {
+	// MaxUnsavedWrites is the number of unsaved writes from which new
+	// writes are throttled. Zero disables backpressure.
+	MaxUnsavedWrites int
+
+	// Block makes throttled writes wait until a save brings the unsaved
+	// writes back under the threshold, rather than failing with ErrBusy.
+	Block bool
+
+	// Timeout is how long a blocked write waits before it fails with
+	// ErrBusy. Zero waits indefinitely.
+	Timeout time.Duration
+
+	// OnThrottle is called with true when the database starts throttling
+	// writes, and with false when it stops, which allows applications to
+	// surface the state. It is called without holding the database lock.
+	OnThrottle func(throttled bool)
+}
+
+// Throttled reports whether the writes are currently throttled by the
+// BackpressurePolicy.
+func (a *Arc) Throttled() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.throttled
+}
+
+// throttle applies the BackpressurePolicy before a write. It returns ErrBusy
+// if the write must be rejected. The caller must not hold the lock, since
+// blocked writes wait for a save.
+func (a *Arc) throttle() error {
+	policy := a.opts.Backpressure
+
+	if policy.MaxUnsavedWrites == 0 || a.path == "" {
+		return nil
+	}
+
+	var deadline <-chan time.Time
+
+	for {
+		a.mu.Lock()
+
+		if a.seq-a.savedSeq < uint64(policy.MaxUnsavedWrites) {
+			a.mu.Unlock()
+			return nil
+		}
+
+		started := !a.throttled
+		a.throttled = true
+
+		if a.saved == nil {
+			a.saved = make(chan struct{})
+		}
+
+		saved := a.saved
+		a.mu.Unlock()
+
+		if started && policy.OnThrottle != nil {
+			policy.OnThrottle(true)
+		}
+
+		if !policy.Block {
+			return ErrBusy
+		}
+
+		if deadline == nil && policy.Timeout > 0 {
+			timer := time.NewTimer(policy.Timeout)
+			defer timer.Stop()
+
+			deadline = timer.C
+		}
+
+		select {
+		case <-saved:
+		case <-deadline:
+			return ErrBusy
+		}
+	}
+}
+
+// noteSaved wakes the writes that wait for a save, and reports whether the
+// save ended the throttling. The caller must hold the write lock.
+func (a *Arc) noteSaved() bool {
+	if a.saved != nil {
+		close(a.saved)
+		a.saved = nil
+	}
+
+	if !a.throttled || a.seq-a.savedSeq >= uint64(a.opts.Backpressure.MaxUnsavedWrites) {
+		return false
+	}
+
+	a.throttled = false
+
+	return true
+}
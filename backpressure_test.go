@@ -0,0 +1,113 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackpressure(t *testing.T) {
+	var mu sync.Mutex
+	var states []bool
+
+	opts := Options{Backpressure: BackpressurePolicy{
+		MaxUnsavedWrites: 2,
+		OnThrottle: func(throttled bool) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			states = append(states, throttled)
+		},
+	}}
+
+	arc, _ := OpenWithOptions(filepath.Join(t.TempDir(), "test.arc"), opts)
+	defer arc.Close()
+
+	arc.Put([]byte("a"), nil)
+	arc.Put([]byte("b"), nil)
+
+	if err := arc.Put([]byte("c"), nil); !errors.Is(err, ErrBusy) {
+		t.Fatalf("unexpected error: got:%v, want:%v", err, ErrBusy)
+	}
+
+	if err := arc.Begin().Commit(); err != ErrBusy {
+		t.Fatalf("unexpected error: got:%v, want:%v", err, ErrBusy)
+	}
+
+	if !arc.Throttled() {
+		t.Error("expected the writes to be throttled")
+	}
+
+	// Reads are never throttled.
+	if _, err := arc.Get([]byte("a")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := arc.Sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := arc.Put([]byte("c"), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := []bool{true, false}; !slices.Equal(states, want) {
+		t.Errorf("unexpected states: got:%v, want:%v", states, want)
+	}
+}
+
+func TestBackpressureBlock(t *testing.T) {
+	opts := Options{Backpressure: BackpressurePolicy{MaxUnsavedWrites: 1, Block: true}}
+	arc, _ := OpenWithOptions(filepath.Join(t.TempDir(), "test.arc"), opts)
+	defer arc.Close()
+
+	arc.Put([]byte("a"), nil)
+
+	done := make(chan error)
+
+	go func() {
+		done <- arc.Put([]byte("b"), nil)
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("expected the write to block, got:%v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if err := arc.Sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		opts.Backpressure.Timeout = 10 * time.Millisecond
+		arc, _ := OpenWithOptions(filepath.Join(t.TempDir(), "test.arc"), opts)
+		defer arc.Close()
+
+		arc.Put([]byte("a"), nil)
+
+		if err := arc.Put([]byte("b"), nil); !errors.Is(err, ErrBusy) {
+			t.Errorf("unexpected error: got:%v, want:%v", err, ErrBusy)
+		}
+	})
+
+	t.Run("in-memory", func(t *testing.T) {
+		arc, _ := NewWithOptions(opts)
+
+		for i := range 3 {
+			if err := arc.Put([]byte{byte(i)}, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+	})
+}
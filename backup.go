@@ -0,0 +1,148 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// ObjectStore is a minimal interface to an object storage service, such as
+// Amazon S3 or a compatible service, which is used to store backups off-site.
+// The arcs3 package provides an implementation for S3-compatible services.
+type ObjectStore interface {
+	// PutObject stores the body as the object with the given key, replacing
+	// any existing object.
+	PutObject(ctx context.Context, bucket string, key string, body []byte) error
+
+	// GetObject returns the contents of the object with the given key. The
+	// caller must close the returned reader.
+	GetObject(ctx context.Context, bucket string, key string) (io.ReadCloser, error)
+}
+
+// BackupToObjectStore uploads a snapshot of the database to the object store,
+// in the file format. The snapshot is serialized under the read lock, but the
+// upload does not hold it, therefore writes are only blocked while the
+// snapshot is taken.
+func (a *Arc) BackupToObjectStore(ctx context.Context, store ObjectStore, bucket string, key string) error {
+	src, err := a.backup()
+
+	if err != nil {
+		return err
+	}
+
+	return store.PutObject(ctx, bucket, key, src)
+}
+
+// Backup writes a snapshot of the database to w, in the file format. Like
+// BackupToObjectStore, writes are only blocked while the snapshot is taken,
+// and not while it is written to w.
+func (a *Arc) Backup(w io.Writer) error {
+	src, err := a.backup()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(src)
+
+	return err
+}
+
+// backup returns a snapshot of the database in the file format.
+func (a *Arc) backup() ([]byte, error) {
+	var buf bytes.Buffer
+
+	a.rlock()
+	err := a.writeSnapshot(&buf)
+	a.runlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ReadBackup returns an in-memory database that holds the records of a backup
+// written by Backup, or of a database file. Returns ErrCorrupted if the backup
+// is corrupted, and the errors of OpenWithOptions if it does not match the
+// options.
+func ReadBackup(r io.Reader, opts Options) (*Arc, error) {
+	src, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := newArc(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ret.readSnapshot(src); err != nil {
+		return nil, err
+	}
+
+	ret.startSweeper()
+	ret.startTiering()
+
+	return ret, nil
+}
+
+// RestoreFromObjectStore downloads a backup uploaded by BackupToObjectStore,
+// writes it to the database file at the given path, and opens the file like
+// OpenWithOptions. The backup is verified before the file is replaced, which
+// leaves an existing file intact if the backup is corrupted or does not match
+// the options. Returns ErrDatabaseLocked if the file remains open in another
+// process for longer than the LockTimeout.
+func RestoreFromObjectStore(ctx context.Context, store ObjectStore, bucket string, key string, path string, opts Options) (*Arc, error) {
+	r, err := store.GetObject(ctx, bucket, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := io.ReadAll(r)
+	r.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := newArc(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ret.lockFile, err = acquireFileLock(path, true, opts.LockTimeout); err != nil {
+		return nil, err
+	}
+
+	if err := ret.readSnapshot(src); err != nil {
+		ret.releaseFileLock()
+		return nil, err
+	}
+
+	ret.path = path
+
+	if ret.manifest, err = ret.writeManifest(src, ret.manifestRootHash()); err != nil {
+		ret.releaseFileLock()
+		return nil, err
+	}
+
+	if err := writeFileAtomic(path, src); err != nil {
+		ret.releaseFileLock()
+		return nil, err
+	}
+	ret.startCompaction()
+	ret.startSyncer()
+	ret.startSweeper()
+	ret.startTiering()
+
+	return ret, nil
+}
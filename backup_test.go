@@ -0,0 +1,132 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// memoryObjectStore is an ObjectStore that keeps the objects in memory.
+type memoryObjectStore struct {
+	objects map[string][]byte
+}
+
+func (s *memoryObjectStore) PutObject(_ context.Context, bucket string, key string, body []byte) error {
+	s.objects[bucket+"/"+key] = bytes.Clone(body)
+	return nil
+}
+
+func (s *memoryObjectStore) GetObject(_ context.Context, bucket string, key string) (io.ReadCloser, error) {
+	body, ok := s.objects[bucket+"/"+key]
+
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+
+	return io.NopCloser(bytes.NewReader(body)), nil
+}
+
+func TestBackupToObjectStore(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryObjectStore{objects: map[string][]byte{}}
+	src := New()
+
+	src.Put([]byte("apple"), []byte("red"))
+	src.Put([]byte("banana"), bytes.Repeat([]byte("y"), 100))
+
+	if err := src.BackupToObjectStore(ctx, store, "backups", "db"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "restored.db")
+	dst, err := RestoreFromObjectStore(ctx, store, "backups", "db", path, Options{})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !bytes.Equal(dst.RootHash(), src.RootHash()) {
+		t.Error("unexpected root hash")
+	}
+
+	if err := dst.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := Open(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer reopened.Close()
+
+	if got, _ := reopened.Get([]byte("apple")); string(got) != "red" {
+		t.Errorf("unexpected value: got:%q, want:%q", got, "red")
+	}
+}
+
+func TestRestoreFromObjectStoreInvalid(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryObjectStore{objects: map[string][]byte{"backups/corrupt": []byte("corrupt")}}
+	path := filepath.Join(t.TempDir(), "restored.db")
+
+	if _, err := RestoreFromObjectStore(ctx, store, "backups", "missing", path, Options{}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, fs.ErrNotExist)
+	}
+
+	if _, err := RestoreFromObjectStore(ctx, store, "backups", "corrupt", path, Options{}); err == nil {
+		t.Error("expected an error")
+	}
+
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unexpected file: got:%v, want:%v", err, fs.ErrNotExist)
+	}
+}
+
+func TestBackup(t *testing.T) {
+	src := New()
+
+	src.Put([]byte("apple"), []byte("red"))
+	src.Put([]byte("banana"), blobValueX())
+	src.SetMeta("app", []byte("fruits"))
+
+	var buf bytes.Buffer
+
+	if err := src.Backup(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dst, err := ReadBackup(&buf, Options{})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer dst.Close()
+
+	if !bytes.Equal(dst.RootHash(), src.RootHash()) {
+		t.Error("unexpected root hash")
+	}
+
+	if got, _ := dst.GetMeta("app"); string(got) != "fruits" {
+		t.Errorf("unexpected metadata: %q", got)
+	}
+
+	// The restored database is writable and independent of the source.
+	if err := dst.Put([]byte("cherry"), nil); err != nil || src.Len() != 2 {
+		t.Errorf("unexpected write: len:%d, err:%v", src.Len(), err)
+	}
+
+	if _, err := ReadBackup(bytes.NewReader([]byte("corrupt")), Options{}); err == nil {
+		t.Error("expected an error")
+	}
+}
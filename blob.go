@@ -3,7 +3,10 @@
 
 package arc
 
-import "crypto/sha256"
+import (
+	"crypto/sha256"
+	"sync/atomic"
+)
 
 const (
 	// Length of the blobID in bytes.
@@ -42,6 +45,33 @@ func makeBlobID(src []byte) blobID {
 type blob struct {
 	value    []byte
 	refCount int
+
+	// Location of the value in the tier file if the blob was demoted by
+	// the TieringPolicy, in which case value is nil.
+	cold *coldBlob
+
+	// Time of the last read of the blob in Unix nanoseconds, which the
+	// TieringPolicy tracks. Zero if the blob has not been swept yet.
+	lastRead atomic.Int64
+}
+
+// load returns the value of the blob, which is read from the tier file if the
+// blob was demoted.
+func (b *blob) load() ([]byte, error) {
+	if b.cold != nil {
+		return b.cold.load()
+	}
+
+	return b.value, nil
+}
+
+// size returns the length of the value of the blob.
+func (b *blob) size() int {
+	if b.cold != nil {
+		return b.cold.size
+	}
+
+	return len(b.value)
 }
 
 // blobStore maps blobIDs to their corresponding blobs. It is used to store
@@ -62,26 +92,78 @@ func (bs blobStore) get(id []byte) []byte {
 		return nil
 	}
 
+	value, err := b.load()
+
+	if err != nil {
+		return nil
+	}
+
 	// Create a copy of the value since returning a pointer to the underlying
 	// value can have serious implications, such as breaking data integrity.
-	ret := make([]byte, len(b.value))
-	copy(ret, b.value)
+	ret := make([]byte, len(value))
+	copy(ret, value)
 
 	return ret
 }
 
-// put either creates a new blob and inserts it to the blobStore or increments
-// the refCount of an existing blob. It returns a blobID on success.
-func (bs blobStore) put(value []byte) blobID {
-	k := makeBlobID(value)
+// size returns the length of the blob that matches the blobID. It returns zero
+// if the blob does not exist.
+func (bs blobStore) size(id []byte) int {
+	blobID, err := sliceToBlobID(id)
+
+	if err != nil {
+		return 0
+	}
+
+	if b, found := bs[blobID]; found {
+		return b.size()
+	}
+
+	return 0
+}
 
-	if b, found := bs[k]; found {
+// put either creates a new blob with the given blobID and inserts it to the
+// blobStore or increments the refCount of an existing blob.
+func (bs blobStore) put(id blobID, value []byte) {
+	if b, found := bs[id]; found {
 		b.refCount++
 	} else {
-		bs[k] = &blob{value: value, refCount: 1}
+		bs[id] = &blob{value: value, refCount: 1}
+	}
+}
+
+// retain increments the refCount of the blob that matches the blobID, if it
+// exists, which keeps the blob alive for an additional reference.
+func (bs blobStore) retain(id []byte) {
+	blobID, err := sliceToBlobID(id)
+
+	if err != nil {
+		return
 	}
 
-	return k
+	if b, found := bs[blobID]; found {
+		b.refCount++
+	}
+}
+
+// adopt adds a reference to the blob that matches the blobID, and copies the
+// blob from src unless the receiver already holds it. The value itself is
+// shared with src, since blob values are never modified, and so is the
+// location of a demoted value.
+func (bs blobStore) adopt(src blobStore, id []byte) {
+	blobID, err := sliceToBlobID(id)
+
+	if err != nil {
+		return
+	}
+
+	if b, found := bs[blobID]; found {
+		b.refCount++
+	} else if b, found := src[blobID]; found {
+		adopted := &blob{value: b.value, refCount: 1, cold: b.cold}
+		adopted.lastRead.Store(b.lastRead.Load())
+		bs[blobID] = adopted
+	}
 }
 
 // release decrements the refCount of a blob if it exists for the given blobID.
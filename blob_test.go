@@ -5,6 +5,7 @@ package arc
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 )
 
@@ -22,11 +23,8 @@ func TestBlobStorePut(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		blobID := store.put(test.value)
-
-		if !bytes.Equal(blobID.Slice(), test.expectedBlobID.Slice()) {
-			t.Errorf("unexpected blobID: got:%q, want:%q", blobID, test.expectedBlobID)
-		}
+		blobID := test.expectedBlobID
+		store.put(blobID, test.value)
 
 		value := store.get(blobID[:])
 
@@ -126,7 +124,8 @@ func TestBlobStoreRelease(t *testing.T) {
 	var blobID blobID
 
 	for i := 0; i < refCount; i++ {
-		blobID = store.put(value)
+		blobID = makeBlobID(value)
+		store.put(blobID, value)
 	}
 
 	for i := refCount; i > 0; i-- {
@@ -152,3 +151,98 @@ func TestBlobStoreRelease(t *testing.T) {
 		t.Error("store should be empty")
 	}
 }
+
+func TestBlobStoreRetain(t *testing.T) {
+	store := blobStore{}
+	blobID := makeBlobID([]byte("pineapple"))
+	store.put(blobID, []byte("pineapple"))
+
+	store.retain(blobID.Slice())
+	store.release(blobID.Slice())
+
+	if b, found := store[blobID]; !found || b.refCount != 1 {
+		t.Fatalf("expected the retained reference to keep the blob")
+	}
+
+	// Test that the store does not panic with an unknown key.
+	store.retain([]byte("bogus"))
+
+	if len(store) != 1 {
+		t.Errorf("unexpected number of blobs: got:%d, want:%d", len(store), 1)
+	}
+}
+
+func TestBlobStoreAdopt(t *testing.T) {
+	src := blobStore{}
+	blobID := makeBlobID([]byte("pineapple"))
+	src.put(blobID, []byte("pineapple"))
+	store := blobStore{}
+
+	store.adopt(src, blobID.Slice())
+	store.adopt(src, blobID.Slice())
+
+	if b, found := store[blobID]; !found || b.refCount != 2 {
+		t.Fatalf("expected the store to hold both references")
+	}
+
+	if src[blobID].refCount != 1 {
+		t.Errorf("expected the source store to be left intact")
+	}
+
+	// Test that the store does not panic with an unknown key.
+	store.adopt(src, []byte("bogus"))
+
+	if len(store) != 1 {
+		t.Errorf("unexpected number of blobs: got:%d, want:%d", len(store), 1)
+	}
+}
+
+func TestBlobVerification(t *testing.T) {
+	key := []byte("large")
+	value := blobValueX()
+
+	testCases := []struct {
+		name    string
+		opts    Options
+		corrupt func(bs blobStore)
+		want    error
+	}{
+		{
+			name:    "with intact blob",
+			corrupt: func(bs blobStore) {},
+			want:    nil,
+		},
+		{
+			name:    "with modified blob",
+			corrupt: func(bs blobStore) { bs[makeBlobID(value)].value = bytes.Repeat([]byte("y"), len(value)) },
+			want:    ErrCorrupted,
+		},
+		{
+			name:    "with missing blob",
+			corrupt: func(bs blobStore) { delete(bs, makeBlobID(value)) },
+			want:    ErrCorrupted,
+		},
+		{
+			name:    "with modified blob and verification disabled",
+			opts:    Options{SkipBlobVerification: true},
+			corrupt: func(bs blobStore) { bs[makeBlobID(value)].value = bytes.Repeat([]byte("y"), len(value)) },
+			want:    nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			arc, _ := NewWithOptions(tc.opts)
+
+			if err := arc.Put(key, value); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			tc.corrupt(arc.blobs)
+
+			if _, err := arc.Get(key); !errors.Is(err, tc.want) {
+				t.Errorf("unexpected error: got:%v, want:%v", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"log/slog"
+	"sort"
+)
+
+// BlobLeak describes a blob whose reference count disagrees with the number of
+// nodes that reference it.
+type BlobLeak struct {
+	ID         []byte // The blobID, which is the BlobHash of the value.
+	Size       int    // Size of the value in bytes, or 0 if the blob is missing.
+	RefCount   int    // Reference count recorded by the blob store.
+	References int    // Number of records, versions, and large keys referencing it.
+}
+
+// BlobGCReport lists the blobs whose reference counts are inconsistent with
+// the tree. A consistent blob store yields an empty report. Each list is
+// sorted by ID.
+type BlobGCReport struct {
+	// Orphaned lists the blobs that nothing references. They occupy memory
+	// and are written to the database file until they are reclaimed.
+	Orphaned []BlobLeak
+
+	// UnderCounted lists the referenced blobs whose reference count is too
+	// low. They are removed while still referenced once enough of the
+	// references are released, which makes the remaining records unreadable.
+	UnderCounted []BlobLeak
+
+	// OverCounted lists the referenced blobs whose reference count is too
+	// high. They outlive their last reference, and become orphans.
+	OverCounted []BlobLeak
+
+	// Missing lists the blobs that are referenced but absent from the blob
+	// store. The values of the referencing records are lost, and BlobGC
+	// cannot repair them.
+	Missing []BlobLeak
+
+	// ReclaimableBytes is the total size of the orphaned blobs.
+	ReclaimableBytes int64
+}
+
+// BlobGCDryRun cross-checks the reference counts of the blob store against
+// the records and the previous versions that reference the blobs, and reports
+// the inconsistencies without changing anything. Reference counting bugs
+// otherwise go unnoticed until the memory usage grows. The namespaces of a
+// Container share a blob store, therefore the references of every namespace
+// are counted.
+func (a *Arc) BlobGCDryRun() BlobGCReport {
+	a.rlock()
+	defer a.runlock()
+
+	return a.checkBlobs()
+}
+
+// BlobGC is like BlobGCDryRun, but also repairs the blob store. Orphaned blobs
+// are removed, and the reference counts of the under- and over-counted blobs
+// are corrected. Missing blobs are reported but left as they are. It returns
+// the report of the inconsistencies that were found before the repair. The
+// records are unchanged. Returns ErrReadOnly if the database is read-only.
+func (a *Arc) BlobGC() (BlobGCReport, error) {
+	if a.readOnly {
+		return BlobGCReport{}, ErrReadOnly
+	}
+
+	a.lock()
+	defer a.mu.Unlock()
+
+	ret := a.checkBlobs()
+
+	for _, leak := range ret.Orphaned {
+		delete(a.blobs, blobID(leak.ID))
+	}
+
+	for _, leaks := range [][]BlobLeak{ret.UnderCounted, ret.OverCounted} {
+		for _, leak := range leaks {
+			a.blobs[blobID(leak.ID)].refCount = leak.References
+		}
+	}
+
+	// The blob section of the database file changes, even though the
+	// records do not.
+	if len(ret.Orphaned) > 0 || len(ret.UnderCounted) > 0 || len(ret.OverCounted) > 0 {
+		a.metaSeq++
+	}
+
+	level := slog.LevelDebug
+
+	if len(ret.Orphaned)+len(ret.UnderCounted)+len(ret.OverCounted)+len(ret.Missing) > 0 {
+		level = slog.LevelWarn
+	}
+
+	a.log(level, "blob garbage collection finished",
+		"orphaned", len(ret.Orphaned),
+		"under_counted", len(ret.UnderCounted),
+		"over_counted", len(ret.OverCounted),
+		"missing", len(ret.Missing),
+		"reclaimed_bytes", ret.ReclaimableBytes,
+	)
+
+	return ret, nil
+}
+
+// checkBlobs compares the blob store with the blob references, and returns the
+// inconsistencies. The caller must hold the read lock.
+func (a *Arc) checkBlobs() BlobGCReport {
+	var ret BlobGCReport
+	var refs map[blobID]int
+
+	profile("blobgc", func() {
+		refs = a.countBlobReferences()
+	})
+
+	for id, b := range a.blobs {
+		leak := BlobLeak{ID: append([]byte{}, id[:]...), Size: b.size(), RefCount: b.refCount, References: refs[id]}
+
+		switch {
+		case leak.References == 0:
+			ret.Orphaned = append(ret.Orphaned, leak)
+			ret.ReclaimableBytes += int64(leak.Size)
+
+		case leak.RefCount < leak.References:
+			ret.UnderCounted = append(ret.UnderCounted, leak)
+
+		case leak.RefCount > leak.References:
+			ret.OverCounted = append(ret.OverCounted, leak)
+		}
+	}
+
+	for id, n := range refs {
+		if _, found := a.blobs[id]; !found {
+			ret.Missing = append(ret.Missing, BlobLeak{ID: append([]byte{}, id[:]...), References: n})
+		}
+	}
+
+	for _, leaks := range [][]BlobLeak{ret.Orphaned, ret.UnderCounted, ret.OverCounted, ret.Missing} {
+		sort.Slice(leaks, func(i, j int) bool {
+			return bytes.Compare(leaks[i].ID, leaks[j].ID) < 0
+		})
+	}
+
+	return ret
+}
+
+// countBlobReferences returns the number of references to each blob of the
+// blob store, which are held by the values of the records and the previous
+// versions, and by the large keys. Expired records are included, since they
+// hold their references until they are removed. The caller must hold the read
+// lock.
+func (a *Arc) countBlobReferences() map[blobID]int {
+	ret := map[blobID]int{}
+
+	count := func(n *node) {
+		if id, err := sliceToBlobID(n.data); n.blobValue && err == nil {
+			ret[id]++
+		}
+	}
+
+	var visit func(n *node)
+
+	visit = func(n *node) {
+		count(n)
+
+		for child := n.firstChild; child != nil; child = child.nextSibling {
+			visit(child)
+		}
+	}
+
+	dbs := []*Arc{a}
+
+	// The namespaces share the lock of the container, which the caller
+	// holds.
+	if a.container != nil {
+		dbs = dbs[:0]
+
+		for _, db := range a.container.dbs {
+			dbs = append(dbs, db)
+		}
+	}
+
+	for _, db := range dbs {
+		if db.root != nil {
+			visit(db.root)
+		}
+
+		for _, versions := range db.versions {
+			for _, v := range versions {
+				count(v)
+			}
+		}
+
+		db.walkLargeKeys(func(id blobID) {
+			ret[id]++
+		})
+	}
+
+	return ret
+}
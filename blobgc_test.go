@@ -0,0 +1,131 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestBlobGC(t *testing.T) {
+	arc, _ := NewWithOptions(Options{VersionsToKeep: 1})
+
+	orphan := bytes.Repeat([]byte("o"), 100)
+	under := bytes.Repeat([]byte("u"), 100)
+	over := bytes.Repeat([]byte("v"), 100)
+	old := bytes.Repeat([]byte("p"), 100)
+
+	arc.Put([]byte("a"), under)
+	arc.Put([]byte("b"), under)
+	arc.Put([]byte("c"), over)
+	arc.Put([]byte("d"), old)
+	arc.Put([]byte("d"), []byte("small"))
+
+	if report := arc.BlobGCDryRun(); len(report.Orphaned)+len(report.UnderCounted)+len(report.OverCounted)+len(report.Missing) != 0 {
+		t.Fatalf("unexpected report of a consistent store: %+v", report)
+	}
+
+	// Corrupt the reference counts. The blob of the previous version of
+	// "d" must not be reported as an orphan.
+	arc.blobs[makeBlobID(orphan)] = &blob{value: orphan, refCount: 1}
+	arc.blobs[makeBlobID(under)].refCount = 1
+	arc.blobs[makeBlobID(over)].refCount = 3
+
+	missing := bytes.Repeat([]byte("m"), 100)
+	arc.Put([]byte("e"), missing)
+	delete(arc.blobs, makeBlobID(missing))
+
+	report := arc.BlobGCDryRun()
+
+	if len(report.Orphaned) != 1 || !bytes.Equal(report.Orphaned[0].ID, makeBlobID(orphan).Slice()) || report.ReclaimableBytes != 100 {
+		t.Errorf("unexpected orphaned blobs: %+v", report.Orphaned)
+	}
+
+	if len(report.UnderCounted) != 1 || report.UnderCounted[0].RefCount != 1 || report.UnderCounted[0].References != 2 {
+		t.Errorf("unexpected under-counted blobs: %+v", report.UnderCounted)
+	}
+
+	if len(report.OverCounted) != 1 || report.OverCounted[0].RefCount != 3 || report.OverCounted[0].References != 1 {
+		t.Errorf("unexpected over-counted blobs: %+v", report.OverCounted)
+	}
+
+	if len(report.Missing) != 1 || report.Missing[0].References != 1 || report.Missing[0].Size != 0 {
+		t.Errorf("unexpected missing blobs: %+v", report.Missing)
+	}
+
+	// The dry run changes nothing.
+	if _, found := arc.blobs[makeBlobID(orphan)]; !found {
+		t.Fatalf("expected the dry run to keep the orphan")
+	}
+
+	if _, err := arc.BlobGC(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, found := arc.blobs[makeBlobID(orphan)]; found {
+		t.Errorf("expected the orphan to be reclaimed")
+	}
+
+	if report := arc.BlobGCDryRun(); len(report.Orphaned)+len(report.UnderCounted)+len(report.OverCounted) != 0 || len(report.Missing) != 1 {
+		t.Errorf("unexpected report after the repair: %+v", report)
+	}
+
+	// The corrected counts release the blobs along with their references.
+	arc.Delete([]byte("a"))
+	arc.Delete([]byte("c"))
+
+	if value, err := arc.Get([]byte("b")); err != nil || !bytes.Equal(value, under) {
+		t.Errorf("unexpected value: %v", err)
+	}
+
+	if _, found := arc.blobs[makeBlobID(over)]; found {
+		t.Errorf("expected the blob to be released with its last reference")
+	}
+
+	if !arc.dirty() {
+		t.Errorf("expected the repair to mark the database dirty")
+	}
+}
+
+func TestBlobGCContainer(t *testing.T) {
+	c, err := OpenContainer(filepath.Join(t.TempDir(), "test.arcc"))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer c.Close()
+
+	shared := bytes.Repeat([]byte("x"), 100)
+	users, _ := c.DB("users")
+	orders, _ := c.DB("orders")
+
+	users.Put([]byte("alice"), shared)
+	orders.Put([]byte("alice"), shared)
+
+	// The references of the other namespaces keep the blob alive.
+	report, err := users.BlobGC()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(report.Orphaned)+len(report.UnderCounted)+len(report.OverCounted)+len(report.Missing) != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	if value, err := orders.Get([]byte("alice")); err != nil || !bytes.Equal(value, shared) {
+		t.Errorf("unexpected value: %v", err)
+	}
+}
+
+func TestBlobGCReadOnly(t *testing.T) {
+	arc := New()
+	arc.readOnly = true
+
+	if _, err := arc.BlobGC(); err != ErrReadOnly {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrReadOnly)
+	}
+}
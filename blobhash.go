@@ -0,0 +1,129 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrBlobHashMismatch is returned when a database file is opened with a
+	// BlobHash other than the one that it was written with.
+	ErrBlobHashMismatch = errors.New("blob hash mismatch")
+
+	// ErrHashCollision is returned when ParanoidDedup is enabled, and a value
+	// has the same blobID as a different stored value.
+	ErrHashCollision = errors.New("blob hash collision")
+)
+
+// blobHashNamePrefix precedes the name of the BlobHash where it is recorded in
+// the file header, along with the name of the KeyTransform.
+const blobHashNamePrefix = "blobhash:"
+
+// BlobHash computes the IDs under which the values that are larger than 32
+// bytes are deduplicated. The default is SHA-256, which is accelerated by the
+// CPU on most platforms. Other hash functions, such as BLAKE3 or XXH128 from
+// third-party packages, are plugged in using NewBlobHash. Values with equal
+// IDs are stored once, therefore a hash function whose collisions can be
+// produced, deliberately or by chance, can make a record read the value of
+// another record. Non-cryptographic hash functions are only suitable for
+// trusted values.
+//
+// The name of the hash function is recorded in the database file, and opening
+// the file with a different hash function fails with ErrBlobHashMismatch. The
+// root hash covers the IDs of the blob values, therefore root hashes are only
+// comparable between databases that use the same hash function.
+type BlobHash interface {
+	// Name identifies the hash function. It must be 1 to 255 bytes long.
+	Name() string
+
+	// Sum returns the ID of the value. Hash functions with shorter digests
+	// leave the trailing bytes zero. It must be deterministic, and must not
+	// modify the given value.
+	Sum(value []byte) [blobIDLen]byte
+}
+
+// funcBlobHash is a BlobHash backed by a function.
+type funcBlobHash struct {
+	name string
+	fn   func(value []byte) [blobIDLen]byte
+}
+
+// Name returns the name of the hash function.
+func (h funcBlobHash) Name() string {
+	return h.name
+}
+
+// Sum returns the ID of the value.
+func (h funcBlobHash) Sum(value []byte) [blobIDLen]byte {
+	return h.fn(value)
+}
+
+// NewBlobHash returns a BlobHash with the given name, which computes the IDs
+// of the values using fn.
+func NewBlobHash(name string, fn func(value []byte) [blobIDLen]byte) BlobHash {
+	return funcBlobHash{name: name, fn: fn}
+}
+
+// makeBlobID returns the blobID of the value, which is computed by the
+// BlobHash option.
+func (a *Arc) makeBlobID(value []byte) blobID {
+	if a.opts.BlobHash == nil {
+		return makeBlobID(value)
+	}
+
+	return a.opts.BlobHash.Sum(value)
+}
+
+// checkHashCollision returns ErrHashCollision if the value would be stored as
+// a blob whose blobID is taken by a different value. It is a no-op unless the
+// ParanoidDedup option is enabled. The caller must hold the write lock.
+func (a *Arc) checkHashCollision(value []byte) error {
+	if !a.opts.ParanoidDedup || len(value) <= inlineValueThreshold {
+		return nil
+	}
+
+	b, found := a.blobs[a.makeBlobID(value)]
+
+	if !found {
+		return nil
+	}
+
+	stored, err := b.load()
+
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(stored, value) {
+		return ErrHashCollision
+	}
+
+	return nil
+}
+
+// checkTransformName verifies that the name recorded in the header of a
+// database file matches the options. It returns ErrBlobHashMismatch if only
+// the blob hash differs, and ErrKeyTransformMismatch otherwise.
+func (o Options) checkTransformName(name string) error {
+	if name == o.keyTransformName() {
+		return nil
+	}
+
+	o.BlobHash = nil
+	keysOnly := o.keyTransformName()
+	prefix := blobHashNamePrefix
+
+	if keysOnly != "" {
+		prefix = keysOnly + "+" + prefix
+	}
+
+	if name == keysOnly || strings.HasPrefix(name, prefix) {
+		return ErrBlobHashMismatch
+	}
+
+	return ErrKeyTransformMismatch
+}
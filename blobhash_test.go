@@ -0,0 +1,106 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+var sha512BlobHash = NewBlobHash("sha512/256", sha512.Sum512_256)
+
+func TestBlobHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	opts := Options{BlobHash: sha512BlobHash, CaseInsensitiveKeys: true}
+	arc, _ := OpenWithOptions(path, opts)
+
+	arc.Put([]byte("a"), blobValueX())
+	arc.Put([]byte("b"), blobValueX())
+
+	id := sha512.Sum512_256(blobValueX())
+
+	if b, found := arc.blobs[id]; !found || b.refCount != 2 {
+		t.Fatalf("expected the blob to be stored under its SHA-512/256 hash")
+	}
+
+	if value, err := arc.Get([]byte("a")); err != nil || !bytes.Equal(value, blobValueX()) {
+		t.Errorf("unexpected value: %v", err)
+	}
+
+	arc.Close()
+
+	reopened, err := OpenWithOptions(path, opts)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertSameRecords(t, reopened, arc)
+	reopened.Close()
+
+	mismatched := []struct {
+		opts Options
+		want error
+	}{
+		{opts: Options{CaseInsensitiveKeys: true}, want: ErrBlobHashMismatch},
+		{opts: Options{CaseInsensitiveKeys: true, BlobHash: NewBlobHash("other", sha512.Sum512_256)}, want: ErrBlobHashMismatch},
+		{opts: Options{BlobHash: sha512BlobHash}, want: ErrKeyTransformMismatch},
+	}
+
+	for _, tc := range mismatched {
+		if _, err := OpenWithOptions(path, tc.opts); err != tc.want {
+			t.Errorf("unexpected error: got:%v, want:%v", err, tc.want)
+		}
+	}
+
+	// Files written with the default hash function do not open with another.
+	path = filepath.Join(t.TempDir(), "default.arc")
+	arc, _ = Open(path)
+	arc.Put([]byte("a"), blobValueX())
+	arc.Close()
+
+	if _, err := OpenWithOptions(path, Options{BlobHash: sha512BlobHash}); err != ErrBlobHashMismatch {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrBlobHashMismatch)
+	}
+
+	if _, err := NewWithOptions(Options{BlobHash: NewBlobHash("", sha512.Sum512_256)}); err != ErrInvalidOptions {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrInvalidOptions)
+	}
+}
+
+func TestParanoidDedup(t *testing.T) {
+	constant := NewBlobHash("constant", func([]byte) [blobIDLen]byte { return [blobIDLen]byte{} })
+	other := bytes.Repeat([]byte("y"), 64)
+
+	arc, _ := NewWithOptions(Options{BlobHash: constant, ParanoidDedup: true})
+	arc.Put([]byte("a"), blobValueX())
+
+	if err := arc.Put([]byte("b"), other); !errors.Is(err, ErrHashCollision) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrHashCollision)
+	}
+
+	if err := arc.Put([]byte("c"), blobValueX()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := arc.Get([]byte("b")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+
+	// Without the comparison, the colliding value silently reads as the
+	// value that was stored first.
+	arc, _ = NewWithOptions(Options{BlobHash: constant})
+	arc.Put([]byte("a"), blobValueX())
+
+	if err := arc.Put([]byte("b"), other); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if value, _ := arc.Get([]byte("b")); !bytes.Equal(value, blobValueX()) {
+		t.Errorf("unexpected value: %q", value)
+	}
+}
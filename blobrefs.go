@@ -0,0 +1,233 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"iter"
+	"maps"
+	"slices"
+)
+
+// blobRefs maps blobIDs to the keys of the records that reference the blobs.
+// The keys are stored in the form that they have in the tree. Previous
+// versions of the records are not included.
+type blobRefs map[blobID]map[string]struct{}
+
+// add records that the key references the blob.
+func (r blobRefs) add(id blobID, key []byte) {
+	keys, found := r[id]
+
+	if !found {
+		keys = map[string]struct{}{}
+		r[id] = keys
+	}
+
+	keys[string(key)] = struct{}{}
+}
+
+// remove records that the key no longer references the blob.
+func (r blobRefs) remove(id blobID, key []byte) {
+	delete(r[id], string(key))
+
+	if len(r[id]) == 0 {
+		delete(r, id)
+	}
+}
+
+// clone returns a deep copy of the references.
+func (r blobRefs) clone() blobRefs {
+	ret := make(blobRefs, len(r))
+
+	for id, keys := range r {
+		ret[id] = maps.Clone(keys)
+	}
+
+	return ret
+}
+
+// BlobReferences returns the keys of the records whose values are the blob
+// with the given ID, such as the IDs reported by BlobStats, in lexicographic
+// order. The keys share a deduplicated value. It returns nil if no record
+// references the blob. The lookup takes time proportional to the number of
+// keys when Options.TrackBlobReferences is enabled, and walks the entire tree
+// otherwise. Previous versions of the records are not included, and neither
+// are the keys that the Authorizer does not allow to be read.
+func (a *Arc) BlobReferences(id []byte) [][]byte {
+	a.rlock()
+	defer a.runlock()
+
+	target, err := sliceToBlobID(id)
+
+	if err != nil {
+		return nil
+	}
+
+	var ret [][]byte
+
+	if a.blobRefs != nil {
+		for key := range a.blobRefs[target] {
+			ret = append(ret, []byte(key))
+		}
+
+		slices.SortFunc(ret, bytes.Compare)
+	} else {
+		a.walkPrefix(nil, func(key []byte, n *node) bool {
+			if n.blobValue && bytes.Equal(n.data, target[:]) {
+				ret = append(ret, key)
+			}
+
+			return true
+		})
+	}
+
+	ret = slices.DeleteFunc(ret, func(key []byte) bool {
+		return !a.readable(key)
+	})
+
+	for i, key := range ret {
+		ret[i] = a.originalKey(key)
+	}
+
+	return ret
+}
+
+// recordBlobID returns the blobID of the value of the record that matches the
+// key, and false if the record does not exist or its value is not a blob. The
+// caller must hold the read lock.
+func (a *Arc) recordBlobID(key []byte) (blobID, bool) {
+	n, _, err := a.findNodeAndParent(key)
+
+	if err != nil || !n.isRecord || !n.blobValue {
+		return blobID{}, false
+	}
+
+	id, err := sliceToBlobID(n.data)
+
+	return id, err == nil
+}
+
+// updateBlobRef moves the key from the blob that the record referenced before
+// a write to the blob that it references after the write. It is a no-op unless
+// the TrackBlobReferences option is enabled. The caller must hold the write
+// lock.
+func (a *Arc) updateBlobRef(key []byte, previous blobID, hadPrevious bool) {
+	if a.blobRefs == nil {
+		return
+	}
+
+	if hadPrevious {
+		a.blobRefs.remove(previous, key)
+	}
+
+	if id, found := a.recordBlobID(key); found {
+		a.blobRefs.add(id, key)
+	}
+}
+
+// rebuildBlobRefs replaces the blob references with those of every record in
+// the tree. It is a no-op unless the TrackBlobReferences option is enabled.
+// The caller must hold the write lock.
+func (a *Arc) rebuildBlobRefs() {
+	if !a.opts.TrackBlobReferences {
+		return
+	}
+
+	a.blobRefs = a.collectBlobRefs()
+}
+
+// collectBlobRefs returns the blob references of every record in the tree.
+// The caller must hold the read lock.
+func (a *Arc) collectBlobRefs() blobRefs {
+	ret := blobRefs{}
+
+	a.walkPrefix(nil, func(key []byte, n *node) bool {
+		if id, err := sliceToBlobID(n.data); n.blobValue && err == nil {
+			ret.add(id, key)
+		}
+
+		return true
+	})
+
+	return ret
+}
+
+// DuplicateGroup is a set of records whose values are the same deduplicated
+// blob.
+type DuplicateGroup struct {
+	ID   []byte   // The blobID of the shared value.
+	Size int      // Size of the value in bytes.
+	Keys [][]byte // Keys of the records in lexicographic order.
+}
+
+// Duplicates returns an iterator over the groups of records that share a
+// value, which are found using the deduplication of the blob store. Only the
+// groups of at least minRefs records are yielded, and minRefs below 2 is
+// treated as 2. The groups are yielded in descending order of their number
+// of records. Values that are too small to be stored as blobs are not
+// deduplicated, and are therefore never reported. Like BlobReferences, the
+// groups are found without walking the tree when Options.TrackBlobReferences
+// is enabled. The groups are captured when the iteration begins.
+func (a *Arc) Duplicates(minRefs int) iter.Seq[DuplicateGroup] {
+	return func(yield func(DuplicateGroup) bool) {
+		a.rlock()
+		groups := a.collectDuplicates(max(minRefs, 2))
+		a.runlock()
+
+		for _, g := range groups {
+			if !yield(g) {
+				return
+			}
+		}
+	}
+}
+
+// collectDuplicates captures the groups of Duplicates. The caller must hold
+// the read lock.
+func (a *Arc) collectDuplicates(minRefs int) []DuplicateGroup {
+	var ret []DuplicateGroup
+
+	refs := a.blobRefs
+
+	if refs == nil {
+		refs = a.collectBlobRefs()
+	}
+
+	for id, keys := range refs {
+		if len(keys) < minRefs {
+			continue
+		}
+
+		g := DuplicateGroup{ID: bytes.Clone(id[:]), Size: a.blobs.size(id[:])}
+
+		for key := range keys {
+			if a.readable([]byte(key)) {
+				g.Keys = append(g.Keys, []byte(key))
+			}
+		}
+
+		if len(g.Keys) < minRefs {
+			continue
+		}
+
+		slices.SortFunc(g.Keys, bytes.Compare)
+
+		for i, key := range g.Keys {
+			g.Keys[i] = a.originalKey(key)
+		}
+
+		ret = append(ret, g)
+	}
+
+	// Break ties by ID so that the order is deterministic.
+	slices.SortFunc(ret, func(x, y DuplicateGroup) int {
+		if len(x.Keys) != len(y.Keys) {
+			return len(y.Keys) - len(x.Keys)
+		}
+
+		return bytes.Compare(x.ID, y.ID)
+	})
+
+	return ret
+}
@@ -0,0 +1,117 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func assertBlobReferences(t *testing.T, arc *Arc, value []byte, want ...string) {
+	t.Helper()
+
+	var got []string
+
+	for _, key := range arc.BlobReferences(makeBlobID(value).Slice()) {
+		got = append(got, string(key))
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("unexpected references: got:%q, want:%q", got, want)
+	}
+}
+
+func TestBlobReferences(t *testing.T) {
+	other := bytes.Repeat([]byte("y"), 64)
+
+	for _, tracked := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "test.arc")
+		opts := Options{TrackBlobReferences: tracked, CaseInsensitiveKeys: true}
+		arc, _ := OpenWithOptions(path, opts)
+
+		arc.Put([]byte("Cherry"), blobValueX())
+		arc.Put([]byte("apple"), blobValueX())
+		arc.Put([]byte("banana"), blobValueX())
+		arc.Put([]byte("date"), other)
+		arc.Put([]byte("small"), []byte("inline"))
+
+		assertBlobReferences(t, arc, blobValueX(), "apple", "banana", "Cherry")
+
+		arc.Put([]byte("banana"), other)
+		arc.Delete([]byte("apple"))
+		arc.Rename([]byte("date"), []byte("fig"))
+
+		assertBlobReferences(t, arc, blobValueX(), "Cherry")
+		assertBlobReferences(t, arc, other, "banana", "fig")
+		assertBlobReferences(t, arc, []byte("inline"))
+
+		if got := arc.BlobReferences([]byte("bogus")); got != nil {
+			t.Errorf("unexpected references: %q", got)
+		}
+
+		clone := arc.Clone()
+		clone.Delete([]byte("fig"))
+
+		assertBlobReferences(t, clone, other, "banana")
+		assertBlobReferences(t, arc, other, "banana", "fig")
+
+		arc.Close()
+		reopened, _ := OpenWithOptions(path, opts)
+
+		assertBlobReferences(t, reopened, other, "banana", "fig")
+		assertBlobReferences(t, reopened, blobValueX(), "Cherry")
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	other := bytes.Repeat([]byte("y"), 64)
+
+	for _, tracked := range []bool{false, true} {
+		arc, _ := NewWithOptions(Options{TrackBlobReferences: tracked})
+
+		for _, key := range []string{"c", "a", "b"} {
+			arc.Put([]byte(key), blobValueX())
+		}
+
+		arc.Put([]byte("d"), other)
+		arc.Put([]byte("e"), other)
+		arc.Put([]byte("unique"), bytes.Repeat([]byte("z"), 64))
+		arc.Put([]byte("small1"), []byte("inline"))
+		arc.Put([]byte("small2"), []byte("inline"))
+
+		var got []DuplicateGroup
+
+		for g := range arc.Duplicates(0) {
+			got = append(got, g)
+		}
+
+		if len(got) != 2 {
+			t.Fatalf("unexpected number of groups: %d", len(got))
+		}
+
+		if !bytes.Equal(got[0].ID, makeBlobID(blobValueX()).Slice()) || got[0].Size != len(blobValueX()) {
+			t.Errorf("unexpected group: %+v", got[0])
+		}
+
+		if keys := got[0].Keys; len(keys) != 3 || string(keys[0]) != "a" || string(keys[2]) != "c" {
+			t.Errorf("unexpected keys: %q", keys)
+		}
+
+		if keys := got[1].Keys; len(keys) != 2 || string(keys[0]) != "d" {
+			t.Errorf("unexpected keys: %q", keys)
+		}
+
+		var n int
+
+		for range arc.Duplicates(3) {
+			n++
+		}
+
+		if n != 1 {
+			t.Errorf("unexpected number of groups: got:%d, want:%d", n, 1)
+		}
+	}
+}
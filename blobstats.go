@@ -0,0 +1,163 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"slices"
+	"sort"
+)
+
+// blobStatsTopN is the number of most-referenced blobs reported by BlobStats.
+const blobStatsTopN = 10
+
+// BlobStats reports the state of the blob store, and quantifies the savings
+// achieved by value deduplication. Only the values are reported, while the
+// full keys stored in the blob store by Options.LargeKeys are left out.
+type BlobStats struct {
+	// NumBlobs is the number of unique blobs.
+	NumBlobs int
+
+	// LogicalBytes is the total size of the blob values as seen by the
+	// records, which is what the values would occupy without deduplication.
+	LogicalBytes int64
+
+	// PhysicalBytes is the total size of the unique blob values.
+	PhysicalBytes int64
+
+	// DedupRatio is LogicalBytes divided by PhysicalBytes. A ratio of 1
+	// means that deduplication saved nothing. It is 0 if there are no blobs.
+	DedupRatio float64
+
+	// RefCounts maps reference counts to the number of blobs that have
+	// them. For example, RefCounts[1] is the number of unshared blobs.
+	RefCounts map[int]int
+
+	// TopReferenced lists the most referenced blobs in descending order of
+	// their reference count.
+	TopReferenced []BlobInfo
+}
+
+// BlobInfo describes a single blob.
+type BlobInfo struct {
+	ID       []byte // The blobID, which is the BlobHash of the value.
+	Size     int    // Size of the value in bytes.
+	RefCount int    // Number of records that reference the blob.
+}
+
+// BlobStats returns the statistics of the blob store.
+func (a *Arc) BlobStats() BlobStats {
+	a.rlock()
+	defer a.runlock()
+
+	ret := BlobStats{RefCounts: map[int]int{}}
+	infos := make([]BlobInfo, 0, len(a.blobs))
+	keyRefs := a.countLargeKeys()
+
+	for id, b := range a.blobs {
+		// A blob that holds a large key may hold a value as well.
+		refCount := b.refCount - keyRefs[id]
+
+		if refCount <= 0 {
+			continue
+		}
+
+		ret.NumBlobs++
+		ret.LogicalBytes += int64(b.size()) * int64(refCount)
+		ret.PhysicalBytes += int64(b.size())
+		ret.RefCounts[refCount]++
+
+		infos = append(infos, BlobInfo{ID: append([]byte{}, id[:]...), Size: b.size(), RefCount: refCount})
+	}
+
+	if ret.PhysicalBytes > 0 {
+		ret.DedupRatio = float64(ret.LogicalBytes) / float64(ret.PhysicalBytes)
+	}
+
+	// Break ties by ID so that the report is deterministic.
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].RefCount != infos[j].RefCount {
+			return infos[i].RefCount > infos[j].RefCount
+		}
+
+		return bytes.Compare(infos[i].ID, infos[j].ID) < 0
+	})
+
+	ret.TopReferenced = infos[:min(len(infos), blobStatsTopN)]
+
+	return ret
+}
+
+// LargeValue describes a record whose value is stored in the blob store.
+type LargeValue struct {
+	Key      []byte // Key of the record.
+	Size     int    // Size of the value in bytes.
+	BlobID   []byte // The blobID, which is the BlobHash of the value.
+	RefCount int    // Number of records that share the value.
+}
+
+// LargestValues returns the k records with the largest values in descending
+// order of their value size, which shows what takes up the most space. Ties
+// are broken by key. Only the values stored in the blob store are reported,
+// since the inline values are at most 32 bytes. A value shared with other
+// records by deduplication is reported for each of them, along with the
+// number of records that share it. Expired records are included, since they
+// take up space until they are deleted, but the records that the Authorizer
+// does not allow to be read are not. The sizes are read from the blob store,
+// therefore no value is copied. It returns nil if k is not positive.
+func (a *Arc) LargestValues(k int) []LargeValue {
+	a.rlock()
+	defer a.runlock()
+
+	if k <= 0 {
+		return nil
+	}
+
+	var ret []LargeValue
+
+	// The result is kept sorted, and holds at most k values.
+	a.walkPrefix(nil, func(key []byte, n *node) bool {
+		if !n.blobValue {
+			return true
+		}
+
+		v := LargeValue{Key: key, Size: a.blobs.size(n.data), BlobID: n.data}
+
+		if len(ret) == k && compareLargeValues(v, ret[k-1]) >= 0 {
+			return true
+		}
+
+		if !a.readable(key) {
+			return true
+		}
+
+		i, _ := slices.BinarySearchFunc(ret, v, compareLargeValues)
+		ret = slices.Insert(ret, i, v)
+		ret = ret[:min(len(ret), k)]
+
+		return true
+	})
+
+	keyRefs := a.countLargeKeys()
+
+	for i, v := range ret {
+		ret[i].Key = a.originalKey(v.Key)
+		ret[i].BlobID = bytes.Clone(v.BlobID)
+
+		if id, err := sliceToBlobID(v.BlobID); err == nil && a.blobs[id] != nil {
+			ret[i].RefCount = a.blobs[id].refCount - keyRefs[id]
+		}
+	}
+
+	return ret
+}
+
+// compareLargeValues orders the values by descending size, and then by key.
+func compareLargeValues(a LargeValue, b LargeValue) int {
+	if a.Size != b.Size {
+		return b.Size - a.Size
+	}
+
+	return bytes.Compare(a.Key, b.Key)
+}
@@ -0,0 +1,164 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestBlobStats(t *testing.T) {
+	arc := New()
+
+	if stats := arc.BlobStats(); stats.NumBlobs != 0 || stats.DedupRatio != 0 || len(stats.TopReferenced) != 0 {
+		t.Errorf("unexpected stats of an empty database: %+v", stats)
+	}
+
+	shared := bytes.Repeat([]byte("s"), 100)
+	unique := bytes.Repeat([]byte("u"), 50)
+
+	// Inline values must not be accounted for.
+	arc.Put([]byte("inline"), []byte("small"))
+
+	for i := 0; i < 3; i++ {
+		arc.Put([]byte(fmt.Sprintf("shared-%d", i)), shared)
+	}
+
+	arc.Put([]byte("unique"), unique)
+
+	stats := arc.BlobStats()
+
+	if stats.NumBlobs != 2 {
+		t.Errorf("unexpected blob count: got:%d, want:2", stats.NumBlobs)
+	}
+
+	if stats.LogicalBytes != 350 || stats.PhysicalBytes != 150 {
+		t.Errorf("unexpected bytes: got:%d/%d, want:350/150", stats.LogicalBytes, stats.PhysicalBytes)
+	}
+
+	if want := 350.0 / 150.0; stats.DedupRatio != want {
+		t.Errorf("unexpected dedup ratio: got:%f, want:%f", stats.DedupRatio, want)
+	}
+
+	if stats.RefCounts[1] != 1 || stats.RefCounts[3] != 1 {
+		t.Errorf("unexpected refCount distribution: %v", stats.RefCounts)
+	}
+
+	top := stats.TopReferenced
+
+	if len(top) != 2 || top[0].RefCount != 3 || top[0].Size != 100 || !bytes.Equal(top[0].ID, makeBlobID(shared).Slice()) {
+		t.Errorf("unexpected top referenced blobs: %+v", top)
+	}
+}
+
+func TestBlobStatsLargeKeys(t *testing.T) {
+	arc, _ := NewWithOptions(Options{LargeKeys: true})
+	first, second := largeTestKey('a'), largeTestKey('b')
+	value := bytes.Repeat([]byte("v"), 100)
+
+	arc.Put(first, value)
+	arc.Put(second, []byte("small"))
+
+	// The value is the same blob as the full key of the first record.
+	arc.Put([]byte("copy"), first)
+
+	stats := arc.BlobStats()
+
+	if stats.NumBlobs != 2 {
+		t.Errorf("unexpected blob count: got:%d, want:2", stats.NumBlobs)
+	}
+
+	if want := int64(len(value) + len(first)); stats.LogicalBytes != want || stats.PhysicalBytes != want {
+		t.Errorf("unexpected bytes: got:%d/%d, want:%d/%d", stats.LogicalBytes, stats.PhysicalBytes, want, want)
+	}
+
+	if stats.RefCounts[1] != 2 || len(stats.RefCounts) != 1 {
+		t.Errorf("unexpected refCount distribution: %v", stats.RefCounts)
+	}
+
+	for _, v := range arc.LargestValues(2) {
+		if v.RefCount != 1 {
+			t.Errorf("unexpected reference count of %q: got:%d, want:1", v.Key[:8], v.RefCount)
+		}
+	}
+}
+
+func TestBlobStatsAfterDelete(t *testing.T) {
+	arc := New()
+	value := blobValueX()
+
+	// Exercise the leaf, single-child, multi-child, and root deletion paths.
+	for _, key := range []string{"a", "ab", "abc", "abd", "b"} {
+		arc.Put([]byte(key), value)
+	}
+
+	for i, key := range []string{"b", "ab", "a", "abc", "abd"} {
+		if err := arc.Delete([]byte(key)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := 4 - i
+		stats := arc.BlobStats()
+
+		if got := stats.RefCounts[want]; want > 0 && got != 1 {
+			t.Errorf("unexpected refCount after deleting %q: %v", key, stats.RefCounts)
+		}
+
+		if want == 0 && stats.NumBlobs != 0 {
+			t.Errorf("unexpected blob count: got:%d, want:0", stats.NumBlobs)
+		}
+	}
+}
+
+func TestLargestValues(t *testing.T) {
+	arc := New()
+
+	if got := arc.LargestValues(3); got != nil {
+		t.Errorf("unexpected values of an empty database: %+v", got)
+	}
+
+	shared := bytes.Repeat([]byte("s"), 100)
+
+	arc.Put([]byte("inline"), []byte("small"))
+	arc.Put([]byte("shared-1"), shared)
+	arc.Put([]byte("shared-0"), shared)
+	arc.Put([]byte("medium"), bytes.Repeat([]byte("m"), 50))
+	arc.Put([]byte("large"), bytes.Repeat([]byte("l"), 200))
+
+	got := arc.LargestValues(3)
+
+	want := []struct {
+		key      string
+		size     int
+		refCount int
+	}{
+		{"large", 200, 1},
+		{"shared-0", 100, 2},
+		{"shared-1", 100, 2},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("unexpected values: %+v", got)
+	}
+
+	for i, w := range want {
+		if string(got[i].Key) != w.key || got[i].Size != w.size || got[i].RefCount != w.refCount {
+			t.Errorf("unexpected value %d: got:%+v, want:%+v", i, got[i], w)
+		}
+	}
+
+	if id := arc.BlobStats().TopReferenced[0].ID; !bytes.Equal(got[1].BlobID, id) {
+		t.Errorf("unexpected blob ID: got:%x, want:%x", got[1].BlobID, id)
+	}
+
+	// Inline values are not reported.
+	if got := arc.LargestValues(10); len(got) != 4 {
+		t.Errorf("unexpected number of values: got:%d, want:4", len(got))
+	}
+
+	if got := arc.LargestValues(0); got != nil {
+		t.Errorf("unexpected values: %+v", got)
+	}
+}
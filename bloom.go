@@ -0,0 +1,140 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"hash/maphash"
+	"math"
+)
+
+const (
+	// maxBloomFilterBitsPerKey is the maximum BloomFilterBitsPerKey option.
+	maxBloomFilterBitsPerKey = 32
+
+	// minBloomFilterKeys is the number of keys that the smallest Bloom
+	// filter is sized for, which avoids rebuilding small filters often.
+	minBloomFilterKeys = 1024
+)
+
+// keyFilter is a counting Bloom filter over the record keys, which allows the
+// lookups of absent keys to skip the tree descent. The bits of a plain Bloom
+// filter are replaced by counters, which allows keys to be removed. Saturated
+// counters are never decremented, since their true count is unknown, which
+// keeps the filter free of false negatives at the cost of false positives.
+type keyFilter struct {
+	counters  []uint8
+	numHashes int
+	numKeys   int // Number of keys in the filter.
+	capacity  int // Number of keys that the filter is sized for.
+	seed      maphash.Seed
+}
+
+// newKeyFilter returns an empty filter that holds up to capacity keys at the
+// given number of counters per key.
+func newKeyFilter(bitsPerKey int, capacity int) *keyFilter {
+	capacity = max(capacity, minBloomFilterKeys)
+
+	// The false positive rate is the lowest at ln(2) hashes per bit.
+	numHashes := int(math.Round(float64(bitsPerKey) * math.Ln2))
+
+	return &keyFilter{
+		counters:  make([]uint8, capacity*bitsPerKey),
+		numHashes: max(numHashes, 1),
+		capacity:  capacity,
+		seed:      maphash.MakeSeed(),
+	}
+}
+
+// forEachCounter calls fn with the index of every counter of the key. The
+// indexes are derived from a single hash using double hashing.
+func (f *keyFilter) forEachCounter(key []byte, fn func(i int)) {
+	h := maphash.Bytes(f.seed, key)
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	m := uint32(len(f.counters))
+
+	for i := uint32(0); i < uint32(f.numHashes); i++ {
+		fn(int((h1 + i*h2) % m))
+	}
+}
+
+// add adds the key to the filter.
+func (f *keyFilter) add(key []byte) {
+	f.numKeys++
+	f.forEachCounter(key, func(i int) {
+		if f.counters[i] < math.MaxUint8 {
+			f.counters[i]++
+		}
+	})
+}
+
+// remove removes the key, which must have been added, from the filter.
+func (f *keyFilter) remove(key []byte) {
+	f.numKeys--
+	f.forEachCounter(key, func(i int) {
+		if f.counters[i] > 0 && f.counters[i] < math.MaxUint8 {
+			f.counters[i]--
+		}
+	})
+}
+
+// mayContain returns false if the key is definitely not in the filter.
+func (f *keyFilter) mayContain(key []byte) bool {
+	ret := true
+
+	f.forEachCounter(key, func(i int) {
+		ret = ret && f.counters[i] > 0
+	})
+
+	return ret
+}
+
+// clone returns a copy of the filter.
+func (f *keyFilter) clone() *keyFilter {
+	ret := *f
+	ret.counters = append([]uint8(nil), f.counters...)
+
+	return &ret
+}
+
+// filterAdd adds the key of a new record to the Bloom filter, which is rebuilt
+// at twice the capacity once it is full. It is a no-op unless the
+// BloomFilterBitsPerKey option is set. The caller must hold the write lock.
+func (a *Arc) filterAdd(key []byte) {
+	if a.filter == nil {
+		return
+	}
+
+	if a.filter.numKeys >= a.filter.capacity {
+		a.rebuildFilter(2 * a.filter.capacity)
+		return
+	}
+
+	a.filter.add(key)
+}
+
+// filterRemove removes the key of a deleted record from the Bloom filter. It
+// is a no-op unless the BloomFilterBitsPerKey option is set. The caller must
+// hold the write lock.
+func (a *Arc) filterRemove(key []byte) {
+	if a.filter != nil {
+		a.filter.remove(key)
+	}
+}
+
+// rebuildFilter replaces the Bloom filter with one that holds the keys of
+// every record in the tree, and is sized for at least capacity keys. It is a
+// no-op unless the BloomFilterBitsPerKey option is set. The caller must hold
+// the write lock.
+func (a *Arc) rebuildFilter(capacity int) {
+	if a.opts.BloomFilterBitsPerKey == 0 {
+		return
+	}
+
+	a.filter = newKeyFilter(a.opts.BloomFilterBitsPerKey, max(capacity, 2*a.numRecords))
+
+	a.walkPrefix(nil, func(key []byte, n *node) bool {
+		a.filter.add(key)
+		return true
+	})
+}
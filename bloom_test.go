@@ -0,0 +1,121 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyFilter(t *testing.T) {
+	f := newKeyFilter(10, 0)
+
+	for i := range minBloomFilterKeys {
+		f.add([]byte(fmt.Sprintf("key%d", i)))
+	}
+
+	for i := range minBloomFilterKeys {
+		if !f.mayContain([]byte(fmt.Sprintf("key%d", i))) {
+			t.Fatalf("unexpected false negative: key%d", i)
+		}
+	}
+
+	var falsePositives int
+
+	for i := range 10000 {
+		if f.mayContain([]byte(fmt.Sprintf("absent%d", i))) {
+			falsePositives++
+		}
+	}
+
+	// Ten bits per key yield a false positive rate of about one percent.
+	if falsePositives > 300 {
+		t.Errorf("unexpected number of false positives: %d", falsePositives)
+	}
+
+	for i := range minBloomFilterKeys {
+		f.remove([]byte(fmt.Sprintf("key%d", i)))
+	}
+
+	for i, c := range f.counters {
+		if c != 0 {
+			t.Fatalf("unexpected counter %d after removing every key: %d", i, c)
+		}
+	}
+
+	// Saturated counters are never decremented.
+	f.counters[0] = 255
+	f.remove([]byte("bogus"))
+
+	if f.counters[0] != 255 {
+		t.Errorf("expected the saturated counter to stay saturated")
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	opts := Options{BloomFilterBitsPerKey: 10, CaseInsensitiveKeys: true}
+	arc, _ := OpenWithOptions(path, opts)
+	numKeys := 3 * minBloomFilterKeys
+
+	for i := range numKeys {
+		arc.Put([]byte(fmt.Sprintf("Key%d", i)), []byte("value"))
+	}
+
+	// The filter grows along with the database.
+	if arc.filter.capacity < numKeys || arc.filter.numKeys != numKeys {
+		t.Errorf("unexpected filter: capacity:%d, keys:%d", arc.filter.capacity, arc.filter.numKeys)
+	}
+
+	arc.Put([]byte("key0"), []byte("updated"))
+	arc.Delete([]byte("key1"))
+	arc.Rename([]byte("key2"), []byte("renamed"))
+
+	assertFilterKeys := func(arc *Arc) {
+		t.Helper()
+
+		for _, key := range []string{"KEY0", "key3", "Renamed"} {
+			if _, err := arc.Get([]byte(key)); err != nil {
+				t.Errorf("unexpected error for %q: %v", key, err)
+			}
+		}
+
+		for _, key := range []string{"key1", "key2", "absent"} {
+			if _, err := arc.Get([]byte(key)); !errors.Is(err, ErrKeyNotFound) {
+				t.Errorf("unexpected error for %q: got:%v, want:%v", key, err, ErrKeyNotFound)
+			}
+		}
+
+		if arc.filter.numKeys != arc.Len() {
+			t.Errorf("unexpected number of keys in the filter: got:%d, want:%d", arc.filter.numKeys, arc.Len())
+		}
+	}
+
+	assertFilterKeys(arc)
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The filter is rebuilt when the database is loaded.
+	arc, err := OpenWithOptions(path, opts)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer arc.Close()
+
+	assertFilterKeys(arc)
+
+	// Clones maintain their own filter.
+	clone := arc.Clone()
+	clone.Delete([]byte("key3"))
+
+	if _, err := arc.Get([]byte("key3")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
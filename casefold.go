@@ -0,0 +1,172 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+)
+
+// caseFoldTransformName is recorded in the file header in place of, or in
+// addition to, the name of the KeyTransform when CaseInsensitiveKeys is set.
+const caseFoldTransformName = "asciifold"
+
+// foldASCII returns the key with its ASCII letters mapped to lower case. The
+// key itself is returned if it has no upper case letters.
+func foldASCII(key []byte) []byte {
+	i := bytes.IndexFunc(key, func(r rune) bool {
+		return 'A' <= r && r <= 'Z'
+	})
+
+	if i < 0 {
+		return key
+	}
+
+	ret := bytes.Clone(key)
+
+	for ; i < len(ret); i++ {
+		if c := ret[i]; 'A' <= c && c <= 'Z' {
+			ret[i] = c + 'a' - 'A'
+		}
+	}
+
+	return ret
+}
+
+// foldKey returns the key under which the given key is stored in the tree,
+// which is its case-folded form if CaseInsensitiveKeys is set, or its stored
+// form if it is a large key.
+func (a *Arc) foldKey(key []byte) []byte {
+	if !a.opts.CaseInsensitiveKeys || key == nil {
+		return a.digestKey(key)
+	}
+
+	return foldASCII(key)
+}
+
+// originalKey returns the key of the record stored under the given key as it
+// was originally inserted. The caller must hold the read lock.
+func (a *Arc) originalKey(key []byte) []byte {
+	if original := a.largeKey(key); original != nil {
+		return bytes.Clone(original)
+	}
+
+	if original, found := a.originalKeys[string(key)]; found {
+		return bytes.Clone(original)
+	}
+
+	return key
+}
+
+// rememberOriginalKey records the spelling of a newly inserted key. Keys that
+// are spelled in their folded form are not recorded, which saves space. The
+// caller must hold the write lock.
+func (a *Arc) rememberOriginalKey(key []byte, original []byte) {
+	if bytes.Equal(key, original) {
+		delete(a.originalKeys, string(key))
+		return
+	}
+
+	a.originalKeys[string(key)] = bytes.Clone(original)
+}
+
+// isLiveRecord returns true if a record that has not expired is stored under
+// the given key. The caller must hold the read lock.
+func (a *Arc) isLiveRecord(key []byte) bool {
+	n, _, err := a.findNodeAndParent(key)
+
+	return err == nil && n.isRecord && !a.expired(key)
+}
+
+// serializeOriginalKeys serializes the original key section, which consists
+// of the number of keys, the original keys in order, and the checksum of the
+// preceding bytes. Each key is prefixed by its length. The caller must hold
+// the read lock.
+func (a *Arc) serializeOriginalKeys() ([]byte, error) {
+	keys := make([]string, 0, len(a.originalKeys))
+
+	for key := range a.originalKeys {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	ret := binary.LittleEndian.AppendUint64(nil, uint64(len(keys)))
+
+	for _, key := range keys {
+		original := a.originalKeys[key]
+		ret = binary.LittleEndian.AppendUint16(ret, uint16(len(original)))
+		ret = append(ret, original...)
+	}
+
+	checksum, err := computeChecksum(ret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return binary.LittleEndian.AppendUint32(ret, checksum), nil
+}
+
+// readOriginalKeys loads the original key section produced by
+// serializeOriginalKeys. The records must already be loaded, since every
+// original key must refer to an existing record.
+func (a *Arc) readOriginalKeys(src []byte) error {
+	if len(src) < sizeOfUint64+checksumLen {
+		return ErrCorrupted
+	}
+
+	checksumPos := len(src) - checksumLen
+	checksum, err := computeChecksum(src[:checksumPos])
+
+	if err != nil {
+		return err
+	}
+
+	if checksum != binary.LittleEndian.Uint32(src[checksumPos:]) {
+		return ErrInvalidChecksum
+	}
+
+	r := bytes.NewReader(src[:checksumPos])
+
+	var count uint64
+
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return ErrCorrupted
+	}
+
+	if count > 0 && !a.opts.CaseInsensitiveKeys {
+		return ErrCorrupted
+	}
+
+	for i := uint64(0); i < count; i++ {
+		var keyLen uint16
+
+		if err := binary.Read(r, binary.LittleEndian, &keyLen); err != nil {
+			return ErrCorrupted
+		}
+
+		original := make([]byte, keyLen)
+
+		if _, err := io.ReadFull(r, original); err != nil {
+			return ErrCorrupted
+		}
+
+		key := foldASCII(original)
+
+		if n, _, err := a.findNodeAndParent(key); err != nil || !n.isRecord || bytes.Equal(key, original) {
+			return ErrCorrupted
+		}
+
+		a.originalKeys[string(key)] = original
+	}
+
+	if r.Len() != 0 {
+		return ErrCorrupted
+	}
+
+	return nil
+}
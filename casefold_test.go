@@ -0,0 +1,130 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFoldASCII(t *testing.T) {
+	testCases := []struct {
+		key  string
+		want string
+	}{
+		{key: "", want: ""},
+		{key: "example.com", want: "example.com"},
+		{key: "Example.COM", want: "example.com"},
+		{key: "Content-Type", want: "content-type"},
+		{key: "ÀB", want: "Àb"},
+	}
+
+	for _, tc := range testCases {
+		if got := foldASCII([]byte(tc.key)); string(got) != tc.want {
+			t.Errorf("unexpected key: got:%q, want:%q", got, tc.want)
+		}
+	}
+
+	key := []byte("Upper")
+	foldASCII(key)
+
+	if string(key) != "Upper" {
+		t.Errorf("expected the key to be left as is, got:%q", key)
+	}
+}
+
+func TestCaseInsensitiveKeys(t *testing.T) {
+	arc, _ := NewWithOptions(Options{CaseInsensitiveKeys: true})
+
+	arc.Put([]byte("Example.COM"), []byte("1"))
+	arc.Put([]byte("example.org"), []byte("2"))
+	arc.Put([]byte("EXAMPLE.com"), []byte("3"))
+	arc.Put([]byte("beta"), []byte("4"))
+
+	if err := arc.Add([]byte("BETA"), nil); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrDuplicateKey)
+	}
+
+	if arc.Len() != 3 {
+		t.Errorf("unexpected length: got:%d, want:3", arc.Len())
+	}
+
+	if got, err := arc.Get([]byte("example.com")); err != nil || string(got) != "3" {
+		t.Errorf("unexpected value: got:%q, err:%v", got, err)
+	}
+
+	var keys []string
+
+	for key := range arc.Keys(nil) {
+		keys = append(keys, string(key))
+	}
+
+	if want := []string{"beta", "Example.COM", "example.org"}; !slices.Equal(keys, want) {
+		t.Errorf("unexpected keys: got:%q, want:%q", keys, want)
+	}
+
+	if n, _ := arc.CountPrefix([]byte("EXAMPLE.")); n != 2 {
+		t.Errorf("unexpected count: got:%d, want:2", n)
+	}
+
+	if info, _ := arc.Stat([]byte("EXAMPLE.COM")); string(info.Key) != "Example.COM" {
+		t.Errorf("unexpected key: got:%q, want:%q", info.Key, "Example.COM")
+	}
+
+	if key, _, _ := arc.Ceiling([]byte("C")); string(key) != "Example.COM" {
+		t.Errorf("unexpected key: got:%q, want:%q", key, "Example.COM")
+	}
+
+	if c := arc.Cursor(nil); !c.Seek([]byte("EXAMPLE.O")) || string(c.Key()) != "example.org" {
+		t.Errorf("unexpected cursor key: %q", c.Key())
+	}
+
+	// Deleting the record forgets its spelling.
+	arc.Delete([]byte("EXAMPLE.COM"))
+	arc.Put([]byte("example.Com"), []byte("5"))
+
+	if info, _ := arc.Stat([]byte("example.com")); string(info.Key) != "example.Com" {
+		t.Errorf("unexpected key: got:%q, want:%q", info.Key, "example.Com")
+	}
+}
+
+func TestCaseInsensitiveKeysPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := OpenWithOptions(path, Options{CaseInsensitiveKeys: true})
+
+	arc.Put([]byte("Host"), []byte("1"))
+	arc.Put([]byte("accept"), []byte("2"))
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := Open(path); err != ErrKeyTransformMismatch {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyTransformMismatch)
+	}
+
+	reopened, err := OpenWithOptions(path, Options{CaseInsensitiveKeys: true})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer reopened.Close()
+
+	var keys []string
+
+	for key := range reopened.Keys(nil) {
+		keys = append(keys, string(key))
+	}
+
+	if want := []string{"accept", "Host"}; !slices.Equal(keys, want) {
+		t.Errorf("unexpected keys: got:%q, want:%q", keys, want)
+	}
+
+	if got, err := reopened.Get([]byte("HOST")); err != nil || string(got) != "1" {
+		t.Errorf("unexpected value: got:%q, err:%v", got, err)
+	}
+}
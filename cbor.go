@@ -0,0 +1,213 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// ErrInvalidDump is returned when a dump cannot be imported.
+var ErrInvalidDump = errors.New("invalid dump")
+
+// CBOR major types and simple values used by the dump format. See RFC 8949.
+const (
+	cborByteString = 2 << 5
+	cborArray      = 4 << 5
+	cborTag        = 6 << 5
+
+	// cborIndefinite is the additional information that marks an item of
+	// indefinite length, which is terminated by cborBreak.
+	cborIndefinite = 31
+	cborBreak      = 0xFF
+
+	// cborSelfDescribe is the tag that identifies a CBOR stream, and allows
+	// the file type to be detected by its first bytes.
+	cborSelfDescribe = 55799
+)
+
+// ExportCBOR writes every record to w as a CBOR stream, which is
+// self-describing and can be read by any CBOR implementation. The stream is a
+// tagged array of indefinite length, whose items are [key, value] pairs of
+// byte strings in the order of the database. The records are captured like
+// Scan, therefore writes are not blocked while the dump is written. Metadata
+// such as expirations is not exported.
+func (a *Arc) ExportCBOR(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	bw.Write(appendCBORHead(nil, cborTag, cborSelfDescribe))
+	bw.WriteByte(cborArray | cborIndefinite)
+
+	for key, value := range a.Scan(nil) {
+		record := []byte{cborArray | 2}
+		record = appendCBORHead(record, cborByteString, uint64(len(key)))
+		record = append(record, key...)
+		record = appendCBORHead(record, cborByteString, uint64(len(value)))
+
+		if _, err := bw.Write(record); err != nil {
+			return err
+		}
+
+		if _, err := bw.Write(value); err != nil {
+			return err
+		}
+	}
+
+	bw.WriteByte(cborBreak)
+
+	return bw.Flush()
+}
+
+// ImportCBOR reads a CBOR stream produced by ExportCBOR, and puts every record
+// that it holds. The array may also be of definite length, and the leading
+// tag may be omitted, which accommodates streams produced by other tools. The
+// records that precede an error remain in the database. Returns
+// ErrInvalidDump if the stream is malformed, or a SizeError if a record
+// exceeds the limits of the database.
+func (a *Arc) ImportCBOR(r io.Reader) error {
+	br := bufio.NewReader(r)
+	major, arg, err := readCBORHead(br)
+
+	if err != nil {
+		return err
+	}
+
+	if major == cborTag {
+		if arg != cborSelfDescribe {
+			return ErrInvalidDump
+		}
+
+		if major, arg, err = readCBORHead(br); err != nil {
+			return err
+		}
+	}
+
+	if major != cborArray {
+		return ErrInvalidDump
+	}
+
+	indefinite := arg == cborIndefiniteLen
+
+	for i := uint64(0); indefinite || i < arg; i++ {
+		if indefinite {
+			if b, err := br.Peek(1); err != nil {
+				return ErrInvalidDump
+			} else if b[0] == cborBreak {
+				br.ReadByte()
+				return nil
+			}
+		}
+
+		if major, arg, err := readCBORHead(br); err != nil {
+			return err
+		} else if major != cborArray || arg != 2 {
+			return ErrInvalidDump
+		}
+
+		key, err := readCBORByteString(br, a.opts.MaxKeyBytes, ErrKeyTooLarge)
+
+		if err != nil {
+			return err
+		}
+
+		value, err := readCBORByteString(br, a.opts.MaxValueBytes, ErrValueTooLarge)
+
+		if err != nil {
+			return err
+		}
+
+		if err := a.Put(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cborIndefiniteLen is returned by readCBORHead as the argument of items of
+// indefinite length.
+const cborIndefiniteLen = ^uint64(0)
+
+// appendCBORHead appends the head of a CBOR data item, which consists of the
+// major type and the argument encoded in the shortest form.
+func appendCBORHead(dst []byte, major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return append(dst, major|byte(arg))
+	case arg <= 0xFF:
+		return append(dst, major|24, byte(arg))
+	case arg <= 0xFFFF:
+		return binary.BigEndian.AppendUint16(append(dst, major|25), uint16(arg))
+	case arg <= 0xFFFFFFFF:
+		return binary.BigEndian.AppendUint32(append(dst, major|26), uint32(arg))
+	default:
+		return binary.BigEndian.AppendUint64(append(dst, major|27), arg)
+	}
+}
+
+// readCBORHead reads the head of a CBOR data item. It returns the major type
+// and the argument, which is cborIndefiniteLen for items of indefinite length.
+func readCBORHead(r *bufio.Reader) (byte, uint64, error) {
+	initial, err := r.ReadByte()
+
+	if err != nil {
+		return 0, 0, ErrInvalidDump
+	}
+
+	major := initial &^ 0x1F
+	info := initial & 0x1F
+
+	var size int
+
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == cborIndefinite:
+		return major, cborIndefiniteLen, nil
+	case info <= 27:
+		size = 1 << (info - 24)
+	default:
+		return 0, 0, ErrInvalidDump
+	}
+
+	var buf [sizeOfUint64]byte
+
+	if _, err := io.ReadFull(r, buf[sizeOfUint64-size:]); err != nil {
+		return 0, 0, ErrInvalidDump
+	}
+
+	return major, binary.BigEndian.Uint64(buf[:]), nil
+}
+
+// readCBORByteString reads a byte string of definite length. It returns a
+// SizeError wrapping tooLarge if the string exceeds limit bytes. The buffer
+// grows as the content arrives, which prevents a corrupted length from
+// allocating a large buffer up front.
+func readCBORByteString(r *bufio.Reader, limit int, tooLarge error) ([]byte, error) {
+	major, n, err := readCBORHead(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if major != cborByteString || n == cborIndefiniteLen {
+		return nil, ErrInvalidDump
+	}
+
+	if n > uint64(limit) {
+		return nil, &SizeError{Err: tooLarge, Size: int(min(n, math.MaxInt)), Limit: limit}
+	}
+
+	var buf bytes.Buffer
+
+	if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+		return nil, ErrInvalidDump
+	}
+
+	return buf.Bytes(), nil
+}
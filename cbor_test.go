@@ -0,0 +1,111 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestExportCBOR(t *testing.T) {
+	src := New()
+	records := map[string][]byte{
+		"":      []byte("empty"),
+		"apple": []byte("red"),
+		"apply": {},
+		"blob":  bytes.Repeat([]byte("x"), 300),
+		"large": bytes.Repeat([]byte("y"), 70000),
+	}
+
+	for key, value := range records {
+		if err := src.Put([]byte(key), value); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if err := src.ExportCBOR(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The stream starts with the self-describe tag and an indefinite array.
+	if prefix := []byte{0xD9, 0xD9, 0xF7, 0x9F}; !bytes.HasPrefix(buf.Bytes(), prefix) {
+		t.Errorf("unexpected prefix: got:%x, want:%x", buf.Bytes()[:4], prefix)
+	}
+
+	dst := New()
+
+	if err := dst.ImportCBOR(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if dst.Len() != len(records) {
+		t.Errorf("unexpected length: got:%d, want:%d", dst.Len(), len(records))
+	}
+
+	for key, want := range records {
+		got, err := dst.Get([]byte(key))
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !bytes.Equal(got, want) {
+			t.Errorf("unexpected value for %q: got:%d bytes, want:%d bytes", key, len(got), len(want))
+		}
+	}
+}
+
+func TestImportCBOR(t *testing.T) {
+	t.Run("definite length", func(t *testing.T) {
+		// [[h'6b', h'76']] without the self-describe tag.
+		src := []byte{0x81, 0x82, 0x41, 'k', 0x41, 'v'}
+		arc := New()
+
+		if err := arc.ImportCBOR(bytes.NewReader(src)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, _ := arc.Get([]byte("k")); string(got) != "v" {
+			t.Errorf("unexpected value: got:%q, want:%q", got, "v")
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		tests := []struct {
+			name string
+			src  []byte
+		}{
+			{"empty", nil},
+			{"not an array", []byte{0x41, 'k'}},
+			{"unknown tag", []byte{0xC0, 0x80}},
+			{"missing break", []byte{0x9F, 0x82, 0x41, 'k', 0x41, 'v'}},
+			{"wrong pair length", []byte{0x9F, 0x83, 0x41, 'k', 0x41, 'v', 0x41, 'x', 0xFF}},
+			{"text key", []byte{0x9F, 0x82, 0x61, 'k', 0x41, 'v', 0xFF}},
+			{"truncated value", []byte{0x9F, 0x82, 0x41, 'k', 0x45, 'v', 0xFF}},
+			{"reserved info", []byte{0x9C}},
+		}
+
+		for _, test := range tests {
+			arc := New()
+
+			if err := arc.ImportCBOR(bytes.NewReader(test.src)); err != ErrInvalidDump {
+				t.Errorf("%s: unexpected error: got:%v, want:%v", test.name, err, ErrInvalidDump)
+			}
+		}
+	})
+
+	t.Run("size limit", func(t *testing.T) {
+		src := []byte{0x9F, 0x82, 0x43, 'k', 'e', 'y', 0x41, 'v', 0xFF}
+		arc, _ := NewWithOptions(Options{MaxKeyBytes: 2})
+
+		var sizeErr *SizeError
+
+		if err := arc.ImportCBOR(bytes.NewReader(src)); !errors.As(err, &sizeErr) || sizeErr.Err != ErrKeyTooLarge {
+			t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyTooLarge)
+		}
+	})
+}
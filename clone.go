@@ -0,0 +1,229 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+)
+
+// Clone returns an independent in-memory database that holds the same records
+// and metadata. The clone shares the tree and the blobs with the database
+// until either of them is written to, at which point the writer copies the
+// whole tree and the record metadata for itself, which takes time and memory
+// proportional to the database size. The blob values are never copied.
+// Cloning itself copies the read counts enabled by ReadSampleRate, and is
+// otherwise independent of the database size, which makes it suitable for
+// what-if computations and test fixtures that read more than they write. The
+// clone has the same options and secondary indexes, but is writable, and is
+// not bound to the file, the hooks, the replicas, or the primary of the
+// database. Clones of a Container namespace are copied up front, since the
+// namespace shares its blobs with the other namespaces, and so are clones of
+// a database with a capacity limit, since its record nodes reference the
+// usage entries of the database.
+func (a *Arc) Clone() *Arc {
+	// The write lock keeps concurrent clones from racing on the share
+	// count, but does not unshare the structure since nothing is modified.
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ret := a.share()
+
+	if a.container != nil {
+		ret.unshare()
+	}
+
+	ret.startSweeper()
+	ret.startTiering()
+
+	return ret
+}
+
+// share returns a clone that shares the structure of the database. The caller
+// must hold the write lock.
+func (a *Arc) share() *Arc {
+	if a.shared == nil {
+		a.shared = &atomic.Int32{}
+		a.shared.Store(1)
+	}
+
+	a.shared.Add(1)
+
+	ret := &Arc{
+		root:         a.root,
+		numNodes:     a.numNodes,
+		numRecords:   a.numRecords,
+		mu:           &sync.RWMutex{},
+		opts:         a.opts,
+		blobs:        a.blobs,
+		keys:         &keyArena{},
+		timestamps:   a.timestamps,
+		expirations:  a.expirations,
+		versions:     a.versions,
+		tombstones:   a.tombstones,
+		meta:         maps.Clone(a.meta),
+		dictionary:   a.dictionary,
+		filter:       a.filter,
+		blobRefs:     a.blobRefs,
+		originalKeys: a.originalKeys,
+		hashes:       a.hashes,
+		now:          a.now,
+		seq:          a.seq,
+		shared:       a.shared,
+	}
+
+	if a.sizes != nil {
+		copied := *a.sizes
+		ret.sizes = &copied
+	}
+
+	if a.reads != nil {
+		ret.reads = a.reads.clone()
+	}
+
+	// Only the databases that sweep their blobs can have demoted any.
+	if a.tierStop != nil {
+		ret.tier = a.tier.retain()
+	} else if a.tier != nil {
+		ret.tier = newBlobTier(a.opts)
+	}
+
+	if a.indexes != nil {
+		ret.indexes = make(map[string]*index, len(a.indexes))
+
+		// The index trees are only modified under the lock of the database
+		// that they belong to, which the caller holds.
+		for name, idx := range a.indexes {
+			ret.indexes[name] = &index{extract: idx.extract, tree: idx.tree.share()}
+		}
+	}
+
+	if a.quotas != nil {
+		ret.quotas = make(map[string]*Quota, len(a.quotas))
+
+		for prefix, q := range a.quotas {
+			copied := *q
+			ret.quotas[prefix] = &copied
+		}
+	}
+
+	// The record nodes reference the usage entries of their database, and
+	// readers update the entries without unsharing the nodes, therefore the
+	// clone copies the nodes up front, and references the copied entries.
+	if a.usage != nil {
+		usage, copies := a.usage.clone()
+		ret.usage = usage
+		ret.unshare()
+		ret.walkPrefix(nil, func(_ []byte, n *node) bool {
+			n.usage = copies[n.usage]
+			return true
+		})
+	}
+
+	return ret
+}
+
+// unshare gives the database its own copy of the structure that it shares
+// with its clones. The blob values remain shared, since they are never
+// modified. It is a no-op if the database shares nothing, or if its clones
+// have already unshared the structure. The caller must hold the write lock.
+func (a *Arc) unshare() {
+	if a.shared == nil {
+		return
+	}
+
+	// The other databases only let go of the structure after copying it,
+	// therefore a count of one means that nobody else is reading it.
+	if a.shared.Load() > 1 {
+		blobs := blobStore{}
+
+		root := a.root
+
+		if a.root != nil {
+			a.root = copyNode(a.root, a.blobs, blobs)
+		}
+
+		if a.hashes != nil {
+			a.hashes = copyHashes(a.hashes, root, a.root)
+		}
+
+		var versions map[string][]*node
+
+		if a.versions != nil {
+			versions = make(map[string][]*node, len(a.versions))
+		}
+
+		for key, previous := range a.versions {
+			for _, v := range previous {
+				versions[key] = append(versions[key], copyNode(v, a.blobs, blobs))
+			}
+		}
+
+		a.walkLargeKeys(func(id blobID) {
+			blobs.adopt(a.blobs, id[:])
+		})
+
+		if a.timestamps != nil {
+			timestamps := make(map[string]*recordTimestamps, len(a.timestamps))
+
+			for key, ts := range a.timestamps {
+				copied := *ts
+				timestamps[key] = &copied
+			}
+
+			a.timestamps = timestamps
+		}
+
+		if a.filter != nil {
+			a.filter = a.filter.clone()
+		}
+
+		if a.blobRefs != nil {
+			a.blobRefs = a.blobRefs.clone()
+		}
+
+		a.blobs = blobs
+		a.versions = versions
+		a.expirations = maps.Clone(a.expirations)
+		a.tombstones = maps.Clone(a.tombstones)
+		a.originalKeys = maps.Clone(a.originalKeys)
+		a.shared.Add(-1)
+	}
+
+	for _, idx := range a.indexes {
+		idx.tree.unshare()
+	}
+
+	a.shared = nil
+}
+
+// copyNode returns a copy of the subtree rooted at the node, and adds the
+// blobs that it references from src to dst.
+func copyNode(n *node, src blobStore, dst blobStore) *node {
+	ret := &node{}
+	ret.shallowCopyFrom(n)
+	ret.nextSibling = nil
+	ret.firstChild = nil
+
+	if n.blobValue {
+		dst.adopt(src, n.data)
+	}
+
+	var last *node
+
+	for child := n.firstChild; child != nil; child = child.nextSibling {
+		copied := copyNode(child, src, dst)
+
+		if last == nil {
+			ret.firstChild = copied
+		} else {
+			last.nextSibling = copied
+		}
+
+		last = copied
+	}
+
+	return ret
+}
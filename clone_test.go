@@ -0,0 +1,189 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClone(t *testing.T) {
+	arc, _ := NewWithOptions(Options{VersionsToKeep: 1, RecordTimestamps: true})
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	arc.now = func() time.Time { return now }
+
+	for _, row := range basicTestTreeData() {
+		arc.Put(row.key, row.data)
+	}
+
+	arc.Put([]byte("blob"), blobValueX())
+	arc.Put([]byte("apple"), []byte("pie"))
+	arc.Expire([]byte("lime"), time.Hour)
+	arc.SetFlags([]byte("grape"), 0x01)
+
+	clone := arc.Clone()
+
+	if clone.root != arc.root {
+		t.Fatalf("expected the clone to share the tree")
+	}
+
+	assertSameRecords(t, clone, arc)
+
+	if clone.Seq() != arc.Seq() || clone.Len() != arc.Len() {
+		t.Errorf("unexpected clone: seq:%d, len:%d", clone.Seq(), clone.Len())
+	}
+
+	// Writing to the clone copies the tree, and leaves the database intact.
+	want := basicTestTree()
+	want.Put([]byte("blob"), blobValueX())
+	want.Put([]byte("apple"), []byte("pie"))
+
+	clone.Delete([]byte("blob"))
+	clone.Put([]byte("grape"), []byte("raisin"))
+	clone.Expire([]byte("lemon"), time.Hour)
+
+	if clone.root == arc.root {
+		t.Fatalf("expected the clone to copy the tree")
+	}
+
+	assertSameRecords(t, arc, want)
+
+	if len(arc.blobs) != 1 || arc.blobs[makeBlobID(blobValueX())].refCount != 1 {
+		t.Errorf("expected the blob of the database to be intact")
+	}
+
+	if len(clone.blobs) != 0 {
+		t.Errorf("expected the clone to release its blob")
+	}
+
+	if info, _ := arc.Stat([]byte("grape")); info.Flags != 0x01 {
+		t.Errorf("unexpected flags: got:%#x, want:%#x", info.Flags, 0x01)
+	}
+
+	if info, _ := arc.Stat([]byte("lemon")); !info.ExpiresAt.IsZero() {
+		t.Errorf("expected the expiration to stay with the clone")
+	}
+
+	if value, _ := arc.GetVersion([]byte("apple"), 1); string(value) != "cider" {
+		t.Errorf("unexpected version: got:%q, want:%q", value, "cider")
+	}
+
+	if value, _ := clone.GetVersion([]byte("grape"), 1); string(value) != "vine" {
+		t.Errorf("unexpected version: got:%q, want:%q", value, "vine")
+	}
+
+	// The clone is the only other holder, therefore the database writes in
+	// place once the clone has its own copy.
+	root := arc.root
+	arc.Put([]byte("cherry"), []byte("red"))
+
+	if arc.root != root {
+		t.Errorf("expected the database to keep its tree")
+	}
+
+	if _, err := clone.Get([]byte("cherry")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+}
+
+func TestCloneMetadata(t *testing.T) {
+	arc, _ := NewWithOptions(Options{MaxRecords: 3, CaseInsensitiveKeys: true})
+	arc.CreateIndex("value", valueIndexFunc)
+	arc.SetQuota([]byte("user:"), 10, 0)
+
+	arc.Put([]byte("user:Alice"), []byte("admin"))
+	arc.Put([]byte("user:Bob"), []byte("guest"))
+	arc.Put([]byte("user:Carol"), []byte("admin"))
+	arc.Get([]byte("user:alice"))
+	arc.Get([]byte("user:bob"))
+
+	clone := arc.Clone()
+
+	// The usage is cloned, therefore the clone evicts the same record.
+	clone.Put([]byte("user:Dave"), []byte("admin"))
+
+	assertIndexQuery(t, clone, "value", []byte("admin"), []byte("user:Alice"), []byte("user:Dave"))
+	assertIndexQuery(t, arc, "value", []byte("admin"), []byte("user:Alice"), []byte("user:Carol"))
+
+	if got := clone.Quotas(); len(got) != 1 || got[0].Records != 3 {
+		t.Errorf("unexpected quotas of the clone: %+v", got)
+	}
+
+	if got := arc.Quotas(); len(got) != 1 || got[0].Records != 3 {
+		t.Errorf("unexpected quotas of the database: %+v", got)
+	}
+
+	if got := collectKeys(arc); len(got) != 3 || got[2] != "user:Carol" {
+		t.Errorf("unexpected keys of the database: %q", got)
+	}
+}
+
+func TestCloneContainer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arcc")
+	c, _ := OpenContainer(path)
+	defer c.Close()
+
+	users, _ := c.DB("users")
+	orders, _ := c.DB("orders")
+
+	users.Put([]byte("alice"), blobValueX())
+	orders.Put([]byte("alice"), blobValueX())
+
+	clone := users.Clone()
+
+	// The namespace shares its blobs with the other namespaces, therefore
+	// the clone copies them up front.
+	if clone.root == users.root || len(clone.blobs) != 1 || clone.blobs[makeBlobID(blobValueX())].refCount != 1 {
+		t.Fatalf("expected the clone to hold its own copy")
+	}
+
+	clone.Delete([]byte("alice"))
+	orders.Delete([]byte("alice"))
+
+	if value, _ := users.Get([]byte("alice")); !bytes.Equal(value, blobValueX()) {
+		t.Errorf("expected the namespace to keep its value")
+	}
+
+	if err := clone.Save(); err != ErrNotFileBacked {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrNotFileBacked)
+	}
+}
+
+func TestCloneConcurrentWrites(t *testing.T) {
+	arc := basicTestTree()
+	clones := []*Arc{arc, arc.Clone(), arc.Clone()}
+	clones = append(clones, clones[1].Clone())
+
+	var wg sync.WaitGroup
+
+	for i, db := range clones {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := range 50 {
+				db.Put([]byte(fmt.Sprintf("key%d", j)), []byte(fmt.Sprintf("db%d", i)))
+				db.Get([]byte("apple"))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	for i, db := range clones {
+		if db.Len() != len(basicTestTreeData())+50 {
+			t.Errorf("unexpected length of database %d: %d", i, db.Len())
+		}
+
+		if value, _ := db.Get([]byte("key0")); string(value) != fmt.Sprintf("db%d", i) {
+			t.Errorf("unexpected value of database %d: %q", i, value)
+		}
+	}
+}
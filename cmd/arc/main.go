@@ -0,0 +1,75 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+// Command arc manages Arc database files.
+//
+// Usage:
+//
+//	arc migrate-bbolt <source> <destination>
+//
+// The migrate-bbolt command loads the records of the bbolt database at the
+// source path into the Arc database at the destination path, which is
+// created if it does not exist. Bucket names become path-style key prefixes,
+// as described in the arcbolt package.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/chronohq/arc"
+	"github.com/chronohq/arc/arcbolt"
+)
+
+const usage = "usage: arc migrate-bbolt <source> <destination>"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "arc: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run executes the command given by the arguments.
+func run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	switch args[0] {
+	case "migrate-bbolt":
+		return migrateBbolt(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
+
+// migrateBbolt loads the bbolt database into the Arc database, and saves it.
+// The records are staged in memory, which leaves the destination untouched if
+// the source cannot be read.
+func migrateBbolt(args []string) error {
+	if len(args) != 2 {
+		return errors.New(usage)
+	}
+
+	staged := arc.New()
+
+	if err := arcbolt.Load(staged, args[0]); err != nil {
+		return err
+	}
+
+	db, err := arc.Open(args[1])
+
+	if err != nil {
+		return err
+	}
+
+	for key, value := range staged.Scan(nil) {
+		if err := db.Put(key, value); err != nil {
+			return errors.Join(err, db.Close())
+		}
+	}
+
+	return db.Close()
+}
@@ -0,0 +1,47 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chronohq/arc/arcbolt"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "bolt.db")
+	dst := filepath.Join(dir, "arc.db")
+
+	if err := os.WriteFile(src, []byte("not a bbolt file"), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no command", nil},
+		{"unknown command", []string{"unknown"}},
+		{"missing arguments", []string{"migrate-bbolt", src}},
+	}
+
+	for _, test := range tests {
+		if err := run(test.args); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+
+	if err := run([]string{"migrate-bbolt", src, dst}); err != arcbolt.ErrInvalidFile {
+		t.Errorf("unexpected error: got:%v, want:%v", err, arcbolt.ErrInvalidFile)
+	}
+
+	if _, err := os.Stat(dst); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unexpected destination: got:%v, want:%v", err, fs.ErrNotExist)
+	}
+}
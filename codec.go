@@ -0,0 +1,103 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec serializes the typed values stored by PutTyped and read by GetTyped.
+// A Codec must be safe for concurrent use.
+type Codec interface {
+	// Marshal returns the encoding of v.
+	Marshal(v any) ([]byte, error)
+
+	// Unmarshal decodes the data into the value pointed to by v.
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	// JSONCodec encodes values using encoding/json. It is the default
+	// Codec, since the values remain readable by other tools.
+	JSONCodec Codec = jsonCodec{}
+
+	// GobCodec encodes values using encoding/gob, which is more compact
+	// than JSON for Go types, but can only be decoded by Go programs.
+	GobCodec Codec = gobCodec{}
+
+	// MsgpackCodec encodes values using MessagePack, which is more compact
+	// than JSON, and can be decoded by other languages. It is implemented
+	// in this package, and supports the common Go types rather than the
+	// whole specification.
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+// jsonCodec implements JSONCodec.
+type jsonCodec struct{}
+
+// Marshal returns the JSON encoding of v.
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes the JSON encoded data into v.
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// gobCodec implements GobCodec.
+type gobCodec struct{}
+
+// Marshal returns the gob encoding of v. Every value is encoded as a
+// self-contained stream, which includes the type information.
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes the gob encoded data into v.
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// PutTyped encodes v using the database Codec, and inserts or updates the
+// record like Put.
+func (a *Arc) PutTyped(key []byte, v any) error {
+	value, err := a.codec().Marshal(v)
+
+	if err != nil {
+		return err
+	}
+
+	return a.Put(key, value)
+}
+
+// GetTyped retrieves the value that matches the given key like Get, and
+// decodes it into the value pointed to by out using the database Codec.
+// Returns ErrKeyNotFound if the key does not exist.
+func (a *Arc) GetTyped(key []byte, out any) error {
+	value, err := a.Get(key)
+
+	if err != nil {
+		return err
+	}
+
+	return a.codec().Unmarshal(value, out)
+}
+
+// codec returns the configured Codec, or JSONCodec if there is none.
+func (a *Arc) codec() Codec {
+	if a.opts.Codec == nil {
+		return JSONCodec
+	}
+
+	return a.opts.Codec
+}
@@ -0,0 +1,56 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type codecTestUser struct {
+	Name  string
+	Email string
+	Roles []string
+}
+
+func TestPutTyped(t *testing.T) {
+	want := codecTestUser{Name: "alice", Email: "alice@example.com", Roles: []string{"admin"}}
+
+	for _, codec := range []Codec{nil, JSONCodec, GobCodec, MsgpackCodec} {
+		arc, _ := NewWithOptions(Options{Codec: codec})
+
+		if err := arc.PutTyped([]byte("user"), want); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var got codecTestUser
+
+		if err := arc.GetTyped([]byte("user"), &got); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected value: got:%+v, want:%+v", got, want)
+		}
+
+		if err := arc.GetTyped([]byte("missing"), &got); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+		}
+	}
+
+	// The default codec stores plain JSON.
+	arc := New()
+	arc.PutTyped([]byte("user"), want)
+	value, _ := arc.Get([]byte("user"))
+
+	if !json.Valid(value) {
+		t.Errorf("expected a JSON value, got:%q", value)
+	}
+
+	if err := arc.PutTyped([]byte("invalid"), make(chan int)); err == nil {
+		t.Errorf("expected an error for an unsupported type")
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// CompactionPolicy configures the background compaction of a file-backed
+// database. The zero value disables background compaction.
+type CompactionPolicy struct {
+	// Interval is how often the database checks whether its file needs to
+	// be compacted. Zero disables background compaction.
+	Interval time.Duration
+
+	// MinSizeRatio is the ratio of the file size to the live data size above
+	// which the file is compacted. For example, 2 compacts the file once at
+	// least half of it is occupied by records and blobs that have since been
+	// deleted.
+	// Zero compacts the file whenever there are unsaved writes.
+	MinSizeRatio float64
+}
+
+// Compact rewrites the database file so that it only contains the live nodes
+// and blobs. Unlike Close, the file is rewritten even when there are no unsaved
+// writes. It also repacks the node keys in memory, which releases the memory
+// of the keys that are no longer in use. Returns ErrNotFileBacked if the
+// database was not opened using Open, and ErrReadOnly if it was opened using
+// OpenReadOnly.
+func (a *Arc) Compact() error {
+	if a.path == "" {
+		return ErrNotFileBacked
+	}
+
+	if a.readOnly {
+		return ErrReadOnly
+	}
+
+	a.saveMu.Lock()
+	defer a.saveMu.Unlock()
+
+	start := time.Now()
+
+	var err error
+
+	profile("compaction", func() {
+		if err = a.save(); err == nil {
+			a.lock()
+			a.compactKeys()
+			a.mu.Unlock()
+		}
+	})
+
+	if err != nil {
+		a.log(slog.LevelWarn, "compaction failed", "path", a.path, "err", err)
+		return err
+	}
+
+	a.log(slog.LevelDebug, "compacted database file", "path", a.path, "duration", time.Since(start))
+
+	return nil
+}
+
+// startCompaction starts the background compaction goroutine, unless it is
+// disabled by the CompactionPolicy.
+func (a *Arc) startCompaction() {
+	if a.opts.Compaction.Interval == 0 {
+		return
+	}
+
+	a.compactStop = make(chan struct{})
+	a.compactDone = make(chan struct{})
+
+	go func() {
+		defer close(a.compactDone)
+
+		ticker := time.NewTicker(a.opts.Compaction.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-a.compactStop:
+				return
+			case <-ticker.C:
+				// Errors are retried on the next tick. Close reports the
+				// failure if it persists.
+				var err error
+
+				profile("compaction", func() {
+					err = a.maybeCompact()
+				})
+
+				if err != nil {
+					a.log(slog.LevelWarn, "background compaction failed", "path", a.path, "err", err)
+				}
+			}
+		}
+	}()
+}
+
+// stopCompaction stops the background compaction goroutine, and waits for it
+// to exit. It is safe to call more than once.
+func (a *Arc) stopCompaction() {
+	if a.compactStop == nil {
+		return
+	}
+
+	select {
+	case <-a.compactStop:
+	default:
+		close(a.compactStop)
+	}
+
+	<-a.compactDone
+}
+
+// maybeCompact compacts the database file if it has unsaved writes, and its
+// size exceeds the live data size by the configured ratio.
+func (a *Arc) maybeCompact() error {
+	a.saveMu.Lock()
+	defer a.saveMu.Unlock()
+
+	a.mu.RLock()
+	dirty := a.dirty()
+	liveSize := a.liveSize()
+	a.mu.RUnlock()
+
+	if !dirty {
+		return nil
+	}
+
+	var fileSize int64
+
+	if ratio := a.opts.Compaction.MinSizeRatio; ratio > 0 {
+		info, err := os.Stat(a.path)
+
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		// A missing file is written regardless of the ratio.
+		if err == nil && float64(info.Size()) < ratio*float64(liveSize) {
+			return nil
+		}
+
+		if err == nil {
+			fileSize = info.Size()
+		}
+	}
+
+	start := time.Now()
+
+	if err := a.save(); err != nil {
+		return err
+	}
+
+	a.log(slog.LevelDebug, "compacted database file in the background", "path", a.path, "file_size", fileSize, "live_size", liveSize, "duration", time.Since(start))
+
+	return nil
+}
+
+// liveSize returns the approximate size of the database file if it were
+// written now. Encryption overhead is not accounted for. The caller must hold
+// the read lock.
+func (a *Arc) liveSize() int64 {
+	header := a.fileHeader()
+	headerLen := int64(header.len())
+
+	// The number of blobs.
+	ret := int64(sizeOfUint64)
+
+	// The expiration section and its trailing offset.
+	ret += sizeOfUint64 + checksumLen + sizeOfUint64
+
+	for key := range a.expirations {
+		ret += int64(sizeOfUint16 + len(key) + sizeOfUint64)
+	}
+
+	// The original key section and its trailing offset.
+	ret += sizeOfUint64 + checksumLen + sizeOfUint64
+
+	// The dictionary section and its trailing offset.
+	ret += int64(sizeOfUint32 + len(a.dictionary) + checksumLen + sizeOfUint64)
+
+	// The tombstone section and its trailing offset.
+	ret += sizeOfUint64 + checksumLen + sizeOfUint64
+
+	for _, ts := range a.tombstones {
+		ret += int64(sizeOfUint16 + len(ts.key) + sizeOfUint64 + sizeOfUint64)
+	}
+
+	// The version section and its trailing offset.
+	ret += sizeOfUint64 + checksumLen + sizeOfUint64
+
+	for key, versions := range a.versions {
+		ret += int64(sizeOfUint16 + len(key) + sizeOfUint16)
+
+		for _, v := range versions {
+			ret += int64(sizeOfUint8 + sizeOfUint32 + len(v.data))
+		}
+	}
+
+	// The timestamp section and its trailing offset.
+	ret += sizeOfUint64 + checksumLen + sizeOfUint64
+
+	for key := range a.timestamps {
+		ret += int64(sizeOfUint16 + len(key) + sizeOfUint64 + sizeOfUint64)
+	}
+
+	for _, key := range a.originalKeys {
+		ret += int64(sizeOfUint16 + len(key))
+	}
+
+	for _, b := range a.blobs {
+		ret += int64(blobRecordOverhead + b.size())
+	}
+
+	var visit func(n *node)
+
+	visit = func(n *node) {
+		ret += int64(serializedNodeLen(n))
+
+		for child := n.firstChild; child != nil; child = child.nextSibling {
+			visit(child)
+		}
+	}
+
+	if a.root != nil {
+		visit(a.root)
+	}
+
+	return headerLen + paginatedLen(ret)
+}
@@ -0,0 +1,124 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompact(t *testing.T) {
+	if err := New().Compact(); err != ErrNotFileBacked {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrNotFileBacked)
+	}
+
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := Open(path)
+
+	for _, key := range sortedBasicTestKeys() {
+		arc.Put([]byte(key), blobValueX())
+		arc.Put([]byte(key+"-unique"), append(bytes.Repeat([]byte("u"), pageLen), key...))
+	}
+
+	arc.Save()
+	before, _ := os.Stat(path)
+
+	for _, key := range sortedBasicTestKeys() {
+		arc.Delete([]byte(key + "-unique"))
+	}
+
+	if err := arc.Compact(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	after, _ := os.Stat(path)
+
+	if after.Size() >= before.Size() {
+		t.Errorf("expected the file to shrink: before:%d, after:%d", before.Size(), after.Size())
+	}
+
+	if after.Size() != arc.liveSize() {
+		t.Errorf("unexpected file size: got:%d, want:%d", after.Size(), arc.liveSize())
+	}
+
+	arc.Close()
+
+	reopened, err := Open(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertSameRecords(t, reopened, arc)
+}
+
+func TestBackgroundCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	opts := Options{Compaction: CompactionPolicy{Interval: time.Millisecond}}
+	arc, err := OpenWithOptions(path, opts)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer arc.Close()
+
+	arc.Put([]byte("key"), []byte("value"))
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		arc.mu.RLock()
+		saved := arc.seq == arc.savedSeq
+		arc.mu.RUnlock()
+
+		if saved {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the background compaction to save the database")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMaybeCompactSizeRatio(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	opts := Options{Compaction: CompactionPolicy{MinSizeRatio: 2}}
+	arc, _ := OpenWithOptions(path, opts)
+
+	for _, key := range sortedBasicTestKeys() {
+		arc.Put([]byte(key), append(bytes.Repeat([]byte("v"), pageLen/4), key...))
+	}
+
+	// The file does not exist yet, so it is written regardless of the ratio.
+	arc.maybeCompact()
+	before, _ := os.Stat(path)
+
+	arc.Delete([]byte(sortedBasicTestKeys()[0]))
+	arc.maybeCompact()
+
+	if after, _ := os.Stat(path); after.Size() != before.Size() {
+		t.Errorf("expected the file to be left as is below the ratio")
+	}
+
+	for _, key := range sortedBasicTestKeys() {
+		arc.Delete([]byte(key))
+	}
+
+	arc.maybeCompact()
+
+	if after, _ := os.Stat(path); after.Size() >= before.Size() {
+		t.Errorf("expected the file to be compacted above the ratio")
+	}
+}
@@ -0,0 +1,421 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ErrInvalidNamespace is returned when a namespace name is empty or exceeds
+// 64KB.
+var ErrInvalidNamespace = errors.New("invalid namespace name")
+
+// containerMagicByte is the first byte of a container file, which tells it
+// apart from a database file.
+const containerMagicByte = byte(0x43)
+
+// Container holds several independent databases, or namespaces, in a single
+// file. Each namespace is a separate tree with its own keyspace, but the
+// namespaces share one blobStore, therefore a value that is stored in several
+// namespaces is only stored once. This avoids juggling a file per keyspace, or
+// emulating the namespaces with key prefixes.
+//
+// The namespaces are persisted by the Save and Close methods of the
+// container, therefore their own Save, Sync, and Compact methods return
+// ErrNotFileBacked, and the CompactionPolicy, SyncPolicy, and
+// BackpressurePolicy have no effect. The namespaces also share a lock, which
+// serializes the writes across the namespaces. The blobs are not compressed,
+// since a dictionary trained by TrainDictionary only covers one namespace.
+type Container struct {
+	path string
+	opts Options
+
+	// Lock and blobStore shared by the namespaces. The lock also guards
+	// the fields below.
+	mu    *sync.RWMutex
+	blobs blobStore
+
+	// Maps the names of the namespaces to their databases.
+	dbs map[string]*Arc
+
+	// Set when a namespace is created, which must be saved even if it has
+	// no records.
+	created bool
+
+	// Write epoch of the container file, which is incremented by every save.
+	epoch uint64
+
+	// Seals the blobs of the container. It holds no records, and its
+	// options are those of the namespaces, except for the dictionary.
+	sealer *Arc
+
+	// Serializes Save and Close.
+	saveMu sync.Mutex
+
+	// Lock file that excludes other processes from the container file.
+	lockFile *os.File
+}
+
+// OpenContainer opens the container file at the given path with the default
+// options. An empty container is returned if the file does not exist, in
+// which case the file is created on the first Save.
+func OpenContainer(path string) (*Container, error) {
+	return OpenContainerWithOptions(path, Options{})
+}
+
+// OpenContainerWithOptions is like OpenContainer, but configures every
+// namespace with the given options. Returns ErrDatabaseLocked if the file
+// remains open in another process for longer than the LockTimeout.
+func OpenContainerWithOptions(path string, opts Options) (*Container, error) {
+	if opts.Tiering.Path != "" {
+		return nil, ErrInvalidOptions
+	}
+
+	sealer, err := newArc(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &Container{
+		path:   path,
+		opts:   opts,
+		mu:     sealer.mu,
+		blobs:  sealer.blobs,
+		dbs:    map[string]*Arc{},
+		sealer: sealer,
+	}
+
+	if ret.lockFile, err = acquireFileLock(path, true, opts.LockTimeout); err != nil {
+		return nil, err
+	}
+
+	src, err := os.ReadFile(path)
+
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		ret.lockFile.Close()
+		return nil, err
+	}
+
+	if err == nil {
+		profile("load", func() {
+			err = ret.readSnapshot(src)
+		})
+
+		if err != nil {
+			sealer.log(slog.LevelWarn, "failed to load container file", "path", path, "size", len(src), "err", err)
+			ret.lockFile.Close()
+			return nil, err
+		}
+
+		sealer.log(slog.LevelDebug, "loaded container file", "path", path, "size", len(src), "namespaces", len(ret.dbs))
+	}
+
+	for _, db := range ret.dbs {
+		db.startSweeper()
+	}
+
+	return ret, nil
+}
+
+// DB returns the namespace with the given name, which is created if it does
+// not exist. Returns ErrInvalidNamespace if the name is empty or exceeds 64KB.
+func (c *Container) DB(name string) (*Arc, error) {
+	if len(name) == 0 || len(name) > maxUint16 {
+		return nil, ErrInvalidNamespace
+	}
+
+	c.mu.Lock()
+
+	if db, found := c.dbs[name]; found {
+		c.mu.Unlock()
+		return db, nil
+	}
+
+	db := c.newNamespace()
+	c.dbs[name] = db
+	c.created = true
+	c.mu.Unlock()
+
+	db.startSweeper()
+
+	return db, nil
+}
+
+// Names returns the names of the namespaces in lexicographic order.
+func (c *Container) Names() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ret := make([]string, 0, len(c.dbs))
+
+	for name := range c.dbs {
+		ret = append(ret, name)
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
+
+// Save writes every namespace to the container file. Like Arc.Save, the file
+// is replaced atomically.
+func (c *Container) Save() error {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	return c.save()
+}
+
+// Close stops the background work of the namespaces, saves the container if
+// it has unsaved writes, and releases the file lock. The namespaces must not
+// be used afterwards.
+func (c *Container) Close() error {
+	var err error
+
+	c.mu.RLock()
+	dbs := make([]*Arc, 0, len(c.dbs))
+
+	for _, db := range c.dbs {
+		dbs = append(dbs, db)
+	}
+
+	c.mu.RUnlock()
+
+	for _, db := range dbs {
+		err = errors.Join(err, db.Close())
+	}
+
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.mu.RLock()
+	dirty := c.dirty()
+	c.mu.RUnlock()
+
+	if dirty {
+		err = errors.Join(err, c.save())
+	}
+
+	return errors.Join(err, c.lockFile.Close())
+}
+
+// newNamespace returns an empty namespace that shares the lock and the
+// blobStore of the container. Its options were already validated by the
+// container.
+func (c *Container) newNamespace() *Arc {
+	ret, _ := newArc(c.opts)
+	ret.mu = c.mu
+	ret.blobs = c.blobs
+	ret.container = c
+
+	return ret
+}
+
+// dirty returns true if a namespace has unsaved changes, or was created since
+// the last save. The caller must hold the read lock.
+func (c *Container) dirty() bool {
+	if c.created {
+		return true
+	}
+
+	for _, db := range c.dbs {
+		if db.dirty() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// save serializes the container and atomically replaces its file. The caller
+// must hold saveMu.
+func (c *Container) save() error {
+	var buf bytes.Buffer
+
+	c.mu.RLock()
+	seqs := make(map[*Arc][2]uint64, len(c.dbs))
+
+	for _, db := range c.dbs {
+		seqs[db] = [2]uint64{db.seq, db.metaSeq}
+	}
+
+	now := c.sealer.now()
+	err := c.writeSnapshot(&buf)
+	c.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(c.path, buf.Bytes()); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for db, seq := range seqs {
+		db.savedSeq = seq[0]
+		db.savedMetaSeq = seq[1]
+		db.purgeTombstones(now)
+	}
+
+	c.created = false
+	c.epoch++
+
+	return nil
+}
+
+// writeSnapshot serializes the container, which consists of a header in the
+// database file format, except for the magic byte, followed by the body,
+// which is split into pages like the body of a database file. The body holds
+// the blob section of the shared blobs, the number of namespaces, and the
+// namespaces in name order. Each namespace consists of the length of its
+// name, the name, the length of its sections, and the sections that follow
+// the header of a database file, except that the blob section is empty. The
+// offsets of a namespace are relative to the start of its sections. The
+// caller must hold the read lock.
+func (c *Container) writeSnapshot(w *bytes.Buffer) error {
+	header := newArcHeader()
+	header.magic = containerMagicByte
+	header.keyTransform = c.opts.keyTransformName()
+	headerBytes, err := header.serialize()
+
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+
+	if _, err := c.sealer.writeBlobs(&body, c.blobs); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(c.dbs))
+
+	for name := range c.dbs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	binary.Write(&body, binary.LittleEndian, uint64(len(names)))
+
+	for _, name := range names {
+		var sections bytes.Buffer
+
+		if err := c.dbs[name].writeBody(&sections, 0, false); err != nil {
+			return err
+		}
+
+		binary.Write(&body, binary.LittleEndian, uint16(len(name)))
+		body.WriteString(name)
+		binary.Write(&body, binary.LittleEndian, uint64(sections.Len()))
+		body.Write(sections.Bytes())
+	}
+
+	pages, err := paginate(body.Bytes(), c.epoch+1)
+
+	if err != nil {
+		return err
+	}
+
+	w.Write(headerBytes)
+	w.Write(pages)
+
+	return nil
+}
+
+// readSnapshot loads the serialized container produced by writeSnapshot into
+// the receiver, which must be empty.
+func (c *Container) readSnapshot(src []byte) error {
+	header, err := newArcHeaderFromBytes(src)
+
+	if err != nil {
+		return err
+	}
+
+	if header.magic != containerMagicByte {
+		return ErrCorrupted
+	}
+
+	if header.version != fileFormatVersion {
+		return ErrUnsupportedVersion
+	}
+
+	if err := c.opts.checkTransformName(header.keyTransform); err != nil {
+		return err
+	}
+
+	body, epoch, err := unpaginate(nil, src[header.len():])
+
+	if err != nil {
+		return err
+	}
+
+	contents := map[blobID][]byte{}
+	pos, err := c.sealer.readBlobs(body, contents)
+
+	if err != nil {
+		return err
+	}
+
+	if len(body) < pos+sizeOfUint64 {
+		return ErrCorrupted
+	}
+
+	numNamespaces := binary.LittleEndian.Uint64(body[pos:])
+	pos += sizeOfUint64
+
+	for i := uint64(0); i < numNamespaces; i++ {
+		if len(body) < pos+sizeOfUint16 {
+			return ErrCorrupted
+		}
+
+		nameLen := int(binary.LittleEndian.Uint16(body[pos:]))
+		pos += sizeOfUint16
+
+		if nameLen == 0 || len(body) < pos+nameLen+sizeOfUint64 {
+			return ErrCorrupted
+		}
+
+		name := string(body[pos : pos+nameLen])
+		pos += nameLen
+
+		if _, found := c.dbs[name]; found {
+			return ErrCorrupted
+		}
+
+		sectionsLen := binary.LittleEndian.Uint64(body[pos:])
+		pos += sizeOfUint64
+
+		if sectionsLen > uint64(len(body)-pos) {
+			return ErrCorrupted
+		}
+
+		db := c.newNamespace()
+
+		if err := db.readBody(body[pos:pos+int(sectionsLen)], 0, contents); err != nil {
+			return err
+		}
+
+		c.dbs[name] = db
+		pos += int(sectionsLen)
+	}
+
+	if pos != len(body) {
+		return ErrCorrupted
+	}
+
+	c.epoch = epoch
+
+	return nil
+}
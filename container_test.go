@@ -0,0 +1,91 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestContainer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arcc")
+	shared := bytes.Repeat([]byte("x"), 100)
+
+	c, err := OpenContainer(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	users, _ := c.DB("users")
+	orders, _ := c.DB("orders")
+	c.DB("empty")
+
+	users.Put([]byte("alice"), shared)
+	users.Put([]byte("bob"), []byte("small"))
+	orders.Put([]byte("alice"), shared)
+
+	// The keyspaces are independent.
+	if _, err := orders.Get([]byte("bob")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+
+	// The value is stored once across the namespaces.
+	if len(c.blobs) != 1 || c.blobs[makeBlobID(shared)].refCount != 2 {
+		t.Fatalf("expected the blob to be shared")
+	}
+
+	if err := users.Save(); err != ErrNotFileBacked {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrNotFileBacked)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := Open(path); err != ErrCorrupted {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrCorrupted)
+	}
+
+	c, err = OpenContainer(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer c.Close()
+
+	if want := []string{"empty", "orders", "users"}; !slices.Equal(c.Names(), want) {
+		t.Errorf("unexpected names: got:%q, want:%q", c.Names(), want)
+	}
+
+	users, _ = c.DB("users")
+	orders, _ = c.DB("orders")
+
+	if got, _ := users.Get([]byte("bob")); string(got) != "small" {
+		t.Errorf("unexpected value: got:%q, want:%q", got, "small")
+	}
+
+	if got, _ := orders.Get([]byte("alice")); !bytes.Equal(got, shared) {
+		t.Errorf("unexpected value: got:%q, want:%q", got, shared)
+	}
+
+	if c.blobs[makeBlobID(shared)].refCount != 2 {
+		t.Errorf("unexpected reference count: %d", c.blobs[makeBlobID(shared)].refCount)
+	}
+
+	// Deleting the last record of a namespace keeps the shared blobs.
+	orders.Delete([]byte("alice"))
+
+	if got, _ := users.Get([]byte("alice")); !bytes.Equal(got, shared) {
+		t.Errorf("unexpected value: got:%q, want:%q", got, shared)
+	}
+
+	if _, err := c.DB(""); err != ErrInvalidNamespace {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrInvalidNamespace)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+// CountPrefix returns the number of records whose keys begin with the given
+// prefix. A nil prefix counts every record. Expired records are not counted.
+// The count is computed in O(depth) time when Options.TrackPrefixCounts is
+// enabled, and by walking the subtree otherwise, and the expired records are
+// subtracted in time proportional to the number of records that expire.
+func (a *Arc) CountPrefix(prefix []byte) (int, error) {
+	prefix = a.transformKey(prefix)
+
+	if len(prefix) > a.opts.MaxKeyBytes {
+		return 0, &SizeError{Err: ErrKeyTooLarge, Size: len(prefix), Limit: a.opts.MaxKeyBytes}
+	}
+
+	a.rlock()
+	defer a.runlock()
+
+	return a.countPrefix(prefix) - a.countExpired(prefix), nil
+}
+
+// countPrefix returns the number of records whose keys begin with the given
+// prefix, including the expired records. The caller must hold the read lock.
+func (a *Arc) countPrefix(prefix []byte) int {
+	if len(prefix) == 0 {
+		return a.numRecords
+	}
+
+	n, _ := a.findPrefixNode(prefix)
+
+	if n == nil {
+		return 0
+	}
+
+	if a.opts.TrackPrefixCounts {
+		return int(n.subtreeRecords)
+	}
+
+	var ret int
+
+	walkNode(n, nil, func(_ []byte, _ *node) bool {
+		ret++
+		return true
+	})
+
+	return ret
+}
+
+// refreshSubtreeRecords recomputes the subtree record counts of the nodes on
+// the path to the given key, from the bottom up. Only the subtrees that hold
+// the key can be affected by writing it. It is a no-op unless the
+// TrackPrefixCounts option is enabled.
+func (a *Arc) refreshSubtreeRecords(key []byte) {
+	if !a.opts.TrackPrefixCounts {
+		return
+	}
+
+	path := a.nodePath(key)
+
+	for i := len(path) - 1; i >= 0; i-- {
+		path[i].subtreeRecords = path[i].countSubtreeRecords()
+	}
+}
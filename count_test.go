@@ -0,0 +1,92 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestCountPrefix(t *testing.T) {
+	testCases := []struct {
+		prefix string
+		want   int
+	}{
+		{"", 17},
+		{"ap", 4},
+		{"appl", 3},
+		{"b", 6},
+		{"band", 3},
+		{"lemon", 2},
+		{"orange", 1},
+		{"oranges", 0},
+		{"bogus", 0},
+	}
+
+	for _, track := range []bool{false, true} {
+		arc, _ := NewWithOptions(Options{TrackPrefixCounts: track})
+
+		for _, row := range basicTestTreeData() {
+			arc.Put(row.key, row.data)
+		}
+
+		for _, tc := range testCases {
+			got, err := arc.CountPrefix([]byte(tc.prefix))
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tc.want {
+				t.Errorf("unexpected count for %q (track:%v): got:%d, want:%d", tc.prefix, track, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestSubtreeRecordsAfterMutations(t *testing.T) {
+	arc, _ := NewWithOptions(Options{TrackPrefixCounts: true})
+	rng := rand.New(rand.NewSource(1))
+
+	var keys [][]byte
+
+	for i := 0; i < 2000; i++ {
+		key := randomKey(rng, "abc", 5)
+
+		// Delete roughly a third of the time to exercise node merging.
+		if len(keys) > 0 && rng.Intn(3) == 0 {
+			victim := rng.Intn(len(keys))
+			arc.Delete(keys[victim])
+			keys = append(keys[:victim], keys[victim+1:]...)
+		} else if arc.Add(key, nil) == nil {
+			keys = append(keys, key)
+		}
+
+		if arc.root != nil {
+			assertSubtreeRecords(t, arc.root)
+		}
+	}
+}
+
+// assertSubtreeRecords verifies the tracked count of every node in the tree
+// rooted at n, and returns the actual count of n.
+func assertSubtreeRecords(t *testing.T, n *node) uint32 {
+	t.Helper()
+
+	var want uint32
+
+	if n.isRecord {
+		want = 1
+	}
+
+	for child := n.firstChild; child != nil; child = child.nextSibling {
+		want += assertSubtreeRecords(t, child)
+	}
+
+	if n.subtreeRecords != want {
+		t.Fatalf("unexpected subtree count of %q: got:%d, want:%d", n.key, n.subtreeRecords, want)
+	}
+
+	return want
+}
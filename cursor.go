@@ -0,0 +1,143 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import "sort"
+
+// Cursor traverses the records of a point-in-time snapshot of the database in
+// the order of the database, and can be positioned at an arbitrary key using Seek.
+// Writes made after the cursor is created are not visible to it. A Cursor is
+// not safe for concurrent use.
+//
+// A new cursor is unpositioned: calling Next moves it to the first record, and
+// calling Prev moves it to the last record.
+type Cursor struct {
+	records    []record
+	pos        int
+	positioned bool
+	transform  func(key []byte) []byte // Applies the database KeyTransform.
+	compare    func(x, y []byte) int   // Compares keys in the order of the database.
+}
+
+// Cursor returns a cursor over a snapshot of the records whose keys begin with
+// the given prefix. A nil prefix covers the entire database. Creating the
+// snapshot copies the keys, while the values are shared with the tree until
+// they are read.
+func (a *Arc) Cursor(prefix []byte) *Cursor {
+	a.rlock()
+	defer a.runlock()
+
+	ret := &Cursor{transform: a.transformKey, compare: a.compareKeys}
+	walk := a.walkPrefix
+
+	if a.opts.ReverseOrder {
+		walk = a.walkPrefixReverse
+	}
+
+	walk(a.transformKey(prefix), func(key []byte, n *node) bool {
+		if !a.visible(key) {
+			return true
+		}
+
+		ret.records = append(ret.records, record{key: a.originalKey(key), value: n.rawValue(a.blobs), flags: n.userFlags})
+		return true
+	})
+
+	return ret
+}
+
+// First moves the cursor to the first record. It returns false if the snapshot
+// is empty.
+func (c *Cursor) First() bool {
+	return c.moveTo(0)
+}
+
+// Last moves the cursor to the last record. It returns false if the snapshot
+// is empty.
+func (c *Cursor) Last() bool {
+	return c.moveTo(len(c.records) - 1)
+}
+
+// Seek moves the cursor to the first record whose key is greater than or equal
+// to the given key in the order of the database. It returns false if no such
+// record exists.
+func (c *Cursor) Seek(key []byte) bool {
+	key = c.transform(key)
+
+	pos := sort.Search(len(c.records), func(i int) bool {
+		return c.compare(c.records[i].key, key) >= 0
+	})
+
+	return c.moveTo(pos)
+}
+
+// Next moves the cursor to the next record. It returns false if the cursor
+// moved past the last record.
+func (c *Cursor) Next() bool {
+	if !c.positioned {
+		return c.First()
+	}
+
+	return c.moveTo(min(c.pos+1, len(c.records)))
+}
+
+// Prev moves the cursor to the previous record. It returns false if the
+// cursor moved past the first record.
+func (c *Cursor) Prev() bool {
+	if !c.positioned {
+		return c.Last()
+	}
+
+	return c.moveTo(max(c.pos-1, -1))
+}
+
+// Valid returns true if the cursor is positioned at a record.
+func (c *Cursor) Valid() bool {
+	return c.positioned && c.pos >= 0 && c.pos < len(c.records)
+}
+
+// Key returns the key of the current record, or nil if the cursor is not
+// positioned at a record. The returned slice must not be modified.
+func (c *Cursor) Key() []byte {
+	if !c.Valid() {
+		return nil
+	}
+
+	return c.records[c.pos].key
+}
+
+// Value returns a copy of the value of the current record, or nil if the
+// cursor is not positioned at a record.
+func (c *Cursor) Value() []byte {
+	if !c.Valid() {
+		return nil
+	}
+
+	value := c.records[c.pos].value
+
+	if value == nil {
+		return nil
+	}
+
+	return append([]byte{}, value...)
+}
+
+// Flags returns the user flags of the current record, or zero if the cursor is
+// not positioned at a record.
+func (c *Cursor) Flags() uint8 {
+	if !c.Valid() {
+		return 0
+	}
+
+	return c.records[c.pos].flags
+}
+
+// moveTo positions the cursor at the given index, and reports whether the
+// index points at a record.
+func (c *Cursor) moveTo(pos int) bool {
+	c.pos = pos
+	c.positioned = true
+
+	return c.Valid()
+}
@@ -0,0 +1,104 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	arc := basicTestTree()
+	c := arc.Cursor(nil)
+
+	var got []string
+
+	for c.Next() {
+		got = append(got, string(c.Key()))
+	}
+
+	assertKeys(t, got, sortedBasicTestKeys())
+
+	// Moving past the end invalidates the cursor, and Prev returns to the
+	// last record.
+	if c.Valid() || c.Key() != nil || c.Value() != nil {
+		t.Errorf("expected the cursor to be invalid")
+	}
+
+	if !c.Prev() || string(c.Key()) != "orange" {
+		t.Errorf("unexpected key: got:%q, want:%q", c.Key(), "orange")
+	}
+
+	got = nil
+
+	for ok := c.Last(); ok; ok = c.Prev() {
+		got = append(got, string(c.Key()))
+	}
+
+	assertKeys(t, got, reversed(sortedBasicTestKeys()))
+}
+
+func TestCursorSeek(t *testing.T) {
+	arc := basicTestTree()
+	c := arc.Cursor(nil)
+
+	testCases := []struct {
+		seek  string
+		key   string
+		value string
+	}{
+		{seek: "", key: "apple", value: "cider"},
+		{seek: "band", key: "band", value: "practice"},
+		{seek: "bandb", key: "bandsaw", value: "cut"},
+		{seek: "m", key: "orange", value: "juice"},
+		{seek: "z", key: "", value: ""},
+	}
+
+	for _, tc := range testCases {
+		ok := c.Seek([]byte(tc.seek))
+
+		if ok != (tc.key != "") {
+			t.Fatalf("unexpected Seek(%q) result: got:%v", tc.seek, ok)
+		}
+
+		if string(c.Key()) != tc.key || string(c.Value()) != tc.value {
+			t.Errorf("unexpected record: got:%q=%q, want:%q=%q", c.Key(), c.Value(), tc.key, tc.value)
+		}
+	}
+
+	c.Seek([]byte("bandb"))
+
+	if !c.Prev() || string(c.Key()) != "bandage" {
+		t.Errorf("unexpected key: got:%q, want:%q", c.Key(), "bandage")
+	}
+}
+
+func TestCursorSnapshot(t *testing.T) {
+	arc := New()
+	arc.Put([]byte("apple"), []byte("1"))
+	arc.Put([]byte("apricot"), blobValueX())
+
+	c := arc.Cursor([]byte("ap"))
+
+	// Writes after the cursor creation must not be visible.
+	arc.Put([]byte("apple"), []byte("2"))
+	arc.Delete([]byte("apricot"))
+	arc.Put([]byte("apex"), []byte("3"))
+
+	var got []string
+
+	for c.Next() {
+		got = append(got, string(c.Key()))
+	}
+
+	assertKeys(t, got, []string{"apple", "apricot"})
+
+	if c.Seek([]byte("apple")); !bytes.Equal(c.Value(), []byte("1")) {
+		t.Errorf("unexpected value: got:%q, want:%q", c.Value(), "1")
+	}
+
+	if c.Seek([]byte("apricot")); !bytes.Equal(c.Value(), blobValueX()) {
+		t.Errorf("unexpected value: got:%q, want:%q", c.Value(), blobValueX())
+	}
+}
@@ -8,8 +8,8 @@ import "fmt"
 // DebugPrint prints the Arc index structure in a directory tree format.
 // Use this function only for development and debugging purposes.
 func (a *Arc) DebugPrint() {
-	a.mu.RLock()
-	defer a.mu.RUnlock()
+	a.rlock()
+	defer a.runlock()
 
 	if a.Len() == 1 {
 		fmt.Printf("%s (%q)\n", string(a.root.key), a.root.data)
@@ -0,0 +1,248 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"runtime"
+	"sync"
+)
+
+// parallelDecodeBytes is the size of the node section that a single goroutine
+// decodes when the section is decoded concurrently. Node sections that are
+// smaller than twice the size are decoded sequentially. It is a variable so
+// that the tests can exercise the concurrent decoder on small databases.
+var parallelDecodeBytes = 1 << 20
+
+// decodeJob is a run of sibling subtrees that a single goroutine decodes.
+type decodeJob struct {
+	offsets    []uint64 // Offsets of the subtree roots.
+	minOffsets []uint64 // Minimum offsets of the subtree roots.
+	slots      []**node // Destinations of the decoded subtree roots.
+	decoder    snapshotDecoder
+	err        error
+}
+
+// pendingNode is a node whose children are decoded by other jobs, and that is
+// linked to them once every job is done.
+type pendingNode struct {
+	node     *node
+	pn       persistentNode
+	children []*node
+}
+
+// decodeTree decodes the node section that begins at the given offset, and
+// returns the root node. Large sections are split into independent subtrees
+// using the stored child and sibling offsets, which are decoded across
+// goroutines and then linked together.
+func (d *snapshotDecoder) decodeTree(offset uint64) (*node, error) {
+	numWorkers := runtime.GOMAXPROCS(0)
+
+	if numWorkers < 2 || uint64(len(d.src))-offset < 2*uint64(parallelDecodeBytes) {
+		root, _, err := d.decodeNode(offset, offset)
+		return root, err
+	}
+
+	s := treeSplitter{decoder: d}
+	root, err := s.split(offset, offset, uint64(len(d.src)))
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.flush()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, numWorkers)
+
+	for _, job := range s.jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			job.run()
+		}()
+	}
+
+	wg.Wait()
+
+	// The jobs are in pre-order, therefore the first error is the one that
+	// the sequential decoder reports.
+	for _, job := range s.jobs {
+		if job.err != nil {
+			return nil, job.err
+		}
+
+		d.merge(&job.decoder)
+	}
+
+	// Children are linked before their parents, which allows the parents to
+	// derive fields from complete subtrees.
+	for i := len(s.pending) - 1; i >= 0; i-- {
+		p := s.pending[i]
+
+		for j, child := range p.children {
+			if j == 0 {
+				p.node.firstChild = child
+			} else {
+				p.children[j-1].nextSibling = child
+			}
+		}
+
+		p.node.numChildren = len(p.children)
+
+		if err := d.finishNode(p.node, p.pn); err != nil {
+			return nil, err
+		}
+	}
+
+	return root, nil
+}
+
+// run decodes the subtrees of the job.
+func (j *decodeJob) run() {
+	for i, offset := range j.offsets {
+		n, _, err := j.decoder.decodeNode(offset, j.minOffsets[i])
+
+		if err != nil {
+			j.err = err
+			return
+		}
+
+		*j.slots[i] = n
+	}
+}
+
+// merge adds the nodes, records and blob references that another decoder has
+// decoded to the decoder.
+func (d *snapshotDecoder) merge(other *snapshotDecoder) {
+	d.numNodes += other.numNodes
+	d.numRecords += other.numRecords
+
+	for id, b := range other.blobs {
+		if existing, found := d.blobs[id]; found {
+			existing.refCount += b.refCount
+		} else {
+			d.blobs[id] = b
+		}
+	}
+}
+
+// treeSplitter splits the node section into jobs of about
+// parallelDecodeBytes each.
+type treeSplitter struct {
+	decoder *snapshotDecoder
+	jobs    []*decodeJob
+	pending []*pendingNode
+	current *decodeJob // Job that small subtrees are added to.
+	size    uint64     // Number of bytes in the current job.
+}
+
+// split decodes the node at the given offset, whose subtree ends at end, and
+// splits its children into jobs. Children whose subtrees are large are split
+// further.
+func (s *treeSplitter) split(offset uint64, minOffset uint64, end uint64) (*node, error) {
+	pn, nodeLen, err := s.decoder.readNode(offset, minOffset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := s.decoder.makeNode(pn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pendingNode{node: ret, pn: pn}
+	s.pending = append(s.pending, p)
+
+	// The jobs write the decoded children into their slots, therefore the
+	// slice is allocated at its final length.
+	var offsets, minOffsets []uint64
+	nextMin := offset + uint64(nodeLen)
+
+	for childOffset := pn.firstChildOffset; childOffset != 0; {
+		child, _, err := s.decoder.readNode(childOffset, nextMin)
+
+		if err != nil {
+			return nil, err
+		}
+
+		offsets = append(offsets, childOffset)
+		minOffsets = append(minOffsets, nextMin)
+		nextMin = childOffset + 1
+		childOffset = child.nextSiblingOffset
+	}
+
+	p.children = make([]*node, len(offsets))
+
+	for i, childOffset := range offsets {
+		// Pre-order places the subtree of a child before its next sibling,
+		// and the subtree of the last child before the end of the parent.
+		childEnd := end
+
+		if i+1 < len(offsets) {
+			childEnd = offsets[i+1]
+		}
+
+		// Corrupted offsets may produce spans that are out of order. Those
+		// subtrees are left to the jobs, which detect the corruption.
+		if childEnd > childOffset && childEnd-childOffset >= uint64(parallelDecodeBytes) {
+			s.flush()
+
+			child, err := s.split(childOffset, minOffsets[i], childEnd)
+
+			if err != nil {
+				return nil, err
+			}
+
+			p.children[i] = child
+			continue
+		}
+
+		s.add(childOffset, minOffsets[i], &p.children[i])
+
+		if childEnd > childOffset {
+			s.size += childEnd - childOffset
+		}
+
+		if s.size >= uint64(parallelDecodeBytes) {
+			s.flush()
+		}
+	}
+
+	return ret, nil
+}
+
+// add adds the subtree at the given offset to the current job.
+func (s *treeSplitter) add(offset uint64, minOffset uint64, slot **node) {
+	if s.current == nil {
+		s.current = &decodeJob{
+			decoder: snapshotDecoder{
+				arc:      s.decoder.arc,
+				src:      s.decoder.src,
+				contents: s.decoder.contents,
+				blobs:    blobStore{},
+			},
+		}
+	}
+
+	s.current.offsets = append(s.current.offsets, offset)
+	s.current.minOffsets = append(s.current.minOffsets, minOffset)
+	s.current.slots = append(s.current.slots, slot)
+}
+
+// flush closes the current job, if any.
+func (s *treeSplitter) flush() {
+	if s.current != nil {
+		s.jobs = append(s.jobs, s.current)
+		s.current = nil
+		s.size = 0
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestOpenConcurrentDecode(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	threshold := parallelDecodeBytes
+	parallelDecodeBytes = 512
+	defer func() { parallelDecodeBytes = threshold }()
+
+	path := filepath.Join(t.TempDir(), "test.arc")
+	opts := Options{TrackPrefixCounts: true}
+	arc, _ := OpenWithOptions(path, opts)
+
+	// Subtrees of varying sizes, which are split at different depths.
+	for i := range 20 {
+		for j := range i * 10 {
+			arc.Put([]byte(fmt.Sprintf("group%02d/key%03d", i, j)), []byte(fmt.Sprintf("value%d", j)))
+		}
+	}
+
+	for i := range 20 {
+		arc.Put([]byte(fmt.Sprintf("group%02d/blob", i)), blobValueX())
+	}
+
+	arc.Put([]byte("group"), []byte("root"))
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := OpenWithOptions(path, opts)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertSameRecords(t, reopened, arc)
+
+	if reopened.numNodes != arc.numNodes || reopened.numRecords != arc.numRecords {
+		t.Errorf("unexpected counts: nodes:%d, records:%d", reopened.numNodes, reopened.numRecords)
+	}
+
+	if got := reopened.blobs[makeBlobID(blobValueX())].refCount; got != 20 {
+		t.Errorf("unexpected refCount: got:%d, want:%d", got, 20)
+	}
+
+	for _, prefix := range []string{"", "group", "group1", "group19/", "group05/key04"} {
+		got, _ := reopened.CountPrefix([]byte(prefix))
+		want, _ := arc.CountPrefix([]byte(prefix))
+
+		if got != want {
+			t.Errorf("unexpected count of %q: got:%d, want:%d", prefix, got, want)
+		}
+	}
+}
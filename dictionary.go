@@ -0,0 +1,169 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+)
+
+// maxDictionaryLen is the maximum length of a compression dictionary, which is
+// the size of the DEFLATE window. Longer dictionaries would not be used.
+const maxDictionaryLen = 32 << 10
+
+// TrainDictionary builds a compression dictionary from up to sample values,
+// which is then used to compress the blob values when the database is
+// persisted. Datasets with many similar values, such as JSON documents, share
+// most of their structure with the dictionary, which compresses them far
+// better than compressing each value on its own. The blobs are sampled at
+// random, and contribute their leading bytes to the dictionary. A sample of
+// zero or less discards the dictionary, which disables compression. The
+// dictionary is persisted along with the database, and takes effect on the
+// next Save. Inline values are never compressed, since they are too small to
+// benefit. Returns ErrReadOnly if the database is read-only.
+func (a *Arc) TrainDictionary(sample int) error {
+	if a.readOnly {
+		return ErrReadOnly
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.dictionary = nil
+	a.metaSeq++
+
+	if sample <= 0 || len(a.blobs) == 0 {
+		return nil
+	}
+
+	// Map iteration order is random, which yields a random sample.
+	var samples [][]byte
+
+	for _, b := range a.blobs {
+		if len(samples) == sample {
+			break
+		}
+
+		value, err := b.load()
+
+		if err != nil {
+			return err
+		}
+
+		samples = append(samples, value)
+	}
+
+	share := max(maxDictionaryLen/len(samples), 1)
+	dict := make([]byte, 0, maxDictionaryLen)
+
+	for _, value := range samples {
+		if len(dict) == maxDictionaryLen {
+			break
+		}
+
+		n := min(len(value), share, maxDictionaryLen-len(dict))
+		dict = append(dict, value[:n]...)
+	}
+
+	a.dictionary = dict
+
+	return nil
+}
+
+// compressBlob compresses the blob value using the dictionary. The value is
+// returned as is if there is no dictionary. The caller must hold the read
+// lock.
+func (a *Arc) compressBlob(value []byte) ([]byte, error) {
+	if len(a.dictionary) == 0 {
+		return value, nil
+	}
+
+	var buf bytes.Buffer
+
+	// Lower levels fail to find matches in the dictionary for small values,
+	// which are the main beneficiaries of the dictionary.
+	w, err := flate.NewWriterDict(&buf, flate.BestCompression, a.dictionary)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(value); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decompressBlob decompresses the blob value compressed by compressBlob. It
+// returns ErrCorrupted if the value cannot be decompressed.
+func (a *Arc) decompressBlob(compressed []byte) ([]byte, error) {
+	if len(a.dictionary) == 0 {
+		return compressed, nil
+	}
+
+	r := flate.NewReaderDict(bytes.NewReader(compressed), a.dictionary)
+	defer r.Close()
+
+	ret, err := io.ReadAll(io.LimitReader(r, maxValueBytes+1))
+
+	if err != nil || len(ret) > maxValueBytes {
+		return nil, ErrCorrupted
+	}
+
+	return ret, nil
+}
+
+// serializeDictionary serializes the dictionary section, which consists of
+// the length of the dictionary, the dictionary, and the checksum of the
+// preceding bytes. The caller must hold the read lock.
+func (a *Arc) serializeDictionary() ([]byte, error) {
+	ret := binary.LittleEndian.AppendUint32(nil, uint32(len(a.dictionary)))
+	ret = append(ret, a.dictionary...)
+
+	checksum, err := computeChecksum(ret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return binary.LittleEndian.AppendUint32(ret, checksum), nil
+}
+
+// readDictionary loads the dictionary section produced by
+// serializeDictionary. It must be loaded before the blobs.
+func (a *Arc) readDictionary(src []byte) error {
+	if len(src) < sizeOfUint32+checksumLen {
+		return ErrCorrupted
+	}
+
+	checksumPos := len(src) - checksumLen
+	checksum, err := computeChecksum(src[:checksumPos])
+
+	if err != nil {
+		return err
+	}
+
+	if checksum != binary.LittleEndian.Uint32(src[checksumPos:]) {
+		return ErrInvalidChecksum
+	}
+
+	dictLen := int(binary.LittleEndian.Uint32(src))
+
+	if dictLen != checksumPos-sizeOfUint32 || dictLen > maxDictionaryLen {
+		return ErrCorrupted
+	}
+
+	if dictLen > 0 {
+		a.dictionary = bytes.Clone(src[sizeOfUint32:checksumPos])
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrainDictionary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := Open(path)
+
+	for i := range 200 {
+		key := fmt.Sprintf("user:%03d", i)
+		value := fmt.Sprintf(`{"id":%d,"name":"user %d","email":"user%d@example.com","active":true,"roles":["reader"]}`, i, i, i)
+		arc.Put([]byte(key), []byte(value))
+	}
+
+	if err := arc.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	uncompressed, _ := os.Stat(path)
+
+	if err := arc.TrainDictionary(20); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Training the dictionary is an unsaved change on its own.
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	compressed, _ := os.Stat(path)
+
+	if compressed.Size() >= uncompressed.Size()*3/4 {
+		t.Errorf("expected the file to shrink: got:%d, uncompressed:%d", compressed.Size(), uncompressed.Size())
+	}
+
+	reopened, err := Open(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(reopened.dictionary) == 0 {
+		t.Errorf("expected the dictionary to be loaded")
+	}
+
+	assertSameRecords(t, reopened, arc)
+
+	// Discarding the dictionary disables compression.
+	reopened.TrainDictionary(0)
+	reopened.Close()
+
+	if restored, _ := os.Stat(path); restored.Size() != uncompressed.Size() {
+		t.Errorf("unexpected size: got:%d, want:%d", restored.Size(), uncompressed.Size())
+	}
+}
+
+func TestTrainDictionaryWithEncryption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	provider, _ := NewAESGCMProvider(map[uint32][]byte{1: make([]byte, 32)}, 1)
+	opts := Options{Encryption: provider}
+	arc, _ := OpenWithOptions(path, opts)
+
+	arc.Put([]byte("a"), blobValueX())
+	arc.TrainDictionary(1)
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := OpenWithOptions(path, opts)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer reopened.Close()
+
+	assertSameRecords(t, reopened, arc)
+}
@@ -0,0 +1,112 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"fmt"
+	"iter"
+)
+
+// DiffKind identifies the kind of difference between two databases.
+type DiffKind int
+
+const (
+	DiffAdded   DiffKind = iota // DiffAdded identifies a key that only exists in b.
+	DiffRemoved                 // DiffRemoved identifies a key that only exists in a.
+	DiffChanged                 // DiffChanged identifies a key whose value differs.
+)
+
+// String returns the name of the kind.
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	default:
+		return fmt.Sprintf("diffkind(%d)", int(k))
+	}
+}
+
+// DiffEntry describes a key that differs between two databases.
+type DiffEntry struct {
+	Kind DiffKind
+	Key  []byte
+	Old  []byte // The value in a. Nil if the key was added.
+	New  []byte // The value in b. Nil if the key was removed.
+}
+
+// Diff returns an iterator over the differences that turn a into b, in
+// lexicographical key order. Each database is read from a snapshot that is
+// taken when the iteration starts, but the two snapshots are not taken
+// atomically with respect to each other.
+//
+// Case-insensitive databases are compared by their folded keys. Databases
+// should only be compared to databases with the same CaseInsensitiveKeys and
+// ReverseOrder settings.
+//
+// Comparing hashes only reveals whether entire databases are identical, since
+// the two databases cannot be locked together to descend into their subtrees.
+func Diff(a *Arc, b *Arc) iter.Seq[DiffEntry] {
+	return func(yield func(DiffEntry) bool) {
+		// Identical databases are detected without scanning them when both
+		// maintain their subtree hashes.
+		if a.opts.TrackSubtreeHashes && b.opts.TrackSubtreeHashes && bytes.Equal(a.RootHash(), b.RootHash()) {
+			return
+		}
+
+		nextA, stopA := iter.Pull2(a.Scan(nil))
+		defer stopA()
+
+		nextB, stopB := iter.Pull2(b.Scan(nil))
+		defer stopB()
+
+		keyA, valueA, okA := nextA()
+		keyB, valueB, okB := nextB()
+
+		for okA || okB {
+			var entry DiffEntry
+
+			cmp := 0
+
+			switch {
+			case !okA:
+				cmp = 1
+			case !okB:
+				cmp = -1
+			default:
+				cmp = bytes.Compare(a.foldKey(keyA), b.foldKey(keyB))
+
+				if a.opts.ReverseOrder {
+					cmp = -cmp
+				}
+			}
+
+			switch {
+			case cmp < 0:
+				entry = DiffEntry{Kind: DiffRemoved, Key: keyA, Old: valueA}
+				keyA, valueA, okA = nextA()
+
+			case cmp > 0:
+				entry = DiffEntry{Kind: DiffAdded, Key: keyB, New: valueB}
+				keyB, valueB, okB = nextB()
+
+			default:
+				if !bytes.Equal(valueA, valueB) {
+					entry = DiffEntry{Kind: DiffChanged, Key: keyA, Old: valueA, New: valueB}
+				}
+
+				keyA, valueA, okA = nextA()
+				keyB, valueB, okB = nextB()
+			}
+
+			if entry.Key != nil && !yield(entry) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := basicTestTree()
+	b := basicTestTree()
+
+	for range Diff(a, b) {
+		t.Fatalf("expected identical databases to have no differences")
+	}
+
+	keys := sortedBasicTestKeys()
+
+	b.Delete([]byte(keys[0]))
+	b.Put([]byte(keys[1]), []byte("changed"))
+	b.Put([]byte("zzz"), []byte("added"))
+
+	want := []DiffEntry{
+		{Kind: DiffRemoved, Key: []byte(keys[0])},
+		{Kind: DiffChanged, Key: []byte(keys[1]), New: []byte("changed")},
+		{Kind: DiffAdded, Key: []byte("zzz"), New: []byte("added")},
+	}
+
+	var got []DiffEntry
+
+	for entry := range Diff(a, b) {
+		got = append(got, entry)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("unexpected number of entries: got:%d, want:%d", len(got), len(want))
+	}
+
+	for i := range want {
+		if got[i].Kind != want[i].Kind || !bytes.Equal(got[i].Key, want[i].Key) {
+			t.Errorf("unexpected entry: got:%s %q, want:%s %q", got[i].Kind, got[i].Key, want[i].Kind, want[i].Key)
+		}
+
+		if want[i].New != nil && !bytes.Equal(got[i].New, want[i].New) {
+			t.Errorf("unexpected new value: got:%q, want:%q", got[i].New, want[i].New)
+		}
+	}
+
+	// The reverse diff swaps the additions and removals.
+	var kinds []DiffKind
+
+	for entry := range Diff(b, a) {
+		kinds = append(kinds, entry.Kind)
+	}
+
+	if len(kinds) != 3 || kinds[0] != DiffAdded || kinds[1] != DiffChanged || kinds[2] != DiffRemoved {
+		t.Errorf("unexpected reverse diff: %v", kinds)
+	}
+
+	for range Diff(New(), New()) {
+		t.Fatalf("expected empty databases to have no differences")
+	}
+}
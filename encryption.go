@@ -0,0 +1,150 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	// ErrDecryptionFailed is returned when encrypted content cannot be
+	// authenticated, which indicates corruption, tampering, or a wrong key.
+	ErrDecryptionFailed = errors.New("decryption failed")
+
+	// ErrUnknownEncryptionKey is returned when encrypted content references
+	// a key ID that the EncryptionProvider does not hold.
+	ErrUnknownEncryptionKey = errors.New("unknown encryption key")
+)
+
+// EncryptionProvider encrypts the database content at rest. Values are hashed
+// in plaintext to compute their blobIDs, which keeps deduplication working
+// at the cost of revealing which records share the same value, and allowing
+// a guessed value to be confirmed against its persisted blobID. Applications
+// that cannot accept this trade-off should encrypt values before storing them.
+type EncryptionProvider interface {
+	// Encrypt seals the plaintext. The additional data is authenticated but
+	// not encrypted, and must be passed to Decrypt unchanged. The returned
+	// ciphertext must embed everything needed to decrypt it, such as the
+	// ID of the key that was used.
+	Encrypt(plaintext []byte, additionalData []byte) ([]byte, error)
+
+	// Decrypt opens a ciphertext that was sealed by Encrypt.
+	Decrypt(ciphertext []byte, additionalData []byte) ([]byte, error)
+}
+
+// aesGCMHeaderLen is the length of the header that precedes the ciphertext
+// sealed by AESGCMProvider: the key ID and the nonce.
+const aesGCMHeaderLen = sizeOfUint32 + 12
+
+// AESGCMProvider is an EncryptionProvider based on AES-GCM. It supports key
+// rotation by sealing new content with the active key, while retaining older
+// keys for decryption. The ID of the sealing key is stored in the header of
+// each ciphertext. An AESGCMProvider is safe for concurrent use.
+type AESGCMProvider struct {
+	active uint32
+	aeads  map[uint32]cipher.AEAD
+}
+
+// NewAESGCMProvider returns an AESGCMProvider holding the given keys, which
+// must be 16, 24, or 32 bytes long to select AES-128, AES-192, or AES-256.
+// New content is sealed with the key identified by activeKeyID.
+func NewAESGCMProvider(keys map[uint32][]byte, activeKeyID uint32) (*AESGCMProvider, error) {
+	if _, found := keys[activeKeyID]; !found {
+		return nil, ErrUnknownEncryptionKey
+	}
+
+	ret := &AESGCMProvider{active: activeKeyID, aeads: map[uint32]cipher.AEAD{}}
+
+	for id, key := range keys {
+		block, err := aes.NewCipher(key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if ret.aeads[id], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+// Encrypt seals the plaintext with the active key. The ciphertext layout is
+// the little-endian key ID, followed by the random nonce, followed by the
+// sealed content including the authentication tag.
+func (p *AESGCMProvider) Encrypt(plaintext []byte, additionalData []byte) ([]byte, error) {
+	aead := p.aeads[p.active]
+
+	ret := make([]byte, aesGCMHeaderLen, aesGCMHeaderLen+len(plaintext)+aead.Overhead())
+	binary.LittleEndian.PutUint32(ret, p.active)
+
+	nonce := ret[sizeOfUint32:aesGCMHeaderLen]
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(ret, nonce, plaintext, additionalData), nil
+}
+
+// Decrypt opens the ciphertext using the key identified by its header.
+func (p *AESGCMProvider) Decrypt(ciphertext []byte, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aesGCMHeaderLen {
+		return nil, ErrDecryptionFailed
+	}
+
+	aead, found := p.aeads[binary.LittleEndian.Uint32(ciphertext)]
+
+	if !found {
+		return nil, ErrUnknownEncryptionKey
+	}
+
+	nonce := ciphertext[sizeOfUint32:aesGCMHeaderLen]
+	ret, err := aead.Open(nil, nonce, ciphertext[aesGCMHeaderLen:], additionalData)
+
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return ret, nil
+}
+
+// sealBlob compresses the blob value using the dictionary, if any, and then
+// encrypts it for persistence. The blobID is used as the additional data,
+// which prevents blobs from being swapped undetected. The value is not
+// encrypted if no EncryptionProvider is configured.
+func (a *Arc) sealBlob(id blobID, value []byte) ([]byte, error) {
+	value, err := a.compressBlob(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if a.opts.Encryption == nil {
+		return value, nil
+	}
+
+	return a.opts.Encryption.Encrypt(value, id.Slice())
+}
+
+// openBlob decrypts and decompresses the persisted blob value sealed by
+// sealBlob.
+func (a *Arc) openBlob(id blobID, sealed []byte) ([]byte, error) {
+	if a.opts.Encryption == nil {
+		return a.decompressBlob(sealed)
+	}
+
+	compressed, err := a.opts.Encryption.Decrypt(sealed, id.Slice())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a.decompressBlob(compressed)
+}
@@ -0,0 +1,117 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAESGCMProvider(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{0x01}, 32)
+	newKey := bytes.Repeat([]byte{0x02}, 16)
+
+	p1, err := NewAESGCMProvider(map[uint32][]byte{1: oldKey}, 1)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	plaintext := blobValueX()
+	aad := []byte("blob-id")
+
+	sealed, err := p1.Encrypt(plaintext, aad)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if bytes.Contains(sealed, plaintext) {
+		t.Fatalf("expected the plaintext to be encrypted")
+	}
+
+	// Rotate to key 2, while retaining key 1 for existing content.
+	p2, err := NewAESGCMProvider(map[uint32][]byte{1: oldKey, 2: newKey}, 2)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, p := range []*AESGCMProvider{p1, p2} {
+		got, err := p.Decrypt(sealed, aad)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("unexpected plaintext: got:%q, want:%q", got, plaintext)
+		}
+	}
+
+	rotated, _ := p2.Encrypt(plaintext, aad)
+
+	if _, err := p1.Decrypt(rotated, aad); err != ErrUnknownEncryptionKey {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrUnknownEncryptionKey)
+	}
+
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 0xFF
+
+	testCases := []struct {
+		name       string
+		ciphertext []byte
+		aad        []byte
+	}{
+		{"tampered ciphertext", tampered, aad},
+		{"mismatched additional data", sealed, []byte("other-id")},
+		{"truncated header", sealed[:aesGCMHeaderLen-1], aad},
+	}
+
+	for _, tc := range testCases {
+		if _, err := p2.Decrypt(tc.ciphertext, tc.aad); err != ErrDecryptionFailed {
+			t.Errorf("%s: unexpected error: got:%v, want:%v", tc.name, err, ErrDecryptionFailed)
+		}
+	}
+}
+
+func TestNewAESGCMProviderErrors(t *testing.T) {
+	if _, err := NewAESGCMProvider(map[uint32][]byte{1: make([]byte, 32)}, 2); err != ErrUnknownEncryptionKey {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrUnknownEncryptionKey)
+	}
+
+	if _, err := NewAESGCMProvider(map[uint32][]byte{1: make([]byte, 7)}, 1); err == nil {
+		t.Errorf("expected an invalid key size error")
+	}
+}
+
+func TestSealBlob(t *testing.T) {
+	value := blobValueX()
+	id := makeBlobID(value)
+
+	// Without a provider, blobs are persisted as is.
+	arc := New()
+
+	if sealed, _ := arc.sealBlob(id, value); !bytes.Equal(sealed, value) {
+		t.Errorf("unexpected sealed blob: got:%q, want:%q", sealed, value)
+	}
+
+	p, _ := NewAESGCMProvider(map[uint32][]byte{7: make([]byte, 32)}, 7)
+	arc, _ = NewWithOptions(Options{Encryption: p})
+
+	sealed, err := arc.sealBlob(id, value)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, err := arc.openBlob(id, sealed); err != nil || !bytes.Equal(got, value) {
+		t.Errorf("unexpected opened blob: got:%q, err:%v", got, err)
+	}
+
+	// The blobID is authenticated, so blobs cannot be swapped.
+	if _, err := arc.openBlob(makeBlobID([]byte("other")), sealed); err != ErrDecryptionFailed {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrDecryptionFailed)
+	}
+}
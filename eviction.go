@@ -0,0 +1,311 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+)
+
+// EvictionPolicy selects the records to evict once the database exceeds the
+// capacity configured by Options.MaxRecords or Options.MaxBytes.
+type EvictionPolicy int
+
+const (
+	EvictLRU    EvictionPolicy = iota // EvictLRU evicts the least recently used record.
+	EvictLFU                          // EvictLFU evicts an infrequently used record.
+	EvictRandom                       // EvictRandom evicts a random record.
+)
+
+// lfuSampleSize is the number of records that EvictLFU samples to find a
+// record to evict, which avoids maintaining the records in frequency order.
+const lfuSampleSize = 5
+
+// String returns the name of the policy.
+func (p EvictionPolicy) String() string {
+	switch p {
+	case EvictLRU:
+		return "lru"
+	case EvictLFU:
+		return "lfu"
+	case EvictRandom:
+		return "random"
+	default:
+		return fmt.Sprintf("evictionpolicy(%d)", int(p))
+	}
+}
+
+// usageEntry tracks the usage of a record. The record node references its
+// entry, which saves looking the entry up by key, and the entries form an
+// intrusive doubly linked list in the order of use, starting with the most
+// recently used. The entry holds the full key, since the node only holds its
+// path segment, and the key is needed to delete the record once it is evicted.
+type usageEntry struct {
+	key   string
+	size  int64  // Size of the key and the value in bytes.
+	hits  uint64 // Number of uses.
+	index int    // Position in usageTracker.entries.
+
+	prev *usageEntry
+	next *usageEntry
+}
+
+// usageTracker tracks the usage of every record, and selects the records to
+// evict. The list is updated by readers, which only hold the read lock of the
+// database, therefore it is guarded by its own mutex, along with the usage
+// field of the record nodes.
+type usageTracker struct {
+	mu       sync.Mutex
+	entries  []*usageEntry // Supports random sampling.
+	head     *usageEntry   // Most recently used.
+	tail     *usageEntry   // Least recently used.
+	numBytes int64
+}
+
+// evictionEnabled returns true if a capacity limit is configured.
+func (a *Arc) evictionEnabled() bool {
+	return a.opts.MaxRecords > 0 || a.opts.MaxBytes > 0
+}
+
+// trackUsage records that the given record was written with the given value
+// size, and evicts other records if the database exceeds its capacity. It is
+// a no-op unless a capacity limit is configured. Replicas do not evict, since
+// they mirror their primary. The caller must hold the write lock.
+func (a *Arc) trackUsage(key []byte, valueSize int) {
+	if !a.evictionEnabled() {
+		return
+	}
+
+	n, _, err := a.findNodeAndParent(key)
+
+	if err != nil || !n.isRecord {
+		return
+	}
+
+	e := a.usage.record(n, key, valueSize)
+
+	if !a.readOnly {
+		a.evict(e)
+	}
+}
+
+// record marks the record as the most recently used, and updates its size.
+// The given node is the record node of the key.
+func (u *usageTracker) record(n *node, key []byte, valueSize int) *usageEntry {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	size := int64(len(key) + valueSize)
+	e := n.usage
+
+	if e != nil {
+		u.numBytes += size - e.size
+		e.size = size
+		e.hits++
+		u.unlink(e)
+	} else {
+		e = &usageEntry{key: string(key), size: size, hits: 1, index: len(u.entries)}
+		n.usage = e
+		u.entries = append(u.entries, e)
+		u.numBytes += size
+	}
+
+	u.pushFront(e)
+
+	return e
+}
+
+// newUsageTracker returns an empty usageTracker.
+func newUsageTracker() *usageTracker {
+	return &usageTracker{}
+}
+
+// clone returns a copy of the tracker, which lists the records in the same
+// order of use, along with the copy of each entry.
+func (u *usageTracker) clone() (*usageTracker, map[*usageEntry]*usageEntry) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	ret := newUsageTracker()
+	ret.numBytes = u.numBytes
+	copies := make(map[*usageEntry]*usageEntry, len(u.entries))
+
+	for e := u.tail; e != nil; e = e.prev {
+		c := &usageEntry{key: e.key, size: e.size, hits: e.hits, index: len(ret.entries)}
+		copies[e] = c
+		ret.entries = append(ret.entries, c)
+		ret.pushFront(c)
+	}
+
+	return ret, copies
+}
+
+// touchUsage records a read of the given record node. It is a no-op unless a
+// capacity limit is configured. The caller must hold the read lock.
+func (a *Arc) touchUsage(n *node) {
+	if !a.evictionEnabled() {
+		return
+	}
+
+	u := a.usage
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if e := n.usage; e != nil {
+		e.hits++
+		u.unlink(e)
+		u.pushFront(e)
+	}
+}
+
+// forgetUsage stops tracking the usage of the record node that is being
+// deleted. The caller must hold the write lock.
+func (a *Arc) forgetUsage(n *node) {
+	if !a.evictionEnabled() {
+		return
+	}
+
+	u := a.usage
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	e := n.usage
+
+	if e == nil {
+		return
+	}
+
+	u.unlink(e)
+	n.usage = nil
+
+	last := u.entries[len(u.entries)-1]
+	last.index = e.index
+	u.entries[e.index] = last
+	u.entries = u.entries[:len(u.entries)-1]
+	u.numBytes -= e.size
+}
+
+// evict deletes records according to the EvictionPolicy until the database
+// fits within its capacity. The protected entry, which belongs to the record
+// that was just written, is never evicted. The caller must hold the write
+// lock.
+func (a *Arc) evict(protected *usageEntry) {
+	for a.overCapacity() {
+		victim := a.usage.victim(a.opts.Eviction, protected)
+
+		if victim == nil {
+			return
+		}
+
+		if err := a.deleteRecord([]byte(victim.key)); err != nil {
+			return
+		}
+	}
+}
+
+// overCapacity returns true if the database exceeds its capacity.
+func (a *Arc) overCapacity() bool {
+	if a.opts.MaxRecords > 0 && a.numRecords > a.opts.MaxRecords {
+		return true
+	}
+
+	a.usage.mu.Lock()
+	defer a.usage.mu.Unlock()
+
+	return a.opts.MaxBytes > 0 && a.usage.numBytes > a.opts.MaxBytes
+}
+
+// victim selects the entry to evict, or returns nil if the protected entry is
+// the only candidate.
+func (u *usageTracker) victim(policy EvictionPolicy, protected *usageEntry) *usageEntry {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if len(u.entries) < 2 {
+		return nil
+	}
+
+	switch policy {
+	case EvictLFU:
+		var ret *usageEntry
+
+		// Consider every candidate when there are only a few.
+		if len(u.entries)-1 <= lfuSampleSize {
+			for _, e := range u.entries {
+				if e != protected && (ret == nil || e.hits < ret.hits) {
+					ret = e
+				}
+			}
+
+			return ret
+		}
+
+		for range lfuSampleSize {
+			if e := u.randomEntry(protected); ret == nil || e.hits < ret.hits {
+				ret = e
+			}
+		}
+
+		return ret
+
+	case EvictRandom:
+		return u.randomEntry(protected)
+
+	default:
+		if u.tail == protected {
+			return u.tail.prev
+		}
+
+		return u.tail
+	}
+}
+
+// randomEntry returns a random entry other than the excluded one. There must
+// be at least two entries.
+func (u *usageTracker) randomEntry(excluded *usageEntry) *usageEntry {
+	i := rand.IntN(len(u.entries) - 1)
+
+	if i >= excluded.index {
+		i++
+	}
+
+	return u.entries[i]
+}
+
+// pushFront inserts the unlinked entry at the front of the list.
+func (u *usageTracker) pushFront(e *usageEntry) {
+	e.prev = nil
+	e.next = u.head
+
+	if u.head != nil {
+		u.head.prev = e
+	}
+
+	u.head = e
+
+	if u.tail == nil {
+		u.tail = e
+	}
+}
+
+// unlink removes the entry from the list.
+func (u *usageTracker) unlink(e *usageEntry) {
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		u.head = e.next
+	}
+
+	if e.next != nil {
+		e.next.prev = e.prev
+	} else {
+		u.tail = e.prev
+	}
+
+	e.prev = nil
+	e.next = nil
+}
@@ -0,0 +1,131 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEvictLRU(t *testing.T) {
+	arc, _ := NewWithOptions(Options{MaxRecords: 3, Eviction: EvictLRU})
+
+	arc.Put([]byte("a"), []byte("1"))
+	arc.Put([]byte("b"), []byte("2"))
+	arc.Put([]byte("c"), []byte("3"))
+	arc.Get([]byte("a"))
+	arc.Put([]byte("d"), []byte("4"))
+
+	assertKeys(t, collectKeys(arc), []string{"a", "c", "d"})
+
+	// Updates count as uses.
+	arc.Put([]byte("c"), []byte("5"))
+	arc.Put([]byte("e"), []byte("6"))
+
+	assertKeys(t, collectKeys(arc), []string{"c", "d", "e"})
+}
+
+func TestEvictLRUMerge(t *testing.T) {
+	arc, _ := NewWithOptions(Options{MaxRecords: 2, Eviction: EvictLRU})
+
+	// Deleting "xa" merges "xb" into its parent node, which must carry the
+	// usage of the record along.
+	arc.Put([]byte("xa"), []byte("1"))
+	arc.Put([]byte("xb"), []byte("2"))
+	arc.Delete([]byte("xa"))
+	arc.Put([]byte("y"), []byte("3"))
+	arc.Get([]byte("xb"))
+	arc.Put([]byte("z"), []byte("4"))
+
+	assertKeys(t, collectKeys(arc), []string{"xb", "z"})
+
+	if len(arc.usage.entries) != arc.Len() {
+		t.Errorf("unexpected number of tracked records: got:%d, want:%d", len(arc.usage.entries), arc.Len())
+	}
+
+	// Clones track the usage of their own nodes.
+	clone := arc.Clone()
+	clone.Get([]byte("xb"))
+	clone.Put([]byte("w"), []byte("5"))
+	arc.Put([]byte("w"), []byte("5"))
+
+	assertKeys(t, collectKeys(clone), []string{"w", "xb"})
+	assertKeys(t, collectKeys(arc), []string{"w", "z"})
+}
+
+func TestEvictLFU(t *testing.T) {
+	arc, _ := NewWithOptions(Options{MaxRecords: 3, Eviction: EvictLFU})
+
+	arc.Put([]byte("a"), []byte("1"))
+	arc.Put([]byte("b"), []byte("2"))
+	arc.Put([]byte("c"), []byte("3"))
+
+	for range 3 {
+		arc.Get([]byte("a"))
+		arc.Get([]byte("b"))
+	}
+
+	arc.Get([]byte("c"))
+	arc.Put([]byte("d"), []byte("4"))
+
+	assertKeys(t, collectKeys(arc), []string{"a", "b", "d"})
+}
+
+func TestEvictRandom(t *testing.T) {
+	arc, _ := NewWithOptions(Options{MaxRecords: 10, Eviction: EvictRandom})
+
+	for i := range 100 {
+		key := []byte(fmt.Sprintf("key-%03d", i))
+		arc.Put(key, key)
+
+		if arc.Len() > 10 {
+			t.Fatalf("unexpected length: got:%d, want at most %d", arc.Len(), 10)
+		}
+
+		if _, err := arc.Get(key); err != nil {
+			t.Fatalf("expected the written record to be kept: %v", err)
+		}
+	}
+
+	if len(arc.usage.entries) != arc.Len() {
+		t.Errorf("unexpected number of tracked records: got:%d, want:%d", len(arc.usage.entries), arc.Len())
+	}
+}
+
+func TestEvictMaxBytes(t *testing.T) {
+	arc, _ := NewWithOptions(Options{MaxBytes: 100})
+
+	arc.Put([]byte("blob-1"), blobValueX())
+	arc.Put([]byte("blob-2"), blobValueX())
+	arc.Put([]byte("small"), []byte("value"))
+
+	// Deduplicated blobs still count once per record.
+	assertKeys(t, collectKeys(arc), []string{"blob-2", "small"})
+
+	if want := int64(len("blob-2") + len(blobValueX()) + len("small") + len("value")); arc.usage.numBytes != want {
+		t.Errorf("unexpected numBytes: got:%d, want:%d", arc.usage.numBytes, want)
+	}
+
+	arc.Delete([]byte("small"))
+
+	if want := int64(len("blob-2") + len(blobValueX())); arc.usage.numBytes != want {
+		t.Errorf("unexpected numBytes: got:%d, want:%d", arc.usage.numBytes, want)
+	}
+
+	// A record that exceeds the capacity on its own is kept.
+	large := make([]byte, 200)
+	arc.Put([]byte("large"), large)
+
+	assertKeys(t, collectKeys(arc), []string{"large"})
+}
+
+func collectKeys(arc *Arc) []string {
+	var ret []string
+
+	for key := range arc.Keys(nil) {
+		ret = append(ret, string(key))
+	}
+
+	return ret
+}
@@ -0,0 +1,309 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Expire sets the record that matches the given key to expire once the given
+// duration has elapsed. See ExpireAt for the details.
+func (a *Arc) Expire(key []byte, ttl time.Duration) error {
+	return a.ExpireAt(key, a.now().Add(ttl))
+}
+
+// ExpireAt sets the record that matches the given key to expire at the given
+// time. The zero time removes the expiration. Expired records are no longer
+// returned by any read method, nor counted by Len and CountPrefix, and are
+// deleted by the sweeper that runs every Options.ExpirationSweepInterval.
+// Without the sweeper, they are only deleted by writes. Put and Add remove the expiration of the records that they
+// write. Expirations are persisted along with the records. Returns
+// ErrKeyNotFound if the key does not exist.
+func (a *Arc) ExpireAt(key []byte, t time.Time) error {
+	key = a.transformKey(key)
+
+	return a.runHooks(OpInfo{Op: OpExpire, Key: key}, func(*OpInfo) error {
+		if err := a.checkKey(key); err != nil {
+			return err
+		}
+
+		if a.readOnly {
+			return ErrReadOnly
+		}
+
+		a.lock()
+		defer a.mu.Unlock()
+
+		if a.expired(key) {
+			return ErrKeyNotFound
+		}
+
+		return a.expireRecord(key, t)
+	})
+}
+
+// expireRecord sets the expiration time of the record, or removes it if t is
+// the zero time. The caller must hold the write lock.
+func (a *Arc) expireRecord(key []byte, t time.Time) error {
+	n, _, err := a.findNodeAndParent(key)
+
+	if err != nil {
+		return err
+	}
+
+	if !n.isRecord {
+		return ErrKeyNotFound
+	}
+
+	if t.IsZero() {
+		delete(a.expirations, string(key))
+	} else {
+		if a.expirations == nil {
+			a.expirations = map[string]time.Time{}
+		}
+
+		a.expirations[string(key)] = t
+	}
+
+	a.seq++
+	a.noteTxnWrite(key)
+	a.publishChange(OpExpire, key, encodeExpiration(t))
+
+	return nil
+}
+
+// expired returns true if the record that matches the given key has expired.
+// The caller must hold the read lock.
+func (a *Arc) expired(key []byte) bool {
+	t, found := a.expirations[string(key)]
+	return found && !a.now().Before(t)
+}
+
+// visible returns true if the record that matches the given key has not
+// expired, and the Authorizer allows it to be read. Records that are not
+// visible are skipped by the iterators, and are not counted. The caller must
+// hold the read lock.
+func (a *Arc) visible(key []byte) bool {
+	return !a.expired(key) && a.readable(key)
+}
+
+// countExpired returns the number of expired records whose keys begin with the
+// given prefix, which the sweeper has not deleted yet. The caller must hold
+// the read lock.
+func (a *Arc) countExpired(prefix []byte) int {
+	if len(a.expirations) == 0 {
+		return 0
+	}
+
+	var ret int
+
+	now := a.now()
+
+	for key, t := range a.expirations {
+		if !now.Before(t) && strings.HasPrefix(key, string(prefix)) {
+			ret++
+		}
+	}
+
+	return ret
+}
+
+// expiresAt returns the expiration time of the record, or the zero time if it
+// does not expire. The caller must hold the read lock.
+func (a *Arc) expiresAt(key []byte) time.Time {
+	return a.expirations[string(key)]
+}
+
+// startSweeper starts the goroutine that deletes the expired records, unless
+// the ExpirationSweepInterval option is zero.
+func (a *Arc) startSweeper() {
+	if a.opts.ExpirationSweepInterval == 0 {
+		return
+	}
+
+	a.sweepStop = make(chan struct{})
+	a.sweepDone = make(chan struct{})
+
+	go func() {
+		defer close(a.sweepDone)
+
+		ticker := time.NewTicker(a.opts.ExpirationSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-a.sweepStop:
+				return
+			case <-ticker.C:
+				a.sweepExpired()
+			}
+		}
+	}()
+}
+
+// stopSweeper stops the sweeper goroutine, and waits for it to exit. It is
+// safe to call more than once.
+func (a *Arc) stopSweeper() {
+	if a.sweepStop == nil {
+		return
+	}
+
+	select {
+	case <-a.sweepStop:
+	default:
+		close(a.sweepStop)
+	}
+
+	<-a.sweepDone
+}
+
+// sweepExpired deletes the expired records, and returns the number of deleted
+// records. The tombstones whose retention has elapsed are discarded as well.
+func (a *Arc) sweepExpired() int {
+	a.lock()
+	defer a.mu.Unlock()
+
+	a.purgeTombstones(a.now())
+
+	var ret int
+
+	for key := range a.expirations {
+		if !a.expired([]byte(key)) {
+			continue
+		}
+
+		if err := a.deleteRecord([]byte(key)); err == nil {
+			ret++
+		}
+	}
+
+	return ret
+}
+
+// encodeExpiration encodes the expiration time as nanoseconds since the Unix
+// epoch. The zero time is encoded as zero.
+func encodeExpiration(t time.Time) []byte {
+	var nanos int64
+
+	if !t.IsZero() {
+		nanos = t.UnixNano()
+	}
+
+	return binary.LittleEndian.AppendUint64(nil, uint64(nanos))
+}
+
+// decodeExpiration decodes the expiration time encoded by encodeExpiration.
+func decodeExpiration(src []byte) (time.Time, error) {
+	if len(src) != sizeOfUint64 {
+		return time.Time{}, ErrCorrupted
+	}
+
+	nanos := int64(binary.LittleEndian.Uint64(src))
+
+	if nanos == 0 {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(0, nanos), nil
+}
+
+// serializeExpirations serializes the expiration section, which consists of
+// the number of expirations, the expirations in key order, and the checksum of
+// the preceding bytes. Each expiration holds the key length, the key, and the
+// encoded expiration time. The caller must hold the read lock.
+func (a *Arc) serializeExpirations() ([]byte, error) {
+	keys := make([]string, 0, len(a.expirations))
+
+	for key := range a.expirations {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	ret := binary.LittleEndian.AppendUint64(nil, uint64(len(keys)))
+
+	for _, key := range keys {
+		ret = binary.LittleEndian.AppendUint16(ret, uint16(len(key)))
+		ret = append(ret, key...)
+		ret = append(ret, encodeExpiration(a.expirations[key])...)
+	}
+
+	checksum, err := computeChecksum(ret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return binary.LittleEndian.AppendUint32(ret, checksum), nil
+}
+
+// readExpirations loads the expiration section produced by
+// serializeExpirations. The records must already be loaded, since every
+// expiration must refer to an existing record.
+func (a *Arc) readExpirations(src []byte) error {
+	if len(src) < sizeOfUint64+checksumLen {
+		return ErrCorrupted
+	}
+
+	checksumPos := len(src) - checksumLen
+	checksum, err := computeChecksum(src[:checksumPos])
+
+	if err != nil {
+		return err
+	}
+
+	if checksum != binary.LittleEndian.Uint32(src[checksumPos:]) {
+		return ErrInvalidChecksum
+	}
+
+	r := bytes.NewReader(src[:checksumPos])
+
+	var count uint64
+
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return ErrCorrupted
+	}
+
+	for i := uint64(0); i < count; i++ {
+		var keyLen uint16
+
+		if err := binary.Read(r, binary.LittleEndian, &keyLen); err != nil {
+			return ErrCorrupted
+		}
+
+		entry := make([]byte, int(keyLen)+sizeOfUint64)
+
+		if _, err := io.ReadFull(r, entry); err != nil {
+			return ErrCorrupted
+		}
+
+		key := entry[:keyLen]
+		t, err := decodeExpiration(entry[keyLen:])
+
+		if err != nil {
+			return err
+		}
+
+		if n, _, err := a.findNodeAndParent(key); err != nil || !n.isRecord || t.IsZero() {
+			return ErrCorrupted
+		}
+
+		if a.expirations == nil {
+			a.expirations = map[string]time.Time{}
+		}
+
+		a.expirations[string(key)] = t
+	}
+
+	if r.Len() != 0 {
+		return ErrCorrupted
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpireAt(t *testing.T) {
+	arc := New()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	arc.now = func() time.Time { return now }
+
+	if err := arc.Expire([]byte("missing"), time.Minute); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+
+	arc.Put([]byte("session"), []byte("data"))
+	arc.Put([]byte("blob"), blobValueX())
+	arc.Put([]byte("keep"), []byte("data"))
+
+	if err := arc.Expire([]byte("session"), time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := arc.ExpireAt([]byte("blob"), now.Add(time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, _ := arc.Stat([]byte("session"))
+
+	if !info.ExpiresAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("unexpected ExpiresAt: got:%v, want:%v", info.ExpiresAt, now.Add(time.Minute))
+	}
+
+	now = now.Add(time.Minute)
+
+	if _, err := arc.Get([]byte("session")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+
+	if _, err := arc.Stat([]byte("session")); err != ErrKeyNotFound {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+
+	if _, err := arc.Get([]byte("blob")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if got := arc.sweepExpired(); got != 1 {
+		t.Errorf("unexpected number of swept records: got:%d, want:%d", got, 1)
+	}
+
+	if arc.Len() != 2 {
+		t.Errorf("unexpected length: got:%d, want:%d", arc.Len(), 2)
+	}
+
+	// Removing the expiration keeps the record.
+	arc.ExpireAt([]byte("blob"), time.Time{})
+	now = now.Add(2 * time.Hour)
+
+	if _, err := arc.Get([]byte("blob")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Put removes the expiration, and Add treats expired records as absent.
+	arc.Expire([]byte("keep"), time.Minute)
+	arc.Put([]byte("keep"), []byte("updated"))
+	arc.Expire([]byte("blob"), time.Minute)
+	now = now.Add(time.Minute)
+
+	if _, err := arc.Get([]byte("keep")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := arc.Add([]byte("blob"), []byte("new")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if info, _ := arc.Stat([]byte("blob")); !info.ExpiresAt.IsZero() {
+		t.Errorf("expected Add to remove the expiration")
+	}
+
+	if len(arc.expirations) != 0 {
+		t.Errorf("unexpected number of expirations: %d", len(arc.expirations))
+	}
+}
+
+func TestExpiredReads(t *testing.T) {
+	for _, opts := range []Options{{}, {TrackPrefixCounts: true}} {
+		arc, _ := NewWithOptions(opts)
+		now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		arc.now = func() time.Time { return now }
+
+		for _, key := range []string{"a", "b/x", "b/y", "c/z"} {
+			arc.Put([]byte(key), []byte(key))
+		}
+
+		// No sweeper runs, therefore the expired records remain in the tree.
+		for _, key := range []string{"a", "b/y", "c/z"} {
+			arc.Expire([]byte(key), time.Minute)
+		}
+
+		now = now.Add(time.Minute)
+
+		if has, _ := arc.Has([]byte("a")); has {
+			t.Errorf("expected Has to report the expired record as absent")
+		}
+
+		if got := arc.Len(); got != 1 {
+			t.Errorf("unexpected length: got:%d, want:%d", got, 1)
+		}
+
+		for prefix, want := range map[string]int{"": 1, "b/": 1, "c/": 0} {
+			if got, _ := arc.CountPrefix([]byte(prefix)); got != want {
+				t.Errorf("unexpected count of %q: got:%d, want:%d", prefix, got, want)
+			}
+		}
+
+		for _, consistency := range []Consistency{Snapshot, Locked, Relaxed} {
+			var keys []string
+
+			for key := range arc.ScanWithOptions(ScanOptions{Consistency: consistency}) {
+				keys = append(keys, string(key))
+			}
+
+			if len(keys) != 1 || keys[0] != "b/x" {
+				t.Errorf("unexpected scanned keys: %q", keys)
+			}
+		}
+
+		if page, _ := arc.List(ListOptions{}); len(page.Keys) != 1 {
+			t.Errorf("unexpected number of listed keys: got:%d, want:%d", len(page.Keys), 1)
+		}
+
+		var numInfos int
+
+		for range arc.ScanInfo(nil) {
+			numInfos++
+		}
+
+		if numInfos != 1 {
+			t.Errorf("unexpected number of scanned infos: got:%d, want:%d", numInfos, 1)
+		}
+
+		if c := arc.Cursor(nil); !c.First() || string(c.Key()) != "b/x" || c.Next() {
+			t.Errorf("expected the cursor to only hold the unexpired record")
+		}
+
+		if entries, _ := arc.Children(nil, '/'); len(entries) != 1 || string(entries[0].Name) != "b" {
+			t.Errorf("unexpected children: %v", entries)
+		}
+
+		for name, navigate := range map[string]func() ([]byte, []byte, error){
+			"Min":  arc.Min,
+			"Max":  arc.Max,
+			"Next": func() ([]byte, []byte, error) { return arc.Next([]byte("a")) },
+			"Prev": func() ([]byte, []byte, error) { return arc.Prev([]byte("c/z")) },
+		} {
+			if key, _, err := navigate(); err != nil || string(key) != "b/x" {
+				t.Errorf("unexpected %s: got:%q, err:%v", name, key, err)
+			}
+		}
+
+		if _, _, err := arc.Ceiling([]byte("b/y")); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+		}
+
+		txn := arc.Begin()
+
+		if got := txn.Len(); got != 1 {
+			t.Errorf("unexpected transaction length: got:%d, want:%d", got, 1)
+		}
+
+		txn.Rollback()
+	}
+}
+
+func TestExpirationPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := Open(path)
+
+	arc.Put([]byte("session"), []byte("data"))
+	arc.Put([]byte("keep"), []byte("data"))
+	arc.Put([]byte("later"), []byte("data"))
+
+	deadline := time.Now().Add(50 * time.Millisecond)
+	arc.ExpireAt([]byte("session"), deadline)
+	arc.ExpireAt([]byte("later"), time.Now().Add(time.Hour))
+	arc.Close()
+
+	reopened, err := OpenWithOptions(path, Options{ExpirationSweepInterval: time.Millisecond})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer reopened.Close()
+
+	if info, _ := reopened.Stat([]byte("later")); info.ExpiresAt.IsZero() {
+		t.Errorf("expected the expiration to be persisted")
+	}
+
+	// The sweeper resumes after the reload.
+	for reopened.Len() != 2 {
+		if time.Since(deadline) > 5*time.Second {
+			t.Fatalf("expected the sweeper to delete the expired record")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := reopened.Get([]byte("session")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import "errors"
+
+// failpoint identifies a point of the persistence path at which a fault can
+// be injected, which allows tests to simulate a crash at that point.
+type failpoint int
+
+const (
+	// failpointWrite truncates the write of the temporary file to half of
+	// the data, which simulates a crash in the middle of the write.
+	failpointWrite failpoint = iota + 1
+
+	// failpointSync simulates a crash before the temporary file is synced.
+	failpointSync
+
+	// failpointRename simulates a crash before the temporary file replaces
+	// the database file.
+	failpointRename
+
+	// failpointSyncDir simulates a crash after the rename, but before the
+	// directory is synced.
+	failpointSyncDir
+)
+
+// errInjectedCrash is returned at a failpoint at which a crash is simulated.
+// The work in progress is abandoned without cleaning up, like a killed
+// process would.
+var errInjectedCrash = errors.New("injected crash")
+
+// injectFault decides whether a crash is simulated at the failpoint. It is
+// nil unless set by tests.
+var injectFault func(fp failpoint) bool
+
+// crashAt returns errInjectedCrash if a crash is simulated at the failpoint.
+func crashAt(fp failpoint) error {
+	if injectFault != nil && injectFault(fp) {
+		return errInjectedCrash
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// simulateCrash abandons the database without saving it, like a killed
+// process would, and releases the file lock that the process held.
+func simulateCrash(a *Arc) {
+	a.stopSweeper()
+	a.stopCompaction()
+	a.stopSyncer()
+	a.releaseFileLock()
+}
+
+// crashTestWrites applies the writes of the given round to the databases.
+func crashTestWrites(round int, dbs ...*Arc) {
+	for _, db := range dbs {
+		for i := range 50 {
+			key := []byte(fmt.Sprintf("key-%03d", i))
+
+			switch {
+			case round > 0 && i%5 == 0:
+				db.Delete(key)
+			case round > 0 && i%2 == 0:
+				db.Put(key, bytes.Repeat([]byte{byte(round)}, 40+i))
+			case round == 0:
+				db.Put(key, []byte(fmt.Sprintf("value-%d", i)))
+			}
+		}
+	}
+}
+
+func TestCrashConsistency(t *testing.T) {
+	tests := []struct {
+		name      string
+		failpoint failpoint
+		durable   bool // Whether the interrupted save survives the crash.
+	}{
+		{"partial write", failpointWrite, false},
+		{"before sync", failpointSync, false},
+		{"before rename", failpointRename, false},
+		{"before directory sync", failpointSyncDir, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.arc")
+			acknowledged, pending := New(), New()
+			db, _ := Open(path)
+
+			crashTestWrites(0, db, acknowledged, pending)
+
+			if err := db.Close(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			db, _ = Open(path)
+			crashTestWrites(1, db, pending)
+
+			injectFault = func(fp failpoint) bool { return fp == test.failpoint }
+			err := db.Save()
+			injectFault = nil
+
+			if err != errInjectedCrash {
+				t.Fatalf("unexpected error: got:%v, want:%v", err, errInjectedCrash)
+			}
+
+			simulateCrash(db)
+
+			recovered, err := Open(path)
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := acknowledged
+
+			if test.durable {
+				want = pending
+			}
+
+			assertSameRecords(t, recovered, want)
+
+			if !bytes.Equal(recovered.RootHash(), want.RootHash()) {
+				t.Error("unexpected root hash")
+			}
+
+			// The leftovers of the crash must not affect later saves.
+			crashTestWrites(2, recovered, want)
+
+			if err := recovered.Close(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			reopened, err := Open(path)
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			assertSameRecords(t, reopened, want)
+			reopened.Close()
+		})
+	}
+}
+
+func TestTornFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.arc")
+	db, _ := Open(path)
+
+	crashTestWrites(0, db)
+	crashTestWrites(1, db)
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	src, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	torn := filepath.Join(dir, "torn.arc")
+
+	// A file that lost its tail must never load as a valid database.
+	for n := range len(src) {
+		if err := os.WriteFile(torn, src[:n], 0o644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if db, err := Open(torn); err == nil {
+			db.Close()
+			t.Fatalf("expected an error for a file truncated to %d of %d bytes", n, len(src))
+		}
+	}
+}
+
+func TestCorruptedFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.arc")
+	db, _ := Open(path)
+
+	crashTestWrites(0, db)
+	crashTestWrites(1, db)
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	src, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want, _ := OpenReadOnly(path)
+	defer want.Close()
+
+	corrupted := filepath.Join(dir, "corrupted.arc")
+
+	// A flipped bit must either be detected, or leave the records intact.
+	for i := range src {
+		flipped := bytes.Clone(src)
+		flipped[i] ^= 0x10
+
+		if err := os.WriteFile(corrupted, flipped, 0o644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got, err := OpenReadOnly(corrupted)
+
+		if err != nil {
+			continue
+		}
+
+		if !bytes.Equal(got.RootHash(), want.RootHash()) {
+			t.Errorf("undetected corruption at offset %d of %d", i, len(src))
+		}
+
+		got.Close()
+	}
+}
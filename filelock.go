@@ -0,0 +1,63 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"os"
+	"time"
+)
+
+// lockFileSuffix is appended to the database file path to form the path of
+// its lock file. A separate file is locked because Save replaces the database
+// file, which would otherwise drop the lock.
+const lockFileSuffix = ".lock"
+
+// lockRetryInterval is how often a held file lock is retried until the lock
+// timeout expires.
+const lockRetryInterval = 10 * time.Millisecond
+
+// acquireFileLock creates the lock file of the database file at the given
+// path if needed, and locks it. Writers take an exclusive lock, which
+// excludes all other processes, while readers take a shared lock. A lock that
+// is held by another process in an incompatible mode is retried until the
+// timeout expires, after which ErrDatabaseLocked is returned.
+func acquireFileLock(path string, exclusive bool, timeout time.Duration) (*os.File, error) {
+	f, err := os.OpenFile(path+lockFileSuffix, os.O_RDWR|os.O_CREATE, 0o644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		err = flock(f, exclusive)
+
+		if err != ErrDatabaseLocked || !time.Now().Before(deadline) {
+			break
+		}
+
+		time.Sleep(min(lockRetryInterval, time.Until(deadline)))
+	}
+
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// releaseFileLock releases the lock acquired by acquireFileLock. It is a no-op
+// if the database holds no lock.
+func (a *Arc) releaseFileLock() error {
+	if a.lockFile == nil {
+		return nil
+	}
+
+	err := a.lockFile.Close()
+	a.lockFile = nil
+
+	return err
+}
@@ -0,0 +1,14 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+//go:build !unix && !windows
+
+package arc
+
+import "os"
+
+// flock is a no-op on platforms without file locking support, where the processes
+// that open the same database file are not coordinated.
+func flock(f *os.File, exclusive bool) error {
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	holder, err := Open(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	start := time.Now()
+	timeout := 30 * time.Millisecond
+
+	if _, err := OpenWithOptions(path, Options{LockTimeout: timeout}); err != ErrDatabaseLocked {
+		t.Fatalf("unexpected error: got:%v, want:%v", err, ErrDatabaseLocked)
+	}
+
+	if elapsed := time.Since(start); elapsed < timeout {
+		t.Errorf("expected Open to wait for the timeout: waited %v", elapsed)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		holder.Close()
+	}()
+
+	arc, err := OpenWithOptions(path, Options{LockTimeout: 5 * time.Second})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	arc.Close()
+}
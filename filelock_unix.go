@@ -0,0 +1,31 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+//go:build unix
+
+package arc
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// flock places an advisory lock on the file without blocking. The lock is
+// shared unless exclusive is true. Returns ErrDatabaseLocked if the lock is
+// held by another open file in an incompatible mode.
+func flock(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return ErrDatabaseLocked
+	}
+
+	return err
+}
@@ -0,0 +1,49 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+//go:build windows
+
+package arc
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	lockfileFailImmediately = 0x00000001
+	lockfileExclusiveLock   = 0x00000002
+
+	// errorLockViolation is the ERROR_LOCK_VIOLATION system error code.
+	errorLockViolation = syscall.Errno(33)
+)
+
+var procLockFileEx = syscall.NewLazyDLL("kernel32.dll").NewProc("LockFileEx")
+
+// flock locks the file using LockFileEx without blocking. The lock is shared
+// unless exclusive is true. Returns ErrDatabaseLocked if the lock is held by
+// another open file in an incompatible mode.
+func flock(f *os.File, exclusive bool) error {
+	flags := uintptr(lockfileFailImmediately)
+
+	if exclusive {
+		flags |= lockfileExclusiveLock
+	}
+
+	var overlapped syscall.Overlapped
+
+	// Lock the maximum range, which covers the entire file.
+	r, _, err := procLockFileEx.Call(f.Fd(), flags, 0, ^uintptr(0), ^uintptr(0), uintptr(unsafe.Pointer(&overlapped)))
+
+	if r != 0 {
+		return nil
+	}
+
+	if errors.Is(err, errorLockViolation) {
+		return ErrDatabaseLocked
+	}
+
+	return err
+}
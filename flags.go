@@ -0,0 +1,93 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+// PutWithFlags inserts or updates a key-value pair in the database, and sets
+// the user flags of the record. The flags are a byte that the database stores
+// along with the record without interpreting it, which lets applications mark
+// records, for example as pinned or archived, without encoding the mark in the
+// value. Put and Add leave the flags of existing records untouched, and new
+// records start with no flags set. The flags are returned by Stat, ScanInfo,
+// and Cursor.Flags, and are persisted along with the records.
+func (a *Arc) PutWithFlags(key []byte, value []byte, flags uint8) error {
+	key = a.applyKeyTransform(key)
+
+	return a.runHooks(OpInfo{Op: OpPut, Key: key, ValueSize: len(value)}, func(*OpInfo) error {
+		if a.readOnly {
+			return ErrReadOnly
+		}
+
+		a.lock()
+		defer a.mu.Unlock()
+
+		if err := a.putRecord(key, value, true); err != nil {
+			return err
+		}
+
+		return a.setRecordFlags(a.foldKey(key), flags)
+	})
+}
+
+// SetFlags sets the user flags of the record that matches the given key,
+// without modifying its value. See PutWithFlags for the details. Returns
+// ErrKeyNotFound if the key does not exist.
+func (a *Arc) SetFlags(key []byte, flags uint8) error {
+	key = a.applyKeyTransform(key)
+
+	return a.runHooks(OpInfo{Op: OpSetFlags, Key: key}, func(*OpInfo) error {
+		key := a.foldKey(key)
+
+		if err := a.checkKey(key); err != nil {
+			return err
+		}
+
+		if a.readOnly {
+			return ErrReadOnly
+		}
+
+		a.lock()
+		defer a.mu.Unlock()
+
+		if a.expired(key) {
+			return ErrKeyNotFound
+		}
+
+		return a.setRecordFlags(key, flags)
+	})
+}
+
+// setRecordFlags sets the user flags of the record, and publishes the change
+// to the replicas. It is a no-op if the flags are already set. The caller must
+// hold the write lock.
+func (a *Arc) setRecordFlags(key []byte, flags uint8) error {
+	n, _, err := a.findNodeAndParent(key)
+
+	if err != nil {
+		return err
+	}
+
+	if !n.isRecord {
+		return ErrKeyNotFound
+	}
+
+	if n.userFlags == flags {
+		return nil
+	}
+
+	n.userFlags = flags
+	a.seq++
+	a.noteTxnWrite(key)
+	a.publishChange(OpSetFlags, key, []byte{flags})
+
+	return nil
+}
+
+// setUserFlags sets the user flags of the record without any bookkeeping. It
+// is a no-op if the record does not exist. The caller must hold the write
+// lock.
+func (a *Arc) setUserFlags(key []byte, flags uint8) {
+	if n, _, err := a.findNodeAndParent(key); err == nil && n.isRecord {
+		n.userFlags = flags
+	}
+}
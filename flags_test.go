@@ -0,0 +1,162 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPutWithFlags(t *testing.T) {
+	arc := New()
+
+	if err := arc.PutWithFlags([]byte("pinned"), []byte("value"), 0x01); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	arc.Put([]byte("plain"), []byte("value"))
+
+	if info, _ := arc.Stat([]byte("pinned")); info.Flags != 0x01 {
+		t.Errorf("unexpected flags: got:%d, want:%d", info.Flags, 0x01)
+	}
+
+	if info, _ := arc.Stat([]byte("plain")); info.Flags != 0 {
+		t.Errorf("unexpected flags: got:%d, want:%d", info.Flags, 0)
+	}
+
+	// Put leaves the flags of existing records untouched.
+	arc.Put([]byte("pinned"), []byte("updated"))
+
+	if info, _ := arc.Stat([]byte("pinned")); info.Flags != 0x01 {
+		t.Errorf("unexpected flags: got:%d, want:%d", info.Flags, 0x01)
+	}
+
+	// The flags do not outlive the record.
+	arc.Delete([]byte("pinned"))
+	arc.Put([]byte("pinned"), []byte("value"))
+
+	if info, _ := arc.Stat([]byte("pinned")); info.Flags != 0 {
+		t.Errorf("unexpected flags: got:%d, want:%d", info.Flags, 0)
+	}
+}
+
+func TestSetFlags(t *testing.T) {
+	arc := New()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	arc.now = func() time.Time { return now }
+
+	if err := arc.SetFlags([]byte("missing"), 0x01); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+
+	arc.Put([]byte("key"), []byte("value"))
+	arc.Put([]byte("keyring"), []byte("value"))
+	seq := arc.Seq()
+
+	// Intermediate nodes are not records.
+	if err := arc.SetFlags([]byte("ke"), 0x01); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+
+	if err := arc.SetFlags([]byte("key"), 0x02); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if value, _ := arc.Get([]byte("key")); string(value) != "value" {
+		t.Errorf("unexpected value: got:%q, want:%q", value, "value")
+	}
+
+	if info, _ := arc.Stat([]byte("key")); info.Flags != 0x02 {
+		t.Errorf("unexpected flags: got:%d, want:%d", info.Flags, 0x02)
+	}
+
+	// Setting the same flags again is not a write.
+	arc.SetFlags([]byte("key"), 0x02)
+
+	if arc.Seq() != seq+1 {
+		t.Errorf("unexpected seq: got:%d, want:%d", arc.Seq(), seq+1)
+	}
+
+	// Expired records are overwritten as if they had been deleted.
+	arc.Expire([]byte("key"), time.Minute)
+	now = now.Add(time.Minute)
+
+	if err := arc.SetFlags([]byte("key"), 0x01); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+
+	arc.Put([]byte("key"), []byte("value"))
+
+	if info, _ := arc.Stat([]byte("key")); info.Flags != 0 {
+		t.Errorf("unexpected flags: got:%d, want:%d", info.Flags, 0)
+	}
+}
+
+func TestFlagsIterators(t *testing.T) {
+	arc := New()
+	arc.PutWithFlags([]byte("a"), []byte("1"), 0x01)
+	arc.Put([]byte("b"), []byte("2"))
+	arc.PutWithFlags([]byte("c"), blobValueX(), 0x04)
+
+	want := []uint8{0x01, 0, 0x04}
+	var got []uint8
+
+	for info := range arc.ScanInfo(nil) {
+		got = append(got, info.Flags)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("unexpected number of records: got:%d, want:%d", len(got), len(want))
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("unexpected flags at %d: got:%d, want:%d", i, got[i], want[i])
+		}
+	}
+
+	c := arc.Cursor(nil)
+
+	if c.Flags() != 0 {
+		t.Errorf("expected no flags for an unpositioned cursor")
+	}
+
+	for i := 0; c.Next(); i++ {
+		if c.Flags() != want[i] {
+			t.Errorf("unexpected flags at %d: got:%d, want:%d", i, c.Flags(), want[i])
+		}
+	}
+}
+
+func TestFlagsPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := Open(path)
+	arc.PutWithFlags([]byte("apple"), []byte("1"), 0xff)
+	arc.PutWithFlags([]byte("app"), blobValueX(), 0x10)
+	arc.Put([]byte("apply"), []byte("2"))
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	arc, err := Open(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer arc.Close()
+
+	for key, want := range map[string]uint8{"apple": 0xff, "app": 0x10, "apply": 0} {
+		if info, _ := arc.Stat([]byte(key)); info.Flags != want {
+			t.Errorf("unexpected flags for %q: got:%d, want:%d", key, info.Flags, want)
+		}
+	}
+
+	if value, _ := arc.Get([]byte("app")); string(value) != string(blobValueX()) {
+		t.Errorf("unexpected value for %q", "app")
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// subtreeHash is the SHA-256 hash of a subtree.
+type subtreeHash [sha256.Size]byte
+
+// RootHash returns the SHA-256 fingerprint of the database contents, or nil if
+// the database is empty. Databases that hold the same records have the same
+// root hash, regardless of the order of the writes that produced them. The
+// hash is computed in constant time when Options.TrackSubtreeHashes is
+// enabled, and by hashing the entire tree otherwise.
+func (a *Arc) RootHash() []byte {
+	a.rlock()
+	defer a.runlock()
+
+	if a.root == nil {
+		return nil
+	}
+
+	ret := a.hashNode(a.root, false)
+
+	return ret[:]
+}
+
+// hashNode returns the hash of the subtree rooted at the node. The hash covers
+// the key segment, the value, and the hashes of the children in order. Since
+// the shape of a Radix tree is determined by its keys, equal subtrees at the
+// same position have equal hashes. Values are represented by their SHA-256
+// hash, which is the blobID for blob values. Up-to-date hashes are reused, and
+// the computed hashes are cached if cache is true, which requires the write
+// lock.
+func (a *Arc) hashNode(n *node, cache bool) subtreeHash {
+	if ret, found := a.hashes[n]; found {
+		return ret
+	}
+
+	h := sha256.New()
+	h.Write(binary.AppendUvarint(nil, uint64(len(n.key))))
+	h.Write(n.key)
+
+	if n.isRecord {
+		h.Write([]byte{flagIsRecord})
+
+		if n.blobValue {
+			h.Write(n.data)
+		} else {
+			digest := sha256.Sum256(n.data)
+			h.Write(digest[:])
+		}
+	} else {
+		h.Write([]byte{0})
+	}
+
+	for child := n.firstChild; child != nil; child = child.nextSibling {
+		childHash := a.hashNode(child, cache)
+		h.Write(childHash[:])
+	}
+
+	var ret subtreeHash
+
+	h.Sum(ret[:0])
+
+	if cache {
+		a.hashes[n] = ret
+	}
+
+	return ret
+}
+
+// refreshSubtreeHashes recomputes the subtree hashes that are affected by
+// writing the given key. The hashes on the path to the key are invalidated,
+// while the nodes whose keys have been modified have already been invalidated
+// by the modification. It is a no-op unless the TrackSubtreeHashes option is
+// enabled. The caller must hold the write lock.
+func (a *Arc) refreshSubtreeHashes(key []byte) {
+	if !a.opts.TrackSubtreeHashes || a.root == nil {
+		return
+	}
+
+	for _, n := range a.nodePath(key) {
+		delete(a.hashes, n)
+	}
+
+	a.hashNode(a.root, true)
+}
+
+// forgetHash discards the cached hash of the node, which is stale once its key
+// has changed, and must not outlive the node once it has been removed from
+// the tree. It is a no-op unless the TrackSubtreeHashes option is enabled.
+func (a *Arc) forgetHash(n *node) {
+	if a.hashes != nil {
+		delete(a.hashes, n)
+	}
+}
+
+// copyHashes returns the cached hashes of the subtree rooted at src, keyed by
+// the nodes of dst, which must be a copy of the subtree.
+func copyHashes(hashes map[*node]subtreeHash, src *node, dst *node) map[*node]subtreeHash {
+	ret := make(map[*node]subtreeHash, len(hashes))
+
+	var walk func(src *node, dst *node)
+
+	walk = func(src *node, dst *node) {
+		if h, found := hashes[src]; found {
+			ret[dst] = h
+		}
+
+		for s, d := src.firstChild, dst.firstChild; s != nil; s, d = s.nextSibling, d.nextSibling {
+			walk(s, d)
+		}
+	}
+
+	if src != nil {
+		walk(src, dst)
+	}
+
+	return ret
+}
@@ -0,0 +1,118 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestRootHash(t *testing.T) {
+	if New().RootHash() != nil {
+		t.Errorf("expected a nil root hash for an empty database")
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	tracked, _ := NewWithOptions(Options{TrackSubtreeHashes: true})
+	untracked := New()
+
+	for i := 0; i < 2000; i++ {
+		key := randomKey(rng, "abc", 6)
+		value := randomKey(rng, "xyz", 2*inlineValueThreshold)
+
+		if rng.Intn(3) == 0 {
+			tracked.Delete(key)
+			untracked.Delete(key)
+		} else {
+			tracked.Put(key, value)
+			untracked.Put(key, value)
+		}
+
+		if i%100 != 0 {
+			continue
+		}
+
+		if got, want := tracked.RootHash(), untracked.RootHash(); !bytes.Equal(got, want) {
+			t.Fatalf("unexpected root hash after %d operations: got:%x, want:%x", i, got, want)
+		}
+
+		assertHashesMaintained(t, tracked)
+	}
+
+	// The same records written in a different order yield the same hash.
+	rebuilt, _ := NewWithOptions(Options{TrackSubtreeHashes: true})
+
+	for key, value := range untracked.ScanReverse(nil) {
+		rebuilt.Put(key, value)
+	}
+
+	if !bytes.Equal(rebuilt.RootHash(), untracked.RootHash()) {
+		t.Errorf("expected the rebuilt database to have the same root hash")
+	}
+
+	for key := range untracked.Keys(nil) {
+		rebuilt.Put(key, []byte("changed"))
+		break
+	}
+
+	if bytes.Equal(rebuilt.RootHash(), untracked.RootHash()) {
+		t.Errorf("expected the root hash to reflect the change")
+	}
+}
+
+func assertHashesMaintained(t *testing.T, a *Arc) {
+	t.Helper()
+
+	var numNodes int
+	var walk func(n *node)
+
+	walk = func(n *node) {
+		numNodes++
+
+		got, found := a.hashes[n]
+
+		if !found {
+			t.Fatalf("expected the hash of %q to be maintained", n.key)
+		}
+
+		// An Arc without cached hashes recomputes the subtree.
+		if want := (&Arc{}).hashNode(n, false); got != want {
+			t.Fatalf("expected the hash of %q to be up to date", n.key)
+		}
+
+		for child := n.firstChild; child != nil; child = child.nextSibling {
+			walk(child)
+		}
+	}
+
+	if a.root != nil {
+		walk(a.root)
+	}
+
+	if len(a.hashes) != numNodes {
+		t.Fatalf("expected %d cached hashes, got:%d", numNodes, len(a.hashes))
+	}
+}
+
+func TestRootHashClone(t *testing.T) {
+	arc, _ := NewWithOptions(Options{TrackSubtreeHashes: true})
+
+	for _, key := range []string{"apple", "apricot", "banana"} {
+		arc.Put([]byte(key), []byte(key))
+	}
+
+	clone := arc.Clone()
+	clone.Put([]byte("app"), []byte("app"))
+	clone.Delete([]byte("banana"))
+
+	assertHashesMaintained(t, arc)
+	assertHashesMaintained(t, clone)
+
+	for key := range arc.hashes {
+		if _, found := clone.hashes[key]; found {
+			t.Fatalf("expected the clone to cache the hashes of its own nodes")
+		}
+	}
+}
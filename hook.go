@@ -0,0 +1,158 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Op identifies a database operation.
+type Op int
+
+const (
+	OpGet        Op = iota // OpGet identifies Get.
+	OpPut                  // OpPut identifies Put, PutReader, and PutWithFlags.
+	OpAdd                  // OpAdd identifies Add.
+	OpDelete               // OpDelete identifies Delete.
+	OpExpire               // OpExpire identifies Expire and ExpireAt.
+	OpSetFlags             // OpSetFlags identifies SetFlags.
+	OpRename               // OpRename identifies Rename.
+	OpMovePrefix           // OpMovePrefix identifies MovePrefix.
+)
+
+// String returns the name of the operation.
+func (op Op) String() string {
+	switch op {
+	case OpGet:
+		return "get"
+	case OpPut:
+		return "put"
+	case OpAdd:
+		return "add"
+	case OpDelete:
+		return "delete"
+	case OpExpire:
+		return "expire"
+	case OpSetFlags:
+		return "setflags"
+	case OpRename:
+		return "rename"
+	case OpMovePrefix:
+		return "moveprefix"
+	default:
+		return fmt.Sprintf("op(%d)", int(op))
+	}
+}
+
+// OpInfo describes an operation that is passed to hooks. Hooks must neither
+// modify nor retain the keys.
+type OpInfo struct {
+	Op  Op     // The operation.
+	Key []byte // The key given to the operation.
+	To  []byte // The new key of Rename, or the new prefix of MovePrefix.
+
+	// ValueSize is the size of the value in bytes. It is set before the
+	// operation for writes, and after the operation for reads.
+	ValueSize int
+
+	// Err is the error returned by the operation. It is only set after the
+	// operation.
+	Err error
+}
+
+// Hook intercepts database operations, which enables logging, authorization,
+// rate limiting, and metrics collection without wrapping the database. Hooks
+// are called without holding the database lock, therefore they may safely
+// call methods of the database.
+type Hook interface {
+	// Before is called before the operation. Returning an error aborts the
+	// operation, and the error is returned to the caller.
+	Before(info OpInfo) error
+
+	// After is called after the operation, including when it failed. It is
+	// only called if Before returned nil for the same hook.
+	After(info OpInfo)
+}
+
+// Use registers a hook. Hooks run their Before callbacks in the order of
+// registration, and their After callbacks in the reverse order.
+func (a *Arc) Use(hook Hook) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var hooks []Hook
+
+	if current := a.hooks.Load(); current != nil {
+		hooks = append(hooks, *current...)
+	}
+
+	hooks = append(hooks, hook)
+	a.hooks.Store(&hooks)
+}
+
+// runHooks runs the operation fn surrounded by the registered hooks. The fn
+// function may update the info that is passed to the After callbacks, which
+// observe the error as is. The returned error is wrapped in an OpError.
+func (a *Arc) runHooks(info OpInfo, fn func(info *OpInfo) error) error {
+	current := a.hooks.Load()
+
+	if current == nil {
+		return wrapOpError(info.Op, info.Key, a.runOp(&info, fn))
+	}
+
+	hooks := *current
+
+	var err error
+	var called int
+
+	for _, h := range hooks {
+		if err = h.Before(info); err != nil {
+			break
+		}
+
+		called++
+	}
+
+	if err == nil {
+		err = a.runOp(&info, fn)
+	}
+
+	info.Err = err
+
+	for i := called - 1; i >= 0; i-- {
+		hooks[i].After(info)
+	}
+
+	return wrapOpError(info.Op, info.Key, err)
+}
+
+// runOp runs the operation fn if the Authorizer permits it, and throttles and
+// persists its writes according to the BackpressurePolicy and the SyncPolicy.
+// The After hooks therefore observe the denied operations, the rejected
+// writes, and the failure to persist. Operations that exceed the
+// SlowOpThreshold are logged.
+func (a *Arc) runOp(info *OpInfo, fn func(info *OpInfo) error) (err error) {
+	if a.logEnabled(slog.LevelWarn) {
+		start := time.Now()
+		defer func() { a.logSlowOp(info, start, err) }()
+	}
+
+	if err := a.authorize(info.Op, info.Key); err != nil {
+		return err
+	}
+
+	if info.Op != OpGet {
+		if err := a.throttle(); err != nil {
+			return err
+		}
+	}
+
+	if err := fn(info); err != nil {
+		return err
+	}
+
+	return a.syncWrite(info.Op)
+}
@@ -0,0 +1,96 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// recordingHook records the hook invocations, and rejects the operations on
+// the keys listed in deny.
+type recordingHook struct {
+	name  string
+	deny  map[string]bool
+	calls *[]string
+}
+
+func (h recordingHook) Before(info OpInfo) error {
+	*h.calls = append(*h.calls, fmt.Sprintf("%s:before:%s:%s:%d", h.name, info.Op, info.Key, info.ValueSize))
+
+	if h.deny[string(info.Key)] {
+		return errTestDenied
+	}
+
+	return nil
+}
+
+func (h recordingHook) After(info OpInfo) {
+	*h.calls = append(*h.calls, fmt.Sprintf("%s:after:%s:%s:%d:%v", h.name, info.Op, info.Key, info.ValueSize, info.Err))
+}
+
+var errTestDenied = errors.New("denied")
+
+func TestHooks(t *testing.T) {
+	arc := New()
+
+	var calls []string
+
+	arc.Use(recordingHook{name: "a", calls: &calls})
+	arc.Use(recordingHook{name: "b", calls: &calls, deny: map[string]bool{"secret": true}})
+
+	arc.Put([]byte("apple"), []byte("red"))
+	arc.Get([]byte("apple"))
+	arc.Add([]byte("apple"), []byte("x"))
+	arc.Delete([]byte("bogus"))
+
+	want := []string{
+		"a:before:put:apple:3",
+		"b:before:put:apple:3",
+		"b:after:put:apple:3:<nil>",
+		"a:after:put:apple:3:<nil>",
+		"a:before:get:apple:0",
+		"b:before:get:apple:0",
+		"b:after:get:apple:3:<nil>",
+		"a:after:get:apple:3:<nil>",
+		"a:before:add:apple:1",
+		"b:before:add:apple:1",
+		"b:after:add:apple:1:" + ErrDuplicateKey.Error(),
+		"a:after:add:apple:1:" + ErrDuplicateKey.Error(),
+		"a:before:delete:bogus:0",
+		"b:before:delete:bogus:0",
+		"b:after:delete:bogus:0:" + ErrKeyNotFound.Error(),
+		"a:after:delete:bogus:0:" + ErrKeyNotFound.Error(),
+	}
+
+	assertKeys(t, calls, want)
+
+	// A rejecting hook aborts the operation, and only the hooks that were
+	// called before it receive the After callback.
+	calls = nil
+
+	if err := arc.Put([]byte("secret"), []byte("1")); !errors.Is(err, errTestDenied) {
+		t.Fatalf("unexpected error: got:%v, want:%v", err, errTestDenied)
+	}
+
+	if _, err := arc.Get([]byte("secret")); !errors.Is(err, errTestDenied) {
+		t.Fatalf("unexpected error: got:%v, want:%v", err, errTestDenied)
+	}
+
+	want = []string{
+		"a:before:put:secret:1",
+		"b:before:put:secret:1",
+		"a:after:put:secret:1:denied",
+		"a:before:get:secret:0",
+		"b:before:get:secret:0",
+		"a:after:get:secret:0:denied",
+	}
+
+	assertKeys(t, calls, want)
+
+	if arc.Len() != 1 {
+		t.Errorf("unexpected record count: got:%d, want:1", arc.Len())
+	}
+}
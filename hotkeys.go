@@ -0,0 +1,135 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sync"
+)
+
+// readCounts holds the number of sampled reads of every record. Reads only
+// hold the read lock of the database, therefore the counts have a lock of
+// their own.
+type readCounts struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+// newReadCounts returns empty readCounts.
+func newReadCounts() *readCounts {
+	return &readCounts{counts: map[string]uint64{}}
+}
+
+// clone returns a copy of the counts.
+func (r *readCounts) clone() *readCounts {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return &readCounts{counts: maps.Clone(r.counts)}
+}
+
+// countRead counts a read of the given record, if the read is sampled. It is
+// a no-op unless the ReadSampleRate option is set. The caller must hold the
+// read lock.
+func (a *Arc) countRead(key []byte) {
+	if a.reads == nil {
+		return
+	}
+
+	if rate := a.opts.ReadSampleRate; rate > 1 && rand.IntN(rate) != 0 {
+		return
+	}
+
+	a.reads.mu.Lock()
+	a.reads.counts[string(key)]++
+	a.reads.mu.Unlock()
+}
+
+// forgetReads discards the read count of a deleted record. The caller must
+// hold the write lock.
+func (a *Arc) forgetReads(key []byte) {
+	if a.reads == nil {
+		return
+	}
+
+	a.reads.mu.Lock()
+	delete(a.reads.counts, string(key))
+	a.reads.mu.Unlock()
+}
+
+// moveReads moves the read count of a renamed record to its new key. The
+// caller must hold the write lock.
+func (a *Arc) moveReads(from []byte, to []byte) {
+	if a.reads == nil {
+		return
+	}
+
+	a.reads.mu.Lock()
+	defer a.reads.mu.Unlock()
+
+	if count, found := a.reads.counts[string(from)]; found {
+		delete(a.reads.counts, string(from))
+		a.reads.counts[string(to)] = count
+	}
+}
+
+// HotKey describes a frequently read record.
+type HotKey struct {
+	Key   []byte // Key of the record.
+	Reads uint64 // Estimated number of reads.
+}
+
+// HotKeys returns the k most read records in descending order of their read
+// counts, which informs caching and capacity planning. Ties are broken by
+// key. The counts are estimated from the reads sampled according to
+// Options.ReadSampleRate, and cover the reads that found the record, such as
+// by Get, since the database was opened. Deleting a record discards its
+// count, and renaming it keeps the count. Expired records, and the records
+// that the Authorizer does not allow to be read, are left out. It returns nil
+// if k is not positive, or if the read counts are disabled.
+func (a *Arc) HotKeys(k int) []HotKey {
+	a.rlock()
+	defer a.runlock()
+
+	if k <= 0 || a.reads == nil {
+		return nil
+	}
+
+	a.reads.mu.Lock()
+
+	ret := make([]HotKey, 0, len(a.reads.counts))
+
+	for key, count := range a.reads.counts {
+		ret = append(ret, HotKey{Key: []byte(key), Reads: count * uint64(a.opts.ReadSampleRate)})
+	}
+
+	a.reads.mu.Unlock()
+
+	slices.SortFunc(ret, func(a HotKey, b HotKey) int {
+		if a.Reads != b.Reads {
+			if a.Reads > b.Reads {
+				return -1
+			}
+
+			return 1
+		}
+
+		return bytes.Compare(a.Key, b.Key)
+	})
+
+	ret = slices.DeleteFunc(ret, func(h HotKey) bool {
+		return !a.visible(h.Key)
+	})
+
+	ret = ret[:min(len(ret), k)]
+
+	for i, h := range ret {
+		ret[i].Key = a.originalKey(h.Key)
+	}
+
+	return ret
+}
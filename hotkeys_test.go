@@ -0,0 +1,137 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestHotKeys(t *testing.T) {
+	arc, _ := NewWithOptions(Options{ReadSampleRate: 1})
+
+	for _, key := range sortedBasicTestKeys() {
+		arc.Put([]byte(key), []byte(key))
+	}
+
+	for i := range 5 {
+		arc.Get([]byte("apple"))
+
+		if i < 3 {
+			arc.Get([]byte("banana"))
+			arc.Get([]byte("apricot"))
+		}
+	}
+
+	// Misses are not counted.
+	arc.Get([]byte("missing"))
+
+	hot := arc.HotKeys(2)
+
+	if len(hot) != 2 || string(hot[0].Key) != "apple" || hot[0].Reads != 5 || string(hot[1].Key) != "apricot" || hot[1].Reads != 3 {
+		t.Fatalf("unexpected hot keys: %+v", hot)
+	}
+
+	// Renaming keeps the count, and deleting discards it.
+	arc.Rename([]byte("apple"), []byte("pineapple"))
+	arc.Delete([]byte("banana"))
+
+	hot = arc.HotKeys(10)
+
+	if len(hot) != 2 || string(hot[0].Key) != "pineapple" || string(hot[1].Key) != "apricot" {
+		t.Errorf("unexpected hot keys: %+v", hot)
+	}
+
+	arc.Put([]byte("banana"), []byte("banana"))
+
+	if hot := arc.HotKeys(10); len(hot) != 2 {
+		t.Errorf("expected the new record to have no reads: %+v", hot)
+	}
+
+	// A clone starts from the counts of the database.
+	clone := arc.Clone()
+	clone.Get([]byte("apricot"))
+
+	if hot := clone.HotKeys(2); hot[1].Reads != 4 {
+		t.Errorf("unexpected count of the clone: %+v", hot)
+	}
+
+	if hot := arc.HotKeys(2); hot[1].Reads != 3 {
+		t.Errorf("unexpected count: %+v", hot)
+	}
+
+	if got := arc.HotKeys(0); got != nil {
+		t.Errorf("unexpected hot keys: %+v", got)
+	}
+}
+
+func TestHotKeysSampled(t *testing.T) {
+	arc, _ := NewWithOptions(Options{ReadSampleRate: 10})
+
+	arc.Put([]byte("hot"), nil)
+	arc.Put([]byte("cold"), nil)
+
+	for range 10000 {
+		arc.Get([]byte("hot"))
+	}
+
+	for range 100 {
+		arc.Get([]byte("cold"))
+	}
+
+	hot := arc.HotKeys(2)
+
+	if len(hot) == 0 || string(hot[0].Key) != "hot" {
+		t.Fatalf("unexpected hot keys: %+v", hot)
+	}
+
+	// The estimate is a multiple of the rate, and close to the reads.
+	if hot[0].Reads%10 != 0 || hot[0].Reads < 8000 || hot[0].Reads > 12000 {
+		t.Errorf("unexpected estimate: %d", hot[0].Reads)
+	}
+}
+
+func TestHotKeysDisabled(t *testing.T) {
+	arc := New()
+	arc.Put([]byte("key"), nil)
+	arc.Get([]byte("key"))
+
+	if got := arc.HotKeys(10); got != nil {
+		t.Errorf("unexpected hot keys: %+v", got)
+	}
+
+	if _, err := NewWithOptions(Options{ReadSampleRate: -1}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestHotKeysConcurrent(t *testing.T) {
+	arc, _ := NewWithOptions(Options{ReadSampleRate: 1})
+	done := make(chan struct{})
+
+	for i := range 4 {
+		arc.Put([]byte(fmt.Sprint(i)), nil)
+	}
+
+	for i := range 4 {
+		go func() {
+			defer func() { done <- struct{}{} }()
+
+			for range 100 {
+				arc.Get([]byte(fmt.Sprint(i)))
+			}
+		}()
+	}
+
+	for range 4 {
+		<-done
+	}
+
+	for _, h := range arc.HotKeys(4) {
+		if h.Reads != 100 {
+			t.Errorf("unexpected count of %q: %d", h.Key, h.Reads)
+		}
+	}
+}
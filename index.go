@@ -0,0 +1,195 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+)
+
+var (
+	// ErrIndexExists is returned when an index is created using a name that
+	// is already taken by another index.
+	ErrIndexExists = errors.New("index already exists")
+
+	// ErrIndexNotFound is returned when the index does not exist.
+	ErrIndexNotFound = errors.New("index not found")
+)
+
+// IndexFunc extracts the index terms of a record. It is called on every write
+// operation, and therefore must be deterministic and free of side effects. The
+// function must not retain or modify the given key and value.
+type IndexFunc func(key []byte, value []byte) [][]byte
+
+// index is a secondary index that maps terms to primary keys. Entries are held
+// in a dedicated radix tree as keys composed of the length-prefixed term and
+// the primary key, which lets lookups share the prefix traversal code.
+type index struct {
+	extract IndexFunc
+	tree    *Arc
+}
+
+// indexUpdate holds the encoded entries to remove from and add to an index as
+// the result of a single record mutation.
+type indexUpdate struct {
+	idx    *index
+	remove [][]byte
+	add    [][]byte
+}
+
+// CreateIndex creates a secondary index that is maintained on every write. The
+// extract function is applied to the existing records to populate the index.
+// It returns ErrIndexExists if the name is already in use.
+func (a *Arc) CreateIndex(name string, extract IndexFunc) error {
+	if a.readOnly {
+		return ErrReadOnly
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, found := a.indexes[name]; found {
+		return ErrIndexExists
+	}
+
+	idx := &index{extract: extract, tree: New()}
+
+	var err error
+
+	a.walkPrefix(nil, func(key []byte, n *node) bool {
+		var value []byte
+
+		if value, err = a.nodeValue(n); err != nil {
+			return false
+		}
+
+		for _, term := range extract(key, value) {
+			if err = idx.tree.insert(encodeIndexEntry(term, key), nil, true); err != nil {
+				return false
+			}
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if a.indexes == nil {
+		a.indexes = map[string]*index{}
+	}
+
+	a.indexes[name] = idx
+
+	return nil
+}
+
+// QueryIndex returns the primary keys of the records that produced the given
+// term in the named index, in the order of the database. It returns ErrIndexNotFound
+// if the index does not exist.
+func (a *Arc) QueryIndex(name string, term []byte) ([][]byte, error) {
+	a.rlock()
+	defer a.runlock()
+
+	idx, found := a.indexes[name]
+
+	if !found {
+		return nil, ErrIndexNotFound
+	}
+
+	var ret [][]byte
+
+	prefix := encodeIndexEntry(term, nil)
+
+	idx.tree.walkPrefix(prefix, func(key []byte, _ *node) bool {
+		if key = key[len(prefix):]; !a.expired(key) {
+			ret = append(ret, a.originalKey(key))
+		}
+
+		return true
+	})
+
+	if a.opts.ReverseOrder {
+		slices.Reverse(ret)
+	}
+
+	return ret, nil
+}
+
+// planIndexUpdates computes the index entries affected by writing the given
+// value, or by deleting the record if deleting is true. Entries are validated
+// up front so that an invalid term cannot leave the indexes half updated.
+func (a *Arc) planIndexUpdates(key []byte, value []byte, deleting bool) ([]indexUpdate, error) {
+	if len(a.indexes) == 0 {
+		return nil, nil
+	}
+
+	var oldValue []byte
+	var hasOld bool
+
+	if n, _, err := a.findNodeAndParent(key); err == nil && n.isRecord {
+		if oldValue, err = a.nodeValue(n); err != nil {
+			return nil, err
+		}
+
+		hasOld = true
+	}
+
+	ret := make([]indexUpdate, 0, len(a.indexes))
+
+	for _, idx := range a.indexes {
+		update := indexUpdate{idx: idx}
+
+		if hasOld {
+			for _, term := range idx.extract(key, oldValue) {
+				update.remove = append(update.remove, encodeIndexEntry(term, key))
+			}
+		}
+
+		if !deleting {
+			for _, term := range idx.extract(key, value) {
+				entry := encodeIndexEntry(term, key)
+
+				if err := idx.tree.checkKey(entry); err != nil {
+					return nil, err
+				}
+
+				update.add = append(update.add, entry)
+			}
+		}
+
+		ret = append(ret, update)
+	}
+
+	return ret, nil
+}
+
+// applyIndexUpdates removes the stale entries and then adds the new entries.
+// Entries that share a term are therefore preserved.
+func (a *Arc) applyIndexUpdates(updates []indexUpdate) {
+	for _, update := range updates {
+		for _, entry := range update.remove {
+			// Duplicate terms yield the same entry more than once.
+			update.idx.tree.delete(entry)
+		}
+
+		for _, entry := range update.add {
+			update.idx.tree.insert(entry, nil, true)
+		}
+	}
+}
+
+// encodeIndexEntry encodes the index term and the primary key into a single
+// index tree key. The term is prefixed by its length, which prevents a term
+// from matching the entries of another term that it happens to prefix.
+func encodeIndexEntry(term []byte, primaryKey []byte) []byte {
+	ret := make([]byte, 0, binary.MaxVarintLen64+len(term)+len(primaryKey))
+	ret = binary.AppendUvarint(ret, uint64(len(term)))
+	ret = append(ret, term...)
+	ret = append(ret, primaryKey...)
+
+	return ret
+}
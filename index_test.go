@@ -0,0 +1,113 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCreateIndex(t *testing.T) {
+	arc := basicTestTree()
+
+	if err := arc.CreateIndex("byValue", valueIndexFunc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := arc.CreateIndex("byValue", valueIndexFunc); err != ErrIndexExists {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrIndexExists)
+	}
+
+	// Existing records must be indexed on creation.
+	for _, row := range basicTestTreeData() {
+		assertIndexQuery(t, arc, "byValue", row.data, row.key)
+	}
+
+	if _, err := arc.QueryIndex("bogus", []byte("jam")); err != ErrIndexNotFound {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrIndexNotFound)
+	}
+}
+
+func TestIndexMaintenance(t *testing.T) {
+	arc := New()
+
+	if err := arc.CreateIndex("byValue", valueIndexFunc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	arc.Put([]byte("apple"), []byte("red"))
+	arc.Put([]byte("cherry"), []byte("red"))
+	arc.Put([]byte("lime"), []byte("green"))
+	arc.Put([]byte("plum"), blobValueX())
+
+	assertIndexQuery(t, arc, "byValue", []byte("red"), []byte("apple"), []byte("cherry"))
+	assertIndexQuery(t, arc, "byValue", blobValueX(), []byte("plum"))
+
+	// A term that is a prefix of another term must not match its entries.
+	assertIndexQuery(t, arc, "byValue", []byte("re"))
+
+	// Updating a record moves it to the new term.
+	arc.Put([]byte("apple"), []byte("green"))
+	assertIndexQuery(t, arc, "byValue", []byte("red"), []byte("cherry"))
+	assertIndexQuery(t, arc, "byValue", []byte("green"), []byte("apple"), []byte("lime"))
+
+	// Rewriting the same value must keep the entry.
+	arc.Put([]byte("lime"), []byte("green"))
+	assertIndexQuery(t, arc, "byValue", []byte("green"), []byte("apple"), []byte("lime"))
+
+	// Failed insertions must not touch the index.
+	if err := arc.Add([]byte("cherry"), []byte("black")); !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("unexpected error: got:%v, want:%v", err, ErrDuplicateKey)
+	}
+
+	assertIndexQuery(t, arc, "byValue", []byte("black"))
+	assertIndexQuery(t, arc, "byValue", []byte("red"), []byte("cherry"))
+
+	// Deleting a record removes its entries.
+	arc.Delete([]byte("cherry"))
+	arc.Delete([]byte("plum"))
+	assertIndexQuery(t, arc, "byValue", []byte("red"))
+	assertIndexQuery(t, arc, "byValue", blobValueX())
+
+	// Multiple and duplicate terms per record.
+	multi := func(key []byte, value []byte) [][]byte {
+		return bytes.Split(value, []byte(","))
+	}
+
+	if err := arc.CreateIndex("tags", multi); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	arc.Put([]byte("post"), []byte("go,db,go"))
+	assertIndexQuery(t, arc, "tags", []byte("go"), []byte("post"))
+	assertIndexQuery(t, arc, "tags", []byte("db"), []byte("post"))
+
+	arc.Delete([]byte("post"))
+	assertIndexQuery(t, arc, "tags", []byte("go"))
+}
+
+func valueIndexFunc(key []byte, value []byte) [][]byte {
+	return [][]byte{value}
+}
+
+func assertIndexQuery(t *testing.T, arc *Arc, name string, term []byte, want ...[]byte) {
+	t.Helper()
+
+	got, err := arc.QueryIndex(name, term)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("unexpected keys for %q: got:%q, want:%q", term, got, want)
+	}
+
+	for i := range got {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("unexpected key: got:%q, want:%q", got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Invalidation is an event that an InvalidationBus propagates after a write,
+// which tells the other databases of the topic to discard the records that the
+// write covers.
+type Invalidation struct {
+	Topic string // Topic of the database that was written.
+	Op    Op     // The write, such as OpPut or OpDelete.
+	Key   []byte // The key given to the write.
+	To    []byte // The new key of OpRename, or the new prefix of OpMovePrefix.
+}
+
+// InvalidationBus propagates invalidations between the databases of a
+// process, such as caches of the same data kept per tenant. Databases join a
+// topic, and the successful writes to a member are propagated to the other
+// members of the topic, which delete the records that the write covers: the
+// key of a write, both keys of OpRename, and every record under both prefixes
+// of OpMovePrefix. The writes are observed by a Hook, therefore the writes
+// that do not run the hooks, such as transactions and expiration sweeps, are
+// not propagated. The deletions themselves do not run the hooks, which keeps
+// them from being propagated again. The members of a topic must use the same
+// KeyTransform, since the keys are propagated as transformed.
+//
+// Invalidations can be propagated across processes by a message bus, such as
+// NATS or Redis. The forward function given to NewInvalidationBus publishes
+// every local invalidation to the message bus, and the invalidations received
+// from it are applied by Deliver.
+type InvalidationBus struct {
+	forward func(Invalidation)
+
+	// Guards the fields below.
+	mu sync.RWMutex
+
+	// Maps the members to their topics.
+	topics map[*Arc]string
+
+	// Members whose hook is registered. Hooks cannot be removed, therefore
+	// the hook of a member that leaves remains, but does nothing.
+	hooked map[*Arc]bool
+}
+
+// NewInvalidationBus returns an empty InvalidationBus. The forward function,
+// if not nil, is called with every invalidation of a member after it has been
+// applied to the other members. It is called from the goroutine that wrote the
+// member, and must neither modify nor retain the keys.
+func NewInvalidationBus(forward func(Invalidation)) *InvalidationBus {
+	return &InvalidationBus{forward: forward, topics: map[*Arc]string{}, hooked: map[*Arc]bool{}}
+}
+
+// Join adds the database to the given topic. A database belongs to at most one
+// topic of the bus, therefore joining another topic leaves the current one.
+func (b *InvalidationBus) Join(topic string, db *Arc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.topics[db] = topic
+
+	if !b.hooked[db] {
+		b.hooked[db] = true
+		db.Use(&invalidationHook{bus: b, db: db})
+	}
+}
+
+// Leave removes the database from its topic. It is a no-op if the database
+// is not a member.
+func (b *InvalidationBus) Leave(db *Arc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.topics, db)
+}
+
+// Deliver applies an invalidation that was received from another process to
+// every member of its topic. It is not forwarded.
+func (b *InvalidationBus) Deliver(inv Invalidation) {
+	for _, db := range b.members(inv.Topic, nil) {
+		db.invalidate(inv)
+	}
+}
+
+// publish applies the invalidation of a write to the given member to the
+// other members of its topic, and forwards it.
+func (b *InvalidationBus) publish(origin *Arc, inv Invalidation) {
+	for _, db := range b.members(inv.Topic, origin) {
+		db.invalidate(inv)
+	}
+
+	if b.forward != nil {
+		b.forward(inv)
+	}
+}
+
+// members returns the members of the topic other than the given database.
+// The members are invalidated after the lock is released, which lets a member
+// join or leave from within a hook.
+func (b *InvalidationBus) members(topic string, except *Arc) []*Arc {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var ret []*Arc
+
+	for db, t := range b.topics {
+		if t == topic && db != except {
+			ret = append(ret, db)
+		}
+	}
+
+	return ret
+}
+
+// invalidationHook publishes the invalidations of the writes to a member of
+// an InvalidationBus.
+type invalidationHook struct {
+	bus *InvalidationBus
+	db  *Arc
+}
+
+// Before implements Hook.
+func (h *invalidationHook) Before(OpInfo) error {
+	return nil
+}
+
+// After implements Hook. It publishes the successful writes of the member.
+func (h *invalidationHook) After(info OpInfo) {
+	if info.Op == OpGet || info.Err != nil {
+		return
+	}
+
+	h.bus.mu.RLock()
+	topic, found := h.bus.topics[h.db]
+	h.bus.mu.RUnlock()
+
+	if !found {
+		return
+	}
+
+	h.bus.publish(h.db, Invalidation{Topic: topic, Op: info.Op, Key: info.Key, To: info.To})
+}
+
+// invalidate deletes the records that the invalidation covers without running
+// the hooks. Records that do not exist are skipped, and so are read-only
+// databases.
+func (a *Arc) invalidate(inv Invalidation) {
+	if a.readOnly {
+		return
+	}
+
+	a.lock()
+	defer a.mu.Unlock()
+
+	keys := [][]byte{inv.Key}
+
+	if inv.Op == OpRename {
+		keys = append(keys, inv.To)
+	}
+
+	if inv.Op == OpMovePrefix {
+		keys = nil
+
+		for _, prefix := range [][]byte{inv.Key, inv.To} {
+			a.walkPrefix(a.foldKey(prefix), func(key []byte, _ *node) bool {
+				keys = append(keys, key)
+				return true
+			})
+		}
+	}
+
+	// The keys of the invalidation belong to the writer, and the deletion
+	// may retain them, such as in a tombstone.
+	for _, key := range keys {
+		a.deleteRecord(a.foldKey(bytes.Clone(key)))
+	}
+}
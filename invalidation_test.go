@@ -0,0 +1,98 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// seededArc returns a database that holds the given keys.
+func seededArc(keys ...string) *Arc {
+	ret := New()
+
+	for _, key := range keys {
+		ret.Put([]byte(key), []byte(key))
+	}
+
+	return ret
+}
+
+func TestInvalidationBus(t *testing.T) {
+	var forwarded []Invalidation
+
+	bus := NewInvalidationBus(func(inv Invalidation) {
+		forwarded = append(forwarded, inv)
+	})
+
+	writer := seededArc()
+	cache := seededArc("apple", "banana", "cherry", "users/alice", "users/bob")
+	other := seededArc("apple")
+
+	bus.Join("catalog", writer)
+	bus.Join("catalog", cache)
+	bus.Join("accounts", other)
+
+	writer.Put([]byte("apple"), []byte("green"))
+	writer.Rename([]byte("apple"), []byte("banana"))
+	writer.Put([]byte("users/carol"), nil)
+	writer.MovePrefix([]byte("users/"), []byte("people/"))
+
+	// Failed writes and reads are not propagated.
+	writer.Delete([]byte("missing"))
+	writer.Get([]byte("cherry"))
+
+	assertKeys(t, toStrings(slices.Collect(cache.Keys(nil))), []string{"cherry"})
+
+	if found, _ := writer.Has([]byte("banana")); !found {
+		t.Errorf("expected the writer to keep its records")
+	}
+
+	if found, _ := other.Has([]byte("apple")); !found {
+		t.Errorf("expected other topics to be left alone")
+	}
+
+	if len(forwarded) != 4 || forwarded[1].Op != OpRename || string(forwarded[1].To) != "banana" || forwarded[0].Topic != "catalog" {
+		t.Errorf("unexpected forwarded invalidations: %+v", forwarded)
+	}
+
+	// Members that leave are no longer invalidated, nor propagate.
+	bus.Leave(cache)
+	cache.Put([]byte("apple"), nil)
+	writer.Put([]byte("apple"), []byte("red"))
+
+	if found, _ := cache.Has([]byte("apple")); !found {
+		t.Errorf("expected the member that left to keep its records")
+	}
+
+	if len(forwarded) != 5 {
+		t.Errorf("unexpected forwarded invalidations: %d", len(forwarded))
+	}
+}
+
+func TestInvalidationBusDeliver(t *testing.T) {
+	bus := NewInvalidationBus(func(Invalidation) {
+		t.Errorf("expected delivered invalidations not to be forwarded")
+	})
+
+	first := seededArc("apple", "banana")
+	second := seededArc("apple", "banana")
+
+	bus.Join("catalog", first)
+	bus.Join("catalog", second)
+
+	bus.Deliver(Invalidation{Topic: "catalog", Op: OpPut, Key: []byte("apple")})
+	bus.Deliver(Invalidation{Topic: "unknown", Op: OpPut, Key: []byte("banana")})
+
+	for _, db := range []*Arc{first, second} {
+		if _, err := db.Get([]byte("apple")); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if db.Len() != 1 {
+			t.Errorf("unexpected length: %d", db.Len())
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+const (
+	// minKeyArenaChunkBytes and maxKeyArenaChunkBytes bound the size of the
+	// chunks that the key arena allocates. Chunks start small and double,
+	// so that small databases and index trees do not reserve large chunks.
+	minKeyArenaChunkBytes = 1 << 10
+	maxKeyArenaChunkBytes = 64 << 10
+
+	// maxKeyArenaKeyBytes is the length of the longest key that the arena
+	// holds. Longer keys are allocated on their own, since they would waste
+	// the tail of a chunk.
+	maxKeyArenaKeyBytes = 256
+
+	// minKeyArenaReclaimBytes is the number of bytes that the arena hands
+	// out before it first checks whether the node keys should be repacked.
+	minKeyArenaReclaimBytes = 1 << 20
+)
+
+// keyArena allocates node keys from shared chunks instead of allocating every
+// key on its own. This saves the allocator overhead of many small objects,
+// which is rounded up to size classes and tracked by the garbage collector,
+// and places the keys that are inserted together next to each other. The
+// nodes still reference their keys through a slice header, therefore the size
+// of the node struct is unchanged. Bytes that are handed out are never
+// modified by the arena, therefore nodes that are shared with clones may keep
+// referencing a chunk while the arena fills its tail. The arena is not safe
+// for concurrent use, and is modified under the write lock of the database
+// that it belongs to. A nil arena allocates every key on its own.
+type keyArena struct {
+	chunk       []byte // Unused tail of the current chunk.
+	chunkBytes  int    // Size of the current chunk.
+	allocated   int    // Bytes handed out since the arena was created.
+	nextReclaim int    // Value of allocated at which reclaimKeys checks.
+}
+
+// clone returns a copy of the key that is allocated from the arena.
+func (ka *keyArena) clone(key []byte) []byte {
+	if key == nil {
+		return nil
+	}
+
+	ret := ka.alloc(len(key))
+	copy(ret, key)
+
+	return ret
+}
+
+// alloc returns a zeroed byte slice of the given length. The capacity of the
+// slice is its length, which keeps appends from overwriting the keys that
+// follow it in the chunk.
+func (ka *keyArena) alloc(n int) []byte {
+	if ka == nil || n > maxKeyArenaKeyBytes {
+		return make([]byte, n)
+	}
+
+	if n > len(ka.chunk) {
+		ka.chunkBytes = min(max(2*ka.chunkBytes, minKeyArenaChunkBytes), maxKeyArenaChunkBytes)
+		ka.chunk = make([]byte, ka.chunkBytes)
+	}
+
+	ret := ka.chunk[:n:n]
+	ka.chunk = ka.chunk[n:]
+	ka.allocated += n
+
+	return ret
+}
+
+// newRecordNode returns a new record node whose key is copied into the key
+// arena. The caller must hold the write lock.
+func (a *Arc) newRecordNode(key []byte, value []byte) *node {
+	return newRecordNode(a.blobs, a.makeBlobID, a.keys.clone(key), value)
+}
+
+// compactKeys moves the keys of every node into a new arena in pre-order. The
+// chunks of the old arena are released once no node references them, which
+// reclaims the bytes of the keys that were deleted, shortened by node splits,
+// or replaced by merges. It also moves the keys that were decoded from the
+// database file into the arena. The caller must hold the write lock, and the
+// database must not share its nodes with clones.
+func (a *Arc) compactKeys() {
+	a.repackKeys()
+
+	for _, idx := range a.indexes {
+		idx.tree.compactKeys()
+	}
+}
+
+// repackKeys moves the keys of the nodes of the tree into a new arena in
+// pre-order, without the secondary index trees. The caller must hold the
+// write lock, and the database must not share its nodes with clones.
+func (a *Arc) repackKeys() {
+	a.keys = &keyArena{}
+
+	var visit func(n *node)
+
+	visit = func(n *node) {
+		n.key = a.keys.clone(n.key)
+
+		for child := n.firstChild; child != nil; child = child.nextSibling {
+			visit(child)
+		}
+	}
+
+	if a.root != nil {
+		visit(a.root)
+	}
+}
+
+// reclaimKeys repacks the keys of the tree once the arena has handed out more
+// than twice the bytes of the keys that the nodes still hold, which releases
+// the chunks of the keys that were deleted, shortened by node splits, or
+// replaced by merges. This is how databases that are not file-backed, and
+// therefore are never compacted, reclaim their chunks. The tree is only
+// walked each time the arena has handed out twice as many bytes as at the
+// previous check, which amortizes the walk over the writes. The caller must
+// hold the write lock, and the database must not share its nodes with
+// clones.
+func (a *Arc) reclaimKeys() {
+	if a.keys == nil || a.keys.allocated < a.keys.nextReclaim {
+		return
+	}
+
+	live := 0
+
+	var visit func(n *node)
+
+	visit = func(n *node) {
+		if len(n.key) <= maxKeyArenaKeyBytes {
+			live += len(n.key)
+		}
+
+		for child := n.firstChild; child != nil; child = child.nextSibling {
+			visit(child)
+		}
+	}
+
+	if a.root != nil {
+		visit(a.root)
+	}
+
+	if a.keys.allocated > 2*live {
+		a.repackKeys()
+	}
+
+	a.keys.nextReclaim = max(2*a.keys.allocated, minKeyArenaReclaimBytes)
+}
@@ -0,0 +1,128 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"unsafe"
+)
+
+func TestKeyArena(t *testing.T) {
+	ka := &keyArena{}
+
+	first := ka.clone([]byte("apple"))
+	second := ka.clone([]byte("banana"))
+
+	if string(first) != "apple" || string(second) != "banana" {
+		t.Fatalf("unexpected keys: %q, %q", first, second)
+	}
+
+	if cap(first) != len(first) {
+		t.Errorf("unexpected capacity: got:%d, want:%d", cap(first), len(first))
+	}
+
+	// Consecutive keys are adjacent in the chunk.
+	if unsafe.Pointer(&first[len(first)-1:][0]) != unsafe.Add(unsafe.Pointer(&second[0]), -1) {
+		t.Errorf("expected the keys to share a chunk")
+	}
+
+	// Appending to a key does not overwrite the key that follows it.
+	_ = append(first, 'x')
+
+	if string(second) != "banana" {
+		t.Errorf("unexpected key: got:%q, want:%q", second, "banana")
+	}
+
+	if ka.clone(nil) != nil {
+		t.Errorf("expected a nil key to stay nil")
+	}
+
+	// Chunks grow until they reach the maximum size.
+	for range 1000 {
+		ka.alloc(maxKeyArenaKeyBytes)
+	}
+
+	if ka.chunkBytes != maxKeyArenaChunkBytes {
+		t.Errorf("unexpected chunk size: got:%d, want:%d", ka.chunkBytes, maxKeyArenaChunkBytes)
+	}
+
+	var nilArena *keyArena
+
+	if got := nilArena.clone([]byte("key")); string(got) != "key" {
+		t.Errorf("unexpected key: got:%q, want:%q", got, "key")
+	}
+}
+
+func TestKeyArenaOwnsKeys(t *testing.T) {
+	arc := New()
+	key := []byte("apple")
+
+	arc.Put(key, []byte("cider"))
+	copy(key, "grape")
+
+	if value, err := arc.Get([]byte("apple")); err != nil || string(value) != "cider" {
+		t.Errorf("expected the tree to own its keys: %v", err)
+	}
+}
+
+func TestCompactKeys(t *testing.T) {
+	arc := New()
+	arc.CreateIndex("value", valueIndexFunc)
+
+	for i := range 100 {
+		arc.Put([]byte(fmt.Sprintf("user:%03d", i)), []byte(fmt.Sprintf("group%d", i%10)))
+	}
+
+	for i := range 100 {
+		if i%4 != 0 {
+			arc.Delete([]byte(fmt.Sprintf("user:%03d", i)))
+		}
+	}
+
+	want := arc.Clone()
+
+	arc.lock()
+	arc.compactKeys()
+	arc.mu.Unlock()
+
+	assertSameRecords(t, arc, want)
+	assertIndexQuery(t, arc, "value", []byte("group0"), []byte("user:000"), []byte("user:020"), []byte("user:040"), []byte("user:060"), []byte("user:080"))
+
+	// The keys are packed in pre-order, therefore the key of the first
+	// child directly follows the key of the root.
+	root, child := arc.root.key, arc.root.firstChild.key
+
+	if unsafe.Pointer(&root[len(root)-1:][0]) != unsafe.Add(unsafe.Pointer(&child[0]), -1) {
+		t.Errorf("expected the keys to be packed")
+	}
+
+	arc.Put([]byte("user:001"), nil)
+
+	if _, err := want.Get([]byte("user:001")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+}
+
+func TestReclaimKeys(t *testing.T) {
+	arc := New()
+	arc.Put([]byte("live"), nil)
+
+	// Every write allocates a key from the arena, but only one key remains,
+	// therefore the chunks are reclaimed without a file to compact.
+	for i := range 300000 {
+		key := []byte(fmt.Sprintf("key:%08d", i))
+		arc.Put(key, nil)
+		arc.Delete(key)
+	}
+
+	if arc.keys.allocated >= 2*minKeyArenaReclaimBytes {
+		t.Errorf("expected the arena to be repacked: allocated:%d", arc.keys.allocated)
+	}
+
+	if value, err := arc.Get([]byte("live")); err != nil || value != nil {
+		t.Errorf("unexpected value: %q, err:%v", value, err)
+	}
+}
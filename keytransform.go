@@ -0,0 +1,139 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ErrKeyTransformMismatch is returned when a database file is opened with a
+// KeyTransform other than the one that it was written with, or with different
+// CaseInsensitiveKeys or ReverseOrder settings.
+var ErrKeyTransformMismatch = errors.New("key transform mismatch")
+
+// maxKeyTransformNameLen is the maximum length of a KeyTransform name, which
+// is imposed by the file format.
+const maxKeyTransformNameLen = math.MaxUint8
+
+// KeyTransform rewrites keys before they reach the tree, such as to normalize
+// them. It is applied to the keys and prefixes given to every method, and the
+// database only ever sees the transformed keys. Iterators and navigation
+// methods therefore yield the transformed keys. Transforms that do not
+// preserve prefixes, such as hashing, make prefix operations meaningless.
+//
+// The name of the transform is recorded in the database file, and opening the
+// file with a different transform fails with ErrKeyTransformMismatch. This
+// ensures that persisted keys remain consistent with the lookups. Changing
+// the behavior of a transform requires changing its name.
+type KeyTransform interface {
+	// Name identifies the transform. It must be 1 to 255 bytes long.
+	Name() string
+
+	// Transform returns the transformed key. It must be deterministic, and
+	// must not modify the given key.
+	Transform(key []byte) []byte
+}
+
+// funcKeyTransform is a KeyTransform backed by a function.
+type funcKeyTransform struct {
+	name string
+	fn   func(key []byte) []byte
+}
+
+// Name returns the name of the transform.
+func (t funcKeyTransform) Name() string {
+	return t.name
+}
+
+// Transform returns the transformed key.
+func (t funcKeyTransform) Transform(key []byte) []byte {
+	return t.fn(key)
+}
+
+// NewKeyTransform returns a KeyTransform with the given name, which applies
+// fn to the keys. For example, Unicode normalization can be implemented by
+// wrapping norm.NFC.Bytes from golang.org/x/text/unicode/norm.
+func NewKeyTransform(name string, fn func(key []byte) []byte) KeyTransform {
+	return funcKeyTransform{name: name, fn: fn}
+}
+
+// LowercaseKeys is a KeyTransform that maps keys to lower case, treating them
+// as UTF-8 encoded text.
+var LowercaseKeys = NewKeyTransform("lowercase", bytes.ToLower)
+
+// HashLongKeys returns a KeyTransform that shortens the keys that exceed
+// maxLen bytes, by replacing their tail with the SHA-256 hash of the whole
+// key. The result is exactly maxLen bytes long, and retains as much of the
+// original key as possible. Values of maxLen below the size of the hash are
+// raised to it.
+func HashLongKeys(maxLen int) KeyTransform {
+	maxLen = max(maxLen, sha256.Size)
+	name := fmt.Sprintf("hashlongkeys:%d", maxLen)
+
+	return NewKeyTransform(name, func(key []byte) []byte {
+		if len(key) <= maxLen {
+			return key
+		}
+
+		sum := sha256.Sum256(key)
+		ret := make([]byte, 0, maxLen)
+		ret = append(ret, key[:maxLen-len(sum)]...)
+
+		return append(ret, sum[:]...)
+	})
+}
+
+// keyTransformName returns the name that identifies the treatment of the keys
+// in the file header, which accounts for the configured KeyTransform, and for
+// the CaseInsensitiveKeys and ReverseOrder settings. The BlobHash is recorded
+// along with them, since it must match just as well. It returns an empty
+// string if the keys are stored as is, in ascending order, and the blobs are
+// identified by SHA-256.
+func (o Options) keyTransformName() string {
+	var names []string
+
+	if o.KeyTransform != nil {
+		names = append(names, o.KeyTransform.Name())
+	}
+
+	if o.CaseInsensitiveKeys {
+		names = append(names, caseFoldTransformName)
+	}
+
+	if o.ReverseOrder {
+		names = append(names, reverseOrderName)
+	}
+
+	if o.LargeKeys {
+		names = append(names, largeKeyName)
+	}
+
+	if o.BlobHash != nil {
+		names = append(names, blobHashNamePrefix+o.BlobHash.Name())
+	}
+
+	return strings.Join(names, "+")
+}
+
+// transformKey returns the key under which the given key is stored in the
+// tree. It applies the configured KeyTransform, and then folds the case of
+// the key if CaseInsensitiveKeys is set.
+func (a *Arc) transformKey(key []byte) []byte {
+	return a.foldKey(a.applyKeyTransform(key))
+}
+
+// applyKeyTransform applies the configured KeyTransform to the given key. Nil
+// keys are returned as is, since they carry a meaning of their own.
+func (a *Arc) applyKeyTransform(key []byte) []byte {
+	if a.opts.KeyTransform == nil || key == nil {
+		return key
+	}
+
+	return a.opts.KeyTransform.Transform(key)
+}
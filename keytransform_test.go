@@ -0,0 +1,112 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLowercaseKeys(t *testing.T) {
+	arc, _ := NewWithOptions(Options{KeyTransform: LowercaseKeys})
+
+	arc.Put([]byte("Apple"), []byte("1"))
+	arc.Put([]byte("APRICOT"), []byte("2"))
+
+	if err := arc.Add([]byte("apple"), []byte("3")); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrDuplicateKey)
+	}
+
+	if got, err := arc.Get([]byte("aPpLe")); err != nil || !bytes.Equal(got, []byte("1")) {
+		t.Errorf("unexpected value: got:%q, err:%v", got, err)
+	}
+
+	var keys []string
+
+	for key := range arc.Keys([]byte("AP")) {
+		keys = append(keys, string(key))
+	}
+
+	if want := []string{"apple", "apricot"}; !slices.Equal(keys, want) {
+		t.Errorf("unexpected keys: got:%q, want:%q", keys, want)
+	}
+
+	if n, _ := arc.CountPrefix([]byte("APR")); n != 1 {
+		t.Errorf("unexpected count: got:%d, want:1", n)
+	}
+
+	if c := arc.Cursor(nil); !c.Seek([]byte("APR")) || string(c.Key()) != "apricot" {
+		t.Errorf("unexpected cursor key: %q", c.Key())
+	}
+
+	if err := arc.Delete([]byte("APPLE")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if arc.Len() != 1 {
+		t.Errorf("unexpected length: got:%d, want:1", arc.Len())
+	}
+}
+
+func TestHashLongKeys(t *testing.T) {
+	transform := HashLongKeys(40)
+	short := []byte("short")
+
+	if got := transform.Transform(short); !bytes.Equal(got, short) {
+		t.Errorf("expected short keys to be left as is, got:%q", got)
+	}
+
+	long := bytes.Repeat([]byte("k"), 100)
+	got := transform.Transform(long)
+
+	if len(got) != 40 || !bytes.HasPrefix(got, long[:8]) {
+		t.Errorf("unexpected transformed key: %x", got)
+	}
+
+	other := append(bytes.Repeat([]byte("k"), 99), 'x')
+
+	if bytes.Equal(transform.Transform(other), got) {
+		t.Errorf("expected distinct keys to remain distinct")
+	}
+
+	if got := HashLongKeys(1).Transform(long); len(got) != 32 {
+		t.Errorf("unexpected length: got:%d, want:32", len(got))
+	}
+}
+
+func TestKeyTransformPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := OpenWithOptions(path, Options{KeyTransform: LowercaseKeys})
+
+	arc.Put([]byte("Hello"), []byte("world"))
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := Open(path); err != ErrKeyTransformMismatch {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyTransformMismatch)
+	}
+
+	upper := NewKeyTransform("uppercase", bytes.ToUpper)
+
+	if _, err := OpenWithOptions(path, Options{KeyTransform: upper}); err != ErrKeyTransformMismatch {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyTransformMismatch)
+	}
+
+	reopened, err := OpenWithOptions(path, Options{KeyTransform: LowercaseKeys})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer reopened.Close()
+
+	if got, err := reopened.Get([]byte("HELLO")); err != nil || string(got) != "world" {
+		t.Errorf("unexpected value: got:%q, err:%v", got, err)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import "bytes"
+
+// largeKeyName is recorded in the file header along with the name of the
+// KeyTransform when LargeKeys is set.
+const largeKeyName = "largekeys"
+
+// largeKeyPrefixLen is the number of leading bytes of a large key that are
+// kept in the tree. They are followed by the blobID of the full key, which
+// makes the stored key exactly as long as the file format allows.
+const largeKeyPrefixLen = maxKeyBytes - blobIDLen
+
+// keyBytesLimit returns the upper limit of the MaxKeyBytes option.
+func (o Options) keyBytesLimit() int {
+	if o.LargeKeys {
+		return maxValueBytes
+	}
+
+	return maxKeyBytes
+}
+
+// digestKey returns the key under which the given key is stored in the tree.
+// Keys that exceed the file format limit are replaced by their leading bytes
+// followed by their blobID if LargeKeys is set. Keys beyond MaxKeyBytes are
+// returned as is, so that they are rejected by checkKey.
+func (a *Arc) digestKey(key []byte) []byte {
+	if !a.opts.LargeKeys || len(key) <= maxKeyBytes || len(key) > a.opts.MaxKeyBytes {
+		return key
+	}
+
+	id := a.makeBlobID(key)
+	ret := make([]byte, 0, maxKeyBytes)
+	ret = append(ret, key[:largeKeyPrefixLen]...)
+
+	return append(ret, id[:]...)
+}
+
+// isLargeKey returns true if the given tree key is the stored form of a large
+// key.
+func (a *Arc) isLargeKey(key []byte) bool {
+	return a.opts.LargeKeys && len(key) == maxKeyBytes
+}
+
+// checkLargeKey returns ErrKeyTooLarge if the given key, as it was passed to
+// a write, has the length of the stored form of the large keys, which it would
+// be mistaken for.
+func (a *Arc) checkLargeKey(key []byte) error {
+	if a.opts.LargeKeys && len(key) == maxKeyBytes {
+		return &SizeError{Err: ErrKeyTooLarge, Size: len(key), Limit: maxKeyBytes - 1}
+	}
+
+	return nil
+}
+
+// largeKey returns the full key of the record that is stored under the given
+// tree key, or nil if the key is not the stored form of a large key. The
+// caller must hold the read lock.
+func (a *Arc) largeKey(key []byte) []byte {
+	if !a.isLargeKey(key) {
+		return nil
+	}
+
+	if b, found := a.blobs[blobID(key[largeKeyPrefixLen:])]; found {
+		value, _ := b.load()
+		return value
+	}
+
+	return nil
+}
+
+// retainLargeKey adds a reference to the blob that holds the full key of a
+// newly inserted record. It is a no-op unless the key is the stored form of a
+// large key. The caller must hold the write lock.
+func (a *Arc) retainLargeKey(key []byte, original []byte) {
+	if a.isLargeKey(key) {
+		a.blobs.put(blobID(key[largeKeyPrefixLen:]), bytes.Clone(original))
+	}
+}
+
+// releaseLargeKey releases the blob that holds the full key of a record that
+// is being deleted. It is a no-op unless the key is the stored form of a large
+// key. The caller must hold the write lock.
+func (a *Arc) releaseLargeKey(key []byte) {
+	if a.isLargeKey(key) {
+		a.blobs.release(key[largeKeyPrefixLen:])
+	}
+}
+
+// walkLargeKeys calls fn with the blobID of the full key of every record whose
+// key is large. The caller must hold the read lock.
+func (a *Arc) walkLargeKeys(fn func(id blobID)) {
+	if !a.opts.LargeKeys {
+		return
+	}
+
+	a.walkPrefix(nil, func(key []byte, _ *node) bool {
+		if a.isLargeKey(key) {
+			fn(blobID(key[largeKeyPrefixLen:]))
+		}
+
+		return true
+	})
+}
+
+// countLargeKeys returns the number of records that reference every blob that
+// holds the full key of a large key. The full keys share the blobStore with the
+// values, and are left out of the value reports by subtracting these counts.
+// It returns nil unless LargeKeys is set. The caller must hold the read lock.
+func (a *Arc) countLargeKeys() map[blobID]int {
+	var ret map[blobID]int
+
+	a.walkLargeKeys(func(id blobID) {
+		if ret == nil {
+			ret = map[blobID]int{}
+		}
+
+		ret[id]++
+	})
+
+	return ret
+}
+
+// attachLargeKeys adds the blobs that hold the full keys of the loaded records
+// to the blobStore, or increments their reference counts if they were already
+// added. The records must already be loaded.
+func (a *Arc) attachLargeKeys(contents map[blobID][]byte) error {
+	var err error
+
+	a.walkLargeKeys(func(id blobID) {
+		if b, found := a.blobs[id]; found {
+			b.refCount++
+		} else if content, found := contents[id]; found {
+			a.blobs[id] = &blob{value: content, refCount: 1}
+		} else {
+			err = ErrCorrupted
+		}
+	})
+
+	return err
+}
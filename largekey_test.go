@@ -0,0 +1,175 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// largeTestKey returns a key that exceeds the file format limit, and differs
+// from the other large test keys only in its last byte.
+func largeTestKey(last byte) []byte {
+	ret := bytes.Repeat([]byte("k"), maxKeyBytes+100)
+	ret[len(ret)-1] = last
+
+	return ret
+}
+
+func TestLargeKeys(t *testing.T) {
+	if _, err := NewWithOptions(Options{LargeKeys: true, CaseInsensitiveKeys: true}); err != ErrInvalidOptions {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrInvalidOptions)
+	}
+
+	if _, err := NewWithOptions(Options{MaxKeyBytes: maxKeyBytes + 1}); err != ErrInvalidOptions {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrInvalidOptions)
+	}
+
+	if err := New().Put(largeTestKey('a'), []byte("value")); !errors.Is(err, ErrKeyTooLarge) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyTooLarge)
+	}
+
+	arc, _ := NewWithOptions(Options{LargeKeys: true})
+	first, second := largeTestKey('a'), largeTestKey('b')
+
+	arc.Put(first, []byte("first"))
+	arc.Put(second, []byte("second"))
+	arc.Put([]byte("small"), []byte("value"))
+
+	if value, err := arc.Get(first); err != nil || string(value) != "first" {
+		t.Errorf("unexpected value: got:%q, err:%v", value, err)
+	}
+
+	if value, err := arc.Get(second); err != nil || string(value) != "second" {
+		t.Errorf("unexpected value: got:%q, err:%v", value, err)
+	}
+
+	// Iterators yield the full keys.
+	var keys [][]byte
+
+	for key := range arc.Scan(nil) {
+		keys = append(keys, key)
+	}
+
+	if len(keys) != 3 || len(keys[0]) != len(first) || !bytes.Equal(keys[2], []byte("small")) {
+		t.Errorf("unexpected keys: %d", len(keys))
+	}
+
+	// Overwriting a large key keeps a single reference to its blob.
+	arc.Put(first, []byte("updated"))
+
+	if b := arc.blobs[arc.makeBlobID(first)]; b == nil || b.refCount != 1 {
+		t.Errorf("expected a single reference to the key")
+	}
+
+	if err := arc.Put(bytes.Repeat([]byte("k"), maxKeyBytes), nil); !errors.Is(err, ErrKeyTooLarge) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyTooLarge)
+	}
+
+	if err := arc.Delete(first); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, found := arc.blobs[arc.makeBlobID(first)]; found {
+		t.Errorf("expected the key to be released along with its record")
+	}
+
+	if _, err := arc.Get(first); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+
+	if report := arc.BlobGCDryRun(); len(report.Orphaned)+len(report.UnderCounted)+len(report.OverCounted)+len(report.Missing) != 0 {
+		t.Errorf("unexpected blob report: %+v", report)
+	}
+}
+
+func TestLargeKeysMaxKeyBytes(t *testing.T) {
+	arc, _ := NewWithOptions(Options{LargeKeys: true, MaxKeyBytes: maxKeyBytes + 10})
+
+	if err := arc.Put(largeTestKey('a'), nil); !errors.Is(err, ErrKeyTooLarge) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyTooLarge)
+	}
+}
+
+func TestLargeKeysMove(t *testing.T) {
+	arc, _ := NewWithOptions(Options{LargeKeys: true})
+	key := largeTestKey('a')
+
+	arc.Put(key, []byte("value"))
+
+	moved := append([]byte("new:"), key...)
+
+	if err := arc.Rename(key, moved); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if value, err := arc.Get(moved); err != nil || string(value) != "value" {
+		t.Errorf("unexpected value: got:%q, err:%v", value, err)
+	}
+
+	if err := arc.MovePrefix([]byte("new:"), []byte("old:")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	moved[0], moved[1], moved[2] = 'o', 'l', 'd'
+
+	if value, err := arc.Get(moved); err != nil || string(value) != "value" {
+		t.Errorf("unexpected value: got:%q, err:%v", value, err)
+	}
+
+	if len(arc.blobs) != 1 {
+		t.Errorf("unexpected blob count: got:%d, want:1", len(arc.blobs))
+	}
+}
+
+func TestLargeKeysPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := OpenWithOptions(path, Options{LargeKeys: true})
+	value := bytes.Repeat([]byte("v"), 100)
+
+	arc.Put(largeTestKey('a'), value)
+	arc.Put(largeTestKey('b'), []byte("small"))
+
+	clone := arc.Clone()
+	clone.Put([]byte("clone"), nil)
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The clone copies the full keys along with the records.
+	for key := range clone.Scan(nil) {
+		if string(key) != "clone" && len(key) != len(largeTestKey('a')) {
+			t.Errorf("unexpected key length: %d", len(key))
+		}
+	}
+
+	if _, err := Open(path); err != ErrKeyTransformMismatch {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyTransformMismatch)
+	}
+
+	reopened, err := OpenWithOptions(path, Options{LargeKeys: true})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer reopened.Close()
+
+	if got, err := reopened.Get(largeTestKey('a')); err != nil || !bytes.Equal(got, value) {
+		t.Errorf("unexpected value: %v", err)
+	}
+
+	for key := range reopened.Scan(nil) {
+		if len(key) != len(largeTestKey('a')) {
+			t.Errorf("unexpected key length: %d", len(key))
+		}
+	}
+
+	if report := reopened.BlobGCDryRun(); len(report.Orphaned)+len(report.UnderCounted)+len(report.OverCounted)+len(report.Missing) != 0 {
+		t.Errorf("unexpected blob report: %+v", report)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+)
+
+// ErrInvalidToken is returned by List when the continuation token is malformed,
+// or was issued for a different prefix or direction.
+var ErrInvalidToken = errors.New("invalid continuation token")
+
+// listTokenVersion is the version of the continuation token encoding.
+const listTokenVersion = uint8(1)
+
+// ListOptions configures List.
+type ListOptions struct {
+	// Prefix restricts the listing to the records whose keys begin with the
+	// prefix. A nil prefix lists the entire database.
+	Prefix []byte
+
+	// Direction is the order in which records are listed.
+	Direction Direction
+
+	// KeysOnly lists nil values, and skips reading the values altogether.
+	KeysOnly bool
+
+	// Limit is the maximum number of records in the page. Zero or less
+	// lists every remaining record.
+	Limit int
+
+	// Token resumes the listing after the page that returned it. An empty
+	// token starts from the beginning.
+	Token string
+}
+
+// Page is a page of records returned by List.
+type Page struct {
+	Keys   [][]byte // Keys of the records in the page.
+	Values [][]byte // Values of the records, or nils if KeysOnly is set.
+
+	// Token resumes the listing after the page, and is empty if the page
+	// is the last one.
+	Token string
+}
+
+// List returns a page of the records selected by the options. Unlike an
+// iterator, listing does not hold any state between pages, which suits
+// paginated APIs. The continuation token encodes the full key of the last
+// record in the page rather than a position in the tree, therefore it remains
+// valid regardless of the writes made between pages, including the deletion
+// of the record itself. Records that exist throughout the listing are listed
+// exactly once, in order, while records written or deleted in the meantime
+// may or may not be listed. Returns ErrInvalidToken if the token is malformed,
+// or was issued for a different prefix or direction.
+func (a *Arc) List(opts ListOptions) (Page, error) {
+	scanOpts := ScanOptions{
+		Prefix:    a.transformKey(opts.Prefix),
+		Direction: a.orderedDirection(opts.Direction),
+		KeysOnly:  opts.KeysOnly,
+	}
+
+	var after []byte
+
+	if opts.Token != "" {
+		var err error
+
+		if after, err = decodeListToken(opts.Token, scanOpts); err != nil {
+			return Page{}, err
+		}
+	}
+
+	a.rlock()
+	records, more := a.collectRecords(scanOpts, a.scanMatch(nil), after, opts.Limit)
+	a.runlock()
+
+	var ret Page
+
+	for _, r := range records {
+		ret.Keys = append(ret.Keys, r.key)
+		ret.Values = append(ret.Values, r.value)
+	}
+
+	if more {
+		ret.Token = encodeListToken(a.foldKey(records[len(records)-1].key), scanOpts)
+	}
+
+	return ret, nil
+}
+
+// encodeListToken returns the continuation token that resumes the listing
+// after the given key. The token consists of the version, the direction, and
+// the key as stored in the tree.
+func encodeListToken(key []byte, opts ScanOptions) string {
+	src := append([]byte{listTokenVersion, byte(opts.Direction)}, key...)
+	return base64.RawURLEncoding.EncodeToString(src)
+}
+
+// decodeListToken returns the key encoded in the continuation token, which
+// must have been issued for the same prefix and direction.
+func decodeListToken(token string, opts ScanOptions) ([]byte, error) {
+	src, err := base64.RawURLEncoding.DecodeString(token)
+
+	if err != nil || len(src) < 2 || src[0] != listTokenVersion {
+		return nil, ErrInvalidToken
+	}
+
+	if Direction(src[1]) != opts.Direction || !bytes.HasPrefix(src[2:], opts.Prefix) {
+		return nil, ErrInvalidToken
+	}
+
+	return src[2:], nil
+}
@@ -0,0 +1,136 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// listAll pages through the listing, and calls between on every page before
+// the next one is requested.
+func listAll(t *testing.T, arc *Arc, opts ListOptions, between func(page Page)) []string {
+	t.Helper()
+
+	var ret []string
+
+	for {
+		page, err := arc.List(opts)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if opts.Limit > 0 && len(page.Keys) > opts.Limit {
+			t.Fatalf("unexpected page size: got:%d, limit:%d", len(page.Keys), opts.Limit)
+		}
+
+		for _, key := range page.Keys {
+			ret = append(ret, string(key))
+		}
+
+		if page.Token == "" {
+			return ret
+		}
+
+		if between != nil {
+			between(page)
+		}
+
+		opts.Token = page.Token
+	}
+}
+
+func TestList(t *testing.T) {
+	arc := basicTestTree()
+
+	testCases := []struct {
+		opts ListOptions
+		want []string
+	}{
+		{opts: ListOptions{Limit: 3}, want: sortedBasicTestKeys()},
+		{opts: ListOptions{Limit: 1, Direction: Reverse}, want: reversed(sortedBasicTestKeys())},
+		{opts: ListOptions{Prefix: []byte("ap"), Limit: 2}, want: []string{"apple", "applet", "application", "apricot"}},
+		{opts: ListOptions{Prefix: []byte("bogus"), Limit: 2}, want: nil},
+		{opts: ListOptions{}, want: sortedBasicTestKeys()},
+	}
+
+	for _, tc := range testCases {
+		if got := listAll(t, arc, tc.opts, nil); !slices.Equal(got, tc.want) {
+			t.Errorf("unexpected keys for %+v: got:%q, want:%q", tc.opts, got, tc.want)
+		}
+	}
+
+	page, _ := arc.List(ListOptions{Prefix: []byte("ap"), Limit: 1})
+
+	if string(page.Values[0]) != "cider" {
+		t.Errorf("unexpected value: got:%q, want:%q", page.Values[0], "cider")
+	}
+
+	// Tokens are bound to the prefix and the direction.
+	invalid := []ListOptions{
+		{Token: "!"},
+		{Token: encodeListToken([]byte("apple"), ScanOptions{Direction: Reverse})},
+		{Prefix: []byte("b"), Token: page.Token},
+	}
+
+	for _, opts := range invalid {
+		if _, err := arc.List(opts); err != ErrInvalidToken {
+			t.Errorf("unexpected error for %+v: got:%v, want:%v", opts, err, ErrInvalidToken)
+		}
+	}
+}
+
+func TestListWithWrites(t *testing.T) {
+	for _, dir := range []Direction{Forward, Reverse} {
+		arc, _ := NewWithOptions(Options{CaseInsensitiveKeys: true})
+		rng := rand.New(rand.NewSource(3))
+		stable := map[string]bool{}
+
+		for i := range 200 {
+			key := fmt.Sprintf("Key%04d", i*2)
+			arc.Put([]byte(key), nil)
+			stable[key] = true
+		}
+
+		got := listAll(t, arc, ListOptions{Direction: dir, Limit: 7}, func(page Page) {
+			// Delete the last listed record, which the token points at.
+			last := string(page.Keys[len(page.Keys)-1])
+			arc.Delete([]byte(last))
+
+			// Write and delete records on both sides of the token.
+			for range 5 {
+				arc.Put([]byte(fmt.Sprintf("Key%04d", rng.Intn(200)*2+1)), nil)
+
+				victim := fmt.Sprintf("Key%04d", rng.Intn(200)*2)
+
+				if arc.Delete([]byte(victim)) == nil {
+					delete(stable, victim)
+				}
+			}
+		})
+
+		seen := map[string]bool{}
+
+		for i, key := range got {
+			if seen[key] {
+				t.Fatalf("key listed twice: %q", key)
+			}
+
+			seen[key] = true
+
+			if i > 0 && (dir == Forward) != (got[i-1] < key) {
+				t.Fatalf("unexpected order: %q then %q", got[i-1], key)
+			}
+		}
+
+		for key := range stable {
+			if !seen[key] {
+				t.Errorf("stable key skipped: %q", key)
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// defaultSlowOpThreshold is the SlowOpThreshold that applies when the option
+// is unset.
+const defaultSlowOpThreshold = 100 * time.Millisecond
+
+// logEnabled returns true if the Logger is set, and handles the given level.
+func (a *Arc) logEnabled(level slog.Level) bool {
+	return a.opts.Logger != nil && a.opts.Logger.Enabled(context.Background(), level)
+}
+
+// log emits an event at the given level to the Logger, if it is set. The args
+// are key-value pairs, as accepted by slog.Logger.Log.
+func (a *Arc) log(level slog.Level, msg string, args ...any) {
+	if a.logEnabled(level) {
+		a.opts.Logger.Log(context.Background(), level, msg, args...)
+	}
+}
+
+// logSlowOp emits a warning if the operation that started at the given time
+// took longer than the SlowOpThreshold. Keys are logged by their length only,
+// since they may hold sensitive data.
+func (a *Arc) logSlowOp(info *OpInfo, start time.Time, err error) {
+	elapsed := time.Since(start)
+
+	if elapsed < a.opts.SlowOpThreshold {
+		return
+	}
+
+	args := []any{"op", info.Op.String(), "key_len", len(info.Key), "duration", elapsed}
+
+	if err != nil {
+		args = append(args, "err", err)
+	}
+
+	a.log(slog.LevelWarn, "slow operation", args...)
+}
@@ -0,0 +1,145 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestLogger returns a Logger that writes every event to the buffer.
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func TestLoggerSlowOp(t *testing.T) {
+	var buf bytes.Buffer
+
+	arc, _ := NewWithOptions(Options{Logger: newTestLogger(&buf), SlowOpThreshold: time.Nanosecond})
+	arc.Put([]byte("secret"), []byte("value"))
+
+	got := buf.String()
+
+	if !strings.Contains(got, `msg="slow operation" op=put key_len=6`) {
+		t.Errorf("expected a slow operation event: %s", got)
+	}
+
+	if strings.Contains(got, "secret") {
+		t.Errorf("expected the key to be left out: %s", got)
+	}
+
+	buf.Reset()
+
+	fast, _ := NewWithOptions(Options{Logger: newTestLogger(&buf), SlowOpThreshold: time.Hour})
+	fast.Put([]byte("key"), []byte("value"))
+
+	if buf.Len() != 0 {
+		t.Errorf("unexpected events: %s", buf.String())
+	}
+}
+
+func TestLoggerCorruption(t *testing.T) {
+	var buf bytes.Buffer
+
+	arc, _ := NewWithOptions(Options{Logger: newTestLogger(&buf), SlowOpThreshold: time.Hour})
+	value := blobValueX()
+
+	arc.Put([]byte("key"), value)
+	arc.blobs[arc.makeBlobID(value)].value = bytes.Repeat([]byte("y"), len(value))
+
+	if _, err := arc.Get([]byte("key")); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	if got := buf.String(); !strings.Contains(got, "level=WARN") || !strings.Contains(got, "blob content does not match its ID") {
+		t.Errorf("expected a corruption event: %s", got)
+	}
+}
+
+func TestLoggerLoad(t *testing.T) {
+	var buf bytes.Buffer
+
+	path := filepath.Join(t.TempDir(), "test.arc")
+	opts := Options{Logger: newTestLogger(&buf)}
+	arc, _ := OpenWithOptions(path, opts)
+
+	if got := buf.String(); !strings.Contains(got, "database file does not exist") {
+		t.Errorf("expected a missing file event: %s", got)
+	}
+
+	arc.Put([]byte("key"), []byte("value"))
+	arc.Close()
+	buf.Reset()
+
+	arc, err := OpenWithOptions(path, opts)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	arc.Close()
+
+	if got := buf.String(); !strings.Contains(got, `msg="loaded database file"`) || !strings.Contains(got, "records=1") {
+		t.Errorf("expected a load event: %s", got)
+	}
+
+	// Damage the file past the header.
+	src, _ := os.ReadFile(path)
+	src[len(src)-1] ^= 0xff
+	os.WriteFile(path, src, 0o644)
+	buf.Reset()
+
+	if _, err := OpenWithOptions(path, opts); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	if got := buf.String(); !strings.Contains(got, `msg="failed to load database file"`) {
+		t.Errorf("expected a load failure event: %s", got)
+	}
+}
+
+func TestLoggerBlobGC(t *testing.T) {
+	var buf bytes.Buffer
+
+	arc, _ := NewWithOptions(Options{Logger: newTestLogger(&buf), SlowOpThreshold: time.Hour})
+	value := blobValueX()
+
+	arc.Put([]byte("key"), value)
+	arc.BlobGC()
+
+	if got := buf.String(); !strings.Contains(got, "level=DEBUG") || !strings.Contains(got, "orphaned=0") {
+		t.Errorf("expected a debug event: %s", got)
+	}
+
+	buf.Reset()
+	arc.blobs[arc.makeBlobID(value)].refCount = 2
+	arc.BlobGC()
+
+	if got := buf.String(); !strings.Contains(got, "level=WARN") || !strings.Contains(got, "over_counted=1") {
+		t.Errorf("expected a warning: %s", got)
+	}
+}
+
+func TestLoggerCompaction(t *testing.T) {
+	var buf bytes.Buffer
+
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := OpenWithOptions(path, Options{Logger: newTestLogger(&buf), SlowOpThreshold: time.Hour})
+	defer arc.Close()
+
+	arc.Put([]byte("key"), []byte("value"))
+
+	if err := arc.Compact(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := buf.String(); !strings.Contains(got, `msg="compacted database file"`) {
+		t.Errorf("expected a compaction event: %s", got)
+	}
+}
@@ -0,0 +1,301 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+)
+
+// ErrManifestMismatch is returned by Open when the database file does not
+// match its manifest, which indicates that the file was damaged or replaced
+// outside of the database, such as by a truncated copy or a bad disk. It is
+// also returned if the manifest itself is damaged.
+var ErrManifestMismatch = errors.New("database file does not match its manifest")
+
+const (
+	// manifestSuffix is appended to the path of the database file to form
+	// the path of its manifest.
+	manifestSuffix = ".manifest"
+
+	// manifestMagicByte is the first byte of a manifest.
+	manifestMagicByte = byte(0x4D)
+
+	// manifestVersion is the manifest format version.
+	manifestVersion = uint8(1)
+
+	// manifestEntryLen is the length of a serialized manifest entry,
+	// excluding the section checksums.
+	manifestEntryLen = sizeOfUint8 + sizeOfUint64 + sizeOfUint64 + sha256.Size + sha256.Size + sizeOfUint32
+)
+
+// manifestEntry describes a database file as written by a save. The sections
+// of the file are the header and the pages of the body.
+type manifestEntry struct {
+	version  uint8             // File format version.
+	epoch    uint64            // Write epoch of the pages.
+	size     uint64            // File size in bytes.
+	fileHash [sha256.Size]byte // SHA-256 of the entire file.
+	rootHash [sha256.Size]byte // RootHash of the records, or zeros if empty.
+	sections []uint32          // Checksums of the sections.
+}
+
+// manifestPath returns the path of the manifest of the given database file.
+func manifestPath(path string) string {
+	return path + manifestSuffix
+}
+
+// newManifestEntry returns the manifest entry of the given database file,
+// whose records have the given root hash.
+func newManifestEntry(src []byte, rootHash []byte) (*manifestEntry, error) {
+	header, err := newArcHeaderFromBytes(src)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &manifestEntry{version: header.version, size: uint64(len(src)), fileHash: sha256.Sum256(src)}
+	copy(ret.rootHash[:], rootHash)
+
+	if len(src) >= header.len()+sizeOfUint64 {
+		ret.epoch = binary.LittleEndian.Uint64(src[header.len():])
+	}
+
+	for _, section := range fileSections(src, header.len()) {
+		checksum, err := computeChecksum(section)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret.sections = append(ret.sections, checksum)
+	}
+
+	return ret, nil
+}
+
+// fileSections splits the database file into the header, whose length is
+// given, and the pages of the body. The last page may be partial if the file
+// is damaged.
+func fileSections(src []byte, headerLen int) [][]byte {
+	headerLen = min(headerLen, len(src))
+	ret := [][]byte{src[:headerLen]}
+
+	for pos := headerLen; pos < len(src); pos += pageLen {
+		ret = append(ret, src[pos:min(pos+pageLen, len(src))])
+	}
+
+	return ret
+}
+
+// serializeManifest returns the manifest that holds the given entries, which
+// is the magic byte, the manifest version, the number of entries, the entries,
+// and the checksum of the preceding bytes.
+func serializeManifest(entries []*manifestEntry) ([]byte, error) {
+	ret := []byte{manifestMagicByte, manifestVersion, byte(len(entries))}
+
+	for _, e := range entries {
+		ret = append(ret, e.version)
+		ret = binary.LittleEndian.AppendUint64(ret, e.epoch)
+		ret = binary.LittleEndian.AppendUint64(ret, e.size)
+		ret = append(ret, e.fileHash[:]...)
+		ret = append(ret, e.rootHash[:]...)
+		ret = binary.LittleEndian.AppendUint32(ret, uint32(len(e.sections)))
+
+		for _, checksum := range e.sections {
+			ret = binary.LittleEndian.AppendUint32(ret, checksum)
+		}
+	}
+
+	checksum, err := computeChecksum(ret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return binary.LittleEndian.AppendUint32(ret, checksum), nil
+}
+
+// parseManifest returns the entries of the manifest produced by
+// serializeManifest. Returns ErrManifestMismatch if the manifest is damaged.
+func parseManifest(src []byte) ([]*manifestEntry, error) {
+	if len(src) < 3+checksumLen || src[0] != manifestMagicByte {
+		return nil, ErrManifestMismatch
+	}
+
+	if src[1] != manifestVersion {
+		return nil, ErrUnsupportedVersion
+	}
+
+	body := src[:len(src)-checksumLen]
+
+	if checksum, err := computeChecksum(body); err != nil || checksum != binary.LittleEndian.Uint32(src[len(body):]) {
+		return nil, ErrManifestMismatch
+	}
+
+	numEntries := int(body[2])
+	pos := 3
+
+	var ret []*manifestEntry
+
+	for range numEntries {
+		if len(body)-pos < manifestEntryLen {
+			return nil, ErrManifestMismatch
+		}
+
+		e := &manifestEntry{version: body[pos]}
+		pos += sizeOfUint8
+		e.epoch = binary.LittleEndian.Uint64(body[pos:])
+		pos += sizeOfUint64
+		e.size = binary.LittleEndian.Uint64(body[pos:])
+		pos += sizeOfUint64
+		pos += copy(e.fileHash[:], body[pos:])
+		pos += copy(e.rootHash[:], body[pos:])
+		numSections := int(binary.LittleEndian.Uint32(body[pos:]))
+		pos += sizeOfUint32
+
+		if (len(body)-pos)/sizeOfUint32 < numSections {
+			return nil, ErrManifestMismatch
+		}
+
+		e.sections = make([]uint32, numSections)
+
+		for i := range e.sections {
+			e.sections[i] = binary.LittleEndian.Uint32(body[pos:])
+			pos += sizeOfUint32
+		}
+
+		ret = append(ret, e)
+	}
+
+	if pos != len(body) || len(ret) == 0 {
+		return nil, ErrManifestMismatch
+	}
+
+	return ret, nil
+}
+
+// writeManifest writes the manifest that describes the given database file,
+// which is about to replace the current file, and returns its entry. The
+// manifest also holds the entry of the current file, if any, therefore a crash
+// before the database file is replaced leaves a manifest that still matches
+// it. It is a no-op unless the Manifest option is set.
+func (a *Arc) writeManifest(src []byte, rootHash []byte) (*manifestEntry, error) {
+	if !a.opts.Manifest {
+		return nil, nil
+	}
+
+	entry, err := newManifestEntry(src, rootHash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*manifestEntry{entry}
+
+	if a.manifest != nil {
+		entries = append(entries, a.manifest)
+	}
+
+	manifest, err := serializeManifest(entries)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeFileAtomic(manifestPath(a.path), manifest); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// checkManifest verifies the database file at the given path against its
+// manifest, and returns the entry that the file matches, which checkRootHash
+// then verifies against the loaded records. It returns nil if the Manifest
+// option is not set, or if the file has no manifest yet. The mismatches are
+// logged along with the first section that differs from the latest save.
+func (a *Arc) checkManifest(path string, src []byte) (*manifestEntry, error) {
+	if !a.opts.Manifest {
+		return nil, nil
+	}
+
+	manifest, err := os.ReadFile(manifestPath(path))
+
+	if errors.Is(err, fs.ErrNotExist) {
+		a.log(slog.LevelDebug, "database file has no manifest", "path", path)
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := parseManifest(manifest)
+
+	if err != nil {
+		a.log(slog.LevelWarn, "failed to read the manifest", "path", path, "err", err)
+		return nil, err
+	}
+
+	fileHash := sha256.Sum256(src)
+
+	for _, e := range entries {
+		if e.size == uint64(len(src)) && e.fileHash == fileHash {
+			return e, nil
+		}
+	}
+
+	latest := entries[0]
+	args := []any{"path", path, "size", len(src), "want_size", latest.size}
+
+	if actual, err := newManifestEntry(src, nil); err == nil {
+		args = append(args, "version", actual.version, "want_version", latest.version)
+
+		for i, checksum := range actual.sections {
+			if i >= len(latest.sections) || checksum != latest.sections[i] {
+				args = append(args, "section", i)
+				break
+			}
+		}
+	}
+
+	a.log(slog.LevelWarn, "database file does not match its manifest", args...)
+
+	return nil, ErrManifestMismatch
+}
+
+// checkRootHash verifies the records loaded from the database file at the
+// given path against the root hash of the manifest entry. It is a no-op if
+// entry is nil. The caller must hold the read lock.
+func (a *Arc) checkRootHash(path string, entry *manifestEntry) error {
+	if entry == nil {
+		return nil
+	}
+
+	if !bytes.Equal(a.manifestRootHash(), entry.rootHash[:]) {
+		a.log(slog.LevelWarn, "database records do not match the manifest", "path", path)
+		return ErrManifestMismatch
+	}
+
+	return nil
+}
+
+// manifestRootHash returns the root hash of the records as recorded by the
+// manifest, which is all zeros for an empty database. It is only computed if
+// the Manifest option is set. The caller must hold the read lock.
+func (a *Arc) manifestRootHash() []byte {
+	var ret subtreeHash
+
+	if a.opts.Manifest && a.root != nil {
+		ret = a.hashNode(a.root, false)
+	}
+
+	return ret[:]
+}
@@ -0,0 +1,225 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// saveWithManifest writes the given records to a new database file with the
+// Manifest option, and returns the path of the file.
+func saveWithManifest(t *testing.T, keys ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := OpenWithOptions(path, Options{Manifest: true})
+
+	for _, key := range keys {
+		arc.Put([]byte(key), []byte(key))
+	}
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return path
+}
+
+func TestManifest(t *testing.T) {
+	path := saveWithManifest(t, sortedBasicTestKeys()...)
+
+	src, _ := os.ReadFile(path)
+	manifest, err := os.ReadFile(manifestPath(path))
+
+	if err != nil {
+		t.Fatalf("expected a manifest: %v", err)
+	}
+
+	entries, err := parseManifest(manifest)
+
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("unexpected manifest: entries:%d, err:%v", len(entries), err)
+	}
+
+	e := entries[0]
+	header, _ := newArcHeaderFromBytes(src)
+
+	// The header is followed by the pages.
+	if e.version != fileFormatVersion || e.size != uint64(len(src)) || len(e.sections) != 1+(len(src)-header.len())/pageLen {
+		t.Errorf("unexpected manifest entry: %+v", e)
+	}
+
+	reopened, err := OpenWithOptions(path, Options{Manifest: true})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !bytes.Equal(e.rootHash[:], reopened.RootHash()) {
+		t.Errorf("unexpected root hash: got:%x, want:%x", e.rootHash, reopened.RootHash())
+	}
+
+	reopened.Close()
+
+	// The manifest is ignored without the option.
+	os.WriteFile(manifestPath(path), []byte("garbage"), 0o644)
+
+	if reopened, err := Open(path); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else {
+		reopened.Close()
+	}
+}
+
+func TestManifestMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage func(path string)
+	}{
+		{"truncated", func(path string) {
+			src, _ := os.ReadFile(path)
+			os.WriteFile(path, src[:len(src)-pageLen], 0o644)
+		}},
+		{"flipped", func(path string) {
+			src, _ := os.ReadFile(path)
+			src[len(src)-1] ^= 0xff
+			os.WriteFile(path, src, 0o644)
+		}},
+		{"damaged manifest", func(path string) {
+			manifest, _ := os.ReadFile(manifestPath(path))
+			manifest[3] ^= 0xff
+			os.WriteFile(manifestPath(path), manifest, 0o644)
+		}},
+		{"root hash", func(path string) {
+			manifest, _ := os.ReadFile(manifestPath(path))
+			entries, _ := parseManifest(manifest)
+			entries[0].rootHash[0] ^= 0xff
+			manifest, _ = serializeManifest(entries)
+			os.WriteFile(manifestPath(path), manifest, 0o644)
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys := sortedBasicTestKeys()
+
+			// Enough records to span several pages.
+			for i := range 500 {
+				keys = append(keys, strings.Repeat("k", i%50)+string(rune('a'+i%26))+strings.Repeat("v", i))
+			}
+
+			path := saveWithManifest(t, keys...)
+			test.damage(path)
+
+			var buf bytes.Buffer
+
+			if _, err := OpenWithOptions(path, Options{Manifest: true, Logger: newTestLogger(&buf)}); !errors.Is(err, ErrManifestMismatch) {
+				t.Fatalf("unexpected error: got:%v, want:%v", err, ErrManifestMismatch)
+			}
+
+			if !strings.Contains(buf.String(), "manifest") {
+				t.Errorf("expected the mismatch to be logged: %s", buf.String())
+			}
+		})
+	}
+}
+
+func TestManifestSection(t *testing.T) {
+	path := saveWithManifest(t, "apple")
+
+	src, _ := os.ReadFile(path)
+	src[len(src)-1] ^= 0xff
+	os.WriteFile(path, src, 0o644)
+
+	var buf bytes.Buffer
+
+	OpenWithOptions(path, Options{Manifest: true, Logger: newTestLogger(&buf)})
+
+	// The damaged byte is in the only page, which follows the header.
+	if !strings.Contains(buf.String(), "section=1") {
+		t.Errorf("expected the damaged section to be logged: %s", buf.String())
+	}
+}
+
+func TestManifestPreviousSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := OpenWithOptions(path, Options{Manifest: true})
+
+	var files [][]byte
+
+	for _, key := range []string{"apple", "banana", "cherry"} {
+		arc.Put([]byte(key), []byte(key))
+
+		if err := arc.Save(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		src, _ := os.ReadFile(path)
+		files = append(files, src)
+	}
+
+	arc.Close()
+
+	// A crash after the manifest is written leaves the previous file in
+	// place, which the manifest still describes.
+	os.WriteFile(path, files[1], 0o644)
+
+	reopened, err := OpenWithOptions(path, Options{Manifest: true})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if reopened.Len() != 2 {
+		t.Errorf("unexpected length: got:%d, want:2", reopened.Len())
+	}
+
+	reopened.Close()
+
+	// Older files are not described by the manifest.
+	os.WriteFile(path, files[0], 0o644)
+
+	if _, err := OpenWithOptions(path, Options{Manifest: true}); !errors.Is(err, ErrManifestMismatch) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrManifestMismatch)
+	}
+}
+
+func TestManifestMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := Open(path)
+	arc.Put([]byte("apple"), []byte("red"))
+	arc.Close()
+
+	// Files written without the option have no manifest until they are
+	// saved with it.
+	arc, err := OpenWithOptions(path, Options{Manifest: true})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	arc.Put([]byte("banana"), []byte("yellow"))
+	arc.Close()
+
+	if _, err := os.Stat(manifestPath(path)); err != nil {
+		t.Errorf("expected a manifest: %v", err)
+	}
+
+	// A migrated file does not keep the stale manifest of its destination.
+	dst := filepath.Join(filepath.Dir(path), "migrated.arc")
+	os.WriteFile(manifestPath(dst), []byte("stale"), 0o644)
+
+	if err := MigrateTo(path, dst, fileFormatVersion); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(manifestPath(dst)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the stale manifest to be removed: %v", err)
+	}
+}
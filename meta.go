@@ -0,0 +1,69 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"math"
+)
+
+// ErrInvalidMeta is returned when a metadata key is empty or exceeds 255
+// bytes, or when a metadata value exceeds 64KB.
+var ErrInvalidMeta = errors.New("invalid metadata")
+
+const (
+	// maxMetaKeyLen is the maximum length of a metadata key, which is
+	// imposed by the file format.
+	maxMetaKeyLen = math.MaxUint8
+
+	// maxMetaValueLen is the maximum length of a metadata value, which is
+	// imposed by the file format.
+	maxMetaValueLen = maxUint16
+)
+
+// SetMeta attaches user metadata to the database, such as a schema version,
+// an application name, or a creation time, without taking up keyspace. The
+// metadata is stored in the header of the database file, where it takes
+// effect on the next Save. It is not replicated, and is not persisted for the
+// namespaces of a Container. A nil value removes the entry. Returns
+// ErrInvalidMeta if the key or the value exceeds the limits of the file
+// format, and ErrReadOnly if the database is read-only.
+func (a *Arc) SetMeta(key string, value []byte) error {
+	if len(key) == 0 || len(key) > maxMetaKeyLen || len(value) > maxMetaValueLen {
+		return ErrInvalidMeta
+	}
+
+	if a.readOnly {
+		return ErrReadOnly
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if value == nil {
+		delete(a.meta, key)
+	} else {
+		if a.meta == nil {
+			a.meta = map[string][]byte{}
+		}
+
+		a.meta[key] = bytes.Clone(value)
+	}
+
+	a.metaSeq++
+
+	return nil
+}
+
+// GetMeta returns a copy of the metadata value set by SetMeta for the given
+// key, and whether it exists.
+func (a *Arc) GetMeta(key string) ([]byte, bool) {
+	a.rlock()
+	defer a.runlock()
+
+	value, found := a.meta[key]
+
+	return bytes.Clone(value), found
+}
@@ -0,0 +1,71 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetMeta(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := Open(path)
+
+	if err := arc.SetMeta("schema", []byte("v2")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	arc.SetMeta("app", []byte("billing"))
+	arc.SetMeta("temp", []byte("x"))
+	arc.SetMeta("temp", nil)
+
+	if _, found := arc.GetMeta("temp"); found {
+		t.Errorf("expected the entry to be removed")
+	}
+
+	// Metadata changes alone are saved.
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := OpenReadOnly(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer reopened.Close()
+
+	if got, found := reopened.GetMeta("schema"); !found || string(got) != "v2" {
+		t.Errorf("unexpected value: got:%q, want:%q", got, "v2")
+	}
+
+	if got, _ := reopened.GetMeta("app"); string(got) != "billing" {
+		t.Errorf("unexpected value: got:%q, want:%q", got, "billing")
+	}
+
+	if err := reopened.SetMeta("schema", nil); err != ErrReadOnly {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrReadOnly)
+	}
+}
+
+func TestSetMetaLimits(t *testing.T) {
+	arc := New()
+	tests := []struct {
+		name  string
+		key   string
+		value []byte
+	}{
+		{"empty key", "", nil},
+		{"long key", strings.Repeat("k", maxMetaKeyLen+1), nil},
+		{"large value", "key", make([]byte, maxMetaValueLen+1)},
+	}
+
+	for _, test := range tests {
+		if err := arc.SetMeta(test.key, test.value); err != ErrInvalidMeta {
+			t.Errorf("%s: unexpected error: got:%v, want:%v", test.name, err, ErrInvalidMeta)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// migration upgrades the contents of a database file from one file format
+// version to the next. The header has already been validated, and is
+// rewritten by the caller.
+type migration func(src []byte) ([]byte, error)
+
+// migrations maps each file format version to the migration that upgrades it
+// to the following version. A change to the file format must bump
+// fileFormatVersion and register the migration from the previous version, so
+// that existing files remain readable.
+var migrations = map[uint8]migration{
+	1:  migrateV1ToV2,
+	2:  migrateV2ToV3,
+	3:  migrateV3ToV4,
+	4:  migrateV4ToV5,
+	5:  migrateV5ToV6,
+	6:  migrateV6ToV7,
+	7:  migrateV7ToV8,
+	8:  migrateV8ToV9,
+	9:  migrateV9ToV10,
+	10: migrateV10ToV11,
+}
+
+// migrateV1ToV2 appends the expiration section that was introduced in version
+// 2, which is empty since version 1 had no expirations, along with its offset.
+func migrateV1ToV2(src []byte) ([]byte, error) {
+	expirations, err := New().serializeExpirations()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]byte, 0, len(src)+len(expirations)+sizeOfUint64)
+	ret = append(ret, src...)
+	ret = append(ret, expirations...)
+
+	return binary.LittleEndian.AppendUint64(ret, uint64(len(src))), nil
+}
+
+// migrateV2ToV3 makes room for the key transform that was added to the header
+// in version 3. Version 2 files had no key transform, therefore the header
+// only grows by the length of its name, and the absolute offsets that follow
+// the header shift accordingly.
+func migrateV2ToV3(src []byte) ([]byte, error) {
+	return shiftOffsets(src, legacyArcHeaderBytesLen, arcHeaderBytesLen-legacyArcHeaderBytesLen)
+}
+
+// shiftOffsets returns a copy of the serialized database, whose header is
+// headerLen bytes long, with the node and expiration section offsets shifted
+// by delta bytes. The checksums of the rewritten nodes are recomputed. It
+// supports file format versions 2 to 5, whose body is not split into pages,
+// and the leading sections of later versions.
+func shiftOffsets(src []byte, headerLen int, delta int) ([]byte, error) {
+	if len(src) < headerLen+sizeOfUint64+sizeOfUint64 {
+		return nil, ErrCorrupted
+	}
+
+	expirationsOffset := int(binary.LittleEndian.Uint64(src[len(src)-sizeOfUint64:]))
+
+	if expirationsOffset < headerLen+sizeOfUint64 || expirationsOffset > len(src)-sizeOfUint64 {
+		return nil, ErrCorrupted
+	}
+
+	ret := bytes.Clone(src)
+	shift := func(offset uint64) uint64 {
+		if offset == 0 {
+			return 0
+		}
+
+		return uint64(int64(offset) + int64(delta))
+	}
+
+	numBlobs := binary.LittleEndian.Uint64(src[headerLen:])
+	pos := headerLen + sizeOfUint64
+
+	for i := uint64(0); i < numBlobs; i++ {
+		_, _, recordLen, err := readBlobRecord(src[pos:expirationsOffset])
+
+		if err != nil {
+			return nil, err
+		}
+
+		pos += recordLen
+	}
+
+	// The child and sibling offsets follow the flags, the number of children,
+	// and the key and data lengths.
+	offsetsPos := sizeOfUint8 + sizeOfUint16 + sizeOfUint16 + sizeOfUint32
+
+	for pos < expirationsOffset {
+		region := src[pos:expirationsOffset]
+
+		if len(region) < minNodeBytesLen {
+			return nil, ErrNodeCorrupted
+		}
+
+		keyLen := int(binary.LittleEndian.Uint16(region[sizeOfUint8+sizeOfUint16:]))
+		dataLen := int(binary.LittleEndian.Uint32(region[sizeOfUint8+sizeOfUint16+sizeOfUint16:]))
+		nodeLen := minNodeBytesLen + keyLen + dataLen + checksumLen
+
+		if nodeLen > len(region) {
+			return nil, ErrNodeCorrupted
+		}
+
+		pn, err := makePersistentNodeFromBytes(region[:nodeLen])
+
+		if err != nil {
+			return nil, err
+		}
+
+		nodeBytes := ret[pos : pos+nodeLen]
+		binary.LittleEndian.PutUint64(nodeBytes[offsetsPos:], shift(pn.firstChildOffset))
+		binary.LittleEndian.PutUint64(nodeBytes[offsetsPos+sizeOfUint64:], shift(pn.nextSiblingOffset))
+
+		checksum, err := computeChecksum(nodeBytes[:nodeLen-checksumLen])
+
+		if err != nil {
+			return nil, err
+		}
+
+		binary.LittleEndian.PutUint32(nodeBytes[nodeLen-checksumLen:], checksum)
+
+		pos += nodeLen
+	}
+
+	binary.LittleEndian.PutUint64(ret[len(ret)-sizeOfUint64:], shift(uint64(expirationsOffset)))
+
+	return ret, nil
+}
+
+// migrateV3ToV4 appends the original key section that was introduced in
+// version 4, which is empty since version 3 had no case-insensitive keys,
+// along with its offset.
+func migrateV3ToV4(src []byte) ([]byte, error) {
+	originalKeys, err := New().serializeOriginalKeys()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]byte, 0, len(src)+len(originalKeys)+sizeOfUint64)
+	ret = append(ret, src...)
+	ret = append(ret, originalKeys...)
+
+	return binary.LittleEndian.AppendUint64(ret, uint64(len(src))), nil
+}
+
+// migrateV4ToV5 appends the dictionary section that was introduced in version
+// 5, which is empty since version 4 did not compress blobs, along with its
+// offset.
+func migrateV4ToV5(src []byte) ([]byte, error) {
+	dictionary, err := New().serializeDictionary()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]byte, 0, len(src)+len(dictionary)+sizeOfUint64)
+	ret = append(ret, src...)
+	ret = append(ret, dictionary...)
+
+	return binary.LittleEndian.AppendUint64(ret, uint64(len(src))), nil
+}
+
+// migrateV5ToV6 splits the body that follows the header into the pages that
+// were introduced in version 6. The pages are tagged with the write epoch
+// zero, which precedes the epoch of any save.
+func migrateV5ToV6(src []byte) ([]byte, error) {
+	header, err := newArcHeaderFromBytes(src)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := paginate(src[header.len():], 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]byte, 0, header.len()+len(pages))
+	ret = append(ret, src[:header.len()]...)
+
+	return append(ret, pages...), nil
+}
+
+// migrateV6ToV7 makes room for the user metadata that was added to the header
+// in version 7. Version 6 files had no metadata, therefore the header only
+// grows by the length of the empty metadata, and the absolute offsets that
+// follow the header shift accordingly. The body is reassembled from its pages
+// to shift the offsets, and split again using the same write epoch.
+func migrateV6ToV7(src []byte) ([]byte, error) {
+	header, err := newArcHeaderFromBytes(src)
+
+	if err != nil {
+		return nil, err
+	}
+
+	headerLen := header.len()
+	logical, epoch, err := unpaginate(bytes.Clone(src[:headerLen]), src[headerLen:])
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The body ends with the original key and dictionary sections, which are
+	// each followed by the offset of the section. What precedes them is laid
+	// out like a version 3 file, which shiftOffsets supports.
+	const delta = sizeOfUint32
+
+	dictionaryOffset, err := trailingOffset(logical, headerLen)
+
+	if err != nil {
+		return nil, err
+	}
+
+	originalKeysOffset, err := trailingOffset(logical[:dictionaryOffset], headerLen)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := shiftOffsets(logical[:originalKeysOffset], headerLen, delta)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret = append(ret, logical[originalKeysOffset:dictionaryOffset-sizeOfUint64]...)
+	ret = binary.LittleEndian.AppendUint64(ret, uint64(originalKeysOffset+delta))
+	ret = append(ret, logical[dictionaryOffset:len(logical)-sizeOfUint64]...)
+	ret = binary.LittleEndian.AppendUint64(ret, uint64(dictionaryOffset+delta))
+
+	pages, err := paginate(ret[headerLen:], epoch)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ret[:headerLen], pages...), nil
+}
+
+// migrateV7ToV8 upgrades the file to version 8, which introduced the user
+// flags of the records. The flags are stored in the nodes that have the
+// hasUserFlags flag set, which version 7 never set, therefore the contents
+// are already valid.
+func migrateV7ToV8(src []byte) ([]byte, error) {
+	return src, nil
+}
+
+// migrateV8ToV9 appends the tombstone section that was introduced in version
+// 9, which is empty since version 8 had no tombstones, along with its offset.
+func migrateV8ToV9(src []byte) ([]byte, error) {
+	tombstones, err := New().serializeTombstones()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return appendPagedSection(src, tombstones)
+}
+
+// migrateV9ToV10 appends the version section that was introduced in version
+// 10, which is empty since version 9 kept no previous values, along with its
+// offset.
+func migrateV9ToV10(src []byte) ([]byte, error) {
+	versions, err := New().serializeVersions()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return appendPagedSection(src, versions)
+}
+
+// migrateV10ToV11 appends the timestamp section that was introduced in
+// version 11, which is empty since version 10 did not persist the record
+// timestamps, along with its offset.
+func migrateV10ToV11(src []byte) ([]byte, error) {
+	timestamps, err := New().serializeTimestamps()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return appendPagedSection(src, timestamps)
+}
+
+// appendPagedSection appends the section, followed by its offset, to the body
+// of a database file whose body is split into pages. The body is reassembled
+// from its pages to append the section, and split again using the same write
+// epoch.
+func appendPagedSection(src []byte, section []byte) ([]byte, error) {
+	header, err := newArcHeaderFromBytes(src)
+
+	if err != nil {
+		return nil, err
+	}
+
+	headerLen := header.len()
+	logical, epoch, err := unpaginate(bytes.Clone(src[:headerLen]), src[headerLen:])
+
+	if err != nil {
+		return nil, err
+	}
+
+	offset := uint64(len(logical))
+	logical = append(logical, section...)
+	logical = binary.LittleEndian.AppendUint64(logical, offset)
+
+	pages, err := paginate(logical[headerLen:], epoch)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(logical[:headerLen], pages...), nil
+}
+
+// trailingOffset returns the section offset that src ends with, which must lie
+// between the header of the given length and the offset itself.
+func trailingOffset(src []byte, headerLen int) (int, error) {
+	if len(src) < headerLen+sizeOfUint64 {
+		return 0, ErrCorrupted
+	}
+
+	offset := binary.LittleEndian.Uint64(src[len(src)-sizeOfUint64:])
+
+	if offset < uint64(headerLen) || offset > uint64(len(src)-sizeOfUint64) {
+		return 0, ErrCorrupted
+	}
+
+	return int(offset), nil
+}
+
+// Migrate upgrades the database file at the given path to the target file
+// format version in place. The file is replaced atomically once all the
+// migrations have succeeded. It is a no-op if the file is already at the
+// target version. Returns ErrUnsupportedVersion if the target version is older
+// than the file version, or if no migration path leads to it, and
+// ErrDatabaseLocked if the file is open.
+func Migrate(path string, targetVersion uint8) error {
+	return MigrateTo(path, path, targetVersion)
+}
+
+// MigrateTo is like Migrate, but writes the upgraded database to dst, leaving
+// the file at src untouched. The file is copied even if it is already at the
+// target version.
+func MigrateTo(src string, dst string, targetVersion uint8) error {
+	lock, err := acquireFileLock(src, src == dst, 0)
+
+	if err != nil {
+		return err
+	}
+
+	defer lock.Close()
+
+	data, err := os.ReadFile(src)
+
+	if err != nil {
+		return err
+	}
+
+	migrated, err := migrate(data, targetVersion)
+
+	if err != nil {
+		return err
+	}
+
+	if src == dst && bytes.Equal(migrated, data) {
+		return nil
+	}
+
+	// The manifest of the destination no longer describes it, and is
+	// written anew by the next save.
+	if err := os.Remove(manifestPath(dst)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return writeFileAtomic(dst, migrated)
+}
+
+// migrate applies the migrations that upgrade the serialized database to the
+// target version. It returns src as is if no migration is needed.
+func migrate(src []byte, targetVersion uint8) ([]byte, error) {
+	if targetVersion > fileFormatVersion {
+		return nil, ErrUnsupportedVersion
+	}
+
+	header, err := newArcHeaderFromBytes(src)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if header.magic != magicByte {
+		return nil, ErrCorrupted
+	}
+
+	if header.version > targetVersion {
+		return nil, ErrUnsupportedVersion
+	}
+
+	for header.version < targetVersion {
+		step, found := migrations[header.version]
+
+		if !found {
+			return nil, ErrUnsupportedVersion
+		}
+
+		if src, err = step(src); err != nil {
+			return nil, err
+		}
+
+		headerLen := header.len()
+		header.version++
+		headerBytes, err := header.serialize()
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Copy the header since src may still be the caller's buffer.
+		src = append(headerBytes, src[headerLen:]...)
+	}
+
+	return src, nil
+}
@@ -0,0 +1,115 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.arc")
+	arc, _ := Open(path)
+
+	for _, key := range sortedBasicTestKeys() {
+		arc.Put([]byte(key), []byte(key))
+	}
+
+	arc.Close()
+
+	original, _ := os.ReadFile(path)
+
+	if err := Migrate(path, fileFormatVersion); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, original) {
+		t.Errorf("expected the file to be left as is")
+	}
+
+	if err := Migrate(path, fileFormatVersion+1); err != ErrUnsupportedVersion {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrUnsupportedVersion)
+	}
+
+	if err := Migrate(path, 1); err != ErrUnsupportedVersion {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrUnsupportedVersion)
+	}
+
+	// Version 1 files have a shorter header, which shifts the offsets, and
+	// their body is not split into pages. They also lack the trailing
+	// expiration, original key, dictionary, tombstone, version, and timestamp
+	// sections along with their offsets, which are empty since the database
+	// has none of them.
+	logical := unpaginatedFile(t, original)
+	header := newArcHeader()
+	headerLen := header.len()
+	sectionLen := sizeOfUint64 + checksumLen + sizeOfUint64
+	dictionaryLen := sizeOfUint32 + checksumLen + sizeOfUint64
+	v3Len := len(logical) - sectionLen - dictionaryLen - 3*sectionLen
+	shifted, err := shiftOffsets(logical[:v3Len], headerLen, legacyArcHeaderBytesLen-headerLen)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	v1 := arcHeader{magic: magicByte, version: 1}
+	v1Header, _ := v1.serialize()
+	v1Len := len(shifted) - sectionLen
+	legacyPath := filepath.Join(dir, "legacy.arc")
+	os.WriteFile(legacyPath, append(v1Header, shifted[headerLen:v1Len]...), 0o600)
+
+	if _, err := Open(legacyPath); err != ErrUnsupportedVersion {
+		t.Fatalf("unexpected error: got:%v, want:%v", err, ErrUnsupportedVersion)
+	}
+
+	migratedPath := filepath.Join(dir, "migrated.arc")
+
+	if err := MigrateTo(legacyPath, migratedPath, fileFormatVersion); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, _ := os.ReadFile(legacyPath); bytes.Equal(got, original) {
+		t.Errorf("expected the source file to be left as is")
+	}
+
+	// The migrated pages carry the initial write epoch, unlike the saved ones.
+	if got, _ := os.ReadFile(migratedPath); !bytes.Equal(unpaginatedFile(t, got), logical) {
+		t.Errorf("unexpected migrated file")
+	}
+
+	if err := Migrate(legacyPath, fileFormatVersion); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := Open(legacyPath)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertSameRecords(t, reopened, arc)
+}
+
+// unpaginatedFile returns the database file with its body reassembled from
+// the pages, as it was laid out prior to version 6.
+func unpaginatedFile(t *testing.T, src []byte) []byte {
+	t.Helper()
+
+	header, err := newArcHeaderFromBytes(src)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ret, _, err := unpaginate(bytes.Clone(src[:header.len()]), src[header.len():])
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return ret
+}
@@ -0,0 +1,326 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrPrefixOverlap is returned by MovePrefix when one prefix begins with the
+// other, in which case the moved records would collide with each other.
+var ErrPrefixOverlap = errors.New("overlapping prefixes")
+
+// keyMove describes the move of a record from one key to another.
+type keyMove struct {
+	from     []byte // Current key of the record.
+	to       []byte // Key that the record is moved to.
+	original []byte // Spelling of the new key.
+	size     int    // Size of the record value.
+}
+
+// Rename moves the record that matches oldKey to newKey. The value is relinked
+// rather than copied, therefore the cost does not depend on the size of the
+// value, and blob values keep their single stored copy. The record keeps its
+// expiration, timestamps, user flags, and previous versions. Returns
+// ErrKeyNotFound if oldKey does not exist, and ErrDuplicateKey if newKey
+// already exists.
+func (a *Arc) Rename(oldKey []byte, newKey []byte) error {
+	oldKey = a.applyKeyTransform(oldKey)
+	newKey = a.applyKeyTransform(newKey)
+
+	return a.runHooks(OpInfo{Op: OpRename, Key: oldKey, To: newKey}, func(*OpInfo) error {
+		if err := a.authorize(OpRename, newKey); err != nil {
+			return err
+		}
+
+		if a.readOnly {
+			return ErrReadOnly
+		}
+
+		a.lock()
+		defer a.mu.Unlock()
+
+		return a.renameRecord(oldKey, newKey)
+	})
+}
+
+// MovePrefix moves every record whose key begins with oldPrefix to the key
+// that begins with newPrefix instead, followed by the rest of the original
+// key. Like Rename, the values are relinked rather than copied, which makes
+// restructuring a hierarchical keyspace cheap. The move is atomic: either
+// every record is moved, or none is. Returns ErrDuplicateKey if a moved record
+// would replace an existing record, and ErrPrefixOverlap if one prefix begins
+// with the other. Moving a prefix without records is a no-op.
+func (a *Arc) MovePrefix(oldPrefix []byte, newPrefix []byte) error {
+	oldPrefix = a.applyKeyTransform(oldPrefix)
+	newPrefix = a.applyKeyTransform(newPrefix)
+
+	return a.runHooks(OpInfo{Op: OpMovePrefix, Key: oldPrefix, To: newPrefix}, func(*OpInfo) error {
+		if err := a.authorize(OpMovePrefix, newPrefix); err != nil {
+			return err
+		}
+
+		if a.readOnly {
+			return ErrReadOnly
+		}
+
+		a.lock()
+		defer a.mu.Unlock()
+
+		return a.movePrefix(oldPrefix, newPrefix)
+	})
+}
+
+// renameRecord implements Rename. The caller must hold the write lock.
+func (a *Arc) renameRecord(oldKey []byte, newKey []byte) error {
+	from := a.foldKey(oldKey)
+
+	if err := a.checkKey(from); err != nil {
+		return err
+	}
+
+	if err := a.checkKey(newKey); err != nil {
+		return err
+	}
+
+	if err := a.checkLargeKey(newKey); err != nil {
+		return err
+	}
+
+	if !a.isLiveRecord(from) {
+		return ErrKeyNotFound
+	}
+
+	to := a.foldKey(newKey)
+
+	if bytes.Equal(from, to) {
+		return nil
+	}
+
+	n, _, _ := a.findNodeAndParent(from)
+	moves := []keyMove{{from: from, to: to, original: newKey, size: n.valueSize(a.blobs)}}
+
+	if err := a.moveRecords(moves); err != nil {
+		return err
+	}
+
+	a.publishChange(OpRename, oldKey, newKey)
+
+	return nil
+}
+
+// movePrefix implements MovePrefix. The caller must hold the write lock.
+func (a *Arc) movePrefix(oldPrefix []byte, newPrefix []byte) error {
+	fromPrefix := a.foldKey(oldPrefix)
+	toPrefix := a.foldKey(newPrefix)
+
+	if bytes.HasPrefix(fromPrefix, toPrefix) || bytes.HasPrefix(toPrefix, fromPrefix) {
+		return ErrPrefixOverlap
+	}
+
+	var moves []keyMove
+
+	a.walkPrefix(fromPrefix, func(key []byte, n *node) bool {
+		m := keyMove{
+			from:     key,
+			to:       append(bytes.Clone(toPrefix), key[len(fromPrefix):]...),
+			original: append(bytes.Clone(newPrefix), a.originalKey(key)[len(fromPrefix):]...),
+			size:     n.valueSize(a.blobs),
+		}
+
+		// The stored forms of large keys do not carry their suffixes, and
+		// keys that grow past the file format limit become large keys.
+		if a.opts.LargeKeys {
+			m.to = a.foldKey(m.original)
+		}
+
+		moves = append(moves, m)
+
+		return true
+	})
+
+	if len(moves) == 0 {
+		return nil
+	}
+
+	for _, m := range moves {
+		if err := a.checkKey(m.to); err != nil {
+			return err
+		}
+
+		if err := a.checkLargeKey(m.original); err != nil {
+			return err
+		}
+	}
+
+	if err := a.moveRecords(moves); err != nil {
+		return err
+	}
+
+	a.publishChange(OpMovePrefix, oldPrefix, newPrefix)
+
+	return nil
+}
+
+// moveRecords moves the records along with their metadata. The destinations
+// must not be among the moved records. Every move is validated before the
+// first record is moved. Expired records in the way are deleted as if they
+// had been swept. The caller must hold the write lock, and publish the change.
+func (a *Arc) moveRecords(moves []keyMove) error {
+	for _, m := range moves {
+		if a.isLiveRecord(m.to) {
+			return ErrDuplicateKey
+		}
+	}
+
+	for _, m := range moves {
+		if n, _, err := a.findNodeAndParent(m.to); err == nil && n.isRecord {
+			if err := a.deleteRecord(m.to); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := a.checkMoveQuotas(moves); err != nil {
+		return err
+	}
+
+	var updates []indexUpdate
+
+	for _, m := range moves {
+		removed, err := a.planIndexUpdates(m.from, nil, true)
+
+		if err != nil {
+			return err
+		}
+
+		updates = append(updates, removed...)
+
+		if len(a.indexes) == 0 {
+			continue
+		}
+
+		n, _, _ := a.findNodeAndParent(m.from)
+		value, err := a.nodeValue(n)
+
+		if err != nil {
+			return err
+		}
+
+		added, err := a.planIndexUpdates(m.to, value, false)
+
+		if err != nil {
+			return err
+		}
+
+		updates = append(updates, added...)
+	}
+
+	a.seq++
+
+	for _, m := range moves {
+		if err := a.relinkRecord(m); err != nil {
+			return err
+		}
+	}
+
+	a.applyIndexUpdates(updates)
+
+	return nil
+}
+
+// relinkRecord moves the value of the record to a new record node, and then
+// deletes the old record node. The value changes hands without touching the
+// blob reference counts. The caller must hold the write lock.
+func (a *Arc) relinkRecord(m keyMove) error {
+	// The destination holds no record, but may be an intermediate node.
+	if err := a.insert(m.to, nil, true); err != nil {
+		return err
+	}
+
+	a.retainLargeKey(m.to, m.original)
+
+	a.filterAdd(m.to)
+
+	src, _, err := a.findNodeAndParent(m.from)
+
+	if err != nil {
+		return err
+	}
+
+	dst, _, err := a.findNodeAndParent(m.to)
+
+	if err != nil {
+		return err
+	}
+
+	dst.data, dst.blobValue, dst.userFlags = src.data, src.blobValue, src.userFlags
+
+	if a.blobRefs != nil && dst.blobValue {
+		a.blobRefs.remove(blobID(dst.data), m.from)
+		a.blobRefs.add(blobID(dst.data), m.to)
+	}
+
+	// Detach the value from the old record node, which would otherwise
+	// release the blob that now belongs to the new record node.
+	src.data, src.blobValue = nil, false
+
+	if err := a.delete(m.from); err != nil {
+		return err
+	}
+
+	if t, found := a.expirations[string(m.from)]; found {
+		delete(a.expirations, string(m.from))
+		a.expirations[string(m.to)] = t
+	}
+
+	if ts, found := a.timestamps[string(m.from)]; found {
+		delete(a.timestamps, string(m.from))
+		a.timestamps[string(m.to)] = ts
+	}
+
+	if versions, found := a.versions[string(m.from)]; found {
+		delete(a.versions, string(m.from))
+		a.versions[string(m.to)] = versions
+	}
+
+	a.buryRecord(m.from)
+	delete(a.tombstones, string(m.to))
+	delete(a.originalKeys, string(m.from))
+
+	if a.opts.CaseInsensitiveKeys {
+		a.rememberOriginalKey(m.to, m.original)
+	}
+
+	a.chargeQuotas(m.from, -1, -int64(len(m.from)+m.size))
+	a.chargeQuotas(m.to, 1, int64(len(m.to)+m.size))
+
+	// The moved record keeps its size, therefore nothing is evicted. The
+	// usage of the old record node was forgotten when it was deleted, and
+	// the new record node is looked up again, since the deletion may have
+	// merged it into its parent.
+	if a.evictionEnabled() {
+		if n, _, err := a.findNodeAndParent(m.to); err == nil {
+			a.usage.record(n, m.to, m.size)
+		}
+	}
+
+	// The value is unchanged, and only the key size may differ.
+	if a.sizes != nil {
+		a.sizes.keys.remove(len(m.from))
+		a.sizes.keys.add(len(m.to))
+	}
+
+	a.moveReads(m.from, m.to)
+
+	a.refreshSubtreeRecords(m.from)
+	a.refreshSubtreeRecords(m.to)
+	a.refreshSubtreeHashes(m.from)
+	a.refreshSubtreeHashes(m.to)
+	a.noteTxnWrite(m.from)
+	a.noteTxnWrite(m.to)
+
+	return nil
+}
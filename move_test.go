@@ -0,0 +1,156 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRename(t *testing.T) {
+	arc := New()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	arc.now = func() time.Time { return now }
+
+	arc.Put([]byte("apple"), blobValueX())
+	arc.Put([]byte("app"), []byte("small"))
+	arc.PutWithFlags([]byte("banana"), []byte("yellow"), 0x01)
+	arc.Expire([]byte("banana"), time.Hour)
+
+	if err := arc.Rename([]byte("missing"), []byte("new")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+
+	if err := arc.Rename([]byte("apple"), []byte("app")); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrDuplicateKey)
+	}
+
+	seq := arc.Seq()
+
+	if err := arc.Rename([]byte("apple"), []byte("cherry")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := arc.Rename([]byte("banana"), []byte("ap")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if arc.Seq() != seq+2 {
+		t.Errorf("unexpected seq: got:%d, want:%d", arc.Seq(), seq+2)
+	}
+
+	assertKeys(t, collectKeys(arc), []string{"ap", "app", "cherry"})
+
+	if arc.Len() != 3 {
+		t.Errorf("unexpected length: got:%d, want:%d", arc.Len(), 3)
+	}
+
+	// The blob changed hands without being copied.
+	if len(arc.blobs) != 1 || arc.blobs[makeBlobID(blobValueX())].refCount != 1 {
+		t.Errorf("unexpected blobs after the rename")
+	}
+
+	if value, _ := arc.Get([]byte("cherry")); !bytes.Equal(value, blobValueX()) {
+		t.Errorf("unexpected value for %q", "cherry")
+	}
+
+	info, _ := arc.Stat([]byte("ap"))
+
+	if info.Flags != 0x01 || !info.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected the metadata to move along: %+v", info)
+	}
+
+	// Expired records do not stand in the way.
+	now = now.Add(time.Hour)
+
+	if err := arc.Rename([]byte("app"), []byte("ap")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if value, _ := arc.Get([]byte("ap")); string(value) != "small" {
+		t.Errorf("unexpected value: got:%q, want:%q", value, "small")
+	}
+}
+
+func TestMovePrefix(t *testing.T) {
+	opts := Options{TrackPrefixCounts: true, TrackSubtreeHashes: true}
+	arc, _ := NewWithOptions(opts)
+	want, _ := NewWithOptions(opts)
+
+	records := map[string]string{
+		"users/alice":      "a",
+		"users/bob":        "b",
+		"users/bob/avatar": string(blobValueX()),
+		"usersettings":     "s",
+		"groups/admins":    "g",
+	}
+
+	for key, value := range records {
+		arc.Put([]byte(key), []byte(value))
+	}
+
+	if err := arc.MovePrefix([]byte("users/"), []byte("users/archive/")); !errors.Is(err, ErrPrefixOverlap) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrPrefixOverlap)
+	}
+
+	if err := arc.MovePrefix([]byte("usersettings"), []byte("groups/admins")); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrDuplicateKey)
+	}
+
+	if err := arc.MovePrefix([]byte("missing/"), []byte("other/")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := arc.MovePrefix([]byte("users/"), []byte("accounts/")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for key, value := range records {
+		if bytes.HasPrefix([]byte(key), []byte("users/")) {
+			key = "accounts/" + key[len("users/"):]
+		}
+
+		want.Put([]byte(key), []byte(value))
+	}
+
+	assertSameRecords(t, arc, want)
+	assertSubtreeRecords(t, arc.root)
+	assertHashesMaintained(t, arc)
+
+	if !bytes.Equal(arc.RootHash(), want.RootHash()) {
+		t.Errorf("unexpected root hash")
+	}
+
+	if arc.numNodes != want.numNodes || arc.numRecords != want.numRecords {
+		t.Errorf("unexpected counts: got:%d/%d, want:%d/%d", arc.numNodes, arc.numRecords, want.numNodes, want.numRecords)
+	}
+}
+
+func TestMovePrefixMetadata(t *testing.T) {
+	arc, _ := NewWithOptions(Options{CaseInsensitiveKeys: true})
+	arc.CreateIndex("value", valueIndexFunc)
+	arc.SetQuota([]byte("b/"), 1, 0)
+
+	arc.Put([]byte("A/One"), []byte("x"))
+	arc.Put([]byte("A/Two"), []byte("y"))
+
+	// The quota is checked against every moved record.
+	if err := arc.MovePrefix([]byte("a/"), []byte("b/")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrQuotaExceeded)
+	}
+
+	if err := arc.MovePrefix([]byte("a/"), []byte("C/")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertKeys(t, collectKeys(arc), []string{"C/One", "C/Two"})
+	assertIndexQuery(t, arc, "value", []byte("x"), []byte("C/One"))
+	assertIndexQuery(t, arc, "value", []byte("y"), []byte("C/Two"))
+
+	if value, _ := arc.Get([]byte("c/ONE")); string(value) != "x" {
+		t.Errorf("unexpected value: got:%q, want:%q", value, "x")
+	}
+}
@@ -0,0 +1,793 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidMsgpack is returned by MsgpackCodec if the data is not valid
+	// MessagePack, or does not fit the value that it is decoded into.
+	ErrInvalidMsgpack = errors.New("invalid msgpack data")
+
+	// ErrUnsupportedMsgpackType is returned by MsgpackCodec if a value has a
+	// type that has no MessagePack representation, such as a channel.
+	ErrUnsupportedMsgpackType = errors.New("unsupported msgpack type")
+)
+
+const (
+	// msgpackTimeExt is the extension type of the timestamps defined by the
+	// MessagePack specification.
+	msgpackTimeExt = -1
+
+	// maxMsgpackDepth is the deepest nesting of arrays and maps that the
+	// decoder accepts, which bounds its recursion on malicious data.
+	maxMsgpackDepth = 10000
+)
+
+// msgpackCodec implements MsgpackCodec. It supports nil, booleans, integers,
+// floats, strings, byte slices, arrays, slices, maps with string keys,
+// structs, pointers, interfaces, and time.Time, which is encoded as the
+// timestamp extension. Structs are encoded as maps keyed by the field names,
+// which are renamed by the name in the msgpack struct tag, or skipped if the
+// name is "-". Fields tagged with omitempty are skipped if they hold the zero
+// value. Maps are encoded in the order of their encoded keys, which makes the
+// encoding deterministic. Values decoded into an empty interface are nil,
+// bool, int64, uint64 for integers beyond the range of int64, float64,
+// string, []byte, []any, map[string]any, or time.Time.
+type msgpackCodec struct{}
+
+// Marshal returns the MessagePack encoding of v.
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	return appendMsgpack(nil, reflect.ValueOf(v))
+}
+
+// Unmarshal decodes the MessagePack encoded data into v, which must be a
+// non-nil pointer.
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("%w: %T", ErrUnsupportedMsgpackType, v)
+	}
+
+	d := msgpackDecoder{src: data}
+
+	if err := d.decode(rv.Elem()); err != nil {
+		return err
+	}
+
+	if d.pos != len(d.src) {
+		return ErrInvalidMsgpack
+	}
+
+	return nil
+}
+
+var (
+	timeType  = reflect.TypeFor[time.Time]()
+	bytesType = reflect.TypeFor[[]byte]()
+)
+
+// appendMsgpack appends the MessagePack encoding of v to dst.
+func appendMsgpack(dst []byte, v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return append(dst, 0xc0), nil
+	}
+
+	if v.Type() == timeType {
+		return appendMsgpackTime(dst, v.Interface().(time.Time)), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(dst, 0xc3), nil
+		}
+
+		return append(dst, 0xc2), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendMsgpackInt(dst, v.Int()), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendMsgpackUint(dst, v.Uint()), nil
+
+	case reflect.Float32:
+		dst = append(dst, 0xca)
+		return binary.BigEndian.AppendUint32(dst, math.Float32bits(float32(v.Float()))), nil
+
+	case reflect.Float64:
+		dst = append(dst, 0xcb)
+		return binary.BigEndian.AppendUint64(dst, math.Float64bits(v.Float())), nil
+
+	case reflect.String:
+		return appendMsgpackString(dst, v.String()), nil
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(dst, 0xc0), nil
+		}
+
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendMsgpackBytes(dst, v.Bytes()), nil
+		}
+
+		return appendMsgpackArray(dst, v)
+
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+
+			return appendMsgpackBytes(dst, b), nil
+		}
+
+		return appendMsgpackArray(dst, v)
+
+	case reflect.Map:
+		if v.IsNil() {
+			return append(dst, 0xc0), nil
+		}
+
+		return appendMsgpackMap(dst, v)
+
+	case reflect.Struct:
+		return appendMsgpackStruct(dst, v)
+
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return append(dst, 0xc0), nil
+		}
+
+		return appendMsgpack(dst, v.Elem())
+	}
+
+	return nil, fmt.Errorf("%w: %v", ErrUnsupportedMsgpackType, v.Type())
+}
+
+// appendMsgpackInt appends the shortest encoding of the signed integer.
+func appendMsgpackInt(dst []byte, n int64) []byte {
+	switch {
+	case n >= 0:
+		return appendMsgpackUint(dst, uint64(n))
+	case n >= -32:
+		return append(dst, byte(n))
+	case n >= math.MinInt8:
+		return append(dst, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(dst, 0xd1), uint16(n))
+	case n >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(dst, 0xd2), uint32(n))
+	}
+
+	return binary.BigEndian.AppendUint64(append(dst, 0xd3), uint64(n))
+}
+
+// appendMsgpackUint appends the shortest encoding of the unsigned integer.
+func appendMsgpackUint(dst []byte, n uint64) []byte {
+	switch {
+	case n <= 0x7f:
+		return append(dst, byte(n))
+	case n <= math.MaxUint8:
+		return append(dst, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, 0xcd), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(dst, 0xce), uint32(n))
+	}
+
+	return binary.BigEndian.AppendUint64(append(dst, 0xcf), n)
+}
+
+// appendMsgpackString appends the string using the shortest str format.
+func appendMsgpackString(dst []byte, s string) []byte {
+	switch n := len(s); {
+	case n <= 31:
+		dst = append(dst, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		dst = append(dst, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		dst = binary.BigEndian.AppendUint16(append(dst, 0xda), uint16(n))
+	default:
+		dst = binary.BigEndian.AppendUint32(append(dst, 0xdb), uint32(n))
+	}
+
+	return append(dst, s...)
+}
+
+// appendMsgpackBytes appends the bytes using the shortest bin format.
+func appendMsgpackBytes(dst []byte, b []byte) []byte {
+	switch n := len(b); {
+	case n <= math.MaxUint8:
+		dst = append(dst, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		dst = binary.BigEndian.AppendUint16(append(dst, 0xc5), uint16(n))
+	default:
+		dst = binary.BigEndian.AppendUint32(append(dst, 0xc6), uint32(n))
+	}
+
+	return append(dst, b...)
+}
+
+// appendMsgpackHeader appends the header of an array or a map with n elements.
+// The fix, 16-bit, and 32-bit formats start with the given bytes.
+func appendMsgpackHeader(dst []byte, n int, fix byte, b16 byte, b32 byte) []byte {
+	switch {
+	case n <= 15:
+		return append(dst, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, b16), uint16(n))
+	}
+
+	return binary.BigEndian.AppendUint32(append(dst, b32), uint32(n))
+}
+
+// appendMsgpackArray appends the elements of the slice or array.
+func appendMsgpackArray(dst []byte, v reflect.Value) ([]byte, error) {
+	dst = appendMsgpackHeader(dst, v.Len(), 0x90, 0xdc, 0xdd)
+
+	var err error
+
+	for i := range v.Len() {
+		if dst, err = appendMsgpack(dst, v.Index(i)); err != nil {
+			return nil, err
+		}
+	}
+
+	return dst, nil
+}
+
+// appendMsgpackMap appends the entries of the map in the order of their
+// encoded keys.
+func appendMsgpackMap(dst []byte, v reflect.Value) ([]byte, error) {
+	type entry struct {
+		key   []byte
+		value reflect.Value
+	}
+
+	if v.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedMsgpackType, v.Type())
+	}
+
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+
+	for iter.Next() {
+		key, err := appendMsgpack(nil, iter.Key())
+
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry{key, iter.Value()})
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int {
+		return bytes.Compare(a.key, b.key)
+	})
+
+	dst = appendMsgpackHeader(dst, len(entries), 0x80, 0xde, 0xdf)
+
+	var err error
+
+	for _, e := range entries {
+		dst = append(dst, e.key...)
+
+		if dst, err = appendMsgpack(dst, e.value); err != nil {
+			return nil, err
+		}
+	}
+
+	return dst, nil
+}
+
+// msgpackField is an exported struct field along with its encoded name.
+type msgpackField struct {
+	name      string
+	index     int
+	omitEmpty bool
+}
+
+// msgpackFields returns the fields of the struct type that are encoded.
+func msgpackFields(t reflect.Type) []msgpackField {
+	var ret []msgpackField
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("msgpack"), ",")
+
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		ret = append(ret, msgpackField{name: name, index: i, omitEmpty: opts == "omitempty"})
+	}
+
+	return ret
+}
+
+// appendMsgpackStruct appends the struct as a map keyed by the field names.
+func appendMsgpackStruct(dst []byte, v reflect.Value) ([]byte, error) {
+	var fields []msgpackField
+
+	for _, f := range msgpackFields(v.Type()) {
+		if !f.omitEmpty || !v.Field(f.index).IsZero() {
+			fields = append(fields, f)
+		}
+	}
+
+	dst = appendMsgpackHeader(dst, len(fields), 0x80, 0xde, 0xdf)
+
+	var err error
+
+	for _, f := range fields {
+		dst = appendMsgpackString(dst, f.name)
+
+		if dst, err = appendMsgpack(dst, v.Field(f.index)); err != nil {
+			return nil, err
+		}
+	}
+
+	return dst, nil
+}
+
+// appendMsgpackTime appends the time using the timestamp extension, in the
+// 96-bit format that holds any time.Time to the nanosecond.
+func appendMsgpackTime(dst []byte, t time.Time) []byte {
+	dst = append(dst, 0xc7, 12, byte(msgpackTimeExt&0xff))
+	dst = binary.BigEndian.AppendUint32(dst, uint32(t.Nanosecond()))
+
+	return binary.BigEndian.AppendUint64(dst, uint64(t.Unix()))
+}
+
+// msgpackDecoder decodes the MessagePack values of src.
+type msgpackDecoder struct {
+	src   []byte
+	pos   int
+	depth int // Number of arrays and maps being read.
+}
+
+// enter descends into an array or a map, which must be left using leave.
+func (d *msgpackDecoder) enter() error {
+	if d.depth++; d.depth > maxMsgpackDepth {
+		return ErrInvalidMsgpack
+	}
+
+	return nil
+}
+
+// leave returns from an array or a map.
+func (d *msgpackDecoder) leave() {
+	d.depth--
+}
+
+// next returns the next n bytes.
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.src)-d.pos < n {
+		return nil, ErrInvalidMsgpack
+	}
+
+	ret := d.src[d.pos : d.pos+n]
+	d.pos += n
+
+	return ret, nil
+}
+
+// uint reads a big-endian unsigned integer of n bytes.
+func (d *msgpackDecoder) uint(n int) (uint64, error) {
+	b, err := d.next(n)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var ret uint64
+
+	for _, c := range b {
+		ret = ret<<8 | uint64(c)
+	}
+
+	return ret, nil
+}
+
+// decode decodes the next value into v, which must be settable.
+func (d *msgpackDecoder) decode(v reflect.Value) error {
+	value, err := d.read()
+
+	if err != nil {
+		return err
+	}
+
+	return setMsgpack(v, value)
+}
+
+// read decodes the next value into its empty interface representation.
+func (d *msgpackDecoder) read() (any, error) {
+	b, err := d.next(1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch c := b[0]; {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.readMap(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return d.readArray(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		return d.readString(int(c & 0x1f))
+	}
+
+	switch c := b[0]; c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (c - 0xc4))
+
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := d.next(int(n))
+
+		return bytes.Clone(b), err
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.uint(1 << (c - 0xc7))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return d.readExt(int(n))
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.uint(1 << (c - 0xcc))
+
+		if n > math.MaxInt64 {
+			return n, err
+		}
+
+		return int64(n), err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		n, err := d.uint(size)
+		shift := 64 - 8*size
+
+		return int64(n<<shift) >> shift, err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.readExt(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (c - 0xd9))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return d.readString(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return d.readArray(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return d.readMap(int(n))
+	}
+
+	return nil, ErrInvalidMsgpack
+}
+
+// readString reads a string of n bytes.
+func (d *msgpackDecoder) readString(n int) (any, error) {
+	b, err := d.next(n)
+	return string(b), err
+}
+
+// readArray reads an array of n elements.
+func (d *msgpackDecoder) readArray(n int) (any, error) {
+	// Every element takes at least a byte, which bounds the allocation.
+	if n > len(d.src)-d.pos {
+		return nil, ErrInvalidMsgpack
+	}
+
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+
+	defer d.leave()
+
+	ret := make([]any, n)
+
+	for i := range ret {
+		var err error
+
+		if ret[i], err = d.read(); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+// readMap reads a map of n entries. The keys must be strings.
+func (d *msgpackDecoder) readMap(n int) (any, error) {
+	if n > len(d.src)-d.pos {
+		return nil, ErrInvalidMsgpack
+	}
+
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+
+	defer d.leave()
+
+	ret := make(map[string]any, n)
+
+	for range n {
+		key, err := d.read()
+
+		if err != nil {
+			return nil, err
+		}
+
+		s, ok := key.(string)
+
+		if !ok {
+			return nil, ErrInvalidMsgpack
+		}
+
+		if ret[s], err = d.read(); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+// readExt reads an extension value with n bytes of data. Only the timestamp
+// extension is supported.
+func (d *msgpackDecoder) readExt(n int) (any, error) {
+	b, err := d.next(1 + n)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if int8(b[0]) != msgpackTimeExt {
+		return nil, ErrInvalidMsgpack
+	}
+
+	data := b[1:]
+
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0), nil
+	case 8:
+		n := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(n&(1<<34-1)), int64(n>>34)), nil
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data))), nil
+	}
+
+	return nil, ErrInvalidMsgpack
+}
+
+// setMsgpack stores the decoded value in v, which must be settable.
+func setMsgpack(v reflect.Value, value any) error {
+	if value == nil {
+		v.SetZero()
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			return ErrInvalidMsgpack
+		}
+
+		v.Set(reflect.ValueOf(value))
+
+		return nil
+
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		return setMsgpack(v.Elem(), value)
+	}
+
+	if v.Type() == timeType {
+		t, ok := value.(time.Time)
+
+		if !ok {
+			return ErrInvalidMsgpack
+		}
+
+		v.Set(reflect.ValueOf(t))
+
+		return nil
+	}
+
+	switch value := value.(type) {
+	case bool:
+		if v.Kind() == reflect.Bool {
+			v.SetBool(value)
+			return nil
+		}
+
+	case int64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if !v.OverflowInt(value) {
+				v.SetInt(value)
+				return nil
+			}
+
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if value >= 0 && !v.OverflowUint(uint64(value)) {
+				v.SetUint(uint64(value))
+				return nil
+			}
+
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(float64(value))
+			return nil
+		}
+
+	case uint64:
+		switch v.Kind() {
+		case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+			if !v.OverflowUint(value) {
+				v.SetUint(value)
+				return nil
+			}
+
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(float64(value))
+			return nil
+		}
+
+	case float64:
+		if v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+			v.SetFloat(value)
+			return nil
+		}
+
+	case string:
+		switch {
+		case v.Kind() == reflect.String:
+			v.SetString(value)
+			return nil
+		case v.Type() == bytesType:
+			v.SetBytes([]byte(value))
+			return nil
+		}
+
+	case []byte:
+		switch {
+		case v.Kind() == reflect.String:
+			v.SetString(string(value))
+			return nil
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			v.Set(reflect.ValueOf(value).Convert(v.Type()))
+			return nil
+		case v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8 && v.Len() == len(value):
+			reflect.Copy(v, reflect.ValueOf(value))
+			return nil
+		}
+
+	case []any:
+		return setMsgpackArray(v, value)
+
+	case map[string]any:
+		return setMsgpackMap(v, value)
+	}
+
+	return ErrInvalidMsgpack
+}
+
+// setMsgpackArray stores the decoded elements in the slice or array v.
+func setMsgpackArray(v reflect.Value, value []any) error {
+	switch v.Kind() {
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), len(value), len(value)))
+	case reflect.Array:
+		if v.Len() != len(value) {
+			return ErrInvalidMsgpack
+		}
+	default:
+		return ErrInvalidMsgpack
+	}
+
+	for i, elem := range value {
+		if err := setMsgpack(v.Index(i), elem); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setMsgpackMap stores the decoded entries in the map or struct v. Entries
+// that match no struct field are ignored.
+func setMsgpackMap(v reflect.Value, value map[string]any) error {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return ErrInvalidMsgpack
+		}
+
+		v.Set(reflect.MakeMapWithSize(v.Type(), len(value)))
+
+		for key, elem := range value {
+			e := reflect.New(v.Type().Elem()).Elem()
+
+			if err := setMsgpack(e, elem); err != nil {
+				return err
+			}
+
+			v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), e)
+		}
+
+		return nil
+
+	case reflect.Struct:
+		for _, f := range msgpackFields(v.Type()) {
+			elem, found := value[f.name]
+
+			if !found {
+				continue
+			}
+
+			if err := setMsgpack(v.Field(f.index), elem); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return ErrInvalidMsgpack
+}
@@ -0,0 +1,170 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type msgpackTestRecord struct {
+	Name     string             `msgpack:"name"`
+	Age      uint8              `msgpack:"age"`
+	Balance  int64              `msgpack:"balance"`
+	Score    float64            `msgpack:"score"`
+	Ratio    float32            `msgpack:"ratio"`
+	Active   bool               `msgpack:"active"`
+	Avatar   []byte             `msgpack:"avatar"`
+	ID       [4]byte            `msgpack:"id"`
+	Tags     []string           `msgpack:"tags"`
+	Labels   map[string]int     `msgpack:"labels"`
+	Manager  *msgpackTestRecord `msgpack:"manager"`
+	Extra    any                `msgpack:"extra"`
+	JoinedAt time.Time          `msgpack:"joined_at"`
+	Note     string             `msgpack:"note,omitempty"`
+	Secret   string             `msgpack:"-"`
+	hidden   int
+}
+
+func TestMsgpackCodec(t *testing.T) {
+	want := msgpackTestRecord{
+		Name:     strings.Repeat("n", 300),
+		Age:      200,
+		Balance:  math.MinInt64,
+		Score:    1.5,
+		Ratio:    0.25,
+		Active:   true,
+		Avatar:   bytes.Repeat([]byte{1}, 70000),
+		ID:       [4]byte{1, 2, 3, 4},
+		Tags:     make([]string, 20),
+		Labels:   map[string]int{"a": -1, "b": 1 << 40},
+		Manager:  &msgpackTestRecord{Name: "bob"},
+		Extra:    map[string]any{"list": []any{int64(1), "two", nil}},
+		JoinedAt: time.Date(2024, 12, 1, 0, 0, 0, 123, time.UTC),
+	}
+
+	data, err := MsgpackCodec.Marshal(want)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got msgpackTestRecord
+
+	if err := MsgpackCodec.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !got.JoinedAt.Equal(want.JoinedAt) {
+		t.Errorf("unexpected time: got:%v, want:%v", got.JoinedAt, want.JoinedAt)
+	}
+
+	got.JoinedAt, want.JoinedAt = time.Time{}, time.Time{}
+	got.Manager.JoinedAt, want.Manager.JoinedAt = time.Time{}, time.Time{}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected value: got:%+v, want:%+v", got, want)
+	}
+
+	// Maps are encoded in key order, therefore the encoding is stable.
+	if again, _ := MsgpackCodec.Marshal(want); !bytes.Equal(again, mustMarshalMsgpack(t, want)) {
+		t.Errorf("expected a deterministic encoding")
+	}
+}
+
+func TestMsgpackCodecEncoding(t *testing.T) {
+	tests := []struct {
+		value any
+		want  []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{127, []byte{0x7f}},
+		{-32, []byte{0xe0}},
+		{-33, []byte{0xd0, 0xdf}},
+		{256, []byte{0xcd, 0x01, 0x00}},
+		{"abc", []byte{0xa3, 'a', 'b', 'c'}},
+		{[]byte{1}, []byte{0xc4, 0x01, 0x01}},
+		{[]int{1, 2}, []byte{0x92, 0x01, 0x02}},
+		{map[string]bool{"b": true, "a": false}, []byte{0x82, 0xa1, 'a', 0xc2, 0xa1, 'b', 0xc3}},
+		{time.Unix(1, 0), []byte{0xc7, 0x0c, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}},
+	}
+
+	for _, test := range tests {
+		if got := mustMarshalMsgpack(t, test.value); !bytes.Equal(got, test.want) {
+			t.Errorf("unexpected encoding of %v: got:%x, want:%x", test.value, got, test.want)
+		}
+	}
+
+	// The 32-bit and 64-bit timestamp formats written by other encoders
+	// are decoded as well.
+	var ts time.Time
+
+	if err := MsgpackCodec.Unmarshal([]byte{0xd6, 0xff, 0, 0, 0, 1}, &ts); err != nil || !ts.Equal(time.Unix(1, 0)) {
+		t.Errorf("unexpected time: %v, err:%v", ts, err)
+	}
+
+	if err := MsgpackCodec.Unmarshal([]byte{0xd7, 0xff, 0, 0, 0, 4, 0, 0, 0, 1}, &ts); err != nil || !ts.Equal(time.Unix(1, 1)) {
+		t.Errorf("unexpected time: %v, err:%v", ts, err)
+	}
+
+	var value any
+
+	if err := MsgpackCodec.Unmarshal([]byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, &value); err != nil || value != uint64(math.MaxUint64) {
+		t.Errorf("unexpected value: %v, err:%v", value, err)
+	}
+}
+
+func TestMsgpackCodecErrors(t *testing.T) {
+	for _, value := range []any{make(chan int), map[int]string{1: "a"}, func() {}} {
+		if _, err := MsgpackCodec.Marshal(value); !errors.Is(err, ErrUnsupportedMsgpackType) {
+			t.Errorf("unexpected error with %T: %v", value, err)
+		}
+	}
+
+	var small int8
+	var name string
+
+	invalid := []struct {
+		data []byte
+		out  any
+	}{
+		{[]byte{0xcd, 0x01, 0x00}, &small},
+		{[]byte{0xa3, 'a'}, &name},
+		{[]byte{0xc3}, &name},
+		{[]byte{0xc1}, &name},
+		{[]byte{0xa1, 'a', 0xa1, 'b'}, &name},
+		{[]byte{0xdd, 0xff, 0xff, 0xff, 0xff}, &name},
+		{append(bytes.Repeat([]byte{0x91}, maxMsgpackDepth+1), 0xc0), &name},
+	}
+
+	for _, test := range invalid {
+		if err := MsgpackCodec.Unmarshal(test.data, test.out); !errors.Is(err, ErrInvalidMsgpack) {
+			t.Errorf("unexpected error with %x: %v", test.data, err)
+		}
+	}
+
+	if err := MsgpackCodec.Unmarshal([]byte{0xc0}, name); !errors.Is(err, ErrUnsupportedMsgpackType) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// mustMarshalMsgpack returns the MessagePack encoding of the value, and fails
+// the test on error.
+func mustMarshalMsgpack(t *testing.T, value any) []byte {
+	t.Helper()
+
+	ret, err := MsgpackCodec.Marshal(value)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return ret
+}
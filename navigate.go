@@ -0,0 +1,272 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import "bytes"
+
+// Min returns the record with the smallest key, or the largest key if
+// Options.ReverseOrder is set. Navigation methods compare keys in the order of
+// the database. Returns ErrKeyNotFound if the database is empty.
+func (a *Arc) Min() (key []byte, value []byte, err error) {
+	a.rlock()
+	defer a.runlock()
+
+	if a.empty() {
+		return nil, nil, ErrKeyNotFound
+	}
+
+	if a.opts.ReverseOrder {
+		key, n := lastRecord(a.root, nil)
+		return a.navigationResult(a.skipExpired(key, n, false))
+	}
+
+	key, n := firstRecord(a.root, nil)
+
+	return a.navigationResult(a.skipExpired(key, n, true))
+}
+
+// Max returns the record with the largest key, or the smallest key if
+// Options.ReverseOrder is set. Returns ErrKeyNotFound if the database is empty.
+func (a *Arc) Max() (key []byte, value []byte, err error) {
+	a.rlock()
+	defer a.runlock()
+
+	if a.empty() {
+		return nil, nil, ErrKeyNotFound
+	}
+
+	if a.opts.ReverseOrder {
+		key, n := firstRecord(a.root, nil)
+		return a.navigationResult(a.skipExpired(key, n, true))
+	}
+
+	key, n := lastRecord(a.root, nil)
+
+	return a.navigationResult(a.skipExpired(key, n, false))
+}
+
+// Ceiling returns the record with the smallest key that is greater than or
+// equal to the given key. Returns ErrKeyNotFound if no such record exists.
+func (a *Arc) Ceiling(key []byte) ([]byte, []byte, error) {
+	return a.navigate(key, true, true)
+}
+
+// Floor returns the record with the largest key that is less than or equal to
+// the given key. Returns ErrKeyNotFound if no such record exists.
+func (a *Arc) Floor(key []byte) ([]byte, []byte, error) {
+	return a.navigate(key, false, true)
+}
+
+// Next returns the record with the smallest key that is strictly greater than
+// the given key. The given key does not need to exist. Returns ErrKeyNotFound
+// if no such record exists.
+func (a *Arc) Next(key []byte) ([]byte, []byte, error) {
+	return a.navigate(key, true, false)
+}
+
+// Prev returns the record with the largest key that is strictly less than the
+// given key. The given key does not need to exist. Returns ErrKeyNotFound if
+// no such record exists.
+func (a *Arc) Prev(key []byte) ([]byte, []byte, error) {
+	return a.navigate(key, false, false)
+}
+
+// navigate looks up the record closest to the given key in the requested
+// direction, which is relative to the order of the database. The given key
+// itself qualifies if inclusive is true.
+func (a *Arc) navigate(key []byte, forward bool, inclusive bool) ([]byte, []byte, error) {
+	key = a.transformKey(key)
+
+	if a.opts.ReverseOrder {
+		forward = !forward
+	}
+
+	if err := a.checkKey(key); err != nil {
+		return nil, nil, err
+	}
+
+	a.rlock()
+	defer a.runlock()
+
+	if a.empty() {
+		return nil, nil, ErrKeyNotFound
+	}
+
+	if forward {
+		key, n := ceilingRecord(a.root, nil, key, inclusive)
+		return a.navigationResult(a.skipExpired(key, n, true))
+	}
+
+	key, n := floorRecord(a.root, nil, key, inclusive)
+
+	return a.navigationResult(a.skipExpired(key, n, false))
+}
+
+// skipExpired returns the given record, or the closest record beyond it in the
+// given direction that has not expired. It returns a nil node if no such
+// record exists.
+func (a *Arc) skipExpired(key []byte, n *node, forward bool) ([]byte, *node) {
+	for n != nil && a.expired(key) {
+		if forward {
+			key, n = ceilingRecord(a.root, nil, key, false)
+		} else {
+			key, n = floorRecord(a.root, nil, key, false)
+		}
+	}
+
+	return key, n
+}
+
+// navigationResult converts the record located by a navigation function into
+// the public return values.
+func (a *Arc) navigationResult(key []byte, n *node) ([]byte, []byte, error) {
+	if n == nil {
+		return nil, nil, ErrKeyNotFound
+	}
+
+	if !a.readable(key) {
+		return nil, nil, ErrPermission
+	}
+
+	value, err := a.nodeValue(n)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return a.originalKey(key), value, nil
+}
+
+// fullKey returns a newly allocated key that concatenates the given parent key
+// and the node's key.
+func fullKey(parentKey []byte, n *node) []byte {
+	ret := make([]byte, 0, len(parentKey)+len(n.key))
+	ret = append(ret, parentKey...)
+
+	return append(ret, n.key...)
+}
+
+// firstRecord returns the record with the smallest key in the subtree rooted
+// at n, along with its full key.
+func firstRecord(n *node, parentKey []byte) ([]byte, *node) {
+	key := fullKey(parentKey, n)
+
+	if n.isRecord {
+		return key, n
+	}
+
+	for child := n.firstChild; child != nil; child = child.nextSibling {
+		if k, found := firstRecord(child, key); found != nil {
+			return k, found
+		}
+	}
+
+	return nil, nil
+}
+
+// lastRecord returns the record with the largest key in the subtree rooted at
+// n, along with its full key.
+func lastRecord(n *node, parentKey []byte) ([]byte, *node) {
+	key := fullKey(parentKey, n)
+	children := n.children()
+
+	for i := len(children) - 1; i >= 0; i-- {
+		if k, found := lastRecord(children[i], key); found != nil {
+			return k, found
+		}
+	}
+
+	if n.isRecord {
+		return key, n
+	}
+
+	return nil, nil
+}
+
+// ceilingRecord returns the record with the smallest key in the subtree rooted
+// at n that is greater than (or equal to, if inclusive) the target. The target
+// is relative to parentKey, which is the full key of n's parent.
+func ceilingRecord(n *node, parentKey []byte, target []byte, inclusive bool) ([]byte, *node) {
+	m := min(len(n.key), len(target))
+
+	switch bytes.Compare(n.key[:m], target[:m]) {
+	case 1:
+		// Every key in the subtree is greater than the target.
+		return firstRecord(n, parentKey)
+	case -1:
+		// Every key in the subtree is less than the target.
+		return nil, nil
+	}
+
+	// The target is a proper prefix of the node's full key, therefore every
+	// key in the subtree is greater than the target.
+	if len(n.key) > len(target) {
+		return firstRecord(n, parentKey)
+	}
+
+	key := fullKey(parentKey, n)
+	rest := target[len(n.key):]
+
+	if len(rest) == 0 && n.isRecord && inclusive {
+		return key, n
+	}
+
+	for child := n.firstChild; child != nil; child = child.nextSibling {
+		if k, found := ceilingRecord(child, key, rest, inclusive); found != nil {
+			return k, found
+		}
+	}
+
+	return nil, nil
+}
+
+// floorRecord returns the record with the largest key in the subtree rooted at
+// n that is less than (or equal to, if inclusive) the target. The target is
+// relative to parentKey, which is the full key of n's parent.
+func floorRecord(n *node, parentKey []byte, target []byte, inclusive bool) ([]byte, *node) {
+	m := min(len(n.key), len(target))
+
+	switch bytes.Compare(n.key[:m], target[:m]) {
+	case -1:
+		// Every key in the subtree is less than the target.
+		return lastRecord(n, parentKey)
+	case 1:
+		// Every key in the subtree is greater than the target.
+		return nil, nil
+	}
+
+	// The target is a proper prefix of the node's full key, therefore every
+	// key in the subtree is greater than the target.
+	if len(n.key) > len(target) {
+		return nil, nil
+	}
+
+	key := fullKey(parentKey, n)
+	rest := target[len(n.key):]
+
+	// The node's full key equals the target, and the keys of its descendants
+	// are all greater than the target.
+	if len(rest) == 0 {
+		if n.isRecord && inclusive {
+			return key, n
+		}
+
+		return nil, nil
+	}
+
+	children := n.children()
+
+	for i := len(children) - 1; i >= 0; i-- {
+		if k, found := floorRecord(children[i], key, rest, inclusive); found != nil {
+			return k, found
+		}
+	}
+
+	// The node's full key is a proper prefix of the target.
+	if n.isRecord {
+		return key, n
+	}
+
+	return nil, nil
+}
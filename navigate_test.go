@@ -0,0 +1,160 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestMinMax(t *testing.T) {
+	arc := New()
+
+	if _, _, err := arc.Min(); err != ErrKeyNotFound {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+
+	if _, _, err := arc.Max(); err != ErrKeyNotFound {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+
+	arc = basicTestTree()
+
+	if key, value, _ := arc.Min(); string(key) != "apple" || string(value) != "cider" {
+		t.Errorf("unexpected min: got:%q=%q, want:%q=%q", key, value, "apple", "cider")
+	}
+
+	if key, value, _ := arc.Max(); string(key) != "orange" || string(value) != "juice" {
+		t.Errorf("unexpected max: got:%q=%q, want:%q=%q", key, value, "orange", "juice")
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	arc := basicTestTree()
+
+	testCases := []struct {
+		key     string
+		ceiling string
+		floor   string
+		next    string
+		prev    string
+	}{
+		{key: "a", ceiling: "apple", floor: "", next: "apple", prev: ""},
+		{key: "apple", ceiling: "apple", floor: "apple", next: "applet", prev: ""},
+		{key: "applea", ceiling: "applet", floor: "apple", next: "applet", prev: "apple"},
+		{key: "ap", ceiling: "apple", floor: "", next: "apple", prev: ""},
+		{key: "b", ceiling: "banana", floor: "apricot", next: "banana", prev: "apricot"},
+		{key: "band", ceiling: "band", floor: "band", next: "bandage", prev: "banana"},
+		{key: "bandz", ceiling: "berry", floor: "bandsaw", next: "berry", prev: "bandsaw"},
+		{key: "lemonadez", ceiling: "lime", floor: "lemonade", next: "lime", prev: "lemonade"},
+		{key: "orange", ceiling: "orange", floor: "orange", next: "", prev: "limestone"},
+		{key: "z", ceiling: "", floor: "orange", next: "", prev: "orange"},
+	}
+
+	for _, tc := range testCases {
+		for name, fn := range map[string]func([]byte) ([]byte, []byte, error){
+			"Ceiling": arc.Ceiling,
+			"Floor":   arc.Floor,
+			"Next":    arc.Next,
+			"Prev":    arc.Prev,
+		} {
+			want := map[string]string{"Ceiling": tc.ceiling, "Floor": tc.floor, "Next": tc.next, "Prev": tc.prev}[name]
+			assertNavigation(t, name, tc.key, fn, want)
+		}
+	}
+
+	if _, _, err := arc.Ceiling(nil); err != ErrNilKey {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrNilKey)
+	}
+}
+
+func TestNavigationRandomized(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	arc := New()
+	known := map[string]bool{}
+
+	for i := 0; i < 500; i++ {
+		key := randomKey(rng, "abc", 6)
+		arc.Put(key, key)
+		known[string(key)] = true
+	}
+
+	sorted := make([]string, 0, len(known))
+
+	for key := range known {
+		sorted = append(sorted, key)
+	}
+
+	sort.Strings(sorted)
+
+	for i := 0; i < 500; i++ {
+		probe := string(randomKey(rng, "abcd", 7))
+		pos := sort.SearchStrings(sorted, probe)
+		exists := pos < len(sorted) && sorted[pos] == probe
+
+		var ceiling, floor, next, prev string
+
+		if pos < len(sorted) {
+			ceiling = sorted[pos]
+		}
+
+		if exists {
+			floor = probe
+
+			if pos+1 < len(sorted) {
+				next = sorted[pos+1]
+			}
+		} else {
+			next = ceiling
+
+			if pos > 0 {
+				floor = sorted[pos-1]
+			}
+		}
+
+		if pos > 0 {
+			prev = sorted[pos-1]
+		}
+
+		assertNavigation(t, "Ceiling", probe, arc.Ceiling, ceiling)
+		assertNavigation(t, "Floor", probe, arc.Floor, floor)
+		assertNavigation(t, "Next", probe, arc.Next, next)
+		assertNavigation(t, "Prev", probe, arc.Prev, prev)
+	}
+}
+
+func assertNavigation(t *testing.T, name string, key string, fn func([]byte) ([]byte, []byte, error), want string) {
+	t.Helper()
+
+	got, _, err := fn([]byte(key))
+
+	if want == "" {
+		if err != ErrKeyNotFound {
+			t.Errorf("%s(%q): unexpected result: got:%q, err:%v", name, key, got, err)
+		}
+
+		return
+	}
+
+	if err != nil {
+		t.Fatalf("%s(%q): unexpected error: %v", name, key, err)
+	}
+
+	if string(got) != want {
+		t.Errorf("%s(%q): unexpected key: got:%q, want:%q", name, key, got, want)
+	}
+}
+
+// randomKey returns a non-empty random key of up to maxLen bytes drawn from
+// the given alphabet.
+func randomKey(rng *rand.Rand, alphabet string, maxLen int) []byte {
+	ret := make([]byte, 1+rng.Intn(maxLen))
+
+	for i := range ret {
+		ret[i] = alphabet[rng.Intn(len(alphabet))]
+	}
+
+	return ret
+}
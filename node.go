@@ -10,25 +10,40 @@ import "bytes"
 // both node representation and persistence metadata. Consider memory overhead
 // carefully before adding new fields to this struct.
 type node struct {
-	key         []byte // Path segment of the node.
-	isRecord    bool   // True if the node contains a database record.
-	blobValue   bool   // True if the value is stored in the blobStore.
-	numChildren int    // Number of connected child nodes.
-	firstChild  *node  // Pointer to the first child node.
-	nextSibling *node  // Pointer to the adjacent sibling node.
+	key       []byte // Path segment of the node.
+	isRecord  bool   // True if the node contains a database record.
+	blobValue bool   // True if the value is stored in the blobStore.
+	userFlags uint8  // Flags set by PutWithFlags and SetFlags.
+
+	// Number of records in the subtree rooted at this node, including the
+	// node itself. Only maintained when Options.TrackPrefixCounts is set.
+	// The field fits in the padding after the booleans and the user flags,
+	// and therefore does not increase the size of the struct.
+	subtreeRecords uint32
+
+	numChildren int   // Number of connected child nodes.
+	firstChild  *node // Pointer to the first child node.
+	nextSibling *node // Pointer to the adjacent sibling node.
 
 	// Holds the node's content. For values less than or equal to 32 bytes,
 	// it stores the content directly. For larger values, it stores a blobID
 	// that references the content in the blobStore.
 	data []byte
+
+	// Usage of the record, which links the record into the list of the
+	// records in the order of use. Only maintained when a capacity limit is
+	// configured. The pointer is present in every node regardless, and
+	// grows the struct from 80 to 88 bytes, which is allocated from the
+	// 96-byte size class.
+	usage *usageEntry
 }
 
-func newRecordNode(bs blobStore, key []byte, value []byte) *node {
+func newRecordNode(bs blobStore, hash func([]byte) blobID, key []byte, value []byte) *node {
 	ret := &node{isRecord: true}
 	ret.setKey(key)
 
 	if value != nil {
-		ret.setValue(bs, value)
+		ret.setValue(bs, hash, value)
 	}
 
 	return ret
@@ -61,6 +76,53 @@ func (n node) value(bs blobStore) []byte {
 	return bs.get(n.data)
 }
 
+// rawValue returns the node's value without copying it. The returned slice
+// must not be modified. It remains valid after the node is updated, because
+// values are replaced rather than modified in place.
+func (n node) rawValue(bs blobStore) []byte {
+	if !n.blobValue {
+		return n.data
+	}
+
+	id, err := sliceToBlobID(n.data)
+
+	if err != nil {
+		return nil
+	}
+
+	if b, found := bs[id]; found {
+		value, _ := b.load()
+		return value
+	}
+
+	return nil
+}
+
+// valueSize returns the size of the node's value in bytes without copying it.
+func (n node) valueSize(bs blobStore) int {
+	if !n.blobValue {
+		return len(n.data)
+	}
+
+	return bs.size(n.data)
+}
+
+// countSubtreeRecords returns the number of records in the subtree rooted at
+// the node, based on the subtreeRecords of its children.
+func (n node) countSubtreeRecords() uint32 {
+	var ret uint32
+
+	if n.isRecord {
+		ret = 1
+	}
+
+	for child := n.firstChild; child != nil; child = child.nextSibling {
+		ret += child.subtreeRecords
+	}
+
+	return ret
+}
+
 // forEachChild loops over the children of the node, and calls the given
 // callback function on each visit.
 func (n node) forEachChild(cb func(int, *node) error) error {
@@ -81,6 +143,19 @@ func (n node) forEachChild(cb func(int, *node) error) error {
 	return nil
 }
 
+// children returns the node's children as a slice in ascending key order. It
+// is useful for traversing the children in reverse order, which the singly
+// linked list of siblings does not support.
+func (n node) children() []*node {
+	ret := make([]*node, 0, n.numChildren)
+
+	for child := n.firstChild; child != nil; child = child.nextSibling {
+		ret = append(ret, child)
+	}
+
+	return ret
+}
+
 // findChild returns the node's child that matches the given key.
 func (n node) findChild(key []byte) (*node, error) {
 	for child := n.firstChild; child != nil; child = child.nextSibling {
@@ -111,7 +186,7 @@ func (n *node) setKey(key []byte) {
 }
 
 // setValue sets the given value to the node and flags it as a record node.
-func (n *node) setValue(bs blobStore, value []byte) {
+func (n *node) setValue(bs blobStore, hash func([]byte) blobID, value []byte) {
 	if n.blobValue {
 		bs.release(n.data)
 	}
@@ -120,7 +195,8 @@ func (n *node) setValue(bs blobStore, value []byte) {
 		n.data = value
 		n.blobValue = false
 	} else {
-		id := bs.put(value)
+		id := hash(value)
+		bs.put(id, value)
 		n.data = id.Slice()
 		n.blobValue = true
 	}
@@ -135,15 +211,17 @@ func (n *node) deleteValue(bs blobStore) {
 	}
 
 	n.data = nil
+	n.blobValue = false
 }
 
-// prependKey prepends the given prefix to the node's existing key.
-func (n *node) prependKey(prefix []byte) {
+// prependKey prepends the given prefix to the node's existing key. The new key
+// is allocated from the given arena.
+func (n *node) prependKey(keys *keyArena, prefix []byte) {
 	if len(prefix) == 0 {
 		return
 	}
 
-	newKey := make([]byte, len(prefix)+len(n.key))
+	newKey := keys.alloc(len(prefix) + len(n.key))
 
 	copy(newKey, prefix)
 	copy(newKey[len(prefix):], n.key)
@@ -228,7 +306,11 @@ func (n *node) shallowCopyFrom(src *node) {
 	n.key = src.key
 	n.data = src.data
 	n.isRecord = src.isRecord
+	n.blobValue = src.blobValue
+	n.userFlags = src.userFlags
 	n.numChildren = src.numChildren
+	n.subtreeRecords = src.subtreeRecords
+	n.usage = src.usage
 	n.firstChild = src.firstChild
 	n.nextSibling = src.nextSibling
 }
@@ -121,13 +121,13 @@ func TestPrependKey(t *testing.T) {
 	prefix := []byte("parent-")
 	expected := []byte("parent-child")
 
-	subject.prependKey(prefix)
+	subject.prependKey(nil, prefix)
 
 	if !bytes.Equal(subject.key, expected) {
 		t.Errorf("unexpected result, got:%q, want:%q", subject.key, expected)
 	}
 
-	subject.prependKey(nil)
+	subject.prependKey(&keyArena{}, nil)
 
 	if !bytes.Equal(subject.key, expected) {
 		t.Errorf("unexpected result, got:%q, want:%q", subject.key, expected)
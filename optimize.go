@@ -0,0 +1,119 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+// Optimize restores the path compression of the tree, and returns the number
+// of nodes that it eliminated. Non-record nodes without children are removed,
+// and non-record nodes with a single child are merged into the child. Deletes
+// already maintain these invariants, therefore Optimize is a safety net for
+// long-lived databases rather than routine maintenance, and normally returns
+// zero. The trees of the secondary indexes are optimized as well, and their
+// eliminated nodes are included in the count. The records are unchanged, and
+// so is the database file until it is next saved. Returns ErrReadOnly if the
+// database is read-only.
+func (a *Arc) Optimize() (int, error) {
+	if a.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	a.lock()
+	defer a.mu.Unlock()
+
+	return a.optimize(), nil
+}
+
+// optimize restores the path compression of the tree and the index trees, and
+// returns the number of nodes that it eliminated. The caller must hold the
+// write lock.
+func (a *Arc) optimize() int {
+	var ret int
+
+	if a.root != nil {
+		root, _ := a.optimizeNode(a.root, &ret)
+
+		// A tree without records has no root.
+		if root == nil {
+			a.root = nil
+		} else {
+			root.nextSibling = nil
+			a.root = root
+		}
+
+		a.numNodes -= ret
+
+		if a.opts.TrackSubtreeHashes && a.root != nil {
+			a.hashNode(a.root, true)
+		}
+	}
+
+	for _, idx := range a.indexes {
+		ret += idx.tree.optimize()
+	}
+
+	return ret
+}
+
+// optimizeNode optimizes the subtree rooted at n, and adds the number of
+// eliminated nodes to removed. It returns the node that replaces n, which is
+// nil if the subtree holds no records, and whether the subtree was changed.
+// The sibling of the returned node is left for the caller to relink.
+func (a *Arc) optimizeNode(n *node, removed *int) (*node, bool) {
+	var changed bool
+	var last *node
+
+	children := n.firstChild
+	n.firstChild = nil
+	n.numChildren = 0
+
+	for child := children; child != nil; {
+		next := child.nextSibling
+		replacement, childChanged := a.optimizeNode(child, removed)
+		changed = changed || childChanged
+
+		if replacement != nil {
+			replacement.nextSibling = nil
+
+			if last == nil {
+				n.firstChild = replacement
+			} else {
+				last.nextSibling = replacement
+			}
+
+			last = replacement
+			n.numChildren++
+		}
+
+		child = next
+	}
+
+	if !n.isRecord {
+		switch n.numChildren {
+		case 0:
+			a.forgetHash(n)
+			*removed++
+
+			return nil, true
+
+		case 1:
+			// The only child inherits the key of the redundant node. Its
+			// subtree record count is unaffected, since non-record nodes
+			// do not count towards it.
+			child := n.firstChild
+			child.prependKey(a.keys, n.key)
+			a.forgetHash(n)
+			a.forgetHash(child)
+			*removed++
+
+			return child, true
+		}
+	}
+
+	// The hash covers the children, therefore it is stale once any of them
+	// has changed.
+	if changed {
+		a.forgetHash(n)
+	}
+
+	return n, changed
+}
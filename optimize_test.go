@@ -0,0 +1,102 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	arc, _ := NewWithOptions(Options{TrackPrefixCounts: true, TrackSubtreeHashes: true})
+
+	for _, key := range []string{"apple", "cherry", "grape"} {
+		arc.Put([]byte(key), []byte(key))
+	}
+
+	want := arc.Clone()
+
+	// Degenerate the tree behind the back of the write path: "apple" gains
+	// a redundant parent, and an empty non-record leaf is added.
+	arc.lock()
+	apple, _ := arc.root.findChild([]byte("apple"))
+	arc.root.removeChild(apple)
+	apple.setKey([]byte("ple"))
+	apple.nextSibling = nil
+	arc.forgetHash(apple)
+
+	redundant := &node{key: []byte("ap")}
+	redundant.addChild(apple)
+	redundant.subtreeRecords = 1
+	arc.root.addChild(redundant)
+	arc.root.addChild(&node{key: []byte("dead")})
+	arc.forgetHash(arc.root)
+	arc.numNodes += 2
+	arc.mu.Unlock()
+
+	if bytes.Equal(arc.RootHash(), want.RootHash()) {
+		t.Fatalf("expected the degenerate tree to hash differently")
+	}
+
+	removed, err := arc.Optimize()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if removed != 2 {
+		t.Errorf("unexpected number of eliminated nodes: got:%d, want:%d", removed, 2)
+	}
+
+	assertSameRecords(t, arc, want)
+	assertSubtreeRecords(t, arc.root)
+	assertHashesMaintained(t, arc)
+
+	if arc.numNodes != want.numNodes {
+		t.Errorf("unexpected numNodes: got:%d, want:%d", arc.numNodes, want.numNodes)
+	}
+
+	if !bytes.Equal(arc.RootHash(), want.RootHash()) {
+		t.Errorf("expected the optimized tree to hash like the original")
+	}
+
+	if removed, _ := arc.Optimize(); removed != 0 {
+		t.Errorf("unexpected number of eliminated nodes: got:%d, want:%d", removed, 0)
+	}
+}
+
+func TestOptimizeAfterChurn(t *testing.T) {
+	arc := New()
+	arc.CreateIndex("value", valueIndexFunc)
+	rng := rand.New(rand.NewSource(7))
+
+	for range 2000 {
+		key := []byte(fmt.Sprintf("key%d", rng.Intn(300)))
+
+		if rng.Intn(2) == 0 {
+			arc.Delete(key)
+		} else {
+			arc.Put(key, []byte(fmt.Sprintf("v%d", rng.Intn(5))))
+		}
+	}
+
+	// Deletes maintain the path compression, therefore there is nothing to
+	// eliminate.
+	if removed, _ := arc.Optimize(); removed != 0 {
+		t.Errorf("unexpected number of eliminated nodes: got:%d, want:%d", removed, 0)
+	}
+}
+
+func TestOptimizeEmptied(t *testing.T) {
+	arc := New()
+	arc.root = &node{key: []byte("a")}
+	arc.root.addChild(&node{key: []byte("b")})
+	arc.numNodes = 2
+
+	if removed, _ := arc.Optimize(); removed != 2 || arc.root != nil || arc.numNodes != 0 {
+		t.Errorf("unexpected tree: removed:%d, numNodes:%d", removed, arc.numNodes)
+	}
+}
@@ -0,0 +1,351 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"time"
+)
+
+// Options holds the configurable parameters of an Arc database. The zero value
+// is valid and yields the same behavior as New.
+type Options struct {
+	// MaxKeyBytes is the maximum key size in bytes. Zero means that the key
+	// size is only bound by the 64KB file format limit, or by the 4GB limit
+	// of the blob store if LargeKeys is set.
+	MaxKeyBytes int
+
+	// MaxValueBytes is the maximum value size in bytes. Zero means that the
+	// value size is only bound by the 4GB file format limit.
+	MaxValueBytes int
+
+	// MaxDepth is the maximum number of nodes on the path from the root of
+	// the tree to any node, including both. Writes that would exceed it fail
+	// with ErrTreeTooDeep, which guards against key patterns that degrade
+	// the tree into a long chain of nested prefixes. Writes that split a
+	// node check the depth of its entire subtree. Zero means no limit.
+	MaxDepth int
+
+	// MaxChildrenPerNode is the maximum number of children of a node.
+	// Writes that would exceed it fail with ErrTooManyChildren, which guards
+	// against key patterns that make a single node branch out to a large
+	// number of children. It must be at least 2, since a split yields a node
+	// with two children. Zero means no limit.
+	MaxChildrenPerNode int
+
+	// RecordTimestamps enables tracking of the creation and last update time
+	// of each record, which are reported by Stat. The timestamps are saved
+	// in the database file, and are discarded when the file is opened
+	// without this option. It is disabled by default to avoid the per-record
+	// memory overhead.
+	RecordTimestamps bool
+
+	// TrackPrefixCounts maintains the number of records in every subtree,
+	// which makes CountPrefix run in O(depth) time instead of walking the
+	// subtree. The bookkeeping adds a small cost to every write operation.
+	TrackPrefixCounts bool
+
+	// TrackSubtreeHashes maintains a SHA-256 hash of every subtree, which
+	// makes RootHash run in constant time instead of hashing the entire
+	// tree. The hashes are kept in a table beside the tree, which costs about
+	// 200 bytes per node while the option is enabled, and the bookkeeping
+	// adds a cost that is proportional to the depth of the key to every write
+	// operation.
+	TrackSubtreeHashes bool
+
+	// TrackBlobReferences maintains the keys of the records that reference
+	// every blob, which makes BlobReferences run in time proportional to the
+	// number of keys instead of walking the tree. The bookkeeping adds a copy
+	// of every key whose value is a blob, and a lookup to every write.
+	TrackBlobReferences bool
+
+	// TrackSizeHistograms maintains the distributions of the key and value
+	// sizes, which makes SizeStats run in constant time instead of walking
+	// the tree. The bookkeeping adds a lookup to every write.
+	TrackSizeHistograms bool
+
+	// ReadSampleRate enables the read counts of the records, which HotKeys
+	// reports. One in every ReadSampleRate reads is counted, chosen at
+	// random, and counts for ReadSampleRate reads, which bounds the cost of
+	// the bookkeeping on the read path. A rate of 1 counts every read. The
+	// counts are kept in memory only. Zero disables the read counts.
+	ReadSampleRate int
+
+	// Manifest writes a sidecar file next to the database file on every
+	// save, at the path of the file followed by ".manifest", which records
+	// the file format version, the file size, the SHA-256 of the file, the
+	// checksums of its header and pages, and the RootHash of the records.
+	// Open verifies the file against the manifest before loading it, and
+	// the records against the RootHash once loaded, and fails with
+	// ErrManifestMismatch if either differs. This detects damage that
+	// happens outside of the database, such as a truncated copy. The
+	// manifest also describes the file that a save replaces, therefore a
+	// crash during the save does not cause a mismatch. Files without a
+	// manifest are loaded as is. Computing the RootHash walks the entire
+	// tree on every save unless TrackSubtreeHashes is set.
+	Manifest bool
+
+	// Encryption encrypts the blob contents when the database is persisted.
+	// Values are kept in plaintext in memory. See EncryptionProvider for the
+	// implications on deduplication. Nil disables encryption.
+	Encryption EncryptionProvider
+
+	// SkipBlobVerification disables the verification of blob contents
+	// against their blobIDs on reads, trading integrity checking for speed.
+	// Iterators and cursors never verify blob contents.
+	SkipBlobVerification bool
+
+	// BlobHash computes the IDs under which blob values are deduplicated.
+	// The setting must be the same every time a database file is opened.
+	// Nil selects SHA-256.
+	BlobHash BlobHash
+
+	// ParanoidDedup compares the bytes of a value with the stored blob that
+	// has the same blobID before sharing the blob, and rejects the write with
+	// ErrHashCollision if they differ. This guards against collisions of
+	// non-cryptographic BlobHash functions, at the cost of a comparison for
+	// every write of a duplicate value.
+	ParanoidDedup bool
+
+	// Compaction configures the background compaction of databases opened
+	// using Open. It has no effect on in-memory databases.
+	Compaction CompactionPolicy
+
+	// Sync configures when the writes to databases opened using Open are
+	// persisted. It has no effect on in-memory databases. The zero value is
+	// SyncNever.
+	Sync SyncPolicy
+
+	// Backpressure throttles the writes to databases opened using Open once
+	// their persistence falls behind. It has no effect on in-memory
+	// databases. The zero value disables backpressure.
+	Backpressure BackpressurePolicy
+
+	// Tiering demotes the blobs that have not been read for a while to a
+	// file on disk, and promotes them back into memory once they are read
+	// again, which keeps the memory usage proportional to the blobs that
+	// are in use. It has no effect on databases opened using OpenReadOnly,
+	// and cannot be used with a Container. The zero value disables tiering.
+	Tiering TieringPolicy
+
+	// ExpirationSweepInterval is how often the records that have expired
+	// are deleted. Zero disables the sweeper, in which case expired records
+	// are hidden from every read method, but remain in the database.
+	ExpirationSweepInterval time.Duration
+
+	// VersionsToKeep is the number of previous values that are kept for
+	// every record, which GetVersion and Versions return. Overwriting a
+	// record keeps the value that it replaced, and drops the oldest value
+	// beyond the limit. Blob values are kept by reference, therefore an
+	// unchanged value costs no additional space. Deleting a record discards
+	// its previous values. Zero disables versioning.
+	VersionsToKeep int
+
+	// TombstoneRetention enables tombstones, which Delete and the other
+	// deletions leave behind for the deleted records, and which Tombstones
+	// reports. A tombstone is kept for at least the retention, and discarded
+	// by the first save or expiration sweep after the retention has elapsed.
+	// Zero disables tombstones.
+	TombstoneRetention time.Duration
+
+	// BloomFilterBitsPerKey enables a Bloom filter over the record keys,
+	// which allows Get to report absent keys without descending the tree.
+	// It suits workloads where many lookups miss. The filter is rebuilt when
+	// the database is loaded, and grows along with the database. Ten bits
+	// per key yield a false positive rate of about one percent. Every bit
+	// takes a byte, since it is a counter that allows keys to be removed.
+	// Zero disables the filter. The maximum is 32.
+	BloomFilterBitsPerKey int
+
+	// MaxRecords is the maximum number of records. Writes that exceed it
+	// evict other records according to the Eviction policy, which turns the
+	// database into a bounded cache. Zero means no limit.
+	MaxRecords int
+
+	// MaxBytes is the maximum total size of the keys and values in bytes,
+	// enforced like MaxRecords. Sizes are logical, therefore deduplicated
+	// blobs count once for every record that references them. Zero means no
+	// limit.
+	MaxBytes int64
+
+	// Eviction selects the records to evict once MaxRecords or MaxBytes is
+	// exceeded. The record that was just written is never evicted. Every
+	// node reserves a pointer to the usage of its record, which is only
+	// used by the eviction, whether or not a capacity limit is configured.
+	Eviction EvictionPolicy
+
+	// KeyTransform rewrites the keys given to every method before they
+	// reach the tree, such as to normalize them. It must be the same every
+	// time a database file is opened. Nil leaves the keys as is.
+	KeyTransform KeyTransform
+
+	// CaseInsensitiveKeys folds the case of ASCII letters when comparing
+	// keys and matching prefixes, which suits hostnames, email addresses,
+	// and header names. The key of a record keeps the spelling that it was
+	// first inserted with, which is what iterators and navigation methods
+	// yield. Records are ordered by their case-folded keys. The setting must
+	// be the same every time a database file is opened.
+	CaseInsensitiveKeys bool
+
+	// LargeKeys accepts keys that exceed the 64KB file format limit. Such
+	// keys are stored in the tree as their first 65503 bytes followed by
+	// their BlobHash, and the full key is kept in the blob store, from which
+	// iterators and navigation methods yield it. Records with large keys
+	// are therefore only ordered by their first 65503 bytes, and are in no
+	// meaningful order among the keys that share them. Likewise, prefixes
+	// longer than 65503 bytes are not meaningful. Keys of exactly 65535
+	// bytes, the length of the stored form, are rejected with
+	// ErrKeyTooLarge. Secondary indexes cannot hold large keys. It cannot
+	// be combined with CaseInsensitiveKeys, and the setting must be the
+	// same every time a database file is opened.
+	LargeKeys bool
+
+	// ReverseOrder orders the records in descending lexicographic key order.
+	// Iterators, cursors, listings, and navigation methods follow the order
+	// of the database, therefore Scan yields the largest key first, Min
+	// returns the largest key, and Next moves towards smaller keys. Custom
+	// comparators are not supported, since the order of the radix tree is
+	// determined by the bytes of the keys. The setting must be the same
+	// every time a database file is opened.
+	ReverseOrder bool
+
+	// Authorizer is consulted before operations, and denies them with
+	// ErrPermission. Nil permits every operation.
+	Authorizer Authorizer
+
+	// Codec encodes the values stored by PutTyped and decodes the values
+	// read by GetTyped. Nil selects JSONCodec.
+	Codec Codec
+
+	// LockTimeout is how long Open waits for another process to release the
+	// database file. Zero fails immediately with ErrDatabaseLocked.
+	LockTimeout time.Duration
+
+	// MaxTxnWrites is the maximum number of keys that a transaction can
+	// write. Zero means no limit.
+	MaxTxnWrites int
+
+	// MaxTxnBytes is the maximum total size of the keys and values buffered
+	// by a transaction in bytes. Zero means no limit.
+	MaxTxnBytes int
+
+	// ReplicationTLS secures the replication connections with TLS. The
+	// primary serves TLS with it in ServeReplication, and replicas connect
+	// with it in NewReplicaWithOptions, therefore the configuration of the
+	// primary needs a certificate, and that of the replicas needs to trust
+	// it. Nil leaves the connections unencrypted.
+	ReplicationTLS *tls.Config
+
+	// ReplicationToken authenticates the replicas. Replicas present it when
+	// they connect, and the primary rejects those whose token differs, in
+	// which case NewReplicaWithOptions returns ErrPermission. It should be
+	// combined with ReplicationTLS, since the token is otherwise sent in the
+	// clear. Empty accepts every replica.
+	ReplicationToken string
+
+	// Logger receives structured events about slow operations, corruption,
+	// blob garbage collection, compaction, and the loading of database
+	// files. Warnings report conditions that need attention, and debug
+	// events trace the background work. Keys are never logged, only their
+	// lengths. Nil disables logging.
+	Logger *slog.Logger
+
+	// SlowOpThreshold is how long an operation can take before it is logged
+	// as slow, including the time spent waiting for locks, backpressure, and
+	// the SyncPolicy. Zero selects 100ms. It has no effect without a Logger.
+	SlowOpThreshold time.Duration
+
+	// MaxTxnDuration is how long a transaction can remain open. Once it
+	// elapses, the transaction is rolled back, which releases the write lock
+	// held by pessimistic transactions. Zero means no limit.
+	MaxTxnDuration time.Duration
+}
+
+// normalize validates the options, and returns a copy with defaults applied
+// to the unset fields. It returns ErrInvalidOptions if a field is negative or
+// exceeds the limits imposed by the file format.
+func (o Options) normalize() (Options, error) {
+	if o.MaxKeyBytes < 0 || o.MaxKeyBytes > o.keyBytesLimit() {
+		return o, ErrInvalidOptions
+	}
+
+	if o.LargeKeys && o.CaseInsensitiveKeys {
+		return o, ErrInvalidOptions
+	}
+
+	if o.MaxValueBytes < 0 || o.MaxValueBytes > maxValueBytes {
+		return o, ErrInvalidOptions
+	}
+
+	if o.Compaction.Interval < 0 || o.Compaction.MinSizeRatio < 0 || o.LockTimeout < 0 || o.ExpirationSweepInterval < 0 || o.TombstoneRetention < 0 || o.SlowOpThreshold < 0 {
+		return o, ErrInvalidOptions
+	}
+
+	if o.Backpressure.MaxUnsavedWrites < 0 || o.Backpressure.Timeout < 0 {
+		return o, ErrInvalidOptions
+	}
+
+	if o.MaxTxnWrites < 0 || o.MaxTxnBytes < 0 || o.MaxTxnDuration < 0 {
+		return o, ErrInvalidOptions
+	}
+
+	if o.VersionsToKeep < 0 || o.VersionsToKeep > maxUint16 {
+		return o, ErrInvalidOptions
+	}
+
+	if o.BloomFilterBitsPerKey < 0 || o.BloomFilterBitsPerKey > maxBloomFilterBitsPerKey {
+		return o, ErrInvalidOptions
+	}
+
+	if !o.Sync.valid() {
+		return o, ErrInvalidOptions
+	}
+
+	if !o.Tiering.valid() {
+		return o, ErrInvalidOptions
+	}
+
+	if o.ReadSampleRate < 0 {
+		return o, ErrInvalidOptions
+	}
+
+	if o.MaxDepth < 0 || o.MaxChildrenPerNode < 0 || o.MaxChildrenPerNode == 1 {
+		return o, ErrInvalidOptions
+	}
+
+	if o.MaxRecords < 0 || o.MaxBytes < 0 || o.Eviction < EvictLRU || o.Eviction > EvictRandom {
+		return o, ErrInvalidOptions
+	}
+
+	if len(o.ReplicationToken) > maxReplicationTokenLen {
+		return o, ErrInvalidOptions
+	}
+
+	if o.KeyTransform != nil && len(o.KeyTransform.Name()) == 0 {
+		return o, ErrInvalidOptions
+	}
+
+	if o.BlobHash != nil && len(o.BlobHash.Name()) == 0 {
+		return o, ErrInvalidOptions
+	}
+
+	if len(o.keyTransformName()) > maxKeyTransformNameLen {
+		return o, ErrInvalidOptions
+	}
+
+	if o.MaxKeyBytes == 0 {
+		o.MaxKeyBytes = o.keyBytesLimit()
+	}
+
+	if o.MaxValueBytes == 0 {
+		o.MaxValueBytes = maxValueBytes
+	}
+
+	if o.SlowOpThreshold == 0 {
+		o.SlowOpThreshold = defaultSlowOpThreshold
+	}
+
+	return o, nil
+}
@@ -0,0 +1,95 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts Options
+		want error
+	}{
+		{name: "with zero options", opts: Options{}, want: nil},
+		{name: "with custom limits", opts: Options{MaxKeyBytes: 8, MaxValueBytes: 16}, want: nil},
+		{name: "with format key limit", opts: Options{MaxKeyBytes: maxKeyBytes}, want: nil},
+		{name: "with negative key limit", opts: Options{MaxKeyBytes: -1}, want: ErrInvalidOptions},
+		{name: "with oversized key limit", opts: Options{MaxKeyBytes: maxKeyBytes + 1}, want: ErrInvalidOptions},
+		{name: "with negative value limit", opts: Options{MaxValueBytes: -1}, want: ErrInvalidOptions},
+		{name: "with oversized value limit", opts: Options{MaxValueBytes: maxValueBytes + 1}, want: ErrInvalidOptions},
+		{name: "with negative transaction limit", opts: Options{MaxTxnBytes: -1}, want: ErrInvalidOptions},
+		{name: "with negative tombstone retention", opts: Options{TombstoneRetention: -1}, want: ErrInvalidOptions},
+		{name: "with negative slow operation threshold", opts: Options{SlowOpThreshold: -1}, want: ErrInvalidOptions},
+		{name: "with oversized version limit", opts: Options{VersionsToKeep: maxUint16 + 1}, want: ErrInvalidOptions},
+		{name: "with oversized bloom filter", opts: Options{BloomFilterBitsPerKey: maxBloomFilterBitsPerKey + 1}, want: ErrInvalidOptions},
+		{name: "with unnamed key transform", opts: Options{KeyTransform: NewKeyTransform("", bytes.ToLower)}, want: ErrInvalidOptions},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewWithOptions(tc.opts); err != tc.want {
+				t.Errorf("unexpected error: got:%v, want:%v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestSizeLimits(t *testing.T) {
+	arc, err := NewWithOptions(Options{MaxKeyBytes: 4, MaxValueBytes: 8})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name    string
+		key     []byte
+		value   []byte
+		want    error
+		size    int
+		limit   int
+		putOnly bool
+	}{
+		{name: "within limits", key: []byte("abcd"), value: []byte("12345678"), want: nil},
+		{name: "key too large", key: []byte("abcde"), value: nil, want: ErrKeyTooLarge, size: 5, limit: 4},
+		{name: "value too large", key: []byte("abc"), value: []byte("123456789"), want: ErrValueTooLarge, size: 9, limit: 8, putOnly: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := map[string]error{
+				"Put":       arc.Put(tc.key, tc.value),
+				"PutReader": arc.PutReader(tc.key, bytes.NewReader(tc.value)),
+			}
+
+			if !tc.putOnly {
+				_, errs["Get"] = arc.Get(tc.key)
+			}
+
+			for op, err := range errs {
+				if !errors.Is(err, tc.want) {
+					t.Fatalf("unexpected %s error: got:%v, want:%v", op, err, tc.want)
+				}
+
+				if tc.want == nil {
+					continue
+				}
+
+				var sizeErr *SizeError
+
+				if !errors.As(err, &sizeErr) {
+					t.Fatalf("expected %s to return a *SizeError, got:%T", op, err)
+				}
+
+				if sizeErr.Size != tc.size || sizeErr.Limit != tc.limit {
+					t.Errorf("unexpected %s size error: got:%d/%d, want:%d/%d", op, sizeErr.Size, sizeErr.Limit, tc.size, tc.limit)
+				}
+			}
+		})
+	}
+}
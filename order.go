@@ -0,0 +1,38 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import "bytes"
+
+// reverseOrderName is recorded in the file header, along with the name of the
+// KeyTransform, when ReverseOrder is set.
+const reverseOrderName = "reverse"
+
+// compareKeys compares two record keys in the order of the database, which
+// accounts for CaseInsensitiveKeys and ReverseOrder. The options are
+// immutable, therefore the lock does not need to be held.
+func (a *Arc) compareKeys(x []byte, y []byte) int {
+	ret := bytes.Compare(a.foldKey(x), a.foldKey(y))
+
+	if a.opts.ReverseOrder {
+		return -ret
+	}
+
+	return ret
+}
+
+// orderedDirection maps the direction requested by the caller, which is
+// relative to the order of the database, to the direction of the traversal of
+// the tree, which is always sorted in ascending order.
+func (a *Arc) orderedDirection(d Direction) Direction {
+	if !a.opts.ReverseOrder {
+		return d
+	}
+
+	if d == Reverse {
+		return Forward
+	}
+
+	return Reverse
+}
@@ -0,0 +1,115 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestReverseOrder(t *testing.T) {
+	arc, _ := NewWithOptions(Options{ReverseOrder: true})
+
+	for _, key := range []string{"a", "ab", "b", "c/x", "c/y"} {
+		arc.Put([]byte(key), []byte(key))
+	}
+
+	var keys []string
+
+	for key := range arc.Scan(nil) {
+		keys = append(keys, string(key))
+	}
+
+	assertKeys(t, keys, []string{"c/y", "c/x", "b", "ab", "a"})
+
+	keys = nil
+
+	for key := range arc.ScanReverse([]byte("a")) {
+		keys = append(keys, string(key))
+	}
+
+	assertKeys(t, keys, []string{"a", "ab"})
+
+	if key, _, _ := arc.Min(); string(key) != "c/y" {
+		t.Errorf("unexpected min: got:%q, want:%q", key, "c/y")
+	}
+
+	if key, _, _ := arc.Max(); string(key) != "a" {
+		t.Errorf("unexpected max: got:%q, want:%q", key, "a")
+	}
+
+	if key, _, _ := arc.Next([]byte("b")); string(key) != "ab" {
+		t.Errorf("unexpected next: got:%q, want:%q", key, "ab")
+	}
+
+	if key, _, _ := arc.Ceiling([]byte("bb")); string(key) != "b" {
+		t.Errorf("unexpected ceiling: got:%q, want:%q", key, "b")
+	}
+
+	c := arc.Cursor(nil)
+
+	if !c.Seek([]byte("aa")) || string(c.Key()) != "a" {
+		t.Errorf("unexpected cursor key: %q", c.Key())
+	}
+
+	if !c.First() || string(c.Key()) != "c/y" {
+		t.Errorf("unexpected first cursor key: %q", c.Key())
+	}
+
+	entries, _ := arc.Children(nil, '/')
+	var names []string
+
+	for _, e := range entries {
+		names = append(names, string(e.Name))
+	}
+
+	if want := []string{"c", "b", "ab", "a"}; !slices.Equal(names, want) {
+		t.Errorf("unexpected children: got:%q, want:%q", names, want)
+	}
+}
+
+func TestReverseOrderPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := OpenWithOptions(path, Options{ReverseOrder: true})
+
+	arc.Put([]byte("a"), []byte("a"))
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := Open(path); err != ErrKeyTransformMismatch {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyTransformMismatch)
+	}
+
+	reopened, err := OpenWithOptions(path, Options{ReverseOrder: true})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened.Close()
+}
+
+func TestCompareKeys(t *testing.T) {
+	testCases := []struct {
+		opts Options
+		x, y string
+		want int
+	}{
+		{opts: Options{}, x: "a", y: "b", want: -1},
+		{opts: Options{ReverseOrder: true}, x: "a", y: "b", want: 1},
+		{opts: Options{CaseInsensitiveKeys: true}, x: "A", y: "a", want: 0},
+		{opts: Options{CaseInsensitiveKeys: true, ReverseOrder: true}, x: "B", y: "a", want: -1},
+	}
+
+	for _, tc := range testCases {
+		arc, _ := NewWithOptions(tc.opts)
+
+		if got := arc.compareKeys([]byte(tc.x), []byte(tc.y)); got != tc.want {
+			t.Errorf("unexpected comparison of %q and %q: got:%d, want:%d", tc.x, tc.y, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrTornWrite is returned when a page of the database file fails its
+// checksum, or belongs to another write than the rest of the file, which
+// indicates that the file was only partially written.
+var ErrTornWrite = errors.New("torn write detected")
+
+const (
+	// pageLen is the length of the pages that the body of the database file
+	// is split into, which matches the common size of a disk sector.
+	pageLen = 4096
+
+	// pageHeaderLen is the length of the page header, which consists of the
+	// write epoch, the index of the page, the number of pages, and the length
+	// of its payload.
+	pageHeaderLen = sizeOfUint64 + sizeOfUint32 + sizeOfUint32 + sizeOfUint16
+
+	// pagePayloadLen is the number of body bytes that a page holds. Pages end
+	// with the checksum of the preceding bytes.
+	pagePayloadLen = pageLen - pageHeaderLen - checksumLen
+)
+
+// paginate splits the body of the database file into pages, which are tagged
+// with the write epoch. Every page but the last is full, and the last page is
+// padded with zeros.
+func paginate(body []byte, epoch uint64) ([]byte, error) {
+	ret := make([]byte, 0, paginatedLen(int64(len(body))))
+	numPages := cap(ret) / pageLen
+
+	for i := range numPages {
+		payload := body[i*pagePayloadLen : min((i+1)*pagePayloadLen, len(body))]
+		page := ret[len(ret) : len(ret)+pageLen]
+		clear(page)
+
+		binary.LittleEndian.PutUint64(page, epoch)
+		binary.LittleEndian.PutUint32(page[sizeOfUint64:], uint32(i))
+		binary.LittleEndian.PutUint32(page[sizeOfUint64+sizeOfUint32:], uint32(numPages))
+		binary.LittleEndian.PutUint16(page[sizeOfUint64+2*sizeOfUint32:], uint16(len(payload)))
+		copy(page[pageHeaderLen:], payload)
+
+		checksum, err := computeChecksum(page[:pageLen-checksumLen])
+
+		if err != nil {
+			return nil, err
+		}
+
+		binary.LittleEndian.PutUint32(page[pageLen-checksumLen:], checksum)
+		ret = ret[:len(ret)+pageLen]
+	}
+
+	return ret, nil
+}
+
+// paginatedLen returns the length of the pages that hold a body of the given
+// length.
+func paginatedLen(bodyLen int64) int64 {
+	return max((bodyLen+pagePayloadLen-1)/pagePayloadLen, 1) * pageLen
+}
+
+// unpaginate verifies the pages produced by paginate, and appends the body
+// that they hold to dst. It also returns the write epoch of the pages, which
+// must all agree. Returns ErrTornWrite if a page is damaged, missing, or
+// belongs to another write.
+func unpaginate(dst []byte, src []byte) ([]byte, uint64, error) {
+	if len(src) == 0 || len(src)%pageLen != 0 {
+		return nil, 0, ErrTornWrite
+	}
+
+	numPages := len(src) / pageLen
+	epoch := binary.LittleEndian.Uint64(src)
+
+	for i := range numPages {
+		page := src[i*pageLen : (i+1)*pageLen]
+		checksum, err := computeChecksum(page[:pageLen-checksumLen])
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if checksum != binary.LittleEndian.Uint32(page[pageLen-checksumLen:]) {
+			return nil, 0, ErrTornWrite
+		}
+
+		payloadLen := int(binary.LittleEndian.Uint16(page[sizeOfUint64+2*sizeOfUint32:]))
+
+		if binary.LittleEndian.Uint64(page) != epoch ||
+			binary.LittleEndian.Uint32(page[sizeOfUint64:]) != uint32(i) ||
+			binary.LittleEndian.Uint32(page[sizeOfUint64+sizeOfUint32:]) != uint32(numPages) ||
+			payloadLen > pagePayloadLen ||
+			(i < numPages-1 && payloadLen != pagePayloadLen) {
+			return nil, 0, ErrTornWrite
+		}
+
+		dst = append(dst, page[pageHeaderLen:pageHeaderLen+payloadLen]...)
+	}
+
+	return dst, epoch, nil
+}
@@ -0,0 +1,116 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	for _, bodyLen := range []int{0, 1, pagePayloadLen, pagePayloadLen + 1, 3 * pagePayloadLen} {
+		body := make([]byte, bodyLen)
+
+		for i := range body {
+			body[i] = byte(i)
+		}
+
+		pages, err := paginate(body, 7)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if int64(len(pages)) != paginatedLen(int64(bodyLen)) {
+			t.Errorf("unexpected length: got:%d, want:%d", len(pages), paginatedLen(int64(bodyLen)))
+		}
+
+		got, epoch, err := unpaginate([]byte("header"), pages)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !bytes.Equal(got, append([]byte("header"), body...)) {
+			t.Errorf("unexpected body of %d bytes", bodyLen)
+		}
+
+		if epoch != 7 {
+			t.Errorf("unexpected epoch: got:%d, want:%d", epoch, 7)
+		}
+	}
+}
+
+func TestUnpaginateTornWrite(t *testing.T) {
+	body := bytes.Repeat([]byte("b"), 2*pagePayloadLen+10)
+	pages, _ := paginate(body, 2)
+	stale, _ := paginate(body, 1)
+
+	flipped := bytes.Clone(pages)
+	flipped[pageLen+100] ^= 0x01
+
+	// The second page was not rewritten by the latest write.
+	mixed := bytes.Clone(pages)
+	copy(mixed[pageLen:2*pageLen], stale[pageLen:2*pageLen])
+
+	swapped := bytes.Clone(pages)
+	copy(swapped[:pageLen], pages[pageLen:2*pageLen])
+	copy(swapped[pageLen:2*pageLen], pages[:pageLen])
+
+	tests := []struct {
+		name string
+		src  []byte
+	}{
+		{"empty", nil},
+		{"truncated", pages[:len(pages)-1]},
+		{"checksum", flipped},
+		{"epoch", mixed},
+		{"order", swapped},
+		{"missing tail", pages[:pageLen]},
+	}
+
+	for _, test := range tests {
+		if _, _, err := unpaginate(nil, test.src); err != ErrTornWrite {
+			t.Errorf("%s: unexpected error: got:%v, want:%v", test.name, err, ErrTornWrite)
+		}
+	}
+}
+
+func TestSaveEpoch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := Open(path)
+
+	for i := range 3 {
+		arc.Put([]byte("key"), []byte{byte(i)})
+
+		if err := arc.Save(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	arc.Close()
+
+	src, _ := os.ReadFile(path)
+
+	header := newArcHeader()
+
+	if got := binary.LittleEndian.Uint64(src[header.len():]); got != 3 {
+		t.Errorf("unexpected epoch: got:%d, want:%d", got, 3)
+	}
+
+	reopened, err := Open(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer reopened.Close()
+
+	if reopened.epoch != 3 {
+		t.Errorf("unexpected epoch: got:%d, want:%d", reopened.epoch, 3)
+	}
+}
@@ -0,0 +1,1059 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrNotFileBacked is returned when a file operation is attempted on a
+	// database that was not opened from a file.
+	ErrNotFileBacked = errors.New("database is not backed by a file")
+
+	// ErrUnsupportedVersion is returned when the database file was written
+	// using a file format version that this package cannot read.
+	ErrUnsupportedVersion = errors.New("unsupported file format version")
+)
+
+// blobRecordOverhead is the number of bytes that a serialized blob occupies in
+// addition to its content: the blobID, the content length, and the checksum.
+const blobRecordOverhead = blobIDLen + sizeOfUint32 + checksumLen
+
+// Open opens the database file at the given path with the default options. An
+// empty database is returned if the file does not exist, in which case the
+// file is created on the first Save.
+func Open(path string) (*Arc, error) {
+	return OpenWithOptions(path, Options{})
+}
+
+// OpenWithOptions is like Open, but configures the database with the given
+// options. The options must match those that the file was written with, such
+// as the EncryptionProvider. Returns ErrDatabaseLocked if the file remains
+// open in another process for longer than the LockTimeout.
+func OpenWithOptions(path string, opts Options) (*Arc, error) {
+	ret, err := open(path, opts, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret.startCompaction()
+	ret.startSyncer()
+	ret.startSweeper()
+	ret.startTiering()
+
+	return ret, nil
+}
+
+// OpenReadOnly opens the existing database file at the given path in
+// read-only mode with the default options. Writes fail with ErrReadOnly, and
+// reads skip the lock acquisition since the database cannot change. Any number
+// of processes may open the same file in read-only mode, but not while it is
+// open in read-write mode, in which case ErrDatabaseLocked is returned.
+func OpenReadOnly(path string) (*Arc, error) {
+	return OpenReadOnlyWithOptions(path, Options{})
+}
+
+// OpenReadOnlyWithOptions is like OpenReadOnly, but configures the database
+// with the given options. The CompactionPolicy and ExpirationSweepInterval are
+// ignored.
+func OpenReadOnlyWithOptions(path string, opts Options) (*Arc, error) {
+	return open(path, opts, true)
+}
+
+// open loads the database file at the given path while holding the file lock
+// that corresponds to the mode. A missing file is only tolerated in read-write
+// mode.
+func open(path string, opts Options, readOnly bool) (*Arc, error) {
+	ret, err := newArc(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ret.lockFile, err = acquireFileLock(path, !readOnly, opts.LockTimeout); err != nil {
+		return nil, err
+	}
+
+	src, err := os.ReadFile(path)
+
+	if err != nil && (readOnly || !errors.Is(err, fs.ErrNotExist)) {
+		ret.releaseFileLock()
+		return nil, err
+	}
+
+	if err == nil {
+		entry, err := ret.checkManifest(path, src)
+
+		if err == nil {
+			err = ret.loadFile(path, src)
+		}
+
+		if err == nil {
+			err = ret.checkRootHash(path, entry)
+		}
+
+		if err != nil {
+			ret.releaseFileLock()
+			return nil, err
+		}
+
+		ret.manifest = entry
+	} else {
+		ret.log(slog.LevelDebug, "database file does not exist, starting empty", "path", path)
+	}
+
+	ret.path = path
+	ret.readOnly = readOnly
+	ret.immutable = readOnly
+
+	return ret, nil
+}
+
+// loadFile loads the contents of the database file at the given path, and logs
+// the outcome. Damaged files, such as those with torn writes, are reported as
+// warnings along with the error.
+func (a *Arc) loadFile(path string, src []byte) error {
+	start := time.Now()
+
+	var err error
+
+	profile("load", func() {
+		err = a.readSnapshot(src)
+	})
+
+	if err != nil {
+		a.log(slog.LevelWarn, "failed to load database file", "path", path, "size", len(src), "err", err)
+		return err
+	}
+
+	a.log(slog.LevelDebug, "loaded database file", "path", path, "size", len(src), "records", a.numRecords, "blobs", len(a.blobs), "duration", time.Since(start))
+
+	return nil
+}
+
+// Save writes the entire database to its file. The file is replaced
+// atomically, therefore a crash during Save leaves the previous version of
+// the file intact. Returns ErrNotFileBacked if the database was not opened
+// using Open, and ErrReadOnly if it was opened using OpenReadOnly.
+func (a *Arc) Save() error {
+	if a.path == "" {
+		return ErrNotFileBacked
+	}
+
+	if a.readOnly {
+		return ErrReadOnly
+	}
+
+	a.saveMu.Lock()
+	defer a.saveMu.Unlock()
+
+	return a.save()
+}
+
+// Close applies the writes queued by PutAsync, stops the background work, and
+// disconnects the replicas or the primary. File-backed databases are then
+// saved if they have unsaved writes, and the file lock is released. For
+// replicas, Close also returns the error that had stopped the replication, if
+// any.
+func (a *Arc) Close() error {
+	a.stopAsync()
+	a.stopSweeper()
+
+	err := a.stopReplication()
+
+	// The tier file is released last, since the final save reads the
+	// demoted blobs from it.
+	if a.path == "" {
+		return errors.Join(err, a.stopTiering())
+	}
+
+	a.stopCompaction()
+	a.stopSyncer()
+
+	a.saveMu.Lock()
+	defer a.saveMu.Unlock()
+
+	a.rlock()
+	dirty := a.dirty()
+	a.runlock()
+
+	if dirty {
+		err = errors.Join(err, a.save())
+	}
+
+	return errors.Join(err, a.stopTiering(), a.releaseFileLock())
+}
+
+// save serializes the database and atomically replaces its file. The caller
+// must hold saveMu, which serializes concurrent saves.
+func (a *Arc) save() error {
+	var buf bytes.Buffer
+
+	// Serialize under the read lock, but write the file without holding it
+	// so that the I/O does not block the readers and writers.
+	a.mu.RLock()
+	seq := a.seq
+	metaSeq := a.metaSeq
+	now := a.now()
+	rootHash := a.manifestRootHash()
+	err := a.writeSnapshot(&buf)
+	a.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	manifest, err := a.writeManifest(buf.Bytes(), rootHash)
+
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(a.path, buf.Bytes()); err != nil {
+		return err
+	}
+
+	if manifest != nil {
+		a.manifest = manifest
+	}
+
+	// The tombstones that had elapsed before the snapshot was taken were
+	// left out of the file.
+	a.lock()
+	a.savedSeq = seq
+	a.savedMetaSeq = metaSeq
+	a.epoch++
+	a.purgeTombstones(now)
+	resumed := a.noteSaved()
+	a.mu.Unlock()
+
+	if resumed && a.opts.Backpressure.OnThrottle != nil {
+		a.opts.Backpressure.OnThrottle(false)
+	}
+
+	return nil
+}
+
+// dirty returns true if the database has unsaved changes. The caller must hold
+// the read lock.
+func (a *Arc) dirty() bool {
+	return a.seq != a.savedSeq || a.metaSeq != a.savedMetaSeq
+}
+
+// writeFileAtomic writes the data to a temporary file in the same directory,
+// syncs it, and then renames it over the destination path.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+
+	if err != nil {
+		return err
+	}
+
+	tmpPath := f.Name()
+
+	if err := crashAt(failpointWrite); err != nil {
+		f.Write(data[:len(data)/2])
+		f.Close()
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := crashAt(failpointSync); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := crashAt(failpointRename); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := crashAt(failpointSyncDir); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// syncDir syncs the directory to persist a rename. Platforms that do not
+// support syncing directories are tolerated.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+
+	if err != nil {
+		return err
+	}
+
+	defer d.Close()
+
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
+
+	return nil
+}
+
+// writeSnapshot serializes the database in the file format, which consists of
+// the header followed by the body, which is split into pages that are tagged
+// with the next write epoch. The body consists of the blob section, the node
+// section, the expiration section, the offset of the expiration section, the
+// original key section, the offset of the original key section, the dictionary
+// section, the offset of the dictionary section, the tombstone section, the
+// offset of the tombstone section, the version section, the offset of the
+// version section, the timestamp section, and the offset of the timestamp
+// section. The blob section holds the number of blobs and the blob
+// records in blobID order. The node section holds the nodes
+// in pre-order, starting with the root node. Nodes reference their first child
+// and next sibling by absolute offset, and zero denotes the absence of a
+// reference. The caller must hold the read lock.
+func (a *Arc) writeSnapshot(w io.Writer) error {
+	header := a.fileHeader()
+	headerBytes, err := header.serialize()
+
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+
+	if err := a.writeBody(&body, uint64(len(headerBytes)), true); err != nil {
+		return err
+	}
+
+	pages, err := paginate(body.Bytes(), a.epoch+1)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(headerBytes); err != nil {
+		return err
+	}
+
+	_, err = w.Write(pages)
+
+	return err
+}
+
+// fileHeader returns the header of the database file. The caller must hold the
+// read lock.
+func (a *Arc) fileHeader() arcHeader {
+	ret := newArcHeader()
+	ret.keyTransform = a.opts.keyTransformName()
+	ret.meta = a.meta
+
+	return ret
+}
+
+// writeBody serializes the sections that follow the header, whose offsets
+// start at the given offset. The blob section is left empty unless withBlobs
+// is true, which allows a Container to write the blobs that its namespaces
+// share once. The caller must hold the read lock.
+func (a *Arc) writeBody(bw *bytes.Buffer, offset uint64, withBlobs bool) error {
+	var blobs blobStore
+
+	if withBlobs {
+		blobs = a.blobs
+	}
+
+	blobsLen, err := a.writeBlobs(bw, blobs)
+
+	if err != nil {
+		return err
+	}
+
+	offset += uint64(blobsLen)
+
+	if a.root != nil {
+		if offset, err = writeNodes(bw, a.root, offset); err != nil {
+			return err
+		}
+	}
+
+	expirations, err := a.serializeExpirations()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := bw.Write(expirations); err != nil {
+		return err
+	}
+
+	if err := binary.Write(bw, binary.LittleEndian, offset); err != nil {
+		return err
+	}
+
+	offset += uint64(len(expirations)) + sizeOfUint64
+
+	originalKeys, err := a.serializeOriginalKeys()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := bw.Write(originalKeys); err != nil {
+		return err
+	}
+
+	if err := binary.Write(bw, binary.LittleEndian, offset); err != nil {
+		return err
+	}
+
+	offset += uint64(len(originalKeys)) + sizeOfUint64
+
+	dictionary, err := a.serializeDictionary()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := bw.Write(dictionary); err != nil {
+		return err
+	}
+
+	if err := binary.Write(bw, binary.LittleEndian, offset); err != nil {
+		return err
+	}
+
+	offset += uint64(len(dictionary)) + sizeOfUint64
+
+	tombstones, err := a.serializeTombstones()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := bw.Write(tombstones); err != nil {
+		return err
+	}
+
+	if err := binary.Write(bw, binary.LittleEndian, offset); err != nil {
+		return err
+	}
+
+	offset += uint64(len(tombstones)) + sizeOfUint64
+
+	versions, err := a.serializeVersions()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := bw.Write(versions); err != nil {
+		return err
+	}
+
+	if err := binary.Write(bw, binary.LittleEndian, offset); err != nil {
+		return err
+	}
+
+	offset += uint64(len(versions)) + sizeOfUint64
+
+	timestamps, err := a.serializeTimestamps()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := bw.Write(timestamps); err != nil {
+		return err
+	}
+
+	return binary.Write(bw, binary.LittleEndian, offset)
+}
+
+// writeBlobs serializes the blob section, which consists of the number of
+// blobs and the blob records in blobID order. It returns the length of the
+// section.
+func (a *Arc) writeBlobs(bw io.Writer, blobs blobStore) (int, error) {
+	ids := make([]blobID, 0, len(blobs))
+
+	for id := range blobs {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return bytes.Compare(ids[i][:], ids[j][:]) < 0
+	})
+
+	if err := binary.Write(bw, binary.LittleEndian, uint64(len(ids))); err != nil {
+		return 0, err
+	}
+
+	ret := sizeOfUint64
+
+	for _, id := range ids {
+		value, err := blobs[id].load()
+
+		if err != nil {
+			return 0, err
+		}
+
+		sealed, err := a.sealBlob(id, value)
+
+		if err != nil {
+			return 0, err
+		}
+
+		record, err := serializeBlobRecord(id, sealed)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err := bw.Write(record); err != nil {
+			return 0, err
+		}
+
+		ret += len(record)
+	}
+
+	return ret, nil
+}
+
+// writeNodes writes the tree rooted at root in a single depth-first pass, and
+// returns the offset that follows the last node. The nodes are laid out in
+// pre-order starting at the given offset, therefore the first child of a node
+// directly follows it. The offset of the next sibling is only known once the
+// subtree of the node is written, so it is back-patched into the buffer along
+// with the checksum of the node. The pass keeps no state beyond the recursion,
+// which is bounded by the depth of the tree.
+func writeNodes(buf *bytes.Buffer, root *node, offset uint64) (uint64, error) {
+	var visit func(n *node) error
+
+	visit = func(n *node) error {
+		pos := buf.Len()
+		pn := makePersistentNode(*n)
+
+		if n.firstChild != nil {
+			pn.firstChildOffset = offset + uint64(serializedNodeLen(n))
+		}
+
+		nodeBytes, err := pn.serialize()
+
+		if err != nil {
+			return err
+		}
+
+		buf.Write(nodeBytes)
+		offset += uint64(len(nodeBytes))
+
+		for child := n.firstChild; child != nil; child = child.nextSibling {
+			if err := visit(child); err != nil {
+				return err
+			}
+		}
+
+		if n.nextSibling != nil {
+			return patchNextSiblingOffset(buf.Bytes()[pos:pos+len(nodeBytes)], offset)
+		}
+
+		return nil
+	}
+
+	if err := visit(root); err != nil {
+		return 0, err
+	}
+
+	return offset, nil
+}
+
+// patchNextSiblingOffset replaces the next sibling offset of the serialized
+// node, and updates its checksum to match.
+func patchNextSiblingOffset(nodeBytes []byte, offset uint64) error {
+	// The next sibling offset is the last field of the fixed-size header.
+	binary.LittleEndian.PutUint64(nodeBytes[minNodeBytesLen-sizeOfUint64:], offset)
+
+	body := nodeBytes[:len(nodeBytes)-checksumLen]
+	checksum, err := computeChecksum(body)
+
+	if err != nil {
+		return err
+	}
+
+	binary.LittleEndian.PutUint32(nodeBytes[len(body):], checksum)
+
+	return nil
+}
+
+// serializedNodeLen returns the length of the serialized node in bytes.
+func serializedNodeLen(n *node) int {
+	ret := minNodeBytesLen + len(n.key) + len(n.data) + checksumLen
+
+	if n.userFlags != 0 {
+		ret += sizeOfUint8
+	}
+
+	return ret
+}
+
+// serializeBlobRecord serializes a blob record, which consists of the blobID,
+// the content length, the content, and the checksum of the preceding bytes.
+func serializeBlobRecord(id blobID, content []byte) ([]byte, error) {
+	if len(content) > maxValueBytes {
+		return nil, ErrValueTooLarge
+	}
+
+	ret := make([]byte, 0, blobRecordOverhead+len(content))
+	ret = append(ret, id[:]...)
+	ret = binary.LittleEndian.AppendUint32(ret, uint32(len(content)))
+	ret = append(ret, content...)
+
+	checksum, err := computeChecksum(ret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return binary.LittleEndian.AppendUint32(ret, checksum), nil
+}
+
+// readBlobRecord parses the blob record at the beginning of src. It returns
+// the blobID, the content, and the length of the record.
+func readBlobRecord(src []byte) (blobID, []byte, int, error) {
+	var id blobID
+
+	if len(src) < blobRecordOverhead {
+		return id, nil, 0, ErrCorrupted
+	}
+
+	copy(id[:], src)
+
+	contentLen := int(binary.LittleEndian.Uint32(src[blobIDLen:]))
+	recordLen := blobRecordOverhead + contentLen
+
+	if len(src) < recordLen {
+		return id, nil, 0, ErrCorrupted
+	}
+
+	checksumPos := recordLen - checksumLen
+	checksum, err := computeChecksum(src[:checksumPos])
+
+	if err != nil {
+		return id, nil, 0, err
+	}
+
+	if checksum != binary.LittleEndian.Uint32(src[checksumPos:]) {
+		return id, nil, 0, ErrInvalidChecksum
+	}
+
+	return id, src[blobIDLen+sizeOfUint32 : checksumPos], recordLen, nil
+}
+
+// readSnapshot loads the serialized database produced by writeSnapshot into
+// the receiver, which must be empty.
+func (a *Arc) readSnapshot(src []byte) error {
+	header, err := newArcHeaderFromBytes(src)
+
+	if err != nil {
+		return err
+	}
+
+	if header.magic != magicByte {
+		return ErrCorrupted
+	}
+
+	if header.version != fileFormatVersion {
+		return ErrUnsupportedVersion
+	}
+
+	if err := a.opts.checkTransformName(header.keyTransform); err != nil {
+		return err
+	}
+
+	a.meta = header.meta
+	pos := header.len()
+
+	// The offsets within the body are relative to the start of the file,
+	// therefore the body is reassembled after a copy of the header.
+	logical := make([]byte, pos, len(src))
+	copy(logical, src)
+
+	if src, a.epoch, err = unpaginate(logical, src[pos:]); err != nil {
+		return err
+	}
+
+	return a.readBody(src, pos, map[blobID][]byte{})
+}
+
+// readBody loads the sections produced by writeBody, which start at offset pos
+// of src. The blob contents are added to contents, where the nodes then look
+// them up, which allows the namespaces of a Container to resolve the blobs
+// that the container holds.
+func (a *Arc) readBody(src []byte, pos int, contents map[blobID][]byte) error {
+	if len(src) < pos+sizeOfUint64+sizeOfUint64 {
+		return ErrCorrupted
+	}
+
+	// The file ends with the offset of the timestamp section, which is loaded
+	// once the records are.
+	timestampsOffset := binary.LittleEndian.Uint64(src[len(src)-sizeOfUint64:])
+
+	if timestampsOffset < uint64(pos+6*sizeOfUint64) || timestampsOffset > uint64(len(src)-sizeOfUint64) {
+		return ErrCorrupted
+	}
+
+	timestamps := src[timestampsOffset : len(src)-sizeOfUint64]
+	src = src[:timestampsOffset]
+
+	// The timestamp section is preceded by the offset of the version section.
+	versionsOffset := binary.LittleEndian.Uint64(src[len(src)-sizeOfUint64:])
+
+	if versionsOffset < uint64(pos+5*sizeOfUint64) || versionsOffset > uint64(len(src)-sizeOfUint64) {
+		return ErrCorrupted
+	}
+
+	versions := src[versionsOffset : len(src)-sizeOfUint64]
+	src = src[:versionsOffset]
+
+	// The version section is preceded by the offset of the tombstone section.
+	tombstonesOffset := binary.LittleEndian.Uint64(src[len(src)-sizeOfUint64:])
+
+	if tombstonesOffset < uint64(pos+4*sizeOfUint64) || tombstonesOffset > uint64(len(src)-sizeOfUint64) {
+		return ErrCorrupted
+	}
+
+	tombstones := src[tombstonesOffset : len(src)-sizeOfUint64]
+	src = src[:tombstonesOffset]
+
+	// The tombstone section is preceded by the offset of the dictionary
+	// section, which must be loaded before the blobs that it compresses.
+	dictionaryOffset := binary.LittleEndian.Uint64(src[len(src)-sizeOfUint64:])
+
+	if dictionaryOffset < uint64(pos+3*sizeOfUint64) || dictionaryOffset > uint64(len(src)-sizeOfUint64) {
+		return ErrCorrupted
+	}
+
+	if err := a.readDictionary(src[dictionaryOffset : len(src)-sizeOfUint64]); err != nil {
+		return err
+	}
+
+	src = src[:dictionaryOffset]
+
+	// The dictionary section is preceded by the offset of the original key
+	// section, which is preceded by the offset of the expiration section.
+	originalKeysOffset := binary.LittleEndian.Uint64(src[len(src)-sizeOfUint64:])
+
+	if originalKeysOffset < uint64(pos+sizeOfUint64+sizeOfUint64) || originalKeysOffset > uint64(len(src)-sizeOfUint64) {
+		return ErrCorrupted
+	}
+
+	originalKeys := src[originalKeysOffset : len(src)-sizeOfUint64]
+	src = src[:originalKeysOffset]
+
+	// The expiration section follows the node section.
+	expirationsOffset := binary.LittleEndian.Uint64(src[len(src)-sizeOfUint64:])
+
+	if expirationsOffset < uint64(pos+sizeOfUint64) || expirationsOffset > uint64(len(src)-sizeOfUint64) {
+		return ErrCorrupted
+	}
+
+	expirations := src[expirationsOffset : len(src)-sizeOfUint64]
+	src = src[:expirationsOffset]
+
+	blobsLen, err := a.readBlobs(src[pos:], contents)
+
+	if err != nil {
+		return err
+	}
+
+	pos += blobsLen
+
+	if pos < len(src) {
+		d := snapshotDecoder{arc: a, src: src, contents: contents, blobs: a.blobs}
+
+		root, err := d.decodeTree(uint64(pos))
+
+		if err != nil {
+			a.clear()
+			return err
+		}
+
+		a.root = root
+		a.numNodes += d.numNodes
+		a.numRecords += d.numRecords
+	}
+
+	if err := a.attachLargeKeys(contents); err != nil {
+		a.clear()
+		return err
+	}
+
+	if err := a.readExpirations(expirations); err != nil {
+		a.clear()
+		return err
+	}
+
+	if err := a.readOriginalKeys(originalKeys); err != nil {
+		a.clear()
+		return err
+	}
+
+	if err := a.readTombstones(tombstones); err != nil {
+		a.clear()
+		a.tombstones = nil
+		return err
+	}
+
+	if err := a.readVersions(versions, contents); err != nil {
+		a.clear()
+		a.tombstones = nil
+		a.versions = nil
+		return err
+	}
+
+	if err := a.readTimestamps(timestamps); err != nil {
+		a.clear()
+		a.tombstones = nil
+		a.versions = nil
+		return err
+	}
+
+	if a.opts.TrackSubtreeHashes && a.root != nil {
+		a.hashNode(a.root, true)
+	}
+
+	a.rebuildFilter(0)
+	a.rebuildBlobRefs()
+	a.rebuildSizeHistograms()
+
+	// The loaded records are considered used in key order. Databases that
+	// exceed their capacity are trimmed by the next write.
+	if a.usage != nil {
+		a.walkPrefix(nil, func(key []byte, n *node) bool {
+			a.usage.record(n, key, n.valueSize(a.blobs))
+			return true
+		})
+	}
+
+	return nil
+}
+
+// readBlobs reads the blob section at the beginning of src, and adds the blob
+// contents to contents. Blobs are held aside until the nodes are loaded, which
+// allows the reference counts to be rebuilt from the actual references. It
+// returns the length of the blob section.
+func (a *Arc) readBlobs(src []byte, contents map[blobID][]byte) (int, error) {
+	if len(src) < sizeOfUint64 {
+		return 0, ErrCorrupted
+	}
+
+	numBlobs := binary.LittleEndian.Uint64(src)
+	pos := sizeOfUint64
+
+	for i := uint64(0); i < numBlobs; i++ {
+		id, sealed, recordLen, err := readBlobRecord(src[pos:])
+
+		if err != nil {
+			return 0, err
+		}
+
+		content, err := a.openBlob(id, sealed)
+
+		if err != nil {
+			return 0, err
+		}
+
+		// Also detects a file that was opened without its EncryptionProvider.
+		if !a.opts.SkipBlobVerification && a.makeBlobID(content) != id {
+			return 0, ErrCorrupted
+		}
+
+		contents[id] = content
+
+		pos += recordLen
+	}
+
+	return pos, nil
+}
+
+// snapshotDecoder rebuilds the tree from the node section of a serialized
+// database.
+type snapshotDecoder struct {
+	arc      *Arc
+	src      []byte
+	contents map[blobID][]byte
+
+	// Blob store that the decoded nodes reference their blobs in, and the
+	// number of decoded nodes and records. Decoders that run concurrently
+	// keep their own, which are merged once they are done.
+	blobs      blobStore
+	numNodes   int
+	numRecords int
+}
+
+// decodeNode decodes the node at the given offset along with its subtree. It
+// returns the node and the offset of its next sibling. Nodes are laid out in
+// pre-order, therefore child and sibling offsets must be greater than the
+// offset of the node. The check prevents corrupted offsets from forming
+// cycles.
+func (d *snapshotDecoder) decodeNode(offset uint64, minOffset uint64) (*node, uint64, error) {
+	pn, nodeLen, err := d.readNode(offset, minOffset)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ret, err := d.makeNode(pn)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	nextMin := offset + uint64(nodeLen)
+	var last *node
+
+	for childOffset := pn.firstChildOffset; childOffset != 0; {
+		child, siblingOffset, err := d.decodeNode(childOffset, nextMin)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if last == nil {
+			ret.firstChild = child
+		} else {
+			last.nextSibling = child
+		}
+
+		last = child
+		ret.numChildren++
+		nextMin = childOffset + 1
+		childOffset = siblingOffset
+	}
+
+	if err := d.finishNode(ret, pn); err != nil {
+		return nil, 0, err
+	}
+
+	return ret, pn.nextSiblingOffset, nil
+}
+
+// readNode reads the serialized node at the given offset, and returns it along
+// with its length. The offset must not be less than minOffset.
+func (d *snapshotDecoder) readNode(offset uint64, minOffset uint64) (persistentNode, int, error) {
+	if offset < minOffset || offset+minNodeBytesLen > uint64(len(d.src)) {
+		return persistentNode{}, 0, ErrNodeCorrupted
+	}
+
+	// The key and data lengths follow the flags and the number of children.
+	region := d.src[offset:]
+	keyLen := int(binary.LittleEndian.Uint16(region[sizeOfUint8+sizeOfUint16:]))
+	dataLen := int(binary.LittleEndian.Uint32(region[sizeOfUint8+sizeOfUint16+sizeOfUint16:]))
+	nodeLen := minNodeBytesLen + keyLen + dataLen + checksumLen
+
+	if region[0]&flagHasUserFlags != 0 {
+		nodeLen += sizeOfUint8
+	}
+
+	if nodeLen > len(region) {
+		return persistentNode{}, 0, ErrNodeCorrupted
+	}
+
+	pn, err := makePersistentNodeFromBytes(region[:nodeLen])
+
+	return pn, nodeLen, err
+}
+
+// makeNode returns the in-memory node of the serialized node without its
+// children, and attaches its blob.
+func (d *snapshotDecoder) makeNode(pn persistentNode) (*node, error) {
+	ret := &node{isRecord: pn.isRecord(), blobValue: pn.hasBlob(), userFlags: pn.userFlags}
+
+	if len(pn.key) > 0 {
+		ret.key = pn.key
+	}
+
+	if len(pn.data) > 0 {
+		ret.data = pn.data
+	} else if pn.hasEmptyValue() {
+		ret.data = []byte{}
+	}
+
+	if ret.blobValue {
+		if err := d.attachBlob(ret); err != nil {
+			return nil, err
+		}
+	}
+
+	d.numNodes++
+
+	if ret.isRecord {
+		d.numRecords++
+	}
+
+	return ret, nil
+}
+
+// finishNode verifies the number of children of the node once they are all
+// attached, and then computes the derived fields that depend on them.
+func (d *snapshotDecoder) finishNode(n *node, pn persistentNode) error {
+	if n.numChildren != int(pn.numChildren) {
+		return ErrNodeCorrupted
+	}
+
+	if d.arc.opts.TrackPrefixCounts {
+		n.subtreeRecords = n.countSubtreeRecords()
+	}
+
+	return nil
+}
+
+// attachBlob adds the blob referenced by the node to the blobStore, or
+// increments its reference count if it was already added.
+func (d *snapshotDecoder) attachBlob(n *node) error {
+	id, err := sliceToBlobID(n.data)
+
+	if err != nil {
+		return err
+	}
+
+	if b, found := d.blobs[id]; found {
+		b.refCount++
+		return nil
+	}
+
+	content, found := d.contents[id]
+
+	if !found {
+		return ErrCorrupted
+	}
+
+	d.blobs[id] = &blob{value: content, refCount: 1}
+
+	return nil
+}
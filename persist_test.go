@@ -0,0 +1,325 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveAndOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, err := Open(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if arc.Len() != 0 {
+		t.Fatalf("expected an empty database, got %d records", arc.Len())
+	}
+
+	for _, key := range sortedBasicTestKeys() {
+		arc.Put([]byte(key), []byte(key))
+	}
+
+	// Two records share the same blob.
+	arc.Put([]byte("blob-a"), blobValueX())
+	arc.Put([]byte("blob-b"), blobValueX())
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := Open(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertSameRecords(t, reopened, arc)
+
+	if reopened.numNodes != arc.numNodes {
+		t.Errorf("unexpected numNodes: got:%d, want:%d", reopened.numNodes, arc.numNodes)
+	}
+
+	if got := reopened.blobs[makeBlobID(blobValueX())].refCount; got != 2 {
+		t.Errorf("unexpected refCount: got:%d, want:%d", got, 2)
+	}
+}
+
+func TestSaveEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := Open(path)
+
+	if err := arc.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	arc.Close()
+
+	reopened, err := Open(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if reopened.root != nil || reopened.Len() != 0 {
+		t.Errorf("expected an empty database")
+	}
+}
+
+func TestSaveNotFileBacked(t *testing.T) {
+	if err := New().Save(); err != ErrNotFileBacked {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrNotFileBacked)
+	}
+
+	if err := New().Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSaveWithEncryption(t *testing.T) {
+	provider, _ := NewAESGCMProvider(map[uint32][]byte{1: bytes.Repeat([]byte{0x01}, 32)}, 1)
+	opts := Options{Encryption: provider}
+	path := filepath.Join(t.TempDir(), "test.arc")
+
+	arc, _ := OpenWithOptions(path, opts)
+	arc.Put([]byte("secret"), blobValueX())
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	raw, _ := os.ReadFile(path)
+
+	if bytes.Contains(raw, blobValueX()) {
+		t.Fatalf("expected the blob to be encrypted at rest")
+	}
+
+	reopened, err := OpenWithOptions(path, opts)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertSameRecords(t, reopened, arc)
+	reopened.Close()
+
+	if _, err := Open(path); err == nil {
+		t.Errorf("expected an error when opening without the provider")
+	}
+}
+
+func TestOpenCorrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := Open(path)
+
+	for _, key := range sortedBasicTestKeys() {
+		arc.Put([]byte(key), []byte(key))
+	}
+
+	arc.Put([]byte("blob"), blobValueX())
+	arc.Close()
+
+	original, _ := os.ReadFile(path)
+
+	for i := range original {
+		corrupted := append([]byte{}, original...)
+		corrupted[i] ^= 0xFF
+
+		os.WriteFile(path, corrupted, 0o600)
+
+		if _, err := Open(path); err == nil {
+			t.Fatalf("expected an error with byte %d corrupted", i)
+		}
+	}
+
+	os.WriteFile(path, original[:len(original)-1], 0o600)
+
+	if _, err := Open(path); err == nil {
+		t.Errorf("expected an error with a truncated file")
+	}
+
+	unsupported := append([]byte{}, original...)
+	unsupported[1] = fileFormatVersion + 1
+	header := arcHeader{magic: unsupported[0], version: unsupported[1], status: unsupported[2]}
+	headerBytes, _ := header.serialize()
+	copy(unsupported, headerBytes)
+
+	os.WriteFile(path, unsupported, 0o600)
+
+	if _, err := Open(path); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrUnsupportedVersion)
+	}
+}
+
+func assertSameRecords(t *testing.T, got *Arc, want *Arc) {
+	t.Helper()
+
+	var gotRecords, wantRecords []record
+
+	for k, v := range got.Scan(nil) {
+		gotRecords = append(gotRecords, record{key: k, value: v})
+	}
+
+	for k, v := range want.Scan(nil) {
+		wantRecords = append(wantRecords, record{key: k, value: v})
+	}
+
+	if len(gotRecords) != len(wantRecords) {
+		t.Fatalf("unexpected number of records: got:%d, want:%d", len(gotRecords), len(wantRecords))
+	}
+
+	for i := range wantRecords {
+		if !bytes.Equal(gotRecords[i].key, wantRecords[i].key) || !bytes.Equal(gotRecords[i].value, wantRecords[i].value) {
+			t.Errorf("unexpected record: got:%q=%q, want:%q=%q", gotRecords[i].key, gotRecords[i].value, wantRecords[i].key, wantRecords[i].value)
+		}
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+
+	if _, err := OpenReadOnly(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("unexpected error: got:%v, want:%v", err, os.ErrNotExist)
+	}
+
+	arc, _ := Open(path)
+
+	for _, key := range sortedBasicTestKeys() {
+		arc.Put([]byte(key), []byte(key))
+	}
+
+	arc.Save()
+
+	if _, err := OpenReadOnly(path); err != ErrDatabaseLocked {
+		t.Fatalf("unexpected error: got:%v, want:%v", err, ErrDatabaseLocked)
+	}
+
+	arc.Close()
+
+	// Multiple read-only handles can share the file.
+	r1, err := OpenReadOnly(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r2, err := OpenReadOnly(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertSameRecords(t, r1, arc)
+	assertSameRecords(t, r2, arc)
+
+	if _, err := Open(path); err != ErrDatabaseLocked {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrDatabaseLocked)
+	}
+
+	writes := map[string]error{
+		"Put":         r1.Put([]byte("key"), []byte("value")),
+		"Add":         r1.Add([]byte("key"), []byte("value")),
+		"Delete":      r1.Delete([]byte(sortedBasicTestKeys()[0])),
+		"CreateIndex": r1.CreateIndex("index", valueIndexFunc),
+		"Save":        r1.Save(),
+		"Compact":     r1.Compact(),
+	}
+
+	for name, err := range writes {
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("unexpected %s error: got:%v, want:%v", name, err, ErrReadOnly)
+		}
+	}
+
+	if r1.Len() != arc.Len() {
+		t.Errorf("unexpected length: got:%d, want:%d", r1.Len(), arc.Len())
+	}
+
+	r1.Close()
+	r2.Close()
+
+	if _, err := Open(path); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestWriteNodes(t *testing.T) {
+	arc := basicTestTree()
+	arc.Put([]byte("blob"), blobValueX())
+
+	// The node section may start anywhere in the buffer.
+	var buf bytes.Buffer
+	buf.WriteString("prefix")
+
+	end, err := writeNodes(&buf, arc.root, uint64(buf.Len()))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if end != uint64(buf.Len()) {
+		t.Errorf("unexpected end offset: got:%d, want:%d", end, buf.Len())
+	}
+
+	decoded := New()
+	d := snapshotDecoder{arc: decoded, src: buf.Bytes(), contents: map[blobID][]byte{makeBlobID(blobValueX()): blobValueX()}, blobs: decoded.blobs}
+	root, _, err := d.decodeNode(uint64(len("prefix")), uint64(len("prefix")))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	decoded.root = root
+	decoded.numNodes = d.numNodes
+	decoded.numRecords = d.numRecords
+
+	assertSameRecords(t, decoded, arc)
+
+	if decoded.numNodes != arc.numNodes {
+		t.Errorf("unexpected numNodes: got:%d, want:%d", decoded.numNodes, arc.numNodes)
+	}
+}
+
+func TestEmptyValuePersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := Open(path)
+
+	arc.Put([]byte("nil"), nil)
+	arc.Put([]byte("empty"), []byte{})
+	arc.Put([]byte("empty/child"), []byte("value"))
+
+	check := func(arc *Arc) {
+		t.Helper()
+
+		if value, err := arc.Get([]byte("nil")); err != nil || value != nil {
+			t.Errorf("unexpected value: got:%q, err:%v", value, err)
+		}
+
+		if value, err := arc.Get([]byte("empty")); err != nil || value == nil || len(value) != 0 {
+			t.Errorf("unexpected value: got:%q, err:%v", value, err)
+		}
+	}
+
+	check(arc)
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := Open(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer reopened.Close()
+
+	check(reopened)
+}
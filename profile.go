@@ -0,0 +1,132 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"context"
+	"runtime"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+)
+
+// profileLabel is the pprof label that tags the internal work of the database
+// with its task, such as "compaction", "load", or "blobgc". The CPU and heap
+// profiles can be filtered by it, such as with "go tool pprof -tagfocus".
+const profileLabel = "arc"
+
+// Profile reports the memory held by a database, and the contention on its
+// lock.
+type Profile struct {
+	// Nodes is the number of nodes in the tree.
+	Nodes int
+
+	// KeyBytes is the total size of the node keys in bytes.
+	KeyBytes int64
+
+	// InlineValueBytes is the total size of the values that are stored in
+	// the nodes rather than in the blob store.
+	InlineValueBytes int64
+
+	// BlobBytes is the total size of the unique blob values.
+	BlobBytes int64
+
+	// HeapAlloc, TotalAlloc, and Mallocs are the fields of runtime.MemStats
+	// as of the snapshot. They cover the entire process, which allows the
+	// share of the database to be put in perspective.
+	HeapAlloc  uint64
+	TotalAlloc uint64
+	Mallocs    uint64
+
+	// LockWaits is the number of times that a write had to wait for the
+	// lock, and LockWaitTime is the total time that the writes waited.
+	LockWaits    uint64
+	LockWaitTime time.Duration
+
+	// ReadLockWaits and ReadLockWaitTime are like LockWaits and LockWaitTime
+	// for the reads, which wait for the writes.
+	ReadLockWaits    uint64
+	ReadLockWaitTime time.Duration
+}
+
+// lockContention counts the acquisitions of the database lock that had to wait
+// for another holder, and the total time spent waiting. Uncontended
+// acquisitions are not timed.
+type lockContention struct {
+	waits         atomic.Uint64
+	waitNanos     atomic.Int64
+	readWaits     atomic.Uint64
+	readWaitNanos atomic.Int64
+}
+
+// ProfileSnapshot returns the memory held by the database, the allocation
+// statistics of the process, and the lock contention since the database was
+// created. Reading the statistics of the process briefly stops the world,
+// therefore ProfileSnapshot is meant to be called periodically rather than on
+// a hot path. The internal work of the database, such as the compaction, the
+// loading of files, and the blob garbage collection, is also tagged with the
+// pprof label "arc", which attributes it in the CPU and heap profiles.
+func (a *Arc) ProfileSnapshot() Profile {
+	var ret Profile
+
+	a.rlock()
+
+	var visit func(n *node)
+
+	visit = func(n *node) {
+		ret.Nodes++
+		ret.KeyBytes += int64(len(n.key))
+
+		if !n.blobValue {
+			ret.InlineValueBytes += int64(len(n.data))
+		}
+
+		for child := n.firstChild; child != nil; child = child.nextSibling {
+			visit(child)
+		}
+	}
+
+	if a.root != nil {
+		visit(a.root)
+	}
+
+	for _, b := range a.blobs {
+		ret.BlobBytes += int64(len(b.value))
+	}
+
+	a.runlock()
+
+	var stats runtime.MemStats
+
+	runtime.ReadMemStats(&stats)
+
+	ret.HeapAlloc = stats.HeapAlloc
+	ret.TotalAlloc = stats.TotalAlloc
+	ret.Mallocs = stats.Mallocs
+
+	ret.LockWaits = a.contention.waits.Load()
+	ret.LockWaitTime = time.Duration(a.contention.waitNanos.Load())
+	ret.ReadLockWaits = a.contention.readWaits.Load()
+	ret.ReadLockWaitTime = time.Duration(a.contention.readWaitNanos.Load())
+
+	return ret
+}
+
+// profile runs fn with the pprof label that tags it with the given task. The
+// labels of a goroutine cannot be restored once they are replaced, therefore
+// fn runs on a separate goroutine, which leaves the labels of the caller as
+// they are. The caller waits for fn to return.
+func profile(task string, fn func()) {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		pprof.Do(context.Background(), pprof.Labels(profileLabel, task), func(context.Context) {
+			fn()
+		})
+	}()
+
+	<-done
+}
@@ -0,0 +1,67 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProfileSnapshot(t *testing.T) {
+	arc := New()
+
+	arc.Put([]byte("apple"), []byte("red"))
+	arc.Put([]byte("apricot"), blobValueX())
+
+	p := arc.ProfileSnapshot()
+
+	// The keys are split into "ap", "ple", and "ricot".
+	if p.Nodes != 3 || p.KeyBytes != 10 {
+		t.Errorf("unexpected tree size: nodes:%d, keys:%d", p.Nodes, p.KeyBytes)
+	}
+
+	if p.InlineValueBytes != 3 || p.BlobBytes != int64(len(blobValueX())) {
+		t.Errorf("unexpected value size: inline:%d, blobs:%d", p.InlineValueBytes, p.BlobBytes)
+	}
+
+	if p.HeapAlloc == 0 || p.Mallocs == 0 {
+		t.Errorf("expected the allocation statistics of the process")
+	}
+
+	if p.LockWaits != 0 || p.ReadLockWaits != 0 {
+		t.Errorf("unexpected lock contention: %+v", p)
+	}
+}
+
+func TestProfileSnapshotContention(t *testing.T) {
+	arc := New()
+	done := make(chan struct{})
+
+	arc.mu.RLock()
+
+	go func() {
+		defer close(done)
+		arc.Put([]byte("key"), []byte("value"))
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	arc.mu.RUnlock()
+	<-done
+
+	if p := arc.ProfileSnapshot(); p.LockWaits != 1 || p.LockWaitTime <= 0 {
+		t.Errorf("unexpected lock contention: waits:%d, time:%v", p.LockWaits, p.LockWaitTime)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	var called bool
+
+	profile("load", func() {
+		called = true
+	})
+
+	if !called {
+		t.Errorf("expected profile to run the function before returning")
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+)
+
+// ErrQuotaExceeded is returned when a write would exceed the quota of a key
+// prefix. See SetQuota.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota describes the limits set on the records whose keys begin with Prefix,
+// along with their current usage.
+type Quota struct {
+	Prefix     []byte // Key prefix that the quota applies to.
+	MaxRecords int64  // Maximum number of records, or zero for no limit.
+	MaxBytes   int64  // Maximum total size of the keys and values in bytes, or zero for no limit.
+	Records    int64  // Current number of records.
+	Bytes      int64  // Current total size of the keys and values in bytes.
+}
+
+// SetQuota limits the number of records, and the total size of the keys and
+// values, under the given key prefix. Writes that would exceed either limit
+// fail with ErrQuotaExceeded, which suits multi-tenant databases that hold a
+// prefix per tenant. Writes that do not increase the usage are allowed even
+// if the usage already exceeds the limits. Zero or less means no limit, and
+// setting no limit at all removes the quota. A key may fall under several
+// quotas, in which case the write must satisfy all of them. Quotas are not
+// persisted, therefore they must be set every time the database is opened.
+// Returns ErrReadOnly if the database is read-only.
+func (a *Arc) SetQuota(prefix []byte, maxRecords int64, maxBytes int64) error {
+	if a.readOnly {
+		return ErrReadOnly
+	}
+
+	prefix = a.transformKey(prefix)
+
+	if len(prefix) > a.opts.MaxKeyBytes {
+		return &SizeError{Err: ErrKeyTooLarge, Size: len(prefix), Limit: a.opts.MaxKeyBytes}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if maxRecords <= 0 && maxBytes <= 0 {
+		delete(a.quotas, string(prefix))
+		return nil
+	}
+
+	q := &Quota{
+		Prefix:     bytes.Clone(prefix),
+		MaxRecords: max(maxRecords, 0),
+		MaxBytes:   max(maxBytes, 0),
+	}
+
+	if n, parentKey := a.findPrefixNode(prefix); n != nil {
+		walkNode(n, parentKey, func(key []byte, n *node) bool {
+			q.Records++
+			q.Bytes += int64(len(key) + n.valueSize(a.blobs))
+			return true
+		})
+	}
+
+	if a.quotas == nil {
+		a.quotas = map[string]*Quota{}
+	}
+
+	a.quotas[string(prefix)] = q
+
+	return nil
+}
+
+// Quotas returns the quotas set by SetQuota in key order, along with their
+// current usage.
+func (a *Arc) Quotas() []Quota {
+	a.rlock()
+	defer a.runlock()
+
+	ret := make([]Quota, 0, len(a.quotas))
+
+	for _, q := range a.quotas {
+		ret = append(ret, *q)
+		ret[len(ret)-1].Prefix = bytes.Clone(q.Prefix)
+	}
+
+	slices.SortFunc(ret, func(x, y Quota) int {
+		return bytes.Compare(x.Prefix, y.Prefix)
+	})
+
+	return ret
+}
+
+// quotaDelta returns the change in usage caused by writing a value of the
+// given size to the key, or by deleting the key if valueSize is negative. The
+// caller must hold the read lock.
+func (a *Arc) quotaDelta(key []byte, valueSize int) (records int64, size int64) {
+	if len(a.quotas) == 0 {
+		return 0, 0
+	}
+
+	if n, _, err := a.findNodeAndParent(key); err == nil && n.isRecord {
+		records--
+		size -= int64(len(key) + n.valueSize(a.blobs))
+	}
+
+	if valueSize >= 0 {
+		records++
+		size += int64(len(key) + valueSize)
+	}
+
+	return records, size
+}
+
+// checkQuotas returns ErrQuotaExceeded if the change in usage would exceed a
+// quota that applies to the key. Replicas mirror their primary, therefore
+// they do not enforce quotas. The caller must hold the read lock.
+func (a *Arc) checkQuotas(key []byte, records int64, size int64) error {
+	if a.readOnly {
+		return nil
+	}
+
+	for _, q := range a.quotas {
+		if !bytes.HasPrefix(key, q.Prefix) {
+			continue
+		}
+
+		if records > 0 && q.MaxRecords > 0 && q.Records+records > q.MaxRecords {
+			return ErrQuotaExceeded
+		}
+
+		if size > 0 && q.MaxBytes > 0 && q.Bytes+size > q.MaxBytes {
+			return ErrQuotaExceeded
+		}
+	}
+
+	return nil
+}
+
+// chargeQuotas applies the change in usage to the quotas that apply to the
+// key. The caller must hold the write lock.
+func (a *Arc) chargeQuotas(key []byte, records int64, size int64) {
+	for _, q := range a.quotas {
+		if bytes.HasPrefix(key, q.Prefix) {
+			q.Records += records
+			q.Bytes += size
+		}
+	}
+}
+
+// checkMoveQuotas returns ErrQuotaExceeded if moving the records would exceed
+// a quota, which is checked against the net change of all the moves. The
+// caller must hold the read lock.
+func (a *Arc) checkMoveQuotas(moves []keyMove) error {
+	if a.readOnly {
+		return nil
+	}
+
+	for _, q := range a.quotas {
+		var records, size int64
+
+		for _, m := range moves {
+			if bytes.HasPrefix(m.from, q.Prefix) {
+				records--
+				size -= int64(len(m.from) + m.size)
+			}
+
+			if bytes.HasPrefix(m.to, q.Prefix) {
+				records++
+				size += int64(len(m.to) + m.size)
+			}
+		}
+
+		if records > 0 && q.MaxRecords > 0 && q.Records+records > q.MaxRecords {
+			return ErrQuotaExceeded
+		}
+
+		if size > 0 && q.MaxBytes > 0 && q.Bytes+size > q.MaxBytes {
+			return ErrQuotaExceeded
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSetQuota(t *testing.T) {
+	arc := New()
+	arc.Put([]byte("tenant1/a"), []byte("12345"))
+	arc.Put([]byte("tenant2/a"), []byte("12345"))
+
+	if err := arc.SetQuota([]byte("tenant1/"), 2, 30); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Existing records count towards the quota.
+	if got := arc.Quotas(); len(got) != 1 || got[0].Records != 1 || got[0].Bytes != 14 {
+		t.Fatalf("unexpected quotas: %+v", got)
+	}
+
+	if err := arc.Put([]byte("tenant1/b"), []byte("1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := arc.Put([]byte("tenant1/c"), nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrQuotaExceeded)
+	}
+
+	if err := arc.Put([]byte("tenant1/b"), []byte("1234567890")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrQuotaExceeded)
+	}
+
+	// Other prefixes are unaffected.
+	if err := arc.Put([]byte("tenant2/b"), []byte("1234567890")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := arc.Delete([]byte("tenant1/a")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := arc.Put([]byte("tenant1/c"), nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if got := arc.Quotas(); got[0].Records != 2 || got[0].Bytes != 19 {
+		t.Errorf("unexpected usage: %+v", got[0])
+	}
+
+	// Removing the limits removes the quota.
+	arc.SetQuota([]byte("tenant1/"), 0, 0)
+
+	if got := arc.Quotas(); len(got) != 0 {
+		t.Errorf("unexpected quotas: %+v", got)
+	}
+}
+
+func TestSetQuotaTxn(t *testing.T) {
+	arc := New()
+	arc.SetQuota([]byte("t/"), 1, 0)
+
+	txn := arc.Begin()
+	txn.Put([]byte("t/a"), nil)
+	txn.Put([]byte("t/b"), nil)
+
+	if err := txn.Commit(); err != ErrQuotaExceeded {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrQuotaExceeded)
+	}
+
+	// None of the writes of the transaction are applied.
+	if arc.Len() != 0 {
+		t.Errorf("unexpected length: got:%d, want:%d", arc.Len(), 0)
+	}
+
+	if got := arc.Quotas(); got[0].Records != 0 {
+		t.Errorf("unexpected usage: %+v", got[0])
+	}
+}
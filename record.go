@@ -0,0 +1,237 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"slices"
+	"time"
+)
+
+// RecordInfo describes a database record without materializing its value.
+type RecordInfo struct {
+	Key       []byte    // Copy of the record key.
+	Size      int       // Size of the record value in bytes.
+	IsBlob    bool      // True if the value is stored in the blobStore.
+	Flags     uint8     // User flags set by PutWithFlags and SetFlags.
+	CreatedAt time.Time // Zero unless Options.RecordTimestamps is enabled.
+	UpdatedAt time.Time // Zero unless Options.RecordTimestamps is enabled.
+	ExpiresAt time.Time // Zero unless the record expires.
+}
+
+// recordTimestamps holds the creation and last update time of a record.
+type recordTimestamps struct {
+	createdAt time.Time
+	updatedAt time.Time
+}
+
+// Stat returns the metadata of the record that matches the given key. Returns
+// ErrKeyNotFound if the key does not exist.
+func (a *Arc) Stat(key []byte) (RecordInfo, error) {
+	key = a.applyKeyTransform(key)
+
+	if err := a.authorize(OpGet, key); err != nil {
+		return RecordInfo{}, err
+	}
+
+	key = a.foldKey(key)
+
+	if err := a.checkKey(key); err != nil {
+		return RecordInfo{}, err
+	}
+
+	a.rlock()
+	defer a.runlock()
+
+	n, _, err := a.findNodeAndParent(key)
+
+	if err != nil {
+		return RecordInfo{}, err
+	}
+
+	if !n.isRecord || a.expired(key) {
+		return RecordInfo{}, ErrKeyNotFound
+	}
+
+	return a.recordInfo(key, n), nil
+}
+
+// Has returns true if a record exists under the given key, regardless of its
+// value. Records with nil or empty values therefore exist just as well.
+func (a *Arc) Has(key []byte) (bool, error) {
+	key = a.applyKeyTransform(key)
+
+	if err := a.authorize(OpGet, key); err != nil {
+		return false, err
+	}
+
+	key = a.foldKey(key)
+
+	if err := a.checkKey(key); err != nil {
+		return false, err
+	}
+
+	a.rlock()
+	defer a.runlock()
+
+	if a.filter != nil && !a.filter.mayContain(key) {
+		return false, nil
+	}
+
+	return a.isLiveRecord(key), nil
+}
+
+// recordInfo builds the RecordInfo of the given record node. The key must be
+// the full key of the record, not just the path segment held by the node.
+func (a *Arc) recordInfo(key []byte, n *node) RecordInfo {
+	ret := RecordInfo{
+		Key:       append([]byte{}, a.originalKey(key)...),
+		Size:      n.valueSize(a.blobs),
+		IsBlob:    n.blobValue,
+		Flags:     n.userFlags,
+		ExpiresAt: a.expiresAt(key),
+	}
+
+	if ts, found := a.timestamps[string(key)]; found {
+		ret.CreatedAt = ts.createdAt
+		ret.UpdatedAt = ts.updatedAt
+	}
+
+	return ret
+}
+
+// touchRecord records the current time as the last update time of the record.
+// The creation time is also set if the record is seen for the first time. It
+// is a no-op unless the RecordTimestamps option is enabled.
+func (a *Arc) touchRecord(key []byte) {
+	if a.timestamps == nil {
+		return
+	}
+
+	now := a.now()
+
+	if ts, found := a.timestamps[string(key)]; found {
+		ts.updatedAt = now
+		return
+	}
+
+	a.timestamps[string(key)] = &recordTimestamps{createdAt: now, updatedAt: now}
+}
+
+// forgetRecord discards the metadata of a deleted record.
+func (a *Arc) forgetRecord(key []byte) {
+	delete(a.expirations, string(key))
+	delete(a.originalKeys, string(key))
+	a.forgetReads(key)
+	a.forgetVersions(key)
+
+	if a.timestamps != nil {
+		delete(a.timestamps, string(key))
+	}
+}
+
+// serializeTimestamps serializes the timestamp section, which consists of the
+// number of records with timestamps, the records in key order, and the
+// checksum of the preceding bytes. Each record holds the key length, the key,
+// the creation time, and the last update time. The caller must hold the read
+// lock.
+func (a *Arc) serializeTimestamps() ([]byte, error) {
+	keys := make([]string, 0, len(a.timestamps))
+
+	for key := range a.timestamps {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	ret := binary.LittleEndian.AppendUint64(nil, uint64(len(keys)))
+
+	for _, key := range keys {
+		ts := a.timestamps[key]
+		ret = binary.LittleEndian.AppendUint16(ret, uint16(len(key)))
+		ret = append(ret, key...)
+		ret = append(ret, encodeExpiration(ts.createdAt)...)
+		ret = append(ret, encodeExpiration(ts.updatedAt)...)
+	}
+
+	checksum, err := computeChecksum(ret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return binary.LittleEndian.AppendUint32(ret, checksum), nil
+}
+
+// readTimestamps loads the timestamp section produced by serializeTimestamps.
+// The records must already be loaded, since every timestamp must refer to an
+// existing record. The section is verified but discarded unless the
+// RecordTimestamps option is enabled.
+func (a *Arc) readTimestamps(src []byte) error {
+	if len(src) < sizeOfUint64+checksumLen {
+		return ErrCorrupted
+	}
+
+	checksumPos := len(src) - checksumLen
+	checksum, err := computeChecksum(src[:checksumPos])
+
+	if err != nil {
+		return err
+	}
+
+	if checksum != binary.LittleEndian.Uint32(src[checksumPos:]) {
+		return ErrInvalidChecksum
+	}
+
+	r := bytes.NewReader(src[:checksumPos])
+
+	var count uint64
+
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return ErrCorrupted
+	}
+
+	for i := uint64(0); i < count; i++ {
+		var keyLen uint16
+
+		if err := binary.Read(r, binary.LittleEndian, &keyLen); err != nil {
+			return ErrCorrupted
+		}
+
+		entry := make([]byte, int(keyLen)+sizeOfUint64+sizeOfUint64)
+
+		if _, err := io.ReadFull(r, entry); err != nil {
+			return ErrCorrupted
+		}
+
+		key := entry[:keyLen]
+		createdAt, err := decodeExpiration(entry[keyLen : int(keyLen)+sizeOfUint64])
+
+		if err != nil {
+			return err
+		}
+
+		updatedAt, err := decodeExpiration(entry[int(keyLen)+sizeOfUint64:])
+
+		if err != nil {
+			return err
+		}
+
+		if n, _, err := a.findNodeAndParent(key); err != nil || !n.isRecord || createdAt.IsZero() || updatedAt.IsZero() {
+			return ErrCorrupted
+		}
+
+		if a.timestamps != nil {
+			a.timestamps[string(key)] = &recordTimestamps{createdAt: createdAt, updatedAt: updatedAt}
+		}
+	}
+
+	if r.Len() != 0 {
+		return ErrCorrupted
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStat(t *testing.T) {
+	arc := basicTestTree()
+
+	for _, known := range basicTestTreeData() {
+		info, err := arc.Stat(known.key)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !bytes.Equal(info.Key, known.key) {
+			t.Errorf("unexpected key: got:%q, want:%q", info.Key, known.key)
+		}
+
+		if info.Size != len(known.data) {
+			t.Errorf("unexpected size: got:%d, want:%d", info.Size, len(known.data))
+		}
+
+		if !info.CreatedAt.IsZero() || !info.UpdatedAt.IsZero() {
+			t.Errorf("expected zero timestamps when the option is disabled")
+		}
+	}
+
+	if err := arc.Put([]byte("blob"), blobValueX()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := arc.Stat([]byte("blob"))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !info.IsBlob || info.Size != len(blobValueX()) {
+		t.Errorf("unexpected blob info: got:%v/%d, want:true/%d", info.IsBlob, info.Size, len(blobValueX()))
+	}
+
+	// Non-record nodes and unknown keys must not be reported.
+	for _, key := range [][]byte{[]byte("ap"), []byte("bogus")} {
+		if _, err := arc.Stat(key); err != ErrKeyNotFound {
+			t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+		}
+	}
+}
+
+func TestRecordTimestamps(t *testing.T) {
+	arc, err := NewWithOptions(Options{RecordTimestamps: true})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clock := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	arc.now = func() time.Time { return clock }
+
+	created := clock
+	arc.Put([]byte("apple"), []byte("1"))
+
+	clock = clock.Add(time.Hour)
+	updated := clock
+	arc.Put([]byte("apple"), []byte("2"))
+
+	// Inserting a sibling splits the tree, but must not affect "apple".
+	clock = clock.Add(time.Hour)
+	arc.Put([]byte("apricot"), []byte("3"))
+
+	info, err := arc.Stat([]byte("apple"))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !info.CreatedAt.Equal(created) {
+		t.Errorf("unexpected CreatedAt: got:%v, want:%v", info.CreatedAt, created)
+	}
+
+	if !info.UpdatedAt.Equal(updated) {
+		t.Errorf("unexpected UpdatedAt: got:%v, want:%v", info.UpdatedAt, updated)
+	}
+
+	// Deleting and re-inserting the record resets its creation time.
+	if err := arc.Delete([]byte("apple")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clock = clock.Add(time.Hour)
+	arc.Add([]byte("apple"), []byte("4"))
+
+	if info, _ = arc.Stat([]byte("apple")); !info.CreatedAt.Equal(clock) {
+		t.Errorf("unexpected CreatedAt: got:%v, want:%v", info.CreatedAt, clock)
+	}
+
+	if len(arc.timestamps) != arc.Len() {
+		t.Errorf("unexpected timestamp count: got:%d, want:%d", len(arc.timestamps), arc.Len())
+	}
+}
+
+func TestRecordTimestampsSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, err := OpenWithOptions(path, Options{RecordTimestamps: true})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clock := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	arc.now = func() time.Time { return clock }
+	arc.Put([]byte("apple"), []byte("1"))
+
+	clock = clock.Add(time.Hour)
+	arc.Put([]byte("apple"), []byte("2"))
+
+	want, _ := arc.Stat([]byte("apple"))
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := OpenWithOptions(path, Options{RecordTimestamps: true})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, _ := reopened.Stat([]byte("apple")); !got.CreatedAt.Equal(want.CreatedAt) || !got.UpdatedAt.Equal(want.UpdatedAt) {
+		t.Errorf("unexpected timestamps: got:%+v, want:%+v", got, want)
+	}
+
+	reopened.Close()
+
+	// The timestamps are ignored unless the option is enabled.
+	reopened, err = Open(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer reopened.Close()
+
+	if got, _ := reopened.Stat([]byte("apple")); !got.CreatedAt.IsZero() || !got.UpdatedAt.IsZero() {
+		t.Errorf("unexpected timestamps: %+v", got)
+	}
+}
+
+func TestHas(t *testing.T) {
+	arc, _ := NewWithOptions(Options{BloomFilterBitsPerKey: 10})
+
+	arc.Put([]byte("nil"), nil)
+	arc.Put([]byte("empty"), []byte{})
+
+	for _, key := range []string{"nil", "empty"} {
+		if found, err := arc.Has([]byte(key)); err != nil || !found {
+			t.Errorf("expected %q to exist: %v", key, err)
+		}
+	}
+
+	if found, err := arc.Has([]byte("absent")); err != nil || found {
+		t.Errorf("expected the key to be absent: %v", err)
+	}
+
+	if _, err := arc.Has(nil); err != ErrNilKey {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrNilKey)
+	}
+
+	arc.Delete([]byte("nil"))
+
+	if found, _ := arc.Has([]byte("nil")); found {
+		t.Errorf("expected the deleted key to be absent")
+	}
+}
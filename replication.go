@@ -0,0 +1,538 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// Replication frame types. A replication stream starts with a snapshot frame,
+// which is followed by a change frame for every write. Replicas first send an
+// auth frame with their ReplicationToken, which may be empty, and the primary
+// answers a token that does not match with a denied frame. Primaries without
+// a token discard the auth frame along with anything else that replicas send.
+const (
+	frameSnapshot = byte(1)
+	frameChange   = byte(2)
+	frameAuth     = byte(3)
+	frameDenied   = byte(4)
+)
+
+// replicationAuthTimeout is how long the primary waits for the TLS handshake
+// and the auth frame of a replica that connects.
+const replicationAuthTimeout = 10 * time.Second
+
+// maxReplicationTokenLen is the maximum length of the token of an auth frame.
+const maxReplicationTokenLen = 4 << 10
+
+// replicationBufferSize is the number of changes that can be queued for a
+// replica. Replicas that fall further behind are disconnected.
+const replicationBufferSize = 4096
+
+// change describes a write that was applied to the database.
+type change struct {
+	seq   uint64
+	op    Op
+	key   []byte
+	value []byte
+}
+
+// replicationStream queues the changes to be sent to a replica.
+type replicationStream struct {
+	changes chan change
+}
+
+// follower tracks the connection of a replica to its primary.
+type follower struct {
+	conn net.Conn
+	done chan struct{} // Closed once the follower has stopped.
+	err  error         // Error that stopped the follower.
+}
+
+// Seq returns the sequence number of the last write. The sequence number is
+// incremented by every successful write, and replicas report the sequence
+// number of their primary, which allows the replication lag to be measured.
+func (a *Arc) Seq() uint64 {
+	a.rlock()
+	defer a.runlock()
+
+	return a.seq
+}
+
+// ServeReplication accepts replica connections on the listener, and streams
+// the database to them. Each replica receives a snapshot of the database,
+// followed by every subsequent write in order. Replication is asynchronous,
+// therefore writes do not wait for the replicas. Replicas that fall too far
+// behind are disconnected. ServeReplication blocks until the listener fails,
+// and returns the error. Close disconnects the replicas. The connections are
+// secured with TLS if the ReplicationTLS option is set, and the replicas must
+// present the ReplicationToken if it is set.
+func (a *Arc) ServeReplication(l net.Listener) error {
+	if a.opts.ReplicationTLS != nil {
+		l = tls.NewListener(l, a.opts.ReplicationTLS)
+	}
+
+	for {
+		conn, err := l.Accept()
+
+		if err != nil {
+			return err
+		}
+
+		go a.serveReplica(conn)
+	}
+}
+
+// serveReplica streams the database to the replica on the connection.
+func (a *Arc) serveReplica(conn net.Conn) {
+	defer conn.Close()
+
+	if err := a.authenticateReplica(conn); err != nil {
+		a.log(slog.LevelWarn, "rejected replica", "addr", conn.RemoteAddr().String(), "err", err)
+		return
+	}
+
+	var snapshot bytes.Buffer
+
+	stream := &replicationStream{changes: make(chan change, replicationBufferSize)}
+
+	// Writers publish their changes under the write lock, therefore no write
+	// can be missed between the snapshot and the subscription.
+	a.rlock()
+	seq := a.seq
+	err := a.writeSnapshot(&snapshot)
+
+	if err == nil {
+		a.subscribe(stream)
+	}
+
+	a.runlock()
+
+	if err != nil {
+		return
+	}
+
+	defer a.unsubscribe(stream)
+
+	// Replicas send nothing beyond the auth frame, so a read only returns once
+	// the replica has disconnected, at which point the stream is no longer
+	// needed.
+	go func() {
+		io.Copy(io.Discard, conn)
+		a.unsubscribe(stream)
+	}()
+
+	w := bufio.NewWriter(conn)
+
+	if err := writeSnapshotFrame(w, seq, snapshot.Bytes()); err != nil {
+		return
+	}
+
+	if err := w.Flush(); err != nil {
+		return
+	}
+
+	for c := range stream.changes {
+		if err := writeChangeFrame(w, c); err != nil {
+			return
+		}
+
+		// Batch the queued changes into as few writes as possible.
+		if len(stream.changes) == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// authenticateReplica completes the TLS handshake of the replica on the
+// connection, if any. It then reads the auth frame of the replica, and
+// verifies its token, unless the ReplicationToken option is empty. The replica
+// is sent a denied frame if its token does not match.
+func (a *Arc) authenticateReplica(conn net.Conn) error {
+	conn.SetDeadline(time.Now().Add(replicationAuthTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	// Complete the handshake up front, so that replicas that do not speak
+	// TLS are disconnected instead of waiting for the snapshot forever.
+	if tc, ok := conn.(*tls.Conn); ok {
+		if err := tc.Handshake(); err != nil {
+			return err
+		}
+	}
+
+	if a.opts.ReplicationToken == "" {
+		return nil
+	}
+
+	token, err := readAuthFrame(conn)
+
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare(token, []byte(a.opts.ReplicationToken)) != 1 {
+		conn.Write([]byte{frameDenied})
+		return ErrPermission
+	}
+
+	return nil
+}
+
+// subscribe registers the stream to receive the changes.
+func (a *Arc) subscribe(stream *replicationStream) {
+	a.replicasMu.Lock()
+	defer a.replicasMu.Unlock()
+
+	if a.replicas == nil {
+		a.replicas = map[*replicationStream]struct{}{}
+	}
+
+	a.replicas[stream] = struct{}{}
+}
+
+// unsubscribe removes the stream, and closes its channel. It is safe to call
+// more than once.
+func (a *Arc) unsubscribe(stream *replicationStream) {
+	a.replicasMu.Lock()
+	defer a.replicasMu.Unlock()
+
+	if _, found := a.replicas[stream]; found {
+		delete(a.replicas, stream)
+		close(stream.changes)
+	}
+}
+
+// publishChange queues the write that was just applied for every replica. The
+// caller must hold the write lock.
+func (a *Arc) publishChange(op Op, key []byte, value []byte) {
+	a.replicasMu.Lock()
+	defer a.replicasMu.Unlock()
+
+	if len(a.replicas) == 0 {
+		return
+	}
+
+	c := change{seq: a.seq, op: op, key: bytes.Clone(key), value: bytes.Clone(value)}
+
+	for stream := range a.replicas {
+		select {
+		case stream.changes <- c:
+		default:
+			// The replica is too far behind to catch up from the queue.
+			delete(a.replicas, stream)
+			close(stream.changes)
+		}
+	}
+}
+
+// NewReplica connects to the primary that serves replication at the given TCP
+// address with the default options, and returns a read-only database that
+// follows it. Writes to the replica fail with ErrReadOnly. The replica stops
+// following once it is disconnected, after which it continues to serve the
+// data it has replicated so far.
+func NewReplica(addr string) (*Arc, error) {
+	return NewReplicaWithOptions(addr, Options{})
+}
+
+// NewReplicaWithOptions is like NewReplica, but configures the replica with
+// the given options. The EncryptionProvider must match that of the primary.
+// The ExpirationSweepInterval is ignored, since expired records are deleted
+// by the primary. The replica connects with TLS if the ReplicationTLS option
+// is set, and presents the ReplicationToken if it is set. Returns
+// ErrPermission if the primary rejects the token.
+func NewReplicaWithOptions(addr string, opts Options) (*Arc, error) {
+	ret, err := newArc(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn
+
+	if opts.ReplicationTLS != nil {
+		conn, err = tls.Dial("tcp", addr, opts.ReplicationTLS)
+	} else {
+		conn, err = net.Dial("tcp", addr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeAuthFrame(conn, opts.ReplicationToken); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	seq, snapshot, err := readSnapshotFrame(r)
+
+	if err == nil {
+		profile("load", func() {
+			err = ret.readSnapshot(snapshot)
+		})
+	}
+
+	if err != nil {
+		ret.log(slog.LevelWarn, "failed to load the snapshot of the primary", "addr", addr, "err", err)
+		conn.Close()
+		return nil, err
+	}
+
+	ret.log(slog.LevelDebug, "loaded the snapshot of the primary", "addr", addr, "seq", seq, "records", ret.numRecords)
+	ret.seq = seq
+	ret.readOnly = true
+	ret.follower = &follower{conn: conn, done: make(chan struct{})}
+
+	go ret.follow(r)
+
+	return ret, nil
+}
+
+// follow applies the changes from the primary until the stream ends.
+func (a *Arc) follow(r *bufio.Reader) {
+	defer close(a.follower.done)
+	defer a.follower.conn.Close()
+
+	for {
+		c, err := readChangeFrame(r)
+
+		if err == nil {
+			err = a.applyChange(c)
+		}
+
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				a.log(slog.LevelWarn, "stopped following the primary", "addr", a.follower.conn.RemoteAddr().String(), "seq", a.Seq(), "err", err)
+			}
+
+			a.follower.err = err
+			return
+		}
+	}
+}
+
+// applyChange applies the change received from the primary. Changes must be
+// applied in sequence, since every write increments the sequence number.
+func (a *Arc) applyChange(c change) error {
+	a.lock()
+	defer a.mu.Unlock()
+
+	if c.seq != a.seq+1 {
+		return ErrCorrupted
+	}
+
+	switch c.op {
+	case OpPut:
+		return a.putRecord(c.key, c.value, true)
+	case OpDelete:
+		return a.deleteRecord(c.key)
+	case OpExpire:
+		t, err := decodeExpiration(c.value)
+
+		if err != nil {
+			return err
+		}
+
+		return a.expireRecord(c.key, t)
+	case OpSetFlags:
+		if len(c.value) != sizeOfUint8 {
+			return ErrCorrupted
+		}
+
+		return a.setRecordFlags(c.key, c.value[0])
+	case OpRename:
+		return a.renameRecord(c.key, c.value)
+	case OpMovePrefix:
+		return a.movePrefix(c.key, c.value)
+	default:
+		return ErrCorrupted
+	}
+}
+
+// stopReplication disconnects the replicas, and stops following the primary.
+// It returns the error that had stopped the follower, unless it was caused by
+// the disconnection.
+func (a *Arc) stopReplication() error {
+	a.replicasMu.Lock()
+
+	for stream := range a.replicas {
+		delete(a.replicas, stream)
+		close(stream.changes)
+	}
+
+	a.replicasMu.Unlock()
+
+	if a.follower == nil {
+		return nil
+	}
+
+	a.follower.conn.Close()
+	<-a.follower.done
+
+	if errors.Is(a.follower.err, net.ErrClosed) {
+		return nil
+	}
+
+	return a.follower.err
+}
+
+// writeSnapshotFrame writes a frame that holds the serialized database as of
+// the given sequence number.
+func writeSnapshotFrame(w io.Writer, seq uint64, snapshot []byte) error {
+	header := make([]byte, 0, sizeOfUint8+sizeOfUint64+sizeOfUint64)
+	header = append(header, frameSnapshot)
+	header = binary.LittleEndian.AppendUint64(header, seq)
+	header = binary.LittleEndian.AppendUint64(header, uint64(len(snapshot)))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	_, err := w.Write(snapshot)
+
+	return err
+}
+
+// readSnapshotFrame reads the frame written by writeSnapshotFrame. Returns
+// ErrPermission if the primary sent a denied frame instead.
+func readSnapshotFrame(r io.Reader) (uint64, []byte, error) {
+	header := make([]byte, sizeOfUint8+sizeOfUint64+sizeOfUint64)
+
+	// The denied frame consists of its type only.
+	if _, err := io.ReadFull(r, header[:sizeOfUint8]); err != nil {
+		return 0, nil, err
+	}
+
+	if header[0] == frameDenied {
+		return 0, nil, ErrPermission
+	}
+
+	if header[0] != frameSnapshot {
+		return 0, nil, ErrCorrupted
+	}
+
+	if _, err := io.ReadFull(r, header[sizeOfUint8:]); err != nil {
+		return 0, nil, err
+	}
+
+	seq := binary.LittleEndian.Uint64(header[sizeOfUint8:])
+	snapshot := make([]byte, binary.LittleEndian.Uint64(header[sizeOfUint8+sizeOfUint64:]))
+
+	if _, err := io.ReadFull(r, snapshot); err != nil {
+		return 0, nil, err
+	}
+
+	return seq, snapshot, nil
+}
+
+// writeAuthFrame writes a frame that holds the token of a replica.
+func writeAuthFrame(w io.Writer, token string) error {
+	frame := make([]byte, 0, sizeOfUint8+sizeOfUint32+len(token))
+	frame = append(frame, frameAuth)
+	frame = binary.LittleEndian.AppendUint32(frame, uint32(len(token)))
+	frame = append(frame, token...)
+
+	_, err := w.Write(frame)
+
+	return err
+}
+
+// readAuthFrame reads the frame written by writeAuthFrame, and returns the
+// token.
+func readAuthFrame(r io.Reader) ([]byte, error) {
+	var frameType [sizeOfUint8]byte
+
+	if _, err := io.ReadFull(r, frameType[:]); err != nil {
+		return nil, err
+	}
+
+	if frameType[0] != frameAuth {
+		return nil, ErrPermission
+	}
+
+	return readLengthPrefixed(r, maxReplicationTokenLen)
+}
+
+// writeChangeFrame writes a frame that holds the change.
+func writeChangeFrame(w io.Writer, c change) error {
+	frame := make([]byte, 0, sizeOfUint8+sizeOfUint64+sizeOfUint8+sizeOfUint32+len(c.key)+sizeOfUint32+len(c.value))
+	frame = append(frame, frameChange)
+	frame = binary.LittleEndian.AppendUint64(frame, c.seq)
+	frame = append(frame, byte(c.op))
+	frame = binary.LittleEndian.AppendUint32(frame, uint32(len(c.key)))
+	frame = append(frame, c.key...)
+	frame = binary.LittleEndian.AppendUint32(frame, uint32(len(c.value)))
+	frame = append(frame, c.value...)
+
+	_, err := w.Write(frame)
+
+	return err
+}
+
+// readChangeFrame reads the frame written by writeChangeFrame.
+func readChangeFrame(r io.Reader) (change, error) {
+	var c change
+
+	header := make([]byte, sizeOfUint8+sizeOfUint64+sizeOfUint8)
+
+	if _, err := io.ReadFull(r, header); err != nil {
+		return c, err
+	}
+
+	if header[0] != frameChange {
+		return c, ErrCorrupted
+	}
+
+	c.seq = binary.LittleEndian.Uint64(header[sizeOfUint8:])
+	c.op = Op(header[sizeOfUint8+sizeOfUint64])
+
+	var err error
+
+	// Keys can be as long as values if the primary has LargeKeys set.
+	if c.key, err = readLengthPrefixed(r, maxValueBytes); err != nil {
+		return c, err
+	}
+
+	if c.value, err = readLengthPrefixed(r, maxValueBytes); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// readLengthPrefixed reads a byte slice that is prefixed by its uint32 length,
+// which must not exceed the limit.
+func readLengthPrefixed(r io.Reader, limit int) ([]byte, error) {
+	var length [sizeOfUint32]byte
+
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+
+	n := binary.LittleEndian.Uint32(length[:])
+
+	if uint64(n) > uint64(limit) {
+		return nil, ErrCorrupted
+	}
+
+	ret := make([]byte, n)
+
+	if _, err := io.ReadFull(r, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,222 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplication(t *testing.T) {
+	primary := New()
+	keys := sortedBasicTestKeys()
+
+	for _, key := range keys[:len(keys)/2] {
+		primary.Put([]byte(key), []byte(key))
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer l.Close()
+
+	go primary.ServeReplication(l)
+
+	replica, err := NewReplica(l.Addr().String())
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertSameRecords(t, replica, primary)
+
+	for _, key := range keys[len(keys)/2:] {
+		primary.Put([]byte(key), []byte(key))
+	}
+
+	primary.Put([]byte("blob"), blobValueX())
+	primary.Delete([]byte(keys[0]))
+	primary.Put([]byte(keys[1]), []byte("updated"))
+	primary.Expire([]byte(keys[2]), time.Hour)
+	primary.SetFlags([]byte(keys[3]), 0x01)
+	primary.Rename([]byte("blob"), []byte("renamed"))
+	primary.MovePrefix([]byte("lem"), []byte("melon"))
+
+	waitForReplica(t, replica, primary.Seq())
+	assertSameRecords(t, replica, primary)
+
+	if info, _ := replica.Stat([]byte(keys[2])); info.ExpiresAt.IsZero() {
+		t.Errorf("expected the expiration to be replicated")
+	}
+
+	if info, _ := replica.Stat([]byte(keys[3])); info.Flags != 0x01 {
+		t.Errorf("expected the flags to be replicated")
+	}
+
+	if err := replica.Put([]byte("key"), []byte("value")); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrReadOnly)
+	}
+
+	// The replica continues to serve reads once the primary is gone.
+	primary.Close()
+	<-replica.follower.done
+
+	if err := replica.Close(); err == nil {
+		t.Errorf("expected the disconnection to be reported")
+	}
+
+	assertSameRecords(t, replica, primary)
+}
+
+func TestReplicaClose(t *testing.T) {
+	primary := New()
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+
+	go primary.ServeReplication(l)
+
+	replica, err := NewReplica(l.Addr().String())
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := replica.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Writes continue once the replica is gone.
+	if err := primary.Put([]byte("key"), []byte("value")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func waitForReplica(t *testing.T, replica *Arc, seq uint64) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for replica.Seq() != seq {
+		if time.Now().After(deadline) {
+			t.Fatalf("replica did not catch up: got:%d, want:%d", replica.Seq(), seq)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReplicationToken(t *testing.T) {
+	primary, _ := NewWithOptions(Options{ReplicationToken: "secret"})
+	primary.Put([]byte("key"), []byte("value"))
+
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+
+	go primary.ServeReplication(l)
+
+	for _, token := range []string{"", "wrong"} {
+		if _, err := NewReplicaWithOptions(l.Addr().String(), Options{ReplicationToken: token}); err == nil {
+			t.Errorf("expected the token %q to be rejected", token)
+		}
+	}
+
+	if _, err := NewReplicaWithOptions(l.Addr().String(), Options{ReplicationToken: "wrong"}); !errors.Is(err, ErrPermission) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrPermission)
+	}
+
+	replica, err := NewReplicaWithOptions(l.Addr().String(), Options{ReplicationToken: "secret"})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer replica.Close()
+
+	assertSameRecords(t, replica, primary)
+
+	if _, err := NewWithOptions(Options{ReplicationToken: strings.Repeat("x", maxReplicationTokenLen+1)}); err != ErrInvalidOptions {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrInvalidOptions)
+	}
+}
+
+func TestReplicationTLS(t *testing.T) {
+	cert, pool := newTestCertificate(t)
+
+	primary, _ := NewWithOptions(Options{ReplicationTLS: &tls.Config{Certificates: []tls.Certificate{cert}}})
+	primary.Put([]byte("key"), []byte("value"))
+
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+
+	go primary.ServeReplication(l)
+
+	// Replicas that do not trust the certificate, or do not speak TLS, fail
+	// to connect.
+	if _, err := NewReplicaWithOptions(l.Addr().String(), Options{ReplicationTLS: &tls.Config{}}); err == nil {
+		t.Errorf("expected an untrusted certificate to be rejected")
+	}
+
+	if _, err := NewReplica(l.Addr().String()); err == nil {
+		t.Errorf("expected a plaintext replica to be rejected")
+	}
+
+	replica, err := NewReplicaWithOptions(l.Addr().String(), Options{ReplicationTLS: &tls.Config{RootCAs: pool}})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer replica.Close()
+
+	primary.Put([]byte("blob"), blobValueX())
+	waitForReplica(t, replica, primary.Seq())
+	assertSameRecords(t, replica, primary)
+}
+
+// newTestCertificate returns a self-signed certificate for 127.0.0.1, along
+// with a pool that trusts it.
+func newTestCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "arc"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	leaf, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
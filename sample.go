@@ -0,0 +1,120 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import "math/rand/v2"
+
+// SampleKeys returns a uniform random sample of up to n distinct record keys,
+// in no particular order, which allows the dataset to be analyzed without
+// reading every record. Every record is equally likely to be sampled. When
+// Options.TrackPrefixCounts is enabled, the records are picked by their rank,
+// and located by descending the tree weighted by the subtree record counts,
+// which takes time proportional to n rather than to the number of records.
+// Otherwise the tree is walked once. Expired records, and the records that
+// the Authorizer does not allow to be read, are left out of the sample, which
+// may then hold fewer than n keys. It returns nil if n is not positive.
+func (a *Arc) SampleKeys(n int) [][]byte {
+	a.rlock()
+	defer a.runlock()
+
+	if n <= 0 || a.empty() {
+		return nil
+	}
+
+	var sample [][]byte
+
+	if a.opts.TrackPrefixCounts {
+		for rank := range sampleRanks(a.numRecords, n) {
+			if key, _ := recordAt(a.root, rank); key != nil {
+				sample = append(sample, key)
+			}
+		}
+	} else {
+		sample = a.reservoirSample(n)
+	}
+
+	ret := sample[:0]
+
+	for _, key := range sample {
+		if a.visible(key) {
+			ret = append(ret, a.originalKey(key))
+		}
+	}
+
+	return ret
+}
+
+// sampleRanks returns min(n, total) distinct ranks picked uniformly at random
+// from [0, total), using Floyd's algorithm.
+func sampleRanks(total int, n int) map[int]struct{} {
+	n = min(n, total)
+	ret := make(map[int]struct{}, n)
+
+	for i := total - n; i < total; i++ {
+		rank := rand.IntN(i + 1)
+
+		if _, found := ret[rank]; found {
+			rank = i
+		}
+
+		ret[rank] = struct{}{}
+	}
+
+	return ret
+}
+
+// recordAt returns the full key and the node of the record at the given rank
+// in the subtree of n, counting from zero in walk order. It relies on the
+// subtree record counts, and returns nil if the rank is out of range.
+func recordAt(n *node, rank int) ([]byte, *node) {
+	key := fullKey(nil, n)
+
+	for {
+		if n.isRecord {
+			if rank == 0 {
+				return key, n
+			}
+
+			rank--
+		}
+
+		next := n.firstChild
+
+		for ; next != nil; next = next.nextSibling {
+			if rank < int(next.subtreeRecords) {
+				break
+			}
+
+			rank -= int(next.subtreeRecords)
+		}
+
+		if next == nil {
+			return nil, nil
+		}
+
+		n = next
+		key = fullKey(key, n)
+	}
+}
+
+// reservoirSample returns up to n record keys picked uniformly at random by a
+// single walk of the tree. The caller must hold the read lock.
+func (a *Arc) reservoirSample(n int) [][]byte {
+	var ret [][]byte
+	var seen int
+
+	a.walkPrefix(nil, func(key []byte, _ *node) bool {
+		seen++
+
+		if len(ret) < n {
+			ret = append(ret, key)
+		} else if i := rand.IntN(seen); i < n {
+			ret[i] = key
+		}
+
+		return true
+	})
+
+	return ret
+}
@@ -0,0 +1,116 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSampleKeys(t *testing.T) {
+	for _, opts := range []Options{{}, {TrackPrefixCounts: true}} {
+		arc, _ := NewWithOptions(opts)
+
+		if got := arc.SampleKeys(3); got != nil {
+			t.Errorf("unexpected sample of an empty database: %q", got)
+		}
+
+		for _, key := range sortedBasicTestKeys() {
+			arc.Put([]byte(key), []byte(key))
+		}
+
+		sample := arc.SampleKeys(3)
+
+		if len(sample) != 3 {
+			t.Fatalf("unexpected sample size: got:%d, want:3", len(sample))
+		}
+
+		slices.SortFunc(sample, bytes.Compare)
+
+		if len(slices.CompactFunc(sample, bytes.Equal)) != 3 {
+			t.Errorf("expected distinct keys: %q", sample)
+		}
+
+		for _, key := range sample {
+			if found, _ := arc.Has(key); !found {
+				t.Errorf("unexpected key: %q", key)
+			}
+		}
+
+		// A sample larger than the database holds every key.
+		all := arc.SampleKeys(arc.Len() + 10)
+		slices.SortFunc(all, bytes.Compare)
+
+		assertKeys(t, toStrings(all), sortedBasicTestKeys())
+
+		if got := arc.SampleKeys(0); got != nil {
+			t.Errorf("unexpected sample: %q", got)
+		}
+	}
+}
+
+func TestSampleKeysUniform(t *testing.T) {
+	const records = 10
+	const rounds = 5000
+
+	for _, opts := range []Options{{}, {TrackPrefixCounts: true}} {
+		arc, _ := NewWithOptions(opts)
+
+		// Nested keys give the records subtrees of different sizes, which
+		// must not bias the sample.
+		for i := range records {
+			arc.Put(bytes.Repeat([]byte("a"), i+1), nil)
+		}
+
+		counts := map[string]int{}
+
+		for range rounds {
+			for _, key := range arc.SampleKeys(2) {
+				counts[string(key)]++
+			}
+		}
+
+		// Every key is expected in a fifth of the samples.
+		for key, count := range counts {
+			if want := rounds * 2 / records; count < want*8/10 || count > want*12/10 {
+				t.Errorf("unexpected frequency of %q with %+v: got:%d, want:%d", key, opts, count, want)
+			}
+		}
+
+		if len(counts) != records {
+			t.Errorf("expected every key to be sampled: %v", counts)
+		}
+	}
+}
+
+func TestSampleKeysExpired(t *testing.T) {
+	arc, _ := NewWithOptions(Options{TrackPrefixCounts: true})
+
+	for i := range 5 {
+		arc.Put([]byte(fmt.Sprintf("key%d", i)), nil)
+	}
+
+	arc.ExpireAt([]byte("key0"), time.Now().Add(-time.Second))
+
+	for range 20 {
+		for _, key := range arc.SampleKeys(5) {
+			if string(key) == "key0" {
+				t.Fatalf("unexpected expired key in the sample")
+			}
+		}
+	}
+}
+
+func toStrings(keys [][]byte) []string {
+	ret := make([]string, len(keys))
+
+	for i, key := range keys {
+		ret[i] = string(key)
+	}
+
+	return ret
+}
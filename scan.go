@@ -0,0 +1,435 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"iter"
+	"regexp"
+	"slices"
+
+	"github.com/chronohq/arc/arckey"
+)
+
+// Direction is the order in which an iterator yields records.
+type Direction int
+
+const (
+	// Forward yields records in ascending lexicographic key order, or in
+	// descending order if Options.ReverseOrder is set.
+	Forward Direction = iota
+
+	// Reverse yields records in the opposite order of Forward.
+	Reverse
+)
+
+// Consistency is the consistency guarantee of an iterator with respect to the
+// writes that are made while the iteration is in progress.
+type Consistency int
+
+const (
+	// Snapshot iterators yield a stable view of the records as of the start
+	// of the iteration. The matching records are captured up front, which
+	// costs memory proportional to the size of the iteration, but writers
+	// are only blocked while the records are captured. This is the default.
+	Snapshot Consistency = iota
+
+	// Locked iterators hold the read lock for the entire iteration, which
+	// provides a stable view without capturing the records, but blocks all
+	// writers until the iteration ends. The loop body must not call any
+	// method of the database, since doing so may deadlock.
+	Locked
+
+	// Relaxed iterators capture the records in chunks, and release the read
+	// lock between chunks. Memory usage is bounded and writers are never
+	// blocked for long, but writes made during the iteration may or may not
+	// be observed. Each key is yielded at most once, in order.
+	Relaxed
+)
+
+// relaxedChunkSize is the number of records that a Relaxed iterator captures
+// while holding the read lock.
+const relaxedChunkSize = 256
+
+// ScanOptions configures the iterator returned by ScanWithOptions.
+type ScanOptions struct {
+	// Prefix restricts the iteration to the records whose keys begin with
+	// the prefix. A nil prefix iterates over the entire database.
+	Prefix []byte
+
+	// Direction is the order in which records are yielded.
+	Direction Direction
+
+	// KeysOnly yields nil values, and skips reading the values altogether.
+	// This avoids resolving blobs and copying values when only the keys are
+	// of interest, such as when auditing or sweeping the keyspace.
+	KeysOnly bool
+
+	// Consistency is the guarantee with respect to concurrent writes.
+	Consistency Consistency
+}
+
+// record is a key-value pair captured from the tree. Cursors also capture the
+// user flags of the record.
+type record struct {
+	key   []byte
+	value []byte
+	flags uint8
+}
+
+// Scan returns an iterator over the records whose keys begin with the given
+// prefix, in lexicographic order. A nil prefix scans the entire database. The
+// records are captured when the iteration begins, therefore the loop body may
+// safely modify the database without affecting the iteration.
+func (a *Arc) Scan(prefix []byte) iter.Seq2[[]byte, []byte] {
+	return a.scan(ScanOptions{Prefix: prefix}, nil)
+}
+
+// ScanReverse is like Scan, but yields the records in reverse lexicographic
+// order.
+func (a *Arc) ScanReverse(prefix []byte) iter.Seq2[[]byte, []byte] {
+	return a.scan(ScanOptions{Prefix: prefix, Direction: Reverse}, nil)
+}
+
+// ScanRegexp is like Scan, but only yields the records whose full keys match
+// the given regular expression. The expression is evaluated during traversal,
+// therefore the values of non-matching records are never copied. Note that
+// the expression matches anywhere within the key unless it is anchored.
+func (a *Arc) ScanRegexp(prefix []byte, re *regexp.Regexp) iter.Seq2[[]byte, []byte] {
+	return a.scan(ScanOptions{Prefix: prefix}, re.Match)
+}
+
+// Keys returns an iterator over the keys that begin with the given prefix, in
+// lexicographic order. It is equivalent to ScanWithOptions with KeysOnly set.
+func (a *Arc) Keys(prefix []byte) iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		for key := range a.scan(ScanOptions{Prefix: prefix, KeysOnly: true}, nil) {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// ScanChildren returns an iterator over the immediate children of the given
+// prefix in a hierarchical keyspace, whose keys are built using
+// arckey.JoinPath. The prefix is typically built using arckey.PathPrefix, and
+// a nil prefix lists the top level. Records whose key has no separator after
+// the prefix are yielded with their values. Deeper records are represented by
+// their subtree, which is yielded once as the key of the child followed by a
+// separator, along with a nil value. Subtrees are skipped without visiting
+// their records. The children are captured when the iteration begins, like
+// with Scan.
+func (a *Arc) ScanChildren(prefix []byte) iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		prefix := a.transformKey(prefix)
+
+		a.rlock()
+		children := a.collectChildren(prefix, arckey.IndexPathSeparator)
+		a.runlock()
+
+		if a.opts.ReverseOrder {
+			slices.Reverse(children)
+		}
+
+		for _, r := range children {
+			if !yield(r.key, r.value) {
+				return
+			}
+		}
+	}
+}
+
+// collectChildren captures the immediate children of the given prefix, where
+// index returns the position of the delimiter that ends the child key, or -1
+// if the key has none. The caller must hold the read lock.
+func (a *Arc) collectChildren(prefix []byte, index func(key []byte) int) []record {
+	var ret []record
+
+	if a.empty() {
+		return nil
+	}
+
+	target := prefix
+	inclusive := true
+
+	for {
+		key, n := ceilingRecord(a.root, nil, target, inclusive)
+
+		if n == nil || !bytes.HasPrefix(key, prefix) {
+			break
+		}
+
+		// Subtrees that only hold expired records are skipped entirely,
+		// since they are entered at their first unexpired record.
+		if a.expired(key) {
+			target, inclusive = key, false
+			continue
+		}
+
+		i := index(key[len(prefix):])
+
+		if i < 0 {
+			ret = append(ret, a.scanRecord(ScanOptions{}, key, n))
+			target, inclusive = key, false
+
+			continue
+		}
+
+		// Folding the case preserves the length of the key.
+		subtree := key[:len(prefix)+i+1]
+		ret = append(ret, record{key: a.originalKey(key)[:len(subtree)]})
+
+		// Resume after the last key of the subtree.
+		if target, inclusive = arckey.PrefixEnd(subtree), true; target == nil {
+			break
+		}
+	}
+
+	return ret
+}
+
+// Entry is an immediate child listed by Children.
+type Entry struct {
+	Name        []byte // Segment that follows the prefix, without the delimiter.
+	IsRecord    bool   // True if the prefix followed by Name is a record key.
+	HasChildren bool   // True if deeper keys begin with the segment and the delimiter.
+}
+
+// Children lists the distinct segments that follow the given prefix, up to
+// the next occurrence of the delimiter, in the order of the database. Deeper keys
+// are collapsed into the segment that leads to them, like the delimiter
+// listing of S3, which allows the keyspace to be browsed like a file system.
+// Unlike ScanChildren, the delimiter is never escaped. Collapsed subtrees are
+// skipped without visiting their records. It returns ErrKeyTooLarge if the
+// prefix exceeds the key size limit.
+func (a *Arc) Children(prefix []byte, delim byte) ([]Entry, error) {
+	prefix = a.transformKey(prefix)
+
+	if len(prefix) > a.opts.MaxKeyBytes {
+		return nil, &SizeError{Err: ErrKeyTooLarge, Size: len(prefix), Limit: a.opts.MaxKeyBytes}
+	}
+
+	a.rlock()
+	children := a.collectChildren(prefix, func(key []byte) int {
+		return bytes.IndexByte(key, delim)
+	})
+	a.runlock()
+
+	var ret []Entry
+
+	positions := map[string]int{}
+
+	for _, r := range children {
+		// Collapsed subtrees end with the delimiter, which records lack.
+		name := r.key[len(prefix):]
+		isRecord := bytes.IndexByte(name, delim) < 0
+
+		if !isRecord {
+			name = name[:len(name)-1]
+		}
+
+		i, found := positions[string(name)]
+
+		if !found {
+			i = len(ret)
+			positions[string(name)] = i
+			ret = append(ret, Entry{Name: name})
+		}
+
+		if isRecord {
+			ret[i].IsRecord = true
+		} else {
+			ret[i].HasChildren = true
+		}
+	}
+
+	// Subtrees are visited in the order of their keys, which includes the
+	// delimiter, and may therefore come after longer names.
+	slices.SortFunc(ret, func(x, y Entry) int {
+		return a.compareKeys(x.Name, y.Name)
+	})
+
+	return ret, nil
+}
+
+// ScanInfo returns an iterator over the metadata of the records whose keys
+// begin with the given prefix, in the order of the database. Like Stat, it
+// does not materialize the values, which allows records to be selected by
+// their size or user flags cheaply. The metadata is captured when the
+// iteration begins, like Scan.
+func (a *Arc) ScanInfo(prefix []byte) iter.Seq[RecordInfo] {
+	prefix = a.transformKey(prefix)
+	opts := ScanOptions{Prefix: prefix, Direction: a.orderedDirection(Forward)}
+
+	return func(yield func(RecordInfo) bool) {
+		var infos []RecordInfo
+
+		a.rlock()
+		a.walkFunc(opts, nil)(prefix, func(key []byte, n *node) bool {
+			if a.visible(key) {
+				infos = append(infos, a.recordInfo(key, n))
+			}
+
+			return true
+		})
+		a.runlock()
+
+		for _, info := range infos {
+			if !yield(info) {
+				return
+			}
+		}
+	}
+}
+
+// ScanWithOptions returns an iterator over the records selected by the given
+// options. It otherwise behaves like Scan.
+func (a *Arc) ScanWithOptions(opts ScanOptions) iter.Seq2[[]byte, []byte] {
+	return a.scan(opts, nil)
+}
+
+// scan returns an iterator over the records selected by the options for which
+// match returns true. A nil match function matches every record.
+func (a *Arc) scan(opts ScanOptions, match func(key []byte) bool) iter.Seq2[[]byte, []byte] {
+	opts.Prefix = a.transformKey(opts.Prefix)
+	opts.Direction = a.orderedDirection(opts.Direction)
+	match = a.scanMatch(match)
+
+	switch opts.Consistency {
+	case Locked:
+		return a.scanLocked(opts, match)
+	case Relaxed:
+		return a.scanRelaxed(opts, match)
+	default:
+		return a.scanSnapshot(opts, match)
+	}
+}
+
+// scanMatch returns the match function that additionally excludes the expired
+// records, and the records that the Authorizer does not allow to be read. A
+// nil match function matches every record.
+func (a *Arc) scanMatch(match func(key []byte) bool) func(key []byte) bool {
+	return func(key []byte) bool {
+		return (match == nil || match(key)) && a.visible(key)
+	}
+}
+
+// scanSnapshot implements the Snapshot consistency.
+func (a *Arc) scanSnapshot(opts ScanOptions, match func(key []byte) bool) iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		a.rlock()
+		records, _ := a.collectRecords(opts, match, nil, 0)
+		a.runlock()
+
+		for _, r := range records {
+			if !yield(r.key, r.value) {
+				return
+			}
+		}
+	}
+}
+
+// scanLocked implements the Locked consistency.
+func (a *Arc) scanLocked(opts ScanOptions, match func(key []byte) bool) iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		a.rlock()
+		defer a.runlock()
+
+		a.walkFunc(opts, nil)(opts.Prefix, func(key []byte, n *node) bool {
+			if match != nil && !match(key) {
+				return true
+			}
+
+			r := a.scanRecord(opts, key, n)
+
+			return yield(r.key, r.value)
+		})
+	}
+}
+
+// scanRelaxed implements the Relaxed consistency.
+func (a *Arc) scanRelaxed(opts ScanOptions, match func(key []byte) bool) iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		var last []byte
+
+		for {
+			a.rlock()
+			records, more := a.collectRecords(opts, match, last, relaxedChunkSize)
+			a.runlock()
+
+			for _, r := range records {
+				if !yield(r.key, r.value) {
+					return
+				}
+			}
+
+			if !more {
+				return
+			}
+
+			// Resume after the key as stored in the tree, rather than the
+			// spelling that it was inserted with.
+			last = a.foldKey(records[len(records)-1].key)
+		}
+	}
+}
+
+// collectRecords captures up to limit records selected by the options, after
+// the given key in the scan direction if it is non-nil. A zero limit captures
+// every record. It returns true if the limit stopped the collection before
+// the end of the scan. The caller must hold the read lock.
+func (a *Arc) collectRecords(opts ScanOptions, match func(key []byte) bool, after []byte, limit int) ([]record, bool) {
+	var ret []record
+	var more bool
+
+	a.walkFunc(opts, after)(opts.Prefix, func(key []byte, n *node) bool {
+		if match != nil && !match(key) {
+			return true
+		}
+
+		if limit > 0 && len(ret) == limit {
+			more = true
+			return false
+		}
+
+		ret = append(ret, a.scanRecord(opts, key, n))
+
+		return true
+	})
+
+	return ret, more
+}
+
+// walkFunc returns the walk function that visits the records in the scan
+// direction, starting after the given key if it is non-nil.
+func (a *Arc) walkFunc(opts ScanOptions, after []byte) func([]byte, func([]byte, *node) bool) {
+	switch {
+	case opts.Direction == Reverse && after != nil:
+		return func(prefix []byte, fn func([]byte, *node) bool) {
+			a.walkPrefixBefore(prefix, after, fn)
+		}
+	case opts.Direction == Reverse:
+		return a.walkPrefixReverse
+	case after != nil:
+		return func(prefix []byte, fn func([]byte, *node) bool) {
+			a.walkPrefixAfter(prefix, after, fn)
+		}
+	default:
+		return a.walkPrefix
+	}
+}
+
+// scanRecord builds the record to yield for the given node. Blob contents are
+// not verified, since iterators have no means to report errors. Corruption is
+// detected by Get.
+func (a *Arc) scanRecord(opts ScanOptions, key []byte, n *node) record {
+	ret := record{key: a.originalKey(key)}
+
+	if !opts.KeysOnly {
+		ret.value = n.value(a.blobs)
+	}
+
+	return ret
+}
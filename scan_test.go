@@ -0,0 +1,514 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/chronohq/arc/arckey"
+)
+
+func TestScan(t *testing.T) {
+	arc := basicTestTree()
+	values := map[string][]byte{}
+
+	for _, row := range basicTestTreeData() {
+		values[string(row.key)] = row.data
+	}
+
+	var got []string
+
+	for key, value := range arc.Scan(nil) {
+		if want := values[string(key)]; !bytes.Equal(value, want) {
+			t.Errorf("unexpected value: got:%q, want:%q", value, want)
+		}
+
+		got = append(got, string(key))
+	}
+
+	assertKeys(t, got, sortedBasicTestKeys())
+
+	got = nil
+
+	for key := range arc.Scan([]byte("gr")) {
+		got = append(got, string(key))
+	}
+
+	assertKeys(t, got, []string{"grape", "grapefruit"})
+
+	// The loop body must be able to modify the database.
+	for key := range arc.Scan([]byte("l")) {
+		if err := arc.Delete(key); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	for key := range arc.Scan([]byte("l")) {
+		t.Errorf("unexpected key: %q", key)
+	}
+
+	// Breaking out of the loop must stop the iteration.
+	var count int
+
+	for range arc.Scan(nil) {
+		if count++; count == 3 {
+			break
+		}
+	}
+
+	if count != 3 {
+		t.Errorf("unexpected iteration count: got:%d, want:3", count)
+	}
+}
+
+func TestScanRegexp(t *testing.T) {
+	arc := basicTestTree()
+
+	testCases := []struct {
+		name   string
+		prefix []byte
+		re     *regexp.Regexp
+		want   []string
+	}{
+		{name: "anchored suffix", prefix: nil, re: regexp.MustCompile(`rry$`), want: []string{"berry", "blueberry"}},
+		{name: "with prefix", prefix: []byte("ap"), re: regexp.MustCompile(`^app.*t`), want: []string{"applet", "application"}},
+		{name: "spanning nodes", prefix: nil, re: regexp.MustCompile(`^band.+`), want: []string{"bandage", "bandsaw"}},
+		{name: "no match", prefix: []byte("l"), re: regexp.MustCompile(`x`), want: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+
+			for key := range arc.ScanRegexp(tc.prefix, tc.re) {
+				got = append(got, string(key))
+			}
+
+			assertKeys(t, got, tc.want)
+		})
+	}
+}
+
+func assertKeys(t *testing.T, got []string, want []string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("unexpected keys: got:%q, want:%q", got, want)
+	}
+
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("unexpected key: got:%q, want:%q", got[i], want[i])
+		}
+	}
+}
+
+func TestScanReverse(t *testing.T) {
+	arc := basicTestTree()
+
+	testCases := []struct {
+		prefix []byte
+		want   []string
+	}{
+		{prefix: nil, want: reversed(sortedBasicTestKeys())},
+		{prefix: []byte("ap"), want: []string{"apricot", "application", "applet", "apple"}},
+		{prefix: []byte("band"), want: []string{"bandsaw", "bandage", "band"}},
+		{prefix: []byte("bogus"), want: nil},
+	}
+
+	for _, tc := range testCases {
+		var got []string
+
+		for key := range arc.ScanReverse(tc.prefix) {
+			got = append(got, string(key))
+		}
+
+		assertKeys(t, got, tc.want)
+
+		got = nil
+
+		for key := range arc.ScanWithOptions(ScanOptions{Prefix: tc.prefix, Direction: Reverse}) {
+			got = append(got, string(key))
+		}
+
+		assertKeys(t, got, tc.want)
+	}
+}
+
+func reversed(src []string) []string {
+	ret := make([]string, len(src))
+
+	for i, s := range src {
+		ret[len(src)-1-i] = s
+	}
+
+	return ret
+}
+
+func TestScanKeysOnly(t *testing.T) {
+	arc := basicTestTree()
+	arc.Put([]byte("blob"), blobValueX())
+
+	var got []string
+
+	for key, value := range arc.ScanWithOptions(ScanOptions{Prefix: []byte("b"), KeysOnly: true}) {
+		if value != nil {
+			t.Errorf("unexpected value for %q: %q", key, value)
+		}
+
+		got = append(got, string(key))
+	}
+
+	want := []string{"banana", "band", "bandage", "bandsaw", "berry", "blob", "blueberry"}
+	assertKeys(t, got, want)
+
+	got = nil
+
+	for key := range arc.Keys([]byte("b")) {
+		got = append(got, string(key))
+	}
+
+	assertKeys(t, got, want)
+}
+
+func TestScanConsistency(t *testing.T) {
+	arc := New()
+	rng := rand.New(rand.NewSource(1))
+
+	// Use more records than a single relaxed chunk holds.
+	for i := 0; i < relaxedChunkSize*3; i++ {
+		key := randomKey(rng, "abcd", 8)
+		arc.Put(key, key)
+	}
+
+	for _, prefix := range []string{"", "a", "bc", "dddd", "zzz"} {
+		for _, dir := range []Direction{Forward, Reverse} {
+			var want []string
+
+			for key := range arc.ScanWithOptions(ScanOptions{Prefix: []byte(prefix), Direction: dir}) {
+				want = append(want, string(key))
+			}
+
+			for _, c := range []Consistency{Locked, Relaxed} {
+				var got []string
+
+				opts := ScanOptions{Prefix: []byte(prefix), Direction: dir, Consistency: c}
+
+				for key, value := range arc.ScanWithOptions(opts) {
+					if !bytes.Equal(key, value) {
+						t.Fatalf("unexpected value: got:%q, want:%q", value, key)
+					}
+
+					got = append(got, string(key))
+				}
+
+				assertKeys(t, got, want)
+			}
+		}
+	}
+}
+
+// assertScanOrder verifies that the iterators of the database yield exactly
+// the keys of the reference set, in ascending byte order.
+func assertScanOrder(t *testing.T, arc *Arc, ref map[string]bool) {
+	t.Helper()
+
+	want := make([]string, 0, len(ref))
+
+	for key := range ref {
+		want = append(want, key)
+	}
+
+	sort.Strings(want)
+
+	for _, c := range []Consistency{Snapshot, Locked, Relaxed} {
+		var got []string
+
+		for key := range arc.ScanWithOptions(ScanOptions{Consistency: c}) {
+			got = append(got, string(key))
+		}
+
+		assertKeys(t, got, want)
+		got = nil
+
+		for key := range arc.ScanWithOptions(ScanOptions{Direction: Reverse, Consistency: c}) {
+			got = append(got, string(key))
+		}
+
+		slices.Reverse(got)
+		assertKeys(t, got, want)
+	}
+
+	// Prefix scans yield the matching subsequence of the reference.
+	for _, prefix := range []string{"\x00", "a", "\x80\xff", "\xff\xff\xff"} {
+		var got, sub []string
+
+		for key := range arc.Scan([]byte(prefix)) {
+			got = append(got, string(key))
+		}
+
+		for _, key := range want {
+			if strings.HasPrefix(key, prefix) {
+				sub = append(sub, key)
+			}
+		}
+
+		assertKeys(t, got, sub)
+	}
+
+	if len(want) == 0 {
+		return
+	}
+
+	if key, _, err := arc.Min(); err != nil || string(key) != want[0] {
+		t.Errorf("unexpected min: got:%q, want:%q, err:%v", key, want[0], err)
+	}
+
+	if key, _, err := arc.Max(); err != nil || string(key) != want[len(want)-1] {
+		t.Errorf("unexpected max: got:%q, want:%q, err:%v", key, want[len(want)-1], err)
+	}
+}
+
+func TestScanOrder(t *testing.T) {
+	// The alphabet includes the bytes at the edges of the signed and the
+	// unsigned ranges, which must sort as unsigned bytes. Short keys over a
+	// small alphabet are often prefixes of one another, which exercises the
+	// splitting of nodes on insertion and the merging of nodes on deletion.
+	const alphabet = "\x00a\x7f\x80\xff"
+
+	for seed := int64(1); seed <= 20; seed++ {
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			rng := rand.New(rand.NewSource(seed))
+			arc := New()
+			ref := map[string]bool{}
+
+			// Duplicate insertions overwrite the records in place.
+			for range 300 {
+				key := randomKey(rng, alphabet, 6)
+
+				if err := arc.Put(key, key); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				ref[string(key)] = true
+			}
+
+			assertScanOrder(t, arc, ref)
+
+			for key := range ref {
+				if rng.Intn(2) == 0 {
+					continue
+				}
+
+				if err := arc.Delete([]byte(key)); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				delete(ref, key)
+			}
+
+			assertScanOrder(t, arc, ref)
+
+			// Reinsert a mix of deleted and new keys into the merged tree.
+			for range 150 {
+				key := randomKey(rng, alphabet, 8)
+				arc.Put(key, key)
+				ref[string(key)] = true
+			}
+
+			assertScanOrder(t, arc, ref)
+			assertScanOrder(t, arc.Clone(), ref)
+		})
+	}
+}
+
+func TestScanRelaxedWithWrites(t *testing.T) {
+	arc := New()
+	rng := rand.New(rand.NewSource(2))
+
+	for i := 0; i < relaxedChunkSize*4; i++ {
+		arc.Put(randomKey(rng, "abcd", 8), nil)
+	}
+
+	for _, dir := range []Direction{Forward, Reverse} {
+		var prev []byte
+
+		opts := ScanOptions{Direction: dir, Consistency: Relaxed}
+
+		for key := range arc.ScanWithOptions(opts) {
+			// Keys must be strictly ordered even though the tree is being
+			// restructured by the loop body.
+			if prev != nil {
+				if cmp := bytes.Compare(prev, key); (dir == Forward && cmp >= 0) || (dir == Reverse && cmp <= 0) {
+					t.Fatalf("unexpected order: %q then %q", prev, key)
+				}
+			}
+
+			prev = key
+
+			arc.Delete(key)
+			arc.Put(randomKey(rng, "abcd", 8), nil)
+		}
+	}
+}
+
+func TestScanRelaxedCaseInsensitive(t *testing.T) {
+	arc, _ := NewWithOptions(Options{CaseInsensitiveKeys: true})
+
+	for i := 0; i < relaxedChunkSize*2; i++ {
+		arc.Put([]byte(fmt.Sprintf("Key%04d", i)), nil)
+	}
+
+	var count int
+
+	// The chunks resume after the stored key, which is folded, rather than
+	// the spelling of the key, which sorts before every stored key.
+	for range arc.ScanWithOptions(ScanOptions{Consistency: Relaxed}) {
+		count++
+	}
+
+	if count != relaxedChunkSize*2 {
+		t.Errorf("unexpected number of records: got:%d, want:%d", count, relaxedChunkSize*2)
+	}
+}
+
+func TestScanChildren(t *testing.T) {
+	arc := New()
+
+	path := func(segments ...string) []byte {
+		var ret [][]byte
+
+		for _, s := range segments {
+			ret = append(ret, []byte(s))
+		}
+
+		return arckey.JoinPath(ret...)
+	}
+
+	arc.Put(path("etc"), []byte("etc"))
+	arc.Put(path("etc", "hosts"), []byte("hosts"))
+	arc.Put(path("etc", "ssh", "sshd_config"), []byte("sshd"))
+	arc.Put(path("etc", "ssh", "keys", "host"), []byte("key"))
+	arc.Put(path("etc", "a/b"), []byte("escaped"))
+	arc.Put(path("usr", "bin", "go"), []byte("go"))
+	arc.Put(path("var"), []byte("var"))
+
+	testCases := []struct {
+		name   string
+		prefix []byte
+		keys   []string
+		values []string
+	}{
+		{
+			name:   "top level",
+			prefix: nil,
+			keys:   []string{"etc", "etc/", "usr/", "var"},
+			values: []string{"etc", "", "", "var"},
+		},
+		{
+			name:   "nested",
+			prefix: arckey.PathPrefix([]byte("etc")),
+			keys:   []string{`etc/a\/b`, "etc/hosts", "etc/ssh/"},
+			values: []string{"escaped", "hosts", ""},
+		},
+		{
+			name:   "deeply nested",
+			prefix: arckey.PathPrefix([]byte("etc"), []byte("ssh")),
+			keys:   []string{"etc/ssh/keys/", "etc/ssh/sshd_config"},
+			values: []string{"", "sshd"},
+		},
+		{
+			name:   "missing",
+			prefix: arckey.PathPrefix([]byte("opt")),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var keys, values []string
+
+			for key, value := range arc.ScanChildren(tc.prefix) {
+				keys = append(keys, string(key))
+				values = append(values, string(value))
+			}
+
+			assertKeys(t, keys, tc.keys)
+			assertKeys(t, values, tc.values)
+		})
+	}
+}
+
+func TestChildren(t *testing.T) {
+	arc := New()
+
+	for _, key := range []string{"etc/hosts", "etc/ssh/sshd_config", "etc-x", "etc/ssh", "usr/bin/go", "var"} {
+		arc.Put([]byte(key), []byte(key))
+	}
+
+	testCases := []struct {
+		name   string
+		prefix string
+		want   []Entry
+	}{
+		{
+			name:   "top level",
+			prefix: "",
+			want: []Entry{
+				{Name: []byte("etc"), HasChildren: true},
+				{Name: []byte("etc-x"), IsRecord: true},
+				{Name: []byte("usr"), HasChildren: true},
+				{Name: []byte("var"), IsRecord: true},
+			},
+		},
+		{
+			name:   "nested",
+			prefix: "etc/",
+			want: []Entry{
+				{Name: []byte("hosts"), IsRecord: true},
+				{Name: []byte("ssh"), IsRecord: true, HasChildren: true},
+			},
+		},
+		{
+			name:   "partial segment",
+			prefix: "etc/h",
+			want:   []Entry{{Name: []byte("osts"), IsRecord: true}},
+		},
+		{
+			name:   "missing",
+			prefix: "opt/",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := arc.Children([]byte(tc.prefix), '/')
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(got) != len(tc.want) {
+				t.Fatalf("unexpected entries: got:%+v, want:%+v", got, tc.want)
+			}
+
+			for i := range got {
+				if !bytes.Equal(got[i].Name, tc.want[i].Name) || got[i].IsRecord != tc.want[i].IsRecord || got[i].HasChildren != tc.want[i].HasChildren {
+					t.Errorf("unexpected entry: got:%+v, want:%+v", got[i], tc.want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := New().Children(make([]byte, maxKeyBytes+1), '/'); !errors.Is(err, ErrKeyTooLarge) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyTooLarge)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"hash/crc32"
+	"io"
+	"sort"
 )
 
 const (
@@ -11,7 +13,7 @@ const (
 	magicByte = byte(0x41)
 
 	// fileFormatVersion is the database file format version.
-	fileFormatVersion = uint8(1)
+	fileFormatVersion = uint8(11)
 
 	// sizeOfUint8 is the size of uint8 in bytes.
 	sizeOfUint8 = 1
@@ -31,14 +33,29 @@ const (
 	// minNodeBytesLen is the minimum length of a serialized node.
 	minNodeBytesLen = sizeOfUint8 + sizeOfUint16 + sizeOfUint16 + sizeOfUint32 + sizeOfUint64 + sizeOfUint64
 
-	// arcHeaderBytesLen is the length of the arc file header.
-	arcHeaderBytesLen = sizeOfUint8 + sizeOfUint8 + sizeOfUint8 + checksumLen
+	// arcHeaderBytesLen is the length of the arc file header, excluding the
+	// name of the key transform.
+	arcHeaderBytesLen = sizeOfUint8 + sizeOfUint8 + sizeOfUint8 + sizeOfUint8 + checksumLen
+
+	// legacyArcHeaderBytesLen is the length of the arc file header prior to
+	// version 3, which introduced the key transform.
+	legacyArcHeaderBytesLen = sizeOfUint8 + sizeOfUint8 + sizeOfUint8 + checksumLen
+
+	// keyTransformVersion is the first file format version that records the
+	// key transform in the header.
+	keyTransformVersion = uint8(3)
+
+	// metaVersion is the first file format version that records the user
+	// metadata in the header.
+	metaVersion = uint8(7)
 )
 
 // Index node flags.
 const (
-	flagIsRecord = 1 << iota // 0b00000001
-	flagHasBlob              // 0b00000010
+	flagIsRecord     = 1 << iota // 0b00000001
+	flagHasBlob                  // 0b00000010
+	flagHasUserFlags             // 0b00000100
+	flagEmptyValue               // 0b00001000
 )
 
 const (
@@ -47,9 +64,11 @@ const (
 )
 
 type arcHeader struct {
-	magic   byte
-	version byte
-	status  byte
+	magic        byte
+	version      byte
+	status       byte
+	keyTransform string            // Name of the KeyTransform, if any.
+	meta         map[string][]byte // User metadata set by SetMeta.
 }
 
 func newArcHeader() arcHeader {
@@ -67,6 +86,21 @@ func (ah *arcHeader) serialize() ([]byte, error) {
 	buf.WriteByte(ah.version)
 	buf.WriteByte(ah.status)
 
+	if ah.version >= keyTransformVersion {
+		if len(ah.keyTransform) > maxKeyTransformNameLen {
+			return nil, ErrInvalidOptions
+		}
+
+		buf.WriteByte(byte(len(ah.keyTransform)))
+		buf.WriteString(ah.keyTransform)
+	}
+
+	if ah.version >= metaVersion {
+		meta := ah.serializeMeta()
+		binary.Write(&buf, binary.LittleEndian, uint32(len(meta)))
+		buf.Write(meta)
+	}
+
 	checksum, err := computeChecksum(buf.Bytes())
 
 	if err != nil {
@@ -80,32 +114,152 @@ func (ah *arcHeader) serialize() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// len returns the length of the serialized header in bytes.
+func (ah *arcHeader) len() int {
+	if ah.version < keyTransformVersion {
+		return legacyArcHeaderBytesLen
+	}
+
+	ret := arcHeaderBytesLen + len(ah.keyTransform)
+
+	if ah.version >= metaVersion {
+		ret += sizeOfUint32
+		ret += len(ah.meta) * (sizeOfUint8 + sizeOfUint16)
+
+		for key, value := range ah.meta {
+			ret += len(key) + len(value)
+		}
+	}
+
+	return ret
+}
+
+// serializeMeta serializes the user metadata in key order. Each entry consists
+// of the length of the key, the key, the length of the value, and the value.
+func (ah *arcHeader) serializeMeta() []byte {
+	keys := make([]string, 0, len(ah.meta))
+
+	for key := range ah.meta {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var ret []byte
+
+	for _, key := range keys {
+		ret = append(ret, byte(len(key)))
+		ret = append(ret, key...)
+		ret = binary.LittleEndian.AppendUint16(ret, uint16(len(ah.meta[key])))
+		ret = append(ret, ah.meta[key]...)
+	}
+
+	return ret
+}
+
+// readMeta parses the user metadata serialized by serializeMeta.
+func readMeta(src []byte) (map[string][]byte, error) {
+	var ret map[string][]byte
+
+	for len(src) > 0 {
+		keyLen := int(src[0])
+
+		if len(src) < sizeOfUint8+keyLen+sizeOfUint16 {
+			return nil, ErrCorrupted
+		}
+
+		key := string(src[sizeOfUint8 : sizeOfUint8+keyLen])
+		src = src[sizeOfUint8+keyLen:]
+		valueLen := int(binary.LittleEndian.Uint16(src))
+
+		if len(src) < sizeOfUint16+valueLen {
+			return nil, ErrCorrupted
+		}
+
+		if ret == nil {
+			ret = map[string][]byte{}
+		}
+
+		ret[key] = bytes.Clone(src[sizeOfUint16 : sizeOfUint16+valueLen])
+		src = src[sizeOfUint16+valueLen:]
+	}
+
+	return ret, nil
+}
+
+// newArcHeaderFromBytes parses the header at the beginning of src, which may
+// be followed by the rest of the file. The length of the header depends on
+// its version, and is reported by the len method of the returned header.
 func newArcHeaderFromBytes(src []byte) (arcHeader, error) {
 	var ret arcHeader
 
-	if len(src) != arcHeaderBytesLen {
+	if len(src) < legacyArcHeaderBytesLen {
 		return ret, ErrCorrupted
 	}
 
-	reader := bytes.NewReader(src)
+	ret.magic = src[0]
+	ret.version = src[1]
+	ret.status = src[2]
 
-	if err := binary.Read(reader, binary.LittleEndian, &ret.magic); err != nil {
-		return ret, err
+	headerLen := legacyArcHeaderBytesLen
+
+	if ret.version >= keyTransformVersion {
+		if len(src) < arcHeaderBytesLen {
+			return ret, ErrCorrupted
+		}
+
+		headerLen = arcHeaderBytesLen + int(src[3])
+
+		if len(src) < headerLen {
+			return ret, ErrCorrupted
+		}
 	}
 
-	if err := binary.Read(reader, binary.LittleEndian, &ret.version); err != nil {
-		return ret, err
+	// The metadata follows the name of the key transform, and is preceded
+	// by its length.
+	metaPos := headerLen - checksumLen
+
+	if ret.version >= metaVersion {
+		if len(src) < headerLen+sizeOfUint32 {
+			return ret, ErrCorrupted
+		}
+
+		metaLen := uint64(binary.LittleEndian.Uint32(src[metaPos:]))
+
+		if metaLen > uint64(len(src)-headerLen-sizeOfUint32) {
+			return ret, ErrCorrupted
+		}
+
+		headerLen += sizeOfUint32 + int(metaLen)
 	}
 
-	if err := binary.Read(reader, binary.LittleEndian, &ret.status); err != nil {
+	gotChecksum, err := computeChecksum(src[:headerLen-checksumLen])
+
+	if err != nil {
 		return ret, err
 	}
 
+	if gotChecksum != binary.LittleEndian.Uint32(src[headerLen-checksumLen:]) {
+		return ret, ErrInvalidChecksum
+	}
+
+	if ret.version >= keyTransformVersion {
+		ret.keyTransform = string(src[arcHeaderBytesLen-checksumLen : metaPos])
+	}
+
+	if ret.version >= metaVersion {
+		if ret.meta, err = readMeta(src[metaPos+sizeOfUint32 : headerLen-checksumLen]); err != nil {
+			return ret, err
+		}
+	}
+
 	return ret, nil
 }
 
 // persistentNode is the on-disk structure of Arc's radix tree node.
-// All fields in this struct are persisted in the same order.
+// All fields in this struct are persisted in the same order. The user flags
+// are only persisted if the hasUserFlags flag is set, which keeps the nodes
+// of unflagged records at their original size.
 type persistentNode struct {
 	flags             uint8
 	numChildren       uint16
@@ -115,6 +269,7 @@ type persistentNode struct {
 	nextSiblingOffset uint64
 	key               []byte
 	data              []byte
+	userFlags         uint8
 }
 
 func makePersistentNode(n node) persistentNode {
@@ -128,11 +283,22 @@ func makePersistentNode(n node) persistentNode {
 		ret.flags |= flagHasBlob
 	}
 
+	if n.userFlags != 0 {
+		ret.flags |= flagHasUserFlags
+	}
+
+	// Empty values are told apart from nil values, which have no data
+	// either.
+	if n.isRecord && n.data != nil && len(n.data) == 0 {
+		ret.flags |= flagEmptyValue
+	}
+
 	ret.numChildren = uint16(n.numChildren)
 	ret.keyLen = uint16(len(n.key))
 	ret.dataLen = uint32(len(n.data))
 	ret.key = n.key
 	ret.data = n.data
+	ret.userFlags = n.userFlags
 
 	// Node offsets are unknown at initialization phase.
 	ret.firstChildOffset = 0
@@ -201,18 +367,30 @@ func makePersistentNodeFromBytes(src []byte) (persistentNode, error) {
 	remaining := nodeReader.Len()
 	expectedRemaining := int(ret.keyLen) + int(ret.dataLen)
 
+	if ret.hasUserFlags() {
+		expectedRemaining += sizeOfUint8
+	}
+
 	if expectedRemaining != remaining {
 		return ret, ErrNodeCorrupted
 	}
 
 	ret.key = make([]byte, ret.keyLen)
-	if _, err := nodeReader.Read(ret.key); err != nil {
+	if _, err := io.ReadFull(nodeReader, ret.key); err != nil {
 		return ret, err
 	}
 
 	if ret.isRecord() {
 		ret.data = make([]byte, ret.dataLen)
-		if _, err := nodeReader.Read(ret.data); err != nil {
+		if _, err := io.ReadFull(nodeReader, ret.data); err != nil {
+			return ret, err
+		}
+	} else if _, err := nodeReader.Seek(int64(ret.dataLen), io.SeekCurrent); err != nil {
+		return ret, err
+	}
+
+	if ret.hasUserFlags() {
+		if err := binary.Read(nodeReader, binary.LittleEndian, &ret.userFlags); err != nil {
 			return ret, err
 		}
 	}
@@ -230,6 +408,16 @@ func (pn persistentNode) hasBlob() bool {
 	return pn.flags&flagHasBlob != 0
 }
 
+// hasUserFlags returns true if the hasUserFlags flag is set.
+func (pn persistentNode) hasUserFlags() bool {
+	return pn.flags&flagHasUserFlags != 0
+}
+
+// hasEmptyValue returns true if the emptyValue flag is set.
+func (pn persistentNode) hasEmptyValue() bool {
+	return pn.flags&flagEmptyValue != 0
+}
+
 // serialize serializes the persistentNode into a standardized byte slice.
 func (pn persistentNode) serialize() ([]byte, error) {
 	var buf bytes.Buffer
@@ -266,6 +454,12 @@ func (pn persistentNode) serialize() ([]byte, error) {
 		return nil, err
 	}
 
+	if pn.hasUserFlags() {
+		if err := buf.WriteByte(pn.userFlags); err != nil {
+			return nil, err
+		}
+	}
+
 	// Append the checksum at the end of the serialized node.
 	checksum, err := computeChecksum(buf.Bytes())
 
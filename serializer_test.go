@@ -22,6 +22,30 @@ func TestArcHeaderSerialize(t *testing.T) {
 				status:  arcFileOpened,
 			},
 		},
+		{
+			name: "with a key transform",
+			header: arcHeader{
+				magic:        magicByte,
+				version:      fileFormatVersion,
+				keyTransform: "lowercase",
+			},
+		},
+		{
+			name: "with metadata",
+			header: arcHeader{
+				magic:        magicByte,
+				version:      fileFormatVersion,
+				keyTransform: "lowercase",
+				meta:         map[string][]byte{"app": []byte("test"), "schema": {2}},
+			},
+		},
+		{
+			name: "with a legacy version",
+			header: arcHeader{
+				magic:   magicByte,
+				version: keyTransformVersion - 1,
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -49,6 +73,24 @@ func TestArcHeaderSerialize(t *testing.T) {
 			if subject.status != tc.header.status {
 				t.Errorf("unexpected status: got:%d, want:%d", subject.status, tc.header.status)
 			}
+
+			if subject.keyTransform != tc.header.keyTransform {
+				t.Errorf("unexpected keyTransform: got:%q, want:%q", subject.keyTransform, tc.header.keyTransform)
+			}
+
+			if len(subject.meta) != len(tc.header.meta) {
+				t.Errorf("unexpected meta: got:%q, want:%q", subject.meta, tc.header.meta)
+			}
+
+			for key, want := range tc.header.meta {
+				if got := subject.meta[key]; string(got) != string(want) {
+					t.Errorf("unexpected meta value for %q: got:%q, want:%q", key, got, want)
+				}
+			}
+
+			if subject.len() != len(bytes) {
+				t.Errorf("unexpected len: got:%d, want:%d", subject.len(), len(bytes))
+			}
 		})
 	}
 }
@@ -154,6 +196,15 @@ func TestPersistentNodeSerialize(t *testing.T) {
 				{key: []byte("store")},
 			},
 		},
+		{
+			name: "with user flags",
+			node: node{
+				key:       []byte("app"),
+				data:      []byte("band"),
+				isRecord:  true,
+				userFlags: 0x81,
+			},
+		},
 		{
 			name: "with non-record node",
 			node: node{
@@ -223,6 +274,24 @@ func TestPersistentNodeSerialize(t *testing.T) {
 			if !bytes.Equal(got.data, pn.data) {
 				t.Errorf("unexpected key: got:%q, want:%q", got.data, pn.data)
 			}
+
+			if got.userFlags != pn.userFlags {
+				t.Errorf("unexpected userFlags: got:%d, want:%d", got.userFlags, pn.userFlags)
+			}
+
+			if len(serializedNode) != serializedNodeLen(&tc.node) {
+				t.Errorf("unexpected length: got:%d, want:%d", len(serializedNode), serializedNodeLen(&tc.node))
+			}
 		})
 	}
 }
+
+func TestArcHeaderChecksum(t *testing.T) {
+	header := newArcHeader()
+	src, _ := header.serialize()
+	src[1]++
+
+	if _, err := newArcHeaderFromBytes(src); err != ErrInvalidChecksum {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrInvalidChecksum)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+var (
+	// ErrTreeTooDeep is returned when a write would make the tree deeper
+	// than Options.MaxDepth.
+	ErrTreeTooDeep = errors.New("tree is too deep")
+
+	// ErrTooManyChildren is returned when a write would give a node more
+	// children than Options.MaxChildrenPerNode.
+	ErrTooManyChildren = errors.New("node has too many children")
+)
+
+// ShapeError describes a write that would exceed MaxDepth or
+// MaxChildrenPerNode, along with the key prefix where the tree would take the
+// offending shape. Err is either ErrTreeTooDeep or ErrTooManyChildren, so
+// callers can continue to use errors.Is to detect the failure.
+type ShapeError struct {
+	Err    error  // ErrTreeTooDeep or ErrTooManyChildren.
+	Prefix []byte // Copy of the leading bytes of the prefix, at most 64.
+	Len    int    // Length of the full prefix in bytes.
+	Size   int    // Depth or number of children that the write would reach.
+	Limit  int    // Configured limit.
+}
+
+// Error returns the error message including the offending prefix.
+func (e *ShapeError) Error() string {
+	prefix := fmt.Sprintf("%q", e.Prefix)
+
+	if e.Len > len(e.Prefix) {
+		prefix = fmt.Sprintf("%q... (%d bytes)", e.Prefix, e.Len)
+	}
+
+	return fmt.Sprintf("%v: %d at prefix %s exceeds the limit of %d", e.Err, e.Size, prefix, e.Limit)
+}
+
+// Unwrap returns the underlying sentinel error.
+func (e *ShapeError) Unwrap() error {
+	return e.Err
+}
+
+// shapeError returns a ShapeError for the given prefix, and logs it. The
+// prefix is not logged, since keys are never logged.
+func (a *Arc) shapeError(err error, prefix []byte, size int, limit int) error {
+	a.log(slog.LevelWarn, "tree shape limit exceeded", "err", err, "prefix_len", len(prefix), "size", size, "limit", limit)
+
+	return &ShapeError{
+		Err:    err,
+		Prefix: bytes.Clone(prefix[:min(len(prefix), maxOpErrorKeyLen)]),
+		Len:    len(prefix),
+		Size:   size,
+		Limit:  limit,
+	}
+}
+
+// checkShape returns a ShapeError if inserting the given key would exceed
+// MaxDepth or MaxChildrenPerNode. It follows the same path through the tree
+// as insert, without modifying it. The caller must hold the write lock.
+func (a *Arc) checkShape(key []byte) error {
+	if (a.opts.MaxDepth == 0 && a.opts.MaxChildrenPerNode == 0) || a.empty() {
+		return nil
+	}
+
+	// A key with no shared prefix moves the root under a new common root.
+	if len(a.root.key) > 0 && longestCommonPrefix(a.root.key, key) == nil {
+		return a.checkDepth(a.root, 2, nil)
+	}
+
+	current := a.root
+	depth := 1
+	consumed := 0
+
+	for {
+		rest := key[consumed:]
+		prefixLen := len(longestCommonPrefix(current.key, rest))
+
+		// Overwriting a record leaves the shape of the tree as it is.
+		if prefixLen == len(current.key) && prefixLen == len(rest) {
+			return nil
+		}
+
+		// The new record takes the place of current, which moves under it.
+		if prefixLen == len(rest) && prefixLen < len(current.key) {
+			return a.checkDepth(current, depth+1, key)
+		}
+
+		// A new node takes the place of current, with current and the new
+		// record as its children.
+		if prefixLen > 0 && prefixLen < len(current.key) {
+			return a.checkDepth(current, depth+1, key[:consumed+prefixLen])
+		}
+
+		consumed += prefixLen
+		next := current.findCompatibleChild(key[consumed:])
+
+		// The new record becomes a child of current.
+		if next == nil {
+			if limit := a.opts.MaxChildrenPerNode; limit > 0 && current.numChildren >= limit {
+				return a.shapeError(ErrTooManyChildren, key[:consumed], current.numChildren+1, limit)
+			}
+
+			if limit := a.opts.MaxDepth; limit > 0 && depth+1 > limit {
+				return a.shapeError(ErrTreeTooDeep, key, depth+1, limit)
+			}
+
+			return nil
+		}
+
+		current = next
+		depth++
+	}
+}
+
+// checkDepth returns a ShapeError if the subtree of n would exceed MaxDepth
+// once n is moved to the given depth. The prefix identifies the node where the
+// tree is split. The subtree is walked until a node exceeds the limit.
+func (a *Arc) checkDepth(n *node, depth int, prefix []byte) error {
+	limit := a.opts.MaxDepth
+
+	if limit == 0 {
+		return nil
+	}
+
+	if height := subtreeHeight(n, limit-depth+2); depth+height-1 > limit {
+		return a.shapeError(ErrTreeTooDeep, prefix, depth+height-1, limit)
+	}
+
+	return nil
+}
+
+// subtreeHeight returns the number of nodes on the longest path from n to a
+// leaf, including both. It stops descending once the height reaches the given
+// bound, which it then returns.
+func subtreeHeight(n *node, bound int) int {
+	if bound <= 1 {
+		return 1
+	}
+
+	ret := 1
+
+	for child := n.firstChild; child != nil; child = child.nextSibling {
+		ret = max(ret, 1+subtreeHeight(child, bound-1))
+
+		if ret >= bound {
+			break
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,168 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestMaxDepth(t *testing.T) {
+	arc, _ := NewWithOptions(Options{MaxDepth: 3})
+
+	// The records form the path ["a" -> "b" -> "c"].
+	for _, key := range []string{"a", "ab", "abc"} {
+		if err := arc.Put([]byte(key), []byte(key)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// Overwrites and siblings at an allowed depth are accepted.
+	for _, key := range []string{"abc", "abd"} {
+		if err := arc.Put([]byte(key), []byte(key)); err != nil {
+			t.Errorf("unexpected error for %q: %v", key, err)
+		}
+	}
+
+	tests := []struct {
+		key    string
+		prefix string
+	}{
+		{"abcd", "abcd"}, // Child of the deepest node.
+		{"b", ""},        // Moves the entire tree under a new root.
+	}
+
+	for _, test := range tests {
+		err := arc.Put([]byte(test.key), []byte("value"))
+
+		var shapeErr *ShapeError
+
+		if !errors.As(err, &shapeErr) || !errors.Is(err, ErrTreeTooDeep) {
+			t.Fatalf("unexpected error for %q: %v", test.key, err)
+		}
+
+		if string(shapeErr.Prefix) != test.prefix || shapeErr.Size != 4 || shapeErr.Limit != 3 {
+			t.Errorf("unexpected shape error for %q: %+v", test.key, shapeErr)
+		}
+	}
+
+	// Inserting "xy" moves "xyz1" and its children one level down, and so
+	// does splitting "xyz1" into "xy" and "z1".
+	arc, _ = NewWithOptions(Options{MaxDepth: 2})
+	arc.Put([]byte("xyz1"), nil)
+	arc.Put([]byte("xyz12"), nil)
+	arc.Put([]byte("xyz13"), nil)
+
+	err := arc.Put([]byte("xy"), nil)
+
+	var shapeErr *ShapeError
+
+	if !errors.As(err, &shapeErr) || string(shapeErr.Prefix) != "xy" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := arc.Put([]byte("xyw"), nil); !errors.Is(err, ErrTreeTooDeep) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrTreeTooDeep)
+	}
+
+	if arc.Len() != 3 {
+		t.Errorf("expected the rejected writes to leave the tree as is: %d records", arc.Len())
+	}
+}
+
+func TestMaxChildrenPerNode(t *testing.T) {
+	var buf bytes.Buffer
+
+	arc, _ := NewWithOptions(Options{MaxChildrenPerNode: 2, Logger: newTestLogger(&buf)})
+
+	for _, key := range []string{"user:1", "user:2"} {
+		if err := arc.Put([]byte(key), nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	err := arc.Put([]byte("user:3"), nil)
+
+	var shapeErr *ShapeError
+
+	if !errors.As(err, &shapeErr) || !errors.Is(err, ErrTooManyChildren) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(shapeErr.Prefix) != "user:" || shapeErr.Size != 3 || shapeErr.Limit != 2 {
+		t.Errorf("unexpected shape error: %+v", shapeErr)
+	}
+
+	if !strings.Contains(err.Error(), `at prefix "user:"`) {
+		t.Errorf("expected the error to identify the prefix: %v", err)
+	}
+
+	// The prefix is not logged, only its length.
+	if log := buf.String(); !strings.Contains(log, "tree shape limit exceeded") || !strings.Contains(log, "prefix_len=5") || strings.Contains(log, "user:") {
+		t.Errorf("unexpected log: %s", log)
+	}
+
+	// A split creates a node with two children, which is allowed.
+	if err := arc.Put([]byte("users"), nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if arc.Len() != 3 {
+		t.Errorf("unexpected length: got:%d, want:3", arc.Len())
+	}
+}
+
+func TestShapeOptions(t *testing.T) {
+	for _, opts := range []Options{{MaxDepth: -1}, {MaxChildrenPerNode: -1}, {MaxChildrenPerNode: 1}} {
+		if _, err := NewWithOptions(opts); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("unexpected error for %+v: %v", opts, err)
+		}
+	}
+}
+
+func TestShapeErrorLongPrefix(t *testing.T) {
+	err := &ShapeError{Err: ErrTreeTooDeep, Prefix: []byte("abc"), Len: 100, Size: 5, Limit: 4}
+
+	if want := `tree is too deep: 5 at prefix "abc"... (100 bytes) exceeds the limit of 4`; err.Error() != want {
+		t.Errorf("unexpected message: got:%q, want:%q", err.Error(), want)
+	}
+}
+
+func TestShapeLimitsRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	arc, _ := NewWithOptions(Options{MaxDepth: 4, MaxChildrenPerNode: 3})
+
+	var rejected int
+
+	for range 2000 {
+		if err := arc.Put(randomKey(rng, "abcd", 8), nil); err != nil {
+			if !errors.Is(err, ErrTreeTooDeep) && !errors.Is(err, ErrTooManyChildren) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			rejected++
+		}
+	}
+
+	if rejected == 0 {
+		t.Fatalf("expected some writes to be rejected")
+	}
+
+	var visit func(n *node, depth int)
+
+	visit = func(n *node, depth int) {
+		if depth > 4 || n.numChildren > 3 {
+			t.Fatalf("node %q exceeds the limits: depth:%d, children:%d", n.key, depth, n.numChildren)
+		}
+
+		for child := n.firstChild; child != nil; child = child.nextSibling {
+			visit(child, depth+1)
+		}
+	}
+
+	visit(arc.root, 1)
+}
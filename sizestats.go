@@ -0,0 +1,260 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// sizeBuckets is the number of buckets of a sizeHistogram, which covers every
+// non-negative int.
+const sizeBuckets = bits.UintSize
+
+// sizeHistogram counts sizes in power-of-two buckets. Bucket 0 holds the size
+// 0, and bucket i holds the sizes from 2^(i-1) through 2^i-1.
+type sizeHistogram struct {
+	counts [sizeBuckets]uint64
+	sum    uint64
+}
+
+// sizeBucket returns the index of the bucket that holds the given size.
+func sizeBucket(size int) int {
+	return bits.Len(uint(size))
+}
+
+// sizeBucketBound returns the largest size that the given bucket holds.
+func sizeBucketBound(i int) int {
+	return 1<<i - 1
+}
+
+// add counts the given size.
+func (h *sizeHistogram) add(size int) {
+	h.counts[sizeBucket(size)]++
+	h.sum += uint64(size)
+}
+
+// remove uncounts the given size, which must have been counted.
+func (h *sizeHistogram) remove(size int) {
+	h.counts[sizeBucket(size)]--
+	h.sum -= uint64(size)
+}
+
+// recordSize is the size of a record as counted by the sizeHistograms.
+type recordSize struct {
+	keyLen   int
+	valueLen int
+	blob     bool // Whether the value is stored in the blob store.
+}
+
+// sizeHistograms holds the distributions of the key and value sizes of the
+// records. Previous versions of the records are not included.
+type sizeHistograms struct {
+	keys         sizeHistogram
+	inlineValues sizeHistogram
+	blobValues   sizeHistogram
+}
+
+// add counts the given record.
+func (s *sizeHistograms) add(r recordSize) {
+	s.keys.add(r.keyLen)
+
+	if r.blob {
+		s.blobValues.add(r.valueLen)
+	} else {
+		s.inlineValues.add(r.valueLen)
+	}
+}
+
+// remove uncounts the given record, which must have been counted.
+func (s *sizeHistograms) remove(r recordSize) {
+	s.keys.remove(r.keyLen)
+
+	if r.blob {
+		s.blobValues.remove(r.valueLen)
+	} else {
+		s.inlineValues.remove(r.valueLen)
+	}
+}
+
+// recordSize returns the size of the record that matches the given key, and
+// whether it was found. The caller must hold the read lock.
+func (a *Arc) recordSize(key []byte) (recordSize, bool) {
+	n, _, err := a.findNodeAndParent(key)
+
+	if err != nil || !n.isRecord {
+		return recordSize{}, false
+	}
+
+	return recordSize{keyLen: len(key), valueLen: n.valueSize(a.blobs), blob: n.blobValue}, true
+}
+
+// rebuildSizeHistograms replaces the size histograms with those of every
+// record in the tree. It is a no-op unless the TrackSizeHistograms option is
+// enabled. The caller must hold the write lock.
+func (a *Arc) rebuildSizeHistograms() {
+	if !a.opts.TrackSizeHistograms {
+		return
+	}
+
+	a.sizes = a.collectSizeHistograms()
+}
+
+// collectSizeHistograms returns the size histograms of every record in the
+// tree. The caller must hold the read lock.
+func (a *Arc) collectSizeHistograms() *sizeHistograms {
+	ret := &sizeHistograms{}
+
+	a.walkPrefix(nil, func(key []byte, n *node) bool {
+		ret.add(recordSize{keyLen: len(key), valueLen: n.valueSize(a.blobs), blob: n.blobValue})
+		return true
+	})
+
+	return ret
+}
+
+// SizeStats reports the distributions of the key and value sizes of the
+// records, which helps to spot pathological keys, and to tell how many values
+// are small enough to be stored inline in the tree rather than in the blob
+// store. Previous versions of the records are not included.
+type SizeStats struct {
+	// Keys is the distribution of the key sizes, as the keys are stored in
+	// the tree.
+	Keys SizeDistribution
+
+	// InlineValues is the distribution of the sizes of the values that are
+	// stored in the tree.
+	InlineValues SizeDistribution
+
+	// BlobValues is the distribution of the sizes of the values that are
+	// stored in the blob store. Every record counts, even if its value is
+	// shared with other records by deduplication.
+	BlobValues SizeDistribution
+}
+
+// SizeDistribution is a histogram of sizes in bytes. The sizes are counted in
+// power-of-two buckets, therefore the percentiles and the maximum are the
+// upper bounds of the buckets that hold them, which are at most twice as
+// large as the actual sizes.
+type SizeDistribution struct {
+	Count uint64 // Number of sizes.
+	Sum   uint64 // Total of the sizes.
+	P50   int    // Median size.
+	P95   int    // 95th percentile size.
+	Max   int    // Largest size.
+
+	// Buckets lists the cumulative counts of the sizes in ascending order
+	// of their upper bounds, up to the bucket that holds the largest size.
+	Buckets []SizeBucket
+}
+
+// SizeBucket is a bucket of a SizeDistribution.
+type SizeBucket struct {
+	UpperBound int    // Largest size in the bucket, inclusive.
+	Count      uint64 // Number of sizes up to UpperBound.
+}
+
+// SizeStats returns the distributions of the key and value sizes. It takes
+// constant time when Options.TrackSizeHistograms is enabled, and walks the
+// entire tree otherwise.
+func (a *Arc) SizeStats() SizeStats {
+	a.rlock()
+	defer a.runlock()
+
+	sizes := a.sizes
+
+	if sizes == nil {
+		sizes = a.collectSizeHistograms()
+	}
+
+	return SizeStats{
+		Keys:         sizes.keys.distribution(),
+		InlineValues: sizes.inlineValues.distribution(),
+		BlobValues:   sizes.blobValues.distribution(),
+	}
+}
+
+// distribution returns the histogram as a SizeDistribution.
+func (h *sizeHistogram) distribution() SizeDistribution {
+	ret := SizeDistribution{Sum: h.sum}
+	last := -1
+
+	for i, count := range h.counts {
+		ret.Count += count
+
+		if count > 0 {
+			last = i
+		}
+	}
+
+	var cumulative uint64
+	var p50, p95 bool
+
+	for i := 0; i <= last; i++ {
+		cumulative += h.counts[i]
+		bound := sizeBucketBound(i)
+
+		ret.Buckets = append(ret.Buckets, SizeBucket{UpperBound: bound, Count: cumulative})
+
+		// The percentile is the smallest size that is at least as large as
+		// the given share of the sizes.
+		if !p50 && cumulative*2 >= ret.Count {
+			ret.P50, p50 = bound, true
+		}
+
+		if !p95 && cumulative*100 >= ret.Count*95 {
+			ret.P95, p95 = bound, true
+		}
+	}
+
+	if last >= 0 {
+		ret.Max = sizeBucketBound(last)
+	}
+
+	return ret
+}
+
+// WritePrometheus writes the distributions to w as Prometheus histograms in
+// the text exposition format, which can be served by a metrics endpoint. The
+// key sizes are named arc_key_size_bytes, and the value sizes are named
+// arc_value_size_bytes with a storage label of "inline" or "blob".
+func (s SizeStats) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "# HELP arc_key_size_bytes Size of the record keys in bytes.")
+	fmt.Fprintln(bw, "# TYPE arc_key_size_bytes histogram")
+	s.Keys.writePrometheus(bw, "arc_key_size_bytes", "")
+
+	fmt.Fprintln(bw, "# HELP arc_value_size_bytes Size of the record values in bytes.")
+	fmt.Fprintln(bw, "# TYPE arc_value_size_bytes histogram")
+	s.InlineValues.writePrometheus(bw, "arc_value_size_bytes", `storage="inline"`)
+	s.BlobValues.writePrometheus(bw, "arc_value_size_bytes", `storage="blob"`)
+
+	return bw.Flush()
+}
+
+// writePrometheus writes the samples of the distribution with the given metric
+// name and labels.
+func (d SizeDistribution) writePrometheus(w io.Writer, name string, labels string) {
+	sep := ""
+
+	if labels != "" {
+		sep = ","
+	}
+
+	for _, b := range d.Buckets {
+		fmt.Fprintf(w, "%s_bucket{%s%sle=\"%d\"} %d\n", name, labels, sep, b.UpperBound, b.Count)
+	}
+
+	fmt.Fprintf(w, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, d.Count)
+
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+
+	fmt.Fprintf(w, "%s_sum%s %d\n", name, labels, d.Sum)
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, d.Count)
+}
@@ -0,0 +1,153 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSizeStats(t *testing.T) {
+	arc := New()
+
+	// Keys of 1, 2, 3, and 5 bytes, and inline values of 0 and 4 bytes.
+	arc.Put([]byte("a"), []byte{})
+	arc.Put([]byte("ab"), []byte("1234"))
+	arc.Put([]byte("abc"), []byte("1234"))
+	arc.Put([]byte("abcde"), blobValueX())
+
+	stats := arc.SizeStats()
+
+	want := SizeDistribution{Count: 4, Sum: 11, P50: 3, P95: 7, Max: 7, Buckets: []SizeBucket{
+		{UpperBound: 0, Count: 0},
+		{UpperBound: 1, Count: 1},
+		{UpperBound: 3, Count: 3},
+		{UpperBound: 7, Count: 4},
+	}}
+
+	if !reflect.DeepEqual(stats.Keys, want) {
+		t.Errorf("unexpected key sizes: got:%+v, want:%+v", stats.Keys, want)
+	}
+
+	if d := stats.InlineValues; d.Count != 3 || d.Sum != 8 || d.P50 != 7 || d.Max != 7 {
+		t.Errorf("unexpected inline value sizes: %+v", d)
+	}
+
+	if d := stats.BlobValues; d.Count != 1 || d.Sum != uint64(len(blobValueX())) || d.Max < len(blobValueX()) {
+		t.Errorf("unexpected blob value sizes: %+v", d)
+	}
+
+	// An empty database has no buckets.
+	if stats := New().SizeStats(); stats.Keys.Count != 0 || stats.Keys.Buckets != nil || stats.Keys.Max != 0 {
+		t.Errorf("unexpected key sizes: %+v", stats.Keys)
+	}
+}
+
+func TestSizeStatsTracking(t *testing.T) {
+	tracked, _ := NewWithOptions(Options{TrackSizeHistograms: true})
+	walked := New()
+
+	for _, arc := range []*Arc{tracked, walked} {
+		for _, key := range sortedBasicTestKeys() {
+			arc.Put([]byte(key), []byte(key))
+		}
+
+		// Overwrite inline values with blobs, and blobs with inline values.
+		arc.Put([]byte("apple"), blobValueX())
+		arc.Put([]byte("banana"), blobValueX())
+		arc.Put([]byte("banana"), []byte("yellow"))
+
+		arc.Delete([]byte("apricot"))
+		arc.Delete([]byte("missing"))
+		arc.Rename([]byte("banana"), []byte("bananas"))
+		arc.Add([]byte("apple"), []byte("duplicate"))
+	}
+
+	if got, want := tracked.SizeStats(), walked.SizeStats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected size stats: got:%+v, want:%+v", got, want)
+	}
+
+	// A clone tracks its own writes.
+	clone := tracked.Clone()
+	clone.Put([]byte("cherry"), []byte("red"))
+
+	if tracked.SizeStats().Keys.Count == clone.SizeStats().Keys.Count {
+		t.Errorf("expected the clone to count its own records")
+	}
+
+	if got, want := clone.SizeStats(), clone.Clone().SizeStats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected size stats of the clone: got:%+v, want:%+v", got, want)
+	}
+
+	for _, key := range sortedBasicTestKeys() {
+		tracked.Delete([]byte(key))
+	}
+
+	for key := range tracked.Scan(nil) {
+		tracked.Delete(key)
+	}
+
+	if stats := tracked.SizeStats(); !reflect.DeepEqual(stats, SizeStats{}) {
+		t.Errorf("expected empty size stats: %+v", stats)
+	}
+}
+
+func TestSizeStatsLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := OpenWithOptions(path, Options{TrackSizeHistograms: true})
+
+	arc.Put([]byte("apple"), []byte("red"))
+	arc.Put([]byte("apricot"), blobValueX())
+
+	want := arc.SizeStats()
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := OpenWithOptions(path, Options{TrackSizeHistograms: true})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer reopened.Close()
+
+	if got := reopened.SizeStats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected size stats: got:%+v, want:%+v", got, want)
+	}
+}
+
+func TestSizeStatsWritePrometheus(t *testing.T) {
+	arc := New()
+
+	arc.Put([]byte("ab"), []byte("1"))
+	arc.Put([]byte("abcd"), blobValueX())
+
+	var buf bytes.Buffer
+
+	if err := arc.SizeStats().WritePrometheus(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, line := range []string{
+		"# TYPE arc_key_size_bytes histogram",
+		`arc_key_size_bytes_bucket{le="3"} 1`,
+		`arc_key_size_bytes_bucket{le="7"} 2`,
+		`arc_key_size_bytes_bucket{le="+Inf"} 2`,
+		"arc_key_size_bytes_sum 6",
+		"arc_key_size_bytes_count 2",
+		"# TYPE arc_value_size_bytes histogram",
+		`arc_value_size_bytes_bucket{storage="inline",le="1"} 1`,
+		`arc_value_size_bytes_sum{storage="inline"} 1`,
+		`arc_value_size_bytes_count{storage="blob"} 1`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("missing line %q in:\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import "encoding/binary"
+
+// Tags of the Snappy block format, which are stored in the two lowest bits of
+// the first byte of each element.
+const (
+	snappyLiteral = 0
+	snappyCopy1   = 1
+	snappyCopy2   = 2
+	snappyCopy4   = 3
+)
+
+// maxSnappyRatio bounds the ratio of the decoded length to the encoded length.
+// Snappy cannot exceed it, which prevents a corrupted length from allocating
+// a large buffer.
+const maxSnappyRatio = 32
+
+// decodeSnappy decodes a block in the Snappy format, which is the default
+// compression of LevelDB and RocksDB tables. It returns ErrInvalidSST if the
+// block is malformed.
+func decodeSnappy(src []byte) ([]byte, error) {
+	n, s := binary.Uvarint(src)
+
+	if s <= 0 || n > uint64(len(src))*maxSnappyRatio {
+		return nil, ErrInvalidSST
+	}
+
+	dst := make([]byte, 0, n)
+
+	for s < len(src) {
+		tag := src[s]
+
+		var length, offset int
+
+		switch tag & 3 {
+		case snappyLiteral:
+			length = int(tag >> 2)
+			s++
+
+			if length >= 60 {
+				extra := length - 59
+
+				if s+extra > len(src) {
+					return nil, ErrInvalidSST
+				}
+
+				var buf [sizeOfUint32]byte
+				copy(buf[:], src[s:s+extra])
+				length = int(binary.LittleEndian.Uint32(buf[:]))
+				s += extra
+			}
+
+			length++
+
+			if length > len(src)-s || uint64(len(dst)+length) > n {
+				return nil, ErrInvalidSST
+			}
+
+			dst = append(dst, src[s:s+length]...)
+			s += length
+
+			continue
+
+		case snappyCopy1:
+			if s+2 > len(src) {
+				return nil, ErrInvalidSST
+			}
+
+			length = 4 + int(tag>>2&7)
+			offset = int(tag&0xE0)<<3 | int(src[s+1])
+			s += 2
+
+		case snappyCopy2:
+			if s+3 > len(src) {
+				return nil, ErrInvalidSST
+			}
+
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[s+1:]))
+			s += 3
+
+		case snappyCopy4:
+			if s+5 > len(src) {
+				return nil, ErrInvalidSST
+			}
+
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[s+1:]))
+			s += 5
+		}
+
+		if offset <= 0 || offset > len(dst) || uint64(len(dst)+length) > n {
+			return nil, ErrInvalidSST
+		}
+
+		// Copies may overlap their own output, which repeats the bytes.
+		for range length {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+
+	if uint64(len(dst)) != n {
+		return nil, ErrInvalidSST
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,58 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeSnappy(t *testing.T) {
+	tests := []struct {
+		name string
+		src  []byte
+		want []byte
+	}{
+		{"empty", []byte{0x00}, []byte{}},
+		{"literal", []byte{0x03, 0x08, 'a', 'b', 'c'}, []byte("abc")},
+		// "ab" followed by a 1-byte offset copy of 6 bytes at offset 2.
+		{"overlapping copy1", []byte{0x08, 0x04, 'a', 'b', 0x09, 0x02}, []byte("abababab")},
+		// "xyz" followed by a 2-byte offset copy of 3 bytes at offset 3.
+		{"copy2", []byte{0x06, 0x08, 'x', 'y', 'z', 0x0A, 0x03, 0x00}, []byte("xyzxyz")},
+		// "q" followed by a 4-byte offset copy of 2 bytes at offset 1.
+		{"copy4", []byte{0x03, 0x00, 'q', 0x07, 0x01, 0x00, 0x00, 0x00}, []byte("qqq")},
+	}
+
+	for _, test := range tests {
+		got, err := decodeSnappy(test.src)
+
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		if !bytes.Equal(got, test.want) {
+			t.Errorf("%s: unexpected result: got:%q, want:%q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDecodeSnappyInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		src  []byte
+	}{
+		{"no length", nil},
+		{"length mismatch", []byte{0x04, 0x08, 'a', 'b', 'c'}},
+		{"truncated literal", []byte{0x03, 0x08, 'a'}},
+		{"offset out of range", []byte{0x05, 0x00, 'a', 0x01, 0x02}},
+		{"zero offset", []byte{0x05, 0x00, 'a', 0x01, 0x00}},
+		{"excessive length", []byte{0xFF, 0xFF, 0xFF, 0xFF, 0x0F}},
+	}
+
+	for _, test := range tests {
+		if _, err := decodeSnappy(test.src); err != ErrInvalidSST {
+			t.Errorf("%s: unexpected error: got:%v, want:%v", test.name, err, ErrInvalidSST)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"os"
+	"sort"
+)
+
+// ErrSplitMismatch is returned by ImportSplit when the index file and the
+// blob file were not exported together, such as when an index-only backup is
+// paired with a blob file of a different export.
+var ErrSplitMismatch = errors.New("index file does not match the blob file")
+
+const (
+	// splitIndexMagicByte is the first byte of an index file written by
+	// ExportSplit.
+	splitIndexMagicByte = byte(0x49)
+
+	// splitBlobsMagicByte is the first byte of a blob file written by
+	// ExportSplit.
+	splitBlobsMagicByte = byte(0x42)
+)
+
+// blobSetID identifies the set of blobs that an index file references, which
+// is the SHA-256 of the sorted blobIDs.
+type blobSetID [sha256.Size]byte
+
+// ExportSplit writes the records to an index file and a blob file, which
+// ImportSplit loads back. The index file holds the keys, inline values, and
+// the other sections of a database file, whereas the blob file holds the
+// blobs, therefore the blob file can be placed on cheaper storage than the
+// index file. Both files identify the set of blobs, which ImportSplit
+// verifies to pair them. The blob file is not written if blobsPath is empty,
+// which makes for tiny index-only backups of a database whose blobs have not
+// changed since the blob file was exported. Each file is replaced
+// atomically, and the blob file is written first.
+func (a *Arc) ExportSplit(indexPath string, blobsPath string) error {
+	var index, blobs bytes.Buffer
+
+	a.rlock()
+	err := a.writeSplit(&index, &blobs, blobsPath != "")
+	a.runlock()
+
+	if err != nil {
+		return err
+	}
+
+	if blobsPath != "" {
+		if err := writeFileAtomic(blobsPath, blobs.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(indexPath, index.Bytes())
+}
+
+// ImportSplit loads the index file and the blob file written by ExportSplit
+// into a new in-memory database with the given options. Returns
+// ErrSplitMismatch if the files were not exported together, and
+// ErrCorrupted if either file is damaged.
+func ImportSplit(indexPath string, blobsPath string, opts Options) (*Arc, error) {
+	ret, err := newArc(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := os.ReadFile(indexPath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, err := os.ReadFile(blobsPath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ret.readSplit(index, blobs); err != nil {
+		return nil, err
+	}
+
+	ret.startSweeper()
+	ret.startTiering()
+
+	return ret, nil
+}
+
+// writeSplit serializes the index file and, if withBlobs is true, the blob
+// file. Both consist of a header in the database file format, except for the
+// magic byte, followed by the body, which is split into pages like the body
+// of a database file. The body of the index file holds the blobSetID and the
+// sections that follow the header of a database file, except that the blob
+// section is empty. Its offsets are relative to the start of the sections.
+// The body of the blob file holds the blobSetID, the length of the
+// dictionary section, the dictionary section, which the blobs are
+// compressed with, and the blob section. The caller must hold the read lock.
+func (a *Arc) writeSplit(index *bytes.Buffer, blobs *bytes.Buffer, withBlobs bool) error {
+	setID := a.blobSetID()
+
+	header := a.fileHeader()
+	header.magic = splitIndexMagicByte
+
+	var body bytes.Buffer
+
+	body.Write(setID[:])
+
+	if err := a.writeBody(&body, 0, false); err != nil {
+		return err
+	}
+
+	if err := writePaginated(index, header, body.Bytes(), a.epoch+1); err != nil {
+		return err
+	}
+
+	if !withBlobs {
+		return nil
+	}
+
+	dictionary, err := a.serializeDictionary()
+
+	if err != nil {
+		return err
+	}
+
+	body.Reset()
+	body.Write(setID[:])
+	binary.Write(&body, binary.LittleEndian, uint64(len(dictionary)))
+	body.Write(dictionary)
+
+	if _, err := a.writeBlobs(&body, a.blobs); err != nil {
+		return err
+	}
+
+	header = newArcHeader()
+	header.magic = splitBlobsMagicByte
+
+	return writePaginated(blobs, header, body.Bytes(), a.epoch+1)
+}
+
+// writePaginated writes the header followed by the body split into pages.
+func writePaginated(w *bytes.Buffer, header arcHeader, body []byte, epoch uint64) error {
+	headerBytes, err := header.serialize()
+
+	if err != nil {
+		return err
+	}
+
+	pages, err := paginate(body, epoch)
+
+	if err != nil {
+		return err
+	}
+
+	w.Write(headerBytes)
+	w.Write(pages)
+
+	return nil
+}
+
+// readSplit loads the index file and the blob file produced by writeSplit
+// into the receiver, which must be empty. The blob file is read first, since
+// the nodes of the index file look up their blobs in its contents.
+func (a *Arc) readSplit(index []byte, blobs []byte) error {
+	_, blobsBody, _, err := readPaginated(blobs, splitBlobsMagicByte)
+
+	if err != nil {
+		return err
+	}
+
+	if len(blobsBody) < sha256.Size+sizeOfUint64 {
+		return ErrCorrupted
+	}
+
+	pos := sha256.Size
+	dictionaryLen := binary.LittleEndian.Uint64(blobsBody[pos:])
+	pos += sizeOfUint64
+
+	if dictionaryLen > uint64(len(blobsBody)-pos) {
+		return ErrCorrupted
+	}
+
+	if err := a.readDictionary(blobsBody[pos : pos+int(dictionaryLen)]); err != nil {
+		return err
+	}
+
+	pos += int(dictionaryLen)
+	contents := map[blobID][]byte{}
+	blobsLen, err := a.readBlobs(blobsBody[pos:], contents)
+
+	if err != nil {
+		return err
+	}
+
+	if pos+blobsLen != len(blobsBody) {
+		return ErrCorrupted
+	}
+
+	header, indexBody, epoch, err := readPaginated(index, splitIndexMagicByte)
+
+	if err != nil {
+		return err
+	}
+
+	if err := a.opts.checkTransformName(header.keyTransform); err != nil {
+		return err
+	}
+
+	if len(indexBody) < sha256.Size {
+		return ErrCorrupted
+	}
+
+	if !bytes.Equal(indexBody[:sha256.Size], blobsBody[:sha256.Size]) {
+		return ErrSplitMismatch
+	}
+
+	if err := a.readBody(indexBody[sha256.Size:], 0, contents); err != nil {
+		return err
+	}
+
+	a.meta = header.meta
+	a.epoch = epoch
+
+	return nil
+}
+
+// readPaginated verifies the header of a file written by writePaginated, and
+// returns the header, the reassembled body, and the write epoch of its pages.
+func readPaginated(src []byte, magic byte) (arcHeader, []byte, uint64, error) {
+	header, err := newArcHeaderFromBytes(src)
+
+	if err != nil {
+		return header, nil, 0, err
+	}
+
+	if header.magic != magic {
+		return header, nil, 0, ErrCorrupted
+	}
+
+	if header.version != fileFormatVersion {
+		return header, nil, 0, ErrUnsupportedVersion
+	}
+
+	body, epoch, err := unpaginate(nil, src[header.len():])
+
+	return header, body, epoch, err
+}
+
+// blobSetID returns the blobSetID of the blobs of the database. The caller
+// must hold the read lock.
+func (a *Arc) blobSetID() blobSetID {
+	ids := make([]blobID, 0, len(a.blobs))
+
+	for id := range a.blobs {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return bytes.Compare(ids[i][:], ids[j][:]) < 0
+	})
+
+	h := sha256.New()
+
+	for _, id := range ids {
+		h.Write(id[:])
+	}
+
+	var ret blobSetID
+	h.Sum(ret[:0])
+
+	return ret
+}
@@ -0,0 +1,137 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportSplit(t *testing.T) {
+	dir := t.TempDir()
+	indexPath := filepath.Join(dir, "index.arc")
+	blobsPath := filepath.Join(dir, "blobs.arc")
+
+	arc := New()
+
+	for _, key := range sortedBasicTestKeys() {
+		arc.Put([]byte(key), []byte(key))
+	}
+
+	arc.Put([]byte("blob"), blobValueX())
+	arc.Put([]byte("blob copy"), blobValueX())
+	arc.SetMeta("app", []byte("test"))
+
+	if err := arc.ExportSplit(indexPath, blobsPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	index, _ := os.ReadFile(indexPath)
+	blobs, _ := os.ReadFile(blobsPath)
+
+	// The blob values are only held by the blob file.
+	if bytes.Contains(index, blobValueX()) || !bytes.Contains(blobs, blobValueX()) {
+		t.Errorf("expected the blob values in the blob file only")
+	}
+
+	imported, err := ImportSplit(indexPath, blobsPath, Options{})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !bytes.Equal(imported.RootHash(), arc.RootHash()) || imported.Len() != arc.Len() {
+		t.Errorf("unexpected records: got:%d, want:%d", imported.Len(), arc.Len())
+	}
+
+	if got, _ := imported.Get([]byte("blob copy")); !bytes.Equal(got, blobValueX()) {
+		t.Errorf("unexpected blob value")
+	}
+
+	if got, _ := imported.GetMeta("app"); string(got) != "test" {
+		t.Errorf("unexpected meta: %q", got)
+	}
+
+	if got := imported.BlobStats().NumBlobs; got != 1 {
+		t.Errorf("unexpected blob count: got:%d, want:1", got)
+	}
+}
+
+func TestExportSplitIndexOnly(t *testing.T) {
+	dir := t.TempDir()
+	indexPath := filepath.Join(dir, "index.arc")
+	blobsPath := filepath.Join(dir, "blobs.arc")
+
+	arc := New()
+	arc.Put([]byte("blob"), blobValueX())
+
+	if err := arc.ExportSplit(indexPath, blobsPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Inline values do not change the blobs, therefore the index can be
+	// exported without the blob file.
+	arc.Put([]byte("apple"), []byte("red"))
+
+	if err := arc.ExportSplit(indexPath, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	imported, err := ImportSplit(indexPath, blobsPath, Options{})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, _ := imported.Get([]byte("apple")); string(got) != "red" {
+		t.Errorf("unexpected value: %q", got)
+	}
+
+	// A new blob is not in the blob file.
+	arc.Put([]byte("another blob"), append(blobValueX(), 'y'))
+
+	if err := arc.ExportSplit(indexPath, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := ImportSplit(indexPath, blobsPath, Options{}); !errors.Is(err, ErrSplitMismatch) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrSplitMismatch)
+	}
+}
+
+func TestImportSplitCorrupted(t *testing.T) {
+	dir := t.TempDir()
+	indexPath := filepath.Join(dir, "index.arc")
+	blobsPath := filepath.Join(dir, "blobs.arc")
+
+	arc := New()
+	arc.Put([]byte("blob"), blobValueX())
+	arc.ExportSplit(indexPath, blobsPath)
+
+	// The files are not interchangeable.
+	if _, err := ImportSplit(blobsPath, indexPath, Options{}); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrCorrupted)
+	}
+
+	// Database files are not index files.
+	dbPath := filepath.Join(dir, "test.arc")
+	db, _ := Open(dbPath)
+	db.Put([]byte("apple"), []byte("red"))
+	db.Close()
+
+	if _, err := ImportSplit(dbPath, blobsPath, Options{}); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrCorrupted)
+	}
+
+	blobs, _ := os.ReadFile(blobsPath)
+	blobs[len(blobs)-1] ^= 0xff
+	os.WriteFile(blobsPath, blobs, 0o644)
+
+	if _, err := ImportSplit(indexPath, blobsPath, Options{}); err == nil {
+		t.Errorf("expected an error")
+	}
+}
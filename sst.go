@@ -0,0 +1,448 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+)
+
+// ErrInvalidSST is returned when a file is not a valid SSTable, or uses
+// features of the format that are not supported.
+var ErrInvalidSST = errors.New("invalid or unsupported sstable")
+
+const (
+	// levelDBTableMagic identifies LevelDB tables, and RocksDB tables written
+	// with the legacy footer.
+	levelDBTableMagic = 0xdb4775248b80fb57
+
+	// rocksDBTableMagic identifies RocksDB block-based tables.
+	rocksDBTableMagic = 0x88e241b785f4cff7
+
+	// maxRocksDBFormatVersion is the latest supported format_version of
+	// RocksDB tables. Later versions change the layout of the footer.
+	maxRocksDBFormatVersion = 5
+
+	// rocksDBDeltaIndexVersion is the format_version from which RocksDB
+	// delta-encodes the block handles of the index block.
+	rocksDBDeltaIndexVersion = 4
+
+	sstHandlesLen      = 40
+	levelDBFooterLen   = sstHandlesLen + sizeOfUint64
+	rocksDBFooterLen   = 1 + sstHandlesLen + sizeOfUint32 + sizeOfUint64
+	sstBlockTrailerLen = 1 + checksumLen
+	sstKeyTrailerLen   = sizeOfUint64
+	sstCRC32CChecksum  = 1
+)
+
+// Compression types of SSTable blocks.
+const (
+	sstNoCompression     = 0
+	sstSnappyCompression = 1
+)
+
+// Value types of the internal keys of SSTables.
+const (
+	sstDeletion       = 0x0
+	sstValue          = 0x1
+	sstSingleDeletion = 0x7
+)
+
+// sstHashIndexFlag is set in the restart count of RocksDB data blocks that
+// carry a hash index, which is not supported.
+const sstHashIndexFlag = 1 << 31
+
+// crc32cTable is the table of the CRC-32C checksums that protect the blocks.
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// sstBlockHandle locates a block within the table.
+type sstBlockHandle struct {
+	offset uint64
+	size   uint64
+}
+
+// sstable is an SSTable file loaded in memory.
+type sstable struct {
+	src        []byte
+	index      sstBlockHandle
+	deltaIndex bool
+}
+
+// IngestSST puts the records of the LevelDB or RocksDB table at the given
+// path, such as an SSTable export of an existing database. Only the latest
+// version of each key is ingested, and deleted keys are skipped, which leaves
+// the records of the database with the same keys untouched. Blocks must be
+// uncompressed or compressed with Snappy, and RocksDB tables must use the
+// default CRC-32C checksums and a format_version of at most 5. The records
+// that precede an error remain in the database. Returns ErrInvalidSST if the
+// file is not a supported SSTable.
+func (a *Arc) IngestSST(path string) error {
+	src, err := os.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	t, err := newSSTable(src)
+
+	if err != nil {
+		return err
+	}
+
+	handles, err := t.readIndex()
+
+	if err != nil {
+		return err
+	}
+
+	var last []byte
+
+	for _, handle := range handles {
+		block, err := t.readBlock(handle)
+
+		if err != nil {
+			return err
+		}
+
+		err = walkSSTBlock(block, func(key []byte, value []byte) error {
+			if len(key) < sstKeyTrailerLen {
+				return ErrInvalidSST
+			}
+
+			userKey := key[:len(key)-sstKeyTrailerLen]
+
+			// Versions of a key are sorted from newest to oldest.
+			if last != nil && bytes.Equal(userKey, last) {
+				return nil
+			}
+
+			last = bytes.Clone(userKey)
+
+			switch key[len(key)-sstKeyTrailerLen] {
+			case sstDeletion, sstSingleDeletion:
+				return nil
+			case sstValue:
+				return a.Put(last, bytes.Clone(value))
+			default:
+				return ErrInvalidSST
+			}
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newSSTable parses the footer of the table, which locates the index block.
+func newSSTable(src []byte) (*sstable, error) {
+	if len(src) < levelDBFooterLen {
+		return nil, ErrInvalidSST
+	}
+
+	ret := &sstable{src: src}
+	var handles []byte
+
+	switch binary.LittleEndian.Uint64(src[len(src)-sizeOfUint64:]) {
+	case levelDBTableMagic:
+		handles = src[len(src)-levelDBFooterLen:]
+
+	case rocksDBTableMagic:
+		if len(src) < rocksDBFooterLen {
+			return nil, ErrInvalidSST
+		}
+
+		footer := src[len(src)-rocksDBFooterLen:]
+		version := binary.LittleEndian.Uint32(footer[1+sstHandlesLen:])
+
+		if footer[0] != sstCRC32CChecksum || version > maxRocksDBFormatVersion {
+			return nil, ErrInvalidSST
+		}
+
+		handles = footer[1:]
+		ret.deltaIndex = version >= rocksDBDeltaIndexVersion
+
+	default:
+		return nil, ErrInvalidSST
+	}
+
+	// The footer begins with the metaindex handle, which is not needed, and
+	// is followed by the index handle.
+	var fields [4]uint64
+
+	for i := range fields {
+		v, n := binary.Uvarint(handles)
+
+		if n <= 0 {
+			return nil, ErrInvalidSST
+		}
+
+		fields[i] = v
+		handles = handles[n:]
+	}
+
+	ret.index = sstBlockHandle{offset: fields[2], size: fields[3]}
+
+	return ret, nil
+}
+
+// readBlock returns the contents of the block, after verifying its checksum
+// and decompressing it.
+func (t *sstable) readBlock(h sstBlockHandle) ([]byte, error) {
+	if h.offset > uint64(len(t.src)) || h.size > uint64(len(t.src))-h.offset ||
+		uint64(len(t.src))-h.offset-h.size < sstBlockTrailerLen {
+		return nil, ErrInvalidSST
+	}
+
+	end := h.offset + h.size
+	block := t.src[h.offset:end]
+	kind := t.src[end]
+	checksum := crc32.Update(crc32.Checksum(block, crc32cTable), crc32cTable, []byte{kind})
+
+	if maskSSTChecksum(checksum) != binary.LittleEndian.Uint32(t.src[end+1:]) {
+		return nil, ErrInvalidSST
+	}
+
+	switch kind {
+	case sstNoCompression:
+		return block, nil
+	case sstSnappyCompression:
+		return decodeSnappy(block)
+	default:
+		return nil, ErrInvalidSST
+	}
+}
+
+// maskSSTChecksum masks the CRC-32C checksum like LevelDB, which makes the
+// checksums of data that embeds checksums robust.
+func maskSSTChecksum(crc uint32) uint32 {
+	return (crc>>15 | crc<<17) + 0xa282ead8
+}
+
+// readIndex returns the handles of the data blocks, which are listed in the
+// index block in the order of their keys.
+func (t *sstable) readIndex() ([]sstBlockHandle, error) {
+	block, err := t.readBlock(t.index)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newSSTBlockReader(block)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []sstBlockHandle
+
+	for !r.done() {
+		if t.deltaIndex {
+			// The entries do not record the length of their value, and only
+			// the handles at restart points are stored in full. The others
+			// hold the difference of the size to the previous handle, and
+			// the block that they locate follows the previous one.
+			restart, _, err := r.nextKey(false)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if restart || len(ret) == 0 {
+				offset, size := r.uvarint(), r.uvarint()
+				ret = append(ret, sstBlockHandle{offset: offset, size: size})
+			} else {
+				prev := ret[len(ret)-1]
+				size := uint64(int64(prev.size) + r.varint())
+				ret = append(ret, sstBlockHandle{offset: prev.offset + prev.size + sstBlockTrailerLen, size: size})
+			}
+		} else {
+			value, err := r.next()
+
+			if err != nil {
+				return nil, err
+			}
+
+			handle, err := decodeSSTHandle(value)
+
+			if err != nil {
+				return nil, err
+			}
+
+			ret = append(ret, handle)
+		}
+
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+
+	return ret, nil
+}
+
+// decodeSSTHandle decodes a block handle, which consists of the offset and the
+// size of the block.
+func decodeSSTHandle(src []byte) (sstBlockHandle, error) {
+	offset, n := binary.Uvarint(src)
+
+	if n <= 0 {
+		return sstBlockHandle{}, ErrInvalidSST
+	}
+
+	size, n2 := binary.Uvarint(src[n:])
+
+	if n2 <= 0 {
+		return sstBlockHandle{}, ErrInvalidSST
+	}
+
+	return sstBlockHandle{offset: offset, size: size}, nil
+}
+
+// walkSSTBlock calls fn for every entry of the block, in order.
+func walkSSTBlock(block []byte, fn func(key []byte, value []byte) error) error {
+	r, err := newSSTBlockReader(block)
+
+	if err != nil {
+		return err
+	}
+
+	for !r.done() {
+		value, err := r.next()
+
+		if err != nil {
+			return err
+		}
+
+		if err := fn(r.key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sstBlockReader reads the entries of a block. The keys are prefix compressed
+// against the previous key, except at the restart points.
+type sstBlockReader struct {
+	data     []byte
+	restarts map[int]bool
+	pos      int
+	key      []byte
+	err      error
+}
+
+// newSSTBlockReader parses the restart points, which trail the entries of the
+// block.
+func newSSTBlockReader(block []byte) (*sstBlockReader, error) {
+	if len(block) < sizeOfUint32 {
+		return nil, ErrInvalidSST
+	}
+
+	numRestarts := binary.LittleEndian.Uint32(block[len(block)-sizeOfUint32:])
+
+	if numRestarts&sstHashIndexFlag != 0 || int(numRestarts) > len(block)/sizeOfUint32-1 {
+		return nil, ErrInvalidSST
+	}
+
+	restartsPos := len(block) - sizeOfUint32 - int(numRestarts)*sizeOfUint32
+	restarts := make(map[int]bool, numRestarts)
+
+	for i := range int(numRestarts) {
+		restarts[int(binary.LittleEndian.Uint32(block[restartsPos+i*sizeOfUint32:]))] = true
+	}
+
+	return &sstBlockReader{data: block[:restartsPos], restarts: restarts}, nil
+}
+
+// done reports whether all entries have been read.
+func (r *sstBlockReader) done() bool {
+	return r.err != nil || r.pos >= len(r.data)
+}
+
+// next reads the key of the next entry into r.key, and returns its value.
+func (r *sstBlockReader) next() ([]byte, error) {
+	_, valueLen, err := r.nextKey(true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if valueLen > uint64(len(r.data)-r.pos) {
+		return nil, ErrInvalidSST
+	}
+
+	ret := r.data[r.pos : r.pos+int(valueLen)]
+	r.pos += int(valueLen)
+
+	return ret, nil
+}
+
+// nextKey reads the key of the next entry into r.key, and reports whether the
+// entry is at a restart point. If withValueLen is set, the length of the value
+// is read and returned, and the value is left to the caller.
+func (r *sstBlockReader) nextKey(withValueLen bool) (bool, uint64, error) {
+	restart := r.restarts[r.pos]
+	shared := r.uvarint()
+	unshared := r.uvarint()
+
+	var valueLen uint64
+
+	if withValueLen {
+		valueLen = r.uvarint()
+	}
+
+	if r.err != nil {
+		return false, 0, r.err
+	}
+
+	if shared > uint64(len(r.key)) || unshared > uint64(len(r.data)-r.pos) {
+		return false, 0, ErrInvalidSST
+	}
+
+	r.key = append(r.key[:shared], r.data[r.pos:r.pos+int(unshared)]...)
+	r.pos += int(unshared)
+
+	return restart, valueLen, nil
+}
+
+// uvarint reads an unsigned varint. Errors are recorded in r.err.
+func (r *sstBlockReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	ret, n := binary.Uvarint(r.data[r.pos:])
+
+	if n <= 0 {
+		r.err = ErrInvalidSST
+		return 0
+	}
+
+	r.pos += n
+
+	return ret
+}
+
+// varint reads a signed varint. Errors are recorded in r.err.
+func (r *sstBlockReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+
+	ret, n := binary.Varint(r.data[r.pos:])
+
+	if n <= 0 {
+		r.err = ErrInvalidSST
+		return 0
+	}
+
+	r.pos += n
+
+	return ret
+}
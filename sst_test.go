@@ -0,0 +1,244 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type sstTestEntry struct {
+	key   []byte
+	value []byte
+}
+
+// sstInternalKey appends the trailer of the internal key to the user key.
+func sstInternalKey(key string, seq uint64, kind byte) []byte {
+	return binary.LittleEndian.AppendUint64([]byte(key), seq<<8|uint64(kind))
+}
+
+// buildSSTBlock builds the contents of a block. If withValueLen is not set,
+// the values are stored without their length, like delta-encoded indexes.
+func buildSSTBlock(entries []sstTestEntry, restartInterval int, withValueLen bool) []byte {
+	var ret []byte
+	var restarts []uint32
+	var prev []byte
+
+	for i, e := range entries {
+		shared := 0
+
+		if i%restartInterval == 0 {
+			restarts = append(restarts, uint32(len(ret)))
+		} else {
+			for shared < len(prev) && shared < len(e.key) && prev[shared] == e.key[shared] {
+				shared++
+			}
+		}
+
+		ret = binary.AppendUvarint(ret, uint64(shared))
+		ret = binary.AppendUvarint(ret, uint64(len(e.key)-shared))
+
+		if withValueLen {
+			ret = binary.AppendUvarint(ret, uint64(len(e.value)))
+		}
+
+		ret = append(ret, e.key[shared:]...)
+		ret = append(ret, e.value...)
+		prev = e.key
+	}
+
+	for _, restart := range restarts {
+		ret = binary.LittleEndian.AppendUint32(ret, restart)
+	}
+
+	return binary.LittleEndian.AppendUint32(ret, uint32(len(restarts)))
+}
+
+// sstTestWriter lays out the blocks of a table.
+type sstTestWriter struct {
+	buf []byte
+}
+
+func (w *sstTestWriter) addBlock(contents []byte, compression byte) sstBlockHandle {
+	ret := sstBlockHandle{offset: uint64(len(w.buf)), size: uint64(len(contents))}
+	checksum := crc32.Update(crc32.Checksum(contents, crc32cTable), crc32cTable, []byte{compression})
+
+	w.buf = append(w.buf, contents...)
+	w.buf = append(w.buf, compression)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, maskSSTChecksum(checksum))
+
+	return ret
+}
+
+func (w *sstTestWriter) finish(index sstBlockHandle, rocksDBVersion int) []byte {
+	meta := w.addBlock(buildSSTBlock(nil, 1, true), sstNoCompression)
+
+	handles := appendSSTHandle(nil, meta)
+	handles = appendSSTHandle(handles, index)
+	handles = append(handles, make([]byte, sstHandlesLen-len(handles))...)
+
+	if rocksDBVersion < 0 {
+		w.buf = append(w.buf, handles...)
+		return binary.LittleEndian.AppendUint64(w.buf, levelDBTableMagic)
+	}
+
+	w.buf = append(w.buf, sstCRC32CChecksum)
+	w.buf = append(w.buf, handles...)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, uint32(rocksDBVersion))
+
+	return binary.LittleEndian.AppendUint64(w.buf, rocksDBTableMagic)
+}
+
+func appendSSTHandle(dst []byte, h sstBlockHandle) []byte {
+	return binary.AppendUvarint(binary.AppendUvarint(dst, h.offset), h.size)
+}
+
+// snappyLiteralBlock encodes the contents as a single Snappy literal.
+func snappyLiteralBlock(contents []byte) []byte {
+	ret := binary.AppendUvarint(nil, uint64(len(contents)))
+	ret = append(ret, 61<<2|snappyLiteral)
+	ret = binary.LittleEndian.AppendUint16(ret, uint16(len(contents)-1))
+
+	return append(ret, contents...)
+}
+
+func writeSSTFile(t *testing.T, src []byte) string {
+	path := filepath.Join(t.TempDir(), "table.sst")
+
+	if err := os.WriteFile(path, src, 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return path
+}
+
+func testSSTBlocks() [][]sstTestEntry {
+	return [][]sstTestEntry{
+		{
+			{sstInternalKey("apple", 5, sstValue), []byte("new")},
+			{sstInternalKey("apple", 3, sstValue), []byte("old")},
+			{sstInternalKey("apricot", 4, sstDeletion), nil},
+			{sstInternalKey("apricot", 2, sstValue), []byte("deleted")},
+		},
+		{
+			{sstInternalKey("banana", 7, sstValue), bytes.Repeat([]byte("y"), 300)},
+		},
+		{
+			{sstInternalKey("cherry", 1, sstValue), []byte("red")},
+		},
+	}
+}
+
+func TestIngestSST(t *testing.T) {
+	want := map[string][]byte{
+		"apple":  []byte("new"),
+		"banana": bytes.Repeat([]byte("y"), 300),
+		"cherry": []byte("red"),
+	}
+
+	t.Run("leveldb", func(t *testing.T) {
+		var w sstTestWriter
+		var index []sstTestEntry
+
+		for i, entries := range testSSTBlocks() {
+			contents := buildSSTBlock(entries, 2, true)
+			compression := byte(sstNoCompression)
+
+			if i == 1 {
+				contents = snappyLiteralBlock(contents)
+				compression = sstSnappyCompression
+			}
+
+			handle := w.addBlock(contents, compression)
+			index = append(index, sstTestEntry{entries[len(entries)-1].key, appendSSTHandle(nil, handle)})
+		}
+
+		src := w.finish(w.addBlock(buildSSTBlock(index, 1, true), sstNoCompression), -1)
+		testIngestSST(t, writeSSTFile(t, src), want)
+	})
+
+	t.Run("rocksdb delta index", func(t *testing.T) {
+		var w sstTestWriter
+		var index []sstTestEntry
+		var prev sstBlockHandle
+
+		for i, entries := range testSSTBlocks() {
+			handle := w.addBlock(buildSSTBlock(entries, 16, true), sstNoCompression)
+			value := appendSSTHandle(nil, handle)
+
+			if i%2 == 1 {
+				value = binary.AppendVarint(nil, int64(handle.size)-int64(prev.size))
+			}
+
+			index = append(index, sstTestEntry{entries[len(entries)-1].key, value})
+			prev = handle
+		}
+
+		src := w.finish(w.addBlock(buildSSTBlock(index, 2, false), sstNoCompression), 5)
+		testIngestSST(t, writeSSTFile(t, src), want)
+	})
+}
+
+func testIngestSST(t *testing.T, path string, want map[string][]byte) {
+	arc := New()
+
+	if err := arc.IngestSST(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if arc.Len() != len(want) {
+		t.Errorf("unexpected length: got:%d, want:%d", arc.Len(), len(want))
+	}
+
+	for key, value := range want {
+		got, err := arc.Get([]byte(key))
+
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", key, err)
+		}
+
+		if !bytes.Equal(got, value) {
+			t.Errorf("unexpected value for %q: got:%q, want:%q", key, got, value)
+		}
+	}
+}
+
+func TestIngestSSTInvalid(t *testing.T) {
+	build := func(compression byte, version int) []byte {
+		var w sstTestWriter
+
+		entries := testSSTBlocks()[2]
+		handle := w.addBlock(buildSSTBlock(entries, 1, true), compression)
+		index := []sstTestEntry{{entries[0].key, appendSSTHandle(nil, handle)}}
+
+		return w.finish(w.addBlock(buildSSTBlock(index, 1, true), sstNoCompression), version)
+	}
+
+	corrupt := build(sstNoCompression, -1)
+	corrupt[3] ^= 0xFF
+
+	badMagic := build(sstNoCompression, -1)
+	badMagic[len(badMagic)-1] ^= 0xFF
+
+	tests := []struct {
+		name string
+		src  []byte
+	}{
+		{"empty", nil},
+		{"checksum", corrupt},
+		{"magic", badMagic},
+		{"compression", build(4, -1)},
+		{"format version", build(sstNoCompression, 6)},
+	}
+
+	for _, test := range tests {
+		if err := New().IngestSST(writeSSTFile(t, test.src)); err != ErrInvalidSST {
+			t.Errorf("%s: unexpected error: got:%v, want:%v", test.name, err, ErrInvalidSST)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import "iter"
+
+// Store is the common interface of the key-value handles, which lets
+// application code be written once against any of them, and be tested against
+// an in-memory database. It is implemented by Arc, including the databases
+// returned by Clone, which act as snapshots, and by Txn. Other packages
+// implement it too, such as arccluster.Cluster.
+type Store interface {
+	// Get retrieves the value of the key. Returns ErrKeyNotFound if the key
+	// does not exist.
+	Get(key []byte) ([]byte, error)
+
+	// Put inserts or updates a key-value pair.
+	Put(key []byte, value []byte) error
+
+	// Delete removes the record of the key. Returns ErrKeyNotFound if the
+	// key does not exist.
+	Delete(key []byte) error
+
+	// Scan returns an iterator over the records whose keys begin with the
+	// given prefix.
+	Scan(prefix []byte) iter.Seq2[[]byte, []byte]
+
+	// Len returns the number of records.
+	Len() int
+}
+
+var (
+	_ Store = (*Arc)(nil)
+	_ Store = (*Txn)(nil)
+)
@@ -0,0 +1,58 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"errors"
+	"testing"
+)
+
+// moveRecord is application code written against a Store.
+func moveRecord(s Store, from []byte, to []byte) error {
+	value, err := s.Get(from)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.Put(to, value); err != nil {
+		return err
+	}
+
+	return s.Delete(from)
+}
+
+func TestStore(t *testing.T) {
+	db := New()
+	db.Put([]byte("apple"), []byte("red"))
+
+	txn := db.Begin()
+	snapshot := db.Clone()
+
+	for _, s := range []Store{db, txn} {
+		if err := moveRecord(s, []byte("apple"), []byte("banana")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := moveRecord(s, []byte("apple"), []byte("banana")); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		for key, value := range s.Scan(nil) {
+			if string(key) != "banana" || string(value) != "red" || s.Len() != 1 {
+				t.Errorf("unexpected record: %q=%q", key, value)
+			}
+		}
+
+		// The transaction starts from the original record.
+		db.Put([]byte("apple"), []byte("red"))
+		db.Delete([]byte("banana"))
+	}
+
+	txn.Rollback()
+
+	if found, _ := snapshot.Has([]byte("banana")); found {
+		t.Errorf("expected the snapshot to be unaffected")
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+//go:build stress
+
+package arc
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	stressWriters     = 8
+	stressReaders     = 4
+	stressKeysPerUser = 256
+)
+
+var stressDuration = flag.Duration("stress.duration", 5*time.Second, "how long TestStress runs its writers")
+
+// stressValue returns a random value. One in three values is large enough to
+// be stored as a blob, and is one of four contents, which makes the writers
+// share the blobs and exercises their reference counts across goroutines.
+func stressValue(rng *rand.Rand) []byte {
+	if rng.IntN(3) == 0 {
+		return bytes.Repeat([]byte{byte('a' + rng.IntN(4))}, 64)
+	}
+
+	return []byte(fmt.Sprintf("v%d", rng.Int()))
+}
+
+// TestStress runs writers and readers concurrently, and verifies the database
+// once they are done. Every writer owns the keys under its own prefix, and
+// tracks the records that it expects to remain, while the readers observe the
+// keys of all the writers. Run it with the race detector:
+//
+//	go test -race -tags stress -run TestStress . -stress.duration=1m
+func TestStress(t *testing.T) {
+	arc := New()
+
+	var writers, readers sync.WaitGroup
+
+	expected := make([]map[string][]byte, stressWriters)
+	stop := make(chan struct{})
+	errs := make(chan error, stressWriters+stressReaders)
+	deadline := time.Now().Add(*stressDuration)
+
+	for w := range stressWriters {
+		expected[w] = map[string][]byte{}
+		writers.Add(1)
+
+		go func(w int) {
+			defer writers.Done()
+
+			rng := rand.New(rand.NewPCG(uint64(w), 0))
+			records := expected[w]
+
+			for time.Now().Before(deadline) {
+				key := fmt.Sprintf("w%02d/%03d", w, rng.IntN(stressKeysPerUser))
+
+				switch rng.IntN(8) {
+				case 0, 1:
+					err := arc.Delete([]byte(key))
+
+					if _, found := records[key]; found && err != nil {
+						errs <- fmt.Errorf("delete %q: %w", key, err)
+						return
+					}
+
+					delete(records, key)
+
+				case 2, 3:
+					value, err := arc.Get([]byte(key))
+
+					if want, found := records[key]; found && (err != nil || !bytes.Equal(value, want)) {
+						errs <- fmt.Errorf("get %q: got:%q, want:%q, err:%v", key, value, want, err)
+						return
+					}
+
+				case 4:
+					// Nobody else writes under the prefix of the writer,
+					// therefore the scan must yield exactly its records.
+					var count int
+
+					for key, value := range arc.Scan([]byte(fmt.Sprintf("w%02d/", w))) {
+						if want, found := records[string(key)]; !found || !bytes.Equal(value, want) {
+							errs <- fmt.Errorf("scan %q: got:%q, want:%q", key, value, want)
+							return
+						}
+
+						count++
+					}
+
+					if count != len(records) {
+						errs <- fmt.Errorf("scan of writer %d: got:%d records, want:%d", w, count, len(records))
+						return
+					}
+
+				default:
+					value := stressValue(rng)
+
+					if err := arc.Put([]byte(key), value); err != nil {
+						errs <- fmt.Errorf("put %q: %w", key, err)
+						return
+					}
+
+					records[key] = value
+				}
+			}
+		}(w)
+	}
+
+	for r := range stressReaders {
+		readers.Add(1)
+
+		go func(r int) {
+			defer readers.Done()
+
+			rng := rand.New(rand.NewPCG(uint64(r), 1))
+
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				// Full scans would starve the writers on machines with few
+				// cores, therefore the readers scan the keys of a writer.
+				prefix := []byte(fmt.Sprintf("w%02d/", rng.IntN(stressWriters)))
+
+				// Every scan must yield its keys in strictly ascending order,
+				// regardless of the writes that happen meanwhile.
+				var prev []byte
+
+				for key := range arc.Scan(prefix) {
+					if prev != nil && bytes.Compare(prev, key) >= 0 {
+						errs <- fmt.Errorf("scan out of order: %q after %q", key, prev)
+						return
+					}
+
+					prev = key
+				}
+
+				arc.Get([]byte(fmt.Sprintf("w%02d/%03d", rng.IntN(stressWriters), rng.IntN(stressKeysPerUser))))
+				arc.Len()
+			}
+		}(r)
+	}
+
+	writers.Wait()
+	close(stop)
+	readers.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if t.Failed() {
+		return
+	}
+
+	want := map[string][]byte{}
+
+	for _, records := range expected {
+		for key, value := range records {
+			want[key] = value
+		}
+	}
+
+	if arc.Len() != len(want) {
+		t.Errorf("unexpected length: got:%d, want:%d", arc.Len(), len(want))
+	}
+
+	for key, value := range arc.Scan(nil) {
+		if !bytes.Equal(value, want[string(key)]) {
+			t.Errorf("unexpected value of %q: got:%q, want:%q", key, value, want[string(key)])
+		}
+
+		delete(want, string(key))
+	}
+
+	if len(want) != 0 {
+		t.Errorf("missing %d records", len(want))
+	}
+
+	if report := arc.BlobGCDryRun(); len(report.Orphaned)+len(report.UnderCounted)+len(report.OverCounted)+len(report.Missing) != 0 {
+		t.Errorf("inconsistent blob store: %+v", report)
+	}
+}
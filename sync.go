@@ -0,0 +1,192 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import "time"
+
+// syncMode identifies when a SyncPolicy persists the writes.
+type syncMode int
+
+const (
+	syncNever syncMode = iota
+	syncAlways
+	syncInterval
+	syncGroup
+)
+
+// SyncPolicy configures when the writes to a file-backed database are
+// persisted, which trades durability for throughput. Writes are always
+// persisted by Save, Sync, and Close. The zero value is SyncNever.
+type SyncPolicy struct {
+	mode     syncMode
+	interval time.Duration // Interval of SyncInterval, or window of SyncGroupCommit.
+}
+
+var (
+	// SyncNever only persists the writes when requested, and on Close.
+	// Writes that were not persisted are lost if the process crashes.
+	SyncNever = SyncPolicy{}
+
+	// SyncAlways persists every write before it returns, which guarantees
+	// that acknowledged writes survive a crash. Every write rewrites the
+	// entire file, therefore it only suits small databases.
+	SyncAlways = SyncPolicy{mode: syncAlways}
+)
+
+// SyncInterval returns a SyncPolicy that persists the writes in the
+// background at the given interval, which bounds the writes that a crash can
+// lose to those of the last interval. The interval must be positive.
+func SyncInterval(d time.Duration) SyncPolicy {
+	return SyncPolicy{mode: syncInterval, interval: d}
+}
+
+// SyncGroupCommit returns a SyncPolicy that persists every write before it
+// returns like SyncAlways, but lets the concurrent writes share a save. The
+// first write to finish waits for the given window, during which the writes
+// that finish join it, and a single save then persists all of them. This
+// raises the throughput of concurrent writes, at the cost of adding up to the
+// window to the latency of every write. The window must be positive.
+func SyncGroupCommit(window time.Duration) SyncPolicy {
+	return SyncPolicy{mode: syncGroup, interval: window}
+}
+
+// valid reports whether the policy was built by SyncInterval or
+// SyncGroupCommit with a positive duration, or is one of the predefined
+// policies.
+func (p SyncPolicy) valid() bool {
+	return (p.mode != syncInterval && p.mode != syncGroup) || p.interval > 0
+}
+
+// commitGroup is a set of writes that are persisted by the same save.
+type commitGroup struct {
+	done chan struct{} // Closed once the save has finished.
+	err  error         // Error of the save, set before done is closed.
+}
+
+// Sync persists the writes that have not been saved yet, and is a no-op if
+// there are none. Unlike Save, the file is left as is if the database has not
+// changed. Returns ErrNotFileBacked if the database was not opened using
+// Open, and ErrReadOnly if it was opened using OpenReadOnly.
+func (a *Arc) Sync() error {
+	if a.path == "" {
+		return ErrNotFileBacked
+	}
+
+	if a.readOnly {
+		return ErrReadOnly
+	}
+
+	return a.sync()
+}
+
+// sync saves the database if it has unsaved writes.
+func (a *Arc) sync() error {
+	a.saveMu.Lock()
+	defer a.saveMu.Unlock()
+
+	a.mu.RLock()
+	dirty := a.dirty()
+	a.mu.RUnlock()
+
+	if !dirty {
+		return nil
+	}
+
+	return a.save()
+}
+
+// syncWrite persists a successful write if the SyncAlways or SyncGroupCommit
+// policy is in effect for a file-backed database. It must be called without
+// holding the lock.
+func (a *Arc) syncWrite(op Op) error {
+	if op == OpGet || a.path == "" || a.readOnly {
+		return nil
+	}
+
+	switch a.opts.Sync.mode {
+	case syncAlways:
+		return a.sync()
+	case syncGroup:
+		return a.groupCommit()
+	default:
+		return nil
+	}
+}
+
+// groupCommit persists the write that the caller has made, along with the
+// writes of the other callers that join the same commitGroup. The caller that
+// opens a group leads it. It waits for the window of the SyncPolicy, closes
+// the group to new members, and saves. The members made their writes before
+// they joined, therefore the save covers them. Writes that finish after the
+// group was closed open the next group.
+func (a *Arc) groupCommit() error {
+	a.groupMu.Lock()
+
+	if g := a.group; g != nil {
+		a.groupMu.Unlock()
+		<-g.done
+
+		return g.err
+	}
+
+	g := &commitGroup{done: make(chan struct{})}
+	a.group = g
+	a.groupMu.Unlock()
+
+	time.Sleep(a.opts.Sync.interval)
+
+	a.groupMu.Lock()
+	a.group = nil
+	a.groupMu.Unlock()
+
+	g.err = a.sync()
+	close(g.done)
+
+	return g.err
+}
+
+// startSyncer starts the goroutine that persists the writes at the interval
+// of the SyncPolicy, unless another policy is in effect.
+func (a *Arc) startSyncer() {
+	if a.opts.Sync.mode != syncInterval {
+		return
+	}
+
+	a.syncStop = make(chan struct{})
+	a.syncDone = make(chan struct{})
+
+	go func() {
+		defer close(a.syncDone)
+
+		ticker := time.NewTicker(a.opts.Sync.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-a.syncStop:
+				return
+			case <-ticker.C:
+				// Errors are retried on the next tick. Close reports the
+				// failure if it persists.
+				a.sync()
+			}
+		}
+	}()
+}
+
+// stopSyncer stops the syncer goroutine, and waits for it to exit. It is safe
+// to call more than once.
+func (a *Arc) stopSyncer() {
+	if a.syncStop == nil {
+		return
+	}
+
+	select {
+	case <-a.syncStop:
+	default:
+		close(a.syncStop)
+	}
+
+	<-a.syncDone
+}
@@ -0,0 +1,159 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSync(t *testing.T) {
+	if err := New().Sync(); err != ErrNotFileBacked {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrNotFileBacked)
+	}
+
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := Open(path)
+	defer arc.Close()
+
+	arc.Put([]byte("key"), []byte("value"))
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected SyncNever to leave the write unsaved")
+	}
+
+	if err := arc.Sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if arc.dirty() || arc.epoch != 1 {
+		t.Errorf("expected the write to be saved")
+	}
+
+	// Sync is a no-op without unsaved writes, unlike Save.
+	if err := arc.Sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if arc.epoch != 1 {
+		t.Errorf("unexpected epoch: got:%d, want:%d", arc.epoch, 1)
+	}
+}
+
+func TestSyncAlways(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := OpenWithOptions(path, Options{Sync: SyncAlways})
+	defer arc.Close()
+
+	arc.Put([]byte("key"), []byte("value"))
+
+	if arc.dirty() || arc.epoch != 1 {
+		t.Fatalf("expected Put to be saved")
+	}
+
+	arc.Delete([]byte("key"))
+
+	if arc.dirty() || arc.epoch != 2 {
+		t.Fatalf("expected Delete to be saved")
+	}
+
+	// Failed writes and reads do not save.
+	arc.Delete([]byte("key"))
+	arc.Get([]byte("key"))
+
+	if arc.epoch != 2 {
+		t.Errorf("unexpected epoch: got:%d, want:%d", arc.epoch, 2)
+	}
+}
+
+func TestSyncInterval(t *testing.T) {
+	if _, err := NewWithOptions(Options{Sync: SyncInterval(0)}); err != ErrInvalidOptions {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrInvalidOptions)
+	}
+
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := OpenWithOptions(path, Options{Sync: SyncInterval(time.Millisecond)})
+	defer arc.Close()
+
+	arc.Put([]byte("key"), []byte("value"))
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		arc.mu.RLock()
+		dirty := arc.dirty()
+		arc.mu.RUnlock()
+
+		if !dirty {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the syncer to save the database")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSyncGroupCommit(t *testing.T) {
+	if _, err := NewWithOptions(Options{Sync: SyncGroupCommit(0)}); err != ErrInvalidOptions {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrInvalidOptions)
+	}
+
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := OpenWithOptions(path, Options{Sync: SyncGroupCommit(50 * time.Millisecond)})
+	defer arc.Close()
+
+	const writers = 16
+
+	var wg sync.WaitGroup
+
+	for i := range writers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			key := []byte(fmt.Sprintf("key%02d", i))
+
+			if err := arc.Put(key, []byte("value")); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			// Every write is in the file before it returns.
+			src, _ := os.ReadFile(path)
+			saved, _ := newArc(Options{})
+
+			if err := saved.readSnapshot(src); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if _, err := saved.Get(key); err != nil {
+				t.Errorf("expected %q to be saved: %v", key, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if arc.dirty() {
+		t.Fatalf("expected every write to be saved")
+	}
+
+	if arc.epoch >= writers {
+		t.Errorf("expected the writes to share saves: got:%d saves for %d writes", arc.epoch, writers)
+	}
+
+	// Failed writes do not save.
+	epoch := arc.epoch
+	arc.Delete([]byte("missing"))
+
+	if arc.epoch != epoch {
+		t.Errorf("unexpected epoch: got:%d, want:%d", arc.epoch, epoch)
+	}
+}
@@ -0,0 +1,425 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// minTierReclaimBytes is the size of the tier file below which the space of
+// the blobs that are no longer demoted is not reclaimed.
+const minTierReclaimBytes = 1 << 20
+
+// TieringPolicy configures the demotion of cold blobs to a file on disk. The
+// values of the demoted blobs are read from the file, therefore they remain
+// available through the same API, at the cost of a disk read. Inline values
+// and keys are always kept in memory, and so are the blobs that hold large
+// keys. The zero value disables tiering.
+type TieringPolicy struct {
+	// Path of the file that holds the demoted blobs. The file is created by
+	// the first demotion, and removed once the database and its clones are
+	// closed. It must not be shared with other databases. Empty disables
+	// tiering.
+	Path string
+
+	// Window is how long a blob can go unread before it is demoted. A
+	// demoted blob that is read is promoted back into memory by the next
+	// sweep. Reads are those that look up a record, such as Get, whereas
+	// iterators and saves do not count.
+	Window time.Duration
+
+	// Interval is how often the blobs are demoted and promoted. Zero
+	// selects the Window.
+	Interval time.Duration
+}
+
+// valid returns true if the policy is disabled or has a positive Window.
+func (p TieringPolicy) valid() bool {
+	if p.Window < 0 || p.Interval < 0 {
+		return false
+	}
+
+	return p.Path == "" || p.Window > 0
+}
+
+// TierStats reports the state of the TieringPolicy.
+type TierStats struct {
+	// HotBlobs is the number of blobs held in memory, and HotBytes is the
+	// total size of their values.
+	HotBlobs int
+	HotBytes int64
+
+	// ColdBlobs is the number of blobs demoted to the tier file, and
+	// ColdBytes is the total size of their values.
+	ColdBlobs int
+	ColdBytes int64
+
+	// FileBytes is the size of the tier file, which includes the space of
+	// the blobs that were promoted or deleted until it is reclaimed.
+	FileBytes int64
+}
+
+// blobTier is the file that holds the demoted blobs. It is shared by a
+// database and its clones, whose blobs may refer to the same demoted values,
+// therefore it has a lock of its own, and is only closed once every database
+// has released it.
+type blobTier struct {
+	path       string
+	encryption EncryptionProvider
+
+	// Guards the fields below.
+	mu sync.Mutex
+
+	// The file, which is nil until the first demotion, and the offset at
+	// which the next demoted blob is written.
+	file *os.File
+	end  int64
+
+	// Number of databases that hold the tier.
+	refs int
+}
+
+// coldBlob locates the value of a demoted blob in the tier file. The value is
+// encrypted with the EncryptionProvider of the database, if any.
+type coldBlob struct {
+	tier      *blobTier
+	file      *os.File
+	id        blobID
+	offset    int64
+	sealedLen int
+	size      int // Length of the value.
+}
+
+// newBlobTier returns the tier of a database with the given options.
+func newBlobTier(opts Options) *blobTier {
+	return &blobTier{path: opts.Tiering.Path, encryption: opts.Encryption, refs: 1}
+}
+
+// retain adds a reference to the tier for a clone, and returns the tier.
+func (t *blobTier) retain() *blobTier {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.refs++
+
+	return t
+}
+
+// release drops a reference to the tier, and removes the file once nobody
+// holds the tier.
+func (t *blobTier) release() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.refs--; t.refs > 0 || t.file == nil {
+		return nil
+	}
+
+	err := t.file.Close()
+	t.file = nil
+
+	if rmErr := os.Remove(t.path); !errors.Is(rmErr, fs.ErrNotExist) {
+		err = errors.Join(err, rmErr)
+	}
+
+	return err
+}
+
+// demote writes the value of the blob to the end of the tier file, and
+// returns its location.
+func (t *blobTier) demote(id blobID, value []byte) (*coldBlob, error) {
+	sealed := value
+
+	if t.encryption != nil {
+		var err error
+
+		if sealed, err = t.encryption.Encrypt(value, id.Slice()); err != nil {
+			return nil, err
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.file == nil {
+		file, err := os.OpenFile(t.path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+
+		if err != nil {
+			return nil, err
+		}
+
+		t.file = file
+		t.end = 0
+	}
+
+	if _, err := t.file.WriteAt(sealed, t.end); err != nil {
+		return nil, err
+	}
+
+	ret := &coldBlob{tier: t, file: t.file, id: id, offset: t.end, sealedLen: len(sealed), size: len(value)}
+	t.end += int64(len(sealed))
+
+	return ret, nil
+}
+
+// fileSize returns the size of the tier file.
+func (t *blobTier) fileSize() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.end
+}
+
+// load reads the value of the demoted blob from the tier file.
+func (c *coldBlob) load() ([]byte, error) {
+	sealed := make([]byte, c.sealedLen)
+
+	if _, err := c.file.ReadAt(sealed, c.offset); err != nil {
+		return nil, err
+	}
+
+	if c.tier.encryption == nil {
+		return sealed, nil
+	}
+
+	return c.tier.encryption.Decrypt(sealed, c.id.Slice())
+}
+
+// touchBlob records a read of the blob value of the node, which keeps the
+// blob in memory, or has the next sweep promote it if it was demoted. It is a
+// no-op unless the Tiering option is set. The caller must hold the read lock.
+func (a *Arc) touchBlob(n *node) {
+	if a.tier == nil || !n.blobValue {
+		return
+	}
+
+	if id, err := sliceToBlobID(n.data); err == nil {
+		if b, found := a.blobs[id]; found {
+			b.lastRead.Store(a.now().UnixNano())
+		}
+	}
+}
+
+// TierStats returns the state of the TieringPolicy. It is the zero value if
+// tiering is disabled.
+func (a *Arc) TierStats() TierStats {
+	a.rlock()
+	defer a.runlock()
+
+	var ret TierStats
+
+	if a.tier == nil {
+		return ret
+	}
+
+	for _, b := range a.blobs {
+		if b.cold != nil {
+			ret.ColdBlobs++
+			ret.ColdBytes += int64(b.size())
+		} else {
+			ret.HotBlobs++
+			ret.HotBytes += int64(b.size())
+		}
+	}
+
+	ret.FileBytes = a.tier.fileSize()
+
+	return ret
+}
+
+// startTiering starts the goroutine that demotes and promotes the blobs,
+// unless the Tiering option is unset. It is not started for read-only
+// databases, whose readers do not hold the lock that the sweeps rely on.
+func (a *Arc) startTiering() {
+	if a.tier == nil {
+		return
+	}
+
+	a.tierStop = make(chan struct{})
+	a.tierDone = make(chan struct{})
+
+	interval := a.opts.Tiering.Interval
+
+	if interval == 0 {
+		interval = a.opts.Tiering.Window
+	}
+
+	go func() {
+		defer close(a.tierDone)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-a.tierStop:
+				return
+			case <-ticker.C:
+				// Errors are retried on the next tick.
+				if err := a.sweepTier(); err != nil {
+					a.log(slog.LevelWarn, "blob tiering failed", "path", a.opts.Tiering.Path, "err", err)
+				}
+			}
+		}
+	}()
+}
+
+// stopTiering stops the tiering goroutine, waits for it to exit, and releases
+// the tier. It is safe to call more than once.
+func (a *Arc) stopTiering() error {
+	if a.tierStop == nil {
+		return nil
+	}
+
+	select {
+	case <-a.tierStop:
+		return nil
+	default:
+		close(a.tierStop)
+	}
+
+	<-a.tierDone
+
+	return a.tier.release()
+}
+
+// sweepTier demotes the blobs that have not been read within the Window, and
+// promotes the demoted blobs that have. Blobs that were not swept before
+// count as read by the sweep, therefore new blobs stay in memory for at least
+// the Window. The tier file is rewritten once most of it is taken up by blobs
+// that are no longer demoted, unless a clone still refers to it.
+func (a *Arc) sweepTier() error {
+	a.lock()
+	defer a.mu.Unlock()
+
+	now := a.now().UnixNano()
+	cutoff := now - int64(a.opts.Tiering.Window)
+
+	// The blobs that hold large keys are read by every iterator.
+	largeKeys := map[blobID]bool{}
+
+	a.walkLargeKeys(func(id blobID) {
+		largeKeys[id] = true
+	})
+
+	var demoted, promoted int
+	var live int64
+
+	for id, b := range a.blobs {
+		last := b.lastRead.Load()
+
+		if last == 0 {
+			b.lastRead.Store(now)
+			last = now
+		}
+
+		switch {
+		case b.cold == nil && last < cutoff && !largeKeys[id]:
+			cold, err := a.tier.demote(id, b.value)
+
+			if err != nil {
+				return err
+			}
+
+			b.value = nil
+			b.cold = cold
+			demoted++
+		case b.cold != nil && last >= cutoff:
+			value, err := b.load()
+
+			if err != nil {
+				return err
+			}
+
+			b.value = value
+			b.cold = nil
+			promoted++
+		}
+
+		if b.cold != nil {
+			live += int64(b.cold.sealedLen)
+		}
+	}
+
+	if demoted > 0 || promoted > 0 {
+		a.log(slog.LevelDebug, "swept blob tier", "demoted", demoted, "promoted", promoted)
+	}
+
+	if size := a.tier.fileSize(); size >= minTierReclaimBytes && size > 2*live {
+		return a.reclaimTier()
+	}
+
+	return nil
+}
+
+// reclaimTier rewrites the tier file with only the demoted blobs of the
+// database. It is a no-op if the tier is shared with a clone. The caller must
+// hold the write lock.
+func (a *Arc) reclaimTier() error {
+	t := a.tier
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.refs > 1 {
+		return nil
+	}
+
+	tmpPath := t.path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+
+	if err != nil {
+		return err
+	}
+
+	relocated := map[*blob]*coldBlob{}
+	var end int64
+
+	for _, b := range a.blobs {
+		if b.cold == nil {
+			continue
+		}
+
+		sealed := make([]byte, b.cold.sealedLen)
+
+		if _, err = b.cold.file.ReadAt(sealed, b.cold.offset); err == nil {
+			_, err = file.WriteAt(sealed, end)
+		}
+
+		if err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+
+		copied := *b.cold
+		copied.file = file
+		copied.offset = end
+		relocated[b] = &copied
+		end += int64(len(sealed))
+	}
+
+	if err := os.Rename(tmpPath, t.path); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	for b, cold := range relocated {
+		b.cold = cold
+	}
+
+	a.log(slog.LevelDebug, "reclaimed blob tier", "path", t.path, "size", t.end, "new_size", end)
+
+	t.file.Close()
+	t.file = file
+	t.end = end
+
+	return nil
+}
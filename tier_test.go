@@ -0,0 +1,251 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTieredArc returns an in-memory database whose blobs are demoted once
+// they have not been read for an hour, along with the path of its tier file
+// and a function that advances its clock. The blobs are only swept when the
+// test calls sweepTier.
+func newTieredArc(t *testing.T, opts Options) (*Arc, string, func(time.Duration)) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "blobs.tier")
+	opts.Tiering = TieringPolicy{Path: path, Window: time.Hour, Interval: 24 * time.Hour}
+	arc, err := NewWithOptions(opts)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now := time.Now()
+	arc.now = func() time.Time { return now }
+
+	return arc, path, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestTiering(t *testing.T) {
+	arc, path, advance := newTieredArc(t, Options{})
+	hot := bytes.Repeat([]byte("h"), inlineValueThreshold*2)
+
+	arc.Put([]byte("cold"), blobValueX())
+	arc.Put([]byte("hot"), hot)
+	arc.Put([]byte("inline"), []byte("value"))
+
+	// New blobs stay in memory for at least the window.
+	arc.sweepTier()
+	advance(30 * time.Minute)
+	arc.Get([]byte("hot"))
+	advance(45 * time.Minute)
+
+	if err := arc.sweepTier(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stats := arc.TierStats()
+	want := TierStats{HotBlobs: 1, HotBytes: int64(len(hot)), ColdBlobs: 1, ColdBytes: int64(len(blobValueX())), FileBytes: int64(len(blobValueX()))}
+
+	if stats != want {
+		t.Fatalf("unexpected stats: got:%+v, want:%+v", stats, want)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Size() != stats.FileBytes {
+		t.Fatalf("unexpected tier file: %v", err)
+	}
+
+	// Demoted blobs are read from the tier file, and promoted by the next
+	// sweep.
+	if got, err := arc.Get([]byte("cold")); err != nil || !bytes.Equal(got, blobValueX()) {
+		t.Fatalf("unexpected value: err:%v", err)
+	}
+
+	arc.sweepTier()
+
+	if stats := arc.TierStats(); stats.ColdBlobs != 0 || stats.HotBlobs != 2 {
+		t.Errorf("expected the blob to be promoted: %+v", stats)
+	}
+
+	if got := arc.BlobStats().PhysicalBytes; got != int64(len(hot)+len(blobValueX())) {
+		t.Errorf("unexpected physical bytes: %d", got)
+	}
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the tier file to be removed: %v", err)
+	}
+}
+
+func TestTieringSave(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.arc")
+	tierPath := filepath.Join(t.TempDir(), "blobs.tier")
+	provider, _ := NewAESGCMProvider(map[uint32][]byte{1: make([]byte, 32)}, 1)
+	opts := Options{Encryption: provider, Tiering: TieringPolicy{Path: tierPath, Window: time.Hour, Interval: 24 * time.Hour}}
+
+	arc, err := OpenWithOptions(dbPath, opts)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now := time.Now()
+	arc.now = func() time.Time { return now }
+	arc.Put([]byte("key"), blobValueX())
+	arc.sweepTier()
+	now = now.Add(2 * time.Hour)
+	arc.sweepTier()
+
+	if stats := arc.TierStats(); stats.ColdBlobs != 1 {
+		t.Fatalf("expected the blob to be demoted: %+v", stats)
+	}
+
+	// Demoted blobs are encrypted like the database file.
+	if src, _ := os.ReadFile(tierPath); bytes.Contains(src, blobValueX()) {
+		t.Errorf("expected the tier file to be encrypted")
+	}
+
+	// A clone keeps reading from the tier file after the database is closed.
+	clone := arc.Clone()
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, _ := clone.Get([]byte("key")); !bytes.Equal(got, blobValueX()) {
+		t.Errorf("unexpected value of the clone")
+	}
+
+	clone.Close()
+
+	if _, err := os.Stat(tierPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the tier file to be removed: %v", err)
+	}
+
+	// The database file holds the demoted blobs.
+	reopened, err := OpenWithOptions(dbPath, opts)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer reopened.Close()
+
+	if got, _ := reopened.Get([]byte("key")); !bytes.Equal(got, blobValueX()) {
+		t.Errorf("unexpected value")
+	}
+}
+
+func TestTieringCloseUnsaved(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.arc")
+	opts := Options{Tiering: TieringPolicy{Path: filepath.Join(t.TempDir(), "blobs.tier"), Window: time.Hour, Interval: 24 * time.Hour}}
+
+	arc, err := OpenWithOptions(dbPath, opts)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now := time.Now()
+	arc.now = func() time.Time { return now }
+	arc.Put([]byte("cold"), blobValueX())
+	arc.sweepTier()
+	now = now.Add(2 * time.Hour)
+	arc.sweepTier()
+
+	if stats := arc.TierStats(); stats.ColdBlobs != 1 {
+		t.Fatalf("expected the blob to be demoted: %+v", stats)
+	}
+
+	// The final save reads the demoted blob from the tier file.
+	arc.Put([]byte("unsaved"), []byte("value"))
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := OpenWithOptions(dbPath, opts)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer reopened.Close()
+
+	if got, _ := reopened.Get([]byte("cold")); !bytes.Equal(got, blobValueX()) {
+		t.Errorf("unexpected value of the demoted blob")
+	}
+
+	if got, _ := reopened.Get([]byte("unsaved")); string(got) != "value" {
+		t.Errorf("unexpected value of the unsaved write: %q", got)
+	}
+}
+
+func TestTieringReclaim(t *testing.T) {
+	arc, _, advance := newTieredArc(t, Options{})
+	value := bytes.Repeat([]byte("v"), minTierReclaimBytes/4)
+
+	for i := range 8 {
+		arc.Put([]byte{'k', byte(i)}, append(value, byte(i)))
+	}
+
+	arc.sweepTier()
+	advance(2 * time.Hour)
+	arc.sweepTier()
+
+	// Reading most of the blobs promotes them, after which most of the tier
+	// file is taken up by blobs that are no longer demoted.
+	for i := range 7 {
+		arc.Get([]byte{'k', byte(i)})
+	}
+
+	if err := arc.sweepTier(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stats := arc.TierStats()
+
+	if stats.ColdBlobs != 1 || stats.FileBytes != stats.ColdBytes {
+		t.Errorf("expected the tier file to be reclaimed: %+v", stats)
+	}
+
+	if got, _ := arc.Get([]byte{'k', 7}); !bytes.Equal(got, append(value, 7)) {
+		t.Errorf("unexpected value")
+	}
+
+	arc.Close()
+}
+
+func TestTieringOptions(t *testing.T) {
+	invalid := []TieringPolicy{
+		{Path: "blobs.tier"},
+		{Path: "blobs.tier", Window: -time.Second},
+		{Path: "blobs.tier", Window: time.Second, Interval: -time.Second},
+	}
+
+	for _, policy := range invalid {
+		if _, err := NewWithOptions(Options{Tiering: policy}); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("unexpected error with %+v: %v", policy, err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "test.arc")
+
+	if _, err := OpenContainerWithOptions(path, Options{Tiering: TieringPolicy{Path: path + ".tier", Window: time.Hour}}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if stats := New().TierStats(); stats != (TierStats{}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"iter"
+	"slices"
+	"time"
+)
+
+// Tombstone records the deletion of a record, which is retained for the
+// Options.TombstoneRetention after the record was deleted.
+type Tombstone struct {
+	Key       []byte    // Key of the deleted record.
+	Seq       uint64    // Sequence number of the deletion.
+	DeletedAt time.Time // Time of the deletion.
+}
+
+// tombstone is the in-memory representation of a Tombstone.
+type tombstone struct {
+	key       []byte // Original spelling of the key.
+	seq       uint64
+	deletedAt time.Time
+}
+
+// Tombstones returns an iterator over the tombstones of the deleted records
+// whose keys begin with the given prefix, in the order of the database. The
+// tombstones are captured when the iteration begins. Comparing their sequence
+// numbers against the Seq of a previous read tells which deletions happened
+// since, which lets consumers that were offline catch up on the deletions
+// rather than only on the records that remain. Records that were written
+// again after their deletion have no tombstone.
+func (a *Arc) Tombstones(prefix []byte) iter.Seq[Tombstone] {
+	prefix = a.transformKey(prefix)
+
+	return func(yield func(Tombstone) bool) {
+		var ret []Tombstone
+
+		a.rlock()
+
+		for key, ts := range a.tombstones {
+			if !bytes.HasPrefix([]byte(key), prefix) || a.authorize(OpGet, ts.key) != nil {
+				continue
+			}
+
+			ret = append(ret, Tombstone{Key: bytes.Clone(ts.key), Seq: ts.seq, DeletedAt: ts.deletedAt})
+		}
+
+		a.runlock()
+
+		slices.SortFunc(ret, func(x, y Tombstone) int {
+			return a.compareKeys(a.foldKey(x.Key), a.foldKey(y.Key))
+		})
+
+		for _, ts := range ret {
+			if !yield(ts) {
+				return
+			}
+		}
+	}
+}
+
+// buryRecord leaves a tombstone for the record that was just deleted, unless
+// the TombstoneRetention option is zero. It must be called before the
+// metadata of the record is discarded, since the tombstone keeps the original
+// spelling of the key. The caller must hold the write lock.
+func (a *Arc) buryRecord(key []byte) {
+	if a.opts.TombstoneRetention == 0 {
+		return
+	}
+
+	if a.tombstones == nil {
+		a.tombstones = map[string]tombstone{}
+	}
+
+	original := a.originalKey(key)
+
+	// The full key of a large key is released along with its record, and
+	// the tombstone keeps the stored form, which fits the file format.
+	if a.isLargeKey(key) {
+		original = key
+	}
+
+	a.tombstones[string(key)] = tombstone{
+		key:       bytes.Clone(original),
+		seq:       a.seq,
+		deletedAt: a.now(),
+	}
+}
+
+// retained returns true if the tombstone is still within the retention period
+// as of the given time.
+func (a *Arc) retained(ts tombstone, now time.Time) bool {
+	return now.Before(ts.deletedAt.Add(a.opts.TombstoneRetention))
+}
+
+// purgeTombstones discards the tombstones whose retention period had elapsed
+// by the given time. The caller must hold the write lock.
+func (a *Arc) purgeTombstones(now time.Time) {
+	for key, ts := range a.tombstones {
+		if !a.retained(ts, now) {
+			delete(a.tombstones, key)
+		}
+	}
+}
+
+// serializeTombstones serializes the tombstone section, which consists of the
+// number of tombstones, the tombstones in key order, and the checksum of the
+// preceding bytes. Each tombstone holds the key length, the key, the sequence
+// number, and the encoded deletion time. Tombstones whose retention period has
+// elapsed are left out. The caller must hold the read lock.
+func (a *Arc) serializeTombstones() ([]byte, error) {
+	now := a.now()
+	keys := make([]string, 0, len(a.tombstones))
+
+	for key, ts := range a.tombstones {
+		if a.retained(ts, now) {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+
+	ret := binary.LittleEndian.AppendUint64(nil, uint64(len(keys)))
+
+	for _, key := range keys {
+		ts := a.tombstones[key]
+		ret = binary.LittleEndian.AppendUint16(ret, uint16(len(ts.key)))
+		ret = append(ret, ts.key...)
+		ret = binary.LittleEndian.AppendUint64(ret, ts.seq)
+		ret = append(ret, encodeExpiration(ts.deletedAt)...)
+	}
+
+	checksum, err := computeChecksum(ret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return binary.LittleEndian.AppendUint32(ret, checksum), nil
+}
+
+// readTombstones loads the tombstone section produced by serializeTombstones.
+// The records must already be loaded, since a tombstone must not refer to an
+// existing record.
+func (a *Arc) readTombstones(src []byte) error {
+	if len(src) < sizeOfUint64+checksumLen {
+		return ErrCorrupted
+	}
+
+	checksumPos := len(src) - checksumLen
+	checksum, err := computeChecksum(src[:checksumPos])
+
+	if err != nil {
+		return err
+	}
+
+	if checksum != binary.LittleEndian.Uint32(src[checksumPos:]) {
+		return ErrInvalidChecksum
+	}
+
+	r := bytes.NewReader(src[:checksumPos])
+
+	var count uint64
+
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return ErrCorrupted
+	}
+
+	for i := uint64(0); i < count; i++ {
+		var keyLen uint16
+
+		if err := binary.Read(r, binary.LittleEndian, &keyLen); err != nil {
+			return ErrCorrupted
+		}
+
+		entry := make([]byte, int(keyLen)+sizeOfUint64+sizeOfUint64)
+
+		if _, err := io.ReadFull(r, entry); err != nil {
+			return ErrCorrupted
+		}
+
+		key := entry[:keyLen]
+		deletedAt, err := decodeExpiration(entry[int(keyLen)+sizeOfUint64:])
+
+		if err != nil {
+			return err
+		}
+
+		if keyLen == 0 || deletedAt.IsZero() || a.isLiveRecord(a.foldKey(key)) {
+			return ErrCorrupted
+		}
+
+		if a.tombstones == nil {
+			a.tombstones = map[string]tombstone{}
+		}
+
+		a.tombstones[string(a.foldKey(key))] = tombstone{
+			key:       key,
+			seq:       binary.LittleEndian.Uint64(entry[keyLen:]),
+			deletedAt: deletedAt,
+		}
+	}
+
+	if r.Len() != 0 {
+		return ErrCorrupted
+	}
+
+	return nil
+}
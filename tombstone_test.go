@@ -0,0 +1,125 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// collectTombstones returns the keys of the tombstones under the prefix.
+func collectTombstones(arc *Arc, prefix []byte) []string {
+	var ret []string
+
+	for ts := range arc.Tombstones(prefix) {
+		ret = append(ret, string(ts.Key))
+	}
+
+	return ret
+}
+
+func TestTombstones(t *testing.T) {
+	arc := New()
+	arc.Put([]byte("key"), []byte("value"))
+	arc.Delete([]byte("key"))
+
+	if got := collectTombstones(arc, nil); len(got) != 0 {
+		t.Errorf("expected no tombstones when the option is disabled: %q", got)
+	}
+
+	arc, _ = NewWithOptions(Options{TombstoneRetention: time.Hour, CaseInsensitiveKeys: true})
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	arc.now = func() time.Time { return now }
+
+	for _, key := range []string{"user:Bob", "user:alice", "user:carol", "group:admins"} {
+		arc.Put([]byte(key), []byte("value"))
+	}
+
+	arc.Delete([]byte("USER:CAROL"))
+	seq := arc.Seq()
+	arc.Delete([]byte("user:bob"))
+	arc.Delete([]byte("group:admins"))
+
+	// Deleting a missing key leaves no tombstone.
+	arc.Delete([]byte("user:dave"))
+
+	got := collectTombstones(arc, []byte("USER:"))
+	want := []string{"user:Bob", "user:carol"}
+
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("unexpected tombstones: got:%q, want:%q", got, want)
+	}
+
+	for ts := range arc.Tombstones([]byte("user:bob")) {
+		if ts.Seq != seq+1 || !ts.DeletedAt.Equal(now) {
+			t.Errorf("unexpected tombstone: %+v", ts)
+		}
+	}
+
+	// Writing the record again removes its tombstone.
+	arc.Put([]byte("user:carol"), []byte("value"))
+
+	if got := collectTombstones(arc, nil); len(got) != 2 {
+		t.Errorf("unexpected tombstones: %q", got)
+	}
+
+	arc.sweepExpired()
+
+	if got := collectTombstones(arc, nil); len(got) != 2 {
+		t.Errorf("expected the tombstones to be retained: %q", got)
+	}
+
+	now = now.Add(time.Hour)
+	arc.sweepExpired()
+
+	if got := collectTombstones(arc, nil); len(got) != 0 {
+		t.Errorf("expected the tombstones to be discarded: %q", got)
+	}
+}
+
+func TestTombstonePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	opts := Options{TombstoneRetention: time.Hour}
+	arc, _ := OpenWithOptions(path, opts)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	arc.now = func() time.Time { return now }
+
+	arc.Put([]byte("old"), []byte("value"))
+	arc.Delete([]byte("old"))
+	now = now.Add(30 * time.Minute)
+	arc.Put([]byte("new"), []byte("value"))
+	arc.Delete([]byte("new"))
+	arc.Put([]byte("live"), []byte("value"))
+	now = now.Add(45 * time.Minute)
+
+	// The save leaves out and discards the elapsed tombstone.
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := collectTombstones(arc, nil); len(got) != 1 || got[0] != "new" {
+		t.Errorf("unexpected tombstones: %q", got)
+	}
+
+	arc, err := OpenWithOptions(path, opts)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer arc.Close()
+
+	got := collectTombstones(arc, nil)
+
+	if len(got) != 1 || got[0] != "new" {
+		t.Fatalf("unexpected tombstones: %q", got)
+	}
+
+	for ts := range arc.Tombstones(nil) {
+		if ts.Seq != 4 || !ts.DeletedAt.Equal(time.Date(2025, 1, 1, 0, 30, 0, 0, time.UTC)) {
+			t.Errorf("unexpected tombstone: %+v", ts)
+		}
+	}
+}
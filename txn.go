@@ -0,0 +1,736 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"iter"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTxnClosed is returned when a transaction is used after it was
+	// committed or rolled back.
+	ErrTxnClosed = errors.New("transaction closed")
+
+	// ErrConflict is returned by Commit when a record that an optimistic
+	// transaction has read was modified by another writer in the meantime.
+	ErrConflict = errors.New("transaction conflict")
+
+	// ErrTxnExpired is returned when a transaction is used after it was
+	// rolled back for exceeding the MaxTxnDuration option.
+	ErrTxnExpired = errors.New("transaction deadline exceeded")
+
+	// ErrTxnTooLarge is returned when a write would exceed the MaxTxnWrites
+	// or MaxTxnBytes option. The transaction remains usable.
+	ErrTxnTooLarge = errors.New("transaction is too large")
+)
+
+// TxnMode selects how a transaction is isolated from the other writers.
+type TxnMode int
+
+const (
+	// TxnOptimistic transactions do not block the other readers and
+	// writers. They track the keys that they read, and Commit fails with
+	// ErrConflict if any of them was modified since it was read, in which
+	// case the transaction can be retried. Only the keys read by Get and
+	// Delete are tracked, therefore writes to keys that were never read are
+	// not conflicts.
+	TxnOptimistic TxnMode = iota
+
+	// TxnPessimistic transactions hold the write lock from Begin until
+	// Commit or Rollback, which blocks all other readers and writers, and
+	// therefore never conflict. They suit short transactions. Methods of
+	// the Arc must not be called while a pessimistic transaction is open,
+	// since they would wait for the transaction forever.
+	TxnPessimistic
+)
+
+// Txn is a transaction, which buffers its writes in an overlay until they are
+// applied atomically by Commit. Reads of the transaction observe its own
+// uncommitted writes layered over the database, while other readers only
+// observe them once committed. Every transaction must be ended by Commit or
+// Rollback, unless it is rolled back by the MaxTxnDuration option.
+type Txn struct {
+	arc    *Arc
+	mode   TxnMode
+	writes map[string]txnWrite
+	size   int // Total size of the buffered keys and values.
+
+	// Guards closed against the deadline timer, which rolls back the
+	// transaction from its own goroutine.
+	mu      sync.Mutex
+	closed  bool
+	expired bool
+	timer   *time.Timer
+
+	// Maps the keys read by an optimistic transaction to the sequence
+	// number of the database as of their first read.
+	reads map[string]uint64
+}
+
+// txnWrite is a write buffered by a transaction.
+type txnWrite struct {
+	key     []byte // Key as given to putRecord, before its case is folded.
+	value   []byte
+	deleted bool
+}
+
+// Begin starts an optimistic transaction.
+func (a *Arc) Begin() *Txn {
+	return a.BeginWithMode(TxnOptimistic)
+}
+
+// BeginWithMode starts a transaction in the given mode. Pessimistic
+// transactions block until the write lock is available.
+func (a *Arc) BeginWithMode(mode TxnMode) *Txn {
+	ret := &Txn{arc: a, mode: mode, writes: map[string]txnWrite{}}
+
+	a.mu.Lock()
+
+	if mode == TxnOptimistic {
+		ret.reads = map[string]uint64{}
+
+		if a.optimisticTxns == 0 {
+			a.txnWrites = map[string]uint64{}
+		}
+
+		a.optimisticTxns++
+		a.mu.Unlock()
+	} else {
+		a.unshare()
+	}
+
+	if d := a.opts.MaxTxnDuration; d > 0 {
+		ret.timer = time.AfterFunc(d, ret.expire)
+	}
+
+	return ret
+}
+
+// Get retrieves the value that matches the given key, as seen by the
+// transaction. Returns ErrKeyNotFound if the key does not exist, or was
+// deleted by the transaction.
+func (t *Txn) Get(key []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	key = t.arc.applyKeyTransform(key)
+
+	if err := t.arc.authorize(OpGet, key); err != nil {
+		return nil, err
+	}
+
+	return t.get(t.arc.foldKey(key))
+}
+
+// get retrieves the value of the transformed key, as seen by the transaction.
+func (t *Txn) get(key []byte) ([]byte, error) {
+	if w, found := t.writes[string(key)]; found {
+		if w.deleted {
+			return nil, ErrKeyNotFound
+		}
+
+		return w.value, nil
+	}
+
+	if err := t.arc.checkKey(key); err != nil {
+		return nil, err
+	}
+
+	// Pessimistic transactions already hold the write lock.
+	if t.mode == TxnPessimistic {
+		return t.arc.lookup(key)
+	}
+
+	t.arc.rlock()
+	defer t.arc.runlock()
+
+	if _, found := t.reads[string(key)]; !found {
+		t.reads[string(key)] = t.arc.seq
+	}
+
+	return t.arc.lookup(key)
+}
+
+// Put inserts or updates a key-value pair in the transaction. Like Arc.Put,
+// the value is retained, therefore the caller must not modify it afterwards.
+// Returns ErrTxnTooLarge if the write exceeds the transaction limits.
+func (t *Txn) Put(key []byte, value []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkOpen(); err != nil {
+		return err
+	}
+
+	if t.arc.readOnly {
+		return ErrReadOnly
+	}
+
+	key = t.arc.applyKeyTransform(key)
+
+	if err := t.arc.authorize(OpPut, key); err != nil {
+		return err
+	}
+
+	if err := t.arc.checkKey(key); err != nil {
+		return err
+	}
+
+	if err := t.arc.checkValue(value); err != nil {
+		return err
+	}
+
+	return t.buffer(txnWrite{key: key, value: value}, t.arc.foldKey(key))
+}
+
+// Delete removes the record that matches the given key in the transaction.
+// Returns ErrKeyNotFound if the key does not exist, as seen by the
+// transaction, and ErrTxnTooLarge if the write exceeds the transaction limits.
+func (t *Txn) Delete(key []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkOpen(); err != nil {
+		return err
+	}
+
+	if t.arc.readOnly {
+		return ErrReadOnly
+	}
+
+	key = t.arc.applyKeyTransform(key)
+
+	if err := t.arc.authorize(OpDelete, key); err != nil {
+		return err
+	}
+
+	key = t.arc.foldKey(key)
+
+	if _, err := t.get(key); err != nil {
+		return err
+	}
+
+	return t.buffer(txnWrite{key: key, deleted: true}, key)
+}
+
+// Scan returns an iterator over the records whose keys begin with the given
+// prefix, as seen by the transaction, in the order of Arc.Scan. The records
+// are captured when the iteration begins, therefore the loop body may write
+// to the transaction. Unlike Get, the scanned keys are not tracked for
+// conflicts. Yields nothing if the transaction is closed.
+func (t *Txn) Scan(prefix []byte) iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		for _, r := range t.collect(prefix) {
+			if !yield(r.key, r.value) {
+				return
+			}
+		}
+	}
+}
+
+// collect captures the records that Scan yields.
+func (t *Txn) collect(prefix []byte) []record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.checkOpen() != nil {
+		return nil
+	}
+
+	a := t.arc
+	opts := ScanOptions{Prefix: a.transformKey(prefix), Direction: a.orderedDirection(Forward)}
+	match := a.scanMatch(nil)
+
+	type entry struct {
+		treeKey []byte
+		record
+	}
+
+	var entries []entry
+
+	if t.mode == TxnOptimistic {
+		a.rlock()
+		defer a.runlock()
+	}
+
+	a.walkPrefix(opts.Prefix, func(key []byte, n *node) bool {
+		if _, written := t.writes[string(key)]; !written && (match == nil || match(key)) {
+			entries = append(entries, entry{key, a.scanRecord(opts, key, n)})
+		}
+
+		return true
+	})
+
+	for key, w := range t.writes {
+		if !w.deleted && bytes.HasPrefix([]byte(key), opts.Prefix) && (match == nil || match([]byte(key))) {
+			entries = append(entries, entry{[]byte(key), record{key: w.key, value: w.value}})
+		}
+	}
+
+	slices.SortFunc(entries, func(x entry, y entry) int {
+		return bytes.Compare(x.treeKey, y.treeKey)
+	})
+
+	if opts.Direction == Reverse {
+		slices.Reverse(entries)
+	}
+
+	ret := make([]record, len(entries))
+
+	for i, e := range entries {
+		ret[i] = e.record
+	}
+
+	return ret
+}
+
+// Len returns the number of records, as seen by the transaction. Returns zero
+// if the transaction is closed.
+func (t *Txn) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.checkOpen() != nil {
+		return 0
+	}
+
+	a := t.arc
+
+	if t.mode == TxnOptimistic {
+		a.rlock()
+		defer a.runlock()
+	}
+
+	ret := a.numRecords - a.countExpired(nil)
+
+	for key, w := range t.writes {
+		n, _, err := a.findNodeAndParent([]byte(key))
+		exists := err == nil && n.isRecord && !a.expired([]byte(key))
+
+		if w.deleted && exists {
+			ret--
+		} else if !w.deleted && !exists {
+			ret++
+		}
+	}
+
+	return ret
+}
+
+// buffer adds the write to the overlay under the given case-folded key,
+// replacing the previous write to the same key. Returns ErrTxnTooLarge if the
+// write exceeds the MaxTxnWrites or MaxTxnBytes option.
+func (t *Txn) buffer(w txnWrite, folded []byte) error {
+	opts := t.arc.opts
+	size := t.size + len(w.key) + len(w.value)
+	prev, replaced := t.writes[string(folded)]
+
+	if replaced {
+		size -= len(prev.key) + len(prev.value)
+	} else if opts.MaxTxnWrites > 0 && len(t.writes) >= opts.MaxTxnWrites {
+		return ErrTxnTooLarge
+	}
+
+	if opts.MaxTxnBytes > 0 && size > opts.MaxTxnBytes {
+		return &SizeError{Err: ErrTxnTooLarge, Size: size, Limit: opts.MaxTxnBytes}
+	}
+
+	t.writes[string(folded)] = w
+	t.size = size
+
+	return nil
+}
+
+// Commit applies the writes of the transaction atomically, and closes it.
+// Records that the transaction deleted, but that were deleted concurrently,
+// are skipped. Writes are persisted according to the SyncPolicy. Returns
+// ErrConflict if an optimistic transaction conflicts with another writer, in
+// which case none of its writes are applied. Returns ErrBusy if the commit is
+// rejected by the BackpressurePolicy, in which case the transaction remains
+// open.
+func (t *Txn) Commit() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkOpen(); err != nil {
+		return err
+	}
+
+	a := t.arc
+
+	// Pessimistic transactions cannot wait for a save, which needs the lock
+	// that they hold, therefore they are not throttled.
+	if t.mode == TxnOptimistic {
+		if err := a.throttle(); err != nil {
+			return err
+		}
+
+		a.lock()
+	}
+
+	err := t.commit()
+	t.close()
+	a.mu.Unlock()
+
+	if err != nil || len(t.writes) == 0 {
+		return err
+	}
+
+	// The commit is persisted like any other write.
+	return a.syncWrite(OpPut)
+}
+
+// commit validates the reads of an optimistic transaction, and applies the
+// writes in key order. If a write fails, the writes that were applied before
+// it are undone by writing the records back, which the subscribers observe as
+// further changes. The caller must hold the write lock.
+func (t *Txn) commit() error {
+	a := t.arc
+
+	for key, seq := range t.reads {
+		if a.txnWrites[key] > seq {
+			return ErrConflict
+		}
+	}
+
+	keys := make([]string, 0, len(t.writes))
+
+	for key := range t.writes {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	if err := t.checkQuotas(keys); err != nil {
+		return err
+	}
+
+	if err := t.checkShape(keys); err != nil {
+		return err
+	}
+
+	// The writes are checked as they are applied, therefore the state of
+	// each record is saved beforehand, so that a failed write can put the
+	// records that were already written back the way they were.
+	undo := make([]*txnUndo, 0, len(keys))
+
+	for _, key := range keys {
+		u := a.saveUndo([]byte(key))
+
+		if err := t.apply(t.writes[key]); err != nil {
+			u.discard(a.blobs)
+
+			if undoErr := a.undo(undo); undoErr != nil {
+				return errors.Join(err, undoErr)
+			}
+
+			return err
+		}
+
+		undo = append(undo, u)
+	}
+
+	for _, u := range undo {
+		u.discard(a.blobs)
+	}
+
+	return nil
+}
+
+// apply applies the buffered write. Records that were deleted concurrently
+// are skipped. The caller must hold the write lock.
+func (t *Txn) apply(w txnWrite) error {
+	if !w.deleted {
+		return t.arc.putRecord(w.key, w.value, true)
+	}
+
+	if err := t.arc.deleteRecord(w.key); err != nil && err != ErrKeyNotFound {
+		return err
+	}
+
+	return nil
+}
+
+// txnUndo holds the state of a record before a commit wrote to it. The value
+// and the previous values are detached from the tree, which keeps their blobs
+// referenced until the undo is discarded.
+type txnUndo struct {
+	key        []byte // Folded key.
+	original   []byte
+	value      *node // Nil if there was no record.
+	flags      uint8
+	expiration time.Time
+	versions   []*node
+	tombstone  tombstone
+	buried     bool
+	timestamps *recordTimestamps
+}
+
+// saveUndo returns the state of the record that matches the folded key. The
+// caller must hold the write lock.
+func (a *Arc) saveUndo(key []byte) *txnUndo {
+	ret := &txnUndo{key: key, original: a.originalKey(key)}
+	ret.tombstone, ret.buried = a.tombstones[string(key)]
+
+	if ts, found := a.timestamps[string(key)]; found {
+		copied := *ts
+		ret.timestamps = &copied
+	}
+
+	n, _, err := a.findNodeAndParent(key)
+
+	if err != nil || !n.isRecord {
+		return ret
+	}
+
+	ret.value = a.copyValue(n)
+	ret.flags = n.userFlags
+	ret.expiration = a.expirations[string(key)]
+
+	for _, v := range a.versions[string(key)] {
+		ret.versions = append(ret.versions, a.copyValue(v))
+	}
+
+	return ret
+}
+
+// undo puts the records back the way they were before the commit wrote to
+// them, in the reverse order of the writes. The caller must hold the write
+// lock.
+func (a *Arc) undo(undo []*txnUndo) error {
+	var errs []error
+
+	for _, u := range slices.Backward(undo) {
+		errs = append(errs, a.restore(u))
+	}
+
+	return errors.Join(errs...)
+}
+
+// restore puts the record back the way it was when the undo was saved. The
+// undo is discarded. The caller must hold the write lock.
+func (a *Arc) restore(u *txnUndo) error {
+	defer u.discard(a.blobs)
+
+	if u.value == nil {
+		if err := a.deleteRecord(u.key); err != nil && err != ErrKeyNotFound {
+			return err
+		}
+	} else {
+		value, err := a.nodeValue(u.value)
+
+		if err != nil {
+			return err
+		}
+
+		if err := a.putRecord(u.original, value, true); err != nil {
+			return err
+		}
+
+		// The saved previous values replace the ones that the writes added,
+		// along with their blob references.
+		a.forgetVersions(u.key)
+
+		if len(u.versions) > 0 {
+			a.versions[string(u.key)] = u.versions
+			u.versions = nil
+		}
+
+		if err := a.setRecordFlags(u.key, u.flags); err != nil {
+			return err
+		}
+
+		if !u.expiration.IsZero() {
+			if err := a.expireRecord(u.key, u.expiration); err != nil {
+				return err
+			}
+		}
+	}
+
+	if u.buried {
+		if a.tombstones == nil {
+			a.tombstones = map[string]tombstone{}
+		}
+
+		a.tombstones[string(u.key)] = u.tombstone
+	} else {
+		delete(a.tombstones, string(u.key))
+	}
+
+	if a.timestamps != nil {
+		if u.timestamps != nil {
+			a.timestamps[string(u.key)] = u.timestamps
+		} else {
+			delete(a.timestamps, string(u.key))
+		}
+	}
+
+	return nil
+}
+
+// discard releases the blobs of the values that the undo holds.
+func (u *txnUndo) discard(bs blobStore) {
+	if u.value != nil {
+		u.value.deleteValue(bs)
+	}
+
+	for _, v := range u.versions {
+		v.deleteValue(bs)
+	}
+
+	u.value = nil
+	u.versions = nil
+}
+
+// checkShape returns a ShapeError if one of the writes to the given keys would
+// exceed MaxDepth or MaxChildrenPerNode on its own, before any of them is
+// applied. Writes that only exceed a limit once combined are rejected as they
+// are applied, and undone. The caller must hold the write lock.
+func (t *Txn) checkShape(keys []string) error {
+	for _, key := range keys {
+		if t.writes[key].deleted {
+			continue
+		}
+
+		if err := t.arc.checkShape([]byte(key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkQuotas returns ErrQuotaExceeded if the writes to the given keys would
+// exceed a quota once combined, which keeps the commit atomic. The caller must
+// hold the write lock.
+func (t *Txn) checkQuotas(keys []string) error {
+	a := t.arc
+
+	if len(a.quotas) == 0 {
+		return nil
+	}
+
+	type charge struct {
+		key           []byte
+		records, size int64
+	}
+
+	var charges []charge
+	var err error
+
+	// Charge the writes one at a time to check them against the combined
+	// usage, and then revert the charges.
+	for _, key := range keys {
+		w := t.writes[key]
+		valueSize := len(w.value)
+
+		if w.deleted {
+			valueSize = -1
+		}
+
+		records, size := a.quotaDelta([]byte(key), valueSize)
+
+		if err = a.checkQuotas([]byte(key), records, size); err != nil {
+			break
+		}
+
+		a.chargeQuotas([]byte(key), records, size)
+		charges = append(charges, charge{[]byte(key), records, size})
+	}
+
+	for _, c := range charges {
+		a.chargeQuotas(c.key, -c.records, -c.size)
+	}
+
+	return err
+}
+
+// Rollback discards the writes of the transaction, and closes it. It is a
+// no-op if the transaction is already closed.
+func (t *Txn) Rollback() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return
+	}
+
+	if t.mode == TxnOptimistic {
+		t.arc.mu.Lock()
+	}
+
+	t.close()
+	t.writes = nil
+	t.arc.mu.Unlock()
+}
+
+// close marks the transaction as closed, and stops tracking the writes once
+// the last optimistic transaction is closed. The caller must hold the write
+// lock.
+func (t *Txn) close() {
+	t.closed = true
+
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+
+	if t.mode != TxnOptimistic {
+		return
+	}
+
+	if t.arc.optimisticTxns--; t.arc.optimisticTxns == 0 {
+		t.arc.txnWrites = nil
+	}
+}
+
+// expire rolls back the transaction once the MaxTxnDuration option elapses.
+// It runs on the goroutine of the deadline timer.
+func (t *Txn) expire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return
+	}
+
+	if t.mode == TxnOptimistic {
+		t.arc.mu.Lock()
+	}
+
+	t.close()
+	t.expired = true
+	t.writes = nil
+	t.arc.mu.Unlock()
+}
+
+// checkOpen returns ErrTxnExpired or ErrTxnClosed if the transaction was
+// closed. The caller must hold the lock of the transaction.
+func (t *Txn) checkOpen() error {
+	if t.expired {
+		return ErrTxnExpired
+	}
+
+	if t.closed {
+		return ErrTxnClosed
+	}
+
+	return nil
+}
+
+// noteTxnWrite records the sequence number of the write to the given key for
+// the active optimistic transactions. The caller must hold the write lock.
+func (a *Arc) noteTxnWrite(key []byte) {
+	if a.txnWrites != nil {
+		a.txnWrites[string(key)] = a.seq
+	}
+}
@@ -0,0 +1,413 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTxnReadYourWrites(t *testing.T) {
+	arc := New()
+	arc.Put([]byte("apple"), []byte("red"))
+	arc.Put([]byte("banana"), []byte("yellow"))
+
+	txn := arc.Begin()
+
+	txn.Put([]byte("apple"), []byte("green"))
+	txn.Put([]byte("cherry"), blobValueX())
+
+	if err := txn.Delete([]byte("banana")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		key     string
+		txnWant []byte
+		arcWant []byte
+	}{
+		{"apple", []byte("green"), []byte("red")},
+		{"banana", nil, []byte("yellow")},
+		{"cherry", blobValueX(), nil},
+	}
+
+	for _, test := range tests {
+		got, err := txn.Get([]byte(test.key))
+
+		if test.txnWant == nil && err != ErrKeyNotFound {
+			t.Errorf("txn %q: unexpected error: got:%v, want:%v", test.key, err, ErrKeyNotFound)
+		} else if !bytes.Equal(got, test.txnWant) {
+			t.Errorf("txn %q: unexpected value: got:%q, want:%q", test.key, got, test.txnWant)
+		}
+
+		// The writes are invisible outside of the transaction.
+		got, err = arc.Get([]byte(test.key))
+
+		if test.arcWant == nil && !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("arc %q: unexpected error: got:%v, want:%v", test.key, err, ErrKeyNotFound)
+		} else if !bytes.Equal(got, test.arcWant) {
+			t.Errorf("arc %q: unexpected value: got:%q, want:%q", test.key, got, test.arcWant)
+		}
+	}
+
+	// Deleting a key that the transaction already deleted fails.
+	if err := txn.Delete([]byte("banana")); err != ErrKeyNotFound {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, test := range tests {
+		got, err := arc.Get([]byte(test.key))
+
+		if test.txnWant == nil && !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("committed %q: unexpected error: got:%v, want:%v", test.key, err, ErrKeyNotFound)
+		} else if !bytes.Equal(got, test.txnWant) {
+			t.Errorf("committed %q: unexpected value: got:%q, want:%q", test.key, got, test.txnWant)
+		}
+	}
+
+	if err := txn.Commit(); err != ErrTxnClosed {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrTxnClosed)
+	}
+}
+
+func TestTxnRollback(t *testing.T) {
+	arc := New()
+	txn := arc.Begin()
+
+	txn.Put([]byte("key"), []byte("value"))
+	txn.Rollback()
+
+	if _, err := txn.Get([]byte("key")); err != ErrTxnClosed {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrTxnClosed)
+	}
+
+	if err := txn.Put([]byte("key"), []byte("value")); err != ErrTxnClosed {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrTxnClosed)
+	}
+
+	if arc.Len() != 0 {
+		t.Errorf("unexpected length: got:%d, want:%d", arc.Len(), 0)
+	}
+}
+
+func TestTxnKeyTransform(t *testing.T) {
+	arc, _ := NewWithOptions(Options{CaseInsensitiveKeys: true})
+	txn := arc.Begin()
+
+	txn.Put([]byte("Apple"), []byte("red"))
+
+	if got, _ := txn.Get([]byte("APPLE")); string(got) != "red" {
+		t.Errorf("unexpected value: got:%q, want:%q", got, "red")
+	}
+
+	txn.Commit()
+
+	if got := slices.Collect(arc.Keys(nil)); len(got) != 1 || string(got[0]) != "Apple" {
+		t.Errorf("unexpected keys: %q", got)
+	}
+}
+
+func TestTxnSizeLimits(t *testing.T) {
+	arc, _ := NewWithOptions(Options{MaxKeyBytes: 2})
+	txn := arc.Begin()
+
+	if err := txn.Put([]byte("key"), nil); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestTxnOptimisticConflict(t *testing.T) {
+	arc := New()
+	arc.Put([]byte("balance"), []byte("10"))
+
+	txn := arc.Begin()
+
+	if _, err := txn.Get([]byte("balance")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A concurrent write to a key that was read is a conflict.
+	arc.Put([]byte("balance"), []byte("20"))
+	txn.Put([]byte("balance"), []byte("11"))
+
+	if err := txn.Commit(); err != ErrConflict {
+		t.Fatalf("unexpected error: got:%v, want:%v", err, ErrConflict)
+	}
+
+	if got, _ := arc.Get([]byte("balance")); string(got) != "20" {
+		t.Errorf("unexpected value: got:%q, want:%q", got, "20")
+	}
+
+	// Writes to keys that were not read are not conflicts.
+	txn = arc.Begin()
+	txn.Get([]byte("balance"))
+	arc.Put([]byte("other"), []byte("value"))
+	txn.Put([]byte("balance"), []byte("21"))
+
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, _ := arc.Get([]byte("balance")); string(got) != "21" {
+		t.Errorf("unexpected value: got:%q, want:%q", got, "21")
+	}
+
+	if arc.txnWrites != nil {
+		t.Error("expected the writes to be untracked once the transactions are closed")
+	}
+}
+
+func TestTxnPessimistic(t *testing.T) {
+	arc := New()
+	arc.Put([]byte("counter"), []byte("0"))
+
+	txn := arc.BeginWithMode(TxnPessimistic)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		arc.Put([]byte("counter"), []byte("2"))
+	}()
+
+	// The concurrent write is blocked until the transaction is committed.
+	if got, _ := txn.Get([]byte("counter")); string(got) != "0" {
+		t.Errorf("unexpected value: got:%q, want:%q", got, "0")
+	}
+
+	txn.Put([]byte("counter"), []byte("1"))
+
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	<-done
+
+	if got, _ := arc.Get([]byte("counter")); string(got) != "2" {
+		t.Errorf("unexpected value: got:%q, want:%q", got, "2")
+	}
+}
+
+func TestTxnCommitUndo(t *testing.T) {
+	for _, mode := range []TxnMode{TxnOptimistic, TxnPessimistic} {
+		arc, _ := NewWithOptions(Options{MaxChildrenPerNode: 2, VersionsToKeep: 1, RecordTimestamps: true})
+		expiresAt := time.Now().Add(time.Hour)
+
+		arc.Put([]byte("k1"), []byte("previous"))
+		arc.PutWithFlags([]byte("k1"), blobValueX(), 3)
+		arc.ExpireAt([]byte("k1"), expiresAt)
+		arc.Put([]byte("k2"), []byte("value"))
+
+		before, _ := arc.Stat([]byte("k1"))
+		blobs := arc.BlobStats()
+		hash := arc.RootHash()
+
+		// The deletion makes room for k3, but k4 exceeds the shape limit
+		// after the other writes were applied.
+		txn := arc.BeginWithMode(mode)
+		txn.Put([]byte("k1"), bytes.Repeat([]byte("v"), inlineValueThreshold*2))
+		txn.Delete([]byte("k2"))
+		txn.Put([]byte("k3"), []byte("value"))
+		txn.Put([]byte("k4"), []byte("value"))
+
+		if err := txn.Commit(); !errors.Is(err, ErrTooManyChildren) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if arc.Len() != 2 {
+			t.Errorf("unexpected length: got:%d, want:2", arc.Len())
+		}
+
+		if got, _ := arc.Get([]byte("k1")); !bytes.Equal(got, blobValueX()) {
+			t.Errorf("unexpected value: %q", got)
+		}
+
+		if got, _ := arc.Versions([]byte("k1")); len(got) != 2 || string(got[1]) != "previous" {
+			t.Errorf("unexpected versions: %q", got)
+		}
+
+		if got, _ := arc.Get([]byte("k2")); string(got) != "value" {
+			t.Errorf("unexpected value: %q", got)
+		}
+
+		if after, _ := arc.Stat([]byte("k1")); after.Flags != 3 || !after.ExpiresAt.Equal(before.ExpiresAt) || after.CreatedAt != before.CreatedAt || after.UpdatedAt != before.UpdatedAt {
+			t.Errorf("unexpected metadata: got:%+v, want:%+v", after, before)
+		}
+
+		if got := arc.BlobStats(); got.NumBlobs != blobs.NumBlobs || got.LogicalBytes != blobs.LogicalBytes {
+			t.Errorf("unexpected blob stats: got:%+v, want:%+v", got, blobs)
+		}
+
+		if !bytes.Equal(arc.RootHash(), hash) {
+			t.Errorf("unexpected root hash")
+		}
+	}
+}
+
+func TestTxnCommitShape(t *testing.T) {
+	arc, _ := NewWithOptions(Options{MaxChildrenPerNode: 2})
+	arc.Put([]byte("k1"), []byte("value"))
+	arc.Put([]byte("k2"), []byte("value"))
+
+	seq := arc.Seq()
+
+	// The write to k3 exceeds the limit on its own, which is detected before
+	// the write to j is applied.
+	txn := arc.Begin()
+	txn.Put([]byte("j"), []byte("value"))
+	txn.Put([]byte("k3"), []byte("value"))
+
+	if err := txn.Commit(); !errors.Is(err, ErrTooManyChildren) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if arc.Seq() != seq {
+		t.Errorf("expected nothing to be written: got:%d, want:%d", arc.Seq(), seq)
+	}
+
+	if arc.Len() != 2 {
+		t.Errorf("unexpected length: got:%d, want:2", arc.Len())
+	}
+}
+
+func TestTxnLimits(t *testing.T) {
+	t.Run("max writes", func(t *testing.T) {
+		arc, _ := NewWithOptions(Options{MaxTxnWrites: 2})
+		txn := arc.Begin()
+
+		txn.Put([]byte("a"), nil)
+		txn.Put([]byte("b"), nil)
+
+		if err := txn.Put([]byte("c"), nil); err != ErrTxnTooLarge {
+			t.Errorf("unexpected error: got:%v, want:%v", err, ErrTxnTooLarge)
+		}
+
+		// Replacing a buffered write does not count towards the limit.
+		if err := txn.Put([]byte("a"), []byte("value")); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if err := txn.Commit(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if arc.Len() != 2 {
+			t.Errorf("unexpected length: got:%d, want:%d", arc.Len(), 2)
+		}
+	})
+
+	t.Run("max bytes", func(t *testing.T) {
+		arc, _ := NewWithOptions(Options{MaxTxnBytes: 10})
+		txn := arc.Begin()
+
+		if err := txn.Put([]byte("key"), []byte("value")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var sizeErr *SizeError
+
+		if err := txn.Put([]byte("other"), []byte("value")); !errors.As(err, &sizeErr) || sizeErr.Err != ErrTxnTooLarge {
+			t.Fatalf("unexpected error: got:%v, want:%v", err, ErrTxnTooLarge)
+		}
+
+		if sizeErr.Size != 18 || sizeErr.Limit != 10 {
+			t.Errorf("unexpected size error: %v", sizeErr)
+		}
+
+		// The size of the replaced write is released.
+		if err := txn.Put([]byte("key"), []byte("small")); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("max duration", func(t *testing.T) {
+		arc, _ := NewWithOptions(Options{MaxTxnDuration: 10 * time.Millisecond})
+		txn := arc.BeginWithMode(TxnPessimistic)
+
+		txn.Put([]byte("key"), []byte("value"))
+
+		// The write lock held by the transaction is released once it expires.
+		if err := arc.Put([]byte("other"), []byte("value")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := txn.Commit(); err != ErrTxnExpired {
+			t.Errorf("unexpected error: got:%v, want:%v", err, ErrTxnExpired)
+		}
+
+		if _, err := arc.Get([]byte("key")); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+		}
+	})
+}
+
+func TestTxnScan(t *testing.T) {
+	arc, _ := NewWithOptions(Options{CaseInsensitiveKeys: true})
+	arc.Put([]byte("fruit/Apple"), []byte("red"))
+	arc.Put([]byte("fruit/banana"), []byte("yellow"))
+	arc.Put([]byte("vegetable/kale"), []byte("green"))
+
+	txn := arc.Begin()
+	defer txn.Rollback()
+
+	txn.Put([]byte("fruit/apple"), []byte("green"))
+	txn.Put([]byte("fruit/cherry"), []byte("red"))
+	txn.Delete([]byte("fruit/banana"))
+
+	var got []string
+
+	for key, value := range txn.Scan([]byte("FRUIT/")) {
+		got = append(got, string(key)+"="+string(value))
+
+		// Writes within the loop do not affect the iteration.
+		txn.Put([]byte("fruit/date"), nil)
+	}
+
+	if want := []string{"fruit/apple=green", "fruit/cherry=red"}; !slices.Equal(got, want) {
+		t.Errorf("unexpected records: got:%q, want:%q", got, want)
+	}
+
+	if txn.Len() != 4 || arc.Len() != 3 {
+		t.Errorf("unexpected length: txn:%d, arc:%d", txn.Len(), arc.Len())
+	}
+
+	txn.Rollback()
+
+	for range txn.Scan(nil) {
+		t.Errorf("expected a closed transaction to yield nothing")
+	}
+
+	if txn.Len() != 0 {
+		t.Errorf("unexpected length: %d", txn.Len())
+	}
+}
+
+func TestTxnScanPessimistic(t *testing.T) {
+	arc, _ := NewWithOptions(Options{ReverseOrder: true})
+	arc.Put([]byte("apple"), nil)
+	arc.Put([]byte("cherry"), nil)
+
+	txn := arc.BeginWithMode(TxnPessimistic)
+	defer txn.Rollback()
+
+	txn.Put([]byte("banana"), nil)
+
+	got := toStrings(slices.Collect(func(yield func([]byte) bool) {
+		for key := range txn.Scan(nil) {
+			if !yield(key) {
+				return
+			}
+		}
+	}))
+
+	if want := []string{"cherry", "banana", "apple"}; !slices.Equal(got, want) {
+		t.Errorf("unexpected keys: got:%q, want:%q", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestUint64Keys(t *testing.T) {
+	arc := New()
+	ids := []uint64{0, 1, 255, 256, 1 << 32, math.MaxUint64 - 1, math.MaxUint64}
+
+	for _, id := range ids {
+		if err := arc.PutUint64(id, []byte(fmt.Sprint(id))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	for _, id := range ids {
+		if value, err := arc.GetUint64(id); err != nil || string(value) != fmt.Sprint(id) {
+			t.Errorf("unexpected value of %d: got:%q, err:%v", id, value, err)
+		}
+	}
+
+	if _, err := arc.GetUint64(2); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+
+	// Keys of other lengths within the range are skipped.
+	arc.Put([]byte{0, 0, 0, 0, 0, 0, 1}, nil)
+	arc.Put([]byte{0, 0, 0, 0, 0, 0, 0, 1, 0}, nil)
+	arc.Put([]byte{0}, nil)
+	arc.Put([]byte("zzzzzzzzz"), nil)
+
+	testCases := []struct {
+		start, end uint64
+		want       []uint64
+	}{
+		{start: 0, end: math.MaxUint64, want: ids},
+		{start: 1, end: 256, want: []uint64{1, 255, 256}},
+		{start: 2, end: 255, want: []uint64{255}},
+		{start: 257, end: 1<<32 - 1, want: nil},
+		{start: math.MaxUint64, end: math.MaxUint64, want: []uint64{math.MaxUint64}},
+		{start: 256, end: 1, want: nil},
+	}
+
+	for _, tc := range testCases {
+		var got []uint64
+
+		for id, value := range arc.ScanUint64Range(tc.start, tc.end) {
+			if string(value) != fmt.Sprint(id) {
+				t.Errorf("unexpected value of %d: %q", id, value)
+			}
+
+			got = append(got, id)
+		}
+
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("unexpected ids in [%d, %d]: got:%v, want:%v", tc.start, tc.end, got, tc.want)
+		}
+	}
+}
+
+func TestUint64KeysReverseOrder(t *testing.T) {
+	arc, _ := NewWithOptions(Options{ReverseOrder: true})
+
+	for id := range uint64(10) {
+		arc.PutUint64(id, nil)
+	}
+
+	arc.Put([]byte{0, 0, 0, 0, 0, 0, 0, 7, 0}, nil)
+
+	var got []uint64
+
+	for id := range arc.ScanUint64Range(3, 7) {
+		got = append(got, id)
+	}
+
+	if want := []uint64{7, 6, 5, 4, 3}; !slices.Equal(got, want) {
+		t.Errorf("unexpected ids: got:%v, want:%v", got, want)
+	}
+
+	got = nil
+
+	for id := range arc.ScanUint64Range(8, math.MaxUint64) {
+		got = append(got, id)
+	}
+
+	if want := []uint64{9, 8}; !slices.Equal(got, want) {
+		t.Errorf("unexpected ids: got:%v, want:%v", got, want)
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+)
+
+// ErrVersionNotFound is returned when a record has fewer previous values than
+// the requested version.
+var ErrVersionNotFound = errors.New("version not found")
+
+// GetVersion returns the nth most recent value of the record that matches the
+// given key, where zero is the current value, one is the value that it
+// replaced, and so on. Previous values are only kept when the VersionsToKeep
+// option is enabled. Returns ErrKeyNotFound if the key does not exist, and
+// ErrVersionNotFound if the record has fewer than n previous values.
+func (a *Arc) GetVersion(key []byte, n int) ([]byte, error) {
+	var ret []byte
+
+	key = a.transformKey(key)
+
+	err := a.runHooks(OpInfo{Op: OpGet, Key: key}, func(info *OpInfo) error {
+		values, err := a.versionsOf(key, n)
+
+		if err != nil {
+			return err
+		}
+
+		if n < 0 || n >= len(values) {
+			return ErrVersionNotFound
+		}
+
+		ret = values[n]
+		info.ValueSize = len(ret)
+
+		return nil
+	})
+
+	return ret, err
+}
+
+// Versions returns the current value of the record that matches the given key,
+// followed by its previous values from the most recent to the oldest. Returns
+// ErrKeyNotFound if the key does not exist.
+func (a *Arc) Versions(key []byte) ([][]byte, error) {
+	var ret [][]byte
+
+	key = a.transformKey(key)
+
+	err := a.runHooks(OpInfo{Op: OpGet, Key: key}, func(info *OpInfo) error {
+		var err error
+
+		if ret, err = a.versionsOf(key, -1); err != nil {
+			return err
+		}
+
+		for _, value := range ret {
+			info.ValueSize += len(value)
+		}
+
+		return nil
+	})
+
+	return ret, err
+}
+
+// versionsOf returns the current value of the record followed by up to limit
+// previous values, or all of them if limit is negative.
+func (a *Arc) versionsOf(key []byte, limit int) ([][]byte, error) {
+	if err := a.checkKey(key); err != nil {
+		return nil, err
+	}
+
+	a.rlock()
+	defer a.runlock()
+
+	current, err := a.lookup(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	previous := a.versions[string(key)]
+
+	if limit >= 0 && limit < len(previous) {
+		previous = previous[:limit]
+	}
+
+	ret := [][]byte{current}
+
+	for _, v := range previous {
+		value, err := a.nodeValue(v)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, value)
+	}
+
+	return ret, nil
+}
+
+// detachValue returns a detached node that holds the current value of the
+// record, and references its blob like the record node, or nil if versions
+// are not kept or the record does not exist. It is called before the record
+// is overwritten, and the caller must either keep the returned node using
+// keepVersion or release its value. The caller must hold the write lock.
+func (a *Arc) detachValue(key []byte) *node {
+	if a.opts.VersionsToKeep == 0 {
+		return nil
+	}
+
+	n, _, err := a.findNodeAndParent(key)
+
+	if err != nil || !n.isRecord {
+		return nil
+	}
+
+	return a.copyValue(n)
+}
+
+// copyValue returns a node that holds the value of the given node, outside of
+// the tree. Blob values are retained until the copy is released with
+// deleteValue. The caller must hold the write lock.
+func (a *Arc) copyValue(n *node) *node {
+	if n.blobValue {
+		a.blobs.retain(n.data)
+	}
+
+	return &node{isRecord: true, data: n.data, blobValue: n.blobValue}
+}
+
+// keepVersion adds the detached value as the most recent previous value of
+// the record, and releases the values beyond the VersionsToKeep. The caller
+// must hold the write lock.
+func (a *Arc) keepVersion(key []byte, v *node) {
+	if a.versions == nil {
+		a.versions = map[string][]*node{}
+	}
+
+	versions := append([]*node{v}, a.versions[string(key)]...)
+
+	for _, old := range versions[min(len(versions), a.opts.VersionsToKeep):] {
+		old.deleteValue(a.blobs)
+	}
+
+	a.versions[string(key)] = versions[:min(len(versions), a.opts.VersionsToKeep)]
+}
+
+// forgetVersions releases the previous values of the record. The caller must
+// hold the write lock.
+func (a *Arc) forgetVersions(key []byte) {
+	for _, v := range a.versions[string(key)] {
+		v.deleteValue(a.blobs)
+	}
+
+	delete(a.versions, string(key))
+}
+
+// serializeVersions serializes the version section, which consists of the
+// number of records with previous values, the records in key order, and the
+// checksum of the preceding bytes. Each record holds the key length, the key,
+// the number of previous values, and the previous values from the most recent
+// to the oldest. Each value holds the node flags, the data length, and the
+// data, which is the blobID of blob values. The caller must hold the read
+// lock.
+func (a *Arc) serializeVersions() ([]byte, error) {
+	keys := make([]string, 0, len(a.versions))
+
+	for key := range a.versions {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	ret := binary.LittleEndian.AppendUint64(nil, uint64(len(keys)))
+
+	for _, key := range keys {
+		ret = binary.LittleEndian.AppendUint16(ret, uint16(len(key)))
+		ret = append(ret, key...)
+		ret = binary.LittleEndian.AppendUint16(ret, uint16(len(a.versions[key])))
+
+		for _, v := range a.versions[key] {
+			ret = append(ret, makePersistentNode(*v).flags)
+			ret = binary.LittleEndian.AppendUint32(ret, uint32(len(v.data)))
+			ret = append(ret, v.data...)
+		}
+	}
+
+	checksum, err := computeChecksum(ret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return binary.LittleEndian.AppendUint32(ret, checksum), nil
+}
+
+// readVersions loads the version section produced by serializeVersions, and
+// resolves the blobs of the previous values from contents. The records must
+// already be loaded. Values beyond the VersionsToKeep are dropped.
+func (a *Arc) readVersions(src []byte, contents map[blobID][]byte) error {
+	if len(src) < sizeOfUint64+checksumLen {
+		return ErrCorrupted
+	}
+
+	checksumPos := len(src) - checksumLen
+	checksum, err := computeChecksum(src[:checksumPos])
+
+	if err != nil {
+		return err
+	}
+
+	if checksum != binary.LittleEndian.Uint32(src[checksumPos:]) {
+		return ErrInvalidChecksum
+	}
+
+	r := bytes.NewReader(src[:checksumPos])
+	d := snapshotDecoder{arc: a, contents: contents, blobs: a.blobs}
+
+	var count uint64
+
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return ErrCorrupted
+	}
+
+	for i := uint64(0); i < count; i++ {
+		var keyLen uint16
+
+		if err := binary.Read(r, binary.LittleEndian, &keyLen); err != nil {
+			return ErrCorrupted
+		}
+
+		key := make([]byte, keyLen)
+
+		if _, err := io.ReadFull(r, key); err != nil {
+			return ErrCorrupted
+		}
+
+		if n, _, err := a.findNodeAndParent(key); err != nil || !n.isRecord {
+			return ErrCorrupted
+		}
+
+		var numVersions uint16
+
+		if err := binary.Read(r, binary.LittleEndian, &numVersions); err != nil || numVersions == 0 {
+			return ErrCorrupted
+		}
+
+		for j := 0; j < int(numVersions); j++ {
+			var flags uint8
+			var dataLen uint32
+
+			if err := binary.Read(r, binary.LittleEndian, &flags); err != nil {
+				return ErrCorrupted
+			}
+
+			if err := binary.Read(r, binary.LittleEndian, &dataLen); err != nil {
+				return ErrCorrupted
+			}
+
+			if int64(dataLen) > int64(r.Len()) {
+				return ErrCorrupted
+			}
+
+			v := &node{isRecord: true, data: make([]byte, dataLen), blobValue: flags&flagHasBlob != 0}
+
+			if _, err := io.ReadFull(r, v.data); err != nil {
+				return ErrCorrupted
+			}
+
+			if dataLen == 0 && flags&flagEmptyValue == 0 {
+				v.data = nil
+			}
+
+			if j >= a.opts.VersionsToKeep {
+				continue
+			}
+
+			if v.blobValue {
+				if err := d.attachBlob(v); err != nil {
+					return err
+				}
+			}
+
+			if a.versions == nil {
+				a.versions = map[string][]*node{}
+			}
+
+			a.versions[string(key)] = append(a.versions[string(key)], v)
+		}
+	}
+
+	if r.Len() != 0 {
+		return ErrCorrupted
+	}
+
+	return nil
+}
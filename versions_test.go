@@ -0,0 +1,157 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestVersions(t *testing.T) {
+	arc := New()
+	arc.Put([]byte("key"), []byte("v1"))
+	arc.Put([]byte("key"), []byte("v2"))
+
+	if _, err := arc.GetVersion([]byte("key"), 1); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrVersionNotFound)
+	}
+
+	arc, _ = NewWithOptions(Options{VersionsToKeep: 2})
+
+	if _, err := arc.Versions([]byte("key")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error: got:%v, want:%v", err, ErrKeyNotFound)
+	}
+
+	for _, value := range []string{"v1", "v2", "v3", "v4"} {
+		arc.Put([]byte("key"), []byte(value))
+	}
+
+	// Failed writes keep no version.
+	arc.Add([]byte("key"), []byte("v5"))
+
+	got, err := arc.Versions([]byte("key"))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"v4", "v3", "v2"}
+
+	if len(got) != len(want) {
+		t.Fatalf("unexpected number of versions: got:%d, want:%d", len(got), len(want))
+	}
+
+	for i := range want {
+		if string(got[i]) != want[i] {
+			t.Errorf("unexpected version %d: got:%q, want:%q", i, got[i], want[i])
+		}
+
+		if value, _ := arc.GetVersion([]byte("key"), i); string(value) != want[i] {
+			t.Errorf("unexpected version %d: got:%q, want:%q", i, value, want[i])
+		}
+	}
+
+	for _, n := range []int{-1, len(want)} {
+		if _, err := arc.GetVersion([]byte("key"), n); !errors.Is(err, ErrVersionNotFound) {
+			t.Errorf("unexpected error: got:%v, want:%v", err, ErrVersionNotFound)
+		}
+	}
+
+	// Deleting the record discards its versions.
+	arc.Delete([]byte("key"))
+	arc.Put([]byte("key"), []byte("v1"))
+
+	if got, _ := arc.Versions([]byte("key")); len(got) != 1 {
+		t.Errorf("unexpected number of versions: got:%d, want:%d", len(got), 1)
+	}
+}
+
+func TestVersionsBlobs(t *testing.T) {
+	arc, _ := NewWithOptions(Options{VersionsToKeep: 1})
+	large := bytes.Repeat([]byte("y"), 64)
+
+	arc.Put([]byte("a"), blobValueX())
+	arc.Put([]byte("b"), blobValueX())
+	arc.Put([]byte("a"), large)
+
+	// The previous value shares its blob with the record that still holds it.
+	if len(arc.blobs) != 2 || arc.blobs[makeBlobID(blobValueX())].refCount != 2 {
+		t.Fatalf("unexpected blobs after the overwrite")
+	}
+
+	if value, _ := arc.GetVersion([]byte("a"), 1); !bytes.Equal(value, blobValueX()) {
+		t.Errorf("unexpected previous value")
+	}
+
+	arc.Delete([]byte("b"))
+	arc.Put([]byte("a"), []byte("small"))
+
+	if len(arc.blobs) != 1 || arc.blobs[makeBlobID(large)].refCount != 1 {
+		t.Errorf("expected the dropped version to release its blob")
+	}
+
+	arc.Delete([]byte("a"))
+
+	if len(arc.blobs) != 0 {
+		t.Errorf("expected the deletion to release the blobs")
+	}
+}
+
+func TestVersionsPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.arc")
+	arc, _ := OpenWithOptions(path, Options{VersionsToKeep: 3})
+
+	arc.Put([]byte("key"), blobValueX())
+	arc.Put([]byte("key"), []byte{})
+	arc.Put([]byte("key"), []byte("v3"))
+	arc.Put([]byte("key"), []byte("v4"))
+
+	if err := arc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	arc, err := OpenWithOptions(path, Options{VersionsToKeep: 3})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, _ := arc.Versions([]byte("key"))
+	want := [][]byte{[]byte("v4"), []byte("v3"), {}, blobValueX()}
+
+	if len(got) != len(want) {
+		t.Fatalf("unexpected number of versions: got:%d, want:%d", len(got), len(want))
+	}
+
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("unexpected version %d: got:%q, want:%q", i, got[i], want[i])
+		}
+	}
+
+	if got[2] == nil {
+		t.Errorf("expected the empty version to be non-nil")
+	}
+
+	arc.Close()
+
+	// Opening with a lower limit drops the oldest versions.
+	arc, err = OpenWithOptions(path, Options{VersionsToKeep: 1})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer arc.Close()
+
+	if got, _ := arc.Versions([]byte("key")); len(got) != 2 {
+		t.Errorf("unexpected number of versions: got:%d, want:%d", len(got), 2)
+	}
+
+	if len(arc.blobs) != 0 {
+		t.Errorf("expected the dropped blob to be left out")
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import "bytes"
+
+// findPrefixNode returns the topmost node whose full key begins with the given
+// prefix, along with the full key of that node's parent. In other words, the
+// returned node is the root of the subtree that holds every key that begins
+// with prefix. It returns nil if no such subtree exists.
+func (a *Arc) findPrefixNode(prefix []byte) (n *node, parentKey []byte) {
+	if a.empty() {
+		return nil, nil
+	}
+
+	current := a.root
+
+	for {
+		// The remaining prefix ends within the current node's key.
+		if len(prefix) <= len(current.key) {
+			if !bytes.HasPrefix(current.key, prefix) {
+				return nil, nil
+			}
+
+			return current, parentKey
+		}
+
+		if !bytes.HasPrefix(prefix, current.key) {
+			return nil, nil
+		}
+
+		prefix = prefix[len(current.key):]
+		parentKey = append(parentKey, current.key...)
+
+		if current = current.findCompatibleChild(prefix); current == nil {
+			return nil, nil
+		}
+	}
+}
+
+// nodePath returns the nodes on the path to the given key, starting with the
+// root node. Every subtree that holds the key is rooted at one of these nodes,
+// since their full keys are the prefixes of the key.
+func (a *Arc) nodePath(key []byte) []*node {
+	var ret []*node
+
+	for n := a.root; n != nil && bytes.HasPrefix(key, n.key); {
+		ret = append(ret, n)
+		key = key[len(n.key):]
+
+		if len(key) == 0 {
+			break
+		}
+
+		n = n.findCompatibleChild(key)
+	}
+
+	return ret
+}
+
+// walkPrefix calls fn on every record whose key begins with the given prefix
+// in lexicographic order. Walking stops when fn returns false. The key passed
+// to fn is freshly allocated, therefore fn may retain it.
+func (a *Arc) walkPrefix(prefix []byte, fn func(key []byte, n *node) bool) {
+	n, parentKey := a.findPrefixNode(prefix)
+
+	if n == nil {
+		return
+	}
+
+	walkNode(n, parentKey, fn)
+}
+
+// walkPrefixReverse is like walkPrefix, but visits the records in reverse
+// lexicographic order.
+func (a *Arc) walkPrefixReverse(prefix []byte, fn func(key []byte, n *node) bool) {
+	n, parentKey := a.findPrefixNode(prefix)
+
+	if n == nil {
+		return
+	}
+
+	walkNodeReverse(n, parentKey, fn)
+}
+
+// walkNode recursively visits the given node and its descendants in pre-order,
+// which yields the records in lexicographic order since children are sorted
+// and every node's key is a prefix of its descendants' keys. It returns false
+// if the walk was stopped by fn.
+func walkNode(n *node, parentKey []byte, fn func(key []byte, n *node) bool) bool {
+	key := fullKey(parentKey, n)
+
+	if n.isRecord && !fn(key, n) {
+		return false
+	}
+
+	for child := n.firstChild; child != nil; child = child.nextSibling {
+		if !walkNode(child, key, fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// walkNodeReverse recursively visits the given node and its descendants in
+// reverse pre-order. The descendants are visited before the node, because a
+// node's key is a prefix of, and therefore sorts before, its descendants'
+// keys. It returns false if the walk was stopped by fn.
+func walkNodeReverse(n *node, parentKey []byte, fn func(key []byte, n *node) bool) bool {
+	key := fullKey(parentKey, n)
+	children := n.children()
+
+	for i := len(children) - 1; i >= 0; i-- {
+		if !walkNodeReverse(children[i], key, fn) {
+			return false
+		}
+	}
+
+	return !n.isRecord || fn(key, n)
+}
+
+// walkPrefixAfter is like walkPrefix, but only visits the records whose keys
+// are greater than the given key, which must begin with prefix. It is used to
+// resume an interrupted walk, and tolerates changes made to the tree since.
+func (a *Arc) walkPrefixAfter(prefix []byte, after []byte, fn func(key []byte, n *node) bool) {
+	n, parentKey := a.findPrefixNode(prefix)
+
+	if n == nil {
+		return
+	}
+
+	walkNodeAfter(n, parentKey, after[len(parentKey):], fn)
+}
+
+// walkPrefixBefore is like walkPrefixReverse, but only visits the records
+// whose keys are less than the given key, which must begin with prefix.
+func (a *Arc) walkPrefixBefore(prefix []byte, before []byte, fn func(key []byte, n *node) bool) {
+	n, parentKey := a.findPrefixNode(prefix)
+
+	if n == nil {
+		return
+	}
+
+	walkNodeBefore(n, parentKey, before[len(parentKey):], fn)
+}
+
+// walkNodeAfter visits the records in the subtree rooted at n whose keys are
+// greater than the target in lexicographic order. The target is relative to
+// parentKey, which is the full key of n's parent. Subtrees that only hold
+// smaller keys are skipped without being visited.
+func walkNodeAfter(n *node, parentKey []byte, target []byte, fn func(key []byte, n *node) bool) bool {
+	m := min(len(n.key), len(target))
+
+	switch bytes.Compare(n.key[:m], target[:m]) {
+	case 1:
+		return walkNode(n, parentKey, fn)
+	case -1:
+		return true
+	}
+
+	// The target is a proper prefix of the node's full key, therefore every
+	// key in the subtree is greater than the target.
+	if len(n.key) > len(target) {
+		return walkNode(n, parentKey, fn)
+	}
+
+	// The node's full key is a prefix of the target, therefore the node's
+	// own record is skipped, and only the children are considered.
+	key := fullKey(parentKey, n)
+
+	for child := n.firstChild; child != nil; child = child.nextSibling {
+		if !walkNodeAfter(child, key, target[len(n.key):], fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// walkNodeBefore visits the records in the subtree rooted at n whose keys are
+// less than the target in reverse lexicographic order. The target is relative
+// to parentKey, which is the full key of n's parent.
+func walkNodeBefore(n *node, parentKey []byte, target []byte, fn func(key []byte, n *node) bool) bool {
+	m := min(len(n.key), len(target))
+
+	switch bytes.Compare(n.key[:m], target[:m]) {
+	case -1:
+		return walkNodeReverse(n, parentKey, fn)
+	case 1:
+		return true
+	}
+
+	// Every key in the subtree is greater than or equal to the target if
+	// the node's full key is not a proper prefix of the target.
+	if len(n.key) >= len(target) {
+		return true
+	}
+
+	key := fullKey(parentKey, n)
+	children := n.children()
+
+	for i := len(children) - 1; i >= 0; i-- {
+		if !walkNodeBefore(children[i], key, target[len(n.key):], fn) {
+			return false
+		}
+	}
+
+	// The node's full key is a proper prefix of the target.
+	return !n.isRecord || fn(key, n)
+}
@@ -0,0 +1,98 @@
+// Copyright Chrono Technologies LLC
+// SPDX-License-Identifier: MIT
+
+package arc
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWalkPrefix(t *testing.T) {
+	arc := basicTestTree()
+
+	testCases := []struct {
+		name   string
+		prefix []byte
+		want   []string
+	}{
+		{name: "with nil prefix", prefix: nil, want: sortedBasicTestKeys()},
+		{name: "with non-record node prefix", prefix: []byte("ap"), want: []string{"apple", "applet", "application", "apricot"}},
+		{name: "with mid-node prefix", prefix: []byte("ban"), want: []string{"banana", "band", "bandage", "bandsaw"}},
+		{name: "with record key prefix", prefix: []byte("lemon"), want: []string{"lemon", "lemonade"}},
+		{name: "with exact leaf prefix", prefix: []byte("orange"), want: []string{"orange"}},
+		{name: "with unknown prefix", prefix: []byte("bogus"), want: nil},
+		{name: "with overlong prefix", prefix: []byte("oranges"), want: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+
+			arc.walkPrefix(tc.prefix, func(key []byte, n *node) bool {
+				got = append(got, string(key))
+				return true
+			})
+
+			assertKeys(t, got, tc.want)
+		})
+	}
+
+	// Walking must stop as soon as the callback returns false.
+	var visited int
+
+	arc.walkPrefix(nil, func(key []byte, n *node) bool {
+		visited++
+		return !bytes.Equal(key, []byte("band"))
+	})
+
+	if visited != 6 {
+		t.Errorf("unexpected visit count: got:%d, want:6", visited)
+	}
+}
+
+func sortedBasicTestKeys() []string {
+	var ret []string
+
+	for _, row := range basicTestTreeData() {
+		ret = append(ret, string(row.key))
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
+
+func TestWalkPrefixAfterAndBefore(t *testing.T) {
+	arc := basicTestTree()
+	sorted := sortedBasicTestKeys()
+
+	for _, prefix := range []string{"", "b", "band", "lemon"} {
+		var keys []string
+
+		for _, key := range sorted {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+
+		for i, pivot := range keys {
+			var after, before []string
+
+			arc.walkPrefixAfter([]byte(prefix), []byte(pivot), func(key []byte, n *node) bool {
+				after = append(after, string(key))
+				return true
+			})
+
+			arc.walkPrefixBefore([]byte(prefix), []byte(pivot), func(key []byte, n *node) bool {
+				before = append(before, string(key))
+				return true
+			})
+
+			assertKeys(t, after, keys[i+1:])
+			assertKeys(t, before, reversed(keys[:i]))
+		}
+	}
+}